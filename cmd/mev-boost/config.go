@@ -0,0 +1,381 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/flashbots/mev-boost/lib"
+	"github.com/flashbots/mev-boost/lib/client"
+	"github.com/sirupsen/logrus"
+)
+
+// validateFlags checks the parsed flags for malformed values and conflicting options, and returns all problems at once,
+// so a misconfiguration fails at startup instead of at the first proposal. Unknown flags are already rejected by flag.Parse.
+func validateFlags() []error {
+	var errs []error
+	fail := func(name, format string, args ...interface{}) {
+		errs = append(errs, fmt.Errorf("-%s: %s", name, fmt.Sprintf(format, args...)))
+	}
+	set := make(map[string]bool)
+	flag.Visit(func(f *flag.Flag) { set[f.Name] = true })
+
+	if *port <= 0 || *port > 65535 {
+		fail("port", "%d is not a valid port", *port)
+	}
+
+	if _, err := logrus.ParseLevel(*logLevel); err != nil {
+		fail("logLevel", "%v", err)
+	}
+
+	relays := splitList(*relayURLs)
+	if len(relays) == 0 {
+		fail("relayUrl", "no relay configured")
+	}
+	for _, relayURL := range relays {
+		if err := client.ValidateRelayEntry(relayURL); err != nil {
+			fail("relayUrl", "%v", err)
+		}
+	}
+	for _, relayURL := range splitList(*shadowRelayURLs) {
+		if err := client.ValidateRelayEntry(relayURL); err != nil {
+			fail("shadowRelayUrl", "%v", err)
+		}
+	}
+
+	urls := []struct{ name, value string }{
+		{"beaconNodeUrl", *beaconNodeURL},
+		{"executionNodeUrl", *executionNodeURL},
+		{"simulationUrl", *simulationURL},
+		{"passthroughUrl", *passthroughURL},
+		{"web3SignerUrl", *web3SignerURL},
+		{"notifyWebhookUrl", *notifyWebhookURL},
+		{"policyUrl", *policyURL},
+		{"auditS3Url", *auditS3URL},
+		{"otlpEndpoint", *otlpEndpoint},
+	}
+	for _, f := range urls {
+		if f.value == "" {
+			continue
+		}
+		if u, err := url.Parse(f.value); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			fail(f.name, "%q is not an http(s) url", f.value)
+		}
+	}
+
+	if _, err := lib.ChainConfigByName(*network); err != nil && *chainConfigPath == "" {
+		fail("network", "%v", err)
+	}
+	var networks []networkConfig
+	if *networksFile != "" {
+		var err error
+		if networks, err = loadNetworks(*networksFile); err != nil {
+			fail("networksFile", "%v", err)
+		} else {
+			errs = append(errs, validateNetworks(networks)...)
+		}
+	}
+	if *frontendsFile != "" {
+		if frontends, err := loadFrontends(*frontendsFile); err != nil {
+			fail("frontendsFile", "%v", err)
+		} else {
+			errs = append(errs, validateFrontends(frontends, networks)...)
+		}
+	}
+	if set["network"] && *chainConfigPath != "" {
+		fail("network", "conflicts with -chainConfig, which sets the network")
+	}
+	if set["network"] && *beaconNodeURL != "" && *chainConfigPath == "" {
+		fail("network", "conflicts with -beaconNodeUrl, whose network is used, run `mev-boost doctor` to compare them")
+	}
+
+	for _, pubkey := range splitList(*validatorPubkeys) {
+		decoded, err := hexutil.Decode(pubkey)
+		if err != nil {
+			fail("validatorPubkeys", "%q is not 0x prefixed hex", pubkey)
+			continue
+		}
+		if err := lib.ValidatePubkey(decoded); err != nil {
+			fail("validatorPubkeys", "%q is not a BLS pubkey: %v", pubkey, err)
+		}
+	}
+
+	if *defaultFeeRecipient != "" && !common.IsHexAddress(*defaultFeeRecipient) {
+		fail("defaultFeeRecipient", "%q is not an address", *defaultFeeRecipient)
+	}
+
+	if *underpaymentTolerance < 0 || *underpaymentTolerance > 1 {
+		fail("underpaymentTolerance", "%v is not a fraction between 0 and 1", *underpaymentTolerance)
+	}
+	if *underpaymentWindow < 0 {
+		fail("underpaymentWindow", "must not be negative")
+	}
+	if *suspensionExpiry < 0 {
+		fail("suspensionExpiry", "must not be negative")
+	}
+	if *revealTradeoff < 0 || *revealTradeoff > 1 {
+		fail("revealLatencyTradeoff", "%v is not a fraction between 0 and 1", *revealTradeoff)
+	}
+	if *revealCurve <= 0 {
+		fail("revealLatencyCurve", "must be positive")
+	}
+	if *payloadIDExpiry < 0 {
+		fail("payloadIdExpirySlots", "must not be negative")
+	}
+	if *whitelabelRateLimit < 0 {
+		fail("whitelabelRateLimit", "must not be negative")
+	}
+	if *whitelabelRateLimit > 0 && *whitelabelBurst < 1 {
+		fail("whitelabelBurst", "must be at least 1 with a rate limit")
+	}
+	if *whitelabelTokensFile != "" && *preferencesTokenFile != "" {
+		fail("preferencesApiTokenFile", "conflicts with -whitelabelTokensFile, which doesn't serve the preferences API")
+	}
+	for _, origin := range splitList(*corsOrigins) {
+		if u, err := url.Parse(origin); origin != "*" && (err != nil || u.Scheme == "" || u.Host == "") {
+			fail("corsOrigins", "%q is not an origin like https://dashboard.example.com", origin)
+		}
+	}
+	if *corsOrigins != "" && len(splitList(*corsMethods)) == 0 {
+		fail("corsMethods", "must not be empty with -corsOrigins")
+	}
+	if *adminAddr != "" && *adminTokenFile == "" {
+		if host, _, err := net.SplitHostPort(*adminAddr); err != nil {
+			fail("adminAddr", "%v", err)
+		} else if ip := net.ParseIP(host); host != "localhost" && (ip == nil || !ip.IsLoopback()) {
+			fail("adminAddr", "%s is reachable from other hosts, use a loopback address or -adminTokenFile", *adminAddr)
+		}
+	}
+	if lib.MinimalBuild {
+		for _, name := range []string{"graphql", "pprof", "adminAddr"} {
+			if set[name] {
+				fail(name, "not available in minimal builds")
+			}
+		}
+	}
+	if *tenantsFile != "" && *whitelabelTokensFile != "" {
+		fail("tenantsFile", "conflicts with -whitelabelTokensFile")
+	}
+	if *jwtSecretFile != "" && (*tenantsFile != "" || *whitelabelTokensFile != "") {
+		fail("jwtSecret", "conflicts with -tenantsFile and -whitelabelTokensFile, whose tokens are in the Authorization header too")
+	}
+	if *proposerTokensFile != "" && (*jwtSecretFile != "" || *tenantsFile != "" || *whitelabelTokensFile != "") {
+		fail("proposerTokensFile", "conflicts with -jwtSecret, -tenantsFile and -whitelabelTokensFile, whose tokens are in the Authorization header too")
+	}
+	if *tenantsFile != "" && *stableHeaders {
+		fail("tenantsFile", "conflicts with -stableHeaders, which would share headers across tenants")
+	}
+	if *grpcAddr != "" && *whitelabelTokensFile != "" {
+		fail("grpcAddr", "conflicts with -whitelabelTokensFile, which only serves the relay API")
+	}
+	if *grpcAddr != "" && *tenantsFile != "" {
+		fail("grpcAddr", "conflicts with -tenantsFile, gRPC calls can't be assigned to tenants")
+	}
+	if *grpcAddr != "" && *jwtSecretFile != "" {
+		fail("grpcAddr", "conflicts with -jwtSecret, gRPC calls aren't authenticated with a JWT")
+	}
+	if *grpcAddr != "" && (*proposerTokensFile != "" || *proposerAllowlist != "" || *methodRateLimits != "") {
+		fail("grpcAddr", "conflicts with -proposerTokensFile, -proposerAllowlist and -methodRateLimits, which don't apply to gRPC calls")
+	}
+	if *builderAPI && !*verifyBidSignatures {
+		fail("builderApi", "requires -verifyBidSignatures, the headers of the REST API carry the signature of the relay")
+	}
+	if *builderAPI && (*tenantsFile != "" || *whitelabelTokensFile != "") {
+		fail("builderApi", "conflicts with -tenantsFile and -whitelabelTokensFile")
+	}
+	if set["policyFailOpen"] && *policyURL == "" {
+		fail("policyFailOpen", "requires -policyUrl")
+	}
+	if *maxConcurrentRequests < 0 {
+		fail("maxConcurrentRequests", "must not be negative")
+	}
+	if *shedBackgroundQueue < 0 {
+		fail("shedBackgroundQueue", "must not be negative")
+	}
+	if (*shedBackgroundQueue > 0 || *shedBackgroundWait > 0) && *maxConcurrentRequests == 0 {
+		fail("shedBackgroundQueue", "requires -maxConcurrentRequests")
+	}
+	if *maxProcs < 0 {
+		fail("gomaxprocs", "must not be negative")
+	}
+	if *memoryLimit < 0 {
+		fail("memoryLimitMb", "must not be negative")
+	}
+	if *logBufferLines < 0 {
+		fail("logBufferLines", "must not be negative")
+	}
+	switch *logFormat {
+	case "text", "logfmt", "json":
+	default:
+		fail("logFormat", "%q is not text, logfmt or json", *logFormat)
+	}
+	if *logMaxSize < 0 {
+		fail("logMaxSizeMb", "must not be negative")
+	}
+	if *logMaxBackups < 0 {
+		fail("logMaxBackups", "must not be negative")
+	}
+	if *drainTimeout < 0 {
+		fail("drainTimeout", "must not be negative")
+	}
+	if *maxHeaderResponse <= 0 {
+		fail("maxHeaderResponseMb", "must be positive")
+	}
+	if *maxPayloadResponse <= 0 {
+		fail("maxPayloadResponseMb", "must be positive")
+	}
+	if *auditS3Retention < 0 {
+		fail("auditS3RetentionDays", "must not be negative")
+	}
+	if set["auditS3RetentionDays"] && *auditS3URL == "" {
+		fail("auditS3RetentionDays", "requires -auditS3Url")
+	}
+	if *auditS3URL != "" && (os.Getenv("AWS_ACCESS_KEY_ID") == "" || os.Getenv("AWS_SECRET_ACCESS_KEY") == "") {
+		fail("auditS3Url", "requires the AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY environment variables")
+	}
+	if *payloadMemoryBudget < 0 {
+		fail("payloadMemoryBudgetMb", "must not be negative")
+	}
+	if *equivocationGuard != "off" && *equivocationGuard != "log" && *equivocationGuard != "refuse" {
+		fail("equivocationGuard", "%q is not off, log or refuse", *equivocationGuard)
+	}
+	if _, err := lib.NewBidSelector(*bidSelection, *bidSelectionMargin/100, splitList(*relayPriority)); err != nil {
+		fail("bidSelection", "%v", err)
+	}
+	if *relayMaxIdleConns < 1 {
+		fail("relayMaxIdleConns", "must be at least 1")
+	}
+	if *relayRetries < 1 {
+		fail("relayRetries", "must be at least 1")
+	}
+	if *relayRetryJitter < 0 || *relayRetryJitter > 1 {
+		fail("relayRetryJitter", "must be between 0 and 1")
+	}
+	if *storeRetention == 0 {
+		fail("storeRetentionSlots", "must be at least 1")
+	}
+	durations := []struct {
+		name  string
+		value time.Duration
+	}{
+		{"relayJitter", *relayJitter},
+		{"reconcileInterval", *reconcileInterval},
+		{"reconcileGrace", *reconcileGrace},
+		{"relayCapabilityInterval", *capabilityInterval},
+		{"relayProbeInterval", *relayProbeInterval},
+		{"readinessInterval", *readinessInterval},
+		{"registrationInterval", *registrationInterval},
+		{"forkchoiceDedupWindow", *forkchoiceDedupWindow},
+		{"shedBackgroundWait", *shedBackgroundWait},
+		{"requestBudget", *requestBudget},
+		{"headerRace", *headerRace},
+		{"bidSubscriptionInterval", *bidSubscriptions},
+		{"websocketReadTimeout", *webSocketReadTimeout},
+		{"revealLatencyWindow", *revealWindow},
+		{"relayTimeoutMin", *relayTimeoutMin},
+		{"relayTimeoutMax", *relayTimeoutMax},
+		{"relayCooldown", *relayCooldown},
+		{"clockSkewThreshold", *clockSkewThreshold},
+		{"relayIdleConnTimeout", *relayIdleConnTimeout},
+		{"relayKeepAlive", *relayKeepAlive},
+		{"relayRetryDelay", *relayRetryDelay},
+	}
+	for _, f := range durations {
+		if f.value < 0 {
+			fail(f.name, "must not be negative")
+		}
+	}
+	if *storeDir != "" && *reputationFile != "" {
+		fail("reputationFile", "conflicts with -storeDir, which keeps the relay reputations")
+	}
+	if *storeDir != "" && *storeTTL <= 0 {
+		fail("storeTtl", "must be positive with -storeDir")
+	}
+	if *relayFailureThreshold < 0 {
+		fail("relayFailureThreshold", "must not be negative")
+	}
+	if *relayFailureThreshold > 0 && *relayCooldown == 0 {
+		fail("relayCooldown", "must be positive with -relayFailureThreshold")
+	}
+	if *relayTimeoutMax > 0 && *relayTimeoutMin > *relayTimeoutMax {
+		fail("relayTimeoutMin", "%s is above -relayTimeoutMax %s", *relayTimeoutMin, *relayTimeoutMax)
+	}
+	if *localJWTSecretFile != "" && *localExecutionURLs == "" && *simulationURL == "" && *passthroughURL == "" {
+		fail("localJwtSecretFile", "requires -localExecutionUrls, -simulationUrl or -passthroughUrl")
+	}
+	if *passthroughURL != "" && *localJWTSecretFile == "" && *jwtSecretFile == "" {
+		fail("passthroughUrl", "requires -localJwtSecretFile or -jwtSecret for the engine API")
+	}
+	if *simulationURL != "" {
+		if *localJWTSecretFile == "" && *jwtSecretFile == "" {
+			fail("simulationUrl", "requires -localJwtSecretFile or -jwtSecret for the engine API")
+		}
+		if *simulationTimeout <= 0 {
+			fail("simulationTimeout", "must be positive")
+		}
+	}
+	if *adjustForClockSkew && *ntpServer == "" {
+		fail("adjustForClockSkew", "requires -ntpServer")
+	}
+	if _, err := lib.ParseClientCompat(*clientCompat); err != nil {
+		fail("clientCompat", "%v", err)
+	}
+	if _, err := lib.ParseNoBidsBehavior(*noBidsBehavior); err != nil {
+		fail("noBidsBehavior", "%v", err)
+	}
+	if _, err := lib.ParseDeprecatedMethodPolicy(*deprecatedMethods); err != nil {
+		fail("deprecatedMethods", "%v", err)
+	}
+	if _, err := lib.ParseWei(*minBid); *minBid != "" && err != nil {
+		fail("minBid", "%v", err)
+	}
+	if _, err := lib.ParseAllowlist(splitList(*proposerAllowlist)); err != nil {
+		fail("proposerAllowlist", "%v", err)
+	}
+	if _, err := lib.ParseMethodRateLimits(*methodRateLimits); err != nil {
+		fail("methodRateLimits", "%v", err)
+	}
+	if _, err := lib.ParseRelayMethodTimeouts(*relayMethodTimeouts); err != nil {
+		fail("relayMethodTimeouts", "%v", err)
+	}
+	if _, err := lib.ParseFieldPolicy(*unknownRelayFields); err != nil {
+		fail("unknownRelayFields", "%v", err)
+	}
+	if _, err := lib.ParseFieldPolicy(*missingRelayFields); err != nil {
+		fail("missingRelayFields", "%v", err)
+	}
+	if *deterministicRelays && *relayJitter > 0 {
+		fail("relayJitter", "conflicts with -deterministicRelayOrder, which disables jitter")
+	}
+	if *verifySignatures && *beaconNodeURL == "" {
+		fail("verifyProposerSignature", "requires -beaconNodeUrl")
+	}
+	if *signaturesFailOpen && !*verifySignatures {
+		fail("proposerSignatureFailOpen", "requires -verifyProposerSignature")
+	}
+	if *verifyBidSignatures {
+		for _, relayURL := range relays {
+			if u, err := url.Parse(lib.RelayEndpoints(relayURL)[0]); err == nil && (u.User == nil || u.User.Username() == "") {
+				fail("verifyBidSignatures", "relay %s has no pubkey in its url", u.Host)
+			}
+		}
+	}
+	if *prefetchHeaders && *beaconNodeURL == "" {
+		fail("prefetchHeaders", "requires -beaconNodeUrl")
+	}
+	if *prefetchHeaders && *validatorPubkeys == "" {
+		fail("prefetchHeaders", "requires -validatorPubkeys")
+	}
+	if *checkChainState && *beaconNodeURL == "" {
+		fail("checkChainState", "requires -beaconNodeUrl")
+	}
+	if *verifyDeliveries && (*beaconNodeURL == "" || *executionNodeURL == "") {
+		fail("verifyDeliveries", "requires -beaconNodeUrl and -executionNodeUrl")
+	}
+	return errs
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"flag"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// setFlags sets the values of the named flags until the end of the test. The flags aren't marked as set on the command
+// line, like flag.Set would, so they can be reset afterwards.
+func setFlags(t *testing.T, values map[string]string) {
+	t.Helper()
+	for name, value := range values {
+		f := flag.Lookup(name)
+		require.NotNil(t, f, name)
+		require.Nil(t, f.Value.Set(value), name)
+		t.Cleanup(func() { f.Value.Set(f.DefValue) })
+	}
+}
+
+func TestValidateFlags(t *testing.T) {
+	tests := []struct {
+		name    string
+		flags   map[string]string
+		wantErr []string
+	}{
+		{"defaults", nil, nil},
+		{"no relay", map[string]string{"relayUrl": ""}, []string{"-relayUrl: no relay configured"}},
+		{"invalid port", map[string]string{"port": "70000"}, []string{"-port: 70000 is not a valid port"}},
+		{"grpc with whitelabel tokens", map[string]string{"grpcAddr": "127.0.0.1:18552", "whitelabelTokensFile": "tokens"}, []string{"-grpcAddr: conflicts with -whitelabelTokensFile"}},
+		{"grpc with tenants", map[string]string{"grpcAddr": "127.0.0.1:18552", "tenantsFile": "tenants.json"}, []string{"-grpcAddr: conflicts with -tenantsFile"}},
+		{"grpc with jwt", map[string]string{"grpcAddr": "127.0.0.1:18552", "jwtSecret": "jwt.hex"}, []string{"-grpcAddr: conflicts with -jwtSecret"}},
+		{"grpc with proposer tokens", map[string]string{"grpcAddr": "127.0.0.1:18552", "proposerTokensFile": "tokens"}, []string{"-grpcAddr: conflicts with -proposerTokensFile"}},
+		{"grpc with proposer allowlist", map[string]string{"grpcAddr": "127.0.0.1:18552", "proposerAllowlist": "10.0.0.0/8"}, []string{"-grpcAddr: conflicts with -proposerTokensFile"}},
+		{"grpc with method rate limits", map[string]string{"grpcAddr": "127.0.0.1:18552", "methodRateLimits": "*=50:100"}, []string{"-grpcAddr: conflicts with -proposerTokensFile"}},
+		{"jwt with tenants", map[string]string{"jwtSecret": "jwt.hex", "tenantsFile": "tenants.json"}, []string{"-jwtSecret: conflicts with -tenantsFile"}},
+		{"jwt with whitelabel tokens", map[string]string{"jwtSecret": "jwt.hex", "whitelabelTokensFile": "tokens"}, []string{"-jwtSecret: conflicts with -tenantsFile"}},
+		{"proposer tokens with jwt", map[string]string{"proposerTokensFile": "tokens", "jwtSecret": "jwt.hex"}, []string{"-proposerTokensFile: conflicts with -jwtSecret"}},
+		{"tenants with whitelabel tokens", map[string]string{"tenantsFile": "tenants.json", "whitelabelTokensFile": "tokens"}, []string{"-tenantsFile: conflicts with -whitelabelTokensFile"}},
+		{"tenants with stable headers", map[string]string{"tenantsFile": "tenants.json", "stableHeaders": "true"}, []string{"-tenantsFile: conflicts with -stableHeaders"}},
+		{"negative durations", map[string]string{"reconcileGrace": "-1s", "relayJitter": "-1ms"}, []string{"-relayJitter: must not be negative", "-reconcileGrace: must not be negative"}},
+		{"negative suspension expiry", map[string]string{"suspensionExpiry": "-1h"}, []string{"-suspensionExpiry: must not be negative"}},
+		{"relay timeouts out of order", map[string]string{"relayTimeoutMin": "2s", "relayTimeoutMax": "1s"}, []string{"-relayTimeoutMin: 2s is above -relayTimeoutMax 1s"}},
+		{"jitter with deterministic order", map[string]string{"relayJitter": "10ms", "deterministicRelayOrder": "true"}, []string{"-relayJitter: conflicts with -deterministicRelayOrder"}},
+		{"signature fail open without verification", map[string]string{"proposerSignatureFailOpen": "true"}, []string{"-proposerSignatureFailOpen: requires -verifyProposerSignature"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setFlags(t, tt.flags)
+
+			var got []string
+			for _, err := range validateFlags() {
+				got = append(got, err.Error())
+			}
+			require.Len(t, got, len(tt.wantErr), strings.Join(got, "\n"))
+			for i, want := range tt.wantErr {
+				require.True(t, strings.HasPrefix(got[i], want), "%q doesn't start with %q", got[i], want)
+			}
+		})
+	}
+}
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/flashbots/mev-boost/lib"
+	"github.com/flashbots/mev-boost/lib/client"
+	"github.com/sirupsen/logrus"
+	"gopkg.in/yaml.v3"
+)
+
+// configFile is the YAML file of -config. Its settings set the flags of the same name, unless they are given on the
+// command line, and its relays set -relayUrl with options of their own. The relays and the log level are reloaded on
+// SIGHUP, the other settings only apply on a restart.
+type configFile struct {
+	Port      int           `yaml:"port"`
+	AdminAddr string        `yaml:"adminAddr"`
+	GRPCAddr  string        `yaml:"grpcAddr"`
+	MinBid    string        `yaml:"minBid"`
+	LogLevel  string        `yaml:"logLevel"`
+	Relays    []configRelay `yaml:"relays"`
+
+	relays []lib.RelayConfig // Relays with their pubkey in the url and ${NAME} references replaced
+}
+
+// configRelay is a relay of the -config file
+type configRelay struct {
+	URL     string        `yaml:"url"`
+	Pubkey  string        `yaml:"pubkey"` // set as the user part of the url, which may hold the pubkey instead
+	Timeout time.Duration `yaml:"timeout"`
+	Weight  float64       `yaml:"weight"`
+	Shadow  bool          `yaml:"shadow"` // only asked for headers to compare its bids, see -shadowRelayUrl
+}
+
+// loadConfigFile reads and checks the -config file, unknown keys are rejected
+func loadConfigFile(path string) (*configFile, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	var cfg configFile
+	if err := decoder.Decode(&cfg); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("could not parse %s: %w", path, err)
+	}
+
+	if cfg.LogLevel != "" {
+		if _, err := logrus.ParseLevel(cfg.LogLevel); err != nil {
+			return nil, fmt.Errorf("logLevel: %w", err)
+		}
+	}
+	for i, relay := range cfg.Relays {
+		// relays are numbered rather than named by url, which may hold credentials
+		entry, err := relay.entry()
+		if err != nil {
+			return nil, fmt.Errorf("relay %d: %w", i+1, err)
+		}
+		if relay.Timeout < 0 {
+			return nil, fmt.Errorf("relay %d: negative timeout", i+1)
+		}
+		if relay.Weight < 0 {
+			return nil, fmt.Errorf("relay %d: negative weight", i+1)
+		}
+		cfg.relays = append(cfg.relays, lib.RelayConfig{URL: entry, Timeout: relay.Timeout, Weight: relay.Weight, Shadow: relay.Shadow})
+	}
+	return &cfg, nil
+}
+
+// entry returns the -relayUrl entry of the relay, with ${NAME} references replaced like in secret flags and the pubkey
+// set as the user part of each endpoint
+func (r configRelay) entry() (string, error) {
+	value, err := expandEnvReferences(r.URL)
+	if err != nil {
+		return "", err
+	}
+	endpoints := lib.RelayEndpoints(value)
+	if r.Pubkey != "" {
+		for i, endpoint := range endpoints {
+			u, err := url.Parse(endpoint)
+			if err != nil {
+				return "", err
+			}
+			if u.User != nil && u.User.Username() != r.Pubkey {
+				return "", fmt.Errorf("pubkey %s differs from the pubkey of %s", r.Pubkey, u.Host)
+			}
+			u.User = url.User(r.Pubkey)
+			endpoints[i] = u.String()
+		}
+	}
+	entry := strings.Join(endpoints, "|")
+	if err := client.ValidateRelayEntry(entry); err != nil {
+		return "", err
+	}
+	return entry, nil
+}
+
+// flags returns the flag values of the settings in the file, by flag name
+func (c *configFile) flags() map[string]string {
+	flags := make(map[string]string)
+	if c.Port != 0 {
+		flags["port"] = strconv.Itoa(c.Port)
+	}
+	if c.AdminAddr != "" {
+		flags["adminAddr"] = c.AdminAddr
+	}
+	if c.GRPCAddr != "" {
+		flags["grpcAddr"] = c.GRPCAddr
+	}
+	if c.MinBid != "" {
+		flags["minBid"] = c.MinBid
+	}
+	if c.LogLevel != "" {
+		flags["logLevel"] = c.LogLevel
+	}
+	if len(c.relays) > 0 {
+		entries := make([]string, len(c.relays))
+		for i, relay := range c.relays {
+			entries[i] = relay.URL
+		}
+		flags["relayUrl"] = strings.Join(entries, ",")
+	}
+	return flags
+}
+
+// applyConfigFile sets the flags of the -config file at path that aren't in cmdline, the flags given on the command line.
+// The flags it sets aren't read from the environment by resolveSecrets.
+func applyConfigFile(path string, cmdline map[string]bool) (*configFile, []error) {
+	cfg, err := loadConfigFile(path)
+	if err != nil {
+		return nil, []error{fmt.Errorf("-config: %v", err)}
+	}
+	var errs []error
+	for name, value := range cfg.flags() {
+		if cmdline[name] {
+			continue
+		}
+		if err := flag.Set(name, value); err != nil {
+			errs = append(errs, fmt.Errorf("-config: %s: %v", name, err))
+		}
+	}
+	return cfg, errs
+}
+
+// reloadConfigFile applies the relays and log level of the -config file again, keeping the current ones if the file is
+// invalid. Settings given on the command line keep taking precedence, running is the file mev-boost was started with.
+func reloadConfigFile(path string, running *configFile, cmdline map[string]bool, reloader *lib.RelayReloader, log *logrus.Entry) {
+	cfg, err := loadConfigFile(path)
+	if err != nil {
+		log.WithError(err).Error("could not reload config file, keeping the current settings")
+		return
+	}
+	if cfg.Port != running.Port || cfg.AdminAddr != running.AdminAddr || cfg.GRPCAddr != running.GRPCAddr || cfg.MinBid != running.MinBid {
+		log.Warn("only the relays and log level of the config file are reloaded, restart mev-boost to apply the other settings")
+	}
+	if len(cfg.relays) > 0 && !cmdline["relayUrl"] {
+		if err := reloader.Reload(context.Background(), append(cfg.relays, shadowRelays()...)); err != nil {
+			log.WithError(err).Error("could not reload relays, keeping the current relays")
+			return
+		}
+	}
+	if cfg.LogLevel != "" && !cmdline["logLevel"] {
+		level, _ := logrus.ParseLevel(cfg.LogLevel) // checked by loadConfigFile
+		logrus.SetLevel(level)
+	}
+	log.WithField("path", path).Info("config file reloaded")
+}
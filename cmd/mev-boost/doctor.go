@@ -0,0 +1,67 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/flashbots/mev-boost/lib/client"
+)
+
+// doctor runs `mev-boost doctor [flags]` and returns the exit code
+func doctor(args []string) int {
+	flags := flag.NewFlagSet("doctor", flag.ExitOnError)
+	relayURLs := flags.String("relayUrl", defaultRelayURLs, "relay urls - single entry or comma-separated list")
+	network := flags.String("network", "mainnet", "network to run on: mainnet, sepolia or ropsten")
+	chainConfigPath := flags.String("chainConfig", "", "path to a consensus-spec style config.yaml for custom networks, overrides -network")
+	beaconNodeURL := flags.String("beaconNodeUrl", "", "beacon node REST API url, -network or -chainConfig are compared to it")
+	executionNodeURL := flags.String("executionNodeUrl", "", "execution client JSON-RPC url")
+	ntpServer := flags.String("ntpServer", "pool.ntp.org:123", "NTP server the clock is compared to, empty to skip the check")
+	maxClockSkew := flags.Duration("maxClockSkew", 500*time.Millisecond, "clock offset to the NTP server that fails the check")
+	timeout := flags.Duration("timeout", 30*time.Second, "timeout for all checks")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: mev-boost doctor [flags]\n\nChecks the configuration and environment of mev-boost, pass the flags mev-boost runs with.\n\n")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	// mev-boost uses the network of the beacon node unless it's given explicitly, so only an explicit one can mismatch
+	explicitNetwork := *chainConfigPath != ""
+	flags.Visit(func(f *flag.Flag) { explicitNetwork = explicitNetwork || f.Name == "network" })
+	configBeaconURL := *beaconNodeURL
+	if explicitNetwork {
+		configBeaconURL = ""
+	}
+	chainConfig, err := loadChainConfig(*network, *chainConfigPath, configBeaconURL)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "could not load chain config: %v\n", err)
+		return 1
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+
+	report := client.Doctor(ctx, client.DoctorOpts{
+		ChainConfig:      chainConfig,
+		RelayURLs:        splitList(*relayURLs),
+		BeaconNodeURL:    *beaconNodeURL,
+		ExecutionNodeURL: *executionNodeURL,
+		NTPServer:        *ntpServer,
+		MaxClockSkew:     *maxClockSkew,
+	})
+	for _, finding := range report.Findings {
+		fmt.Printf("%-4s  %-20s %s\n", finding.Status, finding.Check, finding.Detail)
+		if finding.Fix != "" {
+			fmt.Printf("      %-20s fix: %s\n", "", finding.Fix)
+		}
+	}
+
+	if !report.Passed() {
+		fmt.Println("\nfound problems")
+		return 1
+	}
+	fmt.Println("\nno problems found")
+	return 0
+}
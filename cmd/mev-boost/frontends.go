@@ -0,0 +1,116 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+
+	"github.com/flashbots/mev-boost/lib"
+	"github.com/flashbots/mev-boost/lib/client"
+	"github.com/flashbots/mev-boost/lib/logrusadapter"
+	"github.com/sirupsen/logrus"
+)
+
+// frontendConfig is a frontend of -frontendsFile, serving another consensus client of the network of the flags on an
+// address of its own. It shares the store of the flags, keeps relay connections of its own, and uses their relays
+// unless it lists relays of its own.
+type frontendConfig struct {
+	// Name tells the frontend apart in logs
+	Name string `json:"name"`
+	// Listen is the address to listen on, like 127.0.0.1:18551
+	Listen    string   `json:"listen"`
+	RelayURLs []string `json:"relays,omitempty"`
+}
+
+// loadFrontends reads a JSON list of frontends from path
+func loadFrontends(path string) ([]frontendConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var frontends []frontendConfig
+	if err := json.Unmarshal(data, &frontends); err != nil {
+		return nil, fmt.Errorf("could not parse frontends: %w", err)
+	}
+	return frontends, nil
+}
+
+// validateFrontends checks the frontends of -frontendsFile, whose names must differ and whose ports must differ from
+// -port, the networks of -networksFile and each other
+func validateFrontends(frontends []frontendConfig, networks []networkConfig) []error {
+	var errs []error
+	fail := func(f frontendConfig, format string, args ...interface{}) {
+		errs = append(errs, fmt.Errorf("-frontendsFile: frontend %q: %s", f.Name, fmt.Sprintf(format, args...)))
+	}
+
+	ports := map[int]bool{*port: true}
+	for _, n := range networks {
+		ports[n.Port] = true
+	}
+	names := make(map[string]bool)
+	for _, f := range frontends {
+		if f.Name == "" {
+			fail(f, "no name")
+		} else if names[f.Name] {
+			fail(f, "name is already used")
+		}
+		names[f.Name] = true
+		_, portValue, err := net.SplitHostPort(f.Listen)
+		p, portErr := strconv.Atoi(portValue)
+		switch {
+		case err != nil:
+			fail(f, "invalid listen address %q: %v", f.Listen, err)
+		case portErr != nil || p <= 0 || p > 65535:
+			fail(f, "invalid port in listen address %q", f.Listen)
+		case ports[p]:
+			fail(f, "port %d is already used", p)
+		}
+		ports[p] = true
+		for _, relayURL := range f.RelayURLs {
+			if err := client.ValidateRelayEntry(relayURL); err != nil {
+				fail(f, "%v", err)
+			}
+		}
+	}
+	return errs
+}
+
+// serveFrontend serves a frontend of -frontendsFile on its address, with the relays of the flags in relays unless it
+// has its own, the store of the flags and the options of the flags in shared. Like the networks of -networksFile,
+// frontends don't serve the admin endpoints.
+func serveFrontend(ctx context.Context, f frontendConfig, relays lib.Option, store lib.Store, chainConfig *lib.ChainConfig, shared []lib.Option, adminToken string, log *logrus.Entry) *http.Server {
+	log = log.WithField("frontend", f.Name)
+	logger := logrusadapter.New(log)
+	if len(f.RelayURLs) > 0 {
+		relays = lib.WithRelayURLs(f.RelayURLs...)
+	}
+	opts := append([]lib.Option{
+		relays,
+		lib.WithStore(store),
+		lib.WithLogger(logger),
+		lib.WithMiddleware(lib.RecoveryMiddleware(logger), lib.MetricsMiddleware()),
+		lib.WithChainConfig(chainConfig),
+		lib.WithoutAdminEndpoints(),
+	}, shared...)
+	if adminToken != "" {
+		opts = append(opts, lib.WithAdminToken(adminToken))
+	}
+	router, err := lib.NewRouter(ctx, opts...)
+	if err != nil {
+		log.WithError(err).Fatal("could not create router")
+	}
+
+	server := &http.Server{Addr: f.Listen, Handler: router}
+	log.Println("listening on: ", f.Listen)
+	go func() {
+		if err := server.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+			log.Fatalf("error in server of frontend %s: %v", f.Name, err)
+		}
+	}()
+	return server
+}
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"math"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+)
+
+var (
+	// cgroupRoot is where the cgroup filesystem is mounted
+	cgroupRoot = "/sys/fs/cgroup"
+	// procCgroupFile lists the groups of the process
+	procCgroupFile = "/proc/self/cgroup"
+)
+
+// memoryLimitFraction is the part of the container memory limit the Go heap may grow to before the GC runs more often,
+// the rest is left for stacks, buffers and the runtime itself
+const memoryLimitFraction = 0.9
+
+// errNoLimit is returned when no cgroup limit is set
+var errNoLimit = errors.New("no cgroup limit")
+
+// applyResourceLimits sets GOMAXPROCS and the memory limit of the Go runtime. Values of 0 are derived from the cgroup
+// limits of the container, if any, so the scheduler isn't oversubscribed and the GC runs before the container is OOM
+// killed. The GOMAXPROCS and GOMEMLIMIT environment variables take precedence over the detected limits.
+func applyResourceLimits(maxProcs int, memoryLimit int64, log *logrus.Entry) {
+	if maxProcs == 0 && os.Getenv("GOMAXPROCS") == "" {
+		if cpus, err := cgroupCPULimit(); err == nil {
+			maxProcs = int(math.Max(1, math.Ceil(cpus)))
+		} else if !errors.Is(err, errNoLimit) && !errors.Is(err, os.ErrNotExist) {
+			log.WithError(err).Warn("could not read cgroup cpu limit")
+		}
+	}
+	if maxProcs > 0 {
+		runtime.GOMAXPROCS(maxProcs)
+		log.WithField("gomaxprocs", maxProcs).Info("set GOMAXPROCS")
+	}
+
+	if memoryLimit == 0 && os.Getenv("GOMEMLIMIT") == "" {
+		if limit, err := cgroupMemoryLimit(); err == nil {
+			memoryLimit = int64(float64(limit) * memoryLimitFraction)
+		} else if !errors.Is(err, errNoLimit) && !errors.Is(err, os.ErrNotExist) {
+			log.WithError(err).Warn("could not read cgroup memory limit")
+		}
+	}
+	if memoryLimit > 0 {
+		if setMemoryLimit(memoryLimit) {
+			log.WithField("bytes", memoryLimit).Info("set memory limit")
+		} else {
+			log.Warn("memory limits need a mev-boost built with Go 1.19 or later")
+		}
+	}
+}
+
+// cgroupCPULimit returns the number of CPUs the cgroup of the process may use, from cpu.max of cgroup v2 or the CFS
+// quota of cgroup v1
+func cgroupCPULimit() (float64, error) {
+	if content, err := readCgroupFile("", "cpu.max"); err == nil {
+		fields := strings.Fields(content)
+		if len(fields) != 2 {
+			return 0, errors.New("malformed cpu.max")
+		}
+		if fields[0] == "max" {
+			return 0, errNoLimit
+		}
+		return parseCPUQuota(fields[0], fields[1])
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return 0, err
+	}
+
+	quota, err := readCgroupFile("cpu", "cpu.cfs_quota_us")
+	if err != nil {
+		return 0, err
+	}
+	period, err := readCgroupFile("cpu", "cpu.cfs_period_us")
+	if err != nil {
+		return 0, err
+	}
+	if quota == "-1" {
+		return 0, errNoLimit
+	}
+	return parseCPUQuota(quota, period)
+}
+
+func parseCPUQuota(quota, period string) (float64, error) {
+	q, err := strconv.ParseFloat(quota, 64)
+	if err != nil {
+		return 0, err
+	}
+	p, err := strconv.ParseFloat(period, 64)
+	if err != nil {
+		return 0, err
+	}
+	if q <= 0 || p <= 0 {
+		return 0, errNoLimit
+	}
+	return q / p, nil
+}
+
+// cgroupMemoryLimit returns the memory limit in bytes of the cgroup of the process, from memory.max of cgroup v2 or
+// memory.limit_in_bytes of cgroup v1
+func cgroupMemoryLimit() (int64, error) {
+	content, err := readCgroupFile("", "memory.max")
+	if errors.Is(err, os.ErrNotExist) {
+		content, err = readCgroupFile("memory", "memory.limit_in_bytes")
+	}
+	if err != nil {
+		return 0, err
+	}
+	if content == "max" {
+		return 0, errNoLimit
+	}
+	limit, err := strconv.ParseInt(content, 10, 64)
+	if err != nil {
+		return 0, err
+	}
+	// cgroup v1 reports no limit as the largest page aligned int64
+	if limit <= 0 || limit >= math.MaxInt64&^4095 {
+		return 0, errNoLimit
+	}
+	return limit, nil
+}
+
+// readCgroupFile reads a file of the group of the process in the cgroup hierarchy of controller, or in the cgroup v2
+// hierarchy if controller is empty. It falls back to the root of the hierarchy, which is the group of the container
+// with a private cgroup namespace.
+func readCgroupFile(controller, name string) (string, error) {
+	mount := filepath.Join(cgroupRoot, controller)
+	paths := []string{filepath.Join(mount, name)}
+	if group, err := cgroupPath(controller); err == nil && group != "/" {
+		paths = append([]string{filepath.Join(mount, group, name)}, paths...)
+	}
+
+	var err error
+	for _, path := range paths {
+		var content []byte
+		if content, err = os.ReadFile(path); err == nil {
+			return strings.TrimSpace(string(content)), nil
+		}
+	}
+	return "", err
+}
+
+// cgroupPath returns the group of the process in the cgroup v1 hierarchy of controller, or in the cgroup v2 hierarchy
+// if controller is empty, from /proc/self/cgroup
+func cgroupPath(controller string) (string, error) {
+	f, err := os.Open(procCgroupFile)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		// lines are hierarchy-id:comma-separated-controllers:path, cgroup v2 has id 0 and no controllers
+		fields := strings.SplitN(scanner.Text(), ":", 3)
+		if len(fields) != 3 {
+			continue
+		}
+		if controller == "" && fields[0] == "0" && fields[1] == "" {
+			return fields[2], nil
+		}
+		for _, c := range strings.Split(fields[1], ",") {
+			if controller != "" && c == controller {
+				return fields[2], nil
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return "", err
+	}
+	return "", os.ErrNotExist
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// useCgroupFixture points the cgroup lookups at a temporary hierarchy with files, and /proc/self/cgroup at procCgroup
+func useCgroupFixture(t *testing.T, procCgroup string, files map[string]string) {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(dir, "sys/fs/cgroup", name)
+		require.Nil(t, os.MkdirAll(filepath.Dir(path), 0o755))
+		require.Nil(t, os.WriteFile(path, []byte(content+"\n"), 0o644))
+	}
+	procFile := filepath.Join(dir, "proc/self/cgroup")
+	require.Nil(t, os.MkdirAll(filepath.Dir(procFile), 0o755))
+	require.Nil(t, os.WriteFile(procFile, []byte(procCgroup), 0o644))
+
+	root, proc := cgroupRoot, procCgroupFile
+	cgroupRoot, procCgroupFile = filepath.Join(dir, "sys/fs/cgroup"), procFile
+	t.Cleanup(func() { cgroupRoot, procCgroupFile = root, proc })
+}
+
+func TestCgroupLimits_v2(t *testing.T) {
+	useCgroupFixture(t, "0::/system.slice/mev-boost.service\n", map[string]string{
+		"system.slice/mev-boost.service/cpu.max":    "150000 100000",
+		"system.slice/mev-boost.service/memory.max": "536870912",
+		"cpu.max":    "max 100000",
+		"memory.max": "max",
+	})
+
+	path, err := cgroupPath("")
+	require.Nil(t, err)
+	require.Equal(t, "/system.slice/mev-boost.service", path)
+	cpus, err := cgroupCPULimit()
+	require.Nil(t, err)
+	require.Equal(t, 1.5, cpus)
+	memory, err := cgroupMemoryLimit()
+	require.Nil(t, err)
+	require.Equal(t, int64(536870912), memory)
+}
+
+func TestCgroupLimits_v2NoLimit(t *testing.T) {
+	// a private cgroup namespace shows the group of the container as the root
+	useCgroupFixture(t, "0::/\n", map[string]string{
+		"cpu.max":    "max 100000",
+		"memory.max": "max",
+	})
+
+	_, err := cgroupCPULimit()
+	require.ErrorIs(t, err, errNoLimit)
+	_, err = cgroupMemoryLimit()
+	require.ErrorIs(t, err, errNoLimit)
+}
+
+func TestCgroupLimits_v1(t *testing.T) {
+	useCgroupFixture(t, "12:memory:/docker/abc\n11:cpu,cpuacct:/docker/abc\n1:name=systemd:/docker/abc\n", map[string]string{
+		"cpu/docker/abc/cpu.cfs_quota_us":         "200000",
+		"cpu/docker/abc/cpu.cfs_period_us":        "100000",
+		"memory/docker/abc/memory.limit_in_bytes": "1073741824",
+		"memory/memory.limit_in_bytes":            "9223372036854771712",
+	})
+
+	path, err := cgroupPath("cpu")
+	require.Nil(t, err)
+	require.Equal(t, "/docker/abc", path)
+	_, err = cgroupPath("")
+	require.ErrorIs(t, err, os.ErrNotExist)
+	cpus, err := cgroupCPULimit()
+	require.Nil(t, err)
+	require.Equal(t, 2.0, cpus)
+	memory, err := cgroupMemoryLimit()
+	require.Nil(t, err)
+	require.Equal(t, int64(1073741824), memory)
+}
+
+func TestCgroupLimits_v1NoLimit(t *testing.T) {
+	useCgroupFixture(t, "4:memory:/\n3:cpu,cpuacct:/\n", map[string]string{
+		"cpu/cpu.cfs_quota_us":         "-1",
+		"cpu/cpu.cfs_period_us":        "100000",
+		"memory/memory.limit_in_bytes": "9223372036854771712",
+	})
+
+	_, err := cgroupCPULimit()
+	require.ErrorIs(t, err, errNoLimit)
+	_, err = cgroupMemoryLimit()
+	require.ErrorIs(t, err, errNoLimit)
+}
+
+func TestCgroupLimits_NoCgroups(t *testing.T) {
+	useCgroupFixture(t, "", nil)
+
+	_, err := cgroupCPULimit()
+	require.ErrorIs(t, err, os.ErrNotExist)
+	_, err = cgroupMemoryLimit()
+	require.ErrorIs(t, err, os.ErrNotExist)
+}
+
+func TestCgroupLimits_Malformed(t *testing.T) {
+	useCgroupFixture(t, "0::/\n", map[string]string{
+		"cpu.max":    "150000",
+		"memory.max": "lots",
+	})
+
+	_, err := cgroupCPULimit()
+	require.EqualError(t, err, "malformed cpu.max")
+	_, err = cgroupMemoryLimit()
+	require.NotNil(t, err)
+}
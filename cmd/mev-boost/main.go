@@ -1,16 +1,26 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"flag"
+	"fmt"
+	"io"
 	"math/rand"
+	"net"
 	"net/http"
 	"os"
+	"os/signal"
 	"strconv"
 	"strings"
+	"syscall"
 	"time"
 
+	"github.com/ethereum/go-ethereum/common"
 	"github.com/flashbots/mev-boost/lib"
+	"github.com/flashbots/mev-boost/lib/logrusadapter"
 	"github.com/sirupsen/logrus"
+	"google.golang.org/grpc"
 )
 
 var (
@@ -18,40 +28,824 @@ var (
 
 	// defaults
 	defaultPort      = 18550
-	defaultRelayURLs = getEnv("RELAY_URLS", "http://127.0.0.1:28545")
+	defaultRelayURLs = "http://127.0.0.1:28545" // or RELAY_URLS, see resolveSecrets
 
 	// cli flags
-	port      = flag.Int("port", defaultPort, "port for mev-boost to listen on")
-	relayURLs = flag.String("relayUrl", defaultRelayURLs, "relay urls - single entry or comma-separated list")
+	port                  = flag.Int("port", defaultPort, "port for mev-boost to listen on")
+	relayURLs             = flag.String("relayUrl", defaultRelayURLs, "relay urls - single entry or comma-separated list")
+	network               = flag.String("network", "mainnet", "network to run on: mainnet, sepolia or ropsten")
+	chainConfigPath       = flag.String("chainConfig", "", "path to a consensus-spec style config.yaml for custom networks, overrides -network")
+	configPath            = flag.String("config", "", "YAML file of the relays with their pubkey, timeout, weight and shadow setting, and the settings port, adminAddr, grpcAddr, minBid and logLevel, flags on the command line take precedence. Relays and the log level are reloaded on SIGHUP")
+	shadowRelayURLs       = flag.String("shadowRelayUrl", "", "comma-separated relays that are asked for headers to log and compare their bids, but never used for proposals, to evaluate them")
+	networksFile          = flag.String("networksFile", "", "JSON file of further networks served by this process on ports of their own, each with its relays, chain config and store")
+	frontendsFile         = flag.String("frontendsFile", "", "JSON file of further frontends for consensus clients of the network, each on an address of its own and optionally with its own relays, sharing the store and relay connections")
+	beaconNodeURL         = flag.String("beaconNodeUrl", "", "beacon node REST API url, used to fetch genesis and the fork schedule instead of -network, and to evict finalized slots from the store")
+	underpaymentTolerance = flag.Float64("underpaymentTolerance", 0.01, "fraction of promised bid value a relay may fail to pay before it is suspended")
+	underpaymentWindow    = flag.Int("underpaymentWindow", 0, "number of recent verified payloads per relay checked against underpaymentTolerance (0 disables suspension)")
+	suspensionExpiry      = flag.Duration("suspensionExpiry", 0, "how long a relay stays suspended for underpayment before it is let back in with its payments forgotten (0 until its reputation is reset)")
+	reputationFile        = flag.String("reputationFile", "", "JSON file the payments and suspensions of relays are kept in across restarts")
+	notifyWebhookURL      = flag.String("notifyWebhookUrl", "", "url receiving a JSON POST for events that need operator attention, e.g. a suspended relay")
+	relayJitter           = flag.Duration("relayJitter", 0, "upper bound of a random delay before each relay request except signed blocks, e.g. 20ms")
+	deterministicRelays   = flag.Bool("deterministicRelayOrder", false, "query relays in configured order without jitter, for debugging")
+	reconcileInterval     = flag.Duration("reconcileInterval", 0, "how often delivered payloads are checked against relay data APIs, e.g. 10m (0 disables)")
+	reconcileGrace        = flag.Duration("reconcileGrace", 2*time.Minute, "how long after a delivery a relay data API may lag behind before the delivery is flagged as missing at the relay")
+	validatorPubkeys      = flag.String("validatorPubkeys", "", "comma-separated validator pubkeys, relays are asked for deliveries to them that mev-boost didn't record")
+	capabilityInterval    = flag.Duration("relayCapabilityInterval", 10*time.Minute, "how often relays are asked which methods they support and their bid floor (0 disables)")
+	relayProbeInterval    = flag.Duration("relayProbeInterval", 0, "how often relays are probed for their latency between proposals, used by -revealLatencyTradeoff and -relayTimeoutMax until real calls were seen (0 disables)")
+	readinessInterval     = flag.Duration("readinessInterval", 0, "how often relays and -localExecutionUrls, or -executionNodeUrl, are checked for /mev-boost/v1/readyz and /eth/v1/builder/status (0 disables the endpoints)")
+	web3SignerURL         = flag.String("web3SignerUrl", "", "Web3Signer compatible remote signer url, used to sign validator registrations with the fee recipient and gas limit preferences")
+	executionNodeURL      = flag.String("executionNodeUrl", "", "execution client JSON-RPC url, proposer payments are verified by the fee recipient balance increase of revealed blocks")
+	verifySignatures      = flag.Bool("verifyProposerSignature", false, "reject blinded blocks whose signature doesn't match the proposer pubkey on the beacon node, requires -beaconNodeUrl")
+	signaturesFailOpen    = flag.Bool("proposerSignatureFailOpen", false, "with -verifyProposerSignature, let blinded blocks through if the proposer pubkey can't be looked up on the beacon node instead of rejecting them")
+	verifyBidSignatures   = flag.Bool("verifyBidSignatures", false, "drop relay headers without a valid signature of the pubkey in the relay url")
+	verifyDeliveries      = flag.Bool("verifyDeliveries", false, "check delivered payloads of finalized slots for inclusion and payment, backfilling the recorded ones, requires -beaconNodeUrl and -executionNodeUrl")
+	checkChainState       = flag.Bool("checkChainState", false, "confirm the slot, head and proposer of header requests on the beacon node before serving headers, requires -beaconNodeUrl")
+	checkHeaders          = flag.Bool("checkHeaders", false, "discard relay headers that don't build on the forkchoice head, don't have the slot time as timestamp, move the gas limit away from the registered one or don't pay the registered fee recipient, parent gas limits are looked up on -executionNodeUrl if set")
+	checkGasLimit         = flag.Bool("checkGasLimit", false, "discard relay headers that move the gas limit away from the registered one or change the parent gas limit by more than a block may, without the other checks of -checkHeaders")
+	prefetchHeaders       = flag.Bool("prefetchHeaders", false, "request headers from relays at the start of slots a validator of -validatorPubkeys proposes in, requires -beaconNodeUrl")
+	shedBackgroundQueue   = flag.Int("shedBackgroundQueue", 0, "background requests, e.g. registrations, status calls and metrics scrapes, waiting for -maxConcurrentRequests before further ones are rejected with 503 (0 disables)")
+	shedBackgroundWait    = flag.Duration("shedBackgroundWait", 0, "how long a background request waits for -maxConcurrentRequests before it's rejected with 503 (0 disables)")
+	maxConcurrentRequests = flag.Int("maxConcurrentRequests", 64, "requests served at once, further requests wait with getPayloadHeader and proposeBlindedBlock of the current slot first (0 disables the limit)")
+	requestBudget         = flag.Duration("requestBudget", 4*time.Second, "time a call of the consensus client may take in total, across all relay requests and fallbacks (0 disables)")
+	headerRace            = flag.Duration("headerRace", 0, "getPayloadHeader serves the best header received this long after asking the relays instead of waiting for all of them, e.g. 400ms (0 disables)")
+	forkchoiceDedupWindow = flag.Duration("forkchoiceDedupWindow", 2*time.Second, "back-to-back identical engine_forkchoiceUpdatedV1 calls within this window are answered with the response of the first call (0 disables)")
+	registrationInterval  = flag.Duration("registrationInterval", 12*time.Second, "identical engine_forkchoiceUpdatedV1 registrations of a fee recipient within this interval aren't forwarded to relays (0 disables)")
+	payloadIDExpiry       = flag.Int("payloadIdExpirySlots", 2, "number of slots after which payload ids of engine_forkchoiceUpdatedV1 are rejected by getPayloadHeader (0 disables)")
+	deprecatedMethods     = flag.String("deprecatedMethods", "translate", "calls of deprecated method versions: translate (serve them with the current version and warn) or reject")
+	clientCompat          = flag.String("clientCompat", "auto", "consensus client whose quirks are worked around: auto (from the User-Agent), none, teku, nimbus or lodestar")
+	aggregator            = flag.Bool("aggregator", false, "serve the relay API, so other mev-boost instances can use this one as their relay")
+	whitelabelTokensFile  = flag.String("whitelabelTokensFile", "", "file with one user API token per line, serves only the relay API to users presenting one as bearer token")
+	whitelabelRateLimit   = flag.Float64("whitelabelRateLimit", 10, "requests per second each whitelabel user may make on average (0 disables the limit)")
+	whitelabelBurst       = flag.Int("whitelabelBurst", 20, "requests each whitelabel user may make at once above -whitelabelRateLimit")
+	relayGroupsFile       = flag.String("relayGroupsFile", "", "JSON file of named relay groups with their selection policy and priority, and the groups active globally and per fee recipient")
+	validatorRelaysFile   = flag.String("validatorRelaysFile", "", "JSON file of the relays used for the proposals of each validator pubkey, and a default for other proposers")
+	revealTradeoff        = flag.Float64("revealLatencyTradeoff", 0, "fraction of bid value given up per second a relay reveals payloads slower than the fastest one, at the attestation deadline (0 disables)")
+	revealWindow          = flag.Duration("revealLatencyWindow", 2*time.Second, "how long before the attestation deadline bids start to be weighted by reveal latency")
+	revealCurve           = flag.Float64("revealLatencyCurve", 1, "exponent of the growth of the weighting towards the deadline, 1 is linear, higher values weight later")
+	noBidsBehavior        = flag.String("noBidsBehavior", "error", "answer to header requests without a valid bid: error (the error of the failure), local (always the build locally error) or empty (a zero-value header)")
+	defaultFeeRecipient   = flag.String("defaultFeeRecipient", "", "fee recipient that bids are verified against if the consensus client registered none for their payload")
+	tenantsFile           = flag.String("tenantsFile", "", "JSON file of tenants, consensus clients identified by their token and served with their own relays and min bid")
+	policyURL             = flag.String("policyUrl", "", "Open Policy Agent decision url asked to allow each bid before it's returned, e.g. http://127.0.0.1:8181/v1/data/mevboost/allow")
+	policyFailOpen        = flag.Bool("policyFailOpen", false, "accept bids when the -policyUrl can't be asked, instead of rejecting them")
+	bidSubscriptions      = flag.Duration("bidSubscriptionInterval", 0, "let consensus clients subscribe to the best bid over WebSocket, requesting headers from relays this often (0 disables)")
+	webSocketRPC          = flag.Bool("websocket", false, "serve the JSON-RPC methods over WebSocket connections to the port too")
+	trackMissedValue      = flag.Bool("trackMissedValue", false, "compare delivered payloads to the bids of other relays for the slot, and log and list the value missed when one bid more")
+	webSocketReadTimeout  = flag.Duration("websocketReadTimeout", time.Minute, "close WebSocket connections that don't answer pings for this long (0 disables)")
+	relayTimings          = flag.Bool("relayTimings", false, "record the timings of relay calls and serve them under /mev-boost/v1/relays/timings")
+	graphqlAPI            = flag.Bool("graphql", false, "serve a read-only GraphQL API over delivered payloads and received bids under /mev-boost/v1/graphql")
+	relayTimingsFile      = flag.String("relayTimingsFile", "", "file the timings of relay calls are appended to as JSON lines, implies -relayTimings")
+	relayTimeoutMin       = flag.Duration("relayTimeoutMin", 200*time.Millisecond, "lower bound of the adaptive relay timeouts")
+	localExecutionURLs    = flag.String("localExecutionUrls", "", "comma-separated engine API urls of local execution clients, whose most valuable payload is returned when no relay has a valid bid")
+	localJWTSecretFile    = flag.String("localJwtSecretFile", "", "file with the hex encoded JWT secret of the engine API of -localExecutionUrls, -simulationUrl and -passthroughUrl, -jwtSecret if not set")
+	simulationURL         = flag.String("simulationUrl", "", "engine API url of a local execution client revealed payloads are submitted to with engine_newPayloadV1, only payloads it reports as VALID are returned")
+	passthroughURL        = flag.String("passthroughUrl", "", "engine API url of a local execution client calls of methods mev-boost doesn't serve are forwarded to")
+	passthroughAllow      = flag.String("passthroughAllow", strings.Join(lib.DefaultPassthroughMethods, ","), "comma-separated methods, or prefixes ending in *, forwarded to -passthroughUrl")
+	passthroughDeny       = flag.String("passthroughDeny", "", "comma-separated methods, or prefixes ending in *, never forwarded to -passthroughUrl")
+	simulationTimeout     = flag.Duration("simulationTimeout", time.Second, "time -simulationUrl has to report a revealed payload as VALID")
+	proposerTokensFile    = flag.String("proposerTokensFile", "", "file with one token per line, serves the consensus client API only to clients presenting one as bearer token")
+	proposerAllowlist     = flag.String("proposerAllowlist", "", "comma-separated IP addresses and networks, e.g. 10.0.0.0/8, the consensus client API is served to (all if empty)")
+	methodRateLimits      = flag.String("methodRateLimits", "", "rate limits of JSON-RPC methods as method=perSecond:burst, e.g. builder_getPayloadHeaderV1=2:4,*=50:100, with * for all other methods")
+	jwtSecretFile         = flag.String("jwtSecret", "", "file with the hex encoded JWT secret the consensus client signs its calls with, like for the engine API of its execution client")
+	ntpServer             = flag.String("ntpServer", "", "NTP server the local clock is compared with every 5 minutes, e.g. pool.ntp.org (empty disables)")
+	clockSkewThreshold    = flag.Duration("clockSkewThreshold", 500*time.Millisecond, "clock offset to -ntpServer above which an error is logged")
+	adjustForClockSkew    = flag.Bool("adjustForClockSkew", false, "move slot deadlines by the offset to -ntpServer when it's above -clockSkewThreshold")
+	relayTimeoutMax       = flag.Duration("relayTimeoutMax", 0, "time out relay calls after twice the 95th percentile latency of the relay, at most this long (0 disables)")
+	relayFailureThreshold = flag.Int("relayFailureThreshold", 0, "take relays out of the rotation after this many consecutive failed calls, timeouts or malformed responses (0 disables)")
+	relayCooldown         = flag.Duration("relayCooldown", 30*time.Second, "time until a relay out of the rotation is probed to put it back, see -relayFailureThreshold")
+	relayMethodTimeouts   = flag.String("relayMethodTimeouts", "", "fixed timeouts of relay calls by method, e.g. getHeader=500ms,propose=2s, with the methods forkchoiceUpdated, getHeader and propose")
+	equivocationGuard     = flag.String("equivocationGuard", "log", "header requests on another parent than the header returned for the slot, and signed blocks with another parent or state root than the first of the slot: off, log or refuse")
+	stableHeaders         = flag.Bool("stableHeaders", false, "return the same header to all getPayloadHeader calls of a proposal, by slot, parent and proposer, for redundant consensus clients and distributed validators")
+	corsOrigins           = flag.String("corsOrigins", "", "comma-separated origins of browser dashboards allowed to query the mev-boost APIs and /metrics, or * for any")
+	corsMethods           = flag.String("corsMethods", "GET", "comma-separated methods allowed for -corsOrigins")
+	traceContext          = flag.Bool("traceContext", false, "pass the W3C traceparent and tracestate headers of the consensus client on to relays")
+	otlpEndpoint          = flag.String("otlpEndpoint", "", "OpenTelemetry collector to export spans of JSON-RPC and relay calls to with OTLP over HTTP, e.g. http://localhost:4318")
+	otlpServiceName       = flag.String("otlpServiceName", "mev-boost", "service name of the exported spans")
+	lenientContentTypes   = flag.Bool("lenientContentTypes", false, "only warn about requests and relay responses that aren't application/json instead of rejecting them")
+	maxHeaderResponse     = flag.Int64("maxHeaderResponseMb", 8, "relay responses other than payloads larger than this many MB are aborted")
+	maxPayloadResponse    = flag.Int64("maxPayloadResponseMb", 16, "relay payload responses larger than this many MB are aborted")
+	unknownRelayFields    = flag.String("unknownRelayFields", "warn", "relay responses with fields mev-boost doesn't know: ignore, warn or reject")
+	missingRelayFields    = flag.String("missingRelayFields", "reject", "relay responses missing required fields: ignore, warn or reject")
+	auditFile             = flag.String("auditFile", "", "file deliveries, underpayments, relay suspensions and delivery verifications are appended to as JSON lines for auditing")
+	payoutCSV             = flag.String("payoutCsv", "", "CSV file a row is appended to for each block proposed through mev-boost, with its slot, proposer, winning relay and selected bid value")
+	auditS3URL            = flag.String("auditS3Url", "", "S3-compatible bucket url with an optional key prefix the audit records are written to, e.g. https://s3.eu-central-1.amazonaws.com/bucket/mev-boost, with the credentials in AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY")
+	auditS3Region         = flag.String("auditS3Region", "us-east-1", "region of -auditS3Url")
+	auditS3Retention      = flag.Int("auditS3RetentionDays", 0, "lock the audit objects of -auditS3Url in compliance mode for this many days, the bucket needs object lock enabled (0 disables)")
+	auditPostgres         = flag.String("auditPostgres", "", "url of the Postgres database the audit records are inserted into, e.g. postgres://mevboost@db.example.com/audit")
+	auditPostgresTable    = flag.String("auditPostgresTable", "mevboost_audit", "table of -auditPostgres, created if it doesn't exist")
+	minBid                = flag.String("minBid", "", "ignore bids worth less to the proposer, in wei or with a gwei or eth suffix, e.g. 0.01eth, falling back like without bids")
+	bidSelection          = flag.String("bidSelection", lib.BidSelectionHighestValue, "how bids are selected: highest-value, weighted-random among bids within -bidSelectionMargin of the best by relay weight, or priority of -relayPriority among them")
+	bidSelectionMargin    = flag.Float64("bidSelectionMargin", 0, "percentage of the best bid value bids may be below to be selected by -bidSelection weighted-random or priority, 0 only breaks ties")
+	relayPriority         = flag.String("relayPriority", "", "comma-separated relay urls or hosts, highest priority first, for -bidSelection priority")
+	payloadEscrow         = flag.Bool("payloadEscrow", false, "only select bids that came with their transactions, reveal their payloads from mev-boost and forward signed blocks to relays afterwards")
+	proposalFanOut        = flag.Bool("proposalFanOut", false, "send signed blocks to every relay that bid for the slot, not only the relay of the selected bid, and return the first payload matching the block")
+	builderAPI            = flag.Bool("builderApi", false, "serve the getHeader, blinded block and validator registration routes of the builder REST API next to JSON-RPC, requires -verifyBidSignatures")
+	relaySSZ              = flag.Bool("relaySsz", false, "ask relays for SSZ encoded headers and payloads, relays that don't speak SSZ answer with JSON")
+	relayMaxIdleConns     = flag.Int("relayMaxIdleConns", lib.DefaultRelayTransportConfig.MaxIdleConnsPerHost, "idle connections kept open to each relay, so concurrent calls at the start of a slot don't wait for handshakes")
+	relayIdleConnTimeout  = flag.Duration("relayIdleConnTimeout", lib.DefaultRelayTransportConfig.IdleConnTimeout, "close relay connections idle for longer, should be longer than a slot")
+	relayKeepAlive        = flag.Duration("relayKeepAlive", lib.DefaultRelayTransportConfig.KeepAlive, "interval of TCP keep-alive probes on relay connections (0 uses the default of 15s)")
+	relayRetries          = flag.Int("relayRetries", 1, "times getPayloadHeader and registerValidator calls are sent to a relay at most, retrying connection errors and 5xx statuses with exponential backoff (1 disables retries)")
+	relayRetryDelay       = flag.Duration("relayRetryDelay", 50*time.Millisecond, "wait before the first retry of -relayRetries, doubling with each retry")
+	relayRetryJitter      = flag.Float64("relayRetryJitter", 0.2, "fraction of each -relayRetryDelay wait it's randomized by")
+	relayPrewarm          = flag.Bool("relayPrewarm", false, "connect to every relay at startup, so the first calls don't pay for the TCP and TLS handshakes")
+	payloadCompression    = flag.Bool("payloadCompression", true, "negotiate snappy, deflate or gzip compressed payload responses with relays")
+	storeDir              = flag.String("storeDir", "", "directory of a LevelDB database payloads, bids and relay reputations are kept in across restarts, instead of memory")
+	storeTTL              = flag.Duration("storeTtl", 15*time.Minute, "time entries of -storeDir are kept")
+	storeRetention        = flag.Uint64("storeRetentionSlots", 64, "slots payloads, bids and forkchoice responses are kept in memory for")
+	payloadMemoryBudget   = flag.Int64("payloadMemoryBudgetMb", 1024, "approximate memory in MB cached payloads may use before the oldest are evicted (0 disables the limit)")
+	maxProcs              = flag.Int("gomaxprocs", 0, "number of OS threads running Go code at once (0 uses the container cpu limit, or all cpus)")
+	memoryLimit           = flag.Int64("memoryLimitMb", 0, "soft memory limit in MB the GC keeps the heap under (0 uses 90% of the container memory limit, if any)")
+	logLevel              = flag.String("logLevel", "info", "minimum level of logged messages: trace, debug, info, warn or error")
+	logBufferLines        = flag.Int("logBufferLines", 10000, "log lines buffered while written in the background, lines over it are dropped and counted (0 writes synchronously)")
+	logFormat             = flag.String("logFormat", "text", "format of log lines: text (colored on a terminal), logfmt or json")
+	logFile               = flag.String("logFile", "", "file logs are appended to instead of stderr")
+	logMaxSize            = flag.Int64("logMaxSizeMb", 100, "size in MB -logFile is rotated at (0 never rotates)")
+	logMaxBackups         = flag.Int("logMaxBackups", 5, "number of rotated -logFile files kept, as <file>.1 to <file>.<n> with <file>.1 the most recent")
+	logRelayCalls         = flag.Bool("logRelayCalls", false, "log each relay call with the fields slot, relay, method, duration_ms and value")
+	drainTimeout          = flag.Duration("drainTimeout", 10*time.Second, "time requests in flight are given to finish on SIGINT or SIGTERM before mev-boost exits")
+	grpcAddr              = flag.String("grpcAddr", "", "listen address of the gRPC variant of the builder API, e.g. 127.0.0.1:18552")
+	adminAddr             = flag.String("adminAddr", "", "separate listen address of /metrics and /debug/pprof, e.g. 127.0.0.1:18551, instead of the main port")
+	adminTokenFile        = flag.String("adminTokenFile", "", "file with the bearer token required for /metrics, /debug/pprof and the admin API under /mev-boost/v1/admin, which is only served with a token")
+	enablePprof           = flag.Bool("pprof", false, "serve runtime profiles under /debug/pprof")
+	preferencesTokenFile  = flag.String("preferencesApiTokenFile", "", "file with the bearer token of the validator preferences API, the API is disabled without it")
 )
 
+// usage prints the subcommands and the flags of serve
+func usage() {
+	fmt.Fprintf(flag.CommandLine.Output(), `Usage: mev-boost [command] [flags]
+
+Commands:
+  serve         serve the builder API to consensus clients, the default
+  check-relays  check each relay of -relayUrl
+  test-relay    check a relay before adding it to -relayUrl
+  doctor        check the configuration and environment
+  service       install or remove mev-boost as a Windows service
+  version       print the version
+
+Flags of serve:
+`)
+	flag.PrintDefaults()
+}
+
 func main() {
 	rand.Seed(time.Now().UnixNano()) // warning: not a cryptographically secure seed
 
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "serve": // the default command, for scripts that name it
+			os.Args = append(os.Args[:1], os.Args[2:]...)
+		case "version":
+			fmt.Printf("mev-boost %s\n", version)
+			os.Exit(0)
+		case "check-relays":
+			os.Exit(checkRelays(os.Args[2:]))
+		case "test-relay":
+			os.Exit(testRelay(os.Args[2:]))
+		case "doctor":
+			os.Exit(doctor(os.Args[2:]))
+		case "service":
+			os.Exit(serviceCommand(os.Args[2:]))
+		}
+	}
+
+	flag.Usage = usage
 	flag.Parse()
+	cmdline := make(map[string]bool)
+	flag.Visit(func(f *flag.Flag) { cmdline[f.Name] = true })
+	var fileConfig *configFile
+	var errs []error
+	if *configPath != "" {
+		fileConfig, errs = applyConfigFile(*configPath, cmdline)
+	}
+	errs = append(errs, resolveSecrets()...)
+	if errs = append(errs, validateFlags()...); len(errs) > 0 {
+		for _, err := range errs {
+			fmt.Fprintf(os.Stderr, "invalid configuration: %v\n", err)
+		}
+		os.Exit(2)
+	}
+	level, _ := logrus.ParseLevel(*logLevel) // checked by validateFlags
+	logrus.SetLevel(level)
+	logrus.SetFormatter(logFormatter(*logFormat))
+	inService := isWindowsService()
+	if inService {
+		if err := logToEventLog(); err != nil {
+			logrus.WithError(err).Fatal("could not open event log")
+		}
+	}
+	if !inService || *logFile != "" {
+		var out io.Writer = os.Stderr
+		var file *lib.RotatingFile
+		if *logFile != "" {
+			var err error
+			if file, err = lib.NewRotatingFile(*logFile, *logMaxSize<<20, *logMaxBackups); err != nil {
+				logrus.WithError(err).Fatal("could not open log file")
+			}
+			out = file
+		}
+		if *logBufferLines > 0 {
+			writer := lib.NewAsyncWriter(out, *logBufferLines)
+			logrus.RegisterExitHandler(func() { writer.Close() }) // flush the reason of a fatal exit
+			out = writer
+		}
+		if file != nil {
+			logrus.RegisterExitHandler(func() { file.Close() }) // after the buffer was flushed
+		}
+		logrus.SetOutput(out)
+	}
 	log := logrus.WithField("prefix", "cmd/mev-boost")
 	log.Printf("mev-boost %s\n", version)
+	applyResourceLimits(*maxProcs, *memoryLimit<<20, log)
 
 	_relayURLs := []string{}
 	for _, entry := range strings.Split(*relayURLs, ",") {
 		_relayURLs = append(_relayURLs, strings.Trim(entry, " "))
 	}
 
-	store := lib.NewStoreWithCleanup()
-	router, err := lib.NewRouter(_relayURLs, store, log)
+	chainConfig, err := loadChainConfig(*network, *chainConfigPath, *beaconNodeURL)
+	if err != nil {
+		log.WithError(err).Fatal("could not load chain config")
+	}
+	log.WithFields(logrus.Fields{
+		"network":            chainConfig.Name,
+		"genesisTime":        chainConfig.GenesisTime,
+		"genesisForkVersion": fmt.Sprintf("%#x", chainConfig.GenesisForkVersion),
+		"secondsPerSlot":     chainConfig.SecondsPerSlot,
+	}).Info("using chain config")
+
+	var signer lib.Signer
+	if *web3SignerURL != "" {
+		signer = lib.NewWeb3Signer(*web3SignerURL, chainConfig)
+		checkSigner(signer, splitList(*validatorPubkeys), log)
+	}
+
+	var preferencesToken string
+	if *preferencesTokenFile != "" {
+		preferencesToken, err = readTokenFile(*preferencesTokenFile)
+		if err != nil {
+			log.WithError(err).Fatal("could not read preferences API token")
+		}
+	}
+
+	var adminToken string
+	if *adminTokenFile != "" {
+		adminToken, err = readTokenFile(*adminTokenFile)
+		if err != nil {
+			log.WithError(err).Fatal("could not read admin token")
+		}
+	}
+
+	var jwtSecret []byte
+	if *jwtSecretFile != "" {
+		if jwtSecret, err = lib.LoadJWTSecret(*jwtSecretFile); err != nil {
+			log.WithError(err).Fatal("could not read JWT secret")
+		}
+	}
+
+	localSecret := jwtSecret
+	if *localJWTSecretFile != "" {
+		if localSecret, err = lib.LoadJWTSecret(*localJWTSecretFile); err != nil {
+			log.WithError(err).Fatal("could not read JWT secret of the local execution clients")
+		}
+	}
+	var localClients []*lib.ExecutionClient
+	for _, url := range splitList(*localExecutionURLs) {
+		localClients = append(localClients, lib.NewEngineClient(url, localSecret))
+	}
+
+	var whitelabelTokens []string
+	if *whitelabelTokensFile != "" {
+		whitelabelTokens, err = readTokens(*whitelabelTokensFile)
+		if err != nil {
+			log.WithError(err).Fatal("could not read whitelabel tokens")
+		}
+		if len(whitelabelTokens) == 0 {
+			log.Fatal("whitelabel tokens file is empty")
+		}
+	}
+
+	var proposerTokens []string
+	if *proposerTokensFile != "" {
+		proposerTokens, err = readTokens(*proposerTokensFile)
+		if err != nil {
+			log.WithError(err).Fatal("could not read proposer tokens")
+		}
+		if len(proposerTokens) == 0 {
+			log.Fatal("proposer tokens file is empty")
+		}
+	}
+
+	compat, _ := lib.ParseClientCompat(*clientCompat) // checked by validateFlags
+	noBids, _ := lib.ParseNoBidsBehavior(*noBidsBehavior)
+	unknownFields, _ := lib.ParseFieldPolicy(*unknownRelayFields)
+	deprecated, _ := lib.ParseDeprecatedMethodPolicy(*deprecatedMethods)
+	missingFields, _ := lib.ParseFieldPolicy(*missingRelayFields)
+
+	// ctx ends the background work after the servers were drained
+	ctx, stopBackground := context.WithCancel(context.Background())
+	logger := logrusadapter.New(log)
+	// options that don't depend on the network, they apply to the networks of -networksFile too
+	shared := []lib.Option{
+		lib.WithUnderpaymentSuspension(*underpaymentTolerance, *underpaymentWindow),
+		lib.WithSuspensionExpiry(*suspensionExpiry),
+		lib.WithRelayJitter(*relayJitter),
+		lib.WithCapabilityCheckInterval(*capabilityInterval),
+		lib.WithRelayProbes(*relayProbeInterval),
+		lib.WithReconcileInterval(*reconcileInterval),
+		lib.WithReconcileGrace(*reconcileGrace),
+		lib.WithPayloadIDExpiry(*payloadIDExpiry),
+		lib.WithRegistrationInterval(*registrationInterval),
+		lib.WithForkchoiceDeduplication(*forkchoiceDedupWindow),
+		lib.WithRequestBudget(*requestBudget),
+		lib.WithHeaderRace(*headerRace),
+		lib.WithMaxConcurrentRequests(*maxConcurrentRequests),
+		lib.WithLoadShedding(*shedBackgroundQueue, *shedBackgroundWait),
+		lib.WithResponseSizeLimits(*maxHeaderResponse<<20, *maxPayloadResponse<<20),
+		lib.WithClientCompat(compat),
+		lib.WithNoBidsBehavior(noBids),
+		lib.WithRelayFieldPolicy(unknownFields, missingFields),
+		lib.WithDeprecatedMethodPolicy(deprecated),
+	}
+	if value, err := lib.ParseWei(*minBid); err == nil {
+		shared = append(shared, lib.WithMinBid(value))
+	}
+	if *payloadEscrow {
+		shared = append(shared, lib.WithPayloadEscrow())
+	}
+	if *proposalFanOut {
+		shared = append(shared, lib.WithProposalFanOut())
+	}
+	if *builderAPI {
+		shared = append(shared, lib.WithBuilderAPI())
+	}
+	if *bidSelection != lib.BidSelectionHighestValue {
+		selector, _ := lib.NewBidSelector(*bidSelection, *bidSelectionMargin/100, splitList(*relayPriority))
+		shared = append(shared, lib.WithBidSelector(selector))
+	}
+	shared = append(shared, lib.WithRelayTransport(lib.RelayTransportConfig{
+		MaxIdleConnsPerHost: *relayMaxIdleConns,
+		IdleConnTimeout:     *relayIdleConnTimeout,
+		KeepAlive:           *relayKeepAlive,
+		Prewarm:             *relayPrewarm,
+	}))
+	if *relayRetries > 1 {
+		shared = append(shared, lib.WithRelayRetries(lib.RetryPolicy{MaxAttempts: *relayRetries, BaseDelay: *relayRetryDelay, Jitter: *relayRetryJitter}))
+	}
+	if *trackMissedValue {
+		shared = append(shared, lib.WithMissedValueTracking())
+	}
+	if *payloadCompression {
+		shared = append(shared, lib.WithPayloadCompression())
+	}
+	if *relaySSZ {
+		shared = append(shared, lib.WithRelaySSZ())
+	}
+	if !*lenientContentTypes {
+		shared = append(shared, lib.WithStrictContentTypes())
+	}
+	if *deterministicRelays {
+		shared = append(shared, lib.WithDeterministicRelayOrder())
+	}
+	if *stableHeaders {
+		shared = append(shared, lib.WithStableHeaders())
+	}
+	if *bidSubscriptions > 0 {
+		shared = append(shared, lib.WithBidSubscriptions(*bidSubscriptions))
+	}
+	if *webSocketRPC {
+		shared = append(shared, lib.WithWebSocketRPC(*webSocketReadTimeout))
+	}
+	if jwtSecret != nil {
+		shared = append(shared, lib.WithJWTAuthentication(jwtSecret))
+	}
+	if allowlist, _ := lib.ParseAllowlist(splitList(*proposerAllowlist)); proposerTokens != nil || len(allowlist) > 0 {
+		shared = append(shared, lib.WithProposerAuth(lib.ProposerAuth{Tokens: proposerTokens, Allowlist: allowlist}))
+	}
+	if limits, _ := lib.ParseMethodRateLimits(*methodRateLimits); len(limits) > 0 {
+		shared = append(shared, lib.WithMethodRateLimits(limits))
+	}
+	if *relayTimeoutMax > 0 {
+		shared = append(shared, lib.WithAdaptiveRelayTimeouts(*relayTimeoutMin, *relayTimeoutMax))
+	}
+	if *relayFailureThreshold > 0 {
+		shared = append(shared, lib.WithRelayCircuitBreaker(*relayFailureThreshold, *relayCooldown))
+	}
+	if methodTimeouts, _ := lib.ParseRelayMethodTimeouts(*relayMethodTimeouts); len(methodTimeouts) > 0 {
+		shared = append(shared, lib.WithRelayMethodTimeouts(methodTimeouts))
+	}
+	if *revealTradeoff > 0 {
+		shared = append(shared, lib.WithRevealLatencyWeighting(*revealWindow, *revealTradeoff, *revealCurve))
+	}
+	if origins := splitList(*corsOrigins); len(origins) > 0 {
+		shared = append(shared, lib.WithCORS(origins, splitList(*corsMethods)))
+	}
+	if *traceContext {
+		shared = append(shared, lib.WithTraceContext())
+	}
+	if *otlpEndpoint != "" {
+		shared = append(shared, lib.WithSpanExport(*otlpEndpoint, *otlpServiceName))
+	}
+
+	store := lib.NewStoreWithCleanup(ctx, lib.WithPayloadMemoryBudget(*payloadMemoryBudget<<20), lib.WithRetentionSlots(*storeRetention), lib.WithSlotDuration(chainConfig.SlotDuration()), lib.WithReputationFile(*reputationFile), lib.WithStoreLogger(logger), lib.WithStoreName(chainConfig.Name))
+	var levelDB *lib.LevelDBStore
+	if *storeDir != "" {
+		levelDB, err = lib.NewLevelDBStore(ctx, *storeDir, *storeTTL, logger)
+		if err != nil {
+			log.WithError(err).Fatal("could not open store")
+		}
+		store = levelDB
+	}
+
+	relays := lib.WithRelayURLs(_relayURLs...)
+	if fileConfig != nil && len(fileConfig.relays) > 0 && !cmdline["relayUrl"] {
+		relays = lib.WithRelays(append(fileConfig.relays, shadowRelays()...)...)
+	} else if *shadowRelayURLs != "" {
+		var configs []lib.RelayConfig
+		for _, entry := range _relayURLs {
+			configs = append(configs, lib.RelayConfig{URL: entry})
+		}
+		relays = lib.WithRelays(append(configs, shadowRelays()...)...)
+	}
+	opts := append([]lib.Option{
+		relays,
+		lib.WithStore(store),
+		lib.WithLogger(logger),
+		lib.WithMiddleware(lib.RecoveryMiddleware(logger), lib.MetricsMiddleware()),
+		lib.WithChainConfig(chainConfig),
+		lib.WithNotifyWebhook(*notifyWebhookURL),
+		lib.WithValidatorPubkeys(splitList(*validatorPubkeys)...),
+		lib.WithSigner(signer),
+		lib.WithPreferencesAPI(preferencesToken),
+	}, shared...)
+	if *beaconNodeURL != "" {
+		opts = append(opts, lib.WithFinalizedEviction(lib.NewBeaconClient(*beaconNodeURL)))
+	}
+	if *verifySignatures {
+		opts = append(opts, lib.WithProposerSignatureVerification(lib.NewBeaconClient(*beaconNodeURL)))
+		if *signaturesFailOpen {
+			opts = append(opts, lib.WithProposerSignatureFailOpen())
+		}
+	}
+	if *verifyBidSignatures {
+		opts = append(opts, lib.WithBidSignatureVerification())
+	}
+	if *readinessInterval > 0 {
+		engines := localClients
+		if len(engines) == 0 && *executionNodeURL != "" {
+			engines = []*lib.ExecutionClient{lib.NewExecutionClient(*executionNodeURL)}
+		}
+		opts = append(opts, lib.WithReadinessChecks(*readinessInterval, engines...))
+	}
+	if *checkHeaders {
+		var el *lib.ExecutionClient
+		if *executionNodeURL != "" {
+			el = lib.NewExecutionClient(*executionNodeURL)
+		}
+		opts = append(opts, lib.WithHeaderChecks(el))
+	} else if *checkGasLimit {
+		var el *lib.ExecutionClient
+		if *executionNodeURL != "" {
+			el = lib.NewExecutionClient(*executionNodeURL)
+		}
+		opts = append(opts, lib.WithGasLimitChecks(el))
+	}
+	if *prefetchHeaders {
+		opts = append(opts, lib.WithHeaderPrefetch(lib.NewBeaconClient(*beaconNodeURL)))
+	}
+	if *checkChainState {
+		opts = append(opts, lib.WithChainStateChecks(lib.NewBeaconClient(*beaconNodeURL)))
+	}
+	if *verifyDeliveries {
+		opts = append(opts, lib.WithDeliveryVerification(lib.NewBeaconClient(*beaconNodeURL), lib.NewExecutionClient(*executionNodeURL)))
+	}
+	if *executionNodeURL != "" {
+		opts = append(opts, lib.WithStateDiffPaymentVerification(lib.NewExecutionClient(*executionNodeURL)))
+	}
+	if *aggregator {
+		opts = append(opts, lib.WithAggregatorMode())
+	}
+	if whitelabelTokens != nil {
+		opts = append(opts, lib.WithWhitelabel(whitelabelTokens, *whitelabelRateLimit, *whitelabelBurst))
+	}
+	if *graphqlAPI {
+		opts = append(opts, lib.WithGraphQL())
+	}
+	if *relayTimingsFile != "" {
+		file, err := os.OpenFile(*relayTimingsFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+		if err != nil {
+			log.WithError(err).Fatal("could not open relay timings file")
+		}
+		opts = append(opts, lib.WithRelayTimings(file))
+	} else if *relayTimings {
+		opts = append(opts, lib.WithRelayTimings(nil))
+	}
+	if *logRelayCalls {
+		opts = append(opts, lib.WithRelayCallLog())
+	}
+	if *simulationURL != "" {
+		opts = append(opts, lib.WithPayloadSimulation(lib.NewEngineClient(*simulationURL, localSecret), *simulationTimeout))
+	}
+	if *passthroughURL != "" {
+		opts = append(opts, lib.WithMethodPassthrough(lib.NewEngineClient(*passthroughURL, localSecret), splitList(*passthroughAllow), splitList(*passthroughDeny)))
+	}
+	if len(localClients) > 0 {
+		opts = append(opts, lib.WithLocalExecutionClients(localClients...))
+	}
+	if *auditFile != "" {
+		sink, err := lib.NewFileAuditSink(*auditFile)
+		if err != nil {
+			log.WithError(err).Fatal("could not open audit file")
+		}
+		opts = append(opts, lib.WithAuditSink(sink))
+	}
+	if *payoutCSV != "" {
+		file, err := lib.OpenPayoutCSVFile(*payoutCSV)
+		if err != nil {
+			log.WithError(err).Fatal("could not open payout CSV file")
+		}
+		opts = append(opts, lib.WithPayoutCSVFile(file))
+	}
+	if *auditS3URL != "" {
+		sink, err := lib.NewS3AuditSink(lib.S3AuditSinkConfig{
+			URL:           *auditS3URL,
+			Region:        *auditS3Region,
+			AccessKey:     os.Getenv("AWS_ACCESS_KEY_ID"),
+			SecretKey:     os.Getenv("AWS_SECRET_ACCESS_KEY"),
+			RetentionDays: *auditS3Retention,
+		})
+		if err != nil {
+			log.WithError(err).Fatal("could not configure S3 audit sink")
+		}
+		opts = append(opts, lib.WithAuditSink(sink))
+	}
+	if *auditPostgres != "" {
+		connectCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
+		sink, err := lib.NewPostgresAuditSink(connectCtx, *auditPostgres, *auditPostgresTable)
+		cancel()
+		if err != nil {
+			log.WithError(err).Fatal("could not connect to the Postgres audit database")
+		}
+		opts = append(opts, lib.WithAuditSink(sink))
+	}
+	if *ntpServer != "" {
+		opts = append(opts, lib.WithClockSkewCheck(*ntpServer, *clockSkewThreshold, *adjustForClockSkew))
+	}
+	if *policyURL != "" {
+		opts = append(opts, lib.WithBidDecision(lib.NewOPABidDecision(*policyURL, chainConfig, *policyFailOpen)))
+	}
+	if *relayGroupsFile != "" {
+		groups, err := lib.LoadRelayGroups(*relayGroupsFile)
+		if err != nil {
+			log.WithError(err).Fatal("could not load relay groups")
+		}
+		opts = append(opts, lib.WithRelayGroups(groups))
+	}
+	if *validatorRelaysFile != "" {
+		relays, err := lib.LoadValidatorRelays(*validatorRelaysFile)
+		if err != nil {
+			log.WithError(err).Fatal("could not load validator relays")
+		}
+		opts = append(opts, lib.WithValidatorRelays(relays))
+	}
+	if *defaultFeeRecipient != "" {
+		opts = append(opts, lib.WithDefaultFeeRecipient(common.HexToAddress(*defaultFeeRecipient)))
+	}
+	if *tenantsFile != "" {
+		tenants, err := lib.LoadTenants(*tenantsFile)
+		if err != nil {
+			log.WithError(err).Fatal("could not load tenants")
+		}
+		opts = append(opts, lib.WithTenants(tenants...))
+	}
+	if *enablePprof {
+		opts = append(opts, lib.WithPprof())
+	}
+	if *adminAddr != "" {
+		opts = append(opts, lib.WithoutAdminEndpoints())
+	} else if adminToken != "" {
+		opts = append(opts, lib.WithAdminToken(adminToken))
+	}
+	if adminToken != "" {
+		opts = append(opts, lib.WithAdminAPI(adminToken), lib.WithLogLevelControl(logrusadapter.LevelControl(logrus.StandardLogger())))
+	}
+
+	// one guard for the network of the flags and its frontends, which may serve the same validator
+	frontendShared := shared
+	if *equivocationGuard != "off" {
+		guard := lib.WithEquivocationGuard(lib.NewEquivocationGuard(*equivocationGuard == "refuse"))
+		opts = append(opts, guard)
+		frontendShared = append(shared[:len(shared):len(shared)], guard)
+	}
+
+	var reloader *lib.RelayReloader
+	if fileConfig != nil {
+		reloader = lib.NewRelayReloader()
+		opts = append(opts, lib.WithRelayReloader(reloader))
+	}
+
+	var grpcServer *grpc.Server
+	if *grpcAddr != "" {
+		grpcServer = grpc.NewServer()
+		opts = append(opts, lib.WithGRPCServer(grpcServer))
+	}
+
+	router, err := lib.NewRouter(ctx, opts...)
 	if err != nil {
 		panic(err)
 	}
 
+	if grpcServer != nil {
+		grpcListener, err := net.Listen("tcp", *grpcAddr)
+		if err != nil {
+			log.Fatalf("could not listen for gRPC: %v", err)
+		}
+		log.Println("gRPC builder API listening on: ", *grpcAddr)
+		go func() {
+			if err := grpcServer.Serve(grpcListener); err != nil {
+				log.Fatalf("error in gRPC server: %v", err)
+			}
+		}()
+	}
+
+	server := &http.Server{Addr: ":" + strconv.Itoa(*port), Handler: router}
+	servers := []*http.Server{server}
+	if *adminAddr != "" {
+		admin := &http.Server{Addr: *adminAddr, Handler: lib.NewAdminRouter(adminToken, *enablePprof)}
+		log.Println("admin endpoints listening on: ", *adminAddr)
+		go func() {
+			if err := admin.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+				log.Fatalf("error in admin server: %v", err)
+			}
+		}()
+		servers = append(servers, admin)
+	}
+
+	if *networksFile != "" {
+		networks, err := loadNetworks(*networksFile) // checked by validateFlags
+		if err != nil {
+			log.WithError(err).Fatal("could not load networks")
+		}
+		for _, n := range networks {
+			servers = append(servers, serveNetwork(ctx, n, shared, adminToken, log))
+		}
+	}
+	if *frontendsFile != "" {
+		frontends, err := loadFrontends(*frontendsFile) // checked by validateFlags
+		if err != nil {
+			log.WithError(err).Fatal("could not load frontends")
+		}
+		for _, f := range frontends {
+			servers = append(servers, serveFrontend(ctx, f, relays, store, chainConfig, frontendShared, adminToken, log))
+		}
+	}
+
+	drain := func() {
+		log.WithField("timeout", *drainTimeout).Info("shutting down, draining requests in flight")
+		shutdown(servers, grpcServer, *drainTimeout, log)
+	}
 	log.Println("listening on: ", *port)
-	err = http.ListenAndServe(":"+strconv.Itoa(*port), router)
+	if inService {
+		if err := runWindowsService(server, drain, log); err != nil {
+			log.Fatalf("error in service: %v", err)
+		}
+	} else {
+		listener, err := net.Listen("tcp", server.Addr)
+		if err != nil {
+			log.Fatalf("could not listen: %v", err)
+		}
+		if _, err := sdNotify("READY=1"); err != nil {
+			log.WithError(err).Warn("could not notify systemd")
+		}
+		startSystemdWatchdog(ctx, *port, log)
+		serveErr := make(chan error, 1)
+		go func() { serveErr <- server.Serve(listener) }()
 
-	log.Fatalf("error in server: %v", err)
+		signals := make(chan os.Signal, 1)
+		signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+		reload := make(chan os.Signal, 1)
+		if fileConfig != nil {
+			signal.Notify(reload, syscall.SIGHUP)
+		}
+	wait:
+		for {
+			select {
+			case err := <-serveErr:
+				log.Fatalf("error in server: %v", err)
+			case <-reload:
+				reloadConfigFile(*configPath, fileConfig, cmdline, reloader, log)
+			case sig := <-signals:
+				log.WithField("signal", sig).Info("received signal")
+				break wait
+			}
+		}
+		signal.Stop(signals) // a second signal kills the process right away
+		if _, err := sdNotify("STOPPING=1"); err != nil {
+			log.WithError(err).Warn("could not notify systemd")
+		}
+		drain()
+	}
+
+	stopBackground()
+	if levelDB != nil {
+		if err := levelDB.Close(); err != nil {
+			log.WithError(err).Error("could not close store")
+		}
+	}
+	log.Info("stopped")
+	logrus.Exit(0) // runs the exit handlers, which flush the log buffer
+}
+
+func loadChainConfig(network, chainConfigPath, beaconNodeURL string) (*lib.ChainConfig, error) {
+	if chainConfigPath != "" {
+		return lib.LoadChainConfig(chainConfigPath)
+	}
+	if beaconNodeURL != "" {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		return lib.NewBeaconClient(beaconNodeURL).ChainConfig(ctx)
+	}
+	return lib.ChainConfigByName(network)
+}
+
+// checkSigner logs the keys of the remote signer and warns about validators it can't sign for
+func checkSigner(signer lib.Signer, validatorPubkeys []string, log *logrus.Entry) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	pubkeys, err := signer.PublicKeys(ctx)
+	if err != nil {
+		log.WithError(err).Warn("could not list keys of remote signer")
+		return
+	}
+	log.WithField("keys", len(pubkeys)).Info("using remote signer")
+
+	available := make(map[string]bool, len(pubkeys))
+	for _, pubkey := range pubkeys {
+		available[strings.ToLower(pubkey)] = true
+	}
+	for _, pubkey := range validatorPubkeys {
+		if !available[strings.ToLower(pubkey)] {
+			log.WithField("pubkey", pubkey).Warn("remote signer has no key for validator")
+		}
+	}
+}
+
+// readTokenFile reads a single token from path, surrounding whitespace is ignored
+func readTokenFile(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	token := strings.TrimSpace(string(data))
+	if token == "" {
+		return "", fmt.Errorf("%s is empty", path)
+	}
+	return token, nil
+}
+
+// readTokens reads one token per line, ignoring empty lines and lines starting with #
+func readTokens(path string) ([]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var tokens []string
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line != "" && !strings.HasPrefix(line, "#") {
+			tokens = append(tokens, line)
+		}
+	}
+	return tokens, nil
+}
+
+// shadowRelays returns the relays of -shadowRelayUrl
+func shadowRelays() []lib.RelayConfig {
+	var relays []lib.RelayConfig
+	for _, entry := range splitList(*shadowRelayURLs) {
+		relays = append(relays, lib.RelayConfig{URL: entry, Shadow: true})
+	}
+	return relays
+}
+
+// splitList splits a comma-separated flag value, ignoring empty entries
+func splitList(value string) []string {
+	var entries []string
+	for _, entry := range strings.Split(value, ",") {
+		if entry = strings.TrimSpace(entry); entry != "" {
+			entries = append(entries, entry)
+		}
+	}
+	return entries
 }
 
-func getEnv(key string, defaultValue string) string {
-	if value, ok := os.LookupEnv(key); ok {
-		return value
+// logFormatter returns the logrus formatter of a -logFormat
+func logFormatter(format string) logrus.Formatter {
+	switch format {
+	case "json":
+		return &logrus.JSONFormatter{}
+	case "logfmt":
+		return &logrus.TextFormatter{DisableColors: true, FullTimestamp: true}
+	default:
+		return &logrus.TextFormatter{}
 	}
-	return defaultValue
 }
@@ -0,0 +1,11 @@
+//go:build go1.19
+
+package main
+
+import "runtime/debug"
+
+// setMemoryLimit sets the soft memory limit of the Go runtime, like GOMEMLIMIT
+func setMemoryLimit(bytes int64) bool {
+	debug.SetMemoryLimit(bytes)
+	return true
+}
@@ -0,0 +1,8 @@
+//go:build !go1.19
+
+package main
+
+// setMemoryLimit is a no-op, the Go runtime has no memory limit before Go 1.19
+func setMemoryLimit(bytes int64) bool {
+	return false
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+
+	"github.com/flashbots/mev-boost/lib"
+	"github.com/flashbots/mev-boost/lib/client"
+	"github.com/flashbots/mev-boost/lib/logrusadapter"
+	"github.com/sirupsen/logrus"
+)
+
+// networkConfig is a network of -networksFile, served next to the network of the flags on a port of its own. Its relays,
+// chain config and store are its own, the options of the flags that don't depend on a network apply to it too.
+type networkConfig struct {
+	// Name is mainnet, sepolia or ropsten, or the name of the network in logs if ChainConfig is set
+	Name string `json:"name"`
+	// ChainConfig is the path of a consensus-spec style config.yaml, for networks not known by name
+	ChainConfig    string   `json:"chain_config,omitempty"`
+	Port           int      `json:"port"`
+	RelayURLs      []string `json:"relays"`
+	ReputationFile string   `json:"reputation_file,omitempty"`
+}
+
+// loadNetworks reads a JSON list of networks from path
+func loadNetworks(path string) ([]networkConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var networks []networkConfig
+	if err := json.Unmarshal(data, &networks); err != nil {
+		return nil, fmt.Errorf("could not parse networks: %w", err)
+	}
+	return networks, nil
+}
+
+// validateNetworks checks the networks of -networksFile, whose ports must differ from -port and each other
+func validateNetworks(networks []networkConfig) []error {
+	var errs []error
+	fail := func(n networkConfig, format string, args ...interface{}) {
+		errs = append(errs, fmt.Errorf("-networksFile: network %q: %s", n.Name, fmt.Sprintf(format, args...)))
+	}
+
+	ports := map[int]bool{*port: true}
+	reputationFiles := map[string]bool{*reputationFile: true}
+	for _, n := range networks {
+		if n.Name == "" {
+			fail(n, "no name")
+		}
+		if _, err := lib.ChainConfigByName(n.Name); err != nil && n.ChainConfig == "" {
+			fail(n, "%v", err)
+		}
+		if n.Port <= 0 || n.Port > 65535 {
+			fail(n, "%d is not a valid port", n.Port)
+		} else if ports[n.Port] {
+			fail(n, "port %d is already used", n.Port)
+		}
+		ports[n.Port] = true
+		if len(n.RelayURLs) == 0 {
+			fail(n, "no relay configured")
+		}
+		for _, relayURL := range n.RelayURLs {
+			if err := client.ValidateRelayEntry(relayURL); err != nil {
+				fail(n, "%v", err)
+			}
+		}
+		if n.ReputationFile != "" && reputationFiles[n.ReputationFile] {
+			fail(n, "reputation file %s is already used", n.ReputationFile)
+		}
+		reputationFiles[n.ReputationFile] = true
+	}
+	return errs
+}
+
+// loadNetworkChainConfig returns the chain config of a network of -networksFile
+func loadNetworkChainConfig(n networkConfig) (*lib.ChainConfig, error) {
+	if n.ChainConfig != "" {
+		return lib.LoadChainConfig(n.ChainConfig)
+	}
+	return lib.ChainConfigByName(n.Name)
+}
+
+// serveNetwork serves a network of -networksFile on its port, with the options of the flags in shared. The admin
+// endpoints are only served by the network of the flags, metrics are the same for all networks.
+func serveNetwork(ctx context.Context, n networkConfig, shared []lib.Option, adminToken string, log *logrus.Entry) *http.Server {
+	chainConfig, err := loadNetworkChainConfig(n)
+	if err != nil {
+		log.WithError(err).WithField("network", n.Name).Fatal("could not load chain config")
+	}
+	log = log.WithField("network", chainConfig.Name)
+	logger := logrusadapter.New(log)
+	opts := append([]lib.Option{
+		lib.WithRelayURLs(n.RelayURLs...),
+		lib.WithStore(lib.NewStoreWithCleanup(ctx, lib.WithPayloadMemoryBudget(*payloadMemoryBudget<<20), lib.WithRetentionSlots(*storeRetention), lib.WithSlotDuration(chainConfig.SlotDuration()), lib.WithReputationFile(n.ReputationFile), lib.WithStoreLogger(logger), lib.WithStoreName(chainConfig.Name))),
+		lib.WithLogger(logger),
+		lib.WithMiddleware(lib.RecoveryMiddleware(logger), lib.MetricsMiddleware()),
+		lib.WithChainConfig(chainConfig),
+		lib.WithoutAdminEndpoints(),
+	}, shared...)
+	if adminToken != "" {
+		opts = append(opts, lib.WithAdminToken(adminToken))
+	}
+	router, err := lib.NewRouter(ctx, opts...)
+	if err != nil {
+		log.WithError(err).Fatal("could not create router")
+	}
+
+	server := &http.Server{Addr: ":" + strconv.Itoa(n.Port), Handler: router}
+	log.WithField("relays", len(n.RelayURLs)).Println("listening on: ", n.Port)
+	go func() {
+		if err := server.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+			log.Fatalf("error in server of network %s: %v", n.Name, err)
+		}
+	}()
+	return server
+}
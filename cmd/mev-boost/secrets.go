@@ -0,0 +1,91 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// secretFlags may carry credentials, like relay tokens in the user part of a url or API keys of node providers, and
+// can be set from the environment instead of the command line, which other users of the host can read.
+var secretFlags = []secretFlag{
+	{"relayUrl", "RELAY_URLS", true},
+	{"notifyWebhookUrl", "NOTIFY_WEBHOOK_URL", false},
+	{"web3SignerUrl", "WEB3SIGNER_URL", false},
+	{"executionNodeUrl", "EXECUTION_NODE_URL", false},
+	{"beaconNodeUrl", "BEACON_NODE_URL", false},
+	{"auditPostgres", "AUDIT_POSTGRES_URL", false},
+}
+
+type secretFlag struct {
+	name, env string
+	list      bool // a comma-separated list, whose _FILE may have one entry per line
+}
+
+// envReference matches ${NAME} in secret flag values
+var envReference = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+
+// resolveSecrets sets the secret flags that aren't given on the command line from their environment variable, or from
+// the file named by the variable with a _FILE suffix, like docker and kubernetes secrets. ${NAME} references in the
+// values are then replaced by the environment variable NAME.
+func resolveSecrets() []error {
+	var errs []error
+	fail := func(name, format string, args ...interface{}) {
+		errs = append(errs, fmt.Errorf("-%s: %s", name, fmt.Sprintf(format, args...)))
+	}
+	set := make(map[string]bool)
+	flag.Visit(func(f *flag.Flag) { set[f.Name] = true })
+
+	for _, secret := range secretFlags {
+		value, err := secret.resolve(flag.Lookup(secret.name).Value.String(), set[secret.name])
+		if err != nil {
+			fail(secret.name, "%v", err)
+			continue
+		}
+		if err := flag.Set(secret.name, value); err != nil {
+			fail(secret.name, "%v", err)
+		}
+	}
+	return errs
+}
+
+// resolve returns the value of the secret flag, given its value and whether it was set on the command line
+func (secret secretFlag) resolve(value string, cmdline bool) (string, error) {
+	path, fromFile := os.LookupEnv(secret.env + "_FILE")
+	if _, fromEnv := os.LookupEnv(secret.env); fromFile && fromEnv {
+		return "", fmt.Errorf("both %s and %s_FILE are set", secret.env, secret.env)
+	}
+	if fromFile && !cmdline {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return "", fmt.Errorf("could not read %s_FILE: %w", secret.env, err)
+		}
+		if secret.list {
+			value = strings.Join(strings.Fields(string(data)), ",") // one entry per line
+		} else {
+			value = strings.TrimSpace(string(data))
+		}
+	} else if env, ok := os.LookupEnv(secret.env); ok && !cmdline {
+		value = env
+	}
+	return expandEnvReferences(value)
+}
+
+// expandEnvReferences replaces ${NAME} references in value by the environment variable NAME
+func expandEnvReferences(value string) (string, error) {
+	var missing []string
+	value = envReference.ReplaceAllStringFunc(value, func(reference string) string {
+		name := envReference.FindStringSubmatch(reference)[1]
+		env, ok := os.LookupEnv(name)
+		if !ok {
+			missing = append(missing, name)
+		}
+		return env
+	})
+	if len(missing) > 0 {
+		return "", fmt.Errorf("environment variable %s is not set", strings.Join(missing, ", "))
+	}
+	return value, nil
+}
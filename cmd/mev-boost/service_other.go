@@ -0,0 +1,32 @@
+//go:build !windows
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+
+	"github.com/sirupsen/logrus"
+)
+
+var errNotWindows = errors.New("mev-boost can only run as a service on Windows")
+
+func isWindowsService() bool {
+	return false
+}
+
+func logToEventLog() error {
+	return errNotWindows
+}
+
+func runWindowsService(_ *http.Server, _ func(), _ *logrus.Entry) error {
+	return errNotWindows
+}
+
+// serviceCommand runs `mev-boost service`, which is only supported on Windows
+func serviceCommand(_ []string) int {
+	fmt.Fprintf(os.Stderr, "%v, use a systemd unit or similar on this platform\n", errNotWindows)
+	return 1
+}
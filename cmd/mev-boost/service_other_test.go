@@ -0,0 +1,17 @@
+//go:build !windows
+
+package main
+
+import (
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWindowsServiceUnsupported(t *testing.T) {
+	require.False(t, isWindowsService())
+	require.ErrorIs(t, logToEventLog(), errNotWindows)
+	require.ErrorIs(t, runWindowsService(nil, func() {}, logrus.NewEntry(logrus.New())), errNotWindows)
+	require.Equal(t, 1, serviceCommand([]string{"install"}))
+}
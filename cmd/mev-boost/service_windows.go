@@ -0,0 +1,197 @@
+//go:build windows
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"golang.org/x/sys/windows/svc"
+	"golang.org/x/sys/windows/svc/eventlog"
+	"golang.org/x/sys/windows/svc/mgr"
+)
+
+const serviceName = "mev-boost"
+
+// isWindowsService reports whether mev-boost was started by the service control manager
+func isWindowsService() bool {
+	inService, err := svc.IsWindowsService()
+	return err == nil && inService
+}
+
+// eventWriter is the part of *eventlog.Log used by eventLogHook
+type eventWriter interface {
+	Error(eid uint32, msg string) error
+	Warning(eid uint32, msg string) error
+	Info(eid uint32, msg string) error
+}
+
+// eventLogHook writes log entries to the Windows event log, services have no console
+type eventLogHook struct {
+	log eventWriter
+}
+
+func (h *eventLogHook) Levels() []logrus.Level {
+	return logrus.AllLevels
+}
+
+func (h *eventLogHook) Fire(entry *logrus.Entry) error {
+	msg, err := entry.String()
+	if err != nil {
+		return err
+	}
+	switch entry.Level {
+	case logrus.PanicLevel, logrus.FatalLevel, logrus.ErrorLevel:
+		return h.log.Error(1, msg)
+	case logrus.WarnLevel:
+		return h.log.Warning(1, msg)
+	default:
+		return h.log.Info(1, msg)
+	}
+}
+
+// logToEventLog sends all logs to the event log source registered by `mev-boost service install`
+func logToEventLog() error {
+	log, err := eventlog.Open(serviceName)
+	if err != nil {
+		return err
+	}
+	logrus.AddHook(&eventLogHook{log})
+	logrus.SetOutput(io.Discard)
+	return nil
+}
+
+// windowsService serves the router until the service control manager stops the service, then drains the servers
+type windowsService struct {
+	server *http.Server
+	drain  func()
+	log    *logrus.Entry
+}
+
+func (s *windowsService) Execute(_ []string, requests <-chan svc.ChangeRequest, status chan<- svc.Status) (bool, uint32) {
+	status <- svc.Status{State: svc.StartPending}
+	errC := make(chan error, 1)
+	go func() {
+		if err := s.server.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+			errC <- err
+		}
+	}()
+	status <- svc.Status{State: svc.Running, Accepts: svc.AcceptStop | svc.AcceptShutdown}
+
+	for {
+		select {
+		case err := <-errC:
+			s.log.WithError(err).Error("error in server")
+			return true, 1
+		case req := <-requests:
+			switch req.Cmd {
+			case svc.Interrogate:
+				status <- req.CurrentStatus
+			case svc.Stop, svc.Shutdown:
+				status <- svc.Status{State: svc.StopPending, WaitHint: uint32((*drainTimeout + time.Second).Milliseconds())}
+				s.drain()
+				return false, 0
+			}
+		}
+	}
+}
+
+// runWindowsService runs server as the mev-boost service, it returns when the service is stopped and drain returned
+func runWindowsService(server *http.Server, drain func(), log *logrus.Entry) error {
+	return svc.Run(serviceName, &windowsService{server, drain, log})
+}
+
+// serviceCommand runs `mev-boost service <install|uninstall|start|stop>` and returns the exit code
+func serviceCommand(args []string) int {
+	if len(args) == 0 {
+		fmt.Fprintf(os.Stderr, "Usage: mev-boost service install [mev-boost flags] | uninstall | start | stop\n\n")
+		fmt.Fprintf(os.Stderr, "Manages the %s Windows service. The flags given to install are used each time the service starts.\n", serviceName)
+		return 2
+	}
+
+	var err error
+	switch args[0] {
+	case "install":
+		err = installService(args[1:])
+	case "uninstall":
+		err = uninstallService()
+	case "start":
+		err = withService(func(s *mgr.Service) error { return s.Start() })
+	case "stop":
+		err = withService(func(s *mgr.Service) error {
+			_, err := s.Control(svc.Stop)
+			return err
+		})
+	default:
+		err = fmt.Errorf("unknown service command %q", args[0])
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		return 1
+	}
+	return 0
+}
+
+func installService(args []string) error {
+	exe, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	m, err := mgr.Connect()
+	if err != nil {
+		return err
+	}
+	defer m.Disconnect()
+
+	if s, err := m.OpenService(serviceName); err == nil {
+		s.Close()
+		return fmt.Errorf("service %s is already installed", serviceName)
+	}
+	s, err := m.CreateService(serviceName, exe, mgr.Config{
+		DisplayName: "mev-boost",
+		Description: "Connects the consensus client to MEV relays",
+		StartType:   mgr.StartAutomatic,
+	}, args...)
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+
+	if err := eventlog.InstallAsEventCreate(serviceName, eventlog.Error|eventlog.Warning|eventlog.Info); err != nil {
+		s.Delete()
+		return fmt.Errorf("could not register event log source: %w", err)
+	}
+	return nil
+}
+
+func uninstallService() error {
+	err := withService(func(s *mgr.Service) error { return s.Delete() })
+	if err != nil {
+		return err
+	}
+	return eventlog.Remove(serviceName)
+}
+
+// withService calls f with the installed mev-boost service
+func withService(f func(s *mgr.Service) error) error {
+	m, err := mgr.Connect()
+	if err != nil {
+		return err
+	}
+	defer m.Disconnect()
+
+	s, err := m.OpenService(serviceName)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("service %s is not installed", serviceName)
+		}
+		return fmt.Errorf("could not open service %s: %w", serviceName, err)
+	}
+	defer s.Close()
+	return f(s)
+}
@@ -0,0 +1,112 @@
+//go:build windows
+
+package main
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/sys/windows/svc"
+)
+
+type mockEventLog struct {
+	events []string
+}
+
+func (l *mockEventLog) Error(_ uint32, msg string) error {
+	l.events = append(l.events, "error: "+msg)
+	return nil
+}
+
+func (l *mockEventLog) Warning(_ uint32, msg string) error {
+	l.events = append(l.events, "warning: "+msg)
+	return nil
+}
+
+func (l *mockEventLog) Info(_ uint32, msg string) error {
+	l.events = append(l.events, "info: "+msg)
+	return nil
+}
+
+func TestEventLogHook(t *testing.T) {
+	events := &mockEventLog{}
+	log := logrus.New()
+	log.SetFormatter(&logrus.TextFormatter{DisableTimestamp: true, DisableColors: true})
+	log.SetLevel(logrus.DebugLevel)
+	log.AddHook(&eventLogHook{events})
+
+	log.Error("failed")
+	log.Warn("slow")
+	log.Info("started")
+	log.Debug("details")
+
+	require.Equal(t, []string{
+		"error: level=error msg=failed\n",
+		"warning: level=warning msg=slow\n",
+		"info: level=info msg=started\n",
+		"info: level=debug msg=details\n",
+	}, events.events)
+}
+
+func TestWindowsService_Execute(t *testing.T) {
+	drained := make(chan struct{})
+	server := &http.Server{Addr: "127.0.0.1:0"}
+	service := &windowsService{
+		server: server,
+		drain: func() {
+			server.Close()
+			close(drained)
+		},
+		log: logrus.NewEntry(logrus.New()),
+	}
+
+	requests := make(chan svc.ChangeRequest)
+	status := make(chan svc.Status, 4)
+	type result struct {
+		svcSpecific bool
+		exitCode    uint32
+	}
+	done := make(chan result, 1)
+	go func() {
+		svcSpecific, exitCode := service.Execute(nil, requests, status)
+		done <- result{svcSpecific, exitCode}
+	}()
+
+	require.Equal(t, svc.StartPending, (<-status).State)
+	running := <-status
+	require.Equal(t, svc.Running, running.State)
+	require.Equal(t, svc.AcceptStop|svc.AcceptShutdown, running.Accepts)
+
+	requests <- svc.ChangeRequest{Cmd: svc.Interrogate, CurrentStatus: running}
+	require.Equal(t, running, <-status)
+
+	requests <- svc.ChangeRequest{Cmd: svc.Stop}
+	require.Equal(t, svc.StopPending, (<-status).State)
+	select {
+	case <-drained:
+	case <-time.After(time.Second):
+		t.Fatal("servers weren't drained")
+	}
+	require.Equal(t, result{false, 0}, <-done)
+}
+
+func TestWindowsService_ExecuteServerError(t *testing.T) {
+	service := &windowsService{
+		server: &http.Server{Addr: "invalid address"},
+		drain:  func() { t.Fatal("drained after a server error") },
+		log:    logrus.NewEntry(logrus.New()),
+	}
+
+	status := make(chan svc.Status, 4)
+	svcSpecific, exitCode := service.Execute(nil, make(chan svc.ChangeRequest), status)
+	require.True(t, svcSpecific)
+	require.Equal(t, uint32(1), exitCode)
+}
+
+func TestServiceCommand_Usage(t *testing.T) {
+	require.Equal(t, 2, serviceCommand(nil))
+	require.Equal(t, 1, serviceCommand([]string{"restart"}))
+}
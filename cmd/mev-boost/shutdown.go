@@ -0,0 +1,49 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"google.golang.org/grpc"
+)
+
+// shutdown stops the servers from accepting new connections and waits up to timeout for the requests in flight, so a
+// proposal in progress is still delivered. Requests still running after timeout are cut off.
+func shutdown(servers []*http.Server, grpcServer *grpc.Server, timeout time.Duration, log *logrus.Entry) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	var wg sync.WaitGroup
+	for _, server := range servers {
+		server := server
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := server.Shutdown(ctx); err != nil {
+				log.WithError(err).WithField("addr", server.Addr).Warn("requests still in flight after the drain timeout")
+				server.Close()
+			}
+		}()
+	}
+	if grpcServer != nil {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			stopped := make(chan struct{})
+			go func() {
+				grpcServer.GracefulStop()
+				close(stopped)
+			}()
+			select {
+			case <-stopped:
+			case <-ctx.Done():
+				log.Warn("gRPC calls still in flight after the drain timeout")
+				grpcServer.Stop()
+			}
+		}()
+	}
+	wg.Wait()
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// sdNotify sends state to systemd, see sd_notify(3). It returns false without error if mev-boost wasn't started by a
+// Type=notify unit.
+func sdNotify(state string) (bool, error) {
+	socket := os.Getenv("NOTIFY_SOCKET")
+	if socket == "" {
+		return false, nil
+	}
+	if socket[0] == '@' { // abstract socket
+		socket = "\x00" + socket[1:]
+	}
+
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: socket, Net: "unixgram"})
+	if err != nil {
+		return false, err
+	}
+	defer conn.Close()
+	if _, err := conn.Write([]byte(state)); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// sdWatchdogInterval returns the watchdog timeout of the systemd unit, or 0 if the watchdog isn't enabled for this process
+func sdWatchdogInterval() (time.Duration, error) {
+	usec := os.Getenv("WATCHDOG_USEC")
+	if usec == "" {
+		return 0, nil
+	}
+	if pid := os.Getenv("WATCHDOG_PID"); pid != "" && pid != strconv.Itoa(os.Getpid()) {
+		return 0, nil
+	}
+	interval, err := strconv.ParseUint(usec, 10, 64)
+	if err != nil || interval == 0 {
+		return 0, fmt.Errorf("invalid WATCHDOG_USEC %q", usec)
+	}
+	return time.Duration(interval) * time.Microsecond, nil
+}
+
+// startSystemdWatchdog pings the systemd watchdog at half its timeout, as long as the server at port answers. A hung server
+// misses the pings and systemd restarts it.
+func startSystemdWatchdog(ctx context.Context, port int, log *logrus.Entry) {
+	timeout, err := sdWatchdogInterval()
+	if err != nil {
+		log.WithError(err).Warn("systemd watchdog disabled")
+		return
+	}
+	if timeout == 0 {
+		return
+	}
+
+	interval := timeout / 2
+	client := &http.Client{Timeout: interval}
+	url := fmt.Sprintf("http://127.0.0.1:%d/mev-boost/v1/livez", port)
+	log.WithField("timeout", timeout).Info("pinging systemd watchdog")
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+
+			resp, err := client.Get(url)
+			if err != nil {
+				log.WithError(err).Warn("server didn't answer, skipping systemd watchdog ping")
+				continue
+			}
+			resp.Body.Close()
+			if _, err := sdNotify("WATCHDOG=1"); err != nil {
+				log.WithError(err).Warn("could not ping systemd watchdog")
+			}
+		}
+	}()
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/flashbots/mev-boost/lib"
+	"github.com/flashbots/mev-boost/lib/client"
+)
+
+// testRelay runs `mev-boost test-relay [flags] <url>` and returns the exit code
+func testRelay(args []string) int {
+	flags := flag.NewFlagSet("test-relay", flag.ExitOnError)
+	network := flags.String("network", "mainnet", "network the relay runs on: mainnet, sepolia or ropsten")
+	chainConfigPath := flags.String("chainConfig", "", "path to a consensus-spec style config.yaml for custom networks, overrides -network")
+	beaconNodeURL := flags.String("beaconNodeUrl", "", "beacon node REST API url, used to fetch genesis and the fork schedule instead of -network")
+	headBlockHash := flags.String("headBlockHash", "", "execution block hash the relay should build on")
+	propose := flags.Bool("propose", false, "also reveal the payload, not allowed on mainnet")
+	timeout := flags.Duration("timeout", 30*time.Second, "timeout for all checks")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: mev-boost test-relay [flags] <relay url>\n\nChecks a relay with a throwaway fee recipient before adding it to -relayUrl.\n\n")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return 2
+	}
+
+	chainConfig, err := loadChainConfig(*network, *chainConfigPath, *beaconNodeURL)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "could not load chain config: %v\n", err)
+		return 1
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+
+	report, err := client.CheckRelay(ctx, flags.Arg(0), client.CheckOpts{
+		ChainConfig:   chainConfig,
+		HeadBlockHash: common.HexToHash(*headBlockHash),
+		Propose:       *propose,
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "could not check relay: %v\n", err)
+		return 1
+	}
+
+	printCheckReport(report, chainConfig)
+	if !report.Passed() {
+		fmt.Println("\nrelay check failed")
+		return 1
+	}
+	fmt.Println("\nrelay check passed")
+	return 0
+}
+
+// checkRelays runs `mev-boost check-relays [flags]`, the checks of test-relay for each relay of -relayUrl, and returns
+// the exit code
+func checkRelays(args []string) int {
+	flags := flag.NewFlagSet("check-relays", flag.ExitOnError)
+	relayURLs := flags.String("relayUrl", defaultRelayURLs, "relay urls - single entry or comma-separated list")
+	network := flags.String("network", "mainnet", "network the relays run on: mainnet, sepolia or ropsten")
+	chainConfigPath := flags.String("chainConfig", "", "path to a consensus-spec style config.yaml for custom networks, overrides -network")
+	beaconNodeURL := flags.String("beaconNodeUrl", "", "beacon node REST API url, used to fetch genesis and the fork schedule instead of -network")
+	timeout := flags.Duration("timeout", 30*time.Second, "timeout for all checks")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: mev-boost check-relays [flags]\n\nChecks each relay mev-boost is configured with, pass the -relayUrl mev-boost runs with.\n\n")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	relays := splitList(*relayURLs)
+	if len(relays) == 0 {
+		fmt.Fprintln(os.Stderr, "no relays, set -relayUrl")
+		return 2
+	}
+	chainConfig, err := loadChainConfig(*network, *chainConfigPath, *beaconNodeURL)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "could not load chain config: %v\n", err)
+		return 1
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+
+	reports := make([]*client.CheckReport, len(relays))
+	errs := make([]error, len(relays))
+	var wg sync.WaitGroup
+	for i, relay := range relays {
+		wg.Add(1)
+		go func(i int, relay string) {
+			defer wg.Done()
+			reports[i], errs[i] = client.CheckRelay(ctx, relay, client.CheckOpts{ChainConfig: chainConfig})
+		}(i, relay)
+	}
+	wg.Wait()
+
+	failed := 0
+	for i, report := range reports {
+		if i > 0 {
+			fmt.Println()
+		}
+		if errs[i] != nil {
+			fmt.Printf("relay:         %s\ncould not check relay: %v\n", relays[i], errs[i])
+			failed++
+			continue
+		}
+		printCheckReport(report, chainConfig)
+		if !report.Passed() {
+			failed++
+		}
+	}
+
+	if failed > 0 {
+		fmt.Printf("\n%d of %d relays failed the check\n", failed, len(relays))
+		return 1
+	}
+	fmt.Printf("\nall %d relays passed the check\n", len(relays))
+	return 0
+}
+
+// printCheckReport prints the steps of a relay check
+func printCheckReport(report *client.CheckReport, chainConfig *lib.ChainConfig) {
+	fmt.Printf("relay:         %s\n", report.RelayURL)
+	fmt.Printf("network:       %s\n", chainConfig.Name)
+	fmt.Printf("fee recipient: %s (throwaway)\n\n", report.FeeRecipient)
+	for _, step := range report.Steps {
+		status := "FAIL"
+		if step.Skipped {
+			status = "SKIP"
+		} else if step.Passed {
+			status = "PASS"
+		}
+		fmt.Printf("%s  %-11s %-30s %6dms  %s\n", status, step.Name, step.Method, step.Duration.Milliseconds(), step.Detail)
+	}
+}
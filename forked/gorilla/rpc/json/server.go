@@ -32,6 +32,12 @@ type serverRequest struct {
 	Id *json.RawMessage `json:"id"`
 }
 
+// Error is an error with a JSON-RPC error code. Methods can return errors implementing it to set the code of the response.
+type Error interface {
+	error
+	ErrorCode() int
+}
+
 type jsonError struct {
 	Code    int         `json:"code"`
 	Message string      `json:"message"`
@@ -146,6 +152,10 @@ func (c *CodecRequest) WriteResponse(w http.ResponseWriter, reply interface{}, m
 	if methodErr != nil {
 		// Propagate error message as string.
 		res.Error = &jsonError{Message: methodErr.Error()}
+		var codedErr Error
+		if errors.As(methodErr, &codedErr) {
+			res.Error.Code = codedErr.ErrorCode()
+		}
 		// Result must be null if there was an error invoking the method.
 		// http://json-rpc.org/wiki/specification#a1.2Response
 		res.Result = &null
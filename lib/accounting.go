@@ -0,0 +1,99 @@
+package lib
+
+import (
+	"math/big"
+	"sort"
+	"sync"
+)
+
+// RelayAccount compares the bid values a relay promised to the payments realized by its payloads
+type RelayAccount struct {
+	RelayURL    string   `json:"relayUrl"`
+	Payloads    uint64   `json:"payloads"`    // revealed payloads for which the bid was known
+	Unverified  uint64   `json:"unverified"`  // payloads whose payment could not be checked
+	Underpaid   uint64   `json:"underpaid"`   // payloads that paid less than promised
+	Promised    *big.Int `json:"promised"`    // sum of promised values of verified payloads
+	Paid        *big.Int `json:"paid"`        // sum of realized payments of verified payloads
+	Discrepancy *big.Int `json:"discrepancy"` // promised minus paid, summed over underpaid payloads
+	Suspended   bool     `json:"suspended"`
+}
+
+func newRelayAccount(relayURL string) *RelayAccount {
+	return &RelayAccount{
+		RelayURL:    relayURL,
+		Promised:    new(big.Int),
+		Paid:        new(big.Int),
+		Discrepancy: new(big.Int),
+	}
+}
+
+// relayAccounting keeps a RelayAccount per relay
+type relayAccounting struct {
+	mu       sync.RWMutex
+	accounts map[string]*RelayAccount
+}
+
+func newRelayAccounting() *relayAccounting {
+	return &relayAccounting{accounts: make(map[string]*RelayAccount)}
+}
+
+func (a *relayAccounting) account(relayURL string) *RelayAccount {
+	account, ok := a.accounts[relayURL]
+	if !ok {
+		account = newRelayAccount(relayURL)
+		a.accounts[relayURL] = account
+	}
+	return account
+}
+
+// record adds the outcome of a payment verification. paid is nil if the payment couldn't be verified.
+func (a *relayAccounting) record(bid *Bid, paid *big.Int) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	account := a.account(bid.RelayURL)
+	account.Payloads++
+	relayPayloadsTotal.WithLabelValues(bid.RelayURL).Inc()
+
+	if paid == nil {
+		account.Unverified++
+		relayUnverifiedPayloadsTotal.WithLabelValues(bid.RelayURL).Inc()
+		return
+	}
+
+	promised := bid.Value
+	if promised == nil {
+		promised = new(big.Int)
+	}
+	account.Promised.Add(account.Promised, promised)
+	account.Paid.Add(account.Paid, paid)
+	relayPromisedGweiTotal.WithLabelValues(bid.RelayURL).Add(weiToGwei(promised))
+	relayPaidGweiTotal.WithLabelValues(bid.RelayURL).Add(weiToGwei(paid))
+
+	if paid.Cmp(promised) < 0 {
+		account.Underpaid++
+		account.Discrepancy.Add(account.Discrepancy, new(big.Int).Sub(promised, paid))
+		relayUnderpaidPayloadsTotal.WithLabelValues(bid.RelayURL).Inc()
+	}
+}
+
+// snapshot returns a copy of all accounts, sorted by relay URL
+func (a *relayAccounting) snapshot() []*RelayAccount {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	accounts := make([]*RelayAccount, 0, len(a.accounts))
+	for _, account := range a.accounts {
+		accounts = append(accounts, &RelayAccount{
+			RelayURL:    account.RelayURL,
+			Payloads:    account.Payloads,
+			Unverified:  account.Unverified,
+			Underpaid:   account.Underpaid,
+			Promised:    new(big.Int).Set(account.Promised),
+			Paid:        new(big.Int).Set(account.Paid),
+			Discrepancy: new(big.Int).Set(account.Discrepancy),
+		})
+	}
+	sort.Slice(accounts, func(i, j int) bool { return accounts[i].RelayURL < accounts[j].RelayURL })
+	return accounts
+}
@@ -0,0 +1,51 @@
+//go:build !minimal
+
+package lib
+
+import (
+	"net/http"
+	"net/http/pprof"
+
+	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// MinimalBuild is true in binaries built with the minimal tag, which leave out /metrics, /debug/pprof and the GraphQL API
+const MinimalBuild = false
+
+func init() {
+	// goroutines, heap and GC stats, and open file descriptors, to correlate missed bids with resource pressure
+	metricsRegistry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+}
+
+// NewAdminRouter serves /metrics and, with enablePprof, /debug/pprof, for a separate listener used together with
+// WithoutAdminEndpoints. Requests need token as bearer token unless it's empty.
+func NewAdminRouter(token string, enablePprof bool) *mux.Router {
+	router := mux.NewRouter()
+	handleAdminEndpoints(router, token, enablePprof)
+	return router
+}
+
+// handleAdminEndpoints adds the admin endpoints to router, behind the bearer token unless it's empty
+func handleAdminEndpoints(router *mux.Router, token string, enablePprof bool) {
+	admin := func(handler http.Handler) http.Handler {
+		if token == "" {
+			return handler
+		}
+		return BearerTokenMiddleware(token)(handler)
+	}
+
+	router.Handle("/metrics", admin(promhttp.HandlerFor(metricsRegistry, promhttp.HandlerOpts{}))).Methods(http.MethodGet)
+	if !enablePprof {
+		return
+	}
+	router.Handle("/debug/pprof/cmdline", admin(http.HandlerFunc(pprof.Cmdline)))
+	router.Handle("/debug/pprof/profile", admin(http.HandlerFunc(pprof.Profile)))
+	router.Handle("/debug/pprof/symbol", admin(http.HandlerFunc(pprof.Symbol)))
+	router.Handle("/debug/pprof/trace", admin(http.HandlerFunc(pprof.Trace)))
+	router.PathPrefix("/debug/pprof/").Handler(admin(http.HandlerFunc(pprof.Index)))
+}
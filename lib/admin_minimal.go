@@ -0,0 +1,19 @@
+//go:build minimal
+
+package lib
+
+import (
+	"github.com/gorilla/mux"
+)
+
+// MinimalBuild is true in binaries built with the minimal tag, which leave out /metrics, /debug/pprof and the GraphQL API
+const MinimalBuild = true
+
+// NewAdminRouter serves nothing in minimal builds, the admin endpoints are left out
+func NewAdminRouter(string, bool) *mux.Router {
+	return mux.NewRouter()
+}
+
+// handleAdminEndpoints adds nothing in minimal builds. Metrics are still counted, for the APIs that report them, but
+// not exported.
+func handleAdminEndpoints(*mux.Router, string, bool) {}
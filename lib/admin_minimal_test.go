@@ -0,0 +1,27 @@
+//go:build minimal
+
+package lib
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRouter_MinimalBuild(t *testing.T) {
+	_, err := NewRouter(context.Background(), WithRelayURLs("http://relay"), WithLogger(testLog), WithCapabilityCheckInterval(0), WithGraphQL())
+	require.NotNil(t, err)
+	_, err = NewRouter(context.Background(), WithRelayURLs("http://relay"), WithLogger(testLog), WithCapabilityCheckInterval(0), WithPprof())
+	require.NotNil(t, err)
+
+	router, err := NewRouter(context.Background(), WithRelayURLs("http://relay"), WithLogger(testLog), WithCapabilityCheckInterval(0))
+	require.Nil(t, err)
+	for _, path := range []string{"/metrics", "/debug/pprof/"} {
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, path, nil))
+		require.Equal(t, http.StatusNotFound, rr.Code, path)
+	}
+}
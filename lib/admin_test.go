@@ -0,0 +1,55 @@
+//go:build !minimal
+
+package lib
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRouter_AdminEndpoints(t *testing.T) {
+	get := func(handler http.Handler, path, token string) int {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		return rr.Code
+	}
+
+	t.Run("open by default, without pprof", func(t *testing.T) {
+		router, err := NewRouter(context.Background(), WithRelayURLs("http://relay"), WithLogger(testLog), WithCapabilityCheckInterval(0))
+		require.Nil(t, err)
+		require.Equal(t, http.StatusOK, get(router, "/metrics", ""))
+		require.Equal(t, http.StatusNotFound, get(router, "/debug/pprof/", ""))
+	})
+
+	t.Run("token", func(t *testing.T) {
+		router, err := NewRouter(context.Background(), WithRelayURLs("http://relay"), WithLogger(testLog), WithCapabilityCheckInterval(0),
+			WithAdminToken("secret"), WithPprof())
+		require.Nil(t, err)
+		for _, path := range []string{"/metrics", "/debug/pprof/", "/debug/pprof/heap", "/debug/pprof/cmdline"} {
+			require.Equal(t, http.StatusUnauthorized, get(router, path, ""), path)
+			require.Equal(t, http.StatusForbidden, get(router, path, "wrong"), path)
+			require.Equal(t, http.StatusOK, get(router, path, "secret"), path)
+		}
+		require.Equal(t, http.StatusOK, get(router, "/mev-boost/v1/deliveries", ""), "only admin endpoints need the token")
+	})
+
+	t.Run("separate listener", func(t *testing.T) {
+		router, err := NewRouter(context.Background(), WithRelayURLs("http://relay"), WithLogger(testLog), WithCapabilityCheckInterval(0),
+			WithPprof(), WithoutAdminEndpoints())
+		require.Nil(t, err)
+		require.Equal(t, http.StatusNotFound, get(router, "/metrics", ""))
+		require.Equal(t, http.StatusNotFound, get(router, "/debug/pprof/", ""))
+
+		admin := NewAdminRouter("", true)
+		require.Equal(t, http.StatusOK, get(admin, "/metrics", ""))
+		require.Equal(t, http.StatusOK, get(admin, "/debug/pprof/", ""))
+	})
+}
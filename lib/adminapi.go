@@ -0,0 +1,126 @@
+package lib
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/gorilla/mux"
+)
+
+const (
+	// pathAdminRelays lists the relays on GET, and turns the relay of the url query parameter on or off on PUT
+	pathAdminRelays = "/mev-boost/v1/admin/relays"
+	// pathAdminStore dumps a summary of the store contents on GET
+	pathAdminStore = "/mev-boost/v1/admin/store"
+	// pathAdminLogLevel reads the log level on GET and changes it on PUT
+	pathAdminLogLevel = "/mev-boost/v1/admin/log_level"
+)
+
+// LogLevelControl reads and changes the level of the logger of a router at runtime, for the admin API
+type LogLevelControl struct {
+	// Get returns the current level, like "info"
+	Get func() string
+	// Set changes the level, it fails for unknown levels
+	Set func(level string) error
+}
+
+// AdminRelay is the state of a relay served by the admin API
+type AdminRelay struct {
+	URL string `json:"url"`
+	// Enabled is false for relays turned off with the admin API
+	Enabled bool `json:"enabled"`
+	// Suspended relays underpaid the proposer, see WithUnderpaymentSuspension
+	Suspended bool `json:"suspended"`
+	// Available is false for relays out of the rotation after failed calls
+	Available bool    `json:"available"`
+	Shadow    bool    `json:"shadow"`
+	Weight    float64 `json:"weight"`
+	// Timeout bounds the calls to the relay, like "500ms", empty if the relay has no timeout of its own
+	Timeout string `json:"timeout,omitempty"`
+}
+
+type adminRelayUpdate struct {
+	Enabled *bool `json:"enabled"`
+}
+
+type adminLogLevel struct {
+	Level string `json:"level"`
+}
+
+// handleAdminAPI adds the admin API to router, behind the bearer token
+func (m *RelayService) handleAdminAPI(router *mux.Router, token string, logLevel *LogLevelControl) {
+	router.HandleFunc(pathAdminRelays, requireBearerToken(token, m.handleAdminRelays)).Methods(http.MethodGet)
+	router.HandleFunc(pathAdminRelays, requireBearerToken(token, m.handleAdminUpdateRelay)).Methods(http.MethodPut)
+	router.HandleFunc(pathAdminStore, requireBearerToken(token, m.handleAdminStore)).Methods(http.MethodGet)
+	router.HandleFunc(pathAdminPayouts, requireBearerToken(token, m.handleAdminPayouts)).Methods(http.MethodGet)
+	router.HandleFunc(pathAdminLogLevel, requireBearerToken(token, handleAdminLogLevel(logLevel, m.log))).Methods(http.MethodGet, http.MethodPut)
+}
+
+// adminRelay returns the state of the relay at url
+func (m *RelayService) adminRelay(url string) AdminRelay {
+	relay := AdminRelay{
+		URL:       url,
+		Enabled:   m.relays.enabled(url),
+		Suspended: m.blacklist.isSuspended(url),
+		Available: m.health.available(url),
+		Shadow:    m.relays.shadow(url),
+		Weight:    m.relays.weight(url),
+	}
+	if timeout := m.relays.timeout(url); timeout > 0 {
+		relay.Timeout = timeout.String()
+	}
+	return relay
+}
+
+func (m *RelayService) handleAdminRelays(w http.ResponseWriter, _ *http.Request) {
+	relays := []AdminRelay{}
+	for _, url := range m.relays.all() {
+		relays = append(relays, m.adminRelay(url))
+	}
+	respondJSON(w, http.StatusOK, relays)
+}
+
+func (m *RelayService) handleAdminUpdateRelay(w http.ResponseWriter, r *http.Request) {
+	relayURL := r.URL.Query().Get("url")
+	if !m.relays.contains(relayURL) {
+		respondJSON(w, http.StatusNotFound, keymanagerError{fmt.Sprintf("unknown relay %q", relayURL)})
+		return
+	}
+	var update adminRelayUpdate
+	if err := json.NewDecoder(r.Body).Decode(&update); err != nil || update.Enabled == nil {
+		respondJSON(w, http.StatusBadRequest, keymanagerError{`expected a body like {"enabled": false}`})
+		return
+	}
+	m.relays.setEnabled(relayURL, *update.Enabled)
+	m.log.WithFields(Fields{"url": relayURL, "enabled": *update.Enabled}).Warn("relay turned on or off by admin")
+	respondJSON(w, http.StatusOK, m.adminRelay(relayURL))
+}
+
+func (m *RelayService) handleAdminStore(w http.ResponseWriter, r *http.Request) {
+	respondJSON(w, http.StatusOK, m.store.Dump(r.Context()))
+}
+
+// handleAdminLogLevel serves the log level on GET and changes it on PUT, or answers 501 without a LogLevelControl
+func handleAdminLogLevel(control *LogLevelControl, log Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if control == nil {
+			respondJSON(w, http.StatusNotImplemented, keymanagerError{"the log level can't be changed at runtime, see WithLogLevelControl"})
+			return
+		}
+		if r.Method == http.MethodPut {
+			var level adminLogLevel
+			if err := json.NewDecoder(r.Body).Decode(&level); err != nil {
+				respondJSON(w, http.StatusBadRequest, keymanagerError{`expected a body like {"level": "debug"}`})
+				return
+			}
+			previous := control.Get()
+			if err := control.Set(level.Level); err != nil {
+				respondJSON(w, http.StatusBadRequest, keymanagerError{err.Error()})
+				return
+			}
+			log.WithFields(Fields{"level": level.Level, "previous": previous}).Warn("log level changed by admin")
+		}
+		respondJSON(w, http.StatusOK, adminLogLevel{control.Get()})
+	}
+}
@@ -0,0 +1,108 @@
+package lib
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/require"
+)
+
+func adminRequest(t *testing.T, router http.Handler, method, path string, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, bytes.NewReader([]byte(body)))
+	req.Header.Set("Authorization", "Bearer secret")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestRouter_AdminAPI(t *testing.T) {
+	var calls int32
+	relay := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		resp, err := formatResponse(ForkChoiceResponse{PayloadStatus: PayloadStatus{Status: ForkchoiceStatusValid}})
+		require.Nil(t, err)
+		w.Write(resp)
+	}))
+	defer relay.Close()
+
+	store := NewStore()
+	level := "info"
+	control := LogLevelControl{
+		Get: func() string { return level },
+		Set: func(l string) error {
+			if l != "debug" && l != "info" {
+				return errors.New("unknown level")
+			}
+			level = l
+			return nil
+		},
+	}
+	router, err := NewRouter(context.Background(), WithRelayURLs(relay.URL), WithStore(store), WithLogger(testLog), WithCapabilityCheckInterval(0), WithAdminAPI("secret"), WithLogLevelControl(control))
+	require.Nil(t, err)
+
+	unauthenticated := httptest.NewRecorder()
+	router.ServeHTTP(unauthenticated, httptest.NewRequest(http.MethodGet, pathAdminRelays, nil))
+	require.Equal(t, http.StatusUnauthorized, unauthenticated.Code)
+
+	w := adminRequest(t, router, http.MethodGet, pathAdminRelays, "")
+	require.Equal(t, http.StatusOK, w.Code)
+	var relays []AdminRelay
+	require.Nil(t, json.Unmarshal(w.Body.Bytes(), &relays))
+	require.Equal(t, []AdminRelay{{URL: relay.URL, Enabled: true, Available: true, Weight: 1}}, relays)
+
+	forkchoiceUpdated := func() {
+		body, err := formatRequestBody("engine_forkchoiceUpdatedV1", []interface{}{ForkchoiceStateV1{}, nil})
+		require.Nil(t, err)
+		req := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		router.ServeHTTP(httptest.NewRecorder(), req)
+	}
+	forkchoiceUpdated()
+	require.Equal(t, int32(1), atomic.LoadInt32(&calls))
+
+	path := pathAdminRelays + "?url=" + url.QueryEscape(relay.URL)
+	require.Equal(t, http.StatusNotFound, adminRequest(t, router, http.MethodPut, pathAdminRelays+"?url=http://unknown", `{"enabled": false}`).Code)
+	require.Equal(t, http.StatusBadRequest, adminRequest(t, router, http.MethodPut, path, `{}`).Code)
+	w = adminRequest(t, router, http.MethodPut, path, `{"enabled": false}`)
+	require.Equal(t, http.StatusOK, w.Code)
+	require.JSONEq(t, `{"url": "`+relay.URL+`", "enabled": false, "suspended": false, "available": true, "shadow": false, "weight": 1}`, w.Body.String())
+	forkchoiceUpdated()
+	require.Equal(t, int32(1), atomic.LoadInt32(&calls), "relays turned off get no calls")
+	adminRequest(t, router, http.MethodPut, path, `{"enabled": true}`)
+	forkchoiceUpdated()
+	require.Equal(t, int32(2), atomic.LoadInt32(&calls))
+
+	blockHash := common.HexToHash("0x01")
+	store.SetBid(context.Background(), blockHash, &Bid{RelayURL: relay.URL, Value: big.NewInt(5)})
+	w = adminRequest(t, router, http.MethodGet, pathAdminStore, "")
+	require.Equal(t, http.StatusOK, w.Code)
+	var dump StoreDump
+	require.Nil(t, json.Unmarshal(w.Body.Bytes(), &dump))
+	require.Equal(t, relay.URL, dump.Bids[blockHash.Hex()].RelayURL)
+
+	w = adminRequest(t, router, http.MethodPut, pathAdminLogLevel, `{"level": "debug"}`)
+	require.Equal(t, http.StatusOK, w.Code)
+	require.JSONEq(t, `{"level": "debug"}`, w.Body.String())
+	require.Equal(t, "debug", level)
+	require.Equal(t, http.StatusBadRequest, adminRequest(t, router, http.MethodPut, pathAdminLogLevel, `{"level": "loud"}`).Code)
+	require.JSONEq(t, `{"level": "debug"}`, adminRequest(t, router, http.MethodGet, pathAdminLogLevel, "").Body.String())
+}
+
+func TestRouter_AdminAPIWithoutToken(t *testing.T) {
+	router, err := NewRouter(context.Background(), WithRelayURLs("http://relay.example.com"), WithStore(NewStore()), WithLogger(testLog), WithCapabilityCheckInterval(0))
+	require.Nil(t, err)
+	require.Equal(t, http.StatusNotFound, adminRequest(t, router, http.MethodGet, pathAdminRelays, "").Code, "the admin API needs a token")
+
+	router, err = NewRouter(context.Background(), WithRelayURLs("http://relay.example.com"), WithStore(NewStore()), WithLogger(testLog), WithCapabilityCheckInterval(0), WithAdminAPI("secret"))
+	require.Nil(t, err)
+	require.Equal(t, http.StatusNotImplemented, adminRequest(t, router, http.MethodGet, pathAdminLogLevel, "").Code)
+}
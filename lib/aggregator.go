@@ -0,0 +1,87 @@
+package lib
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+// errMethodNotFound is returned for relay methods that are only served in aggregator mode, downstream mev-boost
+// instances treat its code like a relay that doesn't implement the method
+var errMethodNotFound = errors.New("method not found")
+
+// GetCapabilitiesV1 reports the relay methods mev-boost serves in aggregator mode, those supported by at least one of
+// its relays, the lowest bid floor of its relays if all of them advertise one, and the network it serves
+func (m *RelayService) GetCapabilitiesV1(_ *http.Request, _ *[]interface{}, result *RelayCapabilities) error {
+	if !m.aggregator {
+		return newMethodError(errMethodNotFound, "capabilities are only served in aggregator mode")
+	}
+
+	*result = RelayCapabilities{
+		SpecVersion:        builderSpecVersion,
+		Methods:            []string{},
+		GenesisForkVersion: hexutil.Encode(m.chain.GenesisForkVersion[:]),
+		Encoding:           PayloadEncodingEngine,
+	}
+	for _, method := range relayMethods {
+		for _, url := range m.relays.all() {
+			if m.capabilities.supports(url, method) {
+				result.Methods = append(result.Methods, method)
+				break
+			}
+		}
+	}
+	for i, url := range m.relays.all() {
+		floor := m.capabilities.minBid(url)
+		if floor == nil {
+			result.MinBid = nil
+			break
+		}
+		if i == 0 || floor.Cmp(result.MinBid) < 0 {
+			result.MinBid = floor
+		}
+	}
+	return nil
+}
+
+// handleProposerPayloadDelivered serves the delivery log in the format of the proposer_payload_delivered relay data
+// API, so downstream mev-boost instances can reconcile their deliveries against the aggregator. Deliveries can be
+// filtered by slot, the proposer pubkey isn't recorded and filtering by it returns no deliveries.
+func (m *RelayService) handleProposerPayloadDelivered(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	var slot *uint64
+	if value := query.Get("slot"); value != "" {
+		parsed, err := strconv.ParseUint(value, 10, 64)
+		if err != nil {
+			respondJSON(w, http.StatusBadRequest, keymanagerError{"invalid slot " + strconv.Quote(value)})
+			return
+		}
+		slot = &parsed
+	}
+
+	traces := []BidTrace{}
+	if query.Get("proposer_pubkey") != "" {
+		respondJSON(w, http.StatusOK, traces)
+		return
+	}
+	deliveries := m.deliveries.all()
+	for i := len(deliveries) - 1; i >= 0; i-- { // most recent first, like relays
+		delivery := deliveries[i]
+		if slot != nil && delivery.Slot != *slot {
+			continue
+		}
+		trace := BidTrace{
+			Slot:                 strconv.FormatUint(delivery.Slot, 10),
+			ParentHash:           delivery.ParentHash.Hex(),
+			BlockHash:            delivery.BlockHash.Hex(),
+			ProposerFeeRecipient: delivery.FeeRecipient.Hex(),
+		}
+		if delivery.Value != nil {
+			trace.Value = delivery.Value.String()
+		}
+		traces = append(traces, trace)
+	}
+	respondJSON(w, http.StatusOK, traces)
+}
@@ -0,0 +1,91 @@
+package lib
+
+import (
+	"context"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRelayService_Aggregator(t *testing.T) {
+	limitedRelay := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		resp, err := formatResponse(RelayCapabilities{
+			SpecVersion: builderSpecVersion,
+			Methods:     []string{methodForkchoiceUpdated, methodRelayGetHeader},
+		})
+		require.Nil(t, err)
+		w.Write(resp)
+	}))
+	defer limitedRelay.Close()
+
+	newAggregator := func(t *testing.T, opts ...Option) *httptest.Server {
+		opts = append(opts, WithRelayURLs(limitedRelay.URL), WithLogger(testLog), WithCapabilityCheckInterval(time.Hour))
+		router, err := NewRouter(context.Background(), opts...)
+		require.Nil(t, err)
+		return httptest.NewServer(router)
+	}
+	downstreamCapabilities := func(t *testing.T, aggregator *httptest.Server) *relayCapabilities {
+		downstream, err := newRelayService(WithRelayURLs(aggregator.URL), WithStore(NewStore()), WithLogger(testLog))
+		require.Nil(t, err)
+		downstream.checkRelayCapabilities(context.Background())
+		return downstream.capabilities
+	}
+
+	t.Run("capabilities of the upstream relays", func(t *testing.T) {
+		aggregator := newAggregator(t, WithAggregatorMode())
+		defer aggregator.Close()
+
+		// the aggregator checks its relays in the background after startup
+		require.Eventually(t, func() bool {
+			return !downstreamCapabilities(t, aggregator).supports(aggregator.URL, methodRelayProposeBlock)
+		}, time.Second, 10*time.Millisecond)
+		require.Equal(t, true, downstreamCapabilities(t, aggregator).supports(aggregator.URL, methodRelayGetHeader))
+	})
+
+	t.Run("capabilities without aggregator mode", func(t *testing.T) {
+		boost := newAggregator(t)
+		defer boost.Close()
+
+		capabilities := downstreamCapabilities(t, boost)
+		require.Equal(t, true, capabilities.supports(boost.URL, methodRelayProposeBlock))
+
+		resp, err := http.Get(boost.URL + pathProposerPayloadDelivered)
+		require.Nil(t, err)
+		resp.Body.Close()
+		require.Equal(t, http.StatusNotFound, resp.StatusCode)
+	})
+
+	t.Run("delivered payloads", func(t *testing.T) {
+		relay, err := newRelayService(WithRelayURLs(limitedRelay.URL), WithStore(NewStore()), WithLogger(testLog), WithAggregatorMode())
+		require.Nil(t, err)
+		relay.deliveries.add(&DeliveredPayload{Slot: 1, BlockHash: common.HexToHash("0x01"), Value: big.NewInt(10)})
+		relay.deliveries.add(&DeliveredPayload{Slot: 2, BlockHash: common.HexToHash("0x02"), FeeRecipient: common.HexToAddress("0x03")})
+
+		get := func(query string) []BidTrace {
+			rr := httptest.NewRecorder()
+			relay.handleProposerPayloadDelivered(rr, httptest.NewRequest(http.MethodGet, pathProposerPayloadDelivered+query, nil))
+			require.Equal(t, http.StatusOK, rr.Code)
+			var traces []BidTrace
+			require.Nil(t, json.Unmarshal(rr.Body.Bytes(), &traces))
+			return traces
+		}
+
+		traces := get("")
+		require.Len(t, traces, 2)
+		require.Equal(t, "2", traces[0].Slot)
+		require.Equal(t, common.HexToAddress("0x03").Hex(), traces[0].ProposerFeeRecipient)
+
+		traces = get("?slot=1")
+		require.Len(t, traces, 1)
+		require.Equal(t, common.HexToHash("0x01").Hex(), traces[0].BlockHash)
+		require.Equal(t, "10", traces[0].Value)
+
+		require.Len(t, get("?proposer_pubkey=0x01"), 0)
+	})
+}
@@ -0,0 +1,92 @@
+package lib
+
+import (
+	"math/big"
+	"sort"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+var (
+	// a bid is an outlier if its value is more than bidOutlierFactor times the median of all bids
+	bidOutlierFactor = big.NewInt(10)
+	// minimum number of bids before value outliers are considered
+	bidOutlierMinBids = 3
+)
+
+const (
+	bidAnomalyDuplicateBlockHash = "duplicate_block_hash"
+	bidAnomalyValueOutlier       = "value_outlier"
+)
+
+// bidObservation is a bid received from a relay for a single getPayloadHeader call
+type bidObservation struct {
+	RelayURL  string
+	BlockHash common.Hash
+	Value     *big.Int
+}
+
+// bidAnomaly describes something unusual about the bids of a getPayloadHeader call
+type bidAnomaly struct {
+	Kind      string
+	RelayURLs []string
+	BlockHash common.Hash
+	Value     *big.Int
+	Median    *big.Int
+}
+
+// detectBidAnomalies reports relays returning the same block hash, and bids whose value is far above the median
+func detectBidAnomalies(bids []bidObservation) []bidAnomaly {
+	var anomalies []bidAnomaly
+
+	relaysByBlockHash := make(map[common.Hash][]string)
+	var blockHashes []common.Hash
+	for _, bid := range bids {
+		if _, ok := relaysByBlockHash[bid.BlockHash]; !ok {
+			blockHashes = append(blockHashes, bid.BlockHash)
+		}
+		relaysByBlockHash[bid.BlockHash] = append(relaysByBlockHash[bid.BlockHash], bid.RelayURL)
+	}
+	for _, blockHash := range blockHashes {
+		if relays := relaysByBlockHash[blockHash]; len(relays) > 1 {
+			anomalies = append(anomalies, bidAnomaly{
+				Kind:      bidAnomalyDuplicateBlockHash,
+				RelayURLs: relays,
+				BlockHash: blockHash,
+			})
+		}
+	}
+
+	if len(bids) < bidOutlierMinBids {
+		return anomalies
+	}
+
+	values := make([]*big.Int, 0, len(bids))
+	for _, bid := range bids {
+		if bid.Value != nil {
+			values = append(values, bid.Value)
+		}
+	}
+	if len(values) < bidOutlierMinBids {
+		return anomalies
+	}
+	sort.Slice(values, func(i, j int) bool { return values[i].Cmp(values[j]) < 0 })
+	median := values[len(values)/2]
+	if median.Sign() <= 0 {
+		return anomalies
+	}
+
+	threshold := new(big.Int).Mul(median, bidOutlierFactor)
+	for _, bid := range bids {
+		if bid.Value != nil && bid.Value.Cmp(threshold) > 0 {
+			anomalies = append(anomalies, bidAnomaly{
+				Kind:      bidAnomalyValueOutlier,
+				RelayURLs: []string{bid.RelayURL},
+				BlockHash: bid.BlockHash,
+				Value:     bid.Value,
+				Median:    median,
+			})
+		}
+	}
+	return anomalies
+}
@@ -0,0 +1,56 @@
+package lib
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_detectBidAnomalies(t *testing.T) {
+	hash1 := common.HexToHash("0x01")
+	hash2 := common.HexToHash("0x02")
+	hash3 := common.HexToHash("0x03")
+
+	tests := []struct {
+		name      string
+		bids      []bidObservation
+		wantKinds []string
+	}{
+		{
+			"no bids",
+			nil,
+			nil,
+		},
+		{
+			"distinct bids",
+			[]bidObservation{{"a", hash1, big.NewInt(10)}, {"b", hash2, big.NewInt(12)}, {"c", hash3, big.NewInt(11)}},
+			nil,
+		},
+		{
+			"same block hash from two relays",
+			[]bidObservation{{"a", hash1, big.NewInt(10)}, {"b", hash1, big.NewInt(10)}},
+			[]string{bidAnomalyDuplicateBlockHash},
+		},
+		{
+			"value far above median",
+			[]bidObservation{{"a", hash1, big.NewInt(10)}, {"b", hash2, big.NewInt(12)}, {"c", hash3, big.NewInt(1000)}},
+			[]string{bidAnomalyValueOutlier},
+		},
+		{
+			"outliers need enough bids",
+			[]bidObservation{{"a", hash1, big.NewInt(10)}, {"b", hash2, big.NewInt(1000)}},
+			nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var kinds []string
+			for _, anomaly := range detectBidAnomalies(tt.bids) {
+				kinds = append(kinds, anomaly.Kind)
+			}
+			require.Equal(t, tt.wantKinds, kinds)
+		})
+	}
+}
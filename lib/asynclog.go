@@ -0,0 +1,80 @@
+package lib
+
+import (
+	"io"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var logLinesDroppedTotal = metricsFactory.NewCounter(prometheus.CounterOpts{
+	Name: "mevboost_log_lines_dropped_total",
+	Help: "Log lines dropped because the async log buffer was full",
+})
+
+// AsyncWriter writes to an underlying writer in the background, so slow disks or consoles don't add latency to requests.
+// Writes that don't fit into the buffer are dropped and counted instead of blocking.
+type AsyncWriter struct {
+	out   io.Writer
+	lines chan []byte
+	done  chan struct{}
+
+	mu      sync.Mutex
+	closed  bool
+	dropped uint64
+}
+
+// NewAsyncWriter creates an AsyncWriter buffering up to size writes to out
+func NewAsyncWriter(out io.Writer, size int) *AsyncWriter {
+	w := &AsyncWriter{out: out, lines: make(chan []byte, size), done: make(chan struct{})}
+	go w.run()
+	return w
+}
+
+func (w *AsyncWriter) run() {
+	defer close(w.done)
+	for line := range w.lines {
+		w.out.Write(line) // nowhere to report a failed log write
+	}
+}
+
+// Write queues p, it never blocks and never fails, p is dropped if the buffer is full or the writer is closed
+func (w *AsyncWriter) Write(p []byte) (int, error) {
+	// loggers may reuse p after Write returns
+	line := make([]byte, len(p))
+	copy(line, p)
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.closed {
+		w.dropped++
+		logLinesDroppedTotal.Inc()
+		return len(p), nil
+	}
+	select {
+	case w.lines <- line:
+	default:
+		w.dropped++
+		logLinesDroppedTotal.Inc()
+	}
+	return len(p), nil
+}
+
+// Dropped returns the number of writes dropped so far
+func (w *AsyncWriter) Dropped() uint64 {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.dropped
+}
+
+// Close writes the buffered lines and stops the background writer, later writes are dropped
+func (w *AsyncWriter) Close() error {
+	w.mu.Lock()
+	if !w.closed {
+		w.closed = true
+		close(w.lines)
+	}
+	w.mu.Unlock()
+	<-w.done
+	return nil
+}
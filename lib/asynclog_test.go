@@ -0,0 +1,45 @@
+package lib
+
+import (
+	"bytes"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// blockingWriter blocks writes until it's released
+type blockingWriter struct {
+	release chan struct{}
+	mu      sync.Mutex
+	buf     bytes.Buffer
+}
+
+func (w *blockingWriter) Write(p []byte) (int, error) {
+	<-w.release
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.buf.Write(p)
+}
+
+func TestAsyncWriter(t *testing.T) {
+	out := &blockingWriter{release: make(chan struct{})}
+	w := NewAsyncWriter(out, 2)
+
+	line := []byte("first\n")
+	w.Write(line)
+	copy(line, "reused")
+	// the background writer may or may not have taken the first line off the buffer yet
+	for i := 0; i < 3; i++ {
+		w.Write([]byte("line\n"))
+	}
+	require.True(t, w.Dropped() >= 1 && w.Dropped() <= 2, "dropped %d", w.Dropped())
+
+	close(out.release)
+	require.Nil(t, w.Close())
+	require.Equal(t, "first\n", out.buf.String()[:len("first\n")])
+	require.Equal(t, 4-int(w.Dropped()), bytes.Count(out.buf.Bytes(), []byte("\n")))
+
+	w.Write([]byte("late\n"))
+	require.NotContains(t, out.buf.String(), "late")
+}
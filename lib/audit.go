@@ -0,0 +1,187 @@
+package lib
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// AuditKind is the kind of an AuditRecord
+type AuditKind string
+
+// Kinds of audit records
+const (
+	// AuditDelivery is a payload revealed to the consensus client, Data is the DeliveredPayload
+	AuditDelivery AuditKind = "delivery"
+	// AuditUnderpayment is a revealed payload that paid the proposer less than the bid, Data is the PaymentRecord
+	AuditUnderpayment AuditKind = "underpayment"
+	// AuditVerification is a delivery checked against the finalized chain, Data are the status and the amount paid
+	AuditVerification AuditKind = "verification"
+	// AuditRelaySuspended is a relay suspended for underpaying its bids, Data are the details of the suspension
+	AuditRelaySuspended AuditKind = "relaySuspended"
+)
+
+var (
+	// auditFlushInterval is how often buffered audit records are written to the sinks
+	auditFlushInterval = 10 * time.Second
+	// maxPendingAuditRecords bounds the records buffered per sink while it fails, the oldest are dropped beyond it
+	maxPendingAuditRecords = 100000
+)
+
+var auditRecordsTotal = metricsFactory.NewCounterVec(prometheus.CounterOpts{
+	Name: "mevboost_audit_records_total",
+	Help: "Audit records by sink and result: written, or dropped because the sink failed for too long",
+}, []string{"sink", "result"})
+
+var auditWriteErrorsTotal = metricsFactory.NewCounterVec(prometheus.CounterOpts{
+	Name: "mevboost_audit_write_errors_total",
+	Help: "Failed writes of audit records, by sink. The records are written again with the next batch.",
+}, []string{"sink"})
+
+// AuditRecord is an entry of the audit log
+type AuditRecord struct {
+	Kind      AuditKind    `json:"kind"`
+	Time      time.Time    `json:"time"`
+	Slot      uint64       `json:"slot,string,omitempty"`
+	RelayURL  string       `json:"relayUrl,omitempty"`
+	BlockHash *common.Hash `json:"blockHash,omitempty"`
+	Data      interface{}  `json:"data"`
+}
+
+// AuditSink persists audit records for operators with retention requirements. Write is called from a single goroutine,
+// with the records in the order they were recorded. Records of a failed write are passed again with the next batch, so
+// a sink may see a record twice if a write failed after persisting part of it.
+type AuditSink interface {
+	// Name identifies the sink in logs and metrics
+	Name() string
+	Write(ctx context.Context, records []AuditRecord) error
+}
+
+// auditLog buffers audit records and writes them to the sinks every auditFlushInterval, so slow sinks don't hold up
+// proposals. A nil log drops records.
+type auditLog struct {
+	queues  []*auditQueue
+	flushMu sync.Mutex // sinks are written by one flush at a time
+	log     Logger
+}
+
+// auditQueue holds the records not written to a sink yet
+type auditQueue struct {
+	sink    AuditSink
+	mu      sync.Mutex
+	pending []AuditRecord
+}
+
+func newAuditLog(sinks []AuditSink, log Logger) *auditLog {
+	a := &auditLog{log: log}
+	for _, sink := range sinks {
+		a.queues = append(a.queues, &auditQueue{sink: sink})
+	}
+	return a
+}
+
+func (a *auditLog) record(record AuditRecord) {
+	if a == nil {
+		return
+	}
+	if record.Time.IsZero() {
+		record.Time = now()
+	}
+	for _, queue := range a.queues {
+		queue.mu.Lock()
+		queue.pending = append(queue.pending, record)
+		if dropped := len(queue.pending) - maxPendingAuditRecords; dropped > 0 {
+			queue.pending = queue.pending[dropped:]
+			auditRecordsTotal.WithLabelValues(queue.sink.Name(), "dropped").Add(float64(dropped))
+		}
+		queue.mu.Unlock()
+	}
+}
+
+// onEvent records relay suspensions and delivery verifications
+func (a *auditLog) onEvent(_ context.Context, event *Event) {
+	switch {
+	case event.Kind == EventRelayFault && event.Fault == RelayFaultSuspended:
+		a.record(AuditRecord{Kind: AuditRelaySuspended, Time: event.Time, RelayURL: event.RelayURL, Data: event.Fields})
+	case event.Kind == EventDeliveryVerified:
+		a.record(AuditRecord{Kind: AuditVerification, Time: event.Time, Slot: event.Slot, RelayURL: event.RelayURL, BlockHash: event.BlockHash, Data: event.Fields})
+	}
+}
+
+// flush writes the pending records to each sink. Records of failed writes are kept for the next flush.
+func (a *auditLog) flush(ctx context.Context) {
+	a.flushMu.Lock()
+	defer a.flushMu.Unlock()
+
+	for _, queue := range a.queues {
+		queue.mu.Lock()
+		records := queue.pending
+		queue.pending = nil
+		queue.mu.Unlock()
+		if len(records) == 0 {
+			continue
+		}
+
+		name := queue.sink.Name()
+		if err := queue.sink.Write(ctx, records); err != nil {
+			auditWriteErrorsTotal.WithLabelValues(name).Inc()
+			a.log.WithError(err).WithFields(Fields{"sink": name, "records": len(records)}).Warn("could not write audit records, retrying with the next batch")
+			queue.mu.Lock()
+			queue.pending = append(records, queue.pending...)
+			if dropped := len(queue.pending) - maxPendingAuditRecords; dropped > 0 {
+				queue.pending = queue.pending[dropped:]
+				auditRecordsTotal.WithLabelValues(name, "dropped").Add(float64(dropped))
+			}
+			queue.mu.Unlock()
+			continue
+		}
+		auditRecordsTotal.WithLabelValues(name, "written").Add(float64(len(records)))
+	}
+}
+
+// startAuditLog writes the audit records to the sinks every auditFlushInterval until ctx is done, and the remaining
+// records once more then
+func (m *RelayService) startAuditLog(ctx context.Context) {
+	runLoop(ctx, m.log, "audit_log", auditFlushInterval, false, m.audit.flush)
+	go func() {
+		<-ctx.Done()
+		flushCtx, cancel := context.WithTimeout(context.Background(), auditFlushInterval)
+		defer cancel()
+		m.audit.flush(flushCtx)
+	}()
+}
+
+// FileAuditSink appends audit records to a file as JSON lines, and syncs the file after each batch
+type FileAuditSink struct {
+	file *os.File
+}
+
+// NewFileAuditSink opens path for appending audit records, creating it if needed
+func NewFileAuditSink(path string) (*FileAuditSink, error) {
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	return &FileAuditSink{file: file}, nil
+}
+
+// Name implements AuditSink
+func (s *FileAuditSink) Name() string {
+	return "file"
+}
+
+// Write implements AuditSink
+func (s *FileAuditSink) Write(_ context.Context, records []AuditRecord) error {
+	encoder := json.NewEncoder(s.file)
+	for _, record := range records {
+		if err := encoder.Encode(record); err != nil {
+			return err
+		}
+	}
+	return s.file.Sync()
+}
@@ -0,0 +1,115 @@
+package lib
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/require"
+)
+
+// memoryAuditSink keeps the records written to it, and fails while err is set
+type memoryAuditSink struct {
+	mu      sync.Mutex
+	err     error
+	records []AuditRecord
+}
+
+func (s *memoryAuditSink) Name() string { return "memory" }
+
+func (s *memoryAuditSink) Write(_ context.Context, records []AuditRecord) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.err != nil {
+		return s.err
+	}
+	s.records = append(s.records, records...)
+	return nil
+}
+
+func (s *memoryAuditSink) kinds() []AuditKind {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	kinds := []AuditKind{}
+	for _, record := range s.records {
+		kinds = append(kinds, record.Kind)
+	}
+	return kinds
+}
+
+func TestAuditLog(t *testing.T) {
+	healthy, failing := new(memoryAuditSink), &memoryAuditSink{err: errors.New("unavailable")}
+	audit := newAuditLog([]AuditSink{healthy, failing}, testLog)
+
+	audit.record(AuditRecord{Kind: AuditDelivery, Slot: 1})
+	audit.onEvent(context.Background(), &Event{Kind: EventRelayFault, Fault: RelayFaultRequest})
+	audit.onEvent(context.Background(), &Event{Kind: EventRelayFault, Fault: RelayFaultSuspended, RelayURL: "http://relay"})
+	audit.flush(context.Background())
+	require.Equal(t, []AuditKind{AuditDelivery, AuditRelaySuspended}, healthy.kinds())
+	require.False(t, healthy.records[0].Time.IsZero())
+	require.Empty(t, failing.kinds())
+
+	// records of a failing sink are written once it recovers, and the oldest are dropped while it fails for too long
+	defer func(max int) { maxPendingAuditRecords = max }(maxPendingAuditRecords)
+	maxPendingAuditRecords = 2
+	audit.record(AuditRecord{Kind: AuditUnderpayment})
+	audit.flush(context.Background())
+	failing.err = nil
+	audit.flush(context.Background())
+	require.Equal(t, []AuditKind{AuditDelivery, AuditRelaySuspended, AuditUnderpayment}, healthy.kinds())
+	require.Equal(t, []AuditKind{AuditRelaySuspended, AuditUnderpayment}, failing.kinds())
+
+	var disabled *auditLog
+	disabled.record(AuditRecord{Kind: AuditDelivery})
+}
+
+func TestRelayService_AuditDeliveries(t *testing.T) {
+	sink := new(memoryAuditSink)
+	service, err := newRelayService(WithRelayURLs("http://relay"), WithLogger(testLog), WithAuditSink(sink))
+	require.Nil(t, err)
+
+	blockHash := common.HexToHash("0x01")
+	service.store.SetBid(context.Background(), blockHash, &Bid{RelayURL: "http://relay", Value: big.NewInt(10)})
+	service.recordDelivery(context.Background(), &BlindedBeaconBlock{Slot: 5}, &ExecutionPayloadWithTxRootV1{BlockHash: blockHash}, "")
+	service.events.publish(context.Background(), &Event{Kind: EventDeliveryVerified, Slot: 5, BlockHash: &blockHash, Fields: map[string]interface{}{"status": DeliveryIncluded}})
+	service.audit.flush(context.Background())
+
+	require.Equal(t, []AuditKind{AuditDelivery, AuditVerification}, sink.kinds())
+	delivery := sink.records[0]
+	require.Equal(t, uint64(5), delivery.Slot)
+	require.Equal(t, "http://relay", delivery.RelayURL)
+	require.Equal(t, big.NewInt(10), delivery.Data.(DeliveredPayload).Value)
+}
+
+func TestFileAuditSink(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	sink, err := NewFileAuditSink(path)
+	require.Nil(t, err)
+	blockHash := common.HexToHash("0x01")
+	require.Nil(t, sink.Write(context.Background(), []AuditRecord{
+		{Kind: AuditDelivery, Slot: 5, BlockHash: &blockHash, Data: DeliveredPayload{Slot: 5}},
+		{Kind: AuditRelaySuspended, RelayURL: "http://relay", Data: map[string]interface{}{"paid": 0}},
+	}))
+
+	file, err := os.Open(path)
+	require.Nil(t, err)
+	defer file.Close()
+	var lines []map[string]interface{}
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := make(map[string]interface{})
+		require.Nil(t, json.Unmarshal(scanner.Bytes(), &line))
+		lines = append(lines, line)
+	}
+	require.Len(t, lines, 2)
+	require.Equal(t, "delivery", lines[0]["kind"])
+	require.Equal(t, "5", lines[0]["slot"])
+	require.Equal(t, "http://relay", lines[1]["relayUrl"])
+}
@@ -0,0 +1,94 @@
+package lib
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"regexp"
+
+	_ "github.com/lib/pq" // registers the postgres driver
+)
+
+var postgresTableName = regexp.MustCompile(`^[a-z_][a-z0-9_]*$`)
+
+// PostgresAuditSink inserts audit records into a Postgres table, which is created if it doesn't exist. The data of each
+// record is stored as jsonb.
+type PostgresAuditSink struct {
+	db    *sql.DB
+	table string
+}
+
+// NewPostgresAuditSink connects to the database of dsn, e.g. postgres://user@host/db?sslmode=verify-full, and creates
+// table if needed. The password can be given in the PGPASSWORD environment variable instead of dsn.
+func NewPostgresAuditSink(ctx context.Context, dsn, table string) (*PostgresAuditSink, error) {
+	db, err := sql.Open("postgres", dsn)
+	if err != nil {
+		return nil, err
+	}
+	sink, err := newPostgresAuditSink(ctx, db, table)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return sink, nil
+}
+
+func newPostgresAuditSink(ctx context.Context, db *sql.DB, table string) (*PostgresAuditSink, error) {
+	if !postgresTableName.MatchString(table) {
+		return nil, fmt.Errorf("invalid audit table name %q", table)
+	}
+	_, err := db.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS `+table+` (
+		id BIGSERIAL PRIMARY KEY,
+		kind TEXT NOT NULL,
+		time TIMESTAMPTZ NOT NULL,
+		slot BIGINT,
+		relay_url TEXT,
+		block_hash TEXT,
+		data JSONB
+	)`)
+	if err != nil {
+		return nil, fmt.Errorf("could not create audit table: %w", err)
+	}
+	return &PostgresAuditSink{db: db, table: table}, nil
+}
+
+// Name implements AuditSink
+func (s *PostgresAuditSink) Name() string {
+	return "postgres"
+}
+
+// Write implements AuditSink, the records of a batch are inserted in one transaction
+func (s *PostgresAuditSink) Write(ctx context.Context, records []AuditRecord) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback() // no-op after the commit
+	stmt, err := tx.PrepareContext(ctx, `INSERT INTO `+s.table+` (kind, time, slot, relay_url, block_hash, data) VALUES ($1, $2, $3, $4, $5, $6)`)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	for _, record := range records {
+		data, err := json.Marshal(record.Data)
+		if err != nil {
+			return err
+		}
+		var slot, relayURL, blockHash interface{}
+		if record.Slot != 0 {
+			slot = int64(record.Slot)
+		}
+		if record.RelayURL != "" {
+			relayURL = record.RelayURL
+		}
+		if record.BlockHash != nil {
+			blockHash = record.BlockHash.Hex()
+		}
+		if _, err := stmt.ExecContext(ctx, string(record.Kind), record.Time, slot, relayURL, blockHash, string(data)); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
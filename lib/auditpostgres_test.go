@@ -0,0 +1,95 @@
+package lib
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/require"
+)
+
+// recordingDriver is a database/sql driver that records the statements executed and their args
+type recordingDriver struct {
+	mu         sync.Mutex
+	statements []string
+	args       [][]driver.Value
+	commits    int
+}
+
+// Connect implements driver.Connector, so the driver is used with sql.OpenDB without registering it
+func (d *recordingDriver) Connect(context.Context) (driver.Conn, error) {
+	return &recordingConn{d}, nil
+}
+
+func (d *recordingDriver) Driver() driver.Driver {
+	return d
+}
+
+func (d *recordingDriver) Open(string) (driver.Conn, error) {
+	return &recordingConn{d}, nil
+}
+
+type recordingConn struct{ d *recordingDriver }
+
+func (c *recordingConn) Prepare(query string) (driver.Stmt, error) {
+	return &recordingStmt{c.d, query}, nil
+}
+
+func (c *recordingConn) Close() error              { return nil }
+func (c *recordingConn) Begin() (driver.Tx, error) { return c, nil }
+func (c *recordingConn) Rollback() error           { return nil }
+
+func (c *recordingConn) Commit() error {
+	c.d.mu.Lock()
+	defer c.d.mu.Unlock()
+	c.d.commits++
+	return nil
+}
+
+type recordingStmt struct {
+	d     *recordingDriver
+	query string
+}
+
+func (s *recordingStmt) Close() error  { return nil }
+func (s *recordingStmt) NumInput() int { return -1 }
+
+func (s *recordingStmt) Exec(args []driver.Value) (driver.Result, error) {
+	s.d.mu.Lock()
+	defer s.d.mu.Unlock()
+	s.d.statements = append(s.d.statements, s.query)
+	s.d.args = append(s.d.args, args)
+	return driver.RowsAffected(1), nil
+}
+
+func (s *recordingStmt) Query([]driver.Value) (driver.Rows, error) { return nil, driver.ErrSkip }
+
+func TestPostgresAuditSink(t *testing.T) {
+	recorder := new(recordingDriver)
+	db := sql.OpenDB(recorder)
+	defer db.Close()
+
+	_, err := newPostgresAuditSink(context.Background(), db, "audit; DROP TABLE x")
+	require.NotNil(t, err)
+	sink, err := newPostgresAuditSink(context.Background(), db, "mevboost_audit")
+	require.Nil(t, err)
+	require.True(t, strings.HasPrefix(recorder.statements[0], "CREATE TABLE IF NOT EXISTS mevboost_audit ("))
+
+	blockHash := common.HexToHash("0x01")
+	at := time.Unix(1000, 0)
+	require.Nil(t, sink.Write(context.Background(), []AuditRecord{
+		{Kind: AuditDelivery, Time: at, Slot: 5, RelayURL: "http://relay", BlockHash: &blockHash, Data: DeliveredPayload{Slot: 5}},
+		{Kind: AuditRelaySuspended, Time: at, Data: map[string]interface{}{"paid": 0}},
+	}))
+	require.Equal(t, 1, recorder.commits)
+	require.Len(t, recorder.args, 3)
+	require.True(t, strings.HasPrefix(recorder.statements[1], "INSERT INTO mevboost_audit "))
+	require.Equal(t, []driver.Value{"delivery", at, int64(5), "http://relay", blockHash.Hex()}, recorder.args[1][:5])
+	require.Contains(t, recorder.args[1][5], `"slot":"5"`)
+	require.Equal(t, []driver.Value{"relaySuspended", at, nil, nil, nil, `{"paid":0}`}, recorder.args[2])
+}
@@ -0,0 +1,150 @@
+package lib
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+// S3AuditSinkConfig configures an S3AuditSink
+type S3AuditSinkConfig struct {
+	// URL is the bucket and optional key prefix, in path style: https://s3.eu-central-1.amazonaws.com/bucket/prefix
+	URL       string
+	Region    string
+	AccessKey string
+	SecretKey string
+	// RetentionDays locks each object in compliance mode for this many days, if not 0. The bucket must have object
+	// lock enabled.
+	RetentionDays int
+}
+
+// S3AuditSink writes each batch of audit records as an object of JSON lines to S3-compatible object storage. Objects
+// are named <prefix>/<year>/<month>/<day>/<time of the first record>-<count>.jsonl.
+type S3AuditSink struct {
+	cfg    S3AuditSinkConfig
+	bucket *url.URL
+	client *http.Client
+}
+
+// NewS3AuditSink checks the config of an S3AuditSink
+func NewS3AuditSink(cfg S3AuditSinkConfig) (*S3AuditSink, error) {
+	bucket, err := url.Parse(strings.TrimSuffix(cfg.URL, "/"))
+	if err != nil {
+		return nil, err
+	}
+	if bucket.Scheme != "http" && bucket.Scheme != "https" || strings.Trim(bucket.Path, "/") == "" {
+		return nil, fmt.Errorf("invalid S3 url %q, expected http(s)://host/bucket[/prefix]", cfg.URL)
+	}
+	if cfg.Region == "" || cfg.AccessKey == "" || cfg.SecretKey == "" {
+		return nil, errors.New("S3 region and credentials are required")
+	}
+	return &S3AuditSink{cfg: cfg, bucket: bucket, client: &http.Client{Timeout: 30 * time.Second}}, nil
+}
+
+// Name implements AuditSink
+func (s *S3AuditSink) Name() string {
+	return "s3"
+}
+
+// Write implements AuditSink
+func (s *S3AuditSink) Write(ctx context.Context, records []AuditRecord) error {
+	var body bytes.Buffer
+	encoder := json.NewEncoder(&body)
+	for _, record := range records {
+		if err := encoder.Encode(record); err != nil {
+			return err
+		}
+	}
+
+	first := records[0].Time.UTC()
+	objectURL := *s.bucket
+	objectURL.Path += fmt.Sprintf("/%s/%s-%d.jsonl", first.Format("2006/01/02"), first.Format("150405.000000000"), len(records))
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, objectURL.String(), bytes.NewReader(body.Bytes()))
+	if err != nil {
+		return err
+	}
+	md5sum := md5.Sum(body.Bytes()) // required by object lock
+	req.Header.Set("Content-MD5", base64.StdEncoding.EncodeToString(md5sum[:]))
+	req.Header.Set("Content-Type", "application/x-ndjson")
+	if s.cfg.RetentionDays > 0 {
+		req.Header.Set("X-Amz-Object-Lock-Mode", "COMPLIANCE")
+		req.Header.Set("X-Amz-Object-Lock-Retain-Until-Date", now().UTC().AddDate(0, 0, s.cfg.RetentionDays).Format(time.RFC3339))
+	}
+	signS3Request(req, body.Bytes(), s.cfg.Region, s.cfg.AccessKey, s.cfg.SecretKey, now())
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("HTTP error response from S3: %d %s", resp.StatusCode, strings.TrimSpace(string(message)))
+	}
+	return nil
+}
+
+// signS3Request adds an AWS Signature Version 4 authorization to a request to S3, signing the host, Content-MD5,
+// Content-Type, Range and x-amz-* headers
+func signS3Request(req *http.Request, body []byte, region, accessKey, secretKey string, at time.Time) {
+	payloadHash := sha256.Sum256(body)
+	date := at.UTC().Format("20060102T150405Z")
+	req.Header.Set("X-Amz-Date", date)
+	req.Header.Set("X-Amz-Content-Sha256", hex.EncodeToString(payloadHash[:]))
+
+	headers := map[string]string{"host": req.URL.Host}
+	for name, values := range req.Header {
+		name = strings.ToLower(name)
+		if strings.HasPrefix(name, "x-amz-") || name == "content-md5" || name == "content-type" || name == "range" {
+			headers[name] = strings.TrimSpace(strings.Join(values, ","))
+		}
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		req.URL.Query().Encode(),
+		canonicalHeaders.String(),
+		signedHeaders,
+		hex.EncodeToString(payloadHash[:]),
+	}, "\n")
+	requestHash := sha256.Sum256([]byte(canonicalRequest))
+	scope := date[:8] + "/" + region + "/s3/aws4_request"
+	stringToSign := "AWS4-HMAC-SHA256\n" + date + "\n" + scope + "\n" + hex.EncodeToString(requestHash[:])
+
+	key := hmacSHA256([]byte("AWS4"+secretKey), date[:8])
+	key = hmacSHA256(key, region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s", accessKey, scope, signedHeaders, signature))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
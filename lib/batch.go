@@ -0,0 +1,159 @@
+package lib
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+const (
+	// maxBatchSize is the most calls a JSON-RPC batch request may hold
+	maxBatchSize = 100
+
+	// defaultMaxRequestBodySize is the largest JSON-RPC request body read, see WithMaxRequestBodySize
+	defaultMaxRequestBodySize = 5 << 20
+
+	// JSON-RPC error codes of batches whose calls can't be dispatched
+	rpcErrParse          = -32700
+	rpcErrInvalidRequest = -32600
+	rpcErrInternal       = -32603
+)
+
+// isBatch reports whether body is a JSON-RPC batch request, an array of calls
+func isBatch(body []byte) bool {
+	return startsWith(body, '[')
+}
+
+// startsWith reports whether the first non-whitespace byte of a JSON document is c
+func startsWith(body []byte, c byte) bool {
+	trimmed := bytes.TrimLeft(body, " \t\r\n")
+	return len(trimmed) > 0 && trimmed[0] == c
+}
+
+// batchErrorResponse is the response of a call of a batch that didn't get an answer of the rpc server
+func batchErrorResponse(id json.RawMessage, code int, message string) json.RawMessage {
+	if len(id) == 0 {
+		id = json.RawMessage("null")
+	}
+	resp, _ := json.Marshal(map[string]interface{}{
+		"jsonrpc": "2.0",
+		"id":      id,
+		"error":   rpcError{Code: code, Message: message},
+	})
+	return resp
+}
+
+// batchHandler serves JSON-RPC batch requests: each call of the array goes through next as a request of its own, all
+// at once, and the responses are returned as an array in the order of the calls. Other requests are passed on, with the
+// plain text errors of the rpc server turned into JSON-RPC errors. Bodies over maxBody bytes are rejected with 413.
+func batchHandler(maxBody int64, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(io.LimitReader(r.Body, maxBody+1))
+		if err != nil { // e.g. over the JSON limits
+			respondJSON(w, http.StatusBadRequest, batchErrorResponse(nil, rpcErrParse, err.Error()))
+			return
+		}
+		if int64(len(body)) > maxBody {
+			respondJSON(w, http.StatusRequestEntityTooLarge, batchErrorResponse(nil, rpcErrInvalidRequest, fmt.Sprintf("request body exceeds %d bytes", maxBody)))
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		if !isBatch(body) {
+			serveRPCErrors(w, r, body, next)
+			return
+		}
+
+		var calls []json.RawMessage
+		if err := json.Unmarshal(body, &calls); err != nil {
+			respondJSON(w, http.StatusOK, batchErrorResponse(nil, rpcErrParse, err.Error()))
+			return
+		}
+		if len(calls) == 0 || len(calls) > maxBatchSize {
+			respondJSON(w, http.StatusOK, batchErrorResponse(nil, rpcErrInvalidRequest, fmt.Sprintf("batch must hold 1 to %d calls", maxBatchSize)))
+			return
+		}
+
+		responses := make([]json.RawMessage, len(calls))
+		var wg sync.WaitGroup
+		for i, call := range calls {
+			i, call := i, call
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				if !startsWith(call, '{') {
+					responses[i] = batchErrorResponse(nil, rpcErrInvalidRequest, "call must be an object")
+					return
+				}
+				responses[i] = serveRPC(next, r, call)
+			}()
+		}
+		wg.Wait()
+		respondJSON(w, http.StatusOK, responses)
+	})
+}
+
+// serveRPC serves the JSON-RPC request body with next, in a copy of r, and returns its response: a call of a batch
+// request, or a message of a WebSocket connection
+func serveRPC(next http.Handler, r *http.Request, body []byte) json.RawMessage {
+	sub := r.Clone(r.Context())
+	sub.Body = io.NopCloser(bytes.NewReader(body))
+	sub.ContentLength = int64(len(body))
+	recorder := &responseBuffer{header: make(http.Header), code: http.StatusOK}
+	next.ServeHTTP(recorder, sub)
+
+	resp := bytes.TrimSpace(recorder.body.Bytes())
+	if (recorder.code == http.StatusOK || recorder.code == http.StatusTooManyRequests) && json.Valid(resp) {
+		return resp
+	}
+	// the rpc server and the handlers before it answer some errors in plain text
+	return plainErrorResponse(body, recorder.code, resp)
+}
+
+// serveRPCErrors serves the JSON-RPC request body with next, answering the errors the rpc server returns as plain text
+// with status 400 with a JSON-RPC error of the same status instead, so clients get the error code. Other responses are
+// passed on as is.
+func serveRPCErrors(w http.ResponseWriter, r *http.Request, body []byte, next http.Handler) {
+	recorder := &responseBuffer{header: w.Header(), code: http.StatusOK}
+	next.ServeHTTP(recorder, r)
+
+	resp := bytes.TrimSpace(recorder.body.Bytes())
+	if recorder.code != http.StatusBadRequest || json.Valid(resp) {
+		w.WriteHeader(recorder.code)
+		w.Write(recorder.body.Bytes())
+		return
+	}
+	w.Header().Del("X-Content-Type-Options") // set by http.Error along with the plain text content type
+	respondJSON(w, recorder.code, plainErrorResponse(body, recorder.code, resp))
+}
+
+// plainErrorResponse is the JSON-RPC error of a plain text error response to the request body
+func plainErrorResponse(body []byte, status int, resp []byte) json.RawMessage {
+	message := strings.TrimSpace(string(resp))
+	var req struct {
+		ID json.RawMessage `json:"id"`
+	}
+	if err := json.Unmarshal(body, &req); err != nil { // the id is null if the request isn't an object
+		req.ID = nil
+	}
+	return batchErrorResponse(req.ID, plainErrorCode(body, status, message), message)
+}
+
+// plainErrorCode classifies a plain text error of the rpc server or the handlers before it
+func plainErrorCode(body []byte, status int, message string) int {
+	switch {
+	case !json.Valid(body):
+		return rpcErrParse
+	case strings.HasPrefix(message, "rpc: can't find"), strings.HasPrefix(message, "rpc: service/method request ill-formed"):
+		return rpcErrMethodNotFound
+	case strings.HasPrefix(message, "rpc: method request ill-formed"):
+		return rpcErrInvalidRequest
+	case status == http.StatusBadRequest: // the params didn't decode into the arguments of the method
+		return rpcErrInvalidParams
+	default:
+		return rpcErrInternal
+	}
+}
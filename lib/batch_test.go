@@ -0,0 +1,67 @@
+package lib
+
+import (
+	"context"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRouter_Batch(t *testing.T) {
+	relay := newHeaderRelay(t, ExecutionPayloadWithTxRootV1{BlockHash: common.HexToHash("0x01"), BaseFeePerGas: big.NewInt(7), FeeRecipientDiff: big.NewInt(1)})
+	defer relay.Close()
+
+	store := NewStore()
+	store.SetForkchoiceResponse(context.Background(), "0x01", relay.URL, "0x01")
+	router, err := NewRouter(context.Background(), WithRelayURLs(relay.URL), WithStore(store), WithLogger(testLog), WithCapabilityCheckInterval(0))
+	require.Nil(t, err)
+
+	call := func(body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		return rr
+	}
+	type response struct {
+		ID     json.RawMessage        `json:"id"`
+		Result map[string]interface{} `json:"result"`
+		Error  *rpcError              `json:"error"`
+	}
+
+	rr := call(` [
+		{"jsonrpc": "2.0", "id": "1", "method": "builder_getPayloadHeaderV1", "params": ["0x01"]},
+		{"jsonrpc": "2.0", "id": 2, "method": "engine_getPayloadHeaderV1", "params": ["0x01"]},
+		{"jsonrpc": "2.0", "id": "3", "method": "builder_unknownV1", "params": []},
+		5
+	]`)
+	require.Equal(t, http.StatusOK, rr.Code)
+	var responses []response
+	require.Nil(t, json.Unmarshal(rr.Body.Bytes(), &responses), rr.Body.String())
+	require.Len(t, responses, 4)
+
+	require.Equal(t, `"1"`, string(responses[0].ID))
+	require.Nil(t, responses[0].Error)
+	require.Equal(t, common.HexToHash("0x01").Hex(), responses[0].Result["blockHash"])
+	require.Equal(t, `2`, string(responses[1].ID), "calls of a batch go through the same handlers as single calls")
+	require.Nil(t, responses[1].Error)
+	require.Equal(t, `"3"`, string(responses[2].ID))
+	require.Equal(t, rpcErrMethodNotFound, responses[2].Error.Code)
+	require.Contains(t, responses[2].Error.Message, "builder_unknownV1")
+	require.Equal(t, `null`, string(responses[3].ID))
+	require.Equal(t, rpcErrInvalidRequest, responses[3].Error.Code)
+
+	var single response
+	require.Nil(t, json.Unmarshal(call(`[]`).Body.Bytes(), &single))
+	require.Equal(t, rpcErrInvalidRequest, single.Error.Code)
+	require.Nil(t, json.Unmarshal(call(`[{"id": "1"`).Body.Bytes(), &single))
+	require.Equal(t, rpcErrParse, single.Error.Code)
+	require.Nil(t, json.Unmarshal(call("["+strings.Repeat(`{"id": "1"},`, maxBatchSize)+`{"id": "1"}]`).Body.Bytes(), &single))
+	require.Equal(t, rpcErrInvalidRequest, single.Error.Code)
+}
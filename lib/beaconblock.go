@@ -0,0 +1,524 @@
+package lib
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+// Limits of the lists in a beacon block body, from the mainnet preset of the consensus specs
+const (
+	maxProposerSlashings      = 16
+	maxAttesterSlashings      = 2
+	maxAttestations           = 128
+	maxDeposits               = 16
+	maxVoluntaryExits         = 16
+	maxBLSToExecutionChanges  = 16
+	maxValidatorsPerCommittee = 2048
+	syncCommitteeSize         = 512
+	depositProofLength        = 33
+)
+
+// BLSPubkey is a compressed BLS public key, hex encoded in JSON
+type BLSPubkey [48]byte
+
+// MarshalText implements encoding.TextMarshaler
+func (p BLSPubkey) MarshalText() ([]byte, error) {
+	return hexutil.Bytes(p[:]).MarshalText()
+}
+
+// UnmarshalJSON decodes a hex string of exactly 48 bytes
+func (p *BLSPubkey) UnmarshalJSON(input []byte) error {
+	return hexutil.UnmarshalFixedJSON(reflect.TypeOf(BLSPubkey{}), input, p[:])
+}
+
+func (p BLSPubkey) String() string {
+	return hexutil.Encode(p[:])
+}
+
+// BLSSignature is a compressed BLS signature, hex encoded in JSON
+type BLSSignature [96]byte
+
+// MarshalText implements encoding.TextMarshaler
+func (s BLSSignature) MarshalText() ([]byte, error) {
+	return hexutil.Bytes(s[:]).MarshalText()
+}
+
+// UnmarshalJSON decodes a hex string of exactly 96 bytes
+func (s *BLSSignature) UnmarshalJSON(input []byte) error {
+	return hexutil.UnmarshalFixedJSON(reflect.TypeOf(BLSSignature{}), input, s[:])
+}
+
+func (s BLSSignature) String() string {
+	return hexutil.Encode(s[:])
+}
+
+// SignedBlindedBeaconBlock is a blinded block signed by its proposer, see
+// https://github.com/ethereum/builder-specs/blob/main/specs/builder.md#signedblindedbeaconblock
+type SignedBlindedBeaconBlock struct {
+	Message   *BlindedBeaconBlock `json:"message"`
+	Signature BLSSignature        `json:"signature" ssz-size:"96"`
+}
+
+// BlindedBeaconBlock is a Bellatrix beacon block with the execution payload replaced by its header, see
+// https://github.com/ethereum/builder-specs/blob/main/specs/builder.md#blindedbeaconblock
+type BlindedBeaconBlock struct {
+	Slot          uint64                  `json:"slot,string"`
+	ProposerIndex uint64                  `json:"proposer_index,string"`
+	ParentRoot    common.Hash             `json:"parent_root" ssz-size:"32"`
+	StateRoot     common.Hash             `json:"state_root" ssz-size:"32"`
+	Body          *BlindedBeaconBlockBody `json:"body"`
+}
+
+// BlindedBeaconBlockBody is the body of a Bellatrix blinded beacon block in the beacon API encoding, see
+// https://github.com/ethereum/builder-specs/blob/main/specs/builder.md#blindedbeaconblockbody
+type BlindedBeaconBlockBody struct {
+	RandaoReveal           BLSSignature              `json:"randao_reveal" ssz-size:"96"`
+	Eth1Data               Eth1Data                  `json:"eth1_data"`
+	Graffiti               common.Hash               `json:"graffiti" ssz-size:"32"`
+	ProposerSlashings      []ProposerSlashing        `json:"proposer_slashings" ssz-max:"16"`
+	AttesterSlashings      []AttesterSlashing        `json:"attester_slashings" ssz-max:"2"`
+	Attestations           []Attestation             `json:"attestations" ssz-max:"128"`
+	Deposits               []Deposit                 `json:"deposits" ssz-max:"16"`
+	VoluntaryExits         []SignedVoluntaryExit     `json:"voluntary_exits" ssz-max:"16"`
+	SyncAggregate          SyncAggregate             `json:"sync_aggregate"`
+	ExecutionPayloadHeader *ExecutionPayloadHeaderV1 `json:"execution_payload_header"`
+	// BLSToExecutionChanges are the withdrawal credential changes of capella bodies, whose header has a withdrawals root
+	BLSToExecutionChanges []SignedBLSToExecutionChange `json:"bls_to_execution_changes,omitempty" ssz-max:"16"`
+}
+
+// MarshalJSON encodes the body like the beacon API, with empty lists instead of null
+func (b BlindedBeaconBlockBody) MarshalJSON() ([]byte, error) {
+	type body BlindedBeaconBlockBody // without the MarshalJSON method
+	enc := body(b)
+	if enc.ProposerSlashings == nil {
+		enc.ProposerSlashings = []ProposerSlashing{}
+	}
+	if enc.AttesterSlashings == nil {
+		enc.AttesterSlashings = []AttesterSlashing{}
+	}
+	if enc.Attestations == nil {
+		enc.Attestations = []Attestation{}
+	}
+	if enc.Deposits == nil {
+		enc.Deposits = []Deposit{}
+	}
+	if enc.VoluntaryExits == nil {
+		enc.VoluntaryExits = []SignedVoluntaryExit{}
+	}
+	return json.Marshal(&enc)
+}
+
+// UnmarshalJSON decodes a body in the beacon API encoding. The execution payload header is also accepted as
+// executionPayloadHeader, as sent by early consensus client integrations, see ExecutionPayloadHeaderV1.UnmarshalJSON for
+// the encodings of its fields.
+func (b *BlindedBeaconBlockBody) UnmarshalJSON(input []byte) error {
+	type body BlindedBeaconBlockBody // without the UnmarshalJSON method
+	var dec struct {
+		body
+		ExecutionPayloadHeaderCamel *ExecutionPayloadHeaderV1 `json:"executionPayloadHeader"`
+	}
+	if err := json.Unmarshal(input, &dec); err != nil {
+		return err
+	}
+	if dec.ExecutionPayloadHeader != nil && dec.ExecutionPayloadHeaderCamel != nil {
+		return errors.New("body has both execution_payload_header and executionPayloadHeader")
+	}
+	*b = BlindedBeaconBlockBody(dec.body)
+	if dec.ExecutionPayloadHeaderCamel != nil {
+		b.ExecutionPayloadHeader = dec.ExecutionPayloadHeaderCamel
+	}
+	return nil
+}
+
+// Eth1Data is the deposit contract state a proposer votes for
+type Eth1Data struct {
+	DepositRoot  common.Hash `json:"deposit_root" ssz-size:"32"`
+	DepositCount uint64      `json:"deposit_count,string"`
+	BlockHash    common.Hash `json:"block_hash" ssz-size:"32"`
+}
+
+// BeaconBlockHeader is a beacon block with its body replaced by the body root
+type BeaconBlockHeader struct {
+	Slot          uint64      `json:"slot,string"`
+	ProposerIndex uint64      `json:"proposer_index,string"`
+	ParentRoot    common.Hash `json:"parent_root" ssz-size:"32"`
+	StateRoot     common.Hash `json:"state_root" ssz-size:"32"`
+	BodyRoot      common.Hash `json:"body_root" ssz-size:"32"`
+}
+
+// SignedBeaconBlockHeader is a BeaconBlockHeader signed by its proposer
+type SignedBeaconBlockHeader struct {
+	Message   BeaconBlockHeader `json:"message"`
+	Signature BLSSignature      `json:"signature" ssz-size:"96"`
+}
+
+// ProposerSlashing proves that a proposer signed two blocks for the same slot
+type ProposerSlashing struct {
+	SignedHeader1 SignedBeaconBlockHeader `json:"signed_header_1"`
+	SignedHeader2 SignedBeaconBlockHeader `json:"signed_header_2"`
+}
+
+// Checkpoint is an epoch boundary block
+type Checkpoint struct {
+	Epoch uint64      `json:"epoch,string"`
+	Root  common.Hash `json:"root" ssz-size:"32"`
+}
+
+// AttestationData is the vote of an attestation
+type AttestationData struct {
+	Slot            uint64      `json:"slot,string"`
+	Index           uint64      `json:"index,string"`
+	BeaconBlockRoot common.Hash `json:"beacon_block_root" ssz-size:"32"`
+	Source          Checkpoint  `json:"source"`
+	Target          Checkpoint  `json:"target"`
+}
+
+// QuotedUint64s is a list of integers encoded as decimal strings, like the beacon API does
+type QuotedUint64s []uint64
+
+// MarshalJSON encodes the integers as strings
+func (q QuotedUint64s) MarshalJSON() ([]byte, error) {
+	strs := make([]string, len(q))
+	for i, v := range q {
+		strs[i] = strconv.FormatUint(v, 10)
+	}
+	return json.Marshal(strs)
+}
+
+// UnmarshalJSON decodes a list of integer strings
+func (q *QuotedUint64s) UnmarshalJSON(input []byte) error {
+	var strs []string
+	if err := json.Unmarshal(input, &strs); err != nil {
+		return err
+	}
+	*q = make(QuotedUint64s, len(strs))
+	for i, s := range strs {
+		v, err := strconv.ParseUint(s, 10, 64)
+		if err != nil {
+			return err
+		}
+		(*q)[i] = v
+	}
+	return nil
+}
+
+// IndexedAttestation is an attestation with the indices of its attesters
+type IndexedAttestation struct {
+	AttestingIndices QuotedUint64s   `json:"attesting_indices" ssz-max:"2048"`
+	Data             AttestationData `json:"data"`
+	Signature        BLSSignature    `json:"signature" ssz-size:"96"`
+}
+
+// AttesterSlashing proves that attesters signed two conflicting attestations
+type AttesterSlashing struct {
+	Attestation1 IndexedAttestation `json:"attestation_1"`
+	Attestation2 IndexedAttestation `json:"attestation_2"`
+}
+
+// Attestation is an aggregated vote of a committee, AggregationBits is a Bitlist[2048] including its length bit
+type Attestation struct {
+	AggregationBits hexutil.Bytes   `json:"aggregation_bits" ssz:"bitlist" ssz-max:"2048"`
+	Data            AttestationData `json:"data"`
+	Signature       BLSSignature    `json:"signature" ssz-size:"96"`
+}
+
+// DepositData is a deposit to the deposit contract
+type DepositData struct {
+	Pubkey                BLSPubkey    `json:"pubkey" ssz-size:"48"`
+	WithdrawalCredentials common.Hash  `json:"withdrawal_credentials" ssz-size:"32"`
+	Amount                uint64       `json:"amount,string"`
+	Signature             BLSSignature `json:"signature" ssz-size:"96"`
+}
+
+// Deposit is a DepositData with its merkle proof against the deposit root
+type Deposit struct {
+	Proof []common.Hash `json:"proof" ssz-size:"33,32"`
+	Data  DepositData   `json:"data"`
+}
+
+// VoluntaryExit is the request of a validator to exit
+type VoluntaryExit struct {
+	Epoch          uint64 `json:"epoch,string"`
+	ValidatorIndex uint64 `json:"validator_index,string"`
+}
+
+// SignedVoluntaryExit is a VoluntaryExit signed by the validator
+type SignedVoluntaryExit struct {
+	Message   VoluntaryExit `json:"message"`
+	Signature BLSSignature  `json:"signature" ssz-size:"96"`
+}
+
+// BLSToExecutionChange is the request of a validator to change its BLS withdrawal credentials to an execution address
+type BLSToExecutionChange struct {
+	ValidatorIndex     uint64         `json:"validator_index,string"`
+	FromBLSPubkey      BLSPubkey      `json:"from_bls_pubkey" ssz-size:"48"`
+	ToExecutionAddress common.Address `json:"to_execution_address" ssz-size:"20"`
+}
+
+// SignedBLSToExecutionChange is a BLSToExecutionChange signed with the withdrawal key of the validator
+type SignedBLSToExecutionChange struct {
+	Message   BLSToExecutionChange `json:"message"`
+	Signature BLSSignature         `json:"signature" ssz-size:"96"`
+}
+
+// SyncAggregate is the aggregated signature of the sync committee, SyncCommitteeBits is a Bitvector[512]
+type SyncAggregate struct {
+	SyncCommitteeBits      hexutil.Bytes `json:"sync_committee_bits" ssz-size:"64"`
+	SyncCommitteeSignature BLSSignature  `json:"sync_committee_signature" ssz-size:"96"`
+}
+
+// HashTreeRoot returns the SSZ hash tree root of the block, which is the root of the unblinded block signed by the proposer
+func (b *BlindedBeaconBlock) HashTreeRoot() ([32]byte, error) {
+	if b.Body == nil {
+		return [32]byte{}, errors.New("missing body")
+	}
+	bodyRoot, err := b.Body.HashTreeRoot()
+	if err != nil {
+		return [32]byte{}, err
+	}
+
+	return (&BeaconBlockHeader{
+		Slot:          b.Slot,
+		ProposerIndex: b.ProposerIndex,
+		ParentRoot:    b.ParentRoot,
+		StateRoot:     b.StateRoot,
+		BodyRoot:      bodyRoot,
+	}).HashTreeRoot(), nil
+}
+
+// HashTreeRoot returns the SSZ hash tree root of the body, of the capella body if its header has a withdrawals root
+func (b *BlindedBeaconBlockBody) HashTreeRoot() ([32]byte, error) {
+	if b.ExecutionPayloadHeader == nil {
+		return [32]byte{}, errors.New("missing execution_payload_header")
+	}
+	if len(b.ProposerSlashings) > maxProposerSlashings || len(b.AttesterSlashings) > maxAttesterSlashings ||
+		len(b.Attestations) > maxAttestations || len(b.Deposits) > maxDeposits || len(b.VoluntaryExits) > maxVoluntaryExits ||
+		len(b.BLSToExecutionChanges) > maxBLSToExecutionChanges {
+		return [32]byte{}, errors.New("too many operations in body")
+	}
+
+	var err error
+	chunks := make([][32]byte, 10, 11)
+	chunks[0] = b.RandaoReveal.HashTreeRoot()
+	chunks[1] = b.Eth1Data.HashTreeRoot()
+	chunks[2] = b.Graffiti
+
+	roots := make([][32]byte, len(b.ProposerSlashings))
+	for i := range b.ProposerSlashings {
+		roots[i] = b.ProposerSlashings[i].HashTreeRoot()
+	}
+	chunks[3] = mixInLength(merkleizeWithLimit(roots, maxProposerSlashings), len(roots))
+
+	roots = make([][32]byte, len(b.AttesterSlashings))
+	for i := range b.AttesterSlashings {
+		if roots[i], err = b.AttesterSlashings[i].HashTreeRoot(); err != nil {
+			return [32]byte{}, err
+		}
+	}
+	chunks[4] = mixInLength(merkleizeWithLimit(roots, maxAttesterSlashings), len(roots))
+
+	roots = make([][32]byte, len(b.Attestations))
+	for i := range b.Attestations {
+		if roots[i], err = b.Attestations[i].HashTreeRoot(); err != nil {
+			return [32]byte{}, err
+		}
+	}
+	chunks[5] = mixInLength(merkleizeWithLimit(roots, maxAttestations), len(roots))
+
+	roots = make([][32]byte, len(b.Deposits))
+	for i := range b.Deposits {
+		if roots[i], err = b.Deposits[i].HashTreeRoot(); err != nil {
+			return [32]byte{}, err
+		}
+	}
+	chunks[6] = mixInLength(merkleizeWithLimit(roots, maxDeposits), len(roots))
+
+	roots = make([][32]byte, len(b.VoluntaryExits))
+	for i := range b.VoluntaryExits {
+		roots[i] = b.VoluntaryExits[i].HashTreeRoot()
+	}
+	chunks[7] = mixInLength(merkleizeWithLimit(roots, maxVoluntaryExits), len(roots))
+
+	if chunks[8], err = b.SyncAggregate.HashTreeRoot(); err != nil {
+		return [32]byte{}, err
+	}
+	if chunks[9], err = b.ExecutionPayloadHeader.HashTreeRoot(); err != nil {
+		return [32]byte{}, err
+	}
+	if b.ExecutionPayloadHeader.WithdrawalsRoot != nil {
+		roots = make([][32]byte, len(b.BLSToExecutionChanges))
+		for i := range b.BLSToExecutionChanges {
+			roots[i] = b.BLSToExecutionChanges[i].HashTreeRoot()
+		}
+		chunks = append(chunks, mixInLength(merkleizeWithLimit(roots, maxBLSToExecutionChanges), len(roots)))
+	}
+	return merkleize(chunks), nil
+}
+
+// HashTreeRoot returns the SSZ hash tree root of the pubkey, a Bytes48
+func (p BLSPubkey) HashTreeRoot() [32]byte {
+	return merkleize(packBytes(p[:]))
+}
+
+// HashTreeRoot returns the SSZ hash tree root of the signature, a Bytes96
+func (s BLSSignature) HashTreeRoot() [32]byte {
+	return merkleize(packBytes(s[:]))
+}
+
+// HashTreeRoot returns the SSZ hash tree root of the eth1 data
+func (d *Eth1Data) HashTreeRoot() [32]byte {
+	return merkleize([][32]byte{d.DepositRoot, uint64Chunk(d.DepositCount), d.BlockHash})
+}
+
+// HashTreeRoot returns the SSZ hash tree root of the header, which is also the root of the block it belongs to
+func (h *BeaconBlockHeader) HashTreeRoot() [32]byte {
+	return merkleize([][32]byte{uint64Chunk(h.Slot), uint64Chunk(h.ProposerIndex), h.ParentRoot, h.StateRoot, h.BodyRoot})
+}
+
+// HashTreeRoot returns the SSZ hash tree root of the signed header
+func (h *SignedBeaconBlockHeader) HashTreeRoot() [32]byte {
+	return merkleize([][32]byte{h.Message.HashTreeRoot(), h.Signature.HashTreeRoot()})
+}
+
+// HashTreeRoot returns the SSZ hash tree root of the slashing
+func (s *ProposerSlashing) HashTreeRoot() [32]byte {
+	return merkleize([][32]byte{s.SignedHeader1.HashTreeRoot(), s.SignedHeader2.HashTreeRoot()})
+}
+
+// HashTreeRoot returns the SSZ hash tree root of the checkpoint
+func (c *Checkpoint) HashTreeRoot() [32]byte {
+	return merkleize([][32]byte{uint64Chunk(c.Epoch), c.Root})
+}
+
+// HashTreeRoot returns the SSZ hash tree root of the attestation data
+func (d *AttestationData) HashTreeRoot() [32]byte {
+	return merkleize([][32]byte{uint64Chunk(d.Slot), uint64Chunk(d.Index), d.BeaconBlockRoot, d.Source.HashTreeRoot(), d.Target.HashTreeRoot()})
+}
+
+// HashTreeRoot returns the SSZ hash tree root of the indexed attestation
+func (a *IndexedAttestation) HashTreeRoot() ([32]byte, error) {
+	if len(a.AttestingIndices) > maxValidatorsPerCommittee {
+		return [32]byte{}, fmt.Errorf("too many attesting indices %d", len(a.AttestingIndices))
+	}
+
+	// uint64s are packed four to a chunk
+	indices := make([][32]byte, (len(a.AttestingIndices)+3)/4)
+	for i, index := range a.AttestingIndices {
+		binary.LittleEndian.PutUint64(indices[i/4][8*(i%4):], index)
+	}
+	indicesRoot := mixInLength(merkleizeWithLimit(indices, maxValidatorsPerCommittee/4), len(a.AttestingIndices))
+	return merkleize([][32]byte{indicesRoot, a.Data.HashTreeRoot(), a.Signature.HashTreeRoot()}), nil
+}
+
+// HashTreeRoot returns the SSZ hash tree root of the slashing
+func (s *AttesterSlashing) HashTreeRoot() ([32]byte, error) {
+	attestation1, err := s.Attestation1.HashTreeRoot()
+	if err != nil {
+		return [32]byte{}, err
+	}
+	attestation2, err := s.Attestation2.HashTreeRoot()
+	if err != nil {
+		return [32]byte{}, err
+	}
+	return merkleize([][32]byte{attestation1, attestation2}), nil
+}
+
+// HashTreeRoot returns the SSZ hash tree root of the attestation
+func (a *Attestation) HashTreeRoot() ([32]byte, error) {
+	bits, err := bitlistRoot(a.AggregationBits, maxValidatorsPerCommittee)
+	if err != nil {
+		return [32]byte{}, fmt.Errorf("invalid aggregation_bits: %w", err)
+	}
+	return merkleize([][32]byte{bits, a.Data.HashTreeRoot(), a.Signature.HashTreeRoot()}), nil
+}
+
+// HashTreeRoot returns the SSZ hash tree root of the deposit data
+func (d *DepositData) HashTreeRoot() [32]byte {
+	return merkleize([][32]byte{d.Pubkey.HashTreeRoot(), d.WithdrawalCredentials, uint64Chunk(d.Amount), d.Signature.HashTreeRoot()})
+}
+
+// HashTreeRoot returns the SSZ hash tree root of the deposit
+func (d *Deposit) HashTreeRoot() ([32]byte, error) {
+	if len(d.Proof) != depositProofLength {
+		return [32]byte{}, fmt.Errorf("invalid deposit proof length %d", len(d.Proof))
+	}
+	proof := make([][32]byte, len(d.Proof))
+	for i := range d.Proof {
+		proof[i] = d.Proof[i]
+	}
+	return merkleize([][32]byte{merkleize(proof), d.Data.HashTreeRoot()}), nil
+}
+
+// HashTreeRoot returns the SSZ hash tree root of the exit
+func (e *VoluntaryExit) HashTreeRoot() [32]byte {
+	return merkleize([][32]byte{uint64Chunk(e.Epoch), uint64Chunk(e.ValidatorIndex)})
+}
+
+// HashTreeRoot returns the SSZ hash tree root of the signed exit
+func (e *SignedVoluntaryExit) HashTreeRoot() [32]byte {
+	return merkleize([][32]byte{e.Message.HashTreeRoot(), e.Signature.HashTreeRoot()})
+}
+
+// HashTreeRoot returns the SSZ hash tree root of the credential change
+func (c *BLSToExecutionChange) HashTreeRoot() [32]byte {
+	var address [32]byte
+	copy(address[:], c.ToExecutionAddress[:])
+	return merkleize([][32]byte{uint64Chunk(c.ValidatorIndex), c.FromBLSPubkey.HashTreeRoot(), address})
+}
+
+// HashTreeRoot returns the SSZ hash tree root of the signed credential change
+func (c *SignedBLSToExecutionChange) HashTreeRoot() [32]byte {
+	return merkleize([][32]byte{c.Message.HashTreeRoot(), c.Signature.HashTreeRoot()})
+}
+
+// HashTreeRoot returns the SSZ hash tree root of the sync aggregate
+func (a *SyncAggregate) HashTreeRoot() ([32]byte, error) {
+	if len(a.SyncCommitteeBits) != syncCommitteeSize/8 {
+		return [32]byte{}, fmt.Errorf("invalid sync_committee_bits length %d", len(a.SyncCommitteeBits))
+	}
+	return merkleize([][32]byte{merkleize(packBytes(a.SyncCommitteeBits)), a.SyncCommitteeSignature.HashTreeRoot()}), nil
+}
+
+func uint64Chunk(v uint64) (chunk [32]byte) {
+	binary.LittleEndian.PutUint64(chunk[:], v)
+	return chunk
+}
+
+// packBytes splits bytes into chunks, the last one padded with zeros
+func packBytes(b []byte) [][32]byte {
+	chunks := make([][32]byte, (len(b)+31)/32)
+	for i := range chunks {
+		copy(chunks[i][:], b[32*i:])
+	}
+	return chunks
+}
+
+// bitlistRoot returns the SSZ hash tree root of a bitlist, whose last byte marks its length with the highest set bit
+func bitlistRoot(bits []byte, limit int) ([32]byte, error) {
+	if len(bits) == 0 || bits[len(bits)-1] == 0 {
+		return [32]byte{}, errors.New("missing length bit")
+	}
+	last := bits[len(bits)-1]
+	msb := 7
+	for last>>msb == 0 {
+		msb--
+	}
+	length := 8*(len(bits)-1) + msb
+	if length > limit {
+		return [32]byte{}, fmt.Errorf("bitlist length %d exceeds %d", length, limit)
+	}
+
+	data := append([]byte{}, bits...)
+	data[len(data)-1] ^= 1 << msb
+	if msb == 0 {
+		data = data[:len(data)-1]
+	}
+	return mixInLength(merkleizeWithLimit(packBytes(data), (limit+255)/256), length), nil
+}
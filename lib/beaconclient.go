@@ -0,0 +1,241 @@
+package lib
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// errBeaconNotFound means the beacon node doesn't know the requested resource, e.g. the block of an empty slot
+var errBeaconNotFound = errors.New("not found on beacon node")
+
+// BeaconClient is a minimal client of the beacon node REST API
+type BeaconClient struct {
+	url string
+}
+
+// NewBeaconClient creates a client for the beacon node at url
+func NewBeaconClient(url string) *BeaconClient {
+	return &BeaconClient{url: strings.TrimRight(url, "/")}
+}
+
+// BeaconGenesis is the response of /eth/v1/beacon/genesis
+type BeaconGenesis struct {
+	GenesisTime           string `json:"genesis_time"`
+	GenesisValidatorsRoot string `json:"genesis_validators_root"`
+	GenesisForkVersion    string `json:"genesis_fork_version"`
+}
+
+// BeaconFork is an entry of /eth/v1/config/fork_schedule
+type BeaconFork struct {
+	PreviousVersion string `json:"previous_version"`
+	CurrentVersion  string `json:"current_version"`
+	Epoch           string `json:"epoch"`
+}
+
+// BeaconCheckpoint is a checkpoint of the beacon chain
+type BeaconCheckpoint struct {
+	Epoch string `json:"epoch"`
+	Root  string `json:"root"`
+}
+
+// BeaconFinalityCheckpoints is the response of /eth/v1/beacon/states/{state_id}/finality_checkpoints
+type BeaconFinalityCheckpoints struct {
+	PreviousJustified BeaconCheckpoint `json:"previous_justified"`
+	CurrentJustified  BeaconCheckpoint `json:"current_justified"`
+	Finalized         BeaconCheckpoint `json:"finalized"`
+}
+
+// BeaconValidator is the response of /eth/v1/beacon/states/{state_id}/validators/{validator_id}
+type BeaconValidator struct {
+	Index     string `json:"index"`
+	Status    string `json:"status"`
+	Validator struct {
+		Pubkey string `json:"pubkey"`
+	} `json:"validator"`
+}
+
+// BeaconProposerDuty is an entry of /eth/v1/validator/duties/proposer/{epoch}
+type BeaconProposerDuty struct {
+	Pubkey         string `json:"pubkey"`
+	ValidatorIndex string `json:"validator_index"`
+	Slot           string `json:"slot"`
+}
+
+// BeaconHeader is the response of /eth/v1/beacon/headers/{block_id}
+type BeaconHeader struct {
+	Root   string `json:"root"`
+	Header struct {
+		Message struct {
+			Slot          string `json:"slot"`
+			ProposerIndex string `json:"proposer_index"`
+		} `json:"message"`
+	} `json:"header"`
+}
+
+// get decodes the data field of a beacon API response into dst
+func (c *BeaconClient) get(ctx context.Context, path string, dst interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.url+path, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Add("Accept", "application/json")
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode == http.StatusNotFound {
+		return fmt.Errorf("%w: %s", errBeaconNotFound, path)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("beacon node returned status %d for %s: %s", resp.StatusCode, path, string(body))
+	}
+
+	container := struct {
+		Data interface{} `json:"data"`
+	}{dst}
+	return json.Unmarshal(body, &container)
+}
+
+// Genesis returns the genesis of the beacon chain
+func (c *BeaconClient) Genesis(ctx context.Context) (*BeaconGenesis, error) {
+	genesis := new(BeaconGenesis)
+	if err := c.get(ctx, "/eth/v1/beacon/genesis", genesis); err != nil {
+		return nil, err
+	}
+	return genesis, nil
+}
+
+// ForkSchedule returns all forks of the beacon chain, ordered by epoch
+func (c *BeaconClient) ForkSchedule(ctx context.Context) ([]BeaconFork, error) {
+	var forks []BeaconFork
+	if err := c.get(ctx, "/eth/v1/config/fork_schedule", &forks); err != nil {
+		return nil, err
+	}
+	return forks, nil
+}
+
+// Spec returns the configuration of the beacon node
+func (c *BeaconClient) Spec(ctx context.Context) (map[string]string, error) {
+	spec := make(map[string]string)
+	if err := c.get(ctx, "/eth/v1/config/spec", &spec); err != nil {
+		return nil, err
+	}
+	return spec, nil
+}
+
+// FinalityCheckpoints returns the justified and finalized checkpoints of the head state
+func (c *BeaconClient) FinalityCheckpoints(ctx context.Context) (*BeaconFinalityCheckpoints, error) {
+	checkpoints := new(BeaconFinalityCheckpoints)
+	if err := c.get(ctx, "/eth/v1/beacon/states/head/finality_checkpoints", checkpoints); err != nil {
+		return nil, err
+	}
+	return checkpoints, nil
+}
+
+// Validator returns the validator with the given index or pubkey in the head state
+func (c *BeaconClient) Validator(ctx context.Context, id string) (*BeaconValidator, error) {
+	validator := new(BeaconValidator)
+	if err := c.get(ctx, "/eth/v1/beacon/states/head/validators/"+id, validator); err != nil {
+		return nil, err
+	}
+	return validator, nil
+}
+
+// HeadHeader returns the header of the head block
+func (c *BeaconClient) HeadHeader(ctx context.Context) (*BeaconHeader, error) {
+	header := new(BeaconHeader)
+	if err := c.get(ctx, "/eth/v1/beacon/headers/head", header); err != nil {
+		return nil, err
+	}
+	return header, nil
+}
+
+// ExecutionBlockHash returns the hash of the execution payload of a block, the zero hash for blocks before the merge
+func (c *BeaconClient) ExecutionBlockHash(ctx context.Context, blockID string) (common.Hash, error) {
+	var block struct {
+		Message struct {
+			Body struct {
+				ExecutionPayload struct {
+					BlockHash common.Hash `json:"block_hash"`
+				} `json:"execution_payload"`
+			} `json:"body"`
+		} `json:"message"`
+	}
+	if err := c.get(ctx, "/eth/v2/beacon/blocks/"+blockID, &block); err != nil {
+		return common.Hash{}, err
+	}
+	return block.Message.Body.ExecutionPayload.BlockHash, nil
+}
+
+// ProposerDuties returns the block proposers of the slots of an epoch
+func (c *BeaconClient) ProposerDuties(ctx context.Context, epoch uint64) ([]BeaconProposerDuty, error) {
+	var duties []BeaconProposerDuty
+	if err := c.get(ctx, fmt.Sprintf("/eth/v1/validator/duties/proposer/%d", epoch), &duties); err != nil {
+		return nil, err
+	}
+	return duties, nil
+}
+
+// ChainConfig derives the chain config from the spec, genesis and fork schedule of the beacon node
+func (c *BeaconClient) ChainConfig(ctx context.Context) (*ChainConfig, error) {
+	spec, err := c.Spec(ctx)
+	if err != nil {
+		return nil, err
+	}
+	genesis, err := c.Genesis(ctx)
+	if err != nil {
+		return nil, err
+	}
+	forks, err := c.ForkSchedule(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	file := &chainConfigFile{
+		ConfigName:              spec["CONFIG_NAME"],
+		GenesisTime:             genesis.GenesisTime,
+		GenesisForkVersion:      genesis.GenesisForkVersion,
+		AltairForkVersion:       spec["ALTAIR_FORK_VERSION"],
+		AltairForkEpoch:         spec["ALTAIR_FORK_EPOCH"],
+		BellatrixForkVersion:    spec["BELLATRIX_FORK_VERSION"],
+		BellatrixForkEpoch:      spec["BELLATRIX_FORK_EPOCH"],
+		CapellaForkVersion:      spec["CAPELLA_FORK_VERSION"],
+		CapellaForkEpoch:        spec["CAPELLA_FORK_EPOCH"],
+		SecondsPerSlot:          spec["SECONDS_PER_SLOT"],
+		SlotsPerEpoch:           spec["SLOTS_PER_EPOCH"],
+		TerminalTotalDifficulty: spec["TERMINAL_TOTAL_DIFFICULTY"],
+	}
+
+	// the fork schedule is authoritative: genesis, altair, bellatrix and capella in that order
+	forkVersions := []*string{&file.GenesisForkVersion, &file.AltairForkVersion, &file.BellatrixForkVersion, &file.CapellaForkVersion}
+	forkEpochs := []*string{nil, &file.AltairForkEpoch, &file.BellatrixForkEpoch, &file.CapellaForkEpoch}
+	for i, fork := range forks {
+		if i >= len(forkVersions) {
+			break
+		}
+		*forkVersions[i] = fork.CurrentVersion
+		if forkEpochs[i] != nil {
+			*forkEpochs[i] = fork.Epoch
+		}
+	}
+
+	config, err := file.toChainConfig()
+	if err != nil {
+		return nil, fmt.Errorf("invalid chain config from beacon node: %w", err)
+	}
+	return config, nil
+}
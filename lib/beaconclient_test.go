@@ -0,0 +1,44 @@
+package lib
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func newMockBeaconNode(t *testing.T, responses map[string]string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		response, ok := responses[r.URL.Path]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write([]byte(response))
+	}))
+}
+
+func TestBeaconClient_ChainConfig(t *testing.T) {
+	beaconNode := newMockBeaconNode(t, map[string]string{
+		"/eth/v1/config/spec":          `{"data":{"CONFIG_NAME":"devnet","SECONDS_PER_SLOT":"6","SLOTS_PER_EPOCH":"8","TERMINAL_TOTAL_DIFFICULTY":"100"}}`,
+		"/eth/v1/beacon/genesis":       `{"data":{"genesis_time":"1650000000","genesis_validators_root":"0x01","genesis_fork_version":"0x00000069"}}`,
+		"/eth/v1/config/fork_schedule": `{"data":[{"previous_version":"0x00000069","current_version":"0x00000069","epoch":"0"},{"previous_version":"0x00000069","current_version":"0x01000069","epoch":"1"},{"previous_version":"0x01000069","current_version":"0x02000069","epoch":"2"}]}`,
+	})
+	defer beaconNode.Close()
+
+	config, err := NewBeaconClient(beaconNode.URL + "/").ChainConfig(context.Background())
+	require.Nil(t, err)
+	require.Equal(t, "devnet", config.Name)
+	require.Equal(t, uint64(1650000000), config.GenesisTime)
+	require.Equal(t, [4]byte{0x00, 0x00, 0x00, 0x69}, config.GenesisForkVersion)
+	require.Equal(t, [4]byte{0x01, 0x00, 0x00, 0x69}, config.AltairForkVersion)
+	require.Equal(t, [4]byte{0x02, 0x00, 0x00, 0x69}, config.BellatrixForkVersion)
+	require.Equal(t, uint64(2), config.BellatrixForkEpoch)
+	require.Equal(t, uint64(6), config.SecondsPerSlot)
+	require.Equal(t, uint64(8), config.SlotsPerEpoch)
+
+	_, err = NewBeaconClient("http://127.0.0.1:1").ChainConfig(context.Background())
+	require.NotNil(t, err)
+}
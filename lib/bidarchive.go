@@ -0,0 +1,119 @@
+package lib
+
+import (
+	"math/big"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+var maxArchivedBids = 50000
+
+// Results of archived bids
+const (
+	BidResultValid       = "valid"         // passed validation, but a more valuable bid won
+	BidResultWon         = "won"           // returned to the consensus client
+	BidResultInvalid     = "invalid"       // rejected by validation, see Error
+	BidResultVetoed      = "vetoed"        // rejected by the bid decision callback or policy engine, see Error
+	BidResultBelowMinBid = "below_min_bid" // lower than the min bid of the tenant
+	BidResultNotEscrowed = "not_escrowed"  // came without its transactions, so the payload isn't held in escrow mode
+	BidResultShadow      = "shadow"        // of a shadow relay, only compared with the other bids
+	BidResultLate        = "late"          // arrived after the race cutoff, see WithHeaderRace
+)
+
+// ArchivedBid is a bid received from a relay, with the outcome of its validation
+type ArchivedBid struct {
+	Slot       uint64      `json:"slot,string"`
+	RelayURL   string      `json:"relayUrl"`
+	BlockHash  common.Hash `json:"blockHash"`
+	Value      *big.Int    `json:"value"`
+	ReceivedAt time.Time   `json:"receivedAt"`
+	Result     string      `json:"result"`
+	Error      string      `json:"error,omitempty"`
+}
+
+// bidArchive keeps the most recent bids of all getPayloadHeader calls
+type bidArchive struct {
+	mu   sync.RWMutex
+	bids []*ArchivedBid
+}
+
+func (a *bidArchive) add(bid *ArchivedBid) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.bids = append(a.bids, bid)
+	if len(a.bids) > maxArchivedBids {
+		a.bids = a.bids[len(a.bids)-maxArchivedBids:]
+	}
+}
+
+// setResult updates the result of the most recent bid of a relay with blockHash
+func (a *bidArchive) setResult(relayURL string, blockHash common.Hash, result string, err error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	for i := len(a.bids) - 1; i >= 0; i-- {
+		if bid := a.bids[i]; bid.RelayURL == relayURL && bid.BlockHash == blockHash {
+			bid.Result = result
+			if err != nil {
+				bid.Error = err.Error()
+			}
+			return
+		}
+	}
+}
+
+// slot returns copies of the bids of a slot, in the order they arrived
+func (a *bidArchive) slot(slot uint64) []ArchivedBid {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	bids := []ArchivedBid{}
+	for _, bid := range a.bids {
+		if bid.Slot == slot {
+			bids = append(bids, *bid)
+		}
+	}
+	return bids
+}
+
+// all returns copies of all archived bids, oldest first
+func (a *bidArchive) all() []ArchivedBid {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	bids := make([]ArchivedBid, len(a.bids))
+	for i, bid := range a.bids {
+		bids[i] = *bid
+	}
+	return bids
+}
+
+// archiveBid adds a bid that arrived now, with the validation error if it was rejected
+func (m *RelayService) archiveBid(relayURL string, header *ExecutionPayloadWithTxRootV1, err error) {
+	bid := &ArchivedBid{
+		Slot:       m.chain.SlotAt(header.Timestamp),
+		RelayURL:   relayURL,
+		BlockHash:  header.BlockHash,
+		Value:      header.FeeRecipientDiff,
+		ReceivedAt: now(),
+		Result:     BidResultValid,
+	}
+	if err != nil {
+		bid.Result, bid.Error = BidResultInvalid, err.Error()
+	}
+	m.bids.add(bid)
+}
+
+func (m *RelayService) handleBids(w http.ResponseWriter, r *http.Request) {
+	slot, err := strconv.ParseUint(r.URL.Query().Get("slot"), 10, 64)
+	if err != nil {
+		respondJSON(w, http.StatusBadRequest, keymanagerError{"slot query parameter is required"})
+		return
+	}
+	respondJSON(w, http.StatusOK, m.bids.slot(slot))
+}
@@ -0,0 +1,91 @@
+package lib
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRouter_BidHistory(t *testing.T) {
+	start := time.Now()
+	newRelay := func(blockHash string, value int64) *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			resp, err := formatResponse(ExecutionPayloadWithTxRootV1{
+				BlockHash:        common.HexToHash(blockHash),
+				Timestamp:        MainnetChainConfig.GenesisTime + 5*MainnetChainConfig.SecondsPerSlot,
+				BaseFeePerGas:    big.NewInt(1),
+				FeeRecipientDiff: big.NewInt(value),
+			})
+			require.Nil(t, err)
+			w.Write(resp)
+		}))
+	}
+	low, mid, high := newRelay("0x01", 1), newRelay("0x02", 2), newRelay("0x03", 3)
+	defer low.Close()
+	defer mid.Close()
+	defer high.Close()
+
+	store := NewStore()
+	for _, relay := range []*httptest.Server{low, mid, high} {
+		store.SetForkchoiceResponse(context.Background(), "0x01", relay.URL, "0x01")
+	}
+	router, err := NewRouter(context.Background(), WithRelayURLs(low.URL, mid.URL, high.URL), WithStore(store), WithLogger(testLog), WithCapabilityCheckInterval(0),
+		WithValidationPolicy(ValidationPolicy{Header: func(_ context.Context, relayURL string, _ *ExecutionPayloadWithTxRootV1) error {
+			if relayURL == high.URL {
+				return errors.New("bad header")
+			}
+			return nil
+		}}),
+		WithBidDecision(func(_ context.Context, _ []BidCandidate, winner BidCandidate) error {
+			if winner.RelayURL == mid.URL {
+				return errors.New("not today")
+			}
+			return nil
+		}))
+	require.Nil(t, err)
+	request := func(method, path string, body []byte) (int, []byte) {
+		req := httptest.NewRequest(method, path, bytes.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		return rr.Code, rr.Body.Bytes()
+	}
+
+	body, err := formatRequestBody("builder_getPayloadHeaderV1", []interface{}{"0x01"})
+	require.Nil(t, err)
+	code, _ := request(http.MethodPost, "/", body)
+	require.Equal(t, http.StatusOK, code)
+
+	code, resp := request(http.MethodGet, "/mev-boost/v1/bids?slot=5", nil)
+	require.Equal(t, http.StatusOK, code)
+	var bids []ArchivedBid
+	require.Nil(t, json.Unmarshal(resp, &bids))
+	results := make(map[string]ArchivedBid)
+	for _, bid := range bids {
+		require.Equal(t, uint64(5), bid.Slot)
+		require.False(t, bid.ReceivedAt.Before(start))
+		results[bid.RelayURL] = bid
+	}
+	require.Len(t, results, 3)
+	require.Equal(t, BidResultWon, results[low.URL].Result)
+	require.Equal(t, BidResultVetoed, results[mid.URL].Result)
+	require.Equal(t, "not today", results[mid.URL].Error)
+	require.Equal(t, BidResultInvalid, results[high.URL].Result)
+	require.Equal(t, "bad header", results[high.URL].Error)
+	require.Equal(t, big.NewInt(3), results[high.URL].Value)
+
+	code, resp = request(http.MethodGet, "/mev-boost/v1/bids?slot=6", nil)
+	require.Equal(t, http.StatusOK, code)
+	require.JSONEq(t, "[]", string(resp))
+	code, _ = request(http.MethodGet, "/mev-boost/v1/bids", nil)
+	require.Equal(t, http.StatusBadRequest, code)
+}
@@ -0,0 +1,143 @@
+package lib
+
+import (
+	"fmt"
+	"math/big"
+	"math/rand"
+	"net/url"
+	"sort"
+)
+
+// Bid selection policies
+const (
+	// BidSelectionHighestValue offers the bids by value weighted with the weight of their relay, the default
+	BidSelectionHighestValue = "highest-value"
+	// BidSelectionWeightedRandom offers a random bid among those close to the best first, picked with the probability of
+	// the weight of its relay
+	BidSelectionWeightedRandom = "weighted-random"
+	// BidSelectionPriority offers the bids close to the best in the priority order of their relays first
+	BidSelectionPriority = "priority"
+)
+
+// BidSelector orders the candidates of a getPayloadHeader call, the first acceptable one is returned to the proposer.
+// Candidates come sorted by value weighted with the weight of their relay, most valuable first, weight returns the
+// weight of a relay. Reveal latency weighting and relay groups may reorder the candidates afterwards.
+type BidSelector interface {
+	SelectBids(candidates []BidCandidate, weight func(relayURL string) float64) []BidCandidate
+}
+
+// NewBidSelector returns the selector of a BidSelectionHighestValue, BidSelectionWeightedRandom or BidSelectionPriority
+// policy. Bids within a fraction of the best weighted value, e.g. 0.01 for 1%, are close to the best. priority lists
+// relays by url or host, highest priority first, for BidSelectionPriority.
+func NewBidSelector(policy string, within float64, priority []string) (BidSelector, error) {
+	if within < 0 || within >= 1 {
+		return nil, fmt.Errorf("bids close to the best must be within a fraction of 0 to 1 of its value, not %v", within)
+	}
+	switch policy {
+	case "", BidSelectionHighestValue:
+		return HighestValueSelector{}, nil
+	case BidSelectionWeightedRandom:
+		return &WeightedRandomSelector{Within: within}, nil
+	case BidSelectionPriority:
+		if len(priority) == 0 {
+			return nil, fmt.Errorf("bid selection policy %s needs relays in priority order", policy)
+		}
+		return &PrioritySelector{Within: within, Relays: priority}, nil
+	default:
+		return nil, fmt.Errorf("unknown bid selection policy %q", policy)
+	}
+}
+
+// HighestValueSelector keeps the candidates most valuable first, on equal weighted value the first response wins
+type HighestValueSelector struct{}
+
+// SelectBids returns candidates unchanged
+func (HighestValueSelector) SelectBids(candidates []BidCandidate, _ func(string) float64) []BidCandidate {
+	return candidates
+}
+
+// WeightedRandomSelector offers a random bid among those within Within of the best weighted value first, each picked
+// with a probability proportional to the weight of its relay. The other candidates follow by value.
+type WeightedRandomSelector struct {
+	Within float64
+
+	random func() float64 // rand.Float64 unless overridden in tests
+}
+
+// SelectBids moves the randomly picked candidate close to the best to the front
+func (s *WeightedRandomSelector) SelectBids(candidates []BidCandidate, weight func(string) float64) []BidCandidate {
+	n := closeToBest(candidates, s.Within, weight)
+	if n < 2 {
+		return candidates
+	}
+	total := 0.0
+	for _, candidate := range candidates[:n] {
+		total += weight(candidate.RelayURL)
+	}
+	if total <= 0 {
+		return candidates
+	}
+	random := s.random
+	if random == nil {
+		random = rand.Float64
+	}
+	picked, threshold := n-1, random()*total
+	for i, candidate := range candidates[:n] {
+		if threshold -= weight(candidate.RelayURL); threshold < 0 {
+			picked = i
+			break
+		}
+	}
+	ordered := make([]BidCandidate, 0, len(candidates))
+	ordered = append(ordered, candidates[picked])
+	ordered = append(ordered, candidates[:picked]...)
+	return append(ordered, candidates[picked+1:]...)
+}
+
+// PrioritySelector offers the bids within Within of the best weighted value in the order of their relays in Relays
+// first, by url or host, relays that aren't listed last. With Within 0 it only breaks ties of equal bids.
+type PrioritySelector struct {
+	Within float64
+	Relays []string
+}
+
+// SelectBids orders the candidates close to the best by relay priority
+func (s *PrioritySelector) SelectBids(candidates []BidCandidate, weight func(string) float64) []BidCandidate {
+	n := closeToBest(candidates, s.Within, weight)
+	ordered := make([]BidCandidate, len(candidates))
+	copy(ordered, candidates)
+	sort.SliceStable(ordered[:n], func(i, j int) bool {
+		return s.priority(ordered[i].RelayURL) < s.priority(ordered[j].RelayURL)
+	})
+	return ordered
+}
+
+// priority returns the position of the relay at relayURL in Relays, len(Relays) if it isn't listed
+func (s *PrioritySelector) priority(relayURL string) int {
+	host := relayURL
+	if u, err := url.Parse(relayURL); err == nil {
+		host = u.Host
+	}
+	for i, entry := range s.Relays {
+		if entry == relayURL || entry == host {
+			return i
+		}
+	}
+	return len(s.Relays)
+}
+
+// closeToBest returns the number of leading candidates whose weighted value is within a fraction of the best
+func closeToBest(candidates []BidCandidate, within float64, weight func(string) float64) int {
+	if len(candidates) == 0 {
+		return 0
+	}
+	weighted := func(candidate BidCandidate) *big.Float {
+		return new(big.Float).Mul(new(big.Float).SetInt(bidValue(candidate.Header)), big.NewFloat(weight(candidate.RelayURL)))
+	}
+	threshold := new(big.Float).Mul(weighted(candidates[0]), big.NewFloat(1-within))
+	n := 1
+	for n < len(candidates) && weighted(candidates[n]).Cmp(threshold) >= 0 {
+		n++
+	}
+	return n
+}
@@ -0,0 +1,119 @@
+package lib
+
+import (
+	"context"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/require"
+)
+
+func selectorCandidates(bids ...interface{}) []BidCandidate {
+	var candidates []BidCandidate
+	for i := 0; i < len(bids); i += 2 {
+		candidates = append(candidates, BidCandidate{RelayURL: bids[i].(string), Header: &ExecutionPayloadWithTxRootV1{
+			BlockHash:        common.BigToHash(big.NewInt(int64(i + 1))),
+			FeeRecipientDiff: big.NewInt(int64(bids[i+1].(int))),
+		}})
+	}
+	return candidates
+}
+
+func selectedRelays(candidates []BidCandidate) []string {
+	relays := make([]string, len(candidates))
+	for i, candidate := range candidates {
+		relays[i] = candidate.RelayURL
+	}
+	return relays
+}
+
+func TestHighestValueSelector(t *testing.T) {
+	candidates := selectorCandidates("http://a", 100, "http://b", 100, "http://c", 90)
+	selected := HighestValueSelector{}.SelectBids(candidates, func(string) float64 { return 1 })
+	require.Equal(t, []string{"http://a", "http://b", "http://c"}, selectedRelays(selected))
+}
+
+func TestWeightedRandomSelector(t *testing.T) {
+	weights := map[string]float64{"http://a": 1, "http://b": 3, "http://c": 1}
+	weight := func(relayURL string) float64 { return weights[relayURL] }
+	// weighted values 297, 100 and 95, only b is within 1% of the best
+	candidates := selectorCandidates("http://b", 99, "http://a", 100, "http://c", 95)
+	selector := &WeightedRandomSelector{Within: 0.01, random: func() float64 { return 0.99 }}
+	require.Equal(t, []string{"http://b", "http://a", "http://c"}, selectedRelays(selector.SelectBids(candidates, weight)))
+
+	weights["http://b"], weights["http://d"] = 1, 1
+	candidates = selectorCandidates("http://a", 100, "http://b", 100, "http://c", 99, "http://d", 90)
+	for _, test := range []struct {
+		random float64
+		first  string
+	}{
+		{0, "http://a"},
+		{0.3, "http://a"},
+		{0.34, "http://b"},
+		{0.99, "http://c"},
+	} {
+		selector.random = func() float64 { return test.random }
+		selected := selectedRelays(selector.SelectBids(candidates, weight))
+		require.Equal(t, test.first, selected[0], "random %v", test.random)
+		require.Equal(t, "http://d", selected[3], "bids further from the best keep their place")
+	}
+
+	weights["http://c"] = 0
+	selector.random = func() float64 { return 0.99 }
+	require.Equal(t, "http://b", selectedRelays(selector.SelectBids(candidates, weight))[0], "relays with weight 0 are never picked")
+}
+
+func TestPrioritySelector(t *testing.T) {
+	weight := func(string) float64 { return 1 }
+	candidates := selectorCandidates("http://0xab@a.example.com", 100, "http://b.example.com", 100, "http://c.example.com", 99, "http://d.example.com", 98)
+
+	selector := &PrioritySelector{Relays: []string{"c.example.com", "http://b.example.com", "a.example.com"}}
+	require.Equal(t, []string{"http://b.example.com", "http://0xab@a.example.com", "http://c.example.com", "http://d.example.com"},
+		selectedRelays(selector.SelectBids(candidates, weight)), "without a margin only ties are broken")
+
+	selector.Within = 0.015
+	require.Equal(t, []string{"http://c.example.com", "http://b.example.com", "http://0xab@a.example.com", "http://d.example.com"},
+		selectedRelays(selector.SelectBids(candidates, weight)))
+
+	selector.Within = 0.05
+	require.Equal(t, []string{"http://c.example.com", "http://b.example.com", "http://0xab@a.example.com", "http://d.example.com"},
+		selectedRelays(selector.SelectBids(candidates, weight)), "unlisted relays come last")
+	require.Equal(t, "http://0xab@a.example.com", candidates[0].RelayURL, "candidates aren't modified")
+}
+
+func TestNewBidSelector(t *testing.T) {
+	selector, err := NewBidSelector("", 0, nil)
+	require.Nil(t, err)
+	require.Equal(t, HighestValueSelector{}, selector)
+	selector, err = NewBidSelector(BidSelectionWeightedRandom, 0.01, nil)
+	require.Nil(t, err)
+	require.Equal(t, 0.01, selector.(*WeightedRandomSelector).Within)
+
+	_, err = NewBidSelector(BidSelectionPriority, 0, nil)
+	require.Error(t, err, "priority without relays")
+	_, err = NewBidSelector(BidSelectionHighestValue, 1, nil)
+	require.Error(t, err)
+	_, err = NewBidSelector("lowest-value", 0, nil)
+	require.Error(t, err)
+}
+
+func TestGetPayloadHeaderV1_BidSelector(t *testing.T) {
+	a := newHeaderRelay(t, ExecutionPayloadWithTxRootV1{BlockHash: common.HexToHash("0x01"), BaseFeePerGas: big.NewInt(1), FeeRecipientDiff: big.NewInt(100)})
+	defer a.Close()
+	b := newHeaderRelay(t, ExecutionPayloadWithTxRootV1{BlockHash: common.HexToHash("0x02"), BaseFeePerGas: big.NewInt(1), FeeRecipientDiff: big.NewInt(99)})
+	defer b.Close()
+
+	store := NewStore()
+	for _, relay := range []string{a.URL, b.URL} {
+		store.SetForkchoiceResponse(context.Background(), "0x01", relay, "0x01")
+	}
+	service, err := newRelayService(WithRelayURLs(a.URL, b.URL), WithStore(store), WithLogger(testLog),
+		WithBidSelector(&PrioritySelector{Within: 0.02, Relays: []string{b.URL}}))
+	require.Nil(t, err)
+
+	payloadID := "0x01"
+	result := new(ExecutionPayloadWithTxRootV1)
+	require.Nil(t, service.GetPayloadHeaderV1(nil, &payloadID, result))
+	require.Equal(t, common.HexToHash("0x02"), result.BlockHash, "the bid of the priority relay is within 2% of the best")
+}
@@ -0,0 +1,165 @@
+package lib
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/url"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var bidSignaturesTotal = metricsFactory.NewCounterVec(prometheus.CounterOpts{
+	Name: "mevboost_bid_signatures_total",
+	Help: "Relay headers by relay and result of their signature check: valid, invalid or missing",
+}, []string{"relay", "result"})
+
+// BuilderBid is the message a relay signs for a header, as in the builder specs: the header, its value to the proposer
+// and the pubkey of the relay
+type BuilderBid struct {
+	Header *ExecutionPayloadHeaderV1
+	Value  *big.Int
+	Pubkey BLSPubkey
+}
+
+// SignedBuilderBid is a BuilderBid with the signature of the relay, the SSZ encoded header response of a relay
+type SignedBuilderBid struct {
+	Message   *BuilderBid
+	Signature BLSSignature
+}
+
+// HashTreeRoot returns the SSZ hash tree root of the bid
+func (b *BuilderBid) HashTreeRoot() ([32]byte, error) {
+	if b.Value == nil || b.Value.Sign() < 0 || b.Value.BitLen() > 256 {
+		return [32]byte{}, fmt.Errorf("invalid value %v", b.Value)
+	}
+	header, err := b.Header.HashTreeRoot()
+	if err != nil {
+		return [32]byte{}, err
+	}
+
+	// uint256 is little endian
+	var value [32]byte
+	be := b.Value.FillBytes(make([]byte, 32))
+	for i := range be {
+		value[i] = be[31-i]
+	}
+	return merkleize([][32]byte{header, value, b.Pubkey.HashTreeRoot()}), nil
+}
+
+// payloadHeader returns the header of the bid as header response, with the value of the bid as FeeRecipientDiff
+func (b *BuilderBid) payloadHeader() *ExecutionPayloadWithTxRootV1 {
+	h := b.Header
+	return &ExecutionPayloadWithTxRootV1{
+		ParentHash:       h.ParentHash,
+		FeeRecipient:     h.FeeRecipient,
+		StateRoot:        h.StateRoot,
+		ReceiptsRoot:     h.ReceiptsRoot,
+		LogsBloom:        append([]byte{}, h.LogsBloom[:]...),
+		PrevRandao:       h.PrevRandao,
+		Number:           h.BlockNumber,
+		GasLimit:         h.GasLimit,
+		GasUsed:          h.GasUsed,
+		Timestamp:        h.Timestamp,
+		ExtraData:        h.ExtraData,
+		BaseFeePerGas:    h.BaseFeePerGas,
+		BlockHash:        h.BlockHash,
+		TransactionsRoot: h.TransactionsRoot,
+		FeeRecipientDiff: b.Value,
+	}
+}
+
+// bidSignatures verifies that relay headers are signed by the relay pubkey in the user part of the relay url, over the
+// BuilderBid of the header and its FeeRecipientDiff in the builder domain
+type bidSignatures struct {
+	domain [32]byte
+
+	mu      sync.RWMutex
+	pubkeys map[string]BLSPubkey // by relay url
+}
+
+func newBidSignatures(relayURLs []string, chain *ChainConfig) (*bidSignatures, error) {
+	pubkeys, err := relayPubkeys(relayURLs)
+	if err != nil {
+		return nil, err
+	}
+	return &bidSignatures{domain: chain.BuilderDomain(), pubkeys: pubkeys}, nil
+}
+
+// relayPubkeys returns the pubkeys in the user part of the relay urls
+func relayPubkeys(relayURLs []string) (map[string]BLSPubkey, error) {
+	pubkeys := make(map[string]BLSPubkey, len(relayURLs))
+	for _, relayURL := range relayURLs {
+		u, err := url.Parse(relayURL)
+		if err != nil {
+			return nil, err
+		}
+		if u.User == nil || u.User.Username() == "" {
+			return nil, fmt.Errorf("relay %s has no pubkey to verify its bids with", u.Host)
+		}
+		pubkey, err := hexutil.Decode(u.User.Username())
+		if err == nil {
+			err = ValidatePubkey(pubkey)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid pubkey of relay %s: %w", u.Host, err)
+		}
+		var relayPubkey BLSPubkey
+		copy(relayPubkey[:], pubkey)
+		pubkeys[relayURL] = relayPubkey
+	}
+	return pubkeys, nil
+}
+
+// replace replaces the pubkeys of the relays when they are reloaded, see relayPubkeys
+func (s *bidSignatures) replace(pubkeys map[string]BLSPubkey) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.pubkeys = pubkeys
+}
+
+// splitBidSignature splits the signature off the header response of a relay, a header object with an additional
+// signature field, so the header decodes without it
+func splitBidSignature(result json.RawMessage) (json.RawMessage, []byte, error) {
+	var object map[string]json.RawMessage
+	if err := json.Unmarshal(result, &object); err != nil || object == nil {
+		return result, nil, nil // not an object, left to the header decoding to reject
+	}
+	raw, ok := object["signature"]
+	if !ok || isNull(raw) {
+		return result, nil, nil
+	}
+	var signature hexutil.Bytes
+	if err := json.Unmarshal(raw, &signature); err != nil {
+		return nil, nil, fmt.Errorf("invalid bid signature: %w", err)
+	}
+	delete(object, "signature")
+	header, err := json.Marshal(object)
+	return header, signature, err
+}
+
+// verify checks the signature of a header of relayURL, headers without signature are rejected
+func (s *bidSignatures) verify(relayURL string, header *ExecutionPayloadWithTxRootV1, signature []byte) error {
+	if signature == nil {
+		bidSignaturesTotal.WithLabelValues(relayURL, "missing").Inc()
+		return errors.New("header has no bid signature")
+	}
+	s.mu.RLock()
+	pubkey := s.pubkeys[relayURL]
+	s.mu.RUnlock()
+	bid := &BuilderBid{Header: header.Header(), Value: header.FeeRecipientDiff, Pubkey: pubkey}
+	root, err := bid.HashTreeRoot()
+	if err != nil {
+		return fmt.Errorf("could not compute bid root: %w", err)
+	}
+	ok, err := VerifySignature(bid.Pubkey[:], ComputeSigningRoot(root, s.domain), signature)
+	if err != nil || !ok {
+		bidSignaturesTotal.WithLabelValues(relayURL, "invalid").Inc()
+		return errors.New("bid signature doesn't match the relay pubkey")
+	}
+	bidSignaturesTotal.WithLabelValues(relayURL, "valid").Inc()
+	return nil
+}
@@ -0,0 +1,104 @@
+package lib
+
+import (
+	"context"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/stretchr/testify/require"
+)
+
+// newSignedHeaderRelay serves header with the signature sign returns for its bid, the url of the relay has the pubkey
+// of secretKey in its user part
+func newSignedHeaderRelay(t *testing.T, secretKey *big.Int, header ExecutionPayloadWithTxRootV1, sign func(signature []byte) interface{}) (*httptest.Server, string) {
+	pubkey, _ := blsSign(secretKey, [32]byte{})
+	var relayPubkey BLSPubkey
+	copy(relayPubkey[:], pubkey)
+	bid := &BuilderBid{Header: header.Header(), Value: header.FeeRecipientDiff, Pubkey: relayPubkey}
+	root, err := bid.HashTreeRoot()
+	require.Nil(t, err)
+	_, signature := blsSign(secretKey, ComputeSigningRoot(root, MainnetChainConfig.BuilderDomain()))
+
+	data, err := json.Marshal(header)
+	require.Nil(t, err)
+	var result map[string]interface{}
+	require.Nil(t, json.Unmarshal(data, &result))
+	if s := sign(signature); s != nil {
+		result["signature"] = s
+	}
+
+	relay := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		resp, err := formatResponse(result)
+		require.Nil(t, err)
+		w.Write(resp)
+	}))
+	return relay, strings.Replace(relay.URL, "http://", "http://"+hexutil.Encode(pubkey)+"@", 1)
+}
+
+func TestGetPayloadHeaderV1_BidSignatures(t *testing.T) {
+	header := func(blockHash string, value int64) ExecutionPayloadWithTxRootV1 {
+		return ExecutionPayloadWithTxRootV1{BlockHash: common.HexToHash(blockHash), BaseFeePerGas: big.NewInt(1), FeeRecipientDiff: big.NewInt(value)}
+	}
+	signed := func(signature []byte) interface{} { return hexutil.Bytes(signature) }
+	forged := func(signature []byte) interface{} {
+		signature[len(signature)-1] ^= 1
+		return hexutil.Bytes(signature)
+	}
+	unsigned := func([]byte) interface{} { return nil }
+
+	a, aURL := newSignedHeaderRelay(t, big.NewInt(101), header("0x01", 1), signed)
+	defer a.Close()
+	b, bURL := newSignedHeaderRelay(t, big.NewInt(102), header("0x02", 2), forged)
+	defer b.Close()
+	c, cURL := newSignedHeaderRelay(t, big.NewInt(103), header("0x03", 3), unsigned)
+	defer c.Close()
+
+	store := NewStore()
+	for _, relayURL := range []string{aURL, bURL, cURL} {
+		store.SetForkchoiceResponse(context.Background(), "0x01", relayURL, "0x01")
+	}
+	service, err := newRelayService(WithRelayURLs(aURL, bURL, cURL), WithStore(store), WithLogger(testLog), WithBidSignatureVerification())
+	require.Nil(t, err)
+
+	payloadID := "0x01"
+	result := new(ExecutionPayloadWithTxRootV1)
+	require.Nil(t, service.GetPayloadHeaderV1(nil, &payloadID, result))
+	require.Equal(t, common.HexToHash("0x01"), result.BlockHash, "headers with forged or missing signatures are dropped")
+
+	// without verification the signatures are ignored
+	service, err = newRelayService(WithRelayURLs(aURL, bURL, cURL), WithStore(store), WithLogger(testLog))
+	require.Nil(t, err)
+	require.Nil(t, service.GetPayloadHeaderV1(nil, &payloadID, result))
+	require.Equal(t, common.HexToHash("0x03"), result.BlockHash)
+}
+
+func TestNewBidSignatures(t *testing.T) {
+	_, err := newBidSignatures([]string{"http://127.0.0.1:28545"}, MainnetChainConfig)
+	require.EqualError(t, err, "relay 127.0.0.1:28545 has no pubkey to verify its bids with")
+	_, err = newBidSignatures([]string{"http://0x0102@127.0.0.1:28545"}, MainnetChainConfig)
+	require.NotNil(t, err)
+
+	_, err = newRelayService(WithRelayURLs("http://127.0.0.1:28545"), WithLogger(testLog), WithBidSignatureVerification())
+	require.NotNil(t, err)
+}
+
+func TestSplitBidSignature(t *testing.T) {
+	header, signature, err := splitBidSignature(json.RawMessage(`{"blockHash":"0x01","signature":"0x0203"}`))
+	require.Nil(t, err)
+	require.JSONEq(t, `{"blockHash":"0x01"}`, string(header))
+	require.Equal(t, []byte{2, 3}, signature)
+
+	header, signature, err = splitBidSignature(json.RawMessage(`{"blockHash":"0x01"}`))
+	require.Nil(t, err)
+	require.JSONEq(t, `{"blockHash":"0x01"}`, string(header))
+	require.Nil(t, signature)
+
+	_, _, err = splitBidSignature(json.RawMessage(`{"signature":"nope"}`))
+	require.NotNil(t, err)
+}
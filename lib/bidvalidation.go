@@ -0,0 +1,76 @@
+package lib
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime"
+)
+
+// maxBidValidators bounds how many relay bids are decoded and validated at once
+var maxBidValidators = runtime.NumCPU()
+
+// prevalidateHeader decodes and validates the header of a getPayloadHeader response as soon as it arrives, while other
+// relays are still answering, so every bid is checked by the time one is selected and an invalid top bid doesn't cost
+// another round of validation inside the deadline. At most maxBidValidators headers are validated at once.
+func (m *RelayService) prevalidateHeader(ctx context.Context, res *rpcResponseContainer, logMethod Logger) {
+	select {
+	case m.bidValidators <- struct{}{}:
+		defer func() { <-m.bidValidators }()
+	case <-ctx.Done():
+		res.err = ctx.Err()
+		return
+	}
+
+	header, signature, err := m.decodeHeader(res.url, res.res)
+	if err != nil {
+		res.invalid = err
+		m.health.failure(res.url, relayHealthMalformed, err)
+		return
+	}
+	m.health.success(res.url)
+	res.header = header
+	if res.invalid = m.checkHeader(ctx, res.url, header, logMethod); res.invalid != nil || m.bidSignatures == nil {
+		return
+	}
+	if res.invalid = m.bidSignatures.verify(res.url, header, signature); res.invalid == nil {
+		res.signature = signature
+	}
+}
+
+// decodeHeader decodes the header response of a relay, and returns it with its bid signature, if any. SSZ responses are
+// a SignedBuilderBid, JSON responses a header with FeeRecipientDiff and an optional signature field.
+func (m *RelayService) decodeHeader(relayURL string, res *rpcResponse) (*ExecutionPayloadWithTxRootV1, []byte, error) {
+	if res.SSZ != nil {
+		var bid SignedBuilderBid
+		if err := bid.UnmarshalSSZ(res.SSZ); err != nil {
+			return nil, nil, fmt.Errorf("%w: could not decode SSZ header: %v", ErrValidationFailed, err)
+		}
+		return bid.Message.payloadHeader(), bid.Signature[:], nil
+	}
+
+	result, signature := res.Result, []byte(nil)
+	if m.bidSignatures != nil {
+		var err error
+		if result, signature, err = splitBidSignature(result); err != nil {
+			return nil, nil, err
+		}
+	}
+	header := new(ExecutionPayloadWithTxRootV1)
+	if err := m.decoder.decode(relayURL, result, header); err != nil {
+		return nil, nil, err
+	}
+	return header, signature, nil
+}
+
+// checkHeader rejects headers without block hash or with a transactions root that contradicts their transactions, and
+// applies the validation policy
+func (m *RelayService) checkHeader(ctx context.Context, relayURL string, header *ExecutionPayloadWithTxRootV1, logMethod Logger) error {
+	if header.BlockHash == nilHash {
+		return errors.New("header has no block hash")
+	}
+	if err := m.fillTransactionsRoot(header, logMethod); err != nil {
+		return err
+	}
+	return m.validation.validateHeader(ctx, relayURL, header)
+}
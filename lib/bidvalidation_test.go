@@ -0,0 +1,100 @@
+package lib
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/require"
+)
+
+func newHeaderRelay(t *testing.T, header ExecutionPayloadWithTxRootV1) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		resp, err := formatResponse(header)
+		require.Nil(t, err)
+		w.Write(resp)
+	}))
+}
+
+func TestGetPayloadHeaderV1_PrevalidatesAllBids(t *testing.T) {
+	mismatchedRoot := newHeaderRelay(t, ExecutionPayloadWithTxRootV1{
+		BlockHash:        common.HexToHash("0x01"),
+		BaseFeePerGas:    big.NewInt(1),
+		FeeRecipientDiff: big.NewInt(3),
+		Transactions:     &[]string{},
+		TransactionsRoot: common.HexToHash("0x01"),
+	})
+	defer mismatchedRoot.Close()
+	noBlockHash := newHeaderRelay(t, ExecutionPayloadWithTxRootV1{BaseFeePerGas: big.NewInt(1), FeeRecipientDiff: big.NewInt(2)})
+	defer noBlockHash.Close()
+	valid := newHeaderRelay(t, ExecutionPayloadWithTxRootV1{BlockHash: common.HexToHash("0x03"), BaseFeePerGas: big.NewInt(1), FeeRecipientDiff: big.NewInt(1)})
+	defer valid.Close()
+
+	store := NewStore()
+	relayURLs := []string{mismatchedRoot.URL, noBlockHash.URL, valid.URL}
+	for _, relayURL := range relayURLs {
+		store.SetForkchoiceResponse(context.Background(), "0x01", relayURL, "0x01")
+	}
+	service, err := newRelayService(WithRelayURLs(relayURLs...), WithStore(store), WithLogger(testLog))
+	require.Nil(t, err)
+
+	payloadID := "0x01"
+	header := new(ExecutionPayloadWithTxRootV1)
+	require.Nil(t, service.GetPayloadHeaderV1(nil, &payloadID, header))
+	require.Equal(t, common.HexToHash("0x03"), header.BlockHash)
+
+	results := make(map[string]string)
+	for _, bid := range service.bids.slot(0) {
+		results[bid.RelayURL] = bid.Result
+	}
+	require.Equal(t, map[string]string{
+		mismatchedRoot.URL: BidResultInvalid,
+		noBlockHash.URL:    BidResultInvalid,
+		valid.URL:          BidResultWon,
+	}, results)
+}
+
+func TestGetPayloadHeaderV1_ValidatesBidsConcurrently(t *testing.T) {
+	defer func(n int) { maxBidValidators = n }(maxBidValidators)
+	maxBidValidators = 2
+
+	a := newHeaderRelay(t, ExecutionPayloadWithTxRootV1{BlockHash: common.HexToHash("0x01"), BaseFeePerGas: big.NewInt(1), FeeRecipientDiff: big.NewInt(1)})
+	defer a.Close()
+	b := newHeaderRelay(t, ExecutionPayloadWithTxRootV1{BlockHash: common.HexToHash("0x02"), BaseFeePerGas: big.NewInt(1), FeeRecipientDiff: big.NewInt(2)})
+	defer b.Close()
+
+	// the policy only passes headers once both are validated at the same time
+	var mu sync.Mutex
+	waiting := 0
+	both := make(chan struct{})
+	policy := ValidationPolicy{Header: func(ctx context.Context, relayURL string, header *ExecutionPayloadWithTxRootV1) error {
+		mu.Lock()
+		if waiting++; waiting == 2 {
+			close(both)
+		}
+		mu.Unlock()
+		select {
+		case <-both:
+			return nil
+		case <-time.After(5 * time.Second):
+			return errors.New("headers validated one after another")
+		}
+	}}
+
+	store := NewStore()
+	store.SetForkchoiceResponse(context.Background(), "0x01", a.URL, "0x01")
+	store.SetForkchoiceResponse(context.Background(), "0x01", b.URL, "0x01")
+	service, err := newRelayService(WithRelayURLs(a.URL, b.URL), WithStore(store), WithLogger(testLog), WithValidationPolicy(policy))
+	require.Nil(t, err)
+
+	payloadID := "0x01"
+	header := new(ExecutionPayloadWithTxRootV1)
+	require.Nil(t, service.GetPayloadHeaderV1(nil, &payloadID, header))
+	require.Equal(t, common.HexToHash("0x02"), header.BlockHash)
+}
@@ -0,0 +1,119 @@
+package lib
+
+import (
+	"context"
+	"math/big"
+	"sync"
+	"time"
+)
+
+type paymentOutcome struct {
+	promised *big.Int
+	paid     *big.Int
+}
+
+// relayBlacklist suspends relays whose realized payments over the last window verified payloads fall short of
+// their promised bids by more than tolerance (a fraction of the promised value). The payments and suspensions are kept
+// in the store as relay reputations. A suspension is lifted once it lasted expiry, unless expiry is 0.
+type relayBlacklist struct {
+	tolerance float64
+	window    int
+	expiry    time.Duration
+	store     Store
+	events    *eventBus
+	log       Logger
+
+	mu        sync.RWMutex
+	outcomes  map[string][]paymentOutcome
+	suspended map[string]time.Time
+}
+
+func newRelayBlacklist(tolerance float64, window int, expiry time.Duration, store Store, events *eventBus, log Logger) *relayBlacklist {
+	return &relayBlacklist{
+		tolerance: tolerance,
+		window:    window,
+		expiry:    expiry,
+		store:     store,
+		events:    events,
+		log:       log.WithField("prefix", "lib/blacklist"),
+		outcomes:  make(map[string][]paymentOutcome),
+		suspended: make(map[string]time.Time),
+	}
+}
+
+// record adds a verified payment of a relay, and suspends the relay if it underpaid over the window
+func (b *relayBlacklist) record(relayURL string, promised, paid *big.Int) {
+	if b.window <= 0 || promised == nil || paid == nil {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	outcomes := append(b.outcomes[relayURL], paymentOutcome{promised, paid})
+	if len(outcomes) > b.window {
+		outcomes = outcomes[len(outcomes)-b.window:]
+	}
+	b.outcomes[relayURL] = outcomes
+	defer b.persist(relayURL)
+
+	if _, ok := b.suspended[relayURL]; ok || len(outcomes) < b.window {
+		return
+	}
+
+	totalPromised := new(big.Int)
+	shortfall := new(big.Int)
+	for _, outcome := range outcomes {
+		totalPromised.Add(totalPromised, outcome.promised)
+		if outcome.paid.Cmp(outcome.promised) < 0 {
+			shortfall.Add(shortfall, new(big.Int).Sub(outcome.promised, outcome.paid))
+		}
+	}
+	if totalPromised.Sign() == 0 {
+		return
+	}
+
+	ratio, _ := new(big.Float).Quo(new(big.Float).SetInt(shortfall), new(big.Float).SetInt(totalPromised)).Float64()
+	if ratio <= b.tolerance {
+		return
+	}
+
+	b.suspended[relayURL] = now()
+
+	fields := Fields{
+		"url":       relayURL,
+		"payloads":  len(outcomes),
+		"promised":  totalPromised,
+		"shortfall": shortfall,
+		"ratio":     ratio,
+		"tolerance": b.tolerance,
+	}
+	b.log.WithFields(fields).Error("suspending relay: payments fell short of promised bids")
+	b.events.publish(context.Background(), &Event{Kind: EventRelayFault, RelayURL: relayURL, Fault: RelayFaultSuspended, Fields: fields})
+}
+
+func (b *relayBlacklist) isSuspended(relayURL string) bool {
+	b.mu.RLock()
+	suspendedAt, ok := b.suspended[relayURL]
+	b.mu.RUnlock()
+	if !ok || b.expiry <= 0 || now().Sub(suspendedAt) < b.expiry {
+		return ok
+	}
+	b.reinstate(relayURL, suspendedAt)
+	return false
+}
+
+// reinstate lifts an expired suspension and forgets the payments that led to it, so the relay has a full window to
+// pay its bids before it can be suspended again
+func (b *relayBlacklist) reinstate(relayURL string, suspendedAt time.Time) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if current, ok := b.suspended[relayURL]; !ok || !current.Equal(suspendedAt) {
+		return
+	}
+	delete(b.suspended, relayURL)
+	delete(b.outcomes, relayURL)
+	relaySuspended.WithLabelValues(relayURL).Set(0)
+	b.persist(relayURL)
+	b.log.WithFields(Fields{"url": relayURL, "suspendedAt": suspendedAt, "expiry": b.expiry}).Warn("reinstating relay: suspension expired")
+}
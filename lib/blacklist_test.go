@@ -0,0 +1,64 @@
+package lib
+
+import (
+	"context"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_relayBlacklist_record(t *testing.T) {
+	tests := []struct {
+		name          string
+		window        int
+		paid          []int64
+		wantSuspended bool
+	}{
+		{"disabled", 0, []int64{0, 0, 0}, false},
+		{"paid in full", 3, []int64{100, 100, 100}, false},
+		{"underpaid within tolerance", 3, []int64{100, 100, 95}, false},
+		{"underpaid above tolerance", 3, []int64{100, 80, 90}, true},
+		{"window not yet full", 3, []int64{0, 0}, false},
+		{"single large shortfall", 3, []int64{100, 100, 50}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := newRelayBlacklist(0.05, tt.window, 0, NewStore(), nil, testLog)
+			for _, paid := range tt.paid {
+				b.record("http://relay", big.NewInt(100), big.NewInt(paid))
+			}
+			require.Equal(t, tt.wantSuspended, b.isSuspended("http://relay"))
+			require.Equal(t, false, b.isSuspended("http://other"))
+		})
+	}
+}
+
+func Test_relayBlacklist_expiry(t *testing.T) {
+	defer func() { now = time.Now }()
+	start := time.Unix(1000, 0)
+	now = func() time.Time { return start }
+
+	store := NewStore()
+	b := newRelayBlacklist(0.05, 2, time.Hour, store, nil, testLog)
+	b.record("http://relay", big.NewInt(100), big.NewInt(0))
+	b.record("http://relay", big.NewInt(100), big.NewInt(0))
+	require.True(t, b.isSuspended("http://relay"))
+
+	now = func() time.Time { return start.Add(time.Hour - time.Second) }
+	require.True(t, b.isSuspended("http://relay"))
+
+	now = func() time.Time { return start.Add(time.Hour) }
+	require.False(t, b.isSuspended("http://relay"))
+	reputation, err := store.GetRelayReputation(context.Background(), "http://relay")
+	require.Nil(t, err)
+	require.Nil(t, reputation.SuspendedAt)
+	require.Len(t, reputation.Payments, 0)
+
+	// the relay has to underpay a full window again
+	b.record("http://relay", big.NewInt(100), big.NewInt(0))
+	require.False(t, b.isSuspended("http://relay"))
+	b.record("http://relay", big.NewInt(100), big.NewInt(0))
+	require.True(t, b.isSuspended("http://relay"))
+}
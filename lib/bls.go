@@ -0,0 +1,119 @@
+package lib
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/crypto/bls12381"
+	"github.com/flashbots/mev-boost/lib/internal/bls"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// maxCachedSignatures bounds the verification results kept by VerifySignature, the oldest are evicted beyond it
+var maxCachedSignatures = 4096
+
+var signatureVerificationsTotal = metricsFactory.NewCounterVec(prometheus.CounterOpts{
+	Name: "mevboost_signature_verifications_total",
+	Help: "BLS signature verifications by result: cached, answered without a pairing, or computed",
+}, []string{"result"})
+
+// signatureKey identifies a verification by everything its result depends on
+type signatureKey struct {
+	pubkey      [48]byte
+	signingRoot [32]byte
+	signature   [96]byte
+}
+
+// signatureCache keeps the results of recent verifications, so a signature sent again, e.g. a blinded block retried by
+// the consensus client during a busy slot, doesn't redo the pairing
+type signatureCache struct {
+	mu      sync.Mutex
+	results map[signatureKey]bool
+	keys    []signatureKey // in the order they were added
+}
+
+var verifiedSignatures = &signatureCache{results: make(map[signatureKey]bool)}
+
+func (c *signatureCache) get(key signatureKey) (ok, found bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	ok, found = c.results[key]
+	return ok, found
+}
+
+func (c *signatureCache) add(key signatureKey, ok bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, found := c.results[key]; found {
+		return
+	}
+	c.results[key] = ok
+	c.keys = append(c.keys, key)
+	if len(c.keys) > maxCachedSignatures {
+		delete(c.results, c.keys[0])
+		c.keys = c.keys[1:]
+	}
+}
+
+// VerifySignature checks a BLS signature of the consensus specs: signature is a compressed G2 point signing signingRoot
+// with the key of pubkey, a compressed G1 point. An error is returned if pubkey or signature aren't valid points.
+// The results of recent verifications are cached.
+func VerifySignature(pubkey []byte, signingRoot [32]byte, signature []byte) (bool, error) {
+	if len(pubkey) != 48 || len(signature) != 96 {
+		return verifySignature(pubkey, signingRoot, signature)
+	}
+	key := signatureKey{signingRoot: signingRoot}
+	copy(key.pubkey[:], pubkey)
+	copy(key.signature[:], signature)
+	if ok, found := verifiedSignatures.get(key); found {
+		signatureVerificationsTotal.WithLabelValues("cached").Inc()
+		return ok, nil
+	}
+
+	ok, err := verifySignature(pubkey, signingRoot, signature)
+	if err != nil {
+		return false, err
+	}
+	signatureVerificationsTotal.WithLabelValues("computed").Inc()
+	verifiedSignatures.add(key, ok)
+	return ok, nil
+}
+
+func verifySignature(pubkey []byte, signingRoot [32]byte, signature []byte) (bool, error) {
+	engine := bls12381.NewPairingEngine()
+
+	pk, err := bls.DecompressG1(engine.G1, pubkey)
+	if err != nil {
+		return false, fmt.Errorf("invalid pubkey: %w", err)
+	}
+	if engine.G1.IsZero(pk) {
+		return false, errors.New("invalid pubkey: point at infinity")
+	}
+	sig, err := bls.DecompressG2(engine.G2, signature)
+	if err != nil {
+		return false, fmt.Errorf("invalid signature: %w", err)
+	}
+	msg, err := bls.HashToG2(engine.G2, signingRoot[:], bls.SignatureDST)
+	if err != nil {
+		return false, err
+	}
+
+	// e(pk, H(m)) == e(g1, sig)
+	engine.AddPair(pk, msg)
+	engine.AddPairInv(engine.G1.One(), sig)
+	return engine.Check(), nil
+}
+
+// ValidatePubkey checks that pubkey is a compressed BLS public key, i.e. a valid point of G1 other than infinity
+func ValidatePubkey(pubkey []byte) error {
+	g1 := bls12381.NewG1()
+	pk, err := bls.DecompressG1(g1, pubkey)
+	if err != nil {
+		return err
+	}
+	if g1.IsZero(pk) {
+		return errors.New("point at infinity")
+	}
+	return nil
+}
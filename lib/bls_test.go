@@ -0,0 +1,90 @@
+package lib
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/flashbots/mev-boost/lib/internal/blstest"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+// blsSign signs root with secretKey and returns the compressed pubkey and signature
+func blsSign(secretKey *big.Int, root [32]byte) (pubkey []byte, signature []byte) {
+	pubkey, signature, err := blstest.Sign(secretKey, root)
+	if err != nil {
+		panic(err)
+	}
+	return pubkey, signature
+}
+
+func TestVerifySignature(t *testing.T) {
+	// sign test case of the consensus spec BLS tests
+	pubkey := common.FromHex("0xa491d1b0ecd9bb917989f0e74f0dea0422eac4a873e5e2644f368dffb9a6e20fd6e10c1b77654d067c0618f6e5a7f79a")
+	message := common.HexToHash("0xabababababababababababababababababababababababababababababababab")
+	signature := common.FromHex("0x91347bccf740d859038fcdcaf233eeceb2a436bcaaee9b2aa3bfb70efe29dfb2677562ccbea1c8e061fb9971b0753c240622fab78489ce96768259fc01360346da5b9f579e5da0d941e4c6ba18a0e64906082375394f337fa1af2b7127b0d121")
+
+	secretKey, _ := new(big.Int).SetString("263dbd792f5b1be47ed85f8938c0f29586af0d3ac7b977f21c278fe1462040e3", 16)
+	signedPubkey, signed := blsSign(secretKey, message)
+	require.Equal(t, pubkey, signedPubkey)
+	require.Equal(t, signature, signed)
+
+	ok, err := VerifySignature(pubkey, message, signature)
+	require.Nil(t, err)
+	require.True(t, ok)
+
+	ok, err = VerifySignature(pubkey, common.HexToHash("0x01"), signature)
+	require.Nil(t, err)
+	require.False(t, ok, "signature of another message")
+
+	otherPubkey, _ := blsSign(big.NewInt(42), message)
+	ok, err = VerifySignature(otherPubkey, message, signature)
+	require.Nil(t, err)
+	require.False(t, ok, "signature of another key")
+
+	_, err = VerifySignature(pubkey[:47], message, signature)
+	require.Error(t, err)
+	_, err = VerifySignature(pubkey, message, make([]byte, 96))
+	require.Error(t, err, "uncompressed signature")
+	infinity := make([]byte, 48)
+	infinity[0] = 0xc0
+	_, err = VerifySignature(infinity, message, signature)
+	require.Error(t, err)
+}
+
+func TestVerifySignature_Cache(t *testing.T) {
+	defer func(max int) { maxCachedSignatures = max }(maxCachedSignatures)
+	maxCachedSignatures = 1
+	verifiedSignatures = &signatureCache{results: make(map[signatureKey]bool)}
+	cached, computed := signatureVerificationsTotal.WithLabelValues("cached"), signatureVerificationsTotal.WithLabelValues("computed")
+	cachedBefore, computedBefore := testutil.ToFloat64(cached), testutil.ToFloat64(computed)
+
+	message := common.HexToHash("0xabababababababababababababababababababababababababababababababab")
+	pubkey, signature := blsSign(big.NewInt(42), message)
+	for i := 0; i < 2; i++ {
+		ok, err := VerifySignature(pubkey, message, signature)
+		require.Nil(t, err)
+		require.True(t, ok)
+		ok, err = VerifySignature(pubkey, common.HexToHash("0x01"), signature)
+		require.Nil(t, err)
+		require.False(t, ok)
+	}
+	require.Equal(t, float64(4), testutil.ToFloat64(computed)-computedBefore, "the other message evicts the result")
+
+	ok, err := VerifySignature(pubkey, common.HexToHash("0x01"), signature)
+	require.Nil(t, err)
+	require.False(t, ok)
+	require.Equal(t, float64(1), testutil.ToFloat64(cached)-cachedBefore)
+}
+
+func TestValidatePubkey(t *testing.T) {
+	require.Nil(t, ValidatePubkey(common.FromHex("0xa491d1b0ecd9bb917989f0e74f0dea0422eac4a873e5e2644f368dffb9a6e20fd6e10c1b77654d067c0618f6e5a7f79a")))
+
+	require.Error(t, ValidatePubkey(common.FromHex("0xa491d1b0")), "too short")
+	notOnCurve := common.FromHex("0xa491d1b0ecd9bb917989f0e74f0dea0422eac4a873e5e2644f368dffb9a6e20fd6e10c1b77654d067c0618f6e5a7f79b")
+	require.Error(t, ValidatePubkey(notOnCurve))
+	infinity := make([]byte, 48)
+	infinity[0] = 0xc0
+	require.Error(t, ValidatePubkey(infinity))
+}
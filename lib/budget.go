@@ -0,0 +1,21 @@
+package lib
+
+import (
+	"context"
+	"net/http"
+)
+
+// withBudget bounds a consensus client call by the request budget. All relay requests of the call share it, including
+// fallbacks like fetching headers after waiting for a prefetch, so together they never take longer than the budget.
+func (m *RelayService) withBudget(ctx context.Context) (context.Context, context.CancelFunc) {
+	if m.requestBudget <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, m.requestBudget)
+}
+
+// clientGone returns the error of the consensus client request if the client disconnected, as opposed to the budget
+// running out
+func clientGone(req *http.Request) error {
+	return requestContext(req).Err()
+}
@@ -0,0 +1,33 @@
+package lib
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRelayService_RequestBudget(t *testing.T) {
+	blockRelay := make(chan struct{})
+	relay := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-blockRelay
+	}))
+	defer relay.Close()
+	defer close(blockRelay)
+
+	store := NewStore()
+	store.SetForkchoiceResponse(context.Background(), "0x0102030405060708", relay.URL, "0x01")
+	service, err := newRelayService(WithRelayURLs(relay.URL), WithStore(store), WithLogger(testLog),
+		WithHeaderPrefetch(NewBeaconClient("http://beacon")), WithRequestBudget(100*time.Millisecond))
+	require.Nil(t, err)
+	service.prefetcher.start("0x0102030405060708", 1) // a prefetch that never finishes
+
+	start := time.Now()
+	payloadID := "0x0102030405060708"
+	err = service.GetPayloadHeaderV1(nil, &payloadID, new(ExecutionPayloadWithTxRootV1))
+	require.ErrorIs(t, err, ErrRelayTimeout)
+	require.Less(t, time.Since(start), 500*time.Millisecond, "waiting for the prefetch and the fallback share the budget")
+}
@@ -0,0 +1,204 @@
+package lib
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/gorilla/mux"
+)
+
+// Routes of the builder REST API, see https://github.com/ethereum/builder-specs
+const (
+	pathBuilderHeader        = "/eth/v1/builder/header/{slot}/{parent_hash}/{pubkey}"
+	pathBuilderBlindedBlocks = "/eth/v1/builder/blinded_blocks"
+	pathBuilderValidators    = "/eth/v1/builder/validators"
+
+	// builderAPIVersion is the fork of the responses of the builder REST API
+	builderAPIVersion = "bellatrix"
+)
+
+// builderAPIError is the error response of the builder REST API
+type builderAPIError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func respondBuilderError(w http.ResponseWriter, code int, format string, args ...interface{}) {
+	respondJSON(w, code, builderAPIError{Code: code, Message: fmt.Sprintf(format, args...)})
+}
+
+// builderAPIResponse wraps the responses of the builder REST API with the fork they belong to
+type builderAPIResponse struct {
+	Version string      `json:"version"`
+	Data    interface{} `json:"data"`
+}
+
+// builderBidJSON is the beacon API encoding of a SignedBuilderBid
+type builderBidJSON struct {
+	Message struct {
+		Header *ExecutionPayloadHeaderV1 `json:"header"`
+		Value  string                    `json:"value"`
+		Pubkey BLSPubkey                 `json:"pubkey"`
+	} `json:"message"`
+	Signature hexutil.Bytes `json:"signature"`
+}
+
+// executionPayloadJSON is the beacon API encoding of an execution payload
+type executionPayloadJSON struct {
+	ParentHash    common.Hash    `json:"parent_hash"`
+	FeeRecipient  common.Address `json:"fee_recipient"`
+	StateRoot     common.Hash    `json:"state_root"`
+	ReceiptsRoot  common.Hash    `json:"receipts_root"`
+	LogsBloom     hexutil.Bytes  `json:"logs_bloom"`
+	PrevRandao    common.Hash    `json:"prev_randao"`
+	BlockNumber   uint64         `json:"block_number,string"`
+	GasLimit      uint64         `json:"gas_limit,string"`
+	GasUsed       uint64         `json:"gas_used,string"`
+	Timestamp     uint64         `json:"timestamp,string"`
+	ExtraData     hexutil.Bytes  `json:"extra_data"`
+	BaseFeePerGas string         `json:"base_fee_per_gas"`
+	BlockHash     common.Hash    `json:"block_hash"`
+	Transactions  []string       `json:"transactions"`
+}
+
+func newExecutionPayloadJSON(p *ExecutionPayloadWithTxRootV1) *executionPayloadJSON {
+	payload := &executionPayloadJSON{
+		ParentHash:    p.ParentHash,
+		FeeRecipient:  p.FeeRecipient,
+		StateRoot:     p.StateRoot,
+		ReceiptsRoot:  p.ReceiptsRoot,
+		LogsBloom:     p.LogsBloom,
+		PrevRandao:    p.PrevRandao,
+		BlockNumber:   p.Number,
+		GasLimit:      p.GasLimit,
+		GasUsed:       p.GasUsed,
+		Timestamp:     p.Timestamp,
+		ExtraData:     p.ExtraData,
+		BaseFeePerGas: "0",
+		BlockHash:     p.BlockHash,
+		Transactions:  []string{},
+	}
+	if p.BaseFeePerGas != nil {
+		payload.BaseFeePerGas = p.BaseFeePerGas.String()
+	}
+	if p.Transactions != nil {
+		payload.Transactions = *p.Transactions
+	}
+	return payload
+}
+
+// handleGetHeader serves the header of a slot on a parent with the bid signature of its relay. The header is the one
+// getPayloadHeader returns for the payload id of the latest forkchoiceUpdated call with payload attributes for the slot on
+// the parent, consensus clients still send forkchoiceUpdated through mev-boost to start the relays building. Without
+// such a call or a signed bid it answers 204 No Content, and the consensus client builds the block itself.
+func (m *RelayService) handleGetHeader(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	slot, err := strconv.ParseUint(vars["slot"], 10, 64)
+	if err != nil {
+		respondBuilderError(w, http.StatusBadRequest, "invalid slot %q", vars["slot"])
+		return
+	}
+	parentHash, err := hexutil.Decode(vars["parent_hash"])
+	if err != nil || len(parentHash) != common.HashLength {
+		respondBuilderError(w, http.StatusBadRequest, "invalid parent hash %q", vars["parent_hash"])
+		return
+	}
+	pubkey, err := hexutil.Decode(vars["pubkey"])
+	if err == nil {
+		err = ValidatePubkey(pubkey)
+	}
+	if err != nil {
+		respondBuilderError(w, http.StatusBadRequest, "invalid pubkey %q", vars["pubkey"])
+		return
+	}
+	logMethod := withTraceFields(r.Context(), m.log.WithFields(Fields{"method": "getHeader", "slot": slot, "parentHash": common.BytesToHash(parentHash), "pubkey": hexutil.Encode(pubkey)}))
+
+	payloadID, ok := m.heads.payloadID(slot, common.BytesToHash(parentHash))
+	if !ok {
+		logMethod.Warn("getHeader: no forkchoiceUpdated call with payload attributes for the slot on the parent, no header")
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	header := new(ExecutionPayloadWithTxRootV1)
+	if err := m.GetPayloadHeaderV1(r, &payloadID, header); err != nil {
+		var methodErr *MethodError
+		if errors.As(err, &methodErr) && (errors.Is(methodErr.Kind, ErrChainStateMismatch) || errors.Is(methodErr.Kind, ErrStalePayloadID) || errors.Is(methodErr.Kind, ErrEquivocation)) {
+			respondBuilderError(w, http.StatusBadRequest, "%v", err)
+			return
+		}
+		logMethod.WithError(err).Info("getHeader: no header")
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	if header.BlockHash == nilHash {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	bid := m.store.GetBid(r.Context(), header.BlockHash)
+	var relayPubkey BLSPubkey
+	if bid != nil && bid.Signature != nil {
+		var pubkeys map[string]BLSPubkey
+		if pubkeys, err = relayPubkeys([]string{bid.RelayURL}); err == nil {
+			relayPubkey = pubkeys[bid.RelayURL]
+		}
+	}
+	if bid == nil || bid.Signature == nil || err != nil {
+		logMethod.WithField("blockHash", header.BlockHash).Warn("getHeader: the selected header has no relay signature, no header")
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	var signed builderBidJSON
+	signed.Message.Header = header.Header()
+	signed.Message.Value = bidValue(header).String()
+	signed.Message.Pubkey = relayPubkey
+	signed.Signature = bid.Signature
+	respondJSON(w, http.StatusOK, builderAPIResponse{Version: builderAPIVersion, Data: signed})
+}
+
+// handleSubmitBlindedBlock reveals the payload of a signed blinded block, like proposeBlindedBlock
+func (m *RelayService) handleSubmitBlindedBlock(w http.ResponseWriter, r *http.Request) {
+	block := new(SignedBlindedBeaconBlock)
+	if err := json.NewDecoder(r.Body).Decode(block); err != nil {
+		respondBuilderError(w, http.StatusBadRequest, "invalid signed blinded block: %v", err)
+		return
+	}
+	if block.Message == nil || block.Message.Body == nil || block.Message.Body.ExecutionPayloadHeader == nil {
+		respondBuilderError(w, http.StatusBadRequest, "signed blinded block has no execution payload header")
+		return
+	}
+
+	payload := new(ExecutionPayloadWithTxRootV1)
+	if err := m.ProposeBlindedBlockV1(r, block, payload); err != nil {
+		code := http.StatusInternalServerError
+		var methodErr *MethodError
+		if errors.As(err, &methodErr) && (errors.Is(methodErr.Kind, ErrInvalidSignature) || errors.Is(methodErr.Kind, ErrUnknownPayload) || errors.Is(methodErr.Kind, ErrHeaderMismatch) ||
+			errors.Is(methodErr.Kind, ErrEquivocation)) {
+			code = http.StatusBadRequest
+		}
+		respondBuilderError(w, code, "%v", err)
+		return
+	}
+	respondJSON(w, http.StatusOK, builderAPIResponse{Version: builderAPIVersion, Data: newExecutionPayloadJSON(payload)})
+}
+
+// handleRegisterValidators caches and broadcasts validator registrations, like registerValidator
+func (m *RelayService) handleRegisterValidators(w http.ResponseWriter, r *http.Request) {
+	var registrations []SignedValidatorRegistrationV1
+	if err := json.NewDecoder(r.Body).Decode(&registrations); err != nil {
+		respondBuilderError(w, http.StatusBadRequest, "invalid validator registrations: %v", err)
+		return
+	}
+	var result string
+	if err := m.RegisterValidatorV1(r, &registrations, &result); err != nil {
+		respondBuilderError(w, http.StatusBadRequest, "%v", err)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
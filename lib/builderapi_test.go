@@ -0,0 +1,82 @@
+package lib
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBuilderAPI_GetHeader(t *testing.T) {
+	parent := common.HexToHash("0xaa")
+	header := ExecutionPayloadWithTxRootV1{ParentHash: parent, BlockHash: common.HexToHash("0x01"), BaseFeePerGas: big.NewInt(1), FeeRecipientDiff: big.NewInt(7)}
+	relay, relayURL := newSignedHeaderRelay(t, big.NewInt(101), header, func(signature []byte) interface{} { return hexutil.Bytes(signature) })
+	defer relay.Close()
+
+	store := NewStore()
+	store.SetForkchoiceResponse(context.Background(), "0x01", relayURL, "0x01")
+	service, err := newRelayService(WithRelayURLs(relayURL), WithStore(store), WithLogger(testLog), WithBidSignatureVerification())
+	require.Nil(t, err)
+	service.heads.set("0x01", 10, parent)
+
+	router := mux.NewRouter()
+	router.HandleFunc(pathBuilderHeader, service.handleGetHeader)
+	router.HandleFunc(pathBuilderBlindedBlocks, service.handleSubmitBlindedBlock)
+	router.HandleFunc(pathBuilderValidators, service.handleRegisterValidators)
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, httptest.NewRequest(method, path, strings.NewReader(body)))
+		return rr
+	}
+	proposer, _ := blsSign(big.NewInt(5), [32]byte{})
+	pubkey := hexutil.Encode(proposer)
+
+	rr := do(http.MethodGet, fmt.Sprintf("/eth/v1/builder/header/10/%s/%s", parent.Hex(), pubkey), "")
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	var response struct {
+		Version string         `json:"version"`
+		Data    builderBidJSON `json:"data"`
+	}
+	require.Nil(t, json.Unmarshal(rr.Body.Bytes(), &response))
+	require.Equal(t, builderAPIVersion, response.Version)
+	require.Equal(t, header.BlockHash, response.Data.Message.Header.BlockHash)
+	require.Equal(t, "7", response.Data.Message.Value)
+	require.Len(t, response.Data.Signature, 96)
+
+	rr = do(http.MethodGet, fmt.Sprintf("/eth/v1/builder/header/11/%s/%s", parent.Hex(), pubkey), "")
+	require.Equal(t, http.StatusNoContent, rr.Code, "no forkchoiceUpdated call for the slot")
+	rr = do(http.MethodGet, fmt.Sprintf("/eth/v1/builder/header/10/0xaa/%s", pubkey), "")
+	require.Equal(t, http.StatusBadRequest, rr.Code)
+	var apiErr builderAPIError
+	require.Nil(t, json.Unmarshal(rr.Body.Bytes(), &apiErr))
+	require.Equal(t, http.StatusBadRequest, apiErr.Code)
+
+	rr = do(http.MethodPost, pathBuilderBlindedBlocks, `{"message":{"slot":"1"}}`)
+	require.Equal(t, http.StatusBadRequest, rr.Code)
+	rr = do(http.MethodPost, pathBuilderValidators, `{}`)
+	require.Equal(t, http.StatusBadRequest, rr.Code)
+}
+
+func TestNewRouter_BuilderAPI(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	relayKey, _ := blsSign(big.NewInt(101), [32]byte{})
+	relayURL := "http://" + hexutil.Encode(relayKey) + "@relay"
+	_, err := NewRouter(ctx, WithRelayURLs(relayURL), WithLogger(testLog), WithCapabilityCheckInterval(0), WithBuilderAPI())
+	require.Error(t, err, "bid signatures aren't verified")
+
+	router, err := NewRouter(ctx, WithRelayURLs(relayURL), WithLogger(testLog), WithCapabilityCheckInterval(0), WithBuilderAPI(), WithBidSignatureVerification())
+	require.Nil(t, err)
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, pathSpecStatus, nil))
+	require.Equal(t, http.StatusOK, rr.Code)
+}
@@ -0,0 +1,223 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.2.0
+// - protoc             v3.21.12
+// source: builder.proto
+
+package builderpb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.32.0 or later.
+const _ = grpc.SupportPackageIsVersion7
+
+// BuilderClient is the client API for Builder service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type BuilderClient interface {
+	// Register forwards the fork choice and payload attributes of the next proposal to the relays, like
+	// engine_forkchoiceUpdatedV1, and returns the payload id to get its header with
+	Register(ctx context.Context, in *RegisterRequest, opts ...grpc.CallOption) (*RegisterResponse, error)
+	// GetHeader returns the most valuable header of the relays for a payload id, like builder_getPayloadHeaderV1
+	GetHeader(ctx context.Context, in *GetHeaderRequest, opts ...grpc.CallOption) (*ExecutionPayloadHeader, error)
+	// SubmitBlindedBlock reveals the payload of a signed blinded block, like builder_proposeBlindedBlockV1
+	SubmitBlindedBlock(ctx context.Context, in *SignedBlindedBeaconBlock, opts ...grpc.CallOption) (*ExecutionPayload, error)
+	// Status returns the relays and whether they are currently used
+	Status(ctx context.Context, in *StatusRequest, opts ...grpc.CallOption) (*StatusResponse, error)
+}
+
+type builderClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewBuilderClient(cc grpc.ClientConnInterface) BuilderClient {
+	return &builderClient{cc}
+}
+
+func (c *builderClient) Register(ctx context.Context, in *RegisterRequest, opts ...grpc.CallOption) (*RegisterResponse, error) {
+	out := new(RegisterResponse)
+	err := c.cc.Invoke(ctx, "/mevboost.builder.v1.Builder/Register", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *builderClient) GetHeader(ctx context.Context, in *GetHeaderRequest, opts ...grpc.CallOption) (*ExecutionPayloadHeader, error) {
+	out := new(ExecutionPayloadHeader)
+	err := c.cc.Invoke(ctx, "/mevboost.builder.v1.Builder/GetHeader", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *builderClient) SubmitBlindedBlock(ctx context.Context, in *SignedBlindedBeaconBlock, opts ...grpc.CallOption) (*ExecutionPayload, error) {
+	out := new(ExecutionPayload)
+	err := c.cc.Invoke(ctx, "/mevboost.builder.v1.Builder/SubmitBlindedBlock", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *builderClient) Status(ctx context.Context, in *StatusRequest, opts ...grpc.CallOption) (*StatusResponse, error) {
+	out := new(StatusResponse)
+	err := c.cc.Invoke(ctx, "/mevboost.builder.v1.Builder/Status", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// BuilderServer is the server API for Builder service.
+// All implementations must embed UnimplementedBuilderServer
+// for forward compatibility
+type BuilderServer interface {
+	// Register forwards the fork choice and payload attributes of the next proposal to the relays, like
+	// engine_forkchoiceUpdatedV1, and returns the payload id to get its header with
+	Register(context.Context, *RegisterRequest) (*RegisterResponse, error)
+	// GetHeader returns the most valuable header of the relays for a payload id, like builder_getPayloadHeaderV1
+	GetHeader(context.Context, *GetHeaderRequest) (*ExecutionPayloadHeader, error)
+	// SubmitBlindedBlock reveals the payload of a signed blinded block, like builder_proposeBlindedBlockV1
+	SubmitBlindedBlock(context.Context, *SignedBlindedBeaconBlock) (*ExecutionPayload, error)
+	// Status returns the relays and whether they are currently used
+	Status(context.Context, *StatusRequest) (*StatusResponse, error)
+	mustEmbedUnimplementedBuilderServer()
+}
+
+// UnimplementedBuilderServer must be embedded to have forward compatible implementations.
+type UnimplementedBuilderServer struct {
+}
+
+func (UnimplementedBuilderServer) Register(context.Context, *RegisterRequest) (*RegisterResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Register not implemented")
+}
+func (UnimplementedBuilderServer) GetHeader(context.Context, *GetHeaderRequest) (*ExecutionPayloadHeader, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetHeader not implemented")
+}
+func (UnimplementedBuilderServer) SubmitBlindedBlock(context.Context, *SignedBlindedBeaconBlock) (*ExecutionPayload, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SubmitBlindedBlock not implemented")
+}
+func (UnimplementedBuilderServer) Status(context.Context, *StatusRequest) (*StatusResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Status not implemented")
+}
+func (UnimplementedBuilderServer) mustEmbedUnimplementedBuilderServer() {}
+
+// UnsafeBuilderServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to BuilderServer will
+// result in compilation errors.
+type UnsafeBuilderServer interface {
+	mustEmbedUnimplementedBuilderServer()
+}
+
+func RegisterBuilderServer(s grpc.ServiceRegistrar, srv BuilderServer) {
+	s.RegisterService(&Builder_ServiceDesc, srv)
+}
+
+func _Builder_Register_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RegisterRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(BuilderServer).Register(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/mevboost.builder.v1.Builder/Register",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(BuilderServer).Register(ctx, req.(*RegisterRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Builder_GetHeader_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetHeaderRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(BuilderServer).GetHeader(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/mevboost.builder.v1.Builder/GetHeader",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(BuilderServer).GetHeader(ctx, req.(*GetHeaderRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Builder_SubmitBlindedBlock_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SignedBlindedBeaconBlock)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(BuilderServer).SubmitBlindedBlock(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/mevboost.builder.v1.Builder/SubmitBlindedBlock",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(BuilderServer).SubmitBlindedBlock(ctx, req.(*SignedBlindedBeaconBlock))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Builder_Status_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(StatusRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(BuilderServer).Status(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/mevboost.builder.v1.Builder/Status",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(BuilderServer).Status(ctx, req.(*StatusRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// Builder_ServiceDesc is the grpc.ServiceDesc for Builder service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var Builder_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "mevboost.builder.v1.Builder",
+	HandlerType: (*BuilderServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Register",
+			Handler:    _Builder_Register_Handler,
+		},
+		{
+			MethodName: "GetHeader",
+			Handler:    _Builder_GetHeader_Handler,
+		},
+		{
+			MethodName: "SubmitBlindedBlock",
+			Handler:    _Builder_SubmitBlindedBlock_Handler,
+		},
+		{
+			MethodName: "Status",
+			Handler:    _Builder_Status_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "builder.proto",
+}
@@ -0,0 +1,4 @@
+// Package builderpb contains the protobuf messages and gRPC service of the builder API
+package builderpb
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative builder.proto
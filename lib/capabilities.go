@@ -0,0 +1,243 @@
+package lib
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"math/big"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+const (
+	// builderSpecVersion is the version of the builder spec mev-boost implements
+	builderSpecVersion = "0.1"
+
+	methodForkchoiceUpdated      = "engine_forkchoiceUpdatedV1"
+	methodRelayGetHeader         = "relay_getPayloadHeaderV1"
+	methodRelayProposeBlock      = "relay_proposeBlindedBlockV1"
+	methodRelayGetCapabilities   = "relay_getCapabilitiesV1"
+	methodRelayRegisterValidator = "relay_registerValidatorV1"
+
+	// JSON-RPC error code of a method the server doesn't implement
+	rpcErrMethodNotFound = -32601
+	// JSON-RPC error code of invalid method params
+	rpcErrInvalidParams = -32602
+)
+
+// relayMethods are the methods mev-boost calls on relays, the V2 ones for payloads of capella or later
+var relayMethods = []string{
+	methodForkchoiceUpdated, methodRelayGetHeader, methodRelayProposeBlock, methodRelayRegisterValidator,
+	methodForkchoiceUpdatedV2, methodRelayGetHeaderV2, methodRelayProposeBlockV2,
+}
+
+// PayloadEncoding is the JSON encoding of the payloads and headers a relay returns
+type PayloadEncoding string
+
+// Payload encodings of RelayCapabilities
+const (
+	// PayloadEncodingEngine is the encoding of the engine API, with camelCase field names and hex quantities
+	PayloadEncodingEngine PayloadEncoding = "engine"
+	// PayloadEncodingBeacon is the encoding of the beacon API, with snake_case field names and decimal quantities
+	PayloadEncodingBeacon PayloadEncoding = "beacon"
+)
+
+// RelayCapabilities is the response of relay_getCapabilitiesV1
+type RelayCapabilities struct {
+	SpecVersion string   `json:"specVersion" gencodec:"required"`
+	Methods     []string `json:"methods" gencodec:"required"`
+	// MinBid is the bid floor of the relay in wei, if it advertises one: it rejects header requests of proposers whose min
+	// bid is lower
+	MinBid *big.Int `json:"minBid,omitempty"`
+	// GenesisForkVersion identifies the network the relay serves, relays of another network aren't used
+	GenesisForkVersion string `json:"genesisForkVersion,omitempty"`
+	// Encoding is the encoding of the results of the relay, the engine API encoding if it's empty
+	Encoding PayloadEncoding `json:"encoding,omitempty"`
+}
+
+// relayCapabilities keeps the methods each relay supports, its bid floor and the encoding of its results. Relays that
+// didn't report capabilities are assumed to support all methods without a floor.
+type relayCapabilities struct {
+	mu        sync.RWMutex
+	methods   map[string]map[string]bool // map[relayURL]map[method]supported
+	floors    map[string]*big.Int        // map[relayURL]advertised min bid
+	encodings map[string]PayloadEncoding // map[relayURL]advertised encoding, if it isn't the engine API encoding
+}
+
+func newRelayCapabilities() *relayCapabilities {
+	return &relayCapabilities{
+		methods:   make(map[string]map[string]bool),
+		floors:    make(map[string]*big.Int),
+		encodings: make(map[string]PayloadEncoding),
+	}
+}
+
+func (c *relayCapabilities) set(relayURL string, capabilities *RelayCapabilities) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if capabilities == nil {
+		delete(c.methods, relayURL)
+		delete(c.floors, relayURL)
+		delete(c.encodings, relayURL)
+		return
+	}
+	if capabilities.MinBid != nil && capabilities.MinBid.Sign() > 0 {
+		c.floors[relayURL] = capabilities.MinBid
+	} else {
+		delete(c.floors, relayURL)
+	}
+	if capabilities.Encoding == PayloadEncodingBeacon {
+		c.encodings[relayURL] = capabilities.Encoding
+	} else {
+		delete(c.encodings, relayURL)
+	}
+
+	methods := make(map[string]bool, len(capabilities.Methods))
+	for _, method := range capabilities.Methods {
+		methods[method] = true
+	}
+	c.methods[relayURL] = methods
+}
+
+func (c *relayCapabilities) supports(relayURL, method string) bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	methods, ok := c.methods[relayURL]
+	if !ok {
+		return true
+	}
+	return methods[method]
+}
+
+// exclude marks a relay as supporting no methods, until it reports compatible capabilities
+func (c *relayCapabilities) exclude(relayURL string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.methods[relayURL] = map[string]bool{}
+	delete(c.floors, relayURL)
+	delete(c.encodings, relayURL)
+}
+
+// minBid returns the bid floor a relay advertised, nil if it has none
+func (c *relayCapabilities) minBid(relayURL string) *big.Int {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.floors[relayURL]
+}
+
+// encoding returns the encoding of the results of a relay
+func (c *relayCapabilities) encoding(relayURL string) PayloadEncoding {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if encoding, ok := c.encodings[relayURL]; ok {
+		return encoding
+	}
+	return PayloadEncodingEngine
+}
+
+// relayCompatibility returns an error if the capabilities of a relay show it serves another network, implements an
+// incompatible builder spec version or returns results in an encoding mev-boost doesn't decode. Relays that don't
+// report their network are assumed to serve the one of mev-boost.
+func (m *RelayService) relayCompatibility(capabilities *RelayCapabilities) error {
+	if capabilities.GenesisForkVersion != "" {
+		version, err := hexutil.Decode(capabilities.GenesisForkVersion)
+		if err != nil || len(version) != len(m.chain.GenesisForkVersion) {
+			return fmt.Errorf("invalid genesis fork version %q", capabilities.GenesisForkVersion)
+		}
+		if !bytes.Equal(version, m.chain.GenesisForkVersion[:]) {
+			return fmt.Errorf("relay serves the network with genesis fork version %s, not %s", capabilities.GenesisForkVersion, hexutil.Encode(m.chain.GenesisForkVersion[:]))
+		}
+	}
+	if !compatibleSpecVersions(capabilities.SpecVersion, builderSpecVersion) {
+		return fmt.Errorf("builder spec version %s is incompatible with %s", capabilities.SpecVersion, builderSpecVersion)
+	}
+	switch capabilities.Encoding {
+	case "", PayloadEncodingEngine, PayloadEncodingBeacon:
+	default:
+		return fmt.Errorf("unknown encoding %q", capabilities.Encoding)
+	}
+	return nil
+}
+
+// compatibleSpecVersions reports whether two builder spec versions are compatible by semver: their major versions
+// match, and their minor versions too while the major version is 0. Versions that aren't numbered like that are
+// assumed to be compatible.
+func compatibleSpecVersions(a, b string) bool {
+	partsA, partsB := strings.Split(a, "."), strings.Split(b, ".")
+	if len(partsA) < 2 || len(partsB) < 2 {
+		return true
+	}
+	if partsA[0] != partsB[0] {
+		return false
+	}
+	return partsA[0] != "0" || partsA[1] == partsB[1]
+}
+
+// checkRelayCapabilities queries each relay for the methods and spec version it supports and the network it serves,
+// disables methods a relay doesn't support and excludes relays that are incompatible
+func (m *RelayService) checkRelayCapabilities(ctx context.Context) {
+	var wg sync.WaitGroup
+	for _, url := range m.relays.all() {
+		wg.Add(1)
+		go func(url string) {
+			defer wg.Done()
+			log := m.log.WithFields(Fields{"method": methodRelayGetCapabilities, "url": url})
+
+			var res *rpcResponse
+			err := m.endpoints.call(ctx, url, m.log, func(endpoint string) (err error) {
+				res, err = makeRequest(ctx, m.client, endpoint, methodRelayGetCapabilities, []interface{}{}, m.responseLimits.forMethod(methodRelayGetCapabilities))
+				return err
+			})
+			if err != nil {
+				log.WithError(err).Warn("could not query relay capabilities")
+				return
+			}
+			if res.Error != nil {
+				if res.Error.Code == rpcErrMethodNotFound {
+					log.Debug("relay doesn't report capabilities, assuming all methods are supported")
+				} else {
+					log.WithField("error", res.Error).Warn("error reply from relay")
+				}
+				m.capabilities.set(url, nil)
+				return
+			}
+
+			capabilities := new(RelayCapabilities)
+			if err := m.decoder.decode(url, res.Result, capabilities); err != nil {
+				log.WithFields(Fields{"error": err, "data": string(res.Result)}).Warn("could not unmarshal relay capabilities")
+				return
+			}
+			if err := m.relayCompatibility(capabilities); err != nil {
+				log.WithError(err).Error("relay is incompatible, it won't be used")
+				m.capabilities.exclude(url)
+				return
+			}
+			m.capabilities.set(url, capabilities)
+
+			if capabilities.SpecVersion != builderSpecVersion {
+				log.WithFields(Fields{
+					"relayVersion": capabilities.SpecVersion,
+					"version":      builderSpecVersion,
+				}).Warn("relay implements a different builder spec version")
+			}
+			for _, method := range relayMethods {
+				if !m.capabilities.supports(url, method) {
+					log.WithField("unsupportedMethod", method).Warn("relay doesn't support method, it won't be called")
+				}
+			}
+		}(url)
+	}
+	wg.Wait()
+}
+
+// startRelayCapabilityChecks checks relay capabilities right away, and then every interval until ctx is done
+func (m *RelayService) startRelayCapabilityChecks(ctx context.Context, interval time.Duration) {
+	runLoop(ctx, m.log, "capabilities", interval, true, m.checkRelayCapabilities)
+}
@@ -0,0 +1,157 @@
+package lib
+
+import (
+	"context"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRelayService_checkRelayCapabilities(t *testing.T) {
+	limitedRelay := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		resp, err := formatResponse(RelayCapabilities{
+			SpecVersion: builderSpecVersion,
+			Methods:     []string{methodForkchoiceUpdated, methodRelayGetHeader},
+		})
+		require.Nil(t, err)
+		w.Write(resp)
+	}))
+	defer limitedRelay.Close()
+
+	legacyRelay := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"jsonrpc":"2.0","id":"1","error":{"code":-32601,"message":"method not found"}}`))
+	}))
+	defer legacyRelay.Close()
+
+	relay, err := newRelayService(WithRelayURLs(limitedRelay.URL, legacyRelay.URL), WithStore(NewStore()), WithLogger(testLog))
+	require.Nil(t, err)
+	relay.checkRelayCapabilities(context.Background())
+
+	require.Equal(t, true, relay.capabilities.supports(limitedRelay.URL, methodRelayGetHeader))
+	require.Equal(t, false, relay.capabilities.supports(limitedRelay.URL, methodRelayProposeBlock))
+	require.Equal(t, true, relay.capabilities.supports(legacyRelay.URL, methodRelayProposeBlock))
+}
+
+func TestRelayService_fetchHeaders_BidFloor(t *testing.T) {
+	var calls int32
+	floored := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		resp, err := formatResponse(ExecutionPayloadWithTxRootV1{BlockHash: common.HexToHash("0x01"), BaseFeePerGas: big.NewInt(1), FeeRecipientDiff: big.NewInt(20)})
+		require.Nil(t, err)
+		w.Write(resp)
+	}))
+	defer floored.Close()
+
+	relay, err := newRelayService(WithRelayURLs(floored.URL), WithStore(NewStore()), WithLogger(testLog), WithAggregatorMode())
+	require.Nil(t, err)
+	relay.capabilities.set(floored.URL, &RelayCapabilities{SpecVersion: builderSpecVersion, Methods: relayMethods, MinBid: big.NewInt(10)})
+
+	capabilities := new(RelayCapabilities)
+	require.Nil(t, relay.GetCapabilitiesV1(nil, nil, capabilities))
+	require.Equal(t, big.NewInt(10), capabilities.MinBid, "the aggregator advertises the floor of its relays")
+	require.Equal(t, "0x00000000", capabilities.GenesisForkVersion, "the aggregator advertises its network")
+
+	forkchoiceResponses := map[string]string{floored.URL: "0x01"}
+	fetched := relay.fetchHeaders(context.Background(), forkchoiceResponses, 0, testLog)
+	require.Empty(t, fetched.candidates)
+	require.Equal(t, int32(0), atomic.LoadInt32(&calls), "without a min bid the relay isn't asked")
+
+	low := context.WithValue(context.Background(), tenantContextKey{}, &Tenant{Name: "low", MinBid: big.NewInt(5)})
+	fetched = relay.fetchHeaders(low, forkchoiceResponses, 0, testLog)
+	require.Empty(t, fetched.candidates)
+	require.Equal(t, int32(0), atomic.LoadInt32(&calls))
+
+	high := context.WithValue(context.Background(), tenantContextKey{}, &Tenant{Name: "high", MinBid: big.NewInt(10)})
+	fetched = relay.fetchHeaders(high, forkchoiceResponses, 0, testLog)
+	require.Len(t, fetched.candidates, 1)
+	require.Equal(t, int32(1), atomic.LoadInt32(&calls))
+}
+
+func TestRelayService_checkRelayCapabilities_Compatibility(t *testing.T) {
+	tests := []struct {
+		name         string
+		capabilities RelayCapabilities
+		used         bool
+	}{
+		{"same network", RelayCapabilities{SpecVersion: builderSpecVersion, Methods: relayMethods, GenesisForkVersion: "0x00000000"}, true},
+		{"unknown network", RelayCapabilities{SpecVersion: builderSpecVersion, Methods: relayMethods}, true},
+		{"other network", RelayCapabilities{SpecVersion: builderSpecVersion, Methods: relayMethods, GenesisForkVersion: "0x90000069"}, false},
+		{"invalid network", RelayCapabilities{SpecVersion: builderSpecVersion, Methods: relayMethods, GenesisForkVersion: "0x00"}, false},
+		{"compatible spec version", RelayCapabilities{SpecVersion: "0.1.3", Methods: relayMethods}, true},
+		{"incompatible spec version", RelayCapabilities{SpecVersion: "0.2", Methods: relayMethods}, false},
+		{"unknown encoding", RelayCapabilities{SpecVersion: builderSpecVersion, Methods: relayMethods, Encoding: "ssz"}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			relay := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				resp, err := formatResponse(tt.capabilities)
+				require.Nil(t, err)
+				w.Write(resp)
+			}))
+			defer relay.Close()
+
+			service, err := newRelayService(WithRelayURLs(relay.URL), WithStore(NewStore()), WithLogger(testLog))
+			require.Nil(t, err)
+			service.checkRelayCapabilities(context.Background())
+			require.Equal(t, tt.used, service.capabilities.supports(relay.URL, methodRelayGetHeader))
+		})
+	}
+}
+
+func TestRelayService_BeaconEncodedRelay(t *testing.T) {
+	payload := ExecutionPayloadWithTxRootV1{BlockHash: common.HexToHash("0x01"), Number: 5, BaseFeePerGas: big.NewInt(7), FeeRecipientDiff: big.NewInt(20)}
+	resp, err := formatResponse(payload)
+	require.Nil(t, err)
+	snakeCased, err := snakeCasePayloadResponse(resp)
+	require.Nil(t, err)
+	var decoded struct {
+		Result map[string]interface{} `json:"result"`
+	}
+	require.Nil(t, json.Unmarshal(snakeCased, &decoded))
+	decoded.Result["withdrawals"] = []map[string]string{{"index": "1", "validator_index": "2", "address": "0x0000000000000000000000000000000000000003", "amount": "4"}}
+	beaconEncoded, err := formatResponse(decoded.Result)
+	require.Nil(t, err)
+
+	var encoding atomic.Value
+	encoding.Store(PayloadEncodingBeacon)
+	relay := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req rpcRequest
+		require.Nil(t, json.NewDecoder(r.Body).Decode(&req))
+		if req.Method == methodRelayGetCapabilities {
+			resp, err := formatResponse(RelayCapabilities{SpecVersion: builderSpecVersion, Methods: relayMethods, Encoding: encoding.Load().(PayloadEncoding)})
+			require.Nil(t, err)
+			w.Write(resp)
+			return
+		}
+		w.Write(beaconEncoded)
+	}))
+	defer relay.Close()
+
+	service, err := newRelayService(WithRelayURLs(relay.URL), WithStore(NewStore()), WithLogger(testLog))
+	require.Nil(t, err)
+	service.checkRelayCapabilities(context.Background())
+
+	result := new(ExecutionPayloadWithTxRootV1)
+	rpcErr, _, err := service.requestRelayInto(context.Background(), relay.URL, methodRelayProposeBlock, nil, result)
+	require.Nil(t, err)
+	require.Nil(t, rpcErr)
+	require.Equal(t, payload.BlockHash, result.BlockHash)
+	require.Equal(t, uint64(5), result.Number)
+	require.Equal(t, big.NewInt(7), result.BaseFeePerGas)
+	require.Equal(t, []*Withdrawal{{Index: 1, ValidatorIndex: 2, Address: common.HexToAddress("0x03"), Amount: 4}}, *result.Withdrawals)
+
+	res, _, err := service.requestRelay(context.Background(), relay.URL, methodRelayGetHeader, nil)
+	require.Nil(t, err)
+	require.Contains(t, string(res.Result), `"blockHash"`)
+
+	encoding.Store(PayloadEncodingEngine)
+	service.checkRelayCapabilities(context.Background())
+	_, _, err = service.requestRelayInto(context.Background(), relay.URL, methodRelayProposeBlock, nil, new(ExecutionPayloadWithTxRootV1))
+	require.NotNil(t, err, "the encoding isn't guessed")
+}
@@ -0,0 +1,211 @@
+package lib
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/ethereum/go-ethereum/trie"
+)
+
+const (
+	methodForkchoiceUpdatedV2 = "engine_forkchoiceUpdatedV2"
+	methodRelayGetHeaderV2    = "relay_getPayloadHeaderV2"
+	methodRelayProposeBlockV2 = "relay_proposeBlindedBlockV2"
+
+	// maxWithdrawalsPerPayload is MAX_WITHDRAWALS_PER_PAYLOAD of the capella consensus spec
+	maxWithdrawalsPerPayload = 16
+)
+
+// capellaMethods maps the relay methods mev-boost calls to the versions called for payloads of capella or later
+var capellaMethods = map[string]string{
+	methodForkchoiceUpdated: methodForkchoiceUpdatedV2,
+	methodRelayGetHeader:    methodRelayGetHeaderV2,
+	methodRelayProposeBlock: methodRelayProposeBlockV2,
+}
+
+// Withdrawal as defined in the engine spec: a validator withdrawal of the beacon chain, credited by the execution payload
+type Withdrawal struct {
+	Index          uint64
+	ValidatorIndex uint64
+	Address        common.Address
+	Amount         uint64 // in gwei
+}
+
+type withdrawalJSON struct {
+	Index          *hexutil.Uint64 `json:"index"`
+	ValidatorIndex *hexutil.Uint64 `json:"validatorIndex"`
+	Address        *common.Address `json:"address"`
+	Amount         *hexutil.Uint64 `json:"amount"`
+}
+
+// MarshalJSON encodes the withdrawal like the engine API
+func (w Withdrawal) MarshalJSON() ([]byte, error) {
+	index, validatorIndex, amount := hexutil.Uint64(w.Index), hexutil.Uint64(w.ValidatorIndex), hexutil.Uint64(w.Amount)
+	return json.Marshal(&withdrawalJSON{Index: &index, ValidatorIndex: &validatorIndex, Address: &w.Address, Amount: &amount})
+}
+
+// UnmarshalJSON decodes a withdrawal encoded like the engine API, withdrawals with missing fields are rejected
+func (w *Withdrawal) UnmarshalJSON(input []byte) error {
+	var dec withdrawalJSON
+	if err := json.Unmarshal(input, &dec); err != nil {
+		return err
+	}
+	if dec.Index == nil || dec.ValidatorIndex == nil || dec.Address == nil || dec.Amount == nil {
+		return errors.New("withdrawal needs index, validatorIndex, address and amount")
+	}
+	*w = Withdrawal{Index: uint64(*dec.Index), ValidatorIndex: uint64(*dec.ValidatorIndex), Address: *dec.Address, Amount: uint64(*dec.Amount)}
+	return nil
+}
+
+// hashTreeRoot returns the SSZ hash tree root of the withdrawal
+func (w *Withdrawal) hashTreeRoot() [32]byte {
+	var index, validatorIndex, address, amount [32]byte
+	binary.LittleEndian.PutUint64(index[:], w.Index)
+	binary.LittleEndian.PutUint64(validatorIndex[:], w.ValidatorIndex)
+	copy(address[:], w.Address[:])
+	binary.LittleEndian.PutUint64(amount[:], w.Amount)
+	return merkleize([][32]byte{index, validatorIndex, address, amount})
+}
+
+// withdrawalsRoot returns the hash tree root of a withdrawal list, the root committed to by a capella execution payload
+// header
+func withdrawalsRoot(withdrawals []*Withdrawal) (common.Hash, error) {
+	if len(withdrawals) > maxWithdrawalsPerPayload {
+		return nilHash, fmt.Errorf("%d withdrawals, at most %d fit in a payload", len(withdrawals), maxWithdrawalsPerPayload)
+	}
+	chunks := make([][32]byte, len(withdrawals))
+	for i, withdrawal := range withdrawals {
+		if withdrawal == nil {
+			return nilHash, fmt.Errorf("withdrawal %d is null", i)
+		}
+		chunks[i] = withdrawal.hashTreeRoot()
+	}
+	return mixInLength(merkleizeWithLimit(chunks, maxWithdrawalsPerPayload), len(withdrawals)), nil
+}
+
+// withdrawalList is a withdrawal list the trie root of an execution block header can be derived from
+type withdrawalList []*Withdrawal
+
+func (l withdrawalList) Len() int { return len(l) }
+
+func (l withdrawalList) EncodeIndex(i int, w *bytes.Buffer) {
+	rlp.Encode(w, l[i])
+}
+
+// capellaHeader is the execution block header from the capella upgrade on, with the trie root of its withdrawals
+type capellaHeader struct {
+	ParentHash      common.Hash
+	UncleHash       common.Hash
+	Coinbase        common.Address
+	Root            common.Hash
+	TxHash          common.Hash
+	ReceiptHash     common.Hash
+	Bloom           types.Bloom
+	Difficulty      *big.Int
+	Number          *big.Int
+	GasLimit        uint64
+	GasUsed         uint64
+	Time            uint64
+	Extra           []byte
+	MixDigest       common.Hash
+	Nonce           types.BlockNonce
+	BaseFee         *big.Int
+	WithdrawalsHash common.Hash
+}
+
+// computeCapellaBlockHash is computeBlockHash for payloads with withdrawals
+func computeCapellaBlockHash(payload *ExecutionPayloadWithTxRootV1, txs types.Transactions) common.Hash {
+	header := &capellaHeader{
+		ParentHash:      payload.ParentHash,
+		UncleHash:       types.EmptyUncleHash,
+		Coinbase:        payload.FeeRecipient,
+		Root:            payload.StateRoot,
+		TxHash:          types.DeriveSha(txs, trie.NewStackTrie(nil)),
+		ReceiptHash:     payload.ReceiptsRoot,
+		Bloom:           types.BytesToBloom(payload.LogsBloom),
+		Difficulty:      new(big.Int),
+		Number:          new(big.Int).SetUint64(payload.Number),
+		GasLimit:        payload.GasLimit,
+		GasUsed:         payload.GasUsed,
+		Time:            payload.Timestamp,
+		Extra:           payload.ExtraData,
+		MixDigest:       payload.PrevRandao,
+		BaseFee:         payload.BaseFeePerGas,
+		WithdrawalsHash: types.DeriveSha(withdrawalList(*payload.Withdrawals), trie.NewStackTrie(nil)),
+	}
+	encoded, err := rlp.EncodeToBytes(header)
+	if err != nil {
+		return nilHash
+	}
+	return crypto.Keccak256Hash(encoded)
+}
+
+// fillWithdrawalsRoot computes the withdrawals root of a payload that came with its withdrawals, and rejects it if it
+// contradicts the root the relay sent
+func fillWithdrawalsRoot(payload *ExecutionPayloadWithTxRootV1) error {
+	if payload.Withdrawals == nil {
+		return nil
+	}
+	root, err := withdrawalsRoot(*payload.Withdrawals)
+	if err != nil {
+		return err
+	}
+	if payload.WithdrawalsRoot != nil && *payload.WithdrawalsRoot != root {
+		return fmt.Errorf("mismatched withdrawals root: %s, %s", root, payload.WithdrawalsRoot)
+	}
+	payload.WithdrawalsRoot = &root
+	return nil
+}
+
+type forkContextKey struct{}
+
+// withCapella marks the relay calls of ctx to be for a payload of capella or later if slot is at or after the capella
+// fork of chain, so they are sent with the V2 methods
+func withCapella(ctx context.Context, chain *ChainConfig, slot uint64) context.Context {
+	if !chain.IsCapellaSlot(slot) {
+		return ctx
+	}
+	return context.WithValue(ctx, forkContextKey{}, true)
+}
+
+func isCapella(ctx context.Context) bool {
+	capella, _ := ctx.Value(forkContextKey{}).(bool)
+	return capella
+}
+
+// forkMethod returns the version of a relay method called for the payloads of ctx
+func forkMethod(ctx context.Context, method string) string {
+	if v2, ok := capellaMethods[method]; ok && isCapella(ctx) {
+		return v2
+	}
+	return method
+}
+
+// ForkchoiceUpdatedV2 is ForkchoiceUpdatedV1 for consensus clients of capella, whose payload attributes have withdrawals.
+// Relays are called with the method version of the fork of the payload timestamp either way.
+func (m *RelayService) ForkchoiceUpdatedV2(req *http.Request, args *[]interface{}, result *ForkChoiceResponse) error {
+	return m.ForkchoiceUpdatedV1(req, args, result)
+}
+
+// GetPayloadHeaderV2 is GetPayloadHeaderV1 for consensus clients of capella, the header has a withdrawals root from
+// capella on
+func (m *RelayService) GetPayloadHeaderV2(req *http.Request, args *string, result *ExecutionPayloadWithTxRootV1) error {
+	return m.GetPayloadHeaderV1(req, args, result)
+}
+
+// ProposeBlindedBlockV2 is ProposeBlindedBlockV1 for consensus clients of capella, the payload has withdrawals from
+// capella on
+func (m *RelayService) ProposeBlindedBlockV2(req *http.Request, args *SignedBlindedBeaconBlock, result *ExecutionPayloadWithTxRootV1) error {
+	return m.ProposeBlindedBlockV1(req, args, result)
+}
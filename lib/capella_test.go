@@ -0,0 +1,245 @@
+package lib
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/trie"
+	"github.com/stretchr/testify/require"
+)
+
+// capellaChain has capella from epoch 1, slot 32 at timestamp 384
+var capellaChain = &ChainConfig{
+	Name:               "capella",
+	SecondsPerSlot:     12,
+	SlotsPerEpoch:      32,
+	CapellaForkVersion: [4]byte{0x03, 0x00, 0x00, 0x00},
+	CapellaForkEpoch:   1,
+}
+
+// testWithdrawals returns the withdrawals of a capella test payload
+func testWithdrawals() []*Withdrawal {
+	return []*Withdrawal{
+		{Index: 1, ValidatorIndex: 100, Address: common.HexToAddress("0x0000000000000000000000000000000000000003"), Amount: 32000000000},
+		{Index: 2, ValidatorIndex: 101, Address: common.HexToAddress("0x0000000000000000000000000000000000000004"), Amount: 12345},
+	}
+}
+
+// capellaPayload returns the payload of testBlock with withdrawals, its roots and the block hash of both
+func capellaPayload(t *testing.T) ExecutionPayloadWithTxRootV1 {
+	payload := blockPayload(t, testBlock(t))
+	withdrawals := testWithdrawals()
+	payload.Withdrawals = &withdrawals
+	require.Nil(t, fillWithdrawalsRoot(&payload))
+	raw, _, err := decodeTransactions(*payload.Transactions)
+	require.Nil(t, err)
+	payload.TransactionsRoot, err = transactionsRoot(raw)
+	require.Nil(t, err)
+	return withBlockHash(payload)
+}
+
+func TestWithdrawal_JSON(t *testing.T) {
+	withdrawal := testWithdrawals()[1]
+	encoded, err := json.Marshal(withdrawal)
+	require.Nil(t, err)
+	require.JSONEq(t, `{"index":"0x2","validatorIndex":"0x65","address":"0x0000000000000000000000000000000000000004","amount":"0x3039"}`, string(encoded))
+
+	decoded := new(Withdrawal)
+	require.Nil(t, json.Unmarshal(encoded, decoded))
+	require.Equal(t, withdrawal, decoded)
+	require.NotNil(t, json.Unmarshal([]byte(`{"index":"0x2","validatorIndex":"0x65","amount":"0x3039"}`), decoded), "withdrawals need an address")
+
+	payload := capellaPayload(t)
+	encoded, err = json.Marshal(payload)
+	require.Nil(t, err)
+	var roundTrip ExecutionPayloadWithTxRootV1
+	require.Nil(t, json.Unmarshal(encoded, &roundTrip))
+	require.Equal(t, payload.Withdrawals, roundTrip.Withdrawals)
+	require.Equal(t, payload.WithdrawalsRoot, roundTrip.WithdrawalsRoot)
+
+	encoded, err = json.Marshal(blockPayload(t, testBlock(t)))
+	require.Nil(t, err)
+	require.NotContains(t, string(encoded), "withdrawals", "payloads before capella have no withdrawals")
+}
+
+func Test_withdrawalsRoot(t *testing.T) {
+	root, err := withdrawalsRoot(nil)
+	require.Nil(t, err)
+	require.Equal(t, common.HexToHash("0x792930bbd5baac43bcc798ee49aa8185ef76bb3b44ba62b91d86ae569e4bb535"), root, "root of an empty List[Withdrawal, 16]")
+
+	root, err = withdrawalsRoot(testWithdrawals())
+	require.Nil(t, err)
+	require.NotEqual(t, common.HexToHash("0x792930bbd5baac43bcc798ee49aa8185ef76bb3b44ba62b91d86ae569e4bb535"), root)
+
+	_, err = withdrawalsRoot(make([]*Withdrawal, maxWithdrawalsPerPayload+1))
+	require.NotNil(t, err)
+	_, err = withdrawalsRoot([]*Withdrawal{nil})
+	require.NotNil(t, err)
+
+	require.Equal(t, types.EmptyRootHash, types.DeriveSha(withdrawalList(nil), trie.NewStackTrie(nil)), "trie root of no withdrawals")
+}
+
+func Test_computeCapellaBlockHash(t *testing.T) {
+	bellatrix := blockPayload(t, testBlock(t))
+	_, txs, err := decodeTransactions(*bellatrix.Transactions)
+	require.Nil(t, err)
+
+	empty := bellatrix
+	empty.Withdrawals = &[]*Withdrawal{}
+	require.NotEqual(t, bellatrix.BlockHash, computeBlockHash(&empty, txs), "the header of an empty withdrawal list has the root of the empty trie")
+
+	payload := capellaPayload(t)
+	require.Nil(t, verifyPayload(&payload))
+	tampered := payload
+	tampered.Withdrawals = &[]*Withdrawal{testWithdrawals()[0]}
+	require.True(t, errors.Is(verifyPayload(&tampered), ErrHeaderMismatch), "dropped withdrawal")
+	tampered = payload
+	root := common.HexToHash("0x01")
+	tampered.WithdrawalsRoot = &root
+	require.True(t, errors.Is(verifyPayload(&tampered), ErrHeaderMismatch), "withdrawals root")
+}
+
+func TestExecutionPayloadHeaderV1_CapellaHashTreeRoot(t *testing.T) {
+	payload := capellaPayload(t)
+	header := payload.Header()
+	require.Equal(t, payload.WithdrawalsRoot, header.WithdrawalsRoot)
+	capellaRoot, err := header.HashTreeRoot()
+	require.Nil(t, err)
+
+	header.WithdrawalsRoot = nil
+	bellatrixRoot, err := header.HashTreeRoot()
+	require.Nil(t, err)
+	require.NotEqual(t, bellatrixRoot, capellaRoot, "capella headers have a 15th field")
+
+	encoded, err := json.Marshal(payload.Header())
+	require.Nil(t, err)
+	require.Contains(t, string(encoded), `"withdrawals_root":"`+payload.WithdrawalsRoot.Hex()+`"`)
+	decoded := new(ExecutionPayloadHeaderV1)
+	require.Nil(t, json.Unmarshal(encoded, decoded))
+	require.Equal(t, payload.WithdrawalsRoot, decoded.WithdrawalsRoot)
+
+	withdrawals := testWithdrawals()
+	mismatched := blockPayload(t, testBlock(t))
+	mismatched.Withdrawals = &withdrawals
+	mismatched.WithdrawalsRoot = &common.Hash{0x01}
+	require.NotNil(t, fillWithdrawalsRoot(&mismatched))
+}
+
+func TestChainConfig_IsCapellaSlot(t *testing.T) {
+	require.False(t, MainnetChainConfig.IsCapellaSlot(MainnetChainConfig.CurrentSlot()), "capella isn't scheduled on mainnet")
+	require.False(t, capellaChain.IsCapellaSlot(31))
+	require.True(t, capellaChain.IsCapellaSlot(32))
+	require.Equal(t, [4]byte{0x03, 0x00, 0x00, 0x00}, capellaChain.ForkVersion(1))
+
+	unscheduled := *capellaChain
+	unscheduled.CapellaForkVersion = [4]byte{}
+	unscheduled.CapellaForkEpoch = 0
+	require.False(t, unscheduled.IsCapellaSlot(32), "a zero fork version means capella isn't scheduled")
+}
+
+func TestRelayService_CapellaDispatch(t *testing.T) {
+	payload := capellaPayload(t)
+	var mu sync.Mutex
+	var methods []string
+	relay := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		require.Nil(t, err)
+		var req rpcRequest
+		require.Nil(t, json.Unmarshal(body, &req))
+		mu.Lock()
+		methods = append(methods, req.Method)
+		mu.Unlock()
+
+		var result interface{}
+		switch req.Method {
+		case methodForkchoiceUpdated, methodForkchoiceUpdatedV2:
+			payloadID := hexutil.Bytes{0x01}
+			result = ForkChoiceResponse{PayloadStatus: PayloadStatus{Status: ForkchoiceStatusValid}, PayloadID: &payloadID}
+		default:
+			result = payload
+		}
+		resp, err := formatResponse(result)
+		require.Nil(t, err)
+		w.Write(resp)
+	}))
+	defer relay.Close()
+	lastMethod := func() string {
+		mu.Lock()
+		defer mu.Unlock()
+		return methods[len(methods)-1]
+	}
+
+	router, err := NewRouter(context.Background(), WithRelayURLs(relay.URL), WithStore(NewStore()), WithLogger(testLog), WithChainConfig(capellaChain), WithCapabilityCheckInterval(0))
+	require.Nil(t, err)
+	forkchoiceUpdated := func(method string, timestamp uint64) {
+		attributes := PayloadAttributesV1{Timestamp: hexutil.Uint64(timestamp), SuggestedFeeRecipient: common.HexToAddress("0x05")}
+		body, err := formatRequestBody(method, []interface{}{ForkchoiceStateV1{}, attributes})
+		require.Nil(t, err)
+		req := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		require.Equal(t, http.StatusOK, w.Code)
+		require.NotContains(t, w.Body.String(), `"error":{`)
+	}
+
+	forkchoiceUpdated("engine_forkchoiceUpdatedV1", 372)
+	require.Equal(t, methodForkchoiceUpdated, lastMethod(), "payloads before capella are built with V1")
+	forkchoiceUpdated("engine_forkchoiceUpdatedV1", 384)
+	require.Equal(t, methodForkchoiceUpdatedV2, lastMethod(), "relays are called by the fork of the payload")
+	forkchoiceUpdated("engine_forkchoiceUpdatedV2", 396)
+	require.Equal(t, methodForkchoiceUpdatedV2, lastMethod())
+
+	service, err := newRelayService(WithRelayURLs(relay.URL), WithStore(NewStore()), WithLogger(testLog), WithChainConfig(capellaChain))
+	require.Nil(t, err)
+	block := &SignedBlindedBeaconBlock{Message: &BlindedBeaconBlock{Slot: 33, Body: &BlindedBeaconBlockBody{ExecutionPayloadHeader: payload.Header()}}}
+	var result ExecutionPayloadWithTxRootV1
+	require.Nil(t, service.ProposeBlindedBlockV2(nil, block, &result))
+	require.Equal(t, methodRelayProposeBlockV2, lastMethod())
+	require.Equal(t, payload.Withdrawals, result.Withdrawals)
+
+	header := payload.Header()
+	header.WithdrawalsRoot = nil
+	block.Message.Body.ExecutionPayloadHeader = header
+	err = service.ProposeBlindedBlockV1(nil, block, &result)
+	require.True(t, errors.Is(err, ErrHeaderMismatch), "a payload with withdrawals doesn't match a header without withdrawals root")
+}
+
+func TestBlindedBeaconBlockBody_Capella(t *testing.T) {
+	payload := capellaPayload(t)
+	body := testBlindedBlockBody(payload.Header())
+	body.BLSToExecutionChanges = []SignedBLSToExecutionChange{{
+		Message:   BLSToExecutionChange{ValidatorIndex: 9, FromBLSPubkey: BLSPubkey{0x01}, ToExecutionAddress: common.HexToAddress("0x05")},
+		Signature: BLSSignature{0x02},
+	}}
+	encoded, err := json.Marshal(body)
+	require.Nil(t, err)
+	require.Contains(t, string(encoded), `"bls_to_execution_changes":[{"message":{"validator_index":"9"`)
+
+	decoded := new(BlindedBeaconBlockBody)
+	require.Nil(t, json.Unmarshal(encoded, decoded))
+	expectedRoot, err := body.HashTreeRoot()
+	require.Nil(t, err)
+	root, err := decoded.HashTreeRoot()
+	require.Nil(t, err)
+	require.Equal(t, expectedRoot, root)
+
+	decoded.BLSToExecutionChanges = nil
+	withoutChanges, err := decoded.HashTreeRoot()
+	require.Nil(t, err)
+	require.NotEqual(t, expectedRoot, withoutChanges, "capella bodies commit to their credential changes")
+
+	decoded.BLSToExecutionChanges = make([]SignedBLSToExecutionChange, maxBLSToExecutionChanges+1)
+	_, err = decoded.HashTreeRoot()
+	require.NotNil(t, err)
+}
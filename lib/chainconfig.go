@@ -0,0 +1,274 @@
+package lib
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"gopkg.in/yaml.v3"
+)
+
+// ChainConfig holds the network parameters mev-boost needs to reason about slots and signing domains
+type ChainConfig struct {
+	Name                    string
+	GenesisTime             uint64
+	GenesisForkVersion      [4]byte
+	AltairForkVersion       [4]byte
+	AltairForkEpoch         uint64
+	BellatrixForkVersion    [4]byte
+	BellatrixForkEpoch      uint64
+	CapellaForkVersion      [4]byte // zero if capella isn't scheduled, whatever CapellaForkEpoch is
+	CapellaForkEpoch        uint64
+	SecondsPerSlot          uint64
+	SlotsPerEpoch           uint64
+	TerminalTotalDifficulty *big.Int
+}
+
+// FarFutureEpoch is the epoch of forks that aren't scheduled yet, see FAR_FUTURE_EPOCH of the consensus spec. Capella
+// isn't scheduled on the known networks yet, it's enabled with the CAPELLA_FORK_VERSION and CAPELLA_FORK_EPOCH of a
+// chain config file or of the beacon node.
+const FarFutureEpoch = math.MaxUint64
+
+var (
+	// MainnetChainConfig is the configuration of Ethereum mainnet
+	MainnetChainConfig = &ChainConfig{
+		Name:                    "mainnet",
+		GenesisTime:             1606824023,
+		GenesisForkVersion:      [4]byte{0x00, 0x00, 0x00, 0x00},
+		AltairForkVersion:       [4]byte{0x01, 0x00, 0x00, 0x00},
+		AltairForkEpoch:         74240,
+		BellatrixForkVersion:    [4]byte{0x02, 0x00, 0x00, 0x00},
+		BellatrixForkEpoch:      144896,
+		CapellaForkEpoch:        FarFutureEpoch,
+		SecondsPerSlot:          12,
+		SlotsPerEpoch:           32,
+		TerminalTotalDifficulty: mustParseBig("58750000000000000000000"),
+	}
+
+	// SepoliaChainConfig is the configuration of the Sepolia testnet
+	SepoliaChainConfig = &ChainConfig{
+		Name:                    "sepolia",
+		GenesisTime:             1655733600,
+		GenesisForkVersion:      [4]byte{0x90, 0x00, 0x00, 0x69},
+		AltairForkVersion:       [4]byte{0x90, 0x00, 0x00, 0x70},
+		AltairForkEpoch:         50,
+		BellatrixForkVersion:    [4]byte{0x90, 0x00, 0x00, 0x71},
+		BellatrixForkEpoch:      100,
+		CapellaForkEpoch:        FarFutureEpoch,
+		SecondsPerSlot:          12,
+		SlotsPerEpoch:           32,
+		TerminalTotalDifficulty: mustParseBig("17000000000000000"),
+	}
+
+	// RopstenChainConfig is the configuration of the Ropsten testnet
+	RopstenChainConfig = &ChainConfig{
+		Name:                    "ropsten",
+		GenesisTime:             1653922800,
+		GenesisForkVersion:      [4]byte{0x80, 0x00, 0x00, 0x69},
+		AltairForkVersion:       [4]byte{0x80, 0x00, 0x00, 0x70},
+		AltairForkEpoch:         500,
+		BellatrixForkVersion:    [4]byte{0x80, 0x00, 0x00, 0x71},
+		BellatrixForkEpoch:      27900,
+		CapellaForkEpoch:        FarFutureEpoch,
+		SecondsPerSlot:          12,
+		SlotsPerEpoch:           32,
+		TerminalTotalDifficulty: mustParseBig("50000000000000000"),
+	}
+
+	chainConfigs = map[string]*ChainConfig{
+		MainnetChainConfig.Name: MainnetChainConfig,
+		SepoliaChainConfig.Name: SepoliaChainConfig,
+		RopstenChainConfig.Name: RopstenChainConfig,
+	}
+)
+
+func mustParseBig(s string) *big.Int {
+	n, ok := new(big.Int).SetString(s, 10)
+	if !ok {
+		panic("invalid big int " + s)
+	}
+	return n
+}
+
+// ChainConfigByName returns the configuration of a known network
+func ChainConfigByName(name string) (*ChainConfig, error) {
+	config, ok := chainConfigs[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown network %s", name)
+	}
+	return config, nil
+}
+
+// SlotDuration is the length of a slot
+func (c *ChainConfig) SlotDuration() time.Duration {
+	return time.Duration(c.SecondsPerSlot) * time.Second
+}
+
+// stateExpiry is how long the state of a proposal, like its payload id, is kept: 2 epochs
+func (c *ChainConfig) stateExpiry() time.Duration {
+	return c.SlotDuration() * time.Duration(2*c.SlotsPerEpoch)
+}
+
+// SlotAt returns the slot of a unix timestamp, e.g. the timestamp of an execution payload
+func (c *ChainConfig) SlotAt(timestamp uint64) uint64 {
+	if timestamp < c.GenesisTime || c.SecondsPerSlot == 0 {
+		return 0
+	}
+	return (timestamp - c.GenesisTime) / c.SecondsPerSlot
+}
+
+// SlotStartTime returns the time a slot starts
+func (c *ChainConfig) SlotStartTime(slot uint64) time.Time {
+	return time.Unix(int64(c.GenesisTime+slot*c.SecondsPerSlot), 0)
+}
+
+// AttestationDeadline returns when the block of a payload with timestamp has to be published to get attested, a third
+// into its slot
+func (c *ChainConfig) AttestationDeadline(timestamp uint64) time.Time {
+	return time.Unix(int64(timestamp), 0).Add(c.SlotDuration() / 3)
+}
+
+// ForkVersion returns the fork version active at epoch
+func (c *ChainConfig) ForkVersion(epoch uint64) [4]byte {
+	switch {
+	case c.isCapellaEpoch(epoch):
+		return c.CapellaForkVersion
+	case epoch >= c.BellatrixForkEpoch:
+		return c.BellatrixForkVersion
+	case epoch >= c.AltairForkEpoch:
+		return c.AltairForkVersion
+	default:
+		return c.GenesisForkVersion
+	}
+}
+
+// IsCapellaSlot reports whether the blocks of slot are of the capella fork or later, i.e. their payloads have withdrawals
+func (c *ChainConfig) IsCapellaSlot(slot uint64) bool {
+	if c.SlotsPerEpoch == 0 {
+		return false
+	}
+	return c.isCapellaEpoch(slot / c.SlotsPerEpoch)
+}
+
+func (c *ChainConfig) isCapellaEpoch(epoch uint64) bool {
+	return c.CapellaForkVersion != [4]byte{} && epoch >= c.CapellaForkEpoch
+}
+
+// CurrentSlot returns the slot at the current time
+func (c *ChainConfig) CurrentSlot() uint64 {
+	return c.SlotAt(uint64(now().Unix()))
+}
+
+// chainConfigFile is a consensus-spec style config.yaml, as generated for devnets. Values are kept as strings
+// because fork versions like 0x00000000 would otherwise be parsed as integers.
+type chainConfigFile struct {
+	ConfigName              string `yaml:"CONFIG_NAME"`
+	GenesisTime             string `yaml:"GENESIS_TIME"`
+	MinGenesisTime          string `yaml:"MIN_GENESIS_TIME"`
+	GenesisDelay            string `yaml:"GENESIS_DELAY"`
+	GenesisForkVersion      string `yaml:"GENESIS_FORK_VERSION"`
+	AltairForkVersion       string `yaml:"ALTAIR_FORK_VERSION"`
+	AltairForkEpoch         string `yaml:"ALTAIR_FORK_EPOCH"`
+	BellatrixForkVersion    string `yaml:"BELLATRIX_FORK_VERSION"`
+	BellatrixForkEpoch      string `yaml:"BELLATRIX_FORK_EPOCH"`
+	CapellaForkVersion      string `yaml:"CAPELLA_FORK_VERSION"`
+	CapellaForkEpoch        string `yaml:"CAPELLA_FORK_EPOCH"`
+	SecondsPerSlot          string `yaml:"SECONDS_PER_SLOT"`
+	SlotsPerEpoch           string `yaml:"SLOTS_PER_EPOCH"`
+	TerminalTotalDifficulty string `yaml:"TERMINAL_TOTAL_DIFFICULTY"`
+}
+
+// LoadChainConfig reads a consensus-spec style config file (YAML or JSON). Missing values default to mainnet, except
+// the genesis, altair and bellatrix fork versions which are required, and the genesis time falls back to
+// MIN_GENESIS_TIME + GENESIS_DELAY if GENESIS_TIME isn't set.
+func LoadChainConfig(path string) (*ChainConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	file := new(chainConfigFile)
+	if err := yaml.Unmarshal(data, file); err != nil {
+		return nil, fmt.Errorf("could not parse chain config %s: %w", path, err)
+	}
+	return file.toChainConfig()
+}
+
+func (f *chainConfigFile) toChainConfig() (*ChainConfig, error) {
+	config := *MainnetChainConfig
+	config.Name = f.ConfigName
+	if config.Name == "" {
+		config.Name = "custom"
+	}
+
+	// the signing domains of another network with the fork versions of mainnet would fail every signature check
+	for key, value := range map[string]string{
+		"GENESIS_FORK_VERSION":   f.GenesisForkVersion,
+		"ALTAIR_FORK_VERSION":    f.AltairForkVersion,
+		"BELLATRIX_FORK_VERSION": f.BellatrixForkVersion,
+	} {
+		if value == "" {
+			return nil, fmt.Errorf("missing %s, the fork versions of a network don't default to mainnet", key)
+		}
+	}
+
+	var err error
+	parseUint := func(key, value string, dst *uint64) {
+		if value == "" || err != nil {
+			return
+		}
+		if *dst, err = strconv.ParseUint(value, 0, 64); err != nil {
+			err = fmt.Errorf("invalid %s %q: %w", key, value, err)
+		}
+	}
+	parseVersion := func(key, value string, dst *[4]byte) {
+		if value == "" || err != nil {
+			return
+		}
+		var version []byte
+		if version, err = hexutil.Decode(value); err != nil || len(version) != 4 {
+			err = fmt.Errorf("invalid %s %q: expected 4 hex-encoded bytes", key, value)
+			return
+		}
+		copy(dst[:], version)
+	}
+
+	if f.GenesisTime != "" {
+		parseUint("GENESIS_TIME", f.GenesisTime, &config.GenesisTime)
+	} else if f.MinGenesisTime != "" {
+		var minGenesisTime, genesisDelay uint64
+		parseUint("MIN_GENESIS_TIME", f.MinGenesisTime, &minGenesisTime)
+		parseUint("GENESIS_DELAY", f.GenesisDelay, &genesisDelay)
+		config.GenesisTime = minGenesisTime + genesisDelay
+	}
+	parseVersion("GENESIS_FORK_VERSION", f.GenesisForkVersion, &config.GenesisForkVersion)
+	parseVersion("ALTAIR_FORK_VERSION", f.AltairForkVersion, &config.AltairForkVersion)
+	parseUint("ALTAIR_FORK_EPOCH", f.AltairForkEpoch, &config.AltairForkEpoch)
+	parseVersion("BELLATRIX_FORK_VERSION", f.BellatrixForkVersion, &config.BellatrixForkVersion)
+	parseUint("BELLATRIX_FORK_EPOCH", f.BellatrixForkEpoch, &config.BellatrixForkEpoch)
+	parseVersion("CAPELLA_FORK_VERSION", f.CapellaForkVersion, &config.CapellaForkVersion)
+	parseUint("CAPELLA_FORK_EPOCH", f.CapellaForkEpoch, &config.CapellaForkEpoch)
+	parseUint("SECONDS_PER_SLOT", f.SecondsPerSlot, &config.SecondsPerSlot)
+	parseUint("SLOTS_PER_EPOCH", f.SlotsPerEpoch, &config.SlotsPerEpoch)
+	if err != nil {
+		return nil, err
+	}
+
+	if f.TerminalTotalDifficulty != "" {
+		ttd, ok := new(big.Int).SetString(f.TerminalTotalDifficulty, 10)
+		if !ok {
+			return nil, fmt.Errorf("invalid TERMINAL_TOTAL_DIFFICULTY %q", f.TerminalTotalDifficulty)
+		}
+		config.TerminalTotalDifficulty = ttd
+	}
+
+	if config.SecondsPerSlot == 0 || config.SlotsPerEpoch == 0 {
+		return nil, errors.New("SECONDS_PER_SLOT and SLOTS_PER_EPOCH must be greater than 0")
+	}
+	return &config, nil
+}
@@ -0,0 +1,55 @@
+package lib
+
+import (
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadChainConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	err := os.WriteFile(path, []byte(`
+CONFIG_NAME: devnet
+MIN_GENESIS_TIME: 1650000000
+GENESIS_DELAY: 60
+GENESIS_FORK_VERSION: 0x00000069
+ALTAIR_FORK_VERSION: 0x01000069
+ALTAIR_FORK_EPOCH: 0
+BELLATRIX_FORK_VERSION: 0x02000069
+BELLATRIX_FORK_EPOCH: 2
+CAPELLA_FORK_VERSION: 0x03000069
+CAPELLA_FORK_EPOCH: 4
+SECONDS_PER_SLOT: 6
+TERMINAL_TOTAL_DIFFICULTY: 100
+`), 0o600)
+	require.Nil(t, err)
+
+	config, err := LoadChainConfig(path)
+	require.Nil(t, err)
+	require.Equal(t, "devnet", config.Name)
+	require.Equal(t, uint64(1650000060), config.GenesisTime)
+	require.Equal(t, [4]byte{0x00, 0x00, 0x00, 0x69}, config.GenesisForkVersion)
+	require.Equal(t, [4]byte{0x02, 0x00, 0x00, 0x69}, config.BellatrixForkVersion)
+	require.Equal(t, uint64(0), config.AltairForkEpoch)
+	require.Equal(t, uint64(2), config.BellatrixForkEpoch)
+	require.Equal(t, uint64(6), config.SecondsPerSlot)
+	require.Equal(t, uint64(32), config.SlotsPerEpoch)
+	require.Equal(t, big.NewInt(100), config.TerminalTotalDifficulty)
+	require.Equal(t, uint64(2), config.SlotAt(1650000072))
+	require.Equal(t, [4]byte{0x03, 0x00, 0x00, 0x69}, config.ForkVersion(4))
+	require.True(t, config.IsCapellaSlot(128))
+
+	err = os.WriteFile(path, []byte("GENESIS_FORK_VERSION: 0x0000"), 0o600)
+	require.Nil(t, err)
+	_, err = LoadChainConfig(path)
+	require.NotNil(t, err)
+
+	err = os.WriteFile(path, []byte("GENESIS_FORK_VERSION: 0x00000069\nALTAIR_FORK_VERSION: 0x01000069\nSECONDS_PER_SLOT: 6"), 0o600)
+	require.Nil(t, err)
+	_, err = LoadChainConfig(path)
+	require.Error(t, err, "fork versions don't default to mainnet")
+	require.Contains(t, err.Error(), "BELLATRIX_FORK_VERSION")
+}
@@ -0,0 +1,155 @@
+package lib
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// chainSanity confirms the slot, head and proposer of a header request on the beacon node before headers are served,
+// catching consensus clients and relays that disagree about the chain state
+type chainSanity struct {
+	beacon  *BeaconClient
+	chain   *ChainConfig
+	pubkeys map[string]bool // lowercase pubkeys of local validators, empty if any proposer is accepted
+
+	mu        sync.Mutex
+	proposers map[uint64]map[uint64]string // map[epoch]map[slot]lowercase proposer pubkey
+}
+
+// beaconView is the chain state of the beacon node when a header is requested
+type beaconView struct {
+	headSlot      uint64
+	headRoot      string
+	headBlockHash common.Hash // zero before the merge
+	proposer      string      // pubkey of the proposer of the requested slot
+}
+
+func newChainSanity(beacon *BeaconClient, chain *ChainConfig, validatorPubkeys []string) *chainSanity {
+	pubkeys := make(map[string]bool, len(validatorPubkeys))
+	for _, pubkey := range validatorPubkeys {
+		pubkeys[strings.ToLower(pubkey)] = true
+	}
+	return &chainSanity{
+		beacon:    beacon,
+		chain:     chain,
+		pubkeys:   pubkeys,
+		proposers: make(map[uint64]map[uint64]string),
+	}
+}
+
+// view looks up the head of the beacon node and the proposer of slot
+func (s *chainSanity) view(ctx context.Context, slot uint64) (*beaconView, error) {
+	head, err := s.beacon.HeadHeader(ctx)
+	if err != nil {
+		return nil, err
+	}
+	headSlot, err := strconv.ParseUint(head.Header.Message.Slot, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid head slot %q: %w", head.Header.Message.Slot, err)
+	}
+	headBlockHash, err := s.beacon.ExecutionBlockHash(ctx, head.Root)
+	if err != nil {
+		return nil, err
+	}
+	proposer, err := s.proposer(ctx, slot)
+	if err != nil {
+		return nil, err
+	}
+	return &beaconView{
+		headSlot:      headSlot,
+		headRoot:      head.Root,
+		headBlockHash: headBlockHash,
+		proposer:      proposer,
+	}, nil
+}
+
+// proposer returns the pubkey of the proposer of slot, the duties of an epoch are fetched once
+func (s *chainSanity) proposer(ctx context.Context, slot uint64) (string, error) {
+	epoch := slot / s.chain.SlotsPerEpoch
+	s.mu.Lock()
+	proposers, ok := s.proposers[epoch]
+	s.mu.Unlock()
+	if !ok {
+		duties, err := s.beacon.ProposerDuties(ctx, epoch)
+		if err != nil {
+			return "", err
+		}
+		proposers = make(map[uint64]string, len(duties))
+		for _, duty := range duties {
+			dutySlot, err := strconv.ParseUint(duty.Slot, 10, 64)
+			if err != nil {
+				return "", fmt.Errorf("invalid slot %q of proposer duty: %w", duty.Slot, err)
+			}
+			proposers[dutySlot] = strings.ToLower(duty.Pubkey)
+		}
+
+		s.mu.Lock()
+		for known := range s.proposers {
+			if known+1 < epoch {
+				delete(s.proposers, known)
+			}
+		}
+		s.proposers[epoch] = proposers
+		s.mu.Unlock()
+	}
+
+	proposer, ok := proposers[slot]
+	if !ok {
+		return "", fmt.Errorf("no proposer duty for slot %d", slot)
+	}
+	return proposer, nil
+}
+
+// check returns an ErrChainStateMismatch error if a header for slot shouldn't be served in the chain state of view
+func (s *chainSanity) check(view *beaconView, slot uint64) error {
+	if view.headSlot >= slot {
+		return newMethodError(ErrChainStateMismatch, "beacon node head is at slot %d, a header for slot %d can't be proposed anymore", view.headSlot, slot)
+	}
+	if len(s.pubkeys) > 0 && !s.pubkeys[view.proposer] {
+		return newMethodError(ErrChainStateMismatch, "proposer %s of slot %d is not a local validator", view.proposer, slot)
+	}
+	return nil
+}
+
+// viewAsync starts looking up the view of slot, so it runs while the relays are asked for headers. The returned
+// function waits for the lookup, it returns a nil view if s is nil.
+func (s *chainSanity) viewAsync(ctx context.Context, slot uint64) func() (*beaconView, error) {
+	if s == nil {
+		return func() (*beaconView, error) { return nil, nil }
+	}
+	var view *beaconView
+	var err error
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		view, err = s.view(ctx, slot)
+	}()
+	return func() (*beaconView, error) {
+		<-done
+		return view, err
+	}
+}
+
+// rejectOffHead drops the candidates not building on the head block of view, marking their bids invalid
+func (m *RelayService) rejectOffHead(candidates []BidCandidate, view *beaconView, failures *relayFailures, log Logger) []BidCandidate {
+	if view.headBlockHash == nilHash {
+		return candidates
+	}
+	onHead := make([]BidCandidate, 0, len(candidates))
+	for _, candidate := range candidates {
+		if candidate.Header.ParentHash == view.headBlockHash {
+			onHead = append(onHead, candidate)
+			continue
+		}
+		err := fmt.Errorf("parent %s is not the head %s of the beacon node", candidate.Header.ParentHash, view.headBlockHash)
+		m.bids.setResult(candidate.RelayURL, candidate.Header.BlockHash, BidResultInvalid, err)
+		failures.invalid()
+		log.WithFields(Fields{"error": err, "url": candidate.RelayURL, "blockHash": candidate.Header.BlockHash}).Warn("GetPayloadHeaderV1: header not building on the head of the beacon node")
+	}
+	return onHead
+}
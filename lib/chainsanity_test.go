@@ -0,0 +1,118 @@
+package lib
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/stretchr/testify/require"
+)
+
+func newChainStateBeacon(headSlot uint64, headBlockHash common.Hash, proposer string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/eth/v1/beacon/headers/head":
+			fmt.Fprintf(w, `{"data": {"root": "0xabcd", "header": {"message": {"slot": "%d", "proposer_index": "1"}}}}`, headSlot)
+		case "/eth/v2/beacon/blocks/0xabcd":
+			fmt.Fprintf(w, `{"data": {"message": {"body": {"execution_payload": {"block_hash": "%s"}}}}}`, headBlockHash.Hex())
+		case "/eth/v1/validator/duties/proposer/0":
+			fmt.Fprintf(w, `{"data": [{"pubkey": "%s", "validator_index": "1", "slot": "1"}]}`, proposer)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+}
+
+func TestChainSanity_Check(t *testing.T) {
+	beacon := newChainStateBeacon(0, common.HexToHash("0xaa"), "0xAB")
+	defer beacon.Close()
+
+	checks := newChainSanity(NewBeaconClient(beacon.URL), MainnetChainConfig, []string{"0xab"})
+	view, err := checks.view(context.Background(), 1)
+	require.Nil(t, err)
+	require.Equal(t, &beaconView{headRoot: "0xabcd", headBlockHash: common.HexToHash("0xaa"), proposer: "0xab"}, view)
+	require.Nil(t, checks.check(view, 1))
+
+	view.headSlot = 1
+	require.ErrorIs(t, checks.check(view, 1), ErrChainStateMismatch)
+
+	view.headSlot, view.proposer = 0, "0xcd"
+	require.ErrorIs(t, checks.check(view, 1), ErrChainStateMismatch)
+	require.Nil(t, newChainSanity(nil, MainnetChainConfig, nil).check(view, 1), "any proposer is accepted without local validators")
+
+	_, err = checks.view(context.Background(), 2)
+	require.Error(t, err, "slots without duty can't be checked")
+}
+
+func TestGetPayloadHeaderV1_ChainStateChecks(t *testing.T) {
+	newRelay := func(blockHash, parentHash string, value int64) *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			body, err := ioutil.ReadAll(r.Body)
+			require.Nil(t, err)
+			var req rpcRequest
+			require.Nil(t, json.Unmarshal(body, &req))
+
+			var resp []byte
+			switch req.Method {
+			case methodForkchoiceUpdated:
+				resp, err = formatResponse(ForkChoiceResponse{PayloadStatus: PayloadStatus{Status: ForkchoiceStatusValid}, PayloadID: &hexutil.Bytes{1}})
+			case methodRelayGetHeader:
+				resp, err = formatResponse(ExecutionPayloadWithTxRootV1{
+					BlockHash:        common.HexToHash(blockHash),
+					ParentHash:       common.HexToHash(parentHash),
+					BaseFeePerGas:    big.NewInt(1),
+					FeeRecipientDiff: big.NewInt(value),
+				})
+			}
+			require.Nil(t, err)
+			w.Write(resp)
+		}))
+	}
+	offHead, onHead := newRelay("0x01", "0xdead", 2), newRelay("0x02", "0xaa", 1)
+	defer offHead.Close()
+	defer onHead.Close()
+
+	getHeader := func(beacon *httptest.Server, validatorPubkeys ...string) (*ExecutionPayloadWithTxRootV1, error) {
+		service, err := newRelayService(WithRelayURLs(offHead.URL, onHead.URL), WithStore(NewStore()), WithLogger(testLog),
+			WithChainStateChecks(NewBeaconClient(beacon.URL)), WithValidatorPubkeys(validatorPubkeys...))
+		require.Nil(t, err)
+
+		args := []interface{}{
+			map[string]interface{}{"headBlockHash": common.HexToHash("0xaa").Hex()},
+			map[string]interface{}{"timestamp": hexutil.Uint64(MainnetChainConfig.GenesisTime + 12).String()},
+		}
+		fcu := new(ForkChoiceResponse)
+		require.Nil(t, service.ForkchoiceUpdatedV1(nil, &args, fcu))
+
+		payloadID := fcu.PayloadID.String()
+		header := new(ExecutionPayloadWithTxRootV1)
+		return header, service.GetPayloadHeaderV1(nil, &payloadID, header)
+	}
+
+	beacon := newChainStateBeacon(0, common.HexToHash("0xaa"), "0xab")
+	defer beacon.Close()
+	header, err := getHeader(beacon, "0xab")
+	require.Nil(t, err)
+	require.Equal(t, common.HexToHash("0x02"), header.BlockHash, "the more valuable header doesn't build on the head of the beacon node")
+
+	_, err = getHeader(beacon, "0xcd")
+	require.ErrorIs(t, err, ErrChainStateMismatch)
+
+	ahead := newChainStateBeacon(1, common.HexToHash("0xaa"), "0xab")
+	defer ahead.Close()
+	_, err = getHeader(ahead)
+	require.ErrorIs(t, err, ErrChainStateMismatch)
+
+	unreachable := httptest.NewServer(http.NotFoundHandler())
+	defer unreachable.Close()
+	header, err = getHeader(unreachable, "0xcd")
+	require.Nil(t, err, "checks are skipped if the beacon node can't be reached")
+	require.Equal(t, common.HexToHash("0x02"), header.BlockHash)
+}
@@ -0,0 +1,140 @@
+package lib
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"strconv"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Statuses of a DeliveryVerification
+const (
+	// DeliveryIncluded means the block is in the finalized chain and paid the fee recipient at least the bid value
+	DeliveryIncluded = "included"
+	// DeliveryNotIncluded means the finalized chain has another block or no block at the slot of the delivery
+	DeliveryNotIncluded = "notIncluded"
+	// DeliveryUnderpaid means the block is in the finalized chain but paid the fee recipient less than the bid value
+	DeliveryUnderpaid = "underpaid"
+	// DeliveryUnknownFeeRecipient means the block is in the finalized chain, but its payment can't be checked because no
+	// fee recipient was registered for it
+	DeliveryUnknownFeeRecipient = "unknownFeeRecipient"
+)
+
+// maxDeliveryVerifications bounds the deliveries verified per run, so backfilling a long history doesn't flood the nodes
+var maxDeliveryVerifications = 256
+
+var deliveryVerificationsTotal = metricsFactory.NewCounterVec(prometheus.CounterOpts{
+	Name: "mevboost_delivery_verifications_total",
+	Help: "Delivered payloads checked against the finalized chain, by relay and status",
+}, []string{"relay", "status"})
+
+// DeliveryVerification is the outcome of checking a delivered payload against the finalized chain
+type DeliveryVerification struct {
+	Status string `json:"status"`
+	// Paid is the balance increase of the fee recipient in the block, nil unless the block was included and its fee
+	// recipient is known
+	Paid       *big.Int  `json:"paid,omitempty"`
+	VerifiedAt time.Time `json:"verifiedAt"`
+}
+
+// deliveryVerifier checks delivered payloads of finalized slots for inclusion on the beacon node and for their payment
+// on the execution client
+type deliveryVerifier struct {
+	beacon *BeaconClient
+	el     *ExecutionClient
+}
+
+// verify checks a delivery whose slot is finalized. Failures to reach the nodes are returned, the delivery is checked
+// again on the next run.
+func (v *deliveryVerifier) verify(ctx context.Context, delivery DeliveredPayload) (*DeliveryVerification, error) {
+	verification := &DeliveryVerification{VerifiedAt: now()}
+	blockHash, err := v.beacon.ExecutionBlockHash(ctx, strconv.FormatUint(delivery.Slot, 10))
+	switch {
+	case errors.Is(err, errBeaconNotFound):
+		verification.Status = DeliveryNotIncluded
+		return verification, nil
+	case err != nil:
+		return nil, err
+	case blockHash != delivery.BlockHash:
+		verification.Status = DeliveryNotIncluded
+		return verification, nil
+	case delivery.FeeRecipient == (common.Address{}):
+		verification.Status = DeliveryUnknownFeeRecipient
+		return verification, nil
+	}
+
+	before, err := v.el.BalanceAt(ctx, delivery.FeeRecipient, delivery.ParentHash)
+	if err != nil {
+		return nil, err
+	}
+	after, err := v.el.BalanceAt(ctx, delivery.FeeRecipient, delivery.BlockHash)
+	if err != nil {
+		return nil, err
+	}
+	verification.Paid = new(big.Int).Sub(after, before)
+	verification.Status = DeliveryIncluded
+	if delivery.Value != nil && verification.Paid.Cmp(delivery.Value) < 0 {
+		verification.Status = DeliveryUnderpaid
+	}
+	return verification, nil
+}
+
+// verifyDeliveries checks the deliveries of finalized slots that weren't checked yet and annotates them with the outcome
+func (m *RelayService) verifyDeliveries(ctx context.Context) error {
+	checkpoints, err := m.verifier.beacon.FinalityCheckpoints(ctx)
+	if err != nil {
+		return err
+	}
+	epoch, err := strconv.ParseUint(checkpoints.Finalized.Epoch, 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid finalized epoch %q: %w", checkpoints.Finalized.Epoch, err)
+	}
+
+	for _, delivery := range m.deliveries.unverified(epoch*m.chain.SlotsPerEpoch, maxDeliveryVerifications) {
+		verification, err := m.verifier.verify(ctx, delivery)
+		if err != nil {
+			return fmt.Errorf("could not verify delivery of slot %d: %w", delivery.Slot, err)
+		}
+		m.deliveries.markVerified(delivery.BlockHash, verification)
+		deliveryVerificationsTotal.WithLabelValues(delivery.RelayURL, verification.Status).Inc()
+		blockHash := delivery.BlockHash
+		m.events.publish(ctx, &Event{
+			Kind:      EventDeliveryVerified,
+			RelayURL:  delivery.RelayURL,
+			Slot:      delivery.Slot,
+			BlockHash: &blockHash,
+			Fields:    map[string]interface{}{"status": verification.Status, "paid": verification.Paid},
+		})
+
+		log := m.log.WithFields(Fields{
+			"slot":      delivery.Slot,
+			"blockHash": delivery.BlockHash,
+			"url":       delivery.RelayURL,
+			"status":    verification.Status,
+			"value":     delivery.Value,
+			"paid":      verification.Paid,
+		})
+		if verification.Status == DeliveryIncluded || verification.Status == DeliveryUnknownFeeRecipient {
+			log.Debug("verified delivered payload against the finalized chain")
+		} else {
+			log.Warn("delivered payload doesn't match the finalized chain")
+		}
+	}
+	return nil
+}
+
+// startDeliveryVerification verifies the deliveries of newly finalized slots once per epoch until ctx is done, starting
+// with a backfill of the deliveries recorded so far
+func (m *RelayService) startDeliveryVerification(ctx context.Context) {
+	interval := m.chain.SlotDuration() * time.Duration(m.chain.SlotsPerEpoch)
+	runLoop(ctx, m.log, "delivery_verification", interval, true, func(ctx context.Context) {
+		if err := m.verifyDeliveries(ctx); err != nil {
+			m.log.WithError(err).Warn("could not verify delivered payloads against the chain")
+		}
+	})
+}
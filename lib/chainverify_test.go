@@ -0,0 +1,61 @@
+package lib
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRelayService_verifyDeliveries(t *testing.T) {
+	hash := func(i int64) common.Hash { return common.BigToHash(big.NewInt(i)) }
+	block := func(blockHash common.Hash) string {
+		return fmt.Sprintf(`{"data":{"message":{"body":{"execution_payload":{"block_hash":"%s"}}}}}`, blockHash.Hex())
+	}
+	beacon := newMockBeaconNode(t, map[string]string{
+		"/eth/v1/beacon/states/head/finality_checkpoints": `{"data":{"previous_justified":{"epoch":"2","root":"0x01"},"current_justified":{"epoch":"3","root":"0x02"},"finalized":{"epoch":"2","root":"0x01"}}}`,
+		"/eth/v2/beacon/blocks/10":                        block(hash(2)),
+		"/eth/v2/beacon/blocks/11":                        block(hash(4)),
+		"/eth/v2/beacon/blocks/13":                        block(hash(5)),
+		"/eth/v2/beacon/blocks/14":                        block(hash(7)),
+	})
+	defer beacon.Close()
+	el := newMockExecutionNode(t, map[common.Hash]*big.Int{
+		hash(1): big.NewInt(100), hash(2): big.NewInt(107),
+		hash(3): big.NewInt(100), hash(4): big.NewInt(105),
+	})
+	defer el.Close()
+
+	relay, err := newRelayService(WithRelayURLs("http://relay"), WithLogger(testLog), WithDeliveryVerification(NewBeaconClient(beacon.URL), NewExecutionClient(el.URL)))
+	require.Nil(t, err)
+	proposer := common.HexToAddress("0x0f")
+	deliveries := []*DeliveredPayload{
+		{Slot: 10, BlockHash: hash(2), ParentHash: hash(1), FeeRecipient: proposer, Value: big.NewInt(5)},
+		{Slot: 11, BlockHash: hash(4), ParentHash: hash(3), FeeRecipient: proposer, Value: big.NewInt(10)},
+		{Slot: 12, BlockHash: hash(6), FeeRecipient: proposer, Value: big.NewInt(10)}, // empty slot
+		{Slot: 13, BlockHash: hash(5), Value: big.NewInt(10)},
+		{Slot: 14, BlockHash: hash(8), FeeRecipient: proposer, Value: big.NewInt(10)},  // another block
+		{Slot: 100, BlockHash: hash(9), FeeRecipient: proposer, Value: big.NewInt(10)}, // not finalized
+	}
+	relay.deliveries.merge(deliveries)
+
+	require.Nil(t, relay.verifyDeliveries(context.Background()))
+	statuses := make(map[uint64]string)
+	for _, delivery := range relay.deliveries.all() {
+		if delivery.Verification != nil {
+			statuses[delivery.Slot] = delivery.Verification.Status
+		}
+	}
+	require.Equal(t, map[uint64]string{
+		10: DeliveryIncluded,
+		11: DeliveryUnderpaid,
+		12: DeliveryNotIncluded,
+		13: DeliveryUnknownFeeRecipient,
+		14: DeliveryNotIncluded,
+	}, statuses)
+	require.Equal(t, big.NewInt(7), relay.deliveries.all()[0].Verification.Paid)
+	require.Empty(t, relay.deliveries.unverified(64, maxDeliveryVerifications), "verified deliveries aren't checked again")
+}
@@ -0,0 +1,185 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/flashbots/mev-boost/lib"
+)
+
+// CheckOpts configures CheckRelay
+type CheckOpts struct {
+	ChainConfig   *lib.ChainConfig // defaults to mainnet
+	HeadBlockHash common.Hash      // head the relay builds on, the zero hash lets the relay pick its own head
+	Propose       bool             // also reveal the payload, only allowed on networks other than mainnet
+	HTTPClient    *http.Client     // defaults to a client with a 5 second timeout
+}
+
+// CheckStep is the outcome of a single request made by CheckRelay
+type CheckStep struct {
+	Name     string
+	Method   string
+	Passed   bool
+	Skipped  bool
+	Duration time.Duration
+	Detail   string
+}
+
+// CheckReport is the outcome of CheckRelay
+type CheckReport struct {
+	RelayURL     string
+	FeeRecipient common.Address // throwaway fee recipient used for the checks
+	Steps        []*CheckStep
+}
+
+// Passed reports whether no step failed
+func (r *CheckReport) Passed() bool {
+	for _, step := range r.Steps {
+		if !step.Passed && !step.Skipped {
+			return false
+		}
+	}
+	return true
+}
+
+// CheckRelay runs the calls mev-boost makes during a proposal against a relay: it registers a throwaway fee recipient
+// through forkchoiceUpdated, gets a payload header for it and, on devnets, reveals the payload. The builder spec
+// mev-boost implements has no separate validator registration, registering is part of forkchoiceUpdated.
+func CheckRelay(ctx context.Context, relayURL string, opts CheckOpts) (*CheckReport, error) {
+	chain := opts.ChainConfig
+	if chain == nil {
+		chain = lib.MainnetChainConfig
+	}
+	if opts.Propose && chain.Name == lib.MainnetChainConfig.Name {
+		return nil, errors.New("revealing payloads is only allowed on devnets and testnets")
+	}
+
+	clientOpts := []Option{WithAPI(RelayAPI)}
+	if opts.HTTPClient != nil {
+		clientOpts = append(clientOpts, WithHTTPClient(opts.HTTPClient))
+	}
+	relay := New(relayURL, clientOpts...)
+
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		return nil, err
+	}
+	report := &CheckReport{
+		RelayURL:     relayURL,
+		FeeRecipient: crypto.PubkeyToAddress(key.PublicKey),
+	}
+
+	run := func(name, method string, call func() error) *CheckStep {
+		step := &CheckStep{Name: name, Method: method}
+		report.Steps = append(report.Steps, step)
+
+		start := time.Now()
+		err := call()
+		step.Duration = time.Since(start)
+
+		var rpcErr *Error
+		switch {
+		case errors.As(err, &rpcErr):
+			step.Detail = fmt.Sprintf("error reply from relay: %d %s", rpcErr.Code, rpcErr.Message)
+		case err != nil:
+			step.Detail = err.Error()
+		default:
+			step.Passed = true
+		}
+		return step
+	}
+
+	skip := func(name, method, reason string) {
+		report.Steps = append(report.Steps, &CheckStep{Name: name, Method: method, Skipped: true, Detail: reason})
+	}
+
+	// capabilities are optional, relays that don't report them are assumed to support all methods
+	var capabilities *lib.RelayCapabilities
+	step := run("capabilities", "relay_getCapabilitiesV1", func() (err error) {
+		capabilities, err = relay.Capabilities(ctx)
+		return err
+	})
+	if step.Passed {
+		step.Detail = fmt.Sprintf("spec version %s, methods %v", capabilities.SpecVersion, capabilities.Methods)
+	} else {
+		step.Skipped = true
+	}
+
+	attributes := &lib.PayloadAttributesV1{
+		Timestamp:             hexutil.Uint64(chain.SlotStartTime(chain.CurrentSlot() + 1).Unix()),
+		PrevRandao:            crypto.Keccak256Hash(report.FeeRecipient.Bytes()),
+		SuggestedFeeRecipient: report.FeeRecipient,
+	}
+	forkchoiceState := &lib.ForkchoiceStateV1{
+		HeadBlockHash: opts.HeadBlockHash,
+		SafeBlockHash: opts.HeadBlockHash,
+	}
+	var forkchoiceResponse *lib.ForkChoiceResponse
+	step = run("register", "engine_forkchoiceUpdatedV1", func() (err error) {
+		forkchoiceResponse, err = relay.ForkchoiceUpdated(ctx, forkchoiceState, attributes)
+		return err
+	})
+	if step.Passed && forkchoiceResponse.PayloadID == nil {
+		step.Passed = false
+		step.Detail = fmt.Sprintf("no payload id returned, status %s", forkchoiceResponse.PayloadStatus.Status)
+	}
+	if !step.Passed {
+		skip("getHeader", "relay_getPayloadHeaderV1", "registration failed")
+		skip("getPayload", "relay_proposeBlindedBlockV1", "registration failed")
+		return report, nil
+	}
+	step.Detail = fmt.Sprintf("payload id %s", forkchoiceResponse.PayloadID)
+
+	var header *lib.ExecutionPayloadWithTxRootV1
+	step = run("getHeader", "relay_getPayloadHeaderV1", func() (err error) {
+		header, err = relay.GetPayloadHeader(ctx, forkchoiceResponse.PayloadID.String())
+		return err
+	})
+	if step.Passed {
+		if header.BlockHash == (common.Hash{}) {
+			step.Passed = false
+			step.Detail = "header has no block hash"
+		} else {
+			step.Detail = fmt.Sprintf("block %s, number %d, value %s", header.BlockHash, header.Number, header.FeeRecipientDiff)
+		}
+	}
+
+	switch {
+	case !step.Passed:
+		skip("getPayload", "relay_proposeBlindedBlockV1", "no header")
+	case !opts.Propose:
+		skip("getPayload", "relay_proposeBlindedBlockV1", "revealing payloads is disabled")
+	default:
+		block := &lib.SignedBlindedBeaconBlock{
+			Message: &lib.BlindedBeaconBlock{
+				Slot: chain.SlotAt(uint64(attributes.Timestamp)),
+				Body: &lib.BlindedBeaconBlockBody{
+					SyncAggregate:          lib.SyncAggregate{SyncCommitteeBits: make(hexutil.Bytes, 64)},
+					ExecutionPayloadHeader: header.Header(),
+				},
+			},
+		}
+
+		var payload *lib.ExecutionPayloadWithTxRootV1
+		step = run("getPayload", "relay_proposeBlindedBlockV1", func() (err error) {
+			payload, err = relay.ProposeBlindedBlock(ctx, block)
+			return err
+		})
+		if step.Passed {
+			if payload.BlockHash != header.BlockHash {
+				step.Passed = false
+				step.Detail = fmt.Sprintf("revealed block %s doesn't match header block %s", payload.BlockHash, header.BlockHash)
+			} else {
+				step.Detail = fmt.Sprintf("revealed block %s", payload.BlockHash)
+			}
+		}
+	}
+
+	return report, nil
+}
@@ -0,0 +1,35 @@
+package client
+
+import (
+	"context"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/flashbots/mev-boost/lib"
+	"github.com/flashbots/mev-boost/lib/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckRelay(t *testing.T) {
+	relay := testutil.NewMockRelay()
+	defer relay.Close()
+	relay.SetBid(common.HexToHash("0x1bbf1a3d5b1c8a7a6e0c0a1b4c6c4ebbc2f8da4b8e4c1e2c3b2a1f0e0d0c0b0a"), big.NewInt(1))
+
+	report, err := CheckRelay(context.Background(), relay.URL(), CheckOpts{})
+	require.Nil(t, err)
+	require.True(t, report.Passed())
+	require.Len(t, report.Steps, 4)
+	require.True(t, report.Steps[0].Skipped, "capabilities are optional")
+	require.True(t, report.Steps[2].Passed, "getHeader")
+	require.True(t, report.Steps[3].Skipped, "getPayload is disabled by default")
+
+	_, err = CheckRelay(context.Background(), relay.URL(), CheckOpts{Propose: true})
+	require.Error(t, err, "revealing payloads on mainnet")
+
+	report, err = CheckRelay(context.Background(), relay.URL(), CheckOpts{ChainConfig: lib.SepoliaChainConfig, Propose: true})
+	require.Nil(t, err)
+	require.True(t, report.Passed())
+	require.True(t, report.Steps[3].Passed, "getPayload")
+	require.Len(t, relay.Requests(testutil.MethodProposeBlock), 1)
+}
@@ -0,0 +1,223 @@
+// Package client is a typed Go client of the builder API served by mev-boost and the relay API served by relays
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/flashbots/mev-boost/lib"
+)
+
+var defaultHTTPClient = &http.Client{
+	Timeout: 5 * time.Second,
+}
+
+// maxResponseSize bounds the memory a single response can use
+const maxResponseSize = 16 << 20
+
+// statusPath is the status endpoint of the builder specs, served by relays and mev-boost
+const statusPath = "/eth/v1/builder/status"
+
+// ErrNotReady is returned by Status if the server answers that it isn't ready, with status 503
+var ErrNotReady = errors.New("server is not ready")
+
+// API selects the method names of the client
+type API int
+
+const (
+	// BuilderAPI calls the builder_ methods served by mev-boost
+	BuilderAPI API = iota
+	// RelayAPI calls the relay_ methods served by relays
+	RelayAPI
+)
+
+// Client calls the builder or relay API of a single server
+type Client struct {
+	url        string
+	api        API
+	httpClient *http.Client
+	id         uint64
+}
+
+// Option configures a Client
+type Option func(*Client)
+
+// WithHTTPClient sets the HTTP client, defaults to a client with a 5 second timeout
+func WithHTTPClient(httpClient *http.Client) Option {
+	return func(c *Client) { c.httpClient = httpClient }
+}
+
+// WithAPI sets the API the server implements, defaults to BuilderAPI
+func WithAPI(api API) Option {
+	return func(c *Client) { c.api = api }
+}
+
+// New creates a client of the server at url
+func New(url string, opts ...Option) *Client {
+	c := &Client{url: url, httpClient: defaultHTTPClient}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// URL returns the url of the server
+func (c *Client) URL() string {
+	return c.url
+}
+
+// Error is a JSON-RPC error reply of the server. errors.Is matches it against the lib.Err* values of its code.
+type Error struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("error %d: %s", e.Code, e.Message)
+}
+
+// Is reports whether the error code is the one of target, for the lib.Err* values
+func (e *Error) Is(target error) bool {
+	switch target {
+	case lib.ErrNoBids:
+		return e.Code == lib.ErrorCodeNoBids
+	case lib.ErrRelayTimeout:
+		return e.Code == lib.ErrorCodeRelayTimeout
+	case lib.ErrValidationFailed, lib.ErrHeaderMismatch, lib.ErrInvalidSignature:
+		return e.Code == lib.ErrorCodeValidationFailed
+	case lib.ErrUnknownPayload:
+		return e.Code == lib.ErrorCodeUnknownPayload
+	case lib.ErrStalePayloadID:
+		return e.Code == lib.ErrorCodeStalePayloadID
+	case lib.ErrChainStateMismatch:
+		return e.Code == lib.ErrorCodeChainStateMismatch
+	case lib.ErrBuildLocally:
+		return e.Code == lib.ErrorCodeBuildLocally
+	}
+	return false
+}
+
+// Call calls a JSON-RPC method and decodes its result into result
+func (c *Client) Call(ctx context.Context, method string, result interface{}, params ...interface{}) error {
+	if params == nil {
+		params = []interface{}{}
+	}
+	body, err := json.Marshal(map[string]interface{}{
+		"jsonrpc": "2.0",
+		"id":      atomic.AddUint64(&c.id, 1),
+		"method":  method,
+		"params":  params,
+	})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Add("Content-Type", "application/json")
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	res := struct {
+		Result interface{} `json:"result"`
+		Error  *Error      `json:"error"`
+	}{Result: result}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxResponseSize)).Decode(&res); err != nil {
+		return fmt.Errorf("could not decode response of %s (status %d): %w", method, resp.StatusCode, err)
+	}
+	if res.Error != nil {
+		return res.Error
+	}
+	return nil
+}
+
+func (c *Client) method(builderMethod, relayMethod string) string {
+	if c.api == RelayAPI {
+		return relayMethod
+	}
+	return builderMethod
+}
+
+// ForkchoiceUpdated registers the payload attributes of the next proposal, and returns the payload id for GetPayloadHeader.
+// This is the registration of the proposer's fee recipient with the builders.
+func (c *Client) ForkchoiceUpdated(ctx context.Context, state *lib.ForkchoiceStateV1, attributes *lib.PayloadAttributesV1) (*lib.ForkChoiceResponse, error) {
+	res := new(lib.ForkChoiceResponse)
+	if err := c.Call(ctx, "engine_forkchoiceUpdatedV1", res, state, attributes); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// GetPayloadHeader returns the best payload header for a payload id
+func (c *Client) GetPayloadHeader(ctx context.Context, payloadID string) (*lib.ExecutionPayloadWithTxRootV1, error) {
+	header := new(lib.ExecutionPayloadWithTxRootV1)
+	if err := c.Call(ctx, c.method("builder_getPayloadHeaderV1", "relay_getPayloadHeaderV1"), header, payloadID); err != nil {
+		return nil, err
+	}
+	return header, nil
+}
+
+// ProposeBlindedBlock reveals the payload of a signed blinded block
+func (c *Client) ProposeBlindedBlock(ctx context.Context, block *lib.SignedBlindedBeaconBlock) (*lib.ExecutionPayloadWithTxRootV1, error) {
+	if block == nil || block.Message == nil {
+		return nil, errors.New("block without message")
+	}
+	payload := new(lib.ExecutionPayloadWithTxRootV1)
+	if err := c.Call(ctx, c.method("builder_proposeBlindedBlockV1", "relay_proposeBlindedBlockV1"), payload, block); err != nil {
+		return nil, err
+	}
+	return payload, nil
+}
+
+// Capabilities returns the spec version and methods a relay supports. Relays that don't implement it reply with error code -32601.
+func (c *Client) Capabilities(ctx context.Context) (*lib.RelayCapabilities, error) {
+	capabilities := new(lib.RelayCapabilities)
+	if err := c.Call(ctx, "relay_getCapabilitiesV1", capabilities); err != nil {
+		return nil, err
+	}
+	return capabilities, nil
+}
+
+// Status asks the server whether it's ready on the status endpoint of the builder specs. mev-boost with readiness
+// checks answers with the readiness of its relays and engines, other servers with Ready set and no further details.
+// A server that isn't ready returns its status together with ErrNotReady.
+func (c *Client) Status(ctx context.Context) (*lib.ReadinessStatus, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimRight(c.url, "/")+statusPath, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Add("Accept", "application/json")
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusServiceUnavailable {
+		return nil, fmt.Errorf("status endpoint returned status %d", resp.StatusCode)
+	}
+	status := new(lib.ReadinessStatus)
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxResponseSize)).Decode(status); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("could not decode response of the status endpoint (status %d): %w", resp.StatusCode, err)
+	}
+	status.Ready = resp.StatusCode == http.StatusOK
+	if !status.Ready {
+		return status, ErrNotReady
+	}
+	return status, nil
+}
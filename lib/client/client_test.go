@@ -0,0 +1,72 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/flashbots/mev-boost/lib"
+	"github.com/flashbots/mev-boost/lib/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClient_Capabilities(t *testing.T) {
+	relay := testutil.NewMockRelay()
+	defer relay.Close()
+	relay.SetCapabilities(&lib.RelayCapabilities{SpecVersion: "0.1", Methods: []string{"relay_getPayloadHeaderV1"}})
+
+	capabilities, err := New(relay.URL(), WithAPI(RelayAPI)).Capabilities(context.Background())
+	require.Nil(t, err)
+	require.Equal(t, "0.1", capabilities.SpecVersion)
+	require.Equal(t, []string{"relay_getPayloadHeaderV1"}, capabilities.Methods)
+}
+
+func TestClient_ErrorCodes(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"jsonrpc":"2.0","id":1,"result":null,"error":{"code":-32001,"message":"no valid response from relay"}}`))
+	}))
+	defer server.Close()
+
+	_, err := New(server.URL).GetPayloadHeader(context.Background(), "0x01")
+	require.True(t, errors.Is(err, lib.ErrNoBids))
+	require.False(t, errors.Is(err, lib.ErrRelayTimeout))
+
+	var rpcErr *Error
+	require.True(t, errors.As(err, &rpcErr))
+	require.Equal(t, lib.ErrorCodeNoBids, rpcErr.Code)
+}
+
+func TestClient_Status(t *testing.T) {
+	tests := []struct {
+		name      string
+		code      int
+		body      string
+		wantReady bool
+		wantErr   error
+	}{
+		{"ready", http.StatusOK, `{"status":"ok"}`, true, nil},
+		{"empty body", http.StatusOK, ``, true, nil},
+		{"not ready", http.StatusServiceUnavailable, `{"ready":false,"relays":[{"url":"http://relay","ready":false}]}`, false, ErrNotReady},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				require.Equal(t, "/eth/v1/builder/status", r.URL.Path)
+				w.WriteHeader(tt.code)
+				w.Write([]byte(tt.body))
+			}))
+			defer server.Close()
+
+			status, err := New(server.URL + "/").Status(context.Background())
+			require.Equal(t, tt.wantErr, err)
+			require.Equal(t, tt.wantReady, status.Ready)
+		})
+	}
+
+	server := httptest.NewServer(http.NotFoundHandler())
+	defer server.Close()
+	_, err := New(server.URL).Status(context.Background())
+	require.Error(t, err)
+}
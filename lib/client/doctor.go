@@ -0,0 +1,291 @@
+package client
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/flashbots/mev-boost/lib"
+)
+
+// FindingStatus is the outcome of a Doctor check
+type FindingStatus string
+
+// Outcomes of Doctor checks
+const (
+	FindingOK      FindingStatus = "OK"
+	FindingWarning FindingStatus = "WARN"
+	FindingFailed  FindingStatus = "FAIL"
+	FindingSkipped FindingStatus = "SKIP"
+)
+
+// DoctorOpts configures Doctor, checks whose settings are empty are skipped
+type DoctorOpts struct {
+	ChainConfig      *lib.ChainConfig // network mev-boost is configured for, defaults to mainnet
+	RelayURLs        []string
+	BeaconNodeURL    string
+	ExecutionNodeURL string
+	NTPServer        string        // host:port of an NTP server the clock is compared to
+	MaxClockSkew     time.Duration // defaults to 500ms
+	HTTPClient       *http.Client  // defaults to a client with a 5 second timeout
+}
+
+// Finding is the outcome of a single Doctor check
+type Finding struct {
+	Check  string
+	Status FindingStatus
+	Detail string
+	Fix    string // what to change, empty if nothing needs to
+}
+
+// DoctorReport is the outcome of Doctor
+type DoctorReport struct {
+	Findings []*Finding
+}
+
+// Passed reports whether no check failed
+func (r *DoctorReport) Passed() bool {
+	for _, finding := range r.Findings {
+		if finding.Status == FindingFailed {
+			return false
+		}
+	}
+	return true
+}
+
+// Doctor checks the configuration and environment of mev-boost for the misconfigurations most support requests are
+// about: a skewed clock, a network that doesn't match the beacon node, and unreachable relays or execution client.
+func Doctor(ctx context.Context, opts DoctorOpts) *DoctorReport {
+	chain := opts.ChainConfig
+	if chain == nil {
+		chain = lib.MainnetChainConfig
+	}
+	maxSkew := opts.MaxClockSkew
+	if maxSkew == 0 {
+		maxSkew = 500 * time.Millisecond
+	}
+
+	report := new(DoctorReport)
+	report.Findings = append(report.Findings, checkClock(ctx, opts.NTPServer, maxSkew))
+	report.Findings = append(report.Findings, checkNetwork(ctx, chain, opts.BeaconNodeURL))
+	if len(opts.RelayURLs) == 0 {
+		report.Findings = append(report.Findings, &Finding{Check: "relays", Status: FindingFailed, Detail: "no relays configured", Fix: "set -relayUrl"})
+	}
+	for _, relayURL := range opts.RelayURLs {
+		for _, endpoint := range lib.RelayEndpoints(relayURL) {
+			report.Findings = append(report.Findings, checkRelayReachable(ctx, endpoint, opts.HTTPClient))
+		}
+	}
+	report.Findings = append(report.Findings, checkExecutionNode(ctx, opts.ExecutionNodeURL))
+	return report
+}
+
+func checkClock(ctx context.Context, server string, maxSkew time.Duration) *Finding {
+	finding := &Finding{Check: "clock"}
+	if server == "" {
+		finding.Status = FindingSkipped
+		finding.Detail = "no NTP server configured"
+		return finding
+	}
+
+	offset, err := ntpOffset(ctx, server)
+	if err != nil {
+		finding.Status = FindingWarning
+		finding.Detail = fmt.Sprintf("could not query NTP server %s: %v", server, err)
+		return finding
+	}
+	finding.Detail = fmt.Sprintf("offset to %s is %s", server, offset.Round(time.Millisecond))
+	if offset > maxSkew || offset < -maxSkew {
+		finding.Status = FindingFailed
+		finding.Fix = "enable time synchronization, e.g. chrony or systemd-timesyncd, a skewed clock makes mev-boost misjudge slots"
+		return finding
+	}
+	finding.Status = FindingOK
+	return finding
+}
+
+// ntpEpochOffset is the number of seconds between the NTP epoch 1900 and the unix epoch
+const ntpEpochOffset = 2208988800
+
+// ntpOffset returns how far the local clock is behind server, using a single SNTP request, see RFC 4330
+func ntpOffset(ctx context.Context, server string) (time.Duration, error) {
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "udp", server)
+	if err != nil {
+		return 0, err
+	}
+	defer conn.Close()
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		deadline = time.Now().Add(5 * time.Second)
+	}
+	conn.SetDeadline(deadline)
+
+	request := make([]byte, 48)
+	request[0] = 0x23 // leap indicator 0, version 4, client mode
+	sent := time.Now()
+	if _, err := conn.Write(request); err != nil {
+		return 0, err
+	}
+	response := make([]byte, 48)
+	n, err := conn.Read(response)
+	if err != nil {
+		return 0, err
+	}
+	received := time.Now()
+	if n < 48 || response[0]&0x07 != 4 {
+		return 0, errors.New("invalid NTP response")
+	}
+
+	serverReceived, serverSent := ntpTime(response[32:40]), ntpTime(response[40:48])
+	return (serverReceived.Sub(sent) + serverSent.Sub(received)) / 2, nil
+}
+
+// ntpTime decodes an NTP timestamp of 32 bit seconds and 32 bit fraction
+func ntpTime(b []byte) time.Time {
+	seconds := int64(binary.BigEndian.Uint32(b[:4])) - ntpEpochOffset
+	fraction := int64(binary.BigEndian.Uint32(b[4:])) * int64(time.Second) >> 32
+	return time.Unix(seconds, fraction)
+}
+
+func checkNetwork(ctx context.Context, chain *lib.ChainConfig, beaconNodeURL string) *Finding {
+	finding := &Finding{Check: "network", Detail: fmt.Sprintf("configured for %s", chain.Name)}
+	if beaconNodeURL == "" {
+		finding.Status = FindingSkipped
+		finding.Detail += ", no beacon node to compare to"
+		return finding
+	}
+
+	beaconChain, err := lib.NewBeaconClient(beaconNodeURL).ChainConfig(ctx)
+	if err != nil {
+		finding.Status = FindingFailed
+		finding.Detail = fmt.Sprintf("could not get chain config of beacon node: %v", err)
+		finding.Fix = "check -beaconNodeUrl and that the beacon node REST API is enabled"
+		return finding
+	}
+
+	var mismatches []string
+	compare := func(name string, configured, beacon interface{}) {
+		if fmt.Sprint(configured) != fmt.Sprint(beacon) {
+			mismatches = append(mismatches, fmt.Sprintf("%s %v, beacon node has %v", name, configured, beacon))
+		}
+	}
+	compare("genesis time", chain.GenesisTime, beaconChain.GenesisTime)
+	compare("genesis fork version", hexutil.Encode(chain.GenesisForkVersion[:]), hexutil.Encode(beaconChain.GenesisForkVersion[:]))
+	compare("bellatrix fork version", hexutil.Encode(chain.BellatrixForkVersion[:]), hexutil.Encode(beaconChain.BellatrixForkVersion[:]))
+	compare("bellatrix fork epoch", chain.BellatrixForkEpoch, beaconChain.BellatrixForkEpoch)
+	compare("capella fork version", hexutil.Encode(chain.CapellaForkVersion[:]), hexutil.Encode(beaconChain.CapellaForkVersion[:]))
+	compare("capella fork epoch", chain.CapellaForkEpoch, beaconChain.CapellaForkEpoch)
+	compare("seconds per slot", chain.SecondsPerSlot, beaconChain.SecondsPerSlot)
+	compare("slots per epoch", chain.SlotsPerEpoch, beaconChain.SlotsPerEpoch)
+	if len(mismatches) > 0 {
+		finding.Status = FindingFailed
+		finding.Detail += ": " + strings.Join(mismatches, "; ")
+		finding.Fix = "set -network or -chainConfig to the network of the beacon node"
+		return finding
+	}
+	finding.Status = FindingOK
+	finding.Detail += ", matches the beacon node"
+	return finding
+}
+
+func checkRelayReachable(ctx context.Context, relayURL string, httpClient *http.Client) *Finding {
+	finding := &Finding{Check: "relay " + relayURL}
+	if err := ValidateRelayURL(relayURL); err != nil {
+		finding.Status = FindingFailed
+		finding.Detail = err.Error()
+		finding.Fix = "relay urls look like https://relay.example.com, or https://0x<pubkey>@relay.example.com as documented by the relay"
+		return finding
+	}
+
+	clientOpts := []Option{WithAPI(RelayAPI)}
+	if httpClient != nil {
+		clientOpts = append(clientOpts, WithHTTPClient(httpClient))
+	}
+	capabilities, err := New(relayURL, clientOpts...).Capabilities(ctx)
+	var rpcErr *Error
+	switch {
+	case err == nil:
+		finding.Status = FindingOK
+		finding.Detail = fmt.Sprintf("reachable, builder spec %s", capabilities.SpecVersion)
+	case errors.As(err, &rpcErr):
+		// the relay answered, it just doesn't implement capabilities
+		finding.Status = FindingOK
+		finding.Detail = "reachable"
+	default:
+		finding.Status = FindingFailed
+		finding.Detail = fmt.Sprintf("unreachable: %v", err)
+		finding.Fix = "check the relay url and that the relay is up, `mev-boost test-relay` checks it in depth"
+	}
+	return finding
+}
+
+// ValidateRelayEntry checks each endpoint of a relay entry with ValidateRelayURL, and that they have the same pubkey
+func ValidateRelayEntry(entry string) error {
+	endpoints := lib.RelayEndpoints(entry)
+	if len(endpoints) == 0 {
+		return fmt.Errorf("relay entry %q has no endpoints", entry)
+	}
+	var pubkey string
+	for i, endpoint := range endpoints {
+		if err := ValidateRelayURL(endpoint); err != nil {
+			return err
+		}
+		u, _ := url.Parse(endpoint)
+		if i == 0 {
+			pubkey = u.User.Username()
+		} else if u.User.Username() != pubkey {
+			return fmt.Errorf("endpoints of relay %s have different pubkeys", endpoints[0])
+		}
+	}
+	return nil
+}
+
+// ValidateRelayURL checks that relayURL is an http(s) url, and that the relay pubkey in its user part is a BLS pubkey if
+// present. The password of the user part is the token of a whitelabel mev-boost.
+func ValidateRelayURL(relayURL string) error {
+	u, err := url.Parse(relayURL)
+	if err != nil {
+		return err
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("%q is not an http(s) url", relayURL)
+	}
+	if u.User != nil && u.User.Username() != "" {
+		pubkey, err := hexutil.Decode(u.User.Username())
+		if err != nil {
+			return fmt.Errorf("relay pubkey %q is not 0x prefixed hex", u.User.Username())
+		}
+		if err := lib.ValidatePubkey(pubkey); err != nil {
+			return fmt.Errorf("relay pubkey %q is invalid: %w", u.User.Username(), err)
+		}
+	}
+	return nil
+}
+
+func checkExecutionNode(ctx context.Context, executionNodeURL string) *Finding {
+	finding := &Finding{Check: "execution client"}
+	if executionNodeURL == "" {
+		finding.Status = FindingSkipped
+		finding.Detail = "no execution client configured"
+		return finding
+	}
+
+	chainID, err := lib.NewExecutionClient(executionNodeURL).ChainID(ctx)
+	if err != nil {
+		finding.Status = FindingFailed
+		finding.Detail = fmt.Sprintf("unreachable: %v", err)
+		finding.Fix = "check -executionNodeUrl and that the HTTP JSON-RPC API of the execution client is enabled"
+		return finding
+	}
+	finding.Status = FindingOK
+	finding.Detail = fmt.Sprintf("reachable, chain id %s", chainID)
+	return finding
+}
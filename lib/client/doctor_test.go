@@ -0,0 +1,94 @@
+package client
+
+import (
+	"context"
+	"encoding/binary"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/flashbots/mev-boost/lib"
+	"github.com/flashbots/mev-boost/lib/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+// newMockNTPServer answers SNTP requests with a clock that is skew ahead of the local one
+func newMockNTPServer(t *testing.T, skew time.Duration) string {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.Nil(t, err)
+	t.Cleanup(func() { conn.Close() })
+
+	go func() {
+		request := make([]byte, 48)
+		for {
+			_, addr, err := conn.ReadFrom(request)
+			if err != nil {
+				return
+			}
+			response := make([]byte, 48)
+			response[0] = 0x24 // version 4, server mode
+			now := time.Now().Add(skew)
+			binary.BigEndian.PutUint32(response[32:], uint32(now.Unix()+ntpEpochOffset))
+			binary.BigEndian.PutUint32(response[36:], uint32((int64(now.Nanosecond())<<32)/int64(time.Second)))
+			copy(response[40:], response[32:40])
+			conn.WriteTo(response, addr)
+		}
+	}()
+	return conn.LocalAddr().String()
+}
+
+func TestDoctor(t *testing.T) {
+	relay := testutil.NewMockRelay()
+	defer relay.Close()
+	down := httptest.NewServer(http.NotFoundHandler())
+	down.Close()
+	beaconNode := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		responses := map[string]string{
+			"/eth/v1/config/spec":          `{"data":{"CONFIG_NAME":"sepolia","SECONDS_PER_SLOT":"12","SLOTS_PER_EPOCH":"32","TERMINAL_TOTAL_DIFFICULTY":"17000000000000000"}}`,
+			"/eth/v1/beacon/genesis":       `{"data":{"genesis_time":"1655733600","genesis_validators_root":"0x01","genesis_fork_version":"0x90000069"}}`,
+			"/eth/v1/config/fork_schedule": `{"data":[{"previous_version":"0x90000069","current_version":"0x90000069","epoch":"0"},{"previous_version":"0x90000069","current_version":"0x90000070","epoch":"50"},{"previous_version":"0x90000070","current_version":"0x90000071","epoch":"100"}]}`,
+		}
+		w.Write([]byte(responses[r.URL.Path]))
+	}))
+	defer beaconNode.Close()
+
+	ctx := context.Background()
+	report := Doctor(ctx, DoctorOpts{
+		ChainConfig:   lib.SepoliaChainConfig,
+		RelayURLs:     []string{relay.URL()},
+		BeaconNodeURL: beaconNode.URL,
+		NTPServer:     newMockNTPServer(t, 0),
+	})
+	require.True(t, report.Passed())
+	statuses := make([]FindingStatus, len(report.Findings))
+	for i, finding := range report.Findings {
+		statuses[i] = finding.Status
+	}
+	require.Equal(t, []FindingStatus{FindingOK, FindingOK, FindingOK, FindingSkipped}, statuses)
+
+	report = Doctor(ctx, DoctorOpts{
+		RelayURLs:     []string{down.URL, "http://0x1234@relay.example.com"},
+		BeaconNodeURL: beaconNode.URL,
+		NTPServer:     newMockNTPServer(t, 3*time.Second),
+	})
+	require.False(t, report.Passed())
+	require.Equal(t, FindingFailed, report.Findings[0].Status, "clock is 3s behind")
+	require.Equal(t, FindingFailed, report.Findings[1].Status, "mainnet config with a sepolia beacon node")
+	require.Contains(t, report.Findings[1].Detail, "bellatrix fork version 0x02000000, beacon node has 0x90000071")
+	require.Equal(t, FindingFailed, report.Findings[2].Status, "relay is down")
+	require.Equal(t, FindingFailed, report.Findings[3].Status, "relay pubkey is too short")
+	for _, finding := range report.Findings[:4] {
+		require.NotEmpty(t, finding.Fix)
+	}
+}
+
+func TestValidateRelayEntry(t *testing.T) {
+	pubkey := "0x8b5d2e73e2a3a55c6c87b8b6eb92e0149a125c852751db1422fa951e42a09b82c142c3ea98d0d9930b056a3bc9896b8f"
+	require.Nil(t, ValidateRelayEntry("https://relay.example.com"))
+	require.Nil(t, ValidateRelayEntry("https://"+pubkey+"@eu.relay.example.com|https://"+pubkey+"@us.relay.example.com"))
+	require.Error(t, ValidateRelayEntry("https://eu.relay.example.com|ftp://us.relay.example.com"))
+	require.Error(t, ValidateRelayEntry("https://"+pubkey+"@eu.relay.example.com|https://us.relay.example.com"), "endpoints of a relay share its pubkey")
+	require.Error(t, ValidateRelayEntry("|"))
+}
@@ -0,0 +1,147 @@
+package lib
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// clockCheckInterval is how often the local clock is compared with the NTP server
+var clockCheckInterval = 5 * time.Minute
+
+var clockOffsetSeconds = metricsFactory.NewGauge(prometheus.GaugeOpts{
+	Name: "mevboost_clock_offset_seconds",
+	Help: "Offset of the NTP server clock to the local clock, positive if the local clock is behind",
+})
+
+// ntpEpochOffset is the number of seconds between the NTP epoch 1900 and the unix epoch 1970
+const ntpEpochOffset = 2208988800
+
+// clockSkew compares the local clock with an NTP server, since bid cutoffs like the attestation deadline are relative
+// to slots, and a drifted clock silently cuts bids off too early or too late. Skew beyond threshold is logged as an
+// error, and with adjust the deadlines are moved by the offset, so they're met on the clock of the network.
+type clockSkew struct {
+	server    string
+	threshold time.Duration
+	adjust    bool
+	log       Logger
+
+	mu     sync.RWMutex
+	offset time.Duration // offset of the server clock to the local one, 0 unless it exceeds threshold
+}
+
+func newClockSkew(server string, threshold time.Duration, adjust bool, log Logger) *clockSkew {
+	if _, _, err := net.SplitHostPort(server); err != nil {
+		server = net.JoinHostPort(server, "123")
+	}
+	return &clockSkew{server: server, threshold: threshold, adjust: adjust, log: log.WithField("ntpServer", server)}
+}
+
+// check measures the offset of the local clock
+func (c *clockSkew) check(ctx context.Context) error {
+	offset, err := queryNTP(ctx, c.server)
+	if err != nil {
+		return err
+	}
+	clockOffsetSeconds.Set(offset.Seconds())
+
+	skewed := offset > c.threshold || offset < -c.threshold
+	if skewed {
+		log := c.log.WithFields(Fields{"offset": offset, "threshold": c.threshold})
+		if c.adjust {
+			log.Error("local clock is skewed, moving slot deadlines by the offset, fix the time synchronization of the host")
+		} else {
+			log.Error("local clock is skewed, bids are cut off at the wrong time, fix the time synchronization of the host")
+		}
+	} else {
+		offset = 0
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.offset = offset
+	return nil
+}
+
+// local converts a time of the network clock, e.g. a slot deadline, to the local clock if deadlines are adjusted
+func (c *clockSkew) local(t time.Time) time.Time {
+	if c == nil || !c.adjust {
+		return t
+	}
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return t.Add(-c.offset)
+}
+
+// startClockSkewChecks compares the local clock with the NTP server every clockCheckInterval until ctx is done
+func (m *RelayService) startClockSkewChecks(ctx context.Context) {
+	runLoop(ctx, m.log, "clock_skew", clockCheckInterval, true, func(ctx context.Context) {
+		if err := m.clock.check(ctx); err != nil {
+			m.log.WithError(err).Warn("could not compare the local clock with the NTP server")
+		}
+	})
+}
+
+// queryNTP returns the offset of the clock of an NTP server to the local clock, with a simple SNTP request (RFC 4330)
+func queryNTP(ctx context.Context, server string) (time.Duration, error) {
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "udp", server)
+	if err != nil {
+		return 0, err
+	}
+	defer conn.Close()
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		deadline = time.Now().Add(5 * time.Second)
+	}
+	if err := conn.SetDeadline(deadline); err != nil {
+		return 0, err
+	}
+
+	req := make([]byte, 48)
+	req[0] = 0x23 // no leap warning, version 4, client mode
+	sent := time.Now()
+	binary.BigEndian.PutUint64(req[40:], toNTPTime(sent))
+	if _, err := conn.Write(req); err != nil {
+		return 0, err
+	}
+	resp := make([]byte, 48)
+	n, err := conn.Read(resp)
+	if err != nil {
+		return 0, err
+	}
+	received := time.Now()
+
+	switch {
+	case n < 48:
+		return 0, fmt.Errorf("short NTP response of %d bytes", n)
+	case resp[0]&0x7 != 4:
+		return 0, errors.New("NTP response isn't in server mode")
+	case resp[1] == 0:
+		return 0, fmt.Errorf("NTP server sent kiss code %q", resp[12:16])
+	case binary.BigEndian.Uint64(resp[24:]) != binary.BigEndian.Uint64(req[40:]):
+		return 0, errors.New("NTP response doesn't answer the request")
+	}
+	serverReceived := fromNTPTime(binary.BigEndian.Uint64(resp[32:]))
+	serverSent := fromNTPTime(binary.BigEndian.Uint64(resp[40:]))
+	return (serverReceived.Sub(sent) + serverSent.Sub(received)) / 2, nil
+}
+
+// toNTPTime encodes t as NTP timestamp, seconds since 1900 in the upper 32 bits and the fraction in the lower ones
+func toNTPTime(t time.Time) uint64 {
+	seconds := uint64(t.Unix() + ntpEpochOffset)
+	fraction := uint64(t.Nanosecond()) << 32 / uint64(time.Second)
+	return seconds<<32 | fraction
+}
+
+func fromNTPTime(ntp uint64) time.Time {
+	seconds := int64(ntp>>32) - ntpEpochOffset
+	nanos := int64(math.Round(float64(ntp&0xffffffff) * float64(time.Second) / (1 << 32)))
+	return time.Unix(seconds, nanos)
+}
@@ -0,0 +1,70 @@
+package lib
+
+import (
+	"context"
+	"encoding/binary"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// newMockNTPServer answers SNTP requests with a clock that is offset ahead of the local one, stratum 0 sends a kiss code
+func newMockNTPServer(t *testing.T, offset time.Duration, stratum byte) string {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.Nil(t, err)
+	t.Cleanup(func() { conn.Close() })
+	go func() {
+		req := make([]byte, 48)
+		for {
+			n, addr, err := conn.ReadFrom(req)
+			if err != nil {
+				return
+			}
+			if n < 48 {
+				continue
+			}
+			resp := make([]byte, 48)
+			resp[0], resp[1] = 0x24, stratum // version 4, server mode
+			copy(resp[12:16], "RATE")
+			copy(resp[24:32], req[40:48])
+			serverTime := toNTPTime(time.Now().Add(offset))
+			binary.BigEndian.PutUint64(resp[32:], serverTime)
+			binary.BigEndian.PutUint64(resp[40:], serverTime)
+			conn.WriteTo(resp, addr)
+		}
+	}()
+	return conn.LocalAddr().String()
+}
+
+func TestClockSkew(t *testing.T) {
+	server := newMockNTPServer(t, 2*time.Second, 2)
+	deadline := time.Unix(1000, 0)
+
+	clock := newClockSkew(server, 500*time.Millisecond, true, testLog)
+	require.Nil(t, clock.check(context.Background()))
+	require.InDelta(t, 2*time.Second, clock.offset, float64(100*time.Millisecond))
+	require.InDelta(t, float64(deadline.Add(-2*time.Second).UnixNano()), float64(clock.local(deadline).UnixNano()), float64(100*time.Millisecond), "the local clock is behind, so deadlines come earlier")
+
+	warnOnly := newClockSkew(server, 500*time.Millisecond, false, testLog)
+	require.Nil(t, warnOnly.check(context.Background()))
+	require.Equal(t, deadline, warnOnly.local(deadline))
+
+	tolerant := newClockSkew(server, 5*time.Second, true, testLog)
+	require.Nil(t, tolerant.check(context.Background()))
+	require.Equal(t, deadline, tolerant.local(deadline), "offsets within the threshold aren't adjusted for")
+
+	var disabled *clockSkew
+	require.Equal(t, deadline, disabled.local(deadline))
+}
+
+func TestQueryNTP_kissOfDeath(t *testing.T) {
+	_, err := queryNTP(context.Background(), newMockNTPServer(t, 0, 0))
+	require.EqualError(t, err, `NTP server sent kiss code "RATE"`)
+}
+
+func TestNTPTime(t *testing.T) {
+	ts := time.Unix(1656000000, 250000000)
+	require.Equal(t, ts, fromNTPTime(toNTPTime(ts)))
+}
@@ -0,0 +1,338 @@
+package lib
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+// ClientCompat selects the consensus client whose quirks are worked around
+type ClientCompat string
+
+// Compatibility modes of WithClientCompat
+const (
+	// CompatAuto detects the consensus client from the User-Agent header of each request
+	CompatAuto     ClientCompat = "auto"
+	CompatNone     ClientCompat = "none"
+	CompatTeku     ClientCompat = "teku"
+	CompatNimbus   ClientCompat = "nimbus"
+	CompatLodestar ClientCompat = "lodestar"
+)
+
+// ParseClientCompat parses the name of a compatibility mode
+func ParseClientCompat(name string) (ClientCompat, error) {
+	mode := ClientCompat(strings.ToLower(name))
+	if _, ok := clientQuirksByMode[mode]; !ok && mode != CompatAuto {
+		return "", fmt.Errorf("unknown client compatibility mode %q, expected auto, none, teku, nimbus or lodestar", name)
+	}
+	return mode, nil
+}
+
+// clientQuirks are the deviations of a consensus client from the encoding mev-boost speaks
+type clientQuirks struct {
+	// methodAliases maps method names the client calls to the ones mev-boost serves
+	methodAliases map[string]string
+	// decimalQuantities means the client sends quantities of payload attributes as decimal strings instead of hex
+	decimalQuantities bool
+	// snakeCasePayloads means the client expects payload headers and payloads in the beacon API encoding, with snake_case
+	// field names and decimal quantities
+	snakeCasePayloads bool
+}
+
+var clientQuirksByMode = map[ClientCompat]clientQuirks{
+	CompatNone: {},
+	CompatTeku: {methodAliases: map[string]string{
+		"builder_getHeaderV1":  "builder_getPayloadHeaderV1",
+		"builder_getPayloadV1": "builder_proposeBlindedBlockV1",
+	}},
+	CompatNimbus:   {decimalQuantities: true},
+	CompatLodestar: {snakeCasePayloads: true},
+}
+
+// quirksFor returns the quirks of the client of req in the given mode
+func quirksFor(mode ClientCompat, req *http.Request) (ClientCompat, clientQuirks) {
+	if mode == CompatAuto {
+		mode = CompatNone
+		userAgent := strings.ToLower(req.Header.Get("User-Agent"))
+		for _, client := range []ClientCompat{CompatTeku, CompatNimbus, CompatLodestar} {
+			if strings.Contains(userAgent, string(client)) {
+				mode = client
+				break
+			}
+		}
+	}
+	return mode, clientQuirksByMode[mode]
+}
+
+// compatRequest is a JSON-RPC request whose params are rewritten as needed
+type compatRequest struct {
+	ID      json.RawMessage   `json:"id"`
+	JSONRPC string            `json:"jsonrpc"`
+	Method  string            `json:"method"`
+	Params  []json.RawMessage `json:"params"`
+}
+
+// clientCompatHandler rewrites JSON-RPC requests and responses for the quirks of the calling consensus client
+func clientCompatHandler(mode ClientCompat, log Logger, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		client, quirks := quirksFor(mode, r)
+		if client == CompatNone {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		body, err := io.ReadAll(io.LimitReader(r.Body, maxRelayResponseSize))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		req := new(compatRequest)
+		if err := json.Unmarshal(body, req); err != nil {
+			// let the rpc server answer with a parse error
+			r.Body = io.NopCloser(bytes.NewReader(body))
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if alias, ok := quirks.methodAliases[req.Method]; ok {
+			log.WithFields(Fields{"client": client, "method": req.Method, "alias": alias}).Debug("renaming method for client compatibility")
+			req.Method = alias
+		}
+		if quirks.decimalQuantities && isForkchoiceUpdated(req.Method) && len(req.Params) > 1 {
+			if req.Params[1], err = decimalToHexQuantities(req.Params[1], "timestamp"); err != nil {
+				log.WithError(err).WithField("client", client).Warn("could not convert decimal quantities of payload attributes")
+			}
+		}
+		if body, err = json.Marshal(req); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		r.ContentLength = int64(len(body))
+
+		if !quirks.snakeCasePayloads || !returnsPayload(req.Method) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		recorder := &responseBuffer{header: make(http.Header), code: http.StatusOK}
+		next.ServeHTTP(recorder, r)
+		resp := recorder.body.Bytes()
+		if converted, err := snakeCasePayloadResponse(resp); err != nil {
+			log.WithError(err).WithField("client", client).Warn("could not convert payload to the beacon API encoding")
+		} else {
+			resp = converted
+		}
+		for key, values := range recorder.header {
+			w.Header()[key] = values
+		}
+		w.Header().Set("Content-Length", strconv.Itoa(len(resp)))
+		w.WriteHeader(recorder.code)
+		w.Write(resp)
+	})
+}
+
+// isForkchoiceUpdated reports whether method is a version of forkchoiceUpdated, in any namespace
+func isForkchoiceUpdated(method string) bool {
+	return strings.HasSuffix(method, "_forkchoiceUpdatedV1") || strings.HasSuffix(method, "_forkchoiceUpdatedV2")
+}
+
+// returnsPayload reports whether method is a version of getPayloadHeader or proposeBlindedBlock, in any namespace
+func returnsPayload(method string) bool {
+	for _, suffix := range []string{"_getPayloadHeaderV1", "_proposeBlindedBlockV1", "_getPayloadHeaderV2", "_proposeBlindedBlockV2"} {
+		if strings.HasSuffix(method, suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+// responseBuffer keeps a response in memory, so it can be rewritten before it's sent
+type responseBuffer struct {
+	header http.Header
+	code   int
+	body   bytes.Buffer
+}
+
+func (b *responseBuffer) Header() http.Header {
+	return b.header
+}
+
+func (b *responseBuffer) Write(p []byte) (int, error) {
+	return b.body.Write(p)
+}
+
+func (b *responseBuffer) WriteHeader(code int) {
+	b.code = code
+}
+
+// decimalToHexQuantities converts the given fields of a JSON object from decimal strings to hex quantities
+func decimalToHexQuantities(object json.RawMessage, fields ...string) (json.RawMessage, error) {
+	var decoded map[string]json.RawMessage
+	if err := json.Unmarshal(object, &decoded); err != nil || decoded == nil {
+		return object, err
+	}
+	for _, field := range fields {
+		var value string
+		if err := json.Unmarshal(decoded[field], &value); err != nil || strings.HasPrefix(value, "0x") {
+			continue
+		}
+		n, ok := new(big.Int).SetString(value, 10)
+		if !ok {
+			return object, fmt.Errorf("%s %q is neither hex nor decimal", field, value)
+		}
+		decoded[field], _ = json.Marshal((*hexutil.Big)(n))
+	}
+	return json.Marshal(decoded)
+}
+
+// payloadQuantities are the fields of ExecutionPayloadWithTxRootV1 that are hex quantities in the engine API encoding
+var payloadQuantities = map[string]bool{"blockNumber": true, "gasLimit": true, "gasUsed": true, "timestamp": true, "baseFeePerGas": true}
+
+// snakeCasePayloadResponse converts the payload in the result of a JSON-RPC response to the beacon API encoding
+func snakeCasePayloadResponse(resp []byte) ([]byte, error) {
+	var decoded struct {
+		ID      json.RawMessage            `json:"id"`
+		JSONRPC string                     `json:"jsonrpc,omitempty"`
+		Result  map[string]json.RawMessage `json:"result"`
+		Error   json.RawMessage            `json:"error,omitempty"`
+	}
+	if err := json.Unmarshal(resp, &decoded); err != nil {
+		return nil, err
+	}
+	if decoded.Result == nil {
+		return resp, nil
+	}
+
+	result := make(map[string]json.RawMessage, len(decoded.Result))
+	for field, value := range decoded.Result {
+		if payloadQuantities[field] {
+			var quantity hexutil.Big
+			if err := json.Unmarshal(value, &quantity); err != nil {
+				return nil, fmt.Errorf("%s: %w", field, err)
+			}
+			value, _ = json.Marshal(quantity.ToInt().String())
+		}
+		result[snakeCase(field)] = value
+	}
+	out := map[string]interface{}{"id": decoded.ID, "result": result}
+	if decoded.JSONRPC != "" {
+		out["jsonrpc"] = decoded.JSONRPC
+	}
+	if decoded.Error != nil {
+		out["error"] = decoded.Error
+	}
+	return json.Marshal(out)
+}
+
+// withdrawalQuantities are the fields of withdrawals that are hex quantities in the engine API encoding
+var withdrawalQuantities = []string{"index", "validatorIndex", "amount"}
+
+// engineEncodedResult converts the result of a relay in the beacon API encoding, with snake_case field names and
+// decimal quantities, to the engine API encoding results are decoded from
+func engineEncodedResult(result json.RawMessage) (json.RawMessage, error) {
+	converted, err := camelCaseKeys(result)
+	if err != nil {
+		return nil, err
+	}
+	var object map[string]json.RawMessage
+	if json.Unmarshal(converted, &object) != nil || object == nil {
+		return converted, nil // results that aren't objects have no quantities
+	}
+
+	quantities := make([]string, 0, len(payloadQuantities))
+	for field := range payloadQuantities {
+		quantities = append(quantities, field)
+	}
+	if converted, err = decimalToHexQuantities(converted, quantities...); err != nil {
+		return nil, err
+	}
+	if json.Unmarshal(converted, &object) != nil {
+		return converted, nil
+	}
+	if withdrawals, ok := object["withdrawals"]; ok && !isNull(withdrawals) {
+		var list []json.RawMessage
+		if err := json.Unmarshal(withdrawals, &list); err != nil {
+			return nil, fmt.Errorf("withdrawals: %w", err)
+		}
+		for i, withdrawal := range list {
+			if list[i], err = decimalToHexQuantities(withdrawal, withdrawalQuantities...); err != nil {
+				return nil, fmt.Errorf("withdrawal %d: %w", i, err)
+			}
+		}
+		object["withdrawals"], _ = json.Marshal(list)
+	}
+	return json.Marshal(object)
+}
+
+// camelCaseKeys converts the field names of the objects in a JSON value from snake_case to camelCase
+func camelCaseKeys(value json.RawMessage) (json.RawMessage, error) {
+	trimmed := bytes.TrimSpace(value)
+	if len(trimmed) == 0 {
+		return value, nil
+	}
+	switch trimmed[0] {
+	case '{':
+		var object map[string]json.RawMessage
+		if err := json.Unmarshal(trimmed, &object); err != nil {
+			return nil, err
+		}
+		converted := make(map[string]json.RawMessage, len(object))
+		for field, v := range object {
+			c, err := camelCaseKeys(v)
+			if err != nil {
+				return nil, err
+			}
+			converted[camelCase(field)] = c
+		}
+		return json.Marshal(converted)
+	case '[':
+		var elems []json.RawMessage
+		if err := json.Unmarshal(trimmed, &elems); err != nil {
+			return nil, err
+		}
+		for i, elem := range elems {
+			c, err := camelCaseKeys(elem)
+			if err != nil {
+				return nil, err
+			}
+			elems[i] = c
+		}
+		return json.Marshal(elems)
+	}
+	return value, nil
+}
+
+// camelCase converts a snake_case JSON field name to camelCase
+func camelCase(field string) string {
+	parts := strings.Split(field, "_")
+	for i := 1; i < len(parts); i++ {
+		if parts[i] != "" {
+			parts[i] = strings.ToUpper(parts[i][:1]) + parts[i][1:]
+		}
+	}
+	return strings.Join(parts, "")
+}
+
+// snakeCase converts a camelCase JSON field name to snake_case
+func snakeCase(field string) string {
+	var b strings.Builder
+	for i, r := range field {
+		if unicode.IsUpper(r) {
+			if i > 0 {
+				b.WriteByte('_')
+			}
+			r = unicode.ToLower(r)
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
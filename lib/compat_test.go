@@ -0,0 +1,111 @@
+package lib
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClientCompat(t *testing.T) {
+	var relayAttributes json.RawMessage
+	relay := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := ioutil.ReadAll(r.Body)
+		require.Nil(t, err)
+		var req struct {
+			Method string            `json:"method"`
+			Params []json.RawMessage `json:"params"`
+		}
+		require.Nil(t, json.Unmarshal(body, &req))
+
+		var resp []byte
+		switch req.Method {
+		case methodForkchoiceUpdated:
+			if len(req.Params) > 1 {
+				relayAttributes = req.Params[1]
+			}
+			resp, err = formatResponse(ForkChoiceResponse{PayloadStatus: PayloadStatus{Status: ForkchoiceStatusValid}, PayloadID: &hexutil.Bytes{1}})
+		case methodRelayGetHeader:
+			resp, err = formatResponse(ExecutionPayloadWithTxRootV1{BlockHash: common.HexToHash("0x01"), Number: 10, BaseFeePerGas: big.NewInt(7), FeeRecipientDiff: big.NewInt(1)})
+		}
+		require.Nil(t, err)
+		w.Write(resp)
+	}))
+	defer relay.Close()
+
+	newRouter := func(t *testing.T, mode ClientCompat, opts ...Option) http.Handler {
+		opts = append([]Option{WithRelayURLs(relay.URL), WithLogger(testLog), WithCapabilityCheckInterval(0), WithClientCompat(mode)}, opts...)
+		router, err := NewRouter(context.Background(), opts...)
+		require.Nil(t, err)
+		return router
+	}
+	call := func(t *testing.T, router http.Handler, userAgent, method string, params ...interface{}) map[string]interface{} {
+		body, err := formatRequestBody(method, params)
+		require.Nil(t, err)
+		req := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("User-Agent", userAgent)
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+
+		var resp map[string]interface{}
+		if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+			// the rpc server answers unknown methods in plain text
+			return map[string]interface{}{"error": rr.Body.String()}
+		}
+		return resp
+	}
+	// getHeader requests a header for a new payload id, as userAgent
+	getHeader := func(t *testing.T, router http.Handler, userAgent, method string) map[string]interface{} {
+		fcu := call(t, router, "", "engine_forkchoiceUpdatedV1", map[string]interface{}{})
+		payloadID := fcu["result"].(map[string]interface{})["payloadId"]
+		return call(t, router, userAgent, method, payloadID)
+	}
+
+	t.Run("teku method aliases", func(t *testing.T) {
+		resp := getHeader(t, newRouter(t, CompatAuto), "teku/v22.6.0", "builder_getHeaderV1")
+		require.Nil(t, resp["error"])
+		resp = getHeader(t, newRouter(t, CompatTeku, WithDeprecatedMethodPolicy(DeprecatedReject)), "teku/v22.6.0", "builder_getHeaderV1")
+		require.Nil(t, resp["error"], "teku aliases aren't deprecated calls")
+		resp = getHeader(t, newRouter(t, CompatNone, WithDeprecatedMethodPolicy(DeprecatedReject)), "teku/v22.6.0", "builder_getHeaderV1")
+		require.NotNil(t, resp["error"], "aliases are only known in teku mode")
+	})
+
+	t.Run("nimbus decimal quantities", func(t *testing.T) {
+		attributes := map[string]interface{}{
+			"timestamp":             "1655733600",
+			"prevRandao":            common.HexToHash("0x01"),
+			"suggestedFeeRecipient": common.HexToAddress("0x02"),
+		}
+		resp := call(t, newRouter(t, CompatAuto), "Nimbus/v22.6.0", "engine_forkchoiceUpdatedV1", map[string]interface{}{}, attributes)
+		require.Nil(t, resp["error"])
+		decoded := new(PayloadAttributesV1)
+		require.Nil(t, json.Unmarshal(relayAttributes, decoded))
+		require.Equal(t, hexutil.Uint64(1655733600), decoded.Timestamp)
+	})
+
+	t.Run("lodestar beacon API payloads", func(t *testing.T) {
+		resp := getHeader(t, newRouter(t, CompatLodestar), "", "builder_getPayloadHeaderV1")
+		result := resp["result"].(map[string]interface{})
+		require.Equal(t, "10", result["block_number"])
+		require.Equal(t, "7", result["base_fee_per_gas"])
+		require.Equal(t, common.HexToHash("0x01").Hex(), result["block_hash"])
+
+		resp = getHeader(t, newRouter(t, CompatAuto), "curl", "builder_getPayloadHeaderV1")
+		require.Equal(t, "0xa", resp["result"].(map[string]interface{})["blockNumber"])
+	})
+
+	_, err := ParseClientCompat("Prysm")
+	require.Error(t, err)
+	mode, err := ParseClientCompat("Teku")
+	require.Nil(t, err)
+	require.Equal(t, CompatTeku, mode)
+}
@@ -0,0 +1,62 @@
+package lib
+
+import (
+	"compress/gzip"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/golang/snappy"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// payloadEncodings is the Accept-Encoding of payload requests when compression is negotiated, in order of preference.
+// Snappy is the cheapest to decode of the three, which matters more than the ratio on the payload reveal.
+const payloadEncodings = "snappy, deflate, gzip"
+
+var relayPayloadEncodingsTotal = metricsFactory.NewCounterVec(prometheus.CounterOpts{
+	Name: "mevboost_relay_payload_encodings_total",
+	Help: "Payload responses of relays by the content encoding they were transferred with",
+}, []string{"relay", "encoding"})
+
+// decodedBody is a response body decoded according to its Content-Encoding
+type decodedBody struct {
+	io.Reader
+	closer io.Closer // closes the decoder, nil if it has nothing to release
+}
+
+func (b *decodedBody) Close() error {
+	if b.closer == nil {
+		return nil
+	}
+	return b.closer.Close()
+}
+
+// decodeBody returns the body of resp decoded according to its Content-Encoding, and the encoding. The body of resp
+// is still closed by the caller. Limits have to be applied to the decoded body, a small compressed body can expand
+// to any size.
+func decodeBody(resp *http.Response) (*decodedBody, string, error) {
+	encoding := strings.ToLower(strings.TrimSpace(resp.Header.Get("Content-Encoding")))
+	switch encoding {
+	case "", "identity":
+		return &decodedBody{Reader: resp.Body}, "identity", nil
+	case "snappy":
+		return &decodedBody{Reader: snappy.NewReader(resp.Body)}, encoding, nil
+	case "deflate":
+		reader, err := zlib.NewReader(resp.Body)
+		if err != nil {
+			return nil, encoding, fmt.Errorf("%w: invalid deflate response: %v", ErrValidationFailed, err)
+		}
+		return &decodedBody{Reader: reader, closer: reader}, encoding, nil
+	case "gzip":
+		reader, err := gzip.NewReader(resp.Body)
+		if err != nil {
+			return nil, encoding, fmt.Errorf("%w: invalid gzip response: %v", ErrValidationFailed, err)
+		}
+		return &decodedBody{Reader: reader, closer: reader}, encoding, nil
+	default:
+		return nil, encoding, fmt.Errorf("%w: unsupported content encoding %q", ErrValidationFailed, encoding)
+	}
+}
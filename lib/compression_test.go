@@ -0,0 +1,70 @@
+package lib
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"context"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/golang/snappy"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_makeRequestInto_compressed(t *testing.T) {
+	payload := ExecutionPayloadWithTxRootV1{
+		BlockHash:        common.HexToHash("0x1"),
+		BaseFeePerGas:    big.NewInt(4),
+		FeeRecipientDiff: big.NewInt(1),
+	}
+	resp, err := formatResponse(payload)
+	require.Nil(t, err)
+	compress := func(newWriter func(io.Writer) io.WriteCloser) []byte {
+		var buf bytes.Buffer
+		w := newWriter(&buf)
+		_, err := w.Write(resp)
+		require.Nil(t, err)
+		require.Nil(t, w.Close())
+		return buf.Bytes()
+	}
+
+	tests := []struct {
+		encoding string
+		body     []byte
+		maxSize  int64
+		wantErr  bool
+	}{
+		{"", resp, maxRelayResponseSize, false},
+		{"snappy", compress(func(w io.Writer) io.WriteCloser { return snappy.NewBufferedWriter(w) }), maxRelayResponseSize, false},
+		{"deflate", compress(func(w io.Writer) io.WriteCloser { return zlib.NewWriter(w) }), maxRelayResponseSize, false},
+		{"gzip", compress(func(w io.Writer) io.WriteCloser { return gzip.NewWriter(w) }), maxRelayResponseSize, false},
+		{"br", resp, maxRelayResponseSize, true},
+		{"gzip", resp, maxRelayResponseSize, true},
+		{"snappy", compress(func(w io.Writer) io.WriteCloser { return snappy.NewBufferedWriter(w) }), 16, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.encoding, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				require.Equal(t, payloadEncodings, r.Header.Get("Accept-Encoding"))
+				if tt.encoding != "" {
+					w.Header().Set("Content-Encoding", tt.encoding)
+				}
+				w.Write(tt.body)
+			}))
+			defer server.Close()
+
+			result := new(ExecutionPayloadWithTxRootV1)
+			rpcErr, err := makeRequestInto(context.Background(), &httpClient, server.URL, methodRelayProposeBlock, []interface{}{}, result, responseLimit{size: tt.maxSize}, true)
+			require.Equal(t, tt.wantErr, err != nil, err)
+			require.Nil(t, rpcErr)
+			if !tt.wantErr {
+				require.Equal(t, payload.BlockHash, result.BlockHash)
+			}
+		})
+	}
+}
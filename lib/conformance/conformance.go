@@ -0,0 +1,183 @@
+// Package conformance validates the mev-boost type implementations against builder-specs style test vectors
+package conformance
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/flashbots/mev-boost/lib"
+	"github.com/flashbots/mev-boost/lib/txroot"
+	"gopkg.in/yaml.v3"
+)
+
+// Vectors is the content of a test vector file. Each section holds cases of one kind.
+type Vectors struct {
+	SigningDomains    []SigningDomainCase    `yaml:"signing_domains"`
+	SigningRoots      []SigningRootCase      `yaml:"signing_roots"`
+	TransactionsRoots []TransactionsRootCase `yaml:"transactions_roots"`
+}
+
+// SigningDomainCase checks compute_domain
+type SigningDomainCase struct {
+	Name                  string `yaml:"name"`
+	DomainType            string `yaml:"domain_type"`
+	ForkVersion           string `yaml:"fork_version"`
+	GenesisValidatorsRoot string `yaml:"genesis_validators_root"`
+	Domain                string `yaml:"domain"`
+}
+
+// SigningRootCase checks compute_signing_root
+type SigningRootCase struct {
+	Name        string `yaml:"name"`
+	ObjectRoot  string `yaml:"object_root"`
+	Domain      string `yaml:"domain"`
+	SigningRoot string `yaml:"signing_root"`
+}
+
+// TransactionsRootCase checks the hash tree root of an execution payload's transactions
+type TransactionsRootCase struct {
+	Name         string   `yaml:"name"`
+	Transactions []string `yaml:"transactions"`
+	Root         string   `yaml:"root"`
+}
+
+// Result is the outcome of a single case
+type Result struct {
+	File  string
+	Kind  string
+	Name  string
+	Error error // nil if the case passed
+}
+
+// Passed reports whether the case matched the expected value
+func (r Result) Passed() bool {
+	return r.Error == nil
+}
+
+func (r Result) String() string {
+	status := "PASS"
+	if r.Error != nil {
+		status = "FAIL: " + r.Error.Error()
+	}
+	return fmt.Sprintf("%s %s/%s: %s", filepath.Base(r.File), r.Kind, r.Name, status)
+}
+
+// RunDir runs all *.yaml vector files in a directory
+func RunDir(dir string) ([]Result, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*.yaml"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(files)
+
+	var results []Result
+	for _, file := range files {
+		fileResults, err := RunFile(file)
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, fileResults...)
+	}
+	return results, nil
+}
+
+// RunFile runs all cases of a vector file
+func RunFile(path string) ([]Result, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	vectors := new(Vectors)
+	if err := yaml.Unmarshal(data, vectors); err != nil {
+		return nil, fmt.Errorf("could not parse %s: %w", path, err)
+	}
+
+	var results []Result
+	for _, c := range vectors.SigningDomains {
+		results = append(results, Result{path, "signing_domains", c.Name, c.run()})
+	}
+	for _, c := range vectors.SigningRoots {
+		results = append(results, Result{path, "signing_roots", c.Name, c.run()})
+	}
+	for _, c := range vectors.TransactionsRoots {
+		results = append(results, Result{path, "transactions_roots", c.Name, c.run()})
+	}
+	return results, nil
+}
+
+func decodeFixed(name, value string, dst []byte) error {
+	b, err := hexutil.Decode(value)
+	if err != nil {
+		return fmt.Errorf("invalid %s %q: %w", name, value, err)
+	}
+	if len(b) != len(dst) {
+		return fmt.Errorf("invalid %s %q: expected %d bytes, got %d", name, value, len(dst), len(b))
+	}
+	copy(dst, b)
+	return nil
+}
+
+func (c SigningDomainCase) run() error {
+	var domainType, forkVersion [4]byte
+	var genesisValidatorsRoot, expected [32]byte
+	if err := decodeFixed("domain_type", c.DomainType, domainType[:]); err != nil {
+		return err
+	}
+	if err := decodeFixed("fork_version", c.ForkVersion, forkVersion[:]); err != nil {
+		return err
+	}
+	if err := decodeFixed("genesis_validators_root", c.GenesisValidatorsRoot, genesisValidatorsRoot[:]); err != nil {
+		return err
+	}
+	if err := decodeFixed("domain", c.Domain, expected[:]); err != nil {
+		return err
+	}
+
+	if domain := lib.ComputeDomain(domainType, forkVersion, genesisValidatorsRoot); domain != expected {
+		return fmt.Errorf("expected domain %#x, got %#x", expected, domain)
+	}
+	return nil
+}
+
+func (c SigningRootCase) run() error {
+	var objectRoot, domain, expected [32]byte
+	if err := decodeFixed("object_root", c.ObjectRoot, objectRoot[:]); err != nil {
+		return err
+	}
+	if err := decodeFixed("domain", c.Domain, domain[:]); err != nil {
+		return err
+	}
+	if err := decodeFixed("signing_root", c.SigningRoot, expected[:]); err != nil {
+		return err
+	}
+
+	if root := lib.ComputeSigningRoot(objectRoot, domain); root != expected {
+		return fmt.Errorf("expected signing root %#x, got %#x", expected, root)
+	}
+	return nil
+}
+
+func (c TransactionsRootCase) run() error {
+	var expected [32]byte
+	if err := decodeFixed("root", c.Root, expected[:]); err != nil {
+		return err
+	}
+
+	txs := make([][]byte, len(c.Transactions))
+	for i, tx := range c.Transactions {
+		txs[i] = common.FromHex(tx)
+	}
+	root, err := txroot.TransactionsRoot(txs)
+	if err != nil {
+		return err
+	}
+	if root != expected {
+		return fmt.Errorf("expected root %#x, got %#x", expected, root)
+	}
+	return nil
+}
@@ -0,0 +1,40 @@
+package conformance
+
+import (
+	"flag"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// run against the builder-specs vectors with: go test ./lib/conformance -vectors /path/to/vectors
+var vectorsDir = flag.String("vectors", "testdata", "directory of builder-specs test vectors")
+
+func TestConformance(t *testing.T) {
+	results, err := RunDir(*vectorsDir)
+	require.NoError(t, err)
+	require.NotEmpty(t, results, "no test vectors found in %s", *vectorsDir)
+
+	for _, result := range results {
+		t.Run(result.Kind+"/"+result.Name, func(t *testing.T) {
+			require.NoError(t, result.Error)
+		})
+	}
+}
+
+func TestRunFileInvalidVector(t *testing.T) {
+	c := SigningDomainCase{
+		Name:                  "short fork version",
+		DomainType:            "0x00000001",
+		ForkVersion:           "0x00",
+		GenesisValidatorsRoot: "0x0000000000000000000000000000000000000000000000000000000000000000",
+		Domain:                "0x00000001f5a5fd42d16a20302798ef6ed309979b43003d2320d9f0e8ea9831a9",
+	}
+	require.Error(t, c.run())
+
+	c.ForkVersion = "0x00000000"
+	require.NoError(t, c.run())
+
+	c.ForkVersion = "0x01000000"
+	require.Error(t, c.run())
+}
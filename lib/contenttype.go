@@ -0,0 +1,86 @@
+package lib
+
+import (
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var contentTypeMismatchesTotal = metricsFactory.NewCounterVec(prometheus.CounterOpts{
+	Name: "mevboost_content_type_mismatches_total",
+	Help: "Requests and relay responses without a JSON Content-Type, by direction",
+}, []string{"direction"})
+
+// checkJSONContentType returns an error unless contentType is application/json
+func checkJSONContentType(contentType string) error {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil || mediaType != "application/json" {
+		return fmt.Errorf("%w: unexpected Content-Type %q, expected application/json", ErrValidationFailed, contentType)
+	}
+	return nil
+}
+
+// contentTypeHandler rejects requests that aren't application/json with 415. If lenient, they're served as JSON with a
+// warning instead.
+func contentTypeHandler(strict bool, log Logger, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := checkJSONContentType(r.Header.Get("Content-Type")); err != nil {
+			contentTypeMismatchesTotal.WithLabelValues("request").Inc()
+			if strict {
+				http.Error(w, err.Error(), http.StatusUnsupportedMediaType)
+				return
+			}
+			log.WithFields(Fields{"error": err, "userAgent": r.UserAgent()}).Warn("serving request with unexpected Content-Type")
+			r.Header.Set("Content-Type", "application/json")
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// contentTypeTransport checks that relay responses are application/json, or SSZ if the request accepts SSZ. Strict,
+// other responses fail with an error wrapping ErrValidationFailed, otherwise they're logged.
+type contentTypeTransport struct {
+	next   http.RoundTripper
+	strict bool
+	log    Logger
+}
+
+func (t *contentTypeTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.next.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	if req.Header.Get("Accept") == sszAccept && isSSZResponse(resp) {
+		return resp, nil
+	}
+	if err := checkJSONContentType(resp.Header.Get("Content-Type")); err != nil {
+		contentTypeMismatchesTotal.WithLabelValues("response").Inc()
+		if t.strict {
+			io.Copy(io.Discard, io.LimitReader(resp.Body, 4096)) // let the connection be reused
+			resp.Body.Close()
+			return nil, fmt.Errorf("%s responded with status %d: %w", hostOf(req), resp.StatusCode, err)
+		}
+		t.log.WithFields(Fields{"error": err, "url": hostOf(req)}).Warn("relay response with unexpected Content-Type")
+	}
+	return resp, nil
+}
+
+// hostOf returns the scheme and host of the url of req, without the relay pubkey or token of the user part
+func hostOf(req *http.Request) string {
+	return strings.TrimSuffix(req.URL.Scheme+"://"+req.URL.Host, "://")
+}
+
+// withContentTypeCheck returns a copy of client whose responses are checked by contentTypeTransport
+func withContentTypeCheck(client *http.Client, strict bool, log Logger) *http.Client {
+	next := client.Transport
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	checked := *client
+	checked.Transport = &contentTypeTransport{next: next, strict: strict, log: log}
+	return &checked
+}
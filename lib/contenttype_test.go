@@ -0,0 +1,54 @@
+package lib
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRouter_ContentTypes(t *testing.T) {
+	var contentType string
+	relay := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		resp, err := formatResponse(ForkChoiceResponse{PayloadStatus: PayloadStatus{Status: ForkchoiceStatusValid}, PayloadID: &hexutil.Bytes{1}})
+		require.Nil(t, err)
+		w.Header().Set("Content-Type", contentType)
+		w.Write(resp)
+	}))
+	defer relay.Close()
+
+	call := func(opts []Option, requestContentType string) (int, *rpcResponse) {
+		router, err := NewRouter(context.Background(), append(opts, WithRelayURLs(relay.URL), WithLogger(testLog), WithCapabilityCheckInterval(0))...)
+		require.Nil(t, err)
+		body, err := formatRequestBody(methodForkchoiceUpdated, []interface{}{map[string]interface{}{}})
+		require.Nil(t, err)
+		req := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(body))
+		req.Header.Set("Content-Type", requestContentType)
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		resp, _ := parseRPCResponse(rr.Body.Bytes())
+		return rr.Code, resp
+	}
+	strict := []Option{WithStrictContentTypes()}
+
+	contentType = "application/json; charset=utf-8"
+	code, resp := call(strict, "application/json")
+	require.Equal(t, http.StatusOK, code)
+	require.Nil(t, resp.Error)
+
+	code, _ = call(strict, "text/plain")
+	require.Equal(t, http.StatusUnsupportedMediaType, code)
+	code, resp = call(nil, "text/plain")
+	require.Equal(t, http.StatusOK, code, "lenient requests are served with a warning")
+	require.Nil(t, resp.Error)
+
+	contentType = "text/html"
+	_, resp = call(strict, "application/json")
+	require.NotNil(t, resp.Error, "relay responses of other types are rejected")
+	_, resp = call(nil, "application/json")
+	require.Nil(t, resp.Error)
+}
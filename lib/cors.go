@@ -0,0 +1,56 @@
+package lib
+
+import (
+	"net/http"
+	"strings"
+)
+
+// corsPolicy lets browser-based dashboards hosted on other origins query the mev-boost APIs and /metrics. The JSON-RPC
+// endpoint of the consensus client is never exposed to browsers.
+type corsPolicy struct {
+	origins   map[string]bool
+	anyOrigin bool
+	methods   string
+}
+
+func newCORSPolicy(origins, methods []string) *corsPolicy {
+	p := &corsPolicy{origins: make(map[string]bool, len(origins)), methods: http.MethodGet}
+	for _, origin := range origins {
+		if origin == "*" {
+			p.anyOrigin = true
+		}
+		p.origins[strings.TrimRight(origin, "/")] = true
+	}
+	if len(methods) > 0 {
+		p.methods = strings.ToUpper(strings.Join(methods, ", "))
+	}
+	return p
+}
+
+// allow reports whether a request from origin may read the response of path
+func (p *corsPolicy) allow(origin, path string) bool {
+	return origin != "" && path != "/" && (p.anyOrigin || p.origins[origin])
+}
+
+// middleware adds the CORS headers to responses for allowed origins
+func (p *corsPolicy) middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Vary", "Origin")
+		if origin := r.Header.Get("Origin"); p.allow(origin, r.URL.Path) {
+			w.Header().Set("Access-Control-Allow-Origin", origin)
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// preflight answers CORS preflight requests, with 403 for origins that aren't allowed
+func (p *corsPolicy) preflight(w http.ResponseWriter, r *http.Request) {
+	if !p.allow(r.Header.Get("Origin"), r.URL.Path) {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+	w.Header().Set("Access-Control-Allow-Methods", p.methods)
+	w.Header().Set("Access-Control-Allow-Headers", "Authorization, Content-Type")
+	w.Header().Set("Access-Control-Max-Age", "600")
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,39 @@
+package lib
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRouter_CORS(t *testing.T) {
+	router, err := NewRouter(context.Background(), WithRelayURLs("http://relay"), WithLogger(testLog), WithCapabilityCheckInterval(0),
+		WithCORS([]string{"https://dashboard.example.com/"}, nil))
+	require.Nil(t, err)
+
+	request := func(method, path, origin string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, nil)
+		req.Header.Set("Origin", origin)
+		req.Header.Set("Access-Control-Request-Method", http.MethodGet)
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		return rr
+	}
+
+	rr := request(http.MethodGet, "/mev-boost/v1/deliveries", "https://dashboard.example.com")
+	require.Equal(t, http.StatusOK, rr.Code)
+	require.Equal(t, "https://dashboard.example.com", rr.Header().Get("Access-Control-Allow-Origin"))
+
+	rr = request(http.MethodOptions, "/metrics", "https://dashboard.example.com")
+	require.Equal(t, http.StatusNoContent, rr.Code)
+	require.Equal(t, "https://dashboard.example.com", rr.Header().Get("Access-Control-Allow-Origin"))
+	require.Equal(t, http.MethodGet, rr.Header().Get("Access-Control-Allow-Methods"))
+
+	rr = request(http.MethodGet, "/mev-boost/v1/deliveries", "https://evil.example.com")
+	require.Empty(t, rr.Header().Get("Access-Control-Allow-Origin"))
+	require.Equal(t, http.StatusForbidden, request(http.MethodOptions, "/metrics", "https://evil.example.com").Code)
+	require.Equal(t, http.StatusForbidden, request(http.MethodOptions, "/", "https://dashboard.example.com").Code, "the JSON-RPC endpoint isn't exposed to browsers")
+}
@@ -0,0 +1,126 @@
+package lib
+
+import (
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+var maxDeliveredPayloads = 10000
+
+// DeliveredPayload is a payload mev-boost revealed to the consensus client
+type DeliveredPayload struct {
+	Slot          uint64         `json:"slot,string"`
+	ProposerIndex uint64         `json:"proposerIndex,string"`
+	BlockHash     common.Hash    `json:"blockHash"`
+	BlockNumber   uint64         `json:"blockNumber,string"`
+	ParentHash    common.Hash    `json:"parentHash"`
+	RelayURL      string         `json:"relayUrl"`
+	FeeRecipient  common.Address `json:"feeRecipient"`
+	Value         *big.Int       `json:"value"`
+	DeliveredAt   time.Time      `json:"deliveredAt"`
+	Reconciled    bool           `json:"reconciled"` // the relay's data API was checked for this delivery
+	// Verification is the outcome of checking the delivery against the finalized chain, nil until it was checked
+	Verification *DeliveryVerification `json:"verification,omitempty"`
+}
+
+// deliveryLog keeps the most recent delivered payloads
+type deliveryLog struct {
+	mu         sync.RWMutex
+	deliveries []*DeliveredPayload
+}
+
+func (l *deliveryLog) add(delivery *DeliveredPayload) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for _, existing := range l.deliveries {
+		if existing.BlockHash == delivery.BlockHash {
+			return
+		}
+	}
+
+	l.deliveries = append(l.deliveries, delivery)
+	if len(l.deliveries) > maxDeliveredPayloads {
+		l.deliveries = l.deliveries[len(l.deliveries)-maxDeliveredPayloads:]
+	}
+}
+
+// all returns copies of all deliveries, oldest first
+func (l *deliveryLog) all() []DeliveredPayload {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	deliveries := make([]DeliveredPayload, len(l.deliveries))
+	for i, delivery := range l.deliveries {
+		deliveries[i] = *delivery
+	}
+	return deliveries
+}
+
+// unreconciled returns copies of the deliveries of a relay that weren't checked against its data API yet
+func (l *deliveryLog) unreconciled(relayURL string) []DeliveredPayload {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	var deliveries []DeliveredPayload
+	for _, delivery := range l.deliveries {
+		if delivery.RelayURL == relayURL && !delivery.Reconciled {
+			deliveries = append(deliveries, *delivery)
+		}
+	}
+	return deliveries
+}
+
+func (l *deliveryLog) markReconciled(blockHash common.Hash) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for _, delivery := range l.deliveries {
+		if delivery.BlockHash == blockHash {
+			delivery.Reconciled = true
+		}
+	}
+}
+
+// unverified returns copies of the deliveries up to slot that weren't checked against the chain yet, oldest first
+func (l *deliveryLog) unverified(slot uint64, limit int) []DeliveredPayload {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	var deliveries []DeliveredPayload
+	for _, delivery := range l.deliveries {
+		if len(deliveries) == limit {
+			break
+		}
+		if delivery.Slot <= slot && delivery.Verification == nil {
+			deliveries = append(deliveries, *delivery)
+		}
+	}
+	return deliveries
+}
+
+func (l *deliveryLog) markVerified(blockHash common.Hash, verification *DeliveryVerification) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for _, delivery := range l.deliveries {
+		if delivery.BlockHash == blockHash {
+			delivery.Verification = verification
+		}
+	}
+}
+
+func (l *deliveryLog) hasSlot(slot uint64) bool {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	for _, delivery := range l.deliveries {
+		if delivery.Slot == slot {
+			return true
+		}
+	}
+	return false
+}
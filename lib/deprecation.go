@@ -0,0 +1,129 @@
+package lib
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// DeprecatedMethodPolicy selects how calls of deprecated method versions are answered
+type DeprecatedMethodPolicy string
+
+// Policies of WithDeprecatedMethodPolicy
+const (
+	// DeprecatedTranslate serves a deprecated method with its current version and logs a warning at most once per
+	// deprecationWarnInterval and method, so consensus clients and mev-boost don't have to be upgraded in lockstep
+	DeprecatedTranslate DeprecatedMethodPolicy = "translate"
+	// DeprecatedReject answers deprecated methods with a method not found error naming the current version
+	DeprecatedReject DeprecatedMethodPolicy = "reject"
+)
+
+// ParseDeprecatedMethodPolicy parses the name of a deprecated method policy
+func ParseDeprecatedMethodPolicy(name string) (DeprecatedMethodPolicy, error) {
+	switch policy := DeprecatedMethodPolicy(strings.ToLower(name)); policy {
+	case DeprecatedTranslate, DeprecatedReject:
+		return policy, nil
+	}
+	return "", fmt.Errorf("unknown deprecated method policy %q, expected translate or reject", name)
+}
+
+// deprecatedMethods maps deprecated method versions to their current version. The builder methods were served in the
+// engine namespace before they moved to their own, and the builder spec called them getHeader and getPayload. Their
+// params and results are the same as those of the current version, so the answer is in the format of the old version.
+var deprecatedMethods = map[string]string{
+	"engine_getPayloadHeaderV1":    "builder_getPayloadHeaderV1",
+	"engine_proposeBlindedBlockV1": "builder_proposeBlindedBlockV1",
+	"builder_getHeaderV1":          "builder_getPayloadHeaderV1",
+	"builder_getPayloadV1":         "builder_proposeBlindedBlockV1",
+}
+
+// deprecationWarnInterval is how often a warning is logged per deprecated method
+var deprecationWarnInterval = time.Minute
+
+var deprecatedCallsTotal = metricsFactory.NewCounterVec(prometheus.CounterOpts{
+	Name: "mevboost_deprecated_calls_total",
+	Help: "Calls of deprecated method versions, by method",
+}, []string{"method"})
+
+// deprecationWarnings rate-limits the warnings about calls of deprecated methods
+type deprecationWarnings struct {
+	mu         sync.Mutex
+	warnedAt   map[string]time.Time
+	suppressed map[string]int // calls since the last warning of each method
+}
+
+func newDeprecationWarnings() *deprecationWarnings {
+	return &deprecationWarnings{warnedAt: make(map[string]time.Time), suppressed: make(map[string]int)}
+}
+
+// warn returns true if a warning about method is due, and the number of calls whose warnings were suppressed since the
+// previous one
+func (w *deprecationWarnings) warn(method string) (bool, int) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if warnedAt, ok := w.warnedAt[method]; ok && now().Sub(warnedAt) < deprecationWarnInterval {
+		w.suppressed[method]++
+		return false, 0
+	}
+	suppressed := w.suppressed[method]
+	w.warnedAt[method], w.suppressed[method] = now(), 0
+	return true, suppressed
+}
+
+// deprecatedMethodHandler translates JSON-RPC calls of deprecated method versions to the current version, or rejects
+// them, according to policy
+func deprecatedMethodHandler(policy DeprecatedMethodPolicy, log Logger, next http.Handler) http.Handler {
+	warnings := newDeprecationWarnings()
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(io.LimitReader(r.Body, maxRelayResponseSize))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		req := new(compatRequest)
+		if err := json.Unmarshal(body, req); err != nil { // let the rpc server answer with a parse error
+			next.ServeHTTP(w, r)
+			return
+		}
+		current, ok := deprecatedMethods[req.Method]
+		if !ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		deprecatedCallsTotal.WithLabelValues(req.Method).Inc()
+		if policy == DeprecatedReject {
+			respondJSON(w, http.StatusOK, map[string]interface{}{
+				"id":    req.ID,
+				"error": rpcError{Code: rpcErrMethodNotFound, Message: fmt.Sprintf("method %s is deprecated, use %s", req.Method, current)},
+			})
+			return
+		}
+		if warn, suppressed := warnings.warn(req.Method); warn {
+			log.WithFields(Fields{
+				"method":     req.Method,
+				"current":    current,
+				"userAgent":  r.UserAgent(),
+				"suppressed": suppressed,
+			}).Warn("consensus client called a deprecated method, serving it with the current version")
+		}
+
+		req.Method = current
+		if body, err = json.Marshal(req); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		r.ContentLength = int64(len(body))
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,66 @@
+package lib
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDeprecatedMethods(t *testing.T) {
+	relay := newHeaderRelay(t, ExecutionPayloadWithTxRootV1{BlockHash: common.HexToHash("0x01"), BaseFeePerGas: big.NewInt(7), FeeRecipientDiff: big.NewInt(1)})
+	defer relay.Close()
+
+	call := func(t *testing.T, policy DeprecatedMethodPolicy, method string) map[string]interface{} {
+		store := NewStore()
+		store.SetForkchoiceResponse(context.Background(), "0x01", relay.URL, "0x01")
+		router, err := NewRouter(context.Background(), WithRelayURLs(relay.URL), WithStore(store), WithLogger(testLog), WithCapabilityCheckInterval(0), WithDeprecatedMethodPolicy(policy))
+		require.Nil(t, err)
+
+		body, err := formatRequestBody(method, []interface{}{"0x01"})
+		require.Nil(t, err)
+		req := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		var resp map[string]interface{}
+		require.Nil(t, json.Unmarshal(rr.Body.Bytes(), &resp), rr.Body.String())
+		return resp
+	}
+
+	for _, method := range []string{"builder_getPayloadHeaderV1", "engine_getPayloadHeaderV1", "builder_getHeaderV1"} {
+		resp := call(t, DeprecatedTranslate, method)
+		require.Nil(t, resp["error"], method)
+		require.Equal(t, common.HexToHash("0x01").Hex(), resp["result"].(map[string]interface{})["blockHash"], method)
+	}
+
+	resp := call(t, DeprecatedReject, "engine_getPayloadHeaderV1")
+	require.Equal(t, "method engine_getPayloadHeaderV1 is deprecated, use builder_getPayloadHeaderV1", resp["error"].(map[string]interface{})["message"])
+	require.Nil(t, call(t, DeprecatedReject, "builder_getPayloadHeaderV1")["error"])
+}
+
+func TestDeprecationWarnings(t *testing.T) {
+	defer func() { now = time.Now }()
+	start := time.Unix(1000, 0)
+	now = func() time.Time { return start }
+
+	warnings := newDeprecationWarnings()
+	warn, _ := warnings.warn("engine_getPayloadHeaderV1")
+	require.True(t, warn)
+	warn, _ = warnings.warn("engine_getPayloadHeaderV1")
+	require.False(t, warn)
+	warn, _ = warnings.warn("builder_getHeaderV1")
+	require.True(t, warn, "warnings are limited per method")
+
+	now = func() time.Time { return start.Add(deprecationWarnInterval) }
+	warn, suppressed := warnings.warn("engine_getPayloadHeaderV1")
+	require.True(t, warn)
+	require.Equal(t, 1, suppressed)
+}
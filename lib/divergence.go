@@ -0,0 +1,95 @@
+package lib
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+const bidAnomalyDivergentParents = "divergent_parents"
+
+// forkchoiceHeads remembers the head block of the forkchoiceUpdated call that issued each payload id, the parent the
+// consensus client expects the payload to build on
+type forkchoiceHeads struct {
+	mu    sync.Mutex
+	heads map[string]forkchoiceHead // map[boost payload id]
+	calls uint64                    // number of heads set, orders the heads
+}
+
+type forkchoiceHead struct {
+	slot uint64
+	hash common.Hash
+	call uint64
+}
+
+func newForkchoiceHeads() *forkchoiceHeads {
+	return &forkchoiceHeads{heads: make(map[string]forkchoiceHead)}
+}
+
+// set records the head of a payload id, heads of slots older than the previous one are dropped
+func (h *forkchoiceHeads) set(payloadID string, slot uint64, head common.Hash) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for id, head := range h.heads {
+		if head.slot+1 < slot {
+			delete(h.heads, id)
+		}
+	}
+	h.calls++
+	h.heads[payloadID] = forkchoiceHead{slot: slot, hash: head, call: h.calls}
+}
+
+// get returns the head of a payload id, false if it isn't known
+func (h *forkchoiceHeads) get(payloadID string) (common.Hash, bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	head, ok := h.heads[payloadID]
+	return head.hash, ok
+}
+
+// payloadID returns the payload id of the latest forkchoiceUpdated call with payload attributes for slot on head, false
+// if there was none
+func (h *forkchoiceHeads) payloadID(slot uint64, head common.Hash) (string, bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	var latest string
+	var call uint64
+	for id, entry := range h.heads {
+		if entry.slot == slot && entry.hash == head && entry.call > call {
+			latest, call = id, entry.call
+		}
+	}
+	return latest, call > 0
+}
+
+// parentDivergence returns the relays of the candidates by the parent hash they built on, nil if all agree
+func parentDivergence(candidates []BidCandidate) map[common.Hash][]string {
+	parents := make(map[common.Hash][]string)
+	for _, candidate := range candidates {
+		parents[candidate.Header.ParentHash] = append(parents[candidate.Header.ParentHash], candidate.RelayURL)
+	}
+	if len(parents) < 2 {
+		return nil
+	}
+	for _, relays := range parents {
+		sort.Strings(relays)
+	}
+	return parents
+}
+
+// preferParent moves the candidates building on head to the front, keeping the order within both parts
+func preferParent(candidates []BidCandidate, head common.Hash) []BidCandidate {
+	ordered := make([]BidCandidate, 0, len(candidates))
+	for _, candidate := range candidates {
+		if candidate.Header.ParentHash == head {
+			ordered = append(ordered, candidate)
+		}
+	}
+	for _, candidate := range candidates {
+		if candidate.Header.ParentHash != head {
+			ordered = append(ordered, candidate)
+		}
+	}
+	return ordered
+}
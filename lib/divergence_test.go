@@ -0,0 +1,89 @@
+package lib
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestForkchoiceHeads(t *testing.T) {
+	heads := newForkchoiceHeads()
+	heads.set("0x01", 10, common.HexToHash("0xaa"))
+	heads.set("0x02", 11, common.HexToHash("0xbb"))
+	head, ok := heads.get("0x01")
+	require.True(t, ok)
+	require.Equal(t, common.HexToHash("0xaa"), head)
+
+	heads.set("0x03", 12, common.HexToHash("0xcc"))
+	_, ok = heads.get("0x01")
+	require.False(t, ok, "heads of slots before the previous one are dropped")
+	_, ok = heads.get("0x02")
+	require.True(t, ok)
+}
+
+func TestParentDivergence(t *testing.T) {
+	candidate := func(relayURL, parentHash string) BidCandidate {
+		return BidCandidate{relayURL, &ExecutionPayloadWithTxRootV1{ParentHash: common.HexToHash(parentHash)}}
+	}
+	a, b, c := candidate("https://a.example.com", "0x01"), candidate("https://b.example.com", "0x02"), candidate("https://c.example.com", "0x01")
+
+	require.Nil(t, parentDivergence([]BidCandidate{a, c}))
+	require.Equal(t, map[common.Hash][]string{
+		common.HexToHash("0x01"): {"https://a.example.com", "https://c.example.com"},
+		common.HexToHash("0x02"): {"https://b.example.com"},
+	}, parentDivergence([]BidCandidate{a, b, c}))
+
+	require.Equal(t, []BidCandidate{b, a, c}, preferParent([]BidCandidate{a, b, c}, common.HexToHash("0x02")))
+	require.Equal(t, []BidCandidate{a, c, b}, preferParent([]BidCandidate{a, b, c}, common.HexToHash("0x01")))
+}
+
+func TestGetPayloadHeaderV1_DivergentParents(t *testing.T) {
+	newRelay := func(blockHash, parentHash string, value int64) *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			body, err := ioutil.ReadAll(r.Body)
+			require.Nil(t, err)
+			var req rpcRequest
+			require.Nil(t, json.Unmarshal(body, &req))
+
+			var resp []byte
+			switch req.Method {
+			case methodForkchoiceUpdated:
+				resp, err = formatResponse(ForkChoiceResponse{PayloadStatus: PayloadStatus{Status: ForkchoiceStatusValid}, PayloadID: &hexutil.Bytes{1}})
+			case methodRelayGetHeader:
+				resp, err = formatResponse(ExecutionPayloadWithTxRootV1{
+					BlockHash:        common.HexToHash(blockHash),
+					ParentHash:       common.HexToHash(parentHash),
+					BaseFeePerGas:    big.NewInt(1),
+					FeeRecipientDiff: big.NewInt(value),
+				})
+			}
+			require.Nil(t, err)
+			w.Write(resp)
+		}))
+	}
+	reorged, canonical := newRelay("0x01", "0xdead", 2), newRelay("0x02", "0xaa", 1)
+	defer reorged.Close()
+	defer canonical.Close()
+
+	service, err := newRelayService(WithRelayURLs(reorged.URL, canonical.URL), WithStore(NewStore()), WithLogger(testLog))
+	require.Nil(t, err)
+
+	args := []interface{}{
+		map[string]interface{}{"headBlockHash": common.HexToHash("0xaa").Hex()},
+		map[string]interface{}{"timestamp": hexutil.Uint64(MainnetChainConfig.GenesisTime + 12).String()},
+	}
+	fcu := new(ForkChoiceResponse)
+	require.Nil(t, service.ForkchoiceUpdatedV1(nil, &args, fcu))
+
+	payloadID := fcu.PayloadID.String()
+	header := new(ExecutionPayloadWithTxRootV1)
+	require.Nil(t, service.GetPayloadHeaderV1(nil, &payloadID, header))
+	require.Equal(t, common.HexToHash("0x02"), header.BlockHash, "the header building on the local head wins over a more valuable one")
+}
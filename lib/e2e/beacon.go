@@ -0,0 +1,128 @@
+// Package e2e drives a mev-boost through the builder API calls of whole slots, with the mock relays of testutil and a
+// mock beacon node, to test mev-boost end to end and the interoperability of relays with it
+package e2e
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/flashbots/mev-boost/lib"
+	"github.com/flashbots/mev-boost/lib/client"
+	"github.com/flashbots/mev-boost/lib/internal/blstest"
+)
+
+// MockBeaconNode drives mev-boost like the consensus client of a single validator: it registers the validator, and
+// runs the builder API calls of its proposals
+type MockBeaconNode struct {
+	boost        *client.Client
+	chain        *lib.ChainConfig
+	secretKey    *big.Int
+	pubkey       hexutil.Bytes
+	feeRecipient common.Address
+	gasLimit     uint64
+}
+
+// NewMockBeaconNode returns a consensus client of the mev-boost at boostURL for the validator of secretKey on chain,
+// whose blocks pay feeRecipient
+func NewMockBeaconNode(boostURL string, chain *lib.ChainConfig, secretKey *big.Int, feeRecipient common.Address) (*MockBeaconNode, error) {
+	pubkey, _, err := blstest.Sign(secretKey, [32]byte{})
+	if err != nil {
+		return nil, err
+	}
+	return &MockBeaconNode{
+		boost:        client.New(boostURL),
+		chain:        chain,
+		secretKey:    secretKey,
+		pubkey:       pubkey,
+		feeRecipient: feeRecipient,
+		gasLimit:     30_000_000,
+	}, nil
+}
+
+// Pubkey returns the pubkey of the validator
+func (b *MockBeaconNode) Pubkey() hexutil.Bytes {
+	return b.pubkey
+}
+
+// Registration returns a registration of the validator at timestamp, signed in the builder domain of the chain
+func (b *MockBeaconNode) Registration(timestamp uint64) (*lib.SignedValidatorRegistrationV1, error) {
+	message := &lib.ValidatorRegistrationV1{FeeRecipient: b.feeRecipient, GasLimit: b.gasLimit, Timestamp: timestamp, Pubkey: b.pubkey}
+	root, err := message.HashTreeRoot()
+	if err != nil {
+		return nil, err
+	}
+	_, signature, err := blstest.Sign(b.secretKey, lib.ComputeSigningRoot(root, b.chain.BuilderDomain()))
+	if err != nil {
+		return nil, err
+	}
+	return &lib.SignedValidatorRegistrationV1{Message: message, Signature: signature}, nil
+}
+
+// Register sends a registration of the validator at timestamp to mev-boost
+func (b *MockBeaconNode) Register(ctx context.Context, timestamp uint64) error {
+	registration, err := b.Registration(timestamp)
+	if err != nil {
+		return err
+	}
+	var result string
+	if err := b.boost.Call(ctx, "builder_registerValidatorV1", &result, []*lib.SignedValidatorRegistrationV1{registration}); err != nil {
+		return fmt.Errorf("registerValidator: %w", err)
+	}
+	return nil
+}
+
+// Proposal is the outcome of the builder API calls of a proposal, the fields of the steps that didn't complete are empty
+type Proposal struct {
+	Slot       uint64
+	Attributes *lib.PayloadAttributesV1
+	PayloadID  string
+	Header     *lib.ExecutionPayloadWithTxRootV1
+	Payload    *lib.ExecutionPayloadWithTxRootV1
+}
+
+// Propose runs the builder API calls of a proposal at slot on top of parentHash: forkchoiceUpdated with the payload
+// attributes of the validator, getPayloadHeader, and proposeBlindedBlock with an unsigned blinded block of the header.
+// The error of a failed step names it and wraps the error of mev-boost, so errors.Is matches it with the lib.Err*
+// values. A revealed payload of another block than the header is an error, like for a consensus client.
+func (b *MockBeaconNode) Propose(ctx context.Context, slot uint64, parentHash common.Hash) (*Proposal, error) {
+	proposal := &Proposal{
+		Slot: slot,
+		Attributes: &lib.PayloadAttributesV1{
+			Timestamp:             hexutil.Uint64(b.chain.SlotStartTime(slot).Unix()),
+			PrevRandao:            common.BigToHash(new(big.Int).SetUint64(slot)),
+			SuggestedFeeRecipient: b.feeRecipient,
+		},
+	}
+	state := &lib.ForkchoiceStateV1{HeadBlockHash: parentHash, SafeBlockHash: parentHash, FinalizedBlockHash: parentHash}
+	fcu, err := b.boost.ForkchoiceUpdated(ctx, state, proposal.Attributes)
+	if err != nil {
+		return proposal, fmt.Errorf("forkchoiceUpdated: %w", err)
+	}
+	if fcu.PayloadID == nil {
+		return proposal, fmt.Errorf("forkchoiceUpdated: no payload id, status %s", fcu.PayloadStatus.Status)
+	}
+	proposal.PayloadID = fcu.PayloadID.String()
+
+	if proposal.Header, err = b.boost.GetPayloadHeader(ctx, proposal.PayloadID); err != nil {
+		return proposal, fmt.Errorf("getPayloadHeader: %w", err)
+	}
+
+	block := &lib.SignedBlindedBeaconBlock{
+		Message: &lib.BlindedBeaconBlock{
+			Slot: slot,
+			Body: &lib.BlindedBeaconBlockBody{ExecutionPayloadHeader: proposal.Header.Header()},
+		},
+	}
+	payload, err := b.boost.ProposeBlindedBlock(ctx, block)
+	if err != nil {
+		return proposal, fmt.Errorf("proposeBlindedBlock: %w", err)
+	}
+	if payload.BlockHash != proposal.Header.BlockHash {
+		return proposal, fmt.Errorf("proposeBlindedBlock: revealed payload of block %s for the header of %s", payload.BlockHash, proposal.Header.BlockHash)
+	}
+	proposal.Payload = payload
+	return proposal, nil
+}
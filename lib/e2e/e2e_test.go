@@ -0,0 +1,137 @@
+package e2e
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/flashbots/mev-boost/lib"
+	"github.com/flashbots/mev-boost/lib/logrusadapter"
+	"github.com/flashbots/mev-boost/lib/testutil"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/require"
+)
+
+// e2eGetHeaderTimeout is the time relays have to return a header in the end-to-end tests
+const e2eGetHeaderTimeout = 200 * time.Millisecond
+
+// newE2E starts a mev-boost verifying the bid signatures of relays, which sign their bids with keys 1, 2, ..., and
+// returns the relays and a beacon node of a validator proposing through it. Relay i bids i wei for block i.
+func newE2E(t *testing.T, relayCount int) ([]*testutil.MockRelay, *MockBeaconNode) {
+	relays := make([]*testutil.MockRelay, relayCount)
+	urls := make([]string, relayCount)
+	for i := range relays {
+		relays[i] = testutil.NewMockRelay()
+		t.Cleanup(relays[i].Close)
+		require.Nil(t, relays[i].SignBids(big.NewInt(int64(i+1)), lib.MainnetChainConfig))
+		relays[i].SetBid(common.BigToHash(big.NewInt(int64(i+1))), big.NewInt(int64(i+1)))
+		urls[i] = relays[i].URL()
+	}
+
+	router, err := lib.NewRouter(context.Background(), lib.WithRelayURLs(urls...), lib.WithStore(lib.NewStore()),
+		lib.WithLogger(logrusadapter.New(logrus.WithField("testing", true))), lib.WithCapabilityCheckInterval(0),
+		lib.WithBidSignatureVerification(), lib.WithRelayMethodTimeouts(map[string]time.Duration{testutil.MethodGetPayloadHeader: e2eGetHeaderTimeout}))
+	require.Nil(t, err)
+	server := httptest.NewServer(router)
+	t.Cleanup(server.Close)
+
+	beacon, err := NewMockBeaconNode(server.URL, lib.MainnetChainConfig, big.NewInt(100), common.HexToAddress("0xfee"))
+	require.Nil(t, err)
+	return relays, beacon
+}
+
+func TestE2E_SlotLifecycle(t *testing.T) {
+	relays, beacon := newE2E(t, 2)
+	ctx := context.Background()
+
+	require.Nil(t, beacon.Register(ctx, uint64(time.Now().Unix())))
+	for _, relay := range relays {
+		relay := relay
+		require.Eventually(t, func() bool {
+			registrations, err := relay.Registrations()
+			require.Nil(t, err)
+			return len(registrations) == 1
+		}, time.Second, 10*time.Millisecond, "the registration is sent to every relay")
+		registrations, _ := relay.Registrations()
+		require.Equal(t, beacon.Pubkey(), registrations[0].Message.Pubkey)
+	}
+
+	slot := lib.MainnetChainConfig.CurrentSlot() + 1
+	proposal, err := beacon.Propose(ctx, slot, common.HexToHash("0xaa"))
+	require.Nil(t, err)
+	require.Equal(t, common.BigToHash(big.NewInt(2)), proposal.Payload.BlockHash, "the best bid wins")
+	require.Len(t, relays[1].Requests(testutil.MethodProposeBlock), 1)
+	require.Empty(t, relays[0].Requests(testutil.MethodProposeBlock))
+	for _, relay := range relays {
+		require.Len(t, relay.Requests(testutil.MethodForkchoiceUpdated), 1, "the payload attributes are sent to every relay")
+	}
+}
+
+func TestE2E_RelayFaults(t *testing.T) {
+	tests := []struct {
+		name  string
+		fault func(best *testutil.MockRelay)
+		// blockHash is the block proposed, zero if the proposal fails with err
+		blockHash common.Hash
+		err       error
+	}{
+		{
+			name: "invalid bid signature",
+			fault: func(best *testutil.MockRelay) {
+				best.SetFault(testutil.MethodGetPayloadHeader, testutil.Fault{InvalidSignature: true})
+			},
+			blockHash: common.BigToHash(big.NewInt(1)),
+		},
+		{
+			name: "header too late",
+			fault: func(best *testutil.MockRelay) {
+				best.SetFault(testutil.MethodGetPayloadHeader, testutil.Fault{Delay: 2 * e2eGetHeaderTimeout})
+			},
+			blockHash: common.BigToHash(big.NewInt(1)),
+		},
+		{
+			name: "malformed header",
+			fault: func(best *testutil.MockRelay) {
+				best.SetFault(testutil.MethodGetPayloadHeader, testutil.Fault{Malformed: true})
+			},
+			blockHash: common.BigToHash(big.NewInt(1)),
+		},
+		{
+			name: "payload of another block",
+			fault: func(best *testutil.MockRelay) {
+				best.SetFault(testutil.MethodProposeBlock, testutil.Fault{PayloadMismatch: true})
+			},
+			err: lib.ErrHeaderMismatch,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			relays, beacon := newE2E(t, 2)
+			tt.fault(relays[1])
+
+			proposal, err := beacon.Propose(context.Background(), lib.MainnetChainConfig.CurrentSlot()+1, common.HexToHash("0xaa"))
+			if tt.err != nil {
+				require.True(t, errors.Is(err, tt.err), err)
+				return
+			}
+			require.Nil(t, err)
+			require.Equal(t, tt.blockHash, proposal.Payload.BlockHash)
+		})
+	}
+}
+
+func TestE2E_NoBids(t *testing.T) {
+	relays, beacon := newE2E(t, 2)
+	for _, relay := range relays {
+		relay.SetFault(testutil.MethodGetPayloadHeader, testutil.Fault{ErrorCode: -32000, ErrorMessage: "no bid"})
+	}
+
+	proposal, err := beacon.Propose(context.Background(), lib.MainnetChainConfig.CurrentSlot()+1, common.HexToHash("0xaa"))
+	require.True(t, errors.Is(err, lib.ErrNoBids), err)
+	require.NotEmpty(t, proposal.PayloadID)
+	require.Nil(t, proposal.Header)
+}
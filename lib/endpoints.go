@@ -0,0 +1,190 @@
+package lib
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// relayEndpointSeparator separates the endpoints of a relay entry, e.g. regional endpoints of a distributed relay
+const relayEndpointSeparator = "|"
+
+// relayEndpointCooldown is how long an endpoint that failed at the transport level is only used for failover
+var relayEndpointCooldown = 30 * time.Second
+
+// relayEndpointAlpha is the weight of the latest call in the moving average of the latency of an endpoint
+const relayEndpointAlpha = 0.2
+
+// RelayEndpoints returns the endpoints of a relay entry like "https://eu.relay.example|https://us.relay.example". The
+// relay is identified by its first endpoint.
+func RelayEndpoints(entry string) []string {
+	var endpoints []string
+	for _, endpoint := range strings.Split(entry, relayEndpointSeparator) {
+		if endpoint = strings.TrimSpace(endpoint); endpoint != "" {
+			endpoints = append(endpoints, endpoint)
+		}
+	}
+	return endpoints
+}
+
+// relayEndpoints picks the endpoint of relays with several endpoints: the healthy endpoint with the lowest latency,
+// failing over to the others when it can't be reached
+type relayEndpoints struct {
+	mu        sync.Mutex
+	endpoints map[string][]string      // map[relay url]endpoints, only relays with several endpoints
+	latencies map[string]time.Duration // map[endpoint]moving average of the call latency
+	failedAt  map[string]time.Time     // map[endpoint]last transport failure
+}
+
+// newRelayEndpoints splits relay entries into their endpoints, and returns the relay urls the entries are identified by
+func newRelayEndpoints(entries []string) (*relayEndpoints, []string, error) {
+	endpoints, relayURLs, err := parseRelayEntries(entries)
+	if err != nil {
+		return nil, nil, err
+	}
+	e := &relayEndpoints{
+		endpoints: endpoints,
+		latencies: make(map[string]time.Duration),
+		failedAt:  make(map[string]time.Time),
+	}
+	return e, relayURLs, nil
+}
+
+// parseRelayEntries returns the endpoints of the relay entries with several endpoints, by the relay url the entries
+// are identified by, and these relay urls in the order of the entries
+func parseRelayEntries(entries []string) (map[string][]string, []string, error) {
+	relayEndpoints := make(map[string][]string)
+	relayURLs := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		endpoints := RelayEndpoints(entry)
+		if len(endpoints) == 0 {
+			return nil, nil, fmt.Errorf("relay entry %q has no endpoints", entry)
+		}
+		relayURLs = append(relayURLs, endpoints[0])
+		if len(endpoints) == 1 {
+			continue
+		}
+		if err := sameRelayPubkey(endpoints); err != nil {
+			return nil, nil, err
+		}
+		relayEndpoints[endpoints[0]] = endpoints
+	}
+	return relayEndpoints, relayURLs, nil
+}
+
+// replace replaces the endpoints of the relays, see parseRelayEntries. The latencies of endpoints are kept.
+func (e *relayEndpoints) replace(endpoints map[string][]string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.endpoints = endpoints
+}
+
+// of returns the endpoints of a relay with several endpoints
+func (e *relayEndpoints) of(relayURL string) ([]string, bool) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	endpoints, ok := e.endpoints[relayURL]
+	return endpoints, ok
+}
+
+// sameRelayPubkey checks that the endpoints of a relay agree on its pubkey, the user part of the url
+func sameRelayPubkey(endpoints []string) error {
+	var pubkey string
+	for i, endpoint := range endpoints {
+		u, err := url.Parse(endpoint)
+		if err != nil {
+			return err
+		}
+		if i == 0 {
+			pubkey = u.User.Username()
+		} else if u.User.Username() != pubkey {
+			return fmt.Errorf("endpoints of relay %s have different pubkeys", endpoints[0])
+		}
+	}
+	return nil
+}
+
+// all returns every endpoint of a relay
+func (e *relayEndpoints) all(relayURL string) []string {
+	if endpoints, ok := e.of(relayURL); ok {
+		return endpoints
+	}
+	return []string{relayURL}
+}
+
+// ordered returns the endpoints of a relay in the order they should be tried: healthy endpoints by latency, endpoints
+// without latency yet in configured order, then endpoints that recently failed
+func (e *relayEndpoints) ordered(relayURL string) []string {
+	endpoints, ok := e.of(relayURL)
+	if !ok {
+		return []string{relayURL}
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	rank := func(endpoint string) (int, time.Duration) {
+		if failedAt, ok := e.failedAt[endpoint]; ok && now().Sub(failedAt) < relayEndpointCooldown {
+			return 2, 0
+		}
+		if latency, ok := e.latencies[endpoint]; ok {
+			return 0, latency
+		}
+		return 1, 0
+	}
+	ordered := append([]string(nil), endpoints...)
+	sort.SliceStable(ordered, func(i, j int) bool {
+		ri, li := rank(ordered[i])
+		rj, lj := rank(ordered[j])
+		if ri != rj {
+			return ri < rj
+		}
+		return li < lj
+	})
+	return ordered
+}
+
+// observe records the latency of a successful call to an endpoint, which also makes it healthy again
+func (e *relayEndpoints) observe(endpoint string, latency time.Duration) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if average, ok := e.latencies[endpoint]; ok {
+		latency = time.Duration(relayEndpointAlpha*float64(latency) + (1-relayEndpointAlpha)*float64(average))
+	}
+	e.latencies[endpoint] = latency
+	delete(e.failedAt, endpoint)
+}
+
+func (e *relayEndpoints) fail(endpoint string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.failedAt[endpoint] = now()
+}
+
+// call calls the endpoints of a relay in order until one can be reached. Only transport failures fail over, an endpoint
+// that answered with an error or an invalid response is as good as the relay behind it.
+func (e *relayEndpoints) call(ctx context.Context, relayURL string, log Logger, call func(endpoint string) error) error {
+	if _, ok := e.of(relayURL); !ok {
+		return call(relayURL)
+	}
+
+	var err error
+	for _, endpoint := range e.ordered(relayURL) {
+		sentAt := now()
+		if err = call(endpoint); err == nil {
+			e.observe(endpoint, now().Sub(sentAt))
+			return nil
+		}
+		var urlErr *url.Error
+		if !errors.As(err, &urlErr) || errors.Is(err, ErrValidationFailed) || ctx.Err() != nil {
+			return err
+		}
+		e.fail(endpoint)
+		log.WithFields(Fields{"url": relayURL, "endpoint": endpoint, "error": err}).Warn("relay endpoint unreachable, failing over")
+	}
+	return err
+}
@@ -0,0 +1,56 @@
+package lib
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRelayEndpoints_ordered(t *testing.T) {
+	defer func() { now = time.Now }()
+	start := time.Unix(1700000000, 0)
+	now = func() time.Time { return start }
+
+	endpoints, relayURLs, err := newRelayEndpoints([]string{"https://eu.relay.example|https://us.relay.example|https://asia.relay.example", "https://other.example"})
+	require.Nil(t, err)
+	require.Equal(t, []string{"https://eu.relay.example", "https://other.example"}, relayURLs, "relays are identified by their first endpoint")
+	require.Equal(t, []string{"https://other.example"}, endpoints.ordered("https://other.example"))
+	require.Equal(t, []string{"https://eu.relay.example", "https://us.relay.example", "https://asia.relay.example"}, endpoints.ordered("https://eu.relay.example"))
+
+	endpoints.observe("https://us.relay.example", 50*time.Millisecond)
+	endpoints.observe("https://eu.relay.example", 100*time.Millisecond)
+	require.Equal(t, []string{"https://us.relay.example", "https://eu.relay.example", "https://asia.relay.example"}, endpoints.ordered("https://eu.relay.example"))
+
+	endpoints.fail("https://us.relay.example")
+	require.Equal(t, []string{"https://eu.relay.example", "https://asia.relay.example", "https://us.relay.example"}, endpoints.ordered("https://eu.relay.example"))
+
+	now = func() time.Time { return start.Add(relayEndpointCooldown) }
+	require.Equal(t, []string{"https://us.relay.example", "https://eu.relay.example", "https://asia.relay.example"}, endpoints.ordered("https://eu.relay.example"), "failed endpoints recover after the cooldown")
+
+	_, _, err = newRelayEndpoints([]string{"https://0xab@eu.relay.example|https://0xcd@us.relay.example"})
+	require.Error(t, err)
+}
+
+func TestRelayService_EndpointFailover(t *testing.T) {
+	up := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		resp, err := formatResponse(RelayCapabilities{SpecVersion: builderSpecVersion})
+		require.Nil(t, err)
+		w.Write(resp)
+	}))
+	defer up.Close()
+	down := httptest.NewServer(http.NotFoundHandler())
+	down.Close()
+
+	service, err := newRelayService(WithRelayURLs(down.URL+"|"+up.URL), WithStore(NewStore()), WithLogger(testLog))
+	require.Nil(t, err)
+	require.Equal(t, []string{down.URL}, service.relays.all())
+
+	res, _, err := service.requestRelay(context.Background(), down.URL, methodRelayGetCapabilities, nil)
+	require.Nil(t, err)
+	require.Nil(t, res.Error)
+	require.Equal(t, []string{up.URL, down.URL}, service.endpoints.ordered(down.URL), "the unreachable endpoint is tried last")
+}
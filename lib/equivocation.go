@@ -0,0 +1,189 @@
+package lib
+
+import (
+	"sort"
+	"strconv"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// equivocationRetentionSlots is how many slots the equivocation guard remembers headers and blocks for
+const equivocationRetentionSlots = 64
+
+var equivocationsTotal = metricsFactory.NewCounterVec(prometheus.CounterOpts{
+	Name: "mevboost_equivocations_total",
+	Help: "Header requests and signed blocks conflicting with an earlier one of their slot and proposer, by kind (header or block) and action (logged or refused)",
+}, []string{"kind", "action"})
+
+// EquivocationGuard remembers the parent of the header returned for each slot and proposer, and the signed block whose
+// payload was revealed for each slot and proposer index. A later header request of the slot on another parent, or a signed block with another parent
+// or state root, may make the proposer sign two blocks for the slot, e.g. with redundant consensus clients that
+// disagree about the head. Such requests are logged and, if the guard refuses them, rejected with ErrEquivocation. Routers
+// given the same guard with WithEquivocationGuard share what they saw, they must serve the same network.
+type EquivocationGuard struct {
+	refuse bool
+
+	mu      sync.Mutex
+	headers map[equivocationKey]common.Hash        // parent of the first header returned
+	blocks  map[equivocationKey]equivocationRecord // first signed block
+}
+
+type equivocationKey struct {
+	slot     uint64
+	proposer string
+}
+
+type equivocationRecord struct {
+	parentRoot, stateRoot, blockHash common.Hash
+}
+
+// NewEquivocationGuard returns a guard that rejects conflicting requests if refuse is set, and only logs them otherwise
+func NewEquivocationGuard(refuse bool) *EquivocationGuard {
+	return &EquivocationGuard{
+		refuse:  refuse,
+		headers: make(map[equivocationKey]common.Hash),
+		blocks:  make(map[equivocationKey]equivocationRecord),
+	}
+}
+
+// prune removes the entries of slots before the retention, the caller holds mu
+func (g *EquivocationGuard) prune(slot uint64) {
+	if slot < equivocationRetentionSlots {
+		return
+	}
+	for key := range g.headers {
+		if key.slot < slot-equivocationRetentionSlots {
+			delete(g.headers, key)
+		}
+	}
+	for key := range g.blocks {
+		if key.slot < slot-equivocationRetentionSlots {
+			delete(g.blocks, key)
+		}
+	}
+}
+
+// checkHeader returns ErrEquivocation if a header was returned for the proposal on another parent and the guard refuses
+// conflicts. Proposals on an unknown parent aren't checked.
+func (g *EquivocationGuard) checkHeader(proposal ProposalKey, log Logger) error {
+	if proposal.ParentHash == nilHash {
+		return nil
+	}
+	g.mu.Lock()
+	parent, ok := g.headers[equivocationKey{proposal.Slot, proposal.Proposer}]
+	g.mu.Unlock()
+	if !ok || parent == proposal.ParentHash {
+		return nil
+	}
+	return g.conflict("header", Fields{"slot": proposal.Slot, "proposer": proposal.Proposer, "parent": proposal.ParentHash, "previousParent": parent}, log)
+}
+
+// headerReturned records the parent of a header returned for the proposal, unless one was recorded before
+func (g *EquivocationGuard) headerReturned(proposal ProposalKey) {
+	if proposal.ParentHash == nilHash {
+		return
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.prune(proposal.Slot)
+	key := equivocationKey{proposal.Slot, proposal.Proposer}
+	if _, ok := g.headers[key]; !ok {
+		g.headers[key] = proposal.ParentHash
+	}
+}
+
+// checkBlock returns ErrEquivocation for a block with another parent or state root than the block revealed for its slot
+// and proposer if the guard refuses conflicts. The same block may be sent again.
+func (g *EquivocationGuard) checkBlock(block *BlindedBeaconBlock, blockHash common.Hash, log Logger) error {
+	key := equivocationKey{block.Slot, strconv.FormatUint(block.ProposerIndex, 10)}
+	record := equivocationRecord{block.ParentRoot, block.StateRoot, blockHash}
+	g.mu.Lock()
+	first, ok := g.blocks[key]
+	g.mu.Unlock()
+	if !ok || (first.parentRoot == record.parentRoot && first.stateRoot == record.stateRoot) {
+		return nil
+	}
+	return g.conflict("block", Fields{
+		"slot":               block.Slot,
+		"proposerIndex":      block.ProposerIndex,
+		"parentRoot":         block.ParentRoot,
+		"stateRoot":          block.StateRoot,
+		"blockHash":          blockHash,
+		"previousParentRoot": first.parentRoot,
+		"previousStateRoot":  first.stateRoot,
+		"previousBlockHash":  first.blockHash,
+	}, log)
+}
+
+// blockRevealed records a signed block whose payload was revealed, unless a block of its slot and proposer was recorded
+// before. Only blocks with a verified signature that a relay answered are recorded, so a forged block can't make the
+// guard refuse the proposer's own.
+func (g *EquivocationGuard) blockRevealed(block *BlindedBeaconBlock, blockHash common.Hash) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.prune(block.Slot)
+	key := equivocationKey{block.Slot, strconv.FormatUint(block.ProposerIndex, 10)}
+	if _, ok := g.blocks[key]; !ok {
+		g.blocks[key] = equivocationRecord{block.ParentRoot, block.StateRoot, blockHash}
+	}
+}
+
+// history returns the headers and blocks the guard remembers, oldest first
+func (g *EquivocationGuard) history() EquivocationHistory {
+	history := EquivocationHistory{Headers: []EquivocationHeader{}, Blocks: []EquivocationBlock{}}
+	g.mu.Lock()
+	for key, parent := range g.headers {
+		history.Headers = append(history.Headers, EquivocationHeader{Slot: key.slot, Proposer: key.proposer, ParentHash: parent})
+	}
+	for key, record := range g.blocks {
+		proposerIndex, _ := strconv.ParseUint(key.proposer, 10, 64)
+		history.Blocks = append(history.Blocks, EquivocationBlock{
+			Slot:          key.slot,
+			ProposerIndex: proposerIndex,
+			ParentRoot:    record.parentRoot,
+			StateRoot:     record.stateRoot,
+			BlockHash:     record.blockHash,
+		})
+	}
+	g.mu.Unlock()
+	sort.Slice(history.Headers, func(i, j int) bool { return history.Headers[i].Slot < history.Headers[j].Slot })
+	sort.Slice(history.Blocks, func(i, j int) bool { return history.Blocks[i].Slot < history.Blocks[j].Slot })
+	return history
+}
+
+// restore adds the headers and blocks of history the guard doesn't remember yet, e.g. from another host, and returns how
+// many it added
+func (g *EquivocationGuard) restore(history EquivocationHistory) int {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	added := 0
+	for _, header := range history.Headers {
+		key := equivocationKey{header.Slot, header.Proposer}
+		if _, ok := g.headers[key]; !ok && header.ParentHash != nilHash {
+			g.headers[key] = header.ParentHash
+			added++
+		}
+	}
+	for _, block := range history.Blocks {
+		key := equivocationKey{block.Slot, strconv.FormatUint(block.ProposerIndex, 10)}
+		if _, ok := g.blocks[key]; !ok {
+			g.blocks[key] = equivocationRecord{block.ParentRoot, block.StateRoot, block.BlockHash}
+			added++
+		}
+	}
+	return added
+}
+
+// conflict logs and counts a conflicting request of kind, and returns ErrEquivocation if the guard refuses it
+func (g *EquivocationGuard) conflict(kind string, fields Fields, log Logger) error {
+	if !g.refuse {
+		equivocationsTotal.WithLabelValues(kind, "logged").Inc()
+		log.WithFields(fields).Error("possible equivocation: " + kind + " conflicts with an earlier one of the slot, check for consensus clients of the same validator on different heads")
+		return nil
+	}
+	equivocationsTotal.WithLabelValues(kind, "refused").Inc()
+	log.WithFields(fields).Error("refusing possible equivocation: " + kind + " conflicts with an earlier one of the slot, check for consensus clients of the same validator on different heads")
+	return newMethodError(ErrEquivocation, "%s conflicts with an earlier %s of slot %v, refusing to risk an equivocation", kind, kind, fields["slot"])
+}
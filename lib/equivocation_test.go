@@ -0,0 +1,73 @@
+package lib
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEquivocationGuard_Headers(t *testing.T) {
+	guard := NewEquivocationGuard(true)
+	proposal := ProposalKey{Slot: 100, ParentHash: common.HexToHash("0xaa"), Proposer: "0xab"}
+	require.Nil(t, guard.checkHeader(proposal, testLog))
+	guard.headerReturned(proposal)
+	require.Nil(t, guard.checkHeader(proposal, testLog), "the same parent may ask again")
+
+	otherParent := proposal
+	otherParent.ParentHash = common.HexToHash("0xbb")
+	err := guard.checkHeader(otherParent, testLog)
+	require.True(t, errors.Is(err, ErrEquivocation), "%v", err)
+	guard.headerReturned(otherParent)
+	require.Error(t, guard.checkHeader(otherParent, testLog), "the first header stays recorded")
+
+	otherProposer := otherParent
+	otherProposer.Proposer = "0xcd"
+	require.Nil(t, guard.checkHeader(otherProposer, testLog))
+	otherParent.ParentHash = nilHash
+	require.Nil(t, guard.checkHeader(otherParent, testLog), "unknown parents aren't checked")
+
+	logged := testutil.ToFloat64(equivocationsTotal.WithLabelValues("header", "logged"))
+	require.Nil(t, NewEquivocationGuard(false).checkHeader(proposal, testLog))
+	guard.refuse = false
+	require.Nil(t, guard.checkHeader(ProposalKey{100, common.HexToHash("0xbb"), "0xab"}, testLog), "conflicts are only logged without refuse")
+	require.Equal(t, logged+1, testutil.ToFloat64(equivocationsTotal.WithLabelValues("header", "logged")))
+
+	guard.headerReturned(ProposalKey{Slot: 100 + equivocationRetentionSlots + 1, ParentHash: common.HexToHash("0x01"), Proposer: "0xab"})
+	guard.refuse = true
+	require.Nil(t, guard.checkHeader(otherParent, testLog), "old slots are forgotten")
+}
+
+func TestRelayService_ProposeBlindedBlockV1_EquivocationGuard(t *testing.T) {
+	payload := &ExecutionPayloadWithTxRootV1{BlockHash: common.HexToHash("0x01"), BaseFeePerGas: big.NewInt(1), FeeRecipientDiff: big.NewInt(1)}
+	store := NewStore()
+	store.SetExecutionPayload(context.Background(), payload.BlockHash, payload)
+	service, err := newRelayService(WithRelayURLs("http://localhost:1"), WithStore(store), WithLogger(testLog), WithEquivocationGuard(NewEquivocationGuard(true)))
+	require.Nil(t, err)
+
+	block := func(stateRoot string) *SignedBlindedBeaconBlock {
+		return &SignedBlindedBeaconBlock{Message: &BlindedBeaconBlock{
+			Slot:          100,
+			ProposerIndex: 7,
+			ParentRoot:    common.HexToHash("0xaa"),
+			StateRoot:     common.HexToHash(stateRoot),
+			Body:          &BlindedBeaconBlockBody{ExecutionPayloadHeader: payload.Header()},
+		}}
+	}
+	// a block whose payload no relay reveals, e.g. a forged one, isn't recorded
+	unknown := block("0x03")
+	unknown.Message.Body.ExecutionPayloadHeader = (&ExecutionPayloadWithTxRootV1{BlockHash: common.HexToHash("0x09"), BaseFeePerGas: big.NewInt(1), FeeRecipientDiff: big.NewInt(1)}).Header()
+	err = service.ProposeBlindedBlockV1(nil, unknown, new(ExecutionPayloadWithTxRootV1))
+	require.True(t, errors.Is(err, ErrUnknownPayload), "%v", err)
+
+	require.Nil(t, service.ProposeBlindedBlockV1(nil, block("0x01"), new(ExecutionPayloadWithTxRootV1)))
+	require.Nil(t, service.ProposeBlindedBlockV1(nil, block("0x01"), new(ExecutionPayloadWithTxRootV1)), "the same block may be sent again")
+
+	err = service.ProposeBlindedBlockV1(nil, block("0x02"), new(ExecutionPayloadWithTxRootV1))
+	require.True(t, errors.Is(err, ErrEquivocation), "%v", err)
+	require.Equal(t, ErrorCodeEquivocation, err.(*MethodError).ErrorCode())
+}
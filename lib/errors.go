@@ -0,0 +1,161 @@
+package lib
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+)
+
+// Errors returned by the RelayService methods, use errors.Is to check for them
+var (
+	// ErrNoBids means no relay returned a valid bid or payload id
+	ErrNoBids = errors.New("no bids")
+	// ErrRelayTimeout means all relays that could answer timed out
+	ErrRelayTimeout = errors.New("relay timeout")
+	// ErrValidationFailed means relays answered, but their responses failed validation
+	ErrValidationFailed = errors.New("validation failed")
+	// ErrHeaderMismatch means a relay revealed a payload that doesn't match the header the proposer signed
+	ErrHeaderMismatch = errors.New("payload doesn't match header")
+	// ErrUnknownPayload means neither mev-boost nor the relays know the requested payload id or block hash
+	ErrUnknownPayload = errors.New("unknown payload")
+	// ErrInvalidSignature means the proposer signature of a blinded block or a validator registration is invalid
+	ErrInvalidSignature = errors.New("invalid proposer signature")
+	// ErrStalePayloadID means the payload id was issued too many slots ago, it may have been built on an old head
+	ErrStalePayloadID = errors.New("stale payload id")
+	// ErrChainStateMismatch means the beacon node disagrees with the proposal about the slot or its proposer
+	ErrChainStateMismatch = errors.New("chain state mismatch")
+	// ErrBuildLocally means no relay returned a valid bid and the consensus client should propose a payload of its own
+	// execution client, returned instead of the failure with WithNoBidsBehavior(NoBidsLocal)
+	ErrBuildLocally = errors.New("build locally")
+	// ErrEquivocation means a header request or signed block conflicts with an earlier one of the slot, see
+	// WithEquivocationGuard
+	ErrEquivocation = errors.New("equivocation")
+)
+
+// JSON-RPC error codes of mev-boost failures, so consensus clients can branch on them, e.g. fall back to local block building
+const (
+	// ErrorCodeNoBids is the code of ErrNoBids
+	ErrorCodeNoBids = -32001
+	// ErrorCodeRelayTimeout is the code of ErrRelayTimeout
+	ErrorCodeRelayTimeout = -32002
+	// ErrorCodeValidationFailed is the code of ErrValidationFailed, ErrHeaderMismatch and ErrInvalidSignature
+	ErrorCodeValidationFailed = -32003
+	// ErrorCodeUnknownPayload is the code of ErrUnknownPayload
+	ErrorCodeUnknownPayload = -32004
+	// ErrorCodeStalePayloadID is the code of ErrStalePayloadID
+	ErrorCodeStalePayloadID = -32005
+	// ErrorCodeChainStateMismatch is the code of ErrChainStateMismatch
+	ErrorCodeChainStateMismatch = -32006
+	// ErrorCodeBuildLocally is the code of ErrBuildLocally
+	ErrorCodeBuildLocally = -32007
+	// ErrorCodeRateLimited is the code of calls over the rate limit of their method, see WithMethodRateLimits
+	ErrorCodeRateLimited = -32008
+	// ErrorCodeEquivocation is the code of ErrEquivocation
+	ErrorCodeEquivocation = -32009
+)
+
+var errorCodes = map[error]int{
+	ErrNoBids:             ErrorCodeNoBids,
+	ErrRelayTimeout:       ErrorCodeRelayTimeout,
+	ErrValidationFailed:   ErrorCodeValidationFailed,
+	ErrHeaderMismatch:     ErrorCodeValidationFailed,
+	ErrInvalidSignature:   ErrorCodeValidationFailed,
+	ErrUnknownPayload:     ErrorCodeUnknownPayload,
+	ErrStalePayloadID:     ErrorCodeStalePayloadID,
+	ErrChainStateMismatch: ErrorCodeChainStateMismatch,
+	ErrBuildLocally:       ErrorCodeBuildLocally,
+	ErrEquivocation:       ErrorCodeEquivocation,
+	errMethodNotFound:     rpcErrMethodNotFound,
+	errInvalidParams:      rpcErrInvalidParams,
+}
+
+// MethodError is a failure of a RelayService method. It wraps one of the Err* values and is returned to the
+// consensus client with the matching JSON-RPC error code.
+type MethodError struct {
+	Kind    error
+	Message string
+}
+
+func newMethodError(kind error, format string, args ...interface{}) error {
+	return &MethodError{Kind: kind, Message: fmt.Sprintf(format, args...)}
+}
+
+func (e *MethodError) Error() string {
+	return e.Message
+}
+
+// ErrorCode implements the error interface of the JSON-RPC codec
+func (e *MethodError) ErrorCode() int {
+	return errorCodes[e.Kind]
+}
+
+func (e *MethodError) Unwrap() error {
+	return e.Kind
+}
+
+// isTimeout reports whether a relay request failed because it took too long
+func isTimeout(err error) bool {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
+
+// relayFailures classifies the failed relay requests of a call, to pick the error if no relay succeeds
+type relayFailures struct {
+	mu         sync.Mutex
+	timeouts   int
+	validation int
+	mismatch   int
+	other      int
+}
+
+// request records a failed request, classified by its error
+func (f *relayFailures) request(err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if isTimeout(err) {
+		f.timeouts++
+	} else if errors.Is(err, ErrValidationFailed) {
+		f.validation++
+	} else {
+		f.other++
+	}
+}
+
+// invalid records a response that failed validation
+func (f *relayFailures) invalid() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.validation++
+}
+
+// mismatched records a payload that doesn't match the requested header
+func (f *relayFailures) mismatched() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.mismatch++
+}
+
+// kind returns ErrRelayTimeout if all relays timed out, ErrHeaderMismatch or ErrValidationFailed if any response was invalid, and fallback otherwise
+func (f *relayFailures) kind(fallback error) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	switch {
+	case f.timeouts > 0 && f.validation == 0 && f.mismatch == 0 && f.other == 0:
+		return ErrRelayTimeout
+	case f.mismatch > 0:
+		return ErrHeaderMismatch
+	case f.validation > 0:
+		return ErrValidationFailed
+	default:
+		return fallback
+	}
+}
@@ -0,0 +1,121 @@
+package lib
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_relayFailures_kind(t *testing.T) {
+	tests := []struct {
+		name     string
+		failures func(f *relayFailures)
+		expected error
+	}{
+		{"no failures", func(f *relayFailures) {}, ErrNoBids},
+		{"all timeouts", func(f *relayFailures) { f.request(context.DeadlineExceeded) }, ErrRelayTimeout},
+		{"http client timeout", func(f *relayFailures) {
+			f.request(&url.Error{Op: "Post", URL: "http://relay", Err: os.ErrDeadlineExceeded})
+		}, ErrRelayTimeout},
+		{"timeout and error reply", func(f *relayFailures) {
+			f.request(context.DeadlineExceeded)
+			f.request(rpcError{Code: -32000, Message: "no bid"})
+		}, ErrNoBids},
+		{"invalid response", func(f *relayFailures) {
+			f.request(context.DeadlineExceeded)
+			f.invalid()
+		}, ErrValidationFailed},
+		{"mismatched payload", func(f *relayFailures) {
+			f.invalid()
+			f.mismatched()
+		}, ErrHeaderMismatch},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var failures relayFailures
+			tt.failures(&failures)
+			require.Equal(t, tt.expected, failures.kind(ErrNoBids))
+		})
+	}
+}
+
+func TestRouter_ErrorCodes(t *testing.T) {
+	errorRelay := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		resp, err := formatErrorResponse("no bid")
+		require.Nil(t, err)
+		w.Write(resp)
+	}))
+	defer errorRelay.Close()
+
+	mismatchRelay := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		resp, err := formatResponse(ExecutionPayloadWithTxRootV1{BlockHash: common.HexToHash("0x02"), BaseFeePerGas: big.NewInt(1), FeeRecipientDiff: big.NewInt(1)})
+		require.Nil(t, err)
+		w.Write(resp)
+	}))
+	defer mismatchRelay.Close()
+
+	signedBlock := SignedBlindedBeaconBlock{
+		Message: &BlindedBeaconBlock{
+			Slot: 1,
+			Body: &BlindedBeaconBlockBody{ExecutionPayloadHeader: (&ExecutionPayloadWithTxRootV1{
+				BlockHash:     common.HexToHash("0x01"),
+				BaseFeePerGas: big.NewInt(1),
+			}).Header()},
+		},
+	}
+
+	tests := []struct {
+		name         string
+		relayURL     string
+		method       string
+		params       []interface{}
+		expectedCode int
+	}{
+		{"unknown payload id", errorRelay.URL, "builder_getPayloadHeaderV1", []interface{}{"0x0102030405060708"}, ErrorCodeUnknownPayload},
+		{"no bids", errorRelay.URL, "builder_getPayloadHeaderV1", []interface{}{"0x01"}, ErrorCodeNoBids},
+		{"no payload id", errorRelay.URL, "engine_forkchoiceUpdatedV1", []interface{}{map[string]interface{}{}}, ErrorCodeNoBids},
+		{"unknown block", errorRelay.URL, "builder_proposeBlindedBlockV1", []interface{}{signedBlock}, ErrorCodeUnknownPayload},
+		{"payload for a different block", mismatchRelay.URL, "builder_proposeBlindedBlockV1", []interface{}{signedBlock}, ErrorCodeValidationFailed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := NewStore()
+			store.SetForkchoiceResponse(context.Background(), "0x01", tt.relayURL, "0x01")
+			router, err := NewRouter(context.Background(), WithRelayURLs(tt.relayURL), WithStore(store), WithLogger(testLog))
+			require.Nil(t, err)
+
+			body, err := formatRequestBody(tt.method, tt.params)
+			require.Nil(t, err)
+			req := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(body))
+			req.Header.Add("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			resp, err := parseRPCResponse(w.Body.Bytes())
+			require.Nil(t, err)
+			require.NotNil(t, resp.Error)
+			require.Equal(t, tt.expectedCode, resp.Error.Code, resp.Error.Message)
+		})
+	}
+}
+
+func TestMethodError(t *testing.T) {
+	err := fmt.Errorf("proposing: %w", newMethodError(ErrHeaderMismatch, "relay revealed block %s", "0x01"))
+	require.True(t, errors.Is(err, ErrHeaderMismatch))
+	require.False(t, errors.Is(err, ErrNoBids))
+
+	var methodErr *MethodError
+	require.True(t, errors.As(err, &methodErr))
+	require.Equal(t, ErrorCodeValidationFailed, methodErr.ErrorCode())
+	require.Equal(t, "relay revealed block 0x01", methodErr.Error())
+}
@@ -0,0 +1,38 @@
+package lib
+
+import (
+	"context"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var escrowForwardsTotal = metricsFactory.NewCounterVec(prometheus.CounterOpts{
+	Name: "mevboost_escrow_forwards_total",
+	Help: "Signed blocks forwarded to relays in escrow mode after their payload was revealed, by relay and result",
+}, []string{"relay", "result"})
+
+// forwardEscrowedBlock sends a signed block whose payload mev-boost already holds to the relay of the bid, so the relay
+// learns that its bid won and can publish the block too. The consensus client already got the payload, so this
+// doesn't block the proposal.
+func (m *RelayService) forwardEscrowedBlock(block *SignedBlindedBeaconBlock, relayURL string, blockHash common.Hash, logMethod Logger) {
+	go func() {
+		ctx, cancel := context.WithTimeout(withCapella(context.Background(), m.chain, block.Message.Slot), m.chain.SlotDuration())
+		defer cancel()
+
+		log := logMethod.WithFields(Fields{"url": relayURL, "blockHash": blockHash})
+		res, timing, err := m.requestRelay(ctx, relayURL, methodRelayProposeBlock, []interface{}{block})
+		m.timings.finish(timing, block.Message.Slot, err)
+		switch {
+		case err != nil:
+			escrowForwardsTotal.WithLabelValues(relayURL, "error").Inc()
+			log.WithError(err).Warn("could not forward the escrowed signed block to the relay")
+		case res.Error != nil:
+			escrowForwardsTotal.WithLabelValues(relayURL, "error").Inc()
+			log.WithField("error", res.Error).Warn("relay rejected the escrowed signed block")
+		default:
+			escrowForwardsTotal.WithLabelValues(relayURL, "forwarded").Inc()
+			log.Debug("forwarded the escrowed signed block to the relay")
+		}
+	}()
+}
@@ -0,0 +1,68 @@
+package lib
+
+import (
+	"context"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRelayService_PayloadEscrow(t *testing.T) {
+	withTransactions := ExecutionPayloadWithTxRootV1{BlockHash: common.HexToHash("0x01"), BaseFeePerGas: big.NewInt(1), FeeRecipientDiff: big.NewInt(1), Transactions: &[]string{}}
+	var forwarded int32
+	escrowed := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Method string `json:"method"`
+		}
+		require.Nil(t, json.NewDecoder(r.Body).Decode(&req))
+		if req.Method == methodRelayProposeBlock {
+			atomic.AddInt32(&forwarded, 1)
+		}
+		resp, err := formatResponse(withTransactions)
+		require.Nil(t, err)
+		w.Write(resp)
+	}))
+	defer escrowed.Close()
+	headerOnly := newHeaderRelay(t, ExecutionPayloadWithTxRootV1{BlockHash: common.HexToHash("0x02"), BaseFeePerGas: big.NewInt(1), FeeRecipientDiff: big.NewInt(5)})
+	defer headerOnly.Close()
+
+	newService := func(relayURLs ...string) *RelayService {
+		store := NewStore()
+		for _, relayURL := range relayURLs {
+			store.SetForkchoiceResponse(context.Background(), "0x01", relayURL, "0x01")
+		}
+		service, err := newRelayService(WithRelayURLs(relayURLs...), WithStore(store), WithLogger(testLog), WithPayloadEscrow())
+		require.Nil(t, err)
+		return service
+	}
+	propose := func(service *RelayService, header ExecutionPayloadWithTxRootV1) error {
+		block := &SignedBlindedBeaconBlock{Message: &BlindedBeaconBlock{Slot: 1, Body: &BlindedBeaconBlockBody{ExecutionPayloadHeader: header.Header()}}}
+		return service.ProposeBlindedBlockV1(nil, block, new(ExecutionPayloadWithTxRootV1))
+	}
+
+	// the more valuable bid without transactions isn't selected, the escrowed payload is revealed by mev-boost
+	service := newService(escrowed.URL, headerOnly.URL)
+	payloadID := "0x01"
+	header := new(ExecutionPayloadWithTxRootV1)
+	require.Nil(t, service.GetPayloadHeaderV1(nil, &payloadID, header))
+	require.Equal(t, withTransactions.BlockHash, header.BlockHash)
+	results := make(map[string]string)
+	for _, bid := range service.bids.slot(0) {
+		results[bid.RelayURL] = bid.Result
+	}
+	require.Equal(t, map[string]string{escrowed.URL: BidResultWon, headerOnly.URL: BidResultNotEscrowed}, results)
+	require.Nil(t, propose(service, *header))
+	require.Eventually(t, func() bool { return atomic.LoadInt32(&forwarded) == 1 }, time.Second, 10*time.Millisecond, "the signed block is forwarded after the reveal")
+
+	// blocks whose payload mev-boost doesn't hold aren't forwarded
+	service = newService(headerOnly.URL)
+	err := propose(service, ExecutionPayloadWithTxRootV1{BlockHash: common.HexToHash("0x02"), BaseFeePerGas: big.NewInt(1)})
+	require.ErrorIs(t, err, ErrUnknownPayload)
+}
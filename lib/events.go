@@ -0,0 +1,124 @@
+package lib
+
+import (
+	"context"
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// EventKind is the kind of an Event
+type EventKind string
+
+// Kinds of the events published while serving proposals
+const (
+	// EventAttributesReceived is published for each forkchoiceUpdated call with payload attributes
+	EventAttributesReceived EventKind = "attributesReceived"
+	// EventBidReceived is published for each valid header a relay returned
+	EventBidReceived EventKind = "bidReceived"
+	// EventBidSelected is published for the header returned to the consensus client
+	EventBidSelected EventKind = "bidSelected"
+	// EventBlockSigned is published for each blinded block with a valid proposer signature
+	EventBlockSigned EventKind = "blockSigned"
+	// EventPayloadRevealed is published for the payload returned to the consensus client
+	EventPayloadRevealed EventKind = "payloadRevealed"
+	// EventRelayFault is published when a relay failed a request, was suspended or was taken out of the rotation
+	EventRelayFault EventKind = "relayFault"
+	// EventDeliveryVerified is published when a delivered payload was checked against the finalized chain
+	EventDeliveryVerified EventKind = "deliveryVerified"
+)
+
+// Faults of EventRelayFault events
+const (
+	// RelayFaultRequest means the request failed or the relay answered with an error
+	RelayFaultRequest = "request"
+	// RelayFaultInvalid means the response of the relay failed validation
+	RelayFaultInvalid = "invalid"
+	// RelayFaultMismatch means the relay revealed a payload that doesn't match the signed header
+	RelayFaultMismatch = "mismatch"
+	// RelayFaultSuspended means the relay was suspended for underpaying its bids
+	RelayFaultSuspended = "suspended"
+	// RelayFaultUnhealthy means the relay was taken out of the rotation after consecutive failures
+	RelayFaultUnhealthy = "unhealthy"
+)
+
+// Event is something that happened while serving a proposal. Subscribers get the same value and must not modify it.
+type Event struct {
+	Kind      EventKind    `json:"kind"`
+	Time      time.Time    `json:"time"`
+	RelayURL  string       `json:"relay,omitempty"`
+	Slot      uint64       `json:"slot,omitempty"`
+	BlockHash *common.Hash `json:"blockHash,omitempty"`
+	Value     *big.Int     `json:"value,omitempty"`
+	Fault     string       `json:"fault,omitempty"`
+	Error     string       `json:"error,omitempty"`
+	// Fields are the details of a relay suspension, or the status of a delivery verification
+	Fields map[string]interface{} `json:"fields,omitempty"`
+	// Payload is the header of bid events and the payload of EventPayloadRevealed
+	Payload *ExecutionPayloadWithTxRootV1 `json:"-"`
+}
+
+// EventSubscriber is called with the events it subscribed to, on the goroutine that published them. Subscribers that
+// do I/O must hand the event off instead of holding up the proposal.
+type EventSubscriber func(ctx context.Context, event *Event)
+
+type eventSubscription struct {
+	fn    EventSubscriber
+	kinds []EventKind
+}
+
+// eventBus passes the events of the RelayService to the metrics, the webhook, hooks, the event stream and subscribers
+// added with WithEventSubscriber, so consumers don't need calls in the handlers. A nil bus drops events.
+type eventBus struct {
+	mu          sync.RWMutex
+	subscribers map[EventKind][]EventSubscriber // map[kind]subscribers, "" for subscribers of all kinds
+}
+
+func newEventBus() *eventBus {
+	return &eventBus{subscribers: make(map[EventKind][]EventSubscriber)}
+}
+
+// subscribe calls fn with the events of the given kinds, or all events if no kind is given
+func (b *eventBus) subscribe(fn EventSubscriber, kinds ...EventKind) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if len(kinds) == 0 {
+		kinds = []EventKind{""}
+	}
+	for _, kind := range kinds {
+		b.subscribers[kind] = append(b.subscribers[kind], fn)
+	}
+}
+
+// publish calls the subscribers of the event in the order they subscribed
+func (b *eventBus) publish(ctx context.Context, event *Event) {
+	if b == nil {
+		return
+	}
+	if event.Time.IsZero() {
+		event.Time = now()
+	}
+	b.mu.RLock()
+	subscribers := append(append([]EventSubscriber(nil), b.subscribers[event.Kind]...), b.subscribers[""]...)
+	b.mu.RUnlock()
+	for _, fn := range subscribers {
+		fn(ctx, event)
+	}
+}
+
+// payloadEvent is an event about a header or payload of a relay
+func payloadEvent(kind EventKind, relayURL string, slot uint64, payload *ExecutionPayloadWithTxRootV1) *Event {
+	blockHash := payload.BlockHash
+	return &Event{Kind: kind, RelayURL: relayURL, Slot: slot, BlockHash: &blockHash, Value: payload.FeeRecipientDiff, Payload: payload}
+}
+
+// relayFault publishes a failure of a relay
+func (m *RelayService) relayFault(ctx context.Context, relayURL, fault string, err error) {
+	event := &Event{Kind: EventRelayFault, RelayURL: relayURL, Fault: fault}
+	if err != nil {
+		event.Error = err.Error()
+	}
+	m.events.publish(ctx, event)
+}
@@ -0,0 +1,138 @@
+package lib
+
+import (
+	"bufio"
+	"context"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/gorilla/websocket"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEventBus(t *testing.T) {
+	var received []string
+	bus := newEventBus()
+	bus.subscribe(func(_ context.Context, event *Event) { received = append(received, "all:"+string(event.Kind)) })
+	bus.subscribe(func(_ context.Context, event *Event) { received = append(received, "faults:"+event.RelayURL) }, EventRelayFault)
+
+	bus.publish(context.Background(), &Event{Kind: EventBidSelected})
+	fault := &Event{Kind: EventRelayFault, RelayURL: "https://relay.example.com"}
+	bus.publish(context.Background(), fault)
+	require.Equal(t, []string{"all:bidSelected", "faults:https://relay.example.com", "all:relayFault"}, received)
+	require.False(t, fault.Time.IsZero())
+
+	var disabled *eventBus
+	disabled.publish(context.Background(), &Event{Kind: EventBidSelected})
+}
+
+func TestRelayService_Events(t *testing.T) {
+	valid := newHeaderRelay(t, ExecutionPayloadWithTxRootV1{BlockHash: common.HexToHash("0x01"), BaseFeePerGas: big.NewInt(1), FeeRecipientDiff: big.NewInt(1)})
+	defer valid.Close()
+	invalid := newHeaderRelay(t, ExecutionPayloadWithTxRootV1{BaseFeePerGas: big.NewInt(1), FeeRecipientDiff: big.NewInt(2)})
+	defer invalid.Close()
+
+	var mu sync.Mutex
+	var events []*Event
+	store := NewStore()
+	for _, relayURL := range []string{valid.URL, invalid.URL} {
+		store.SetForkchoiceResponse(context.Background(), "0x01", relayURL, "0x01")
+	}
+	service, err := newRelayService(WithRelayURLs(valid.URL, invalid.URL), WithStore(store), WithLogger(testLog),
+		WithEventSubscriber(func(_ context.Context, event *Event) {
+			mu.Lock()
+			defer mu.Unlock()
+			events = append(events, event)
+		}, EventBidReceived, EventBidSelected, EventRelayFault))
+	require.Nil(t, err)
+
+	payloadID := "0x01"
+	require.Nil(t, service.GetPayloadHeaderV1(nil, &payloadID, new(ExecutionPayloadWithTxRootV1)))
+	kinds := make(map[EventKind]string)
+	for _, event := range events {
+		kinds[event.Kind] = event.RelayURL
+	}
+	require.Equal(t, map[EventKind]string{
+		EventBidReceived: valid.URL,
+		EventBidSelected: valid.URL,
+		EventRelayFault:  invalid.URL,
+	}, kinds)
+}
+
+func TestRelayService_Metrics(t *testing.T) {
+	valid := newHeaderRelay(t, ExecutionPayloadWithTxRootV1{BlockHash: common.HexToHash("0x01"), BaseFeePerGas: big.NewInt(1), FeeRecipientDiff: big.NewInt(2e18)})
+	defer valid.Close()
+	invalid := newHeaderRelay(t, ExecutionPayloadWithTxRootV1{BaseFeePerGas: big.NewInt(1), FeeRecipientDiff: big.NewInt(3e18)})
+	defer invalid.Close()
+
+	store := NewStore()
+	for _, relayURL := range []string{valid.URL, invalid.URL} {
+		store.SetForkchoiceResponse(context.Background(), "0x01", relayURL, "0x01")
+	}
+	service, err := newRelayService(WithRelayURLs(valid.URL, invalid.URL), WithStore(store), WithLogger(testLog))
+	require.Nil(t, err)
+
+	payloadID := "0x01"
+	require.Nil(t, service.GetPayloadHeaderV1(nil, &payloadID, new(ExecutionPayloadWithTxRootV1)))
+	for _, relayURL := range []string{valid.URL, invalid.URL} {
+		require.Equal(t, 1.0, testutil.ToFloat64(relayRequestsTotal.WithLabelValues(relayURL, methodRelayGetHeader, "success")), "requests are counted before validation")
+	}
+	require.Equal(t, 1.0, testutil.ToFloat64(relayBidsTotal.WithLabelValues(valid.URL)))
+	require.Equal(t, 0.0, testutil.ToFloat64(relayBidsTotal.WithLabelValues(invalid.URL)))
+	require.Equal(t, 1.0, testutil.ToFloat64(headersServedTotal.WithLabelValues(valid.URL)))
+}
+
+func TestRelayService_handleEvents(t *testing.T) {
+	service, err := newRelayService(WithRelayURLs("http://relay"), WithLogger(testLog))
+	require.Nil(t, err)
+	server := httptest.NewServer(http.HandlerFunc(service.handleEvents))
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "?kind=relayFault")
+	require.Nil(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+
+	service.events.publish(context.Background(), &Event{Kind: EventBidSelected})
+	service.events.publish(context.Background(), &Event{Kind: EventRelayFault, RelayURL: "http://relay", Fault: RelayFaultRequest})
+	reader := bufio.NewReader(resp.Body)
+	line, err := reader.ReadString('\n')
+	require.Nil(t, err)
+	require.Equal(t, "event: relayFault\n", line)
+	line, err = reader.ReadString('\n')
+	require.Nil(t, err)
+	require.True(t, strings.HasPrefix(line, `data: {"kind":"relayFault"`), line)
+	require.Contains(t, line, `"fault":"request"`)
+}
+
+func TestRelayService_handleEventsWebSocket(t *testing.T) {
+	service, err := newRelayService(WithRelayURLs("http://relay"), WithLogger(testLog))
+	require.Nil(t, err)
+	server := httptest.NewServer(http.HandlerFunc(service.handleEvents))
+	defer server.Close()
+
+	ws, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http")+"?kind=relayFault,bidSelected", nil)
+	require.Nil(t, err)
+	defer ws.Close()
+
+	service.events.publish(context.Background(), &Event{Kind: EventBidReceived, RelayURL: "http://relay"})
+	service.events.publish(context.Background(), &Event{Kind: EventRelayFault, RelayURL: "http://relay", Fault: RelayFaultRequest})
+	var event Event
+	require.Nil(t, ws.ReadJSON(&event))
+	require.Equal(t, EventRelayFault, event.Kind)
+	require.Equal(t, RelayFaultRequest, event.Fault)
+
+	require.Nil(t, ws.Close())
+	require.Eventually(t, func() bool {
+		service.stream.mu.Lock()
+		defer service.stream.mu.Unlock()
+		return len(service.stream.clients) == 0
+	}, time.Second, 10*time.Millisecond, "the client is removed once the connection is closed")
+}
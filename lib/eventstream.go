@@ -0,0 +1,169 @@
+package lib
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	// eventStreamBuffer is how many events a client of the event stream may fall behind before events are dropped for it
+	eventStreamBuffer = 64
+	// eventStreamKeepAlive is how often an idle event stream sends a comment, so proxies don't close it
+	eventStreamKeepAlive = 15 * time.Second
+)
+
+var eventStreamDroppedTotal = metricsFactory.NewCounter(prometheus.CounterOpts{
+	Name: "mevboost_event_stream_dropped_total",
+	Help: "Events dropped for event stream clients that fell behind",
+})
+
+// eventStream passes the events of the event bus to the clients of /mev-boost/v1/events, over server-sent events or
+// WebSocket
+type eventStream struct {
+	mu      sync.Mutex
+	clients map[chan *Event]struct{}
+}
+
+func newEventStream() *eventStream {
+	return &eventStream{clients: make(map[chan *Event]struct{})}
+}
+
+func (s *eventStream) onEvent(_ context.Context, event *Event) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for client := range s.clients {
+		select {
+		case client <- event:
+		default:
+			eventStreamDroppedTotal.Inc()
+		}
+	}
+}
+
+func (s *eventStream) add() chan *Event {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	client := make(chan *Event, eventStreamBuffer)
+	s.clients[client] = struct{}{}
+	return client
+}
+
+func (s *eventStream) remove(client chan *Event) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.clients, client)
+}
+
+// eventKinds returns the kinds in the comma separated kind parameter of r, nil for all kinds
+func eventKinds(r *http.Request) map[EventKind]bool {
+	kind := r.URL.Query().Get("kind")
+	if kind == "" {
+		return nil
+	}
+	kinds := make(map[EventKind]bool)
+	for _, kind := range strings.Split(kind, ",") {
+		kinds[EventKind(kind)] = true
+	}
+	return kinds
+}
+
+// handleEvents streams the events of the event bus as server-sent events, or as JSON text messages if the request opens
+// a WebSocket connection. Only the kinds in the comma separated kind parameter are sent if it's given.
+func (m *RelayService) handleEvents(w http.ResponseWriter, r *http.Request) {
+	kinds := eventKinds(r)
+	if websocket.IsWebSocketUpgrade(r) {
+		m.handleEventsWebSocket(w, r, kinds)
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming is not supported", http.StatusInternalServerError)
+		return
+	}
+
+	client := m.stream.add()
+	defer m.stream.remove(client)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	keepAlive := time.NewTicker(eventStreamKeepAlive)
+	defer keepAlive.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-keepAlive.C:
+			fmt.Fprint(w, ": keepalive\n\n")
+		case event := <-client:
+			if len(kinds) > 0 && !kinds[event.Kind] {
+				continue
+			}
+			data, err := json.Marshal(event)
+			if err != nil {
+				m.log.WithError(err).Error("could not encode event")
+				continue
+			}
+			fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event.Kind, data)
+		}
+		flusher.Flush()
+	}
+}
+
+// handleEventsWebSocket sends each event as a JSON text message. Messages of the client are discarded, the connection
+// is pinged as often as the event stream sends keepalives and closed when a write fails or the client goes away.
+func (m *RelayService) handleEventsWebSocket(w http.ResponseWriter, r *http.Request, kinds map[EventKind]bool) {
+	client := m.stream.add()
+	defer m.stream.remove(client)
+
+	ws, err := subscriptionUpgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return // the upgrader responded with the error
+	}
+	defer ws.Close()
+	ws.SetReadLimit(maxSubscriptionMessageSize)
+
+	closed := make(chan struct{})
+	go func() {
+		defer close(closed)
+		for {
+			// handles pongs and the close message of the client
+			if _, _, err := ws.NextReader(); err != nil {
+				return
+			}
+		}
+	}()
+
+	keepAlive := time.NewTicker(eventStreamKeepAlive)
+	defer keepAlive.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-closed:
+			return
+		case <-keepAlive.C:
+			if err := ws.WriteControl(websocket.PingMessage, nil, time.Now().Add(subscriptionWriteTimeout)); err != nil {
+				return
+			}
+		case event := <-client:
+			if len(kinds) > 0 && !kinds[event.Kind] {
+				continue
+			}
+			ws.SetWriteDeadline(time.Now().Add(subscriptionWriteTimeout))
+			if err := ws.WriteJSON(event); err != nil {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,241 @@
+package lib
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+// ExecutionClient is a minimal client of the eth and engine JSON-RPC APIs of an execution client
+type ExecutionClient struct {
+	url    string
+	client *http.Client
+}
+
+// NewExecutionClient creates a client for the execution client at url
+func NewExecutionClient(url string) *ExecutionClient {
+	return &ExecutionClient{url: strings.TrimRight(url, "/"), client: &httpClient}
+}
+
+// NewEngineClient creates a client for the authenticated engine API of the execution client at url, whose requests
+// carry a JWT signed with jwtSecret, the secret the execution client shares with its consensus client
+func NewEngineClient(url string, jwtSecret []byte) *ExecutionClient {
+	return &ExecutionClient{
+		url:    strings.TrimRight(url, "/"),
+		client: &http.Client{Timeout: httpClient.Timeout, Transport: &jwtTransport{secret: jwtSecret}},
+	}
+}
+
+// URL returns the url of the execution client
+func (c *ExecutionClient) URL() string {
+	return c.url
+}
+
+// Forward sends a JSON-RPC request body to the execution client as it is, and returns the response body
+func (c *ExecutionClient) Forward(ctx context.Context, body []byte) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("status %d", resp.StatusCode)
+	}
+	return io.ReadAll(defaultResponseLimit.reader(resp.Body))
+}
+
+// call decodes the result of method into dst
+func (c *ExecutionClient) call(ctx context.Context, method string, params []interface{}, dst interface{}) error {
+	resp, err := makeRequest(ctx, c.client, c.url, method, params, defaultResponseLimit)
+	if err != nil {
+		return err
+	}
+	if resp.Error != nil {
+		return fmt.Errorf("execution client returned error for %s: %d %s", method, resp.Error.Code, resp.Error.Message)
+	}
+	return json.Unmarshal(resp.Result, dst)
+}
+
+// HasBlock reports whether the execution client imported the block with the given hash
+func (c *ExecutionClient) HasBlock(ctx context.Context, blockHash common.Hash) (bool, error) {
+	var block *struct {
+		Hash common.Hash `json:"hash"`
+	}
+	if err := c.call(ctx, "eth_getBlockByHash", []interface{}{blockHash, false}, &block); err != nil {
+		return false, err
+	}
+	return block != nil, nil
+}
+
+// GasLimit returns the gas limit of the block with the given hash
+func (c *ExecutionClient) GasLimit(ctx context.Context, blockHash common.Hash) (uint64, error) {
+	var block *struct {
+		GasLimit hexutil.Uint64 `json:"gasLimit"`
+	}
+	if err := c.call(ctx, "eth_getBlockByHash", []interface{}{blockHash, false}, &block); err != nil {
+		return 0, err
+	}
+	if block == nil {
+		return 0, fmt.Errorf("unknown block %s", blockHash)
+	}
+	return uint64(block.GasLimit), nil
+}
+
+// BalanceAt returns the balance of account in the state after the block with the given hash, see EIP-1898
+func (c *ExecutionClient) BalanceAt(ctx context.Context, account common.Address, blockHash common.Hash) (*big.Int, error) {
+	var balance hexutil.Big
+	block := map[string]interface{}{"blockHash": blockHash, "requireCanonical": false}
+	if err := c.call(ctx, "eth_getBalance", []interface{}{account, block}, &balance); err != nil {
+		return nil, err
+	}
+	return balance.ToInt(), nil
+}
+
+// ChainID returns the chain id of the execution client
+func (c *ExecutionClient) ChainID(ctx context.Context) (*big.Int, error) {
+	var chainID hexutil.Big
+	if err := c.call(ctx, "eth_chainId", []interface{}{}, &chainID); err != nil {
+		return nil, err
+	}
+	return chainID.ToInt(), nil
+}
+
+// ForkchoiceUpdated forwards the params of an engine_forkchoiceUpdatedV1 call, which starts building a payload if
+// they have payload attributes
+func (c *ExecutionClient) ForkchoiceUpdated(ctx context.Context, params []interface{}) (*ForkChoiceResponse, error) {
+	response := new(ForkChoiceResponse)
+	if err := c.call(ctx, methodForkchoiceUpdated, params, response); err != nil {
+		return nil, err
+	}
+	return response, nil
+}
+
+// executionPayloadV1 is an execution payload in the encoding of the engine API
+type executionPayloadV1 struct {
+	ParentHash    common.Hash     `json:"parentHash"`
+	FeeRecipient  common.Address  `json:"feeRecipient"`
+	StateRoot     common.Hash     `json:"stateRoot"`
+	ReceiptsRoot  common.Hash     `json:"receiptsRoot"`
+	LogsBloom     hexutil.Bytes   `json:"logsBloom"`
+	PrevRandao    common.Hash     `json:"prevRandao"`
+	Number        hexutil.Uint64  `json:"blockNumber"`
+	GasLimit      hexutil.Uint64  `json:"gasLimit"`
+	GasUsed       hexutil.Uint64  `json:"gasUsed"`
+	Timestamp     hexutil.Uint64  `json:"timestamp"`
+	ExtraData     hexutil.Bytes   `json:"extraData"`
+	BaseFeePerGas *hexutil.Big    `json:"baseFeePerGas"`
+	BlockHash     common.Hash     `json:"blockHash"`
+	Transactions  []hexutil.Bytes `json:"transactions"`
+	Withdrawals   *[]*Withdrawal  `json:"withdrawals,omitempty"` // from capella on, sent with the V2 methods
+}
+
+// getPayloadV2Response is the result of engine_getPayloadV2, the payload with the block value
+type getPayloadV2Response struct {
+	ExecutionPayload executionPayloadV1 `json:"executionPayload"`
+}
+
+// GetPayload returns the payload built for payloadID with engine_getPayloadV1, or engine_getPayloadV2 with its
+// withdrawals if ctx is marked for capella. The payload has no FeeRecipientDiff, the execution client doesn't know what
+// it pays the fee recipient.
+func (c *ExecutionClient) GetPayload(ctx context.Context, payloadID string) (*ExecutionPayloadWithTxRootV1, error) {
+	var payload executionPayloadV1
+	if isCapella(ctx) {
+		var response getPayloadV2Response
+		if err := c.call(ctx, "engine_getPayloadV2", []interface{}{payloadID}, &response); err != nil {
+			return nil, err
+		}
+		payload = response.ExecutionPayload
+	} else if err := c.call(ctx, "engine_getPayloadV1", []interface{}{payloadID}, &payload); err != nil {
+		return nil, err
+	}
+	transactions := make([]string, len(payload.Transactions))
+	for i, tx := range payload.Transactions {
+		transactions[i] = tx.String()
+	}
+	return &ExecutionPayloadWithTxRootV1{
+		ParentHash:    payload.ParentHash,
+		FeeRecipient:  payload.FeeRecipient,
+		StateRoot:     payload.StateRoot,
+		ReceiptsRoot:  payload.ReceiptsRoot,
+		LogsBloom:     payload.LogsBloom,
+		PrevRandao:    payload.PrevRandao,
+		Number:        uint64(payload.Number),
+		GasLimit:      uint64(payload.GasLimit),
+		GasUsed:       uint64(payload.GasUsed),
+		Timestamp:     uint64(payload.Timestamp),
+		ExtraData:     payload.ExtraData,
+		BaseFeePerGas: payload.BaseFeePerGas.ToInt(),
+		BlockHash:     payload.BlockHash,
+		Transactions:  &transactions,
+		Withdrawals:   payload.Withdrawals,
+	}, nil
+}
+
+// NewPayload submits a payload with engine_newPayloadV1, or engine_newPayloadV2 if it has withdrawals, and returns the
+// status the execution client reports for it
+func (c *ExecutionClient) NewPayload(ctx context.Context, payload *ExecutionPayloadWithTxRootV1) (*PayloadStatus, error) {
+	if payload.Transactions == nil {
+		return nil, errors.New("payload has no transactions")
+	}
+	transactions := make([]hexutil.Bytes, len(*payload.Transactions))
+	for i, tx := range *payload.Transactions {
+		decoded, err := hexutil.Decode(tx)
+		if err != nil {
+			return nil, fmt.Errorf("transaction %d: %w", i, err)
+		}
+		transactions[i] = decoded
+	}
+	method := "engine_newPayloadV1"
+	if payload.Withdrawals != nil {
+		method = "engine_newPayloadV2"
+	}
+	status := new(PayloadStatus)
+	err := c.call(ctx, method, []interface{}{executionPayloadV1{
+		ParentHash:    payload.ParentHash,
+		FeeRecipient:  payload.FeeRecipient,
+		StateRoot:     payload.StateRoot,
+		ReceiptsRoot:  payload.ReceiptsRoot,
+		LogsBloom:     payload.LogsBloom,
+		PrevRandao:    payload.PrevRandao,
+		Number:        hexutil.Uint64(payload.Number),
+		GasLimit:      hexutil.Uint64(payload.GasLimit),
+		GasUsed:       hexutil.Uint64(payload.GasUsed),
+		Timestamp:     hexutil.Uint64(payload.Timestamp),
+		ExtraData:     payload.ExtraData,
+		BaseFeePerGas: (*hexutil.Big)(payload.BaseFeePerGas),
+		BlockHash:     payload.BlockHash,
+		Transactions:  transactions,
+		Withdrawals:   payload.Withdrawals,
+	}}, status)
+	if err != nil {
+		return nil, err
+	}
+	return status, nil
+}
+
+// jwtTransport authenticates requests to the engine API with a JWT issued at the time of the request
+type jwtTransport struct {
+	secret []byte
+}
+
+func (t *jwtTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	req.Header.Set("Authorization", "Bearer "+newJWT(t.secret, time.Now()))
+	return http.DefaultTransport.RoundTrip(req)
+}
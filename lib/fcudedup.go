@@ -0,0 +1,78 @@
+package lib
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var forkchoiceDeduplicatedTotal = metricsFactory.NewCounter(prometheus.CounterOpts{
+	Name: "mevboost_forkchoice_deduplicated_total",
+	Help: "Back-to-back identical engine_forkchoiceUpdatedV1 calls served with the response of the first call",
+})
+
+// forkchoiceDedup serves a forkchoiceUpdated call that repeats the previous one, with the same forkchoice state and
+// payload attributes, with the response of the previous call instead of forwarding it to relays again. Consensus
+// clients retry calls that are slow to answer; a retry of a call that is still being forwarded waits for its response.
+// Unlike the registrationThrottle, this also covers calls without payload attributes, but only back-to-back repeats.
+type forkchoiceDedup struct {
+	window time.Duration // how long a finished call is served to repeats
+
+	mu   sync.Mutex
+	last *forkchoiceCall
+}
+
+// forkchoiceCall is a forkchoiceUpdated call being forwarded or forwarded recently
+type forkchoiceCall struct {
+	params     string
+	done       chan struct{} // closed when the call finished
+	result     ForkChoiceResponse
+	err        error
+	finishedAt time.Time
+}
+
+func newForkchoiceDedup(window time.Duration) *forkchoiceDedup {
+	return &forkchoiceDedup{window: window}
+}
+
+// join returns the previous call if it has the same params and is in flight, or succeeded less than the window ago.
+// Otherwise it returns a new call that the caller forwards and finishes, true if the caller forwards the call.
+func (d *forkchoiceDedup) join(params string) (*forkchoiceCall, bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if last := d.last; last != nil && last.params == params {
+		select {
+		case <-last.done:
+			if last.err == nil && now().Sub(last.finishedAt) < d.window {
+				return last, false
+			}
+		default:
+			return last, false
+		}
+	}
+	d.last = &forkchoiceCall{params: params, done: make(chan struct{})}
+	return d.last, true
+}
+
+func (d *forkchoiceDedup) finish(call *forkchoiceCall, result *ForkChoiceResponse, err error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	call.result, call.err, call.finishedAt = *result, err, now()
+	close(call.done)
+}
+
+// wait returns the response of a call forwarded by another request, or the error of ctx if it's done first
+func (c *forkchoiceCall) wait(ctx context.Context, result *ForkChoiceResponse) error {
+	select {
+	case <-c.done:
+		forkchoiceDeduplicatedTotal.Inc()
+		*result = c.result
+		return c.err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
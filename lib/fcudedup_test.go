@@ -0,0 +1,117 @@
+package lib
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRelayService_ForkchoiceDeduplication(t *testing.T) {
+	defer func() { now = time.Now }()
+	start := time.Unix(1000, 0)
+	now = func() time.Time { return start }
+
+	var relayCalls int32
+	release := make(chan struct{})
+	relay := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&relayCalls, 1)
+		<-release
+		resp, err := formatResponse(ForkChoiceResponse{PayloadStatus: PayloadStatus{Status: ForkchoiceStatusValid}, PayloadID: &hexutil.Bytes{1}})
+		require.Nil(t, err)
+		w.Write(resp)
+	}))
+	defer relay.Close()
+
+	service, err := newRelayService(WithRelayURLs(relay.URL), WithStore(NewStore()), WithLogger(testLog), WithForkchoiceDeduplication(2*time.Second))
+	require.Nil(t, err)
+
+	forkchoiceUpdated := func(head string) string {
+		args := []interface{}{
+			map[string]interface{}{"headBlockHash": common.HexToHash(head).Hex()},
+			map[string]interface{}{"timestamp": "0x10", "suggestedFeeRecipient": "0x0000000000000000000000000000000000000002"},
+		}
+		result := new(ForkChoiceResponse)
+		require.Nil(t, service.ForkchoiceUpdatedV1(nil, &args, result))
+		return result.PayloadID.String()
+	}
+
+	// a retry while the first call is forwarded waits for its response
+	payloadIDs := make([]string, 2)
+	var wg sync.WaitGroup
+	for i := range payloadIDs {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			payloadIDs[i] = forkchoiceUpdated("0x01")
+		}(i)
+	}
+	require.Eventually(t, func() bool { return atomic.LoadInt32(&relayCalls) == 1 }, time.Second, 10*time.Millisecond)
+	close(release)
+	wg.Wait()
+	require.Equal(t, payloadIDs[0], payloadIDs[1])
+	require.Equal(t, int32(1), atomic.LoadInt32(&relayCalls))
+
+	// a repeat within the window gets the same response
+	require.Equal(t, payloadIDs[0], forkchoiceUpdated("0x01"))
+	require.Equal(t, int32(1), atomic.LoadInt32(&relayCalls))
+
+	// other calls and repeats after the window are forwarded
+	require.NotEqual(t, payloadIDs[0], forkchoiceUpdated("0x02"))
+	require.Equal(t, int32(2), atomic.LoadInt32(&relayCalls))
+	forkchoiceUpdated("0x01")
+	require.Equal(t, int32(3), atomic.LoadInt32(&relayCalls), "only back-to-back repeats are deduplicated")
+	now = func() time.Time { return start.Add(2 * time.Second) }
+	forkchoiceUpdated("0x01")
+	require.Equal(t, int32(4), atomic.LoadInt32(&relayCalls))
+}
+
+func TestRelayService_ForkchoiceDeduplication_CallerGone(t *testing.T) {
+	release := make(chan struct{})
+	relay := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+		resp, err := formatResponse(ForkChoiceResponse{PayloadStatus: PayloadStatus{Status: ForkchoiceStatusValid}, PayloadID: &hexutil.Bytes{1}})
+		require.Nil(t, err)
+		w.Write(resp)
+	}))
+	defer relay.Close()
+
+	service, err := newRelayService(WithRelayURLs(relay.URL), WithStore(NewStore()), WithLogger(testLog), WithForkchoiceDeduplication(2*time.Second))
+	require.Nil(t, err)
+	args := []interface{}{
+		map[string]interface{}{"headBlockHash": common.HexToHash("0x01").Hex()},
+		map[string]interface{}{"timestamp": "0x10", "suggestedFeeRecipient": "0x0000000000000000000000000000000000000002"},
+	}
+
+	// the first caller disconnects while its call is forwarded
+	ctx, cancel := context.WithCancel(context.Background())
+	req, _ := http.NewRequestWithContext(ctx, http.MethodPost, "/", nil)
+	firstDone := make(chan struct{})
+	go func() {
+		defer close(firstDone)
+		service.ForkchoiceUpdatedV1(req, &args, new(ForkChoiceResponse))
+	}()
+	require.Eventually(t, func() bool {
+		service.fcuDedup.mu.Lock()
+		defer service.fcuDedup.mu.Unlock()
+		return service.fcuDedup.last != nil
+	}, time.Second, time.Millisecond)
+
+	// its retry still gets the response of the shared call
+	result := new(ForkChoiceResponse)
+	retryDone := make(chan error, 1)
+	go func() { retryDone <- service.ForkchoiceUpdatedV1(nil, &args, result) }()
+	cancel()
+	time.Sleep(10 * time.Millisecond)
+	close(release)
+	require.Nil(t, <-retryDone)
+	require.NotNil(t, result.PayloadID)
+	<-firstDone
+}
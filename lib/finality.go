@@ -0,0 +1,40 @@
+package lib
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// evictFinalized removes store entries of slots before the finalized checkpoint, they can't be proposed anymore
+func (m *RelayService) evictFinalized(ctx context.Context, beacon *BeaconClient, evicter Evicter) error {
+	checkpoints, err := beacon.FinalityCheckpoints(ctx)
+	if err != nil {
+		return err
+	}
+	epoch, err := strconv.ParseUint(checkpoints.Finalized.Epoch, 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid finalized epoch %q: %w", checkpoints.Finalized.Epoch, err)
+	}
+
+	slot := epoch * m.chain.SlotsPerEpoch
+	evicter.EvictBefore(ctx, uint64(m.chain.SlotStartTime(slot).Unix()))
+	m.log.WithFields(Fields{"epoch": epoch, "slot": slot}).Debug("evicted store entries before finalized checkpoint")
+	return nil
+}
+
+// startFinalizedEviction evicts finalized store entries once per epoch until ctx is done, if the store is an Evicter
+func (m *RelayService) startFinalizedEviction(ctx context.Context, beacon *BeaconClient) {
+	evicter, ok := m.store.(Evicter)
+	if !ok {
+		m.log.Warn("the store can't evict finalized entries, they're kept until they expire")
+		return
+	}
+	interval := m.chain.SlotDuration() * time.Duration(m.chain.SlotsPerEpoch)
+	runLoop(ctx, m.log, "finalized_eviction", interval, false, func(ctx context.Context) {
+		if err := m.evictFinalized(ctx, beacon, evicter); err != nil {
+			m.log.WithError(err).Warn("could not evict finalized store entries")
+		}
+	})
+}
@@ -0,0 +1,37 @@
+package lib
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRelayService_evictFinalized(t *testing.T) {
+	beaconNode := newMockBeaconNode(t, map[string]string{
+		"/eth/v1/beacon/states/head/finality_checkpoints": `{"data":{"previous_justified":{"epoch":"2","root":"0x01"},"current_justified":{"epoch":"3","root":"0x02"},"finalized":{"epoch":"2","root":"0x01"}}}`,
+	})
+	defer beaconNode.Close()
+
+	ctx := context.Background()
+	chain := &ChainConfig{GenesisTime: 0, SecondsPerSlot: 6, SlotsPerEpoch: 8} // epoch 2 starts at 96
+	store := NewStore()
+	store.SetExecutionPayload(ctx, common.HexToHash("0x01"), &ExecutionPayloadWithTxRootV1{Timestamp: 90})
+	store.SetExecutionPayload(ctx, common.HexToHash("0x02"), &ExecutionPayloadWithTxRootV1{Timestamp: 96})
+	store.SetPayloadAttributes(ctx, "0x01", &PayloadAttributesV1{Timestamp: 90})
+	store.SetPayloadAttributes(ctx, "0x02", &PayloadAttributesV1{Timestamp: 102})
+	store.SetBid(ctx, common.HexToHash("0x02"), &Bid{RelayURL: "http://relay"})
+
+	service, err := newRelayService(WithRelayURLs("http://relay"), WithStore(store), WithLogger(testLog), WithChainConfig(chain))
+	require.Nil(t, err)
+	require.Nil(t, service.evictFinalized(ctx, NewBeaconClient(beaconNode.URL), store.(Evicter)))
+
+	require.Nil(t, store.GetExecutionPayload(ctx, common.HexToHash("0x01")))
+	require.NotNil(t, store.GetExecutionPayload(ctx, common.HexToHash("0x02")))
+	require.Nil(t, store.GetPayloadAttributes(ctx, "0x01"))
+	require.NotNil(t, store.GetPayloadAttributes(ctx, "0x02"))
+	require.NotNil(t, store.GetBid(ctx, common.HexToHash("0x02")), "bids of the current slot are kept")
+
+	require.Error(t, service.evictFinalized(ctx, NewBeaconClient("http://127.0.0.1:1"), store.(Evicter)))
+}
@@ -0,0 +1,91 @@
+package lib
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// The fuzz targets run their seeds with go test, and fuzz with e.g. go test -run '^$' -fuzz FuzzRouter_RPCRequest ./lib
+
+func FuzzRouter_RPCRequest(f *testing.F) {
+	for _, seed := range []string{
+		`{"jsonrpc":"2.0","id":1,"method":"engine_forkchoiceUpdatedV1","params":[{"headBlockHash":"0x0000000000000000000000000000000000000000000000000000000000000001"},{"timestamp":"0x10","suggestedFeeRecipient":"0x0000000000000000000000000000000000000002"}]}`,
+		`{"jsonrpc":"2.0","id":1,"method":"builder_getPayloadHeaderV1","params":["0x01"]}`,
+		`{"jsonrpc":"2.0","id":1,"method":"builder_proposeBlindedBlockV1","params":[{"message":{"slot":"1","body":{}}}]}`,
+		`{"jsonrpc":"2.0","id":1,"method":"builder_proposeBlindedBlockV1","params":[{"message":{"slot":"1","body":{"execution_payload_header":{}}}}]}`,
+		`{"jsonrpc":"2.0","id":"a","method":"relay_getCapabilitiesV1","params":[]}`,
+		`[{"jsonrpc":"2.0","id":1,"method":"builder_getPayloadHeaderV1","params":["0x01"]},1,null]`,
+		`{"id":{},"method":"engine_exchangeTransitionConfigurationV1","params":[{}]}`,
+		`{"method":"builder_registerValidatorV1","params":[[{"message":null}]]}`,
+		`[]`,
+		`null`,
+	} {
+		f.Add(seed)
+	}
+	relay := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		resp, _ := formatErrorResponse("no bid")
+		w.Write(resp)
+	}))
+	defer relay.Close()
+	router, err := NewRouter(context.Background(), WithRelayURLs(relay.URL), WithStore(NewStore()), WithLogger(NewStdLogger(log.New(io.Discard, "", 0))), WithCapabilityCheckInterval(0))
+	require.Nil(f, err)
+
+	f.Fuzz(func(t *testing.T, body string) {
+		req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		if rr.Body.Len() > 0 { // notifications, calls without id, get no response
+			require.True(t, json.Valid(rr.Body.Bytes()), "JSON-RPC calls are answered with JSON: %s", rr.Body.String())
+		}
+	})
+}
+
+func FuzzParseRPCResponse(f *testing.F) {
+	for _, seed := range []string{
+		`{"jsonrpc":"2.0","id":"1","result":{"blockHash":"0x01"}}`,
+		`{"jsonrpc":"2.0","id":"1","error":{"code":-32001,"message":"no bids"}}`,
+		`{"id":1,"result":null,"error":null}`,
+		`[]`,
+	} {
+		f.Add([]byte(seed))
+	}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		resp, err := parseRPCResponse(data)
+		if err != nil {
+			return
+		}
+		var payload ExecutionPayloadWithTxRootV1
+		if json.Unmarshal(resp.Result, &payload) == nil && payload.Transactions != nil {
+			decodeTransactions(*payload.Transactions)
+		}
+	})
+}
+
+func FuzzBlindedBeaconBlockBody(f *testing.F) {
+	for _, seed := range []string{
+		`{"randao_reveal":"0x","eth1_data":{},"graffiti":"0x","execution_payload_header":{}}`,
+		`{"execution_payload_header":{"withdrawals_root":"0x0000000000000000000000000000000000000000000000000000000000000001"},"bls_to_execution_changes":[{}]}`,
+		`{"proposer_slashings":[null],"attester_slashings":[{}],"attestations":[{"aggregation_bits":"0x"}],"deposits":[{"proof":[]}],"voluntary_exits":[{}]}`,
+		`{"sync_aggregate":{"sync_committee_bits":"0x"}}`,
+	} {
+		f.Add([]byte(seed))
+	}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		body := new(BlindedBeaconBlockBody)
+		if err := json.Unmarshal(data, body); err != nil {
+			return
+		}
+		body.HashTreeRoot() // errors are fine, panics aren't
+		block := &BlindedBeaconBlock{Body: body}
+		block.HashTreeRoot()
+	})
+}
@@ -32,6 +32,8 @@ func (e ExecutionPayloadWithTxRootV1) MarshalJSON() ([]byte, error) {
 		Transactions     *[]string      `json:"transactions,omitempty"`
 		TransactionsRoot common.Hash    `json:"transactionsRoot"`
 		FeeRecipientDiff *big.Int       `json:"feeRecipientDiff" gencodec:"required"`
+		Withdrawals      *[]*Withdrawal `json:"withdrawals,omitempty"`
+		WithdrawalsRoot  *common.Hash   `json:"withdrawalsRoot,omitempty"`
 	}
 	var enc ExecutionPayloadWithTxRootV1
 	enc.ParentHash = e.ParentHash
@@ -50,6 +52,8 @@ func (e ExecutionPayloadWithTxRootV1) MarshalJSON() ([]byte, error) {
 	enc.Transactions = e.Transactions
 	enc.TransactionsRoot = e.TransactionsRoot
 	enc.FeeRecipientDiff = e.FeeRecipientDiff
+	enc.Withdrawals = e.Withdrawals
+	enc.WithdrawalsRoot = e.WithdrawalsRoot
 	return json.Marshal(&enc)
 }
 
@@ -72,6 +76,8 @@ func (e *ExecutionPayloadWithTxRootV1) UnmarshalJSON(input []byte) error {
 		Transactions     *[]string       `json:"transactions,omitempty"`
 		TransactionsRoot *common.Hash    `json:"transactionsRoot"`
 		FeeRecipientDiff *big.Int        `json:"feeRecipientDiff" gencodec:"required"`
+		Withdrawals      *[]*Withdrawal  `json:"withdrawals,omitempty"`
+		WithdrawalsRoot  *common.Hash    `json:"withdrawalsRoot,omitempty"`
 	}
 	var dec ExecutionPayloadWithTxRootV1
 	if err := json.Unmarshal(input, &dec); err != nil {
@@ -139,5 +145,11 @@ func (e *ExecutionPayloadWithTxRootV1) UnmarshalJSON(input []byte) error {
 		return errors.New("missing required field 'feeRecipientDiff' for ExecutionPayloadWithTxRootV1")
 	}
 	e.FeeRecipientDiff = dec.FeeRecipientDiff
+	if dec.Withdrawals != nil {
+		e.Withdrawals = dec.Withdrawals
+	}
+	if dec.WithdrawalsRoot != nil {
+		e.WithdrawalsRoot = dec.WithdrawalsRoot
+	}
 	return nil
 }
@@ -0,0 +1,269 @@
+//go:build !minimal
+
+package lib
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+	graphql "github.com/graph-gophers/graphql-go"
+)
+
+// graphqlSchema is the read-only query API over delivered payloads and archived bids
+const graphqlSchema = `
+schema {
+	query: Query
+}
+
+# Long is an unsigned 64-bit integer as decimal string, also accepted as number
+scalar Long
+# BigInt is an arbitrary precision integer as decimal string, e.g. a value in wei
+scalar BigInt
+
+type Query {
+	# payloads revealed to the consensus client, most recent first
+	deliveries(fromSlot: Long, toSlot: Long, relay: String, proposerIndex: Long, feeRecipient: String, minValue: BigInt, limit: Int = 100): [Delivery!]!
+	# bids received from relays, most recent first
+	bids(fromSlot: Long, toSlot: Long, relay: String, minValue: BigInt, result: String, limit: Int = 100): [Bid!]!
+}
+
+type Delivery {
+	slot: Long!
+	proposerIndex: Long!
+	blockHash: String!
+	blockNumber: Long!
+	parentHash: String!
+	relay: String!
+	feeRecipient: String!
+	value: BigInt
+	deliveredAt: Time!
+	reconciled: Boolean!
+	# status of the check against the finalized chain, null until it was checked
+	verification: String
+}
+
+type Bid {
+	slot: Long!
+	relay: String!
+	blockHash: String!
+	value: BigInt
+	receivedAt: Time!
+	result: String!
+	error: String
+}
+
+scalar Time
+`
+
+// maxGraphQLResults bounds the limit argument of queries
+const maxGraphQLResults = 10000
+
+// graphqlLong is the Long scalar
+type graphqlLong uint64
+
+func (graphqlLong) ImplementsGraphQLType(name string) bool { return name == "Long" }
+
+func (l *graphqlLong) UnmarshalGraphQL(input interface{}) error {
+	switch input := input.(type) {
+	case int32:
+		if input < 0 {
+			return fmt.Errorf("negative Long %d", input)
+		}
+		*l = graphqlLong(input)
+	case float64:
+		if input < 0 || input != float64(uint64(input)) {
+			return fmt.Errorf("invalid Long %v", input)
+		}
+		*l = graphqlLong(input)
+	case string:
+		value, err := strconv.ParseUint(input, 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid Long %q", input)
+		}
+		*l = graphqlLong(value)
+	default:
+		return fmt.Errorf("unexpected type %T for Long", input)
+	}
+	return nil
+}
+
+func (l graphqlLong) MarshalJSON() ([]byte, error) {
+	return json.Marshal(strconv.FormatUint(uint64(l), 10))
+}
+
+// graphqlBigInt is the BigInt scalar
+type graphqlBigInt struct {
+	*big.Int
+}
+
+func (graphqlBigInt) ImplementsGraphQLType(name string) bool { return name == "BigInt" }
+
+func (b *graphqlBigInt) UnmarshalGraphQL(input interface{}) error {
+	switch input := input.(type) {
+	case int32:
+		b.Int = big.NewInt(int64(input))
+	case string:
+		value, ok := new(big.Int).SetString(input, 10)
+		if !ok {
+			return fmt.Errorf("invalid BigInt %q", input)
+		}
+		b.Int = value
+	default:
+		return fmt.Errorf("unexpected type %T for BigInt, use a decimal string", input)
+	}
+	return nil
+}
+
+func (b graphqlBigInt) MarshalJSON() ([]byte, error) {
+	return json.Marshal(b.Int.String())
+}
+
+func newGraphQLBigInt(value *big.Int) *graphqlBigInt {
+	if value == nil {
+		return nil
+	}
+	return &graphqlBigInt{value}
+}
+
+// graphqlDelivery and graphqlBid are the results of queries, resolved by their fields
+type graphqlDelivery struct {
+	Slot          graphqlLong
+	ProposerIndex graphqlLong
+	BlockHash     string
+	BlockNumber   graphqlLong
+	ParentHash    string
+	Relay         string
+	FeeRecipient  string
+	Value         *graphqlBigInt
+	DeliveredAt   graphql.Time
+	Reconciled    bool
+	Verification  *string
+}
+
+type graphqlBid struct {
+	Slot       graphqlLong
+	Relay      string
+	BlockHash  string
+	Value      *graphqlBigInt
+	ReceivedAt graphql.Time
+	Result     string
+	Error      *string
+}
+
+// graphqlFilter are the arguments common to all queries
+type graphqlFilter struct {
+	FromSlot *graphqlLong
+	ToSlot   *graphqlLong
+	Relay    *string
+	MinValue *graphqlBigInt
+	Limit    int32
+}
+
+func (f *graphqlFilter) matches(slot uint64, relayURL string, value *big.Int) bool {
+	switch {
+	case f.FromSlot != nil && slot < uint64(*f.FromSlot),
+		f.ToSlot != nil && slot > uint64(*f.ToSlot),
+		f.Relay != nil && !strings.Contains(relayURL, *f.Relay),
+		f.MinValue != nil && (value == nil || value.Cmp(f.MinValue.Int) < 0):
+		return false
+	}
+	return true
+}
+
+func (f *graphqlFilter) limit() int {
+	if f.Limit <= 0 || f.Limit > maxGraphQLResults {
+		return maxGraphQLResults
+	}
+	return int(f.Limit)
+}
+
+// graphqlResolver resolves the queries of graphqlSchema
+type graphqlResolver struct {
+	service *RelayService
+}
+
+func (r *graphqlResolver) Deliveries(args struct {
+	graphqlFilter
+	ProposerIndex *graphqlLong
+	FeeRecipient  *string
+}) []*graphqlDelivery {
+	deliveries := r.service.deliveries.all()
+	results := []*graphqlDelivery{}
+	for i := len(deliveries) - 1; i >= 0 && len(results) < args.limit(); i-- {
+		delivery := deliveries[i]
+		if !args.matches(delivery.Slot, delivery.RelayURL, delivery.Value) ||
+			args.ProposerIndex != nil && delivery.ProposerIndex != uint64(*args.ProposerIndex) ||
+			args.FeeRecipient != nil && delivery.FeeRecipient != common.HexToAddress(*args.FeeRecipient) {
+			continue
+		}
+		var verification *string
+		if delivery.Verification != nil {
+			verification = &delivery.Verification.Status
+		}
+		results = append(results, &graphqlDelivery{
+			Slot:          graphqlLong(delivery.Slot),
+			ProposerIndex: graphqlLong(delivery.ProposerIndex),
+			BlockHash:     delivery.BlockHash.Hex(),
+			BlockNumber:   graphqlLong(delivery.BlockNumber),
+			ParentHash:    delivery.ParentHash.Hex(),
+			Relay:         delivery.RelayURL,
+			FeeRecipient:  delivery.FeeRecipient.Hex(),
+			Value:         newGraphQLBigInt(delivery.Value),
+			DeliveredAt:   graphql.Time{Time: delivery.DeliveredAt},
+			Reconciled:    delivery.Reconciled,
+			Verification:  verification,
+		})
+	}
+	return results
+}
+
+func (r *graphqlResolver) Bids(args struct {
+	graphqlFilter
+	Result *string
+}) []*graphqlBid {
+	bids := r.service.bids.all()
+	results := []*graphqlBid{}
+	for i := len(bids) - 1; i >= 0 && len(results) < args.limit(); i-- {
+		bid := bids[i]
+		if !args.matches(bid.Slot, bid.RelayURL, bid.Value) || args.Result != nil && bid.Result != *args.Result {
+			continue
+		}
+		result := &graphqlBid{
+			Slot:       graphqlLong(bid.Slot),
+			Relay:      bid.RelayURL,
+			BlockHash:  bid.BlockHash.Hex(),
+			Value:      newGraphQLBigInt(bid.Value),
+			ReceivedAt: graphql.Time{Time: bid.ReceivedAt},
+			Result:     bid.Result,
+		}
+		if bid.Error != "" {
+			result.Error = &bid.Error
+		}
+		results = append(results, result)
+	}
+	return results
+}
+
+// newGraphQLHandler serves graphqlSchema over POST requests with a JSON body of query, operationName and variables
+func newGraphQLHandler(service *RelayService) http.Handler {
+	schema := graphql.MustParseSchema(graphqlSchema, &graphqlResolver{service},
+		graphql.UseFieldResolvers(), graphql.MaxDepth(4), graphql.MaxParallelism(4))
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var params struct {
+			Query         string                 `json:"query"`
+			OperationName string                 `json:"operationName"`
+			Variables     map[string]interface{} `json:"variables"`
+		}
+		if err := json.NewDecoder(io.LimitReader(r.Body, 1<<20)).Decode(&params); err != nil {
+			respondJSON(w, http.StatusBadRequest, keymanagerError{"invalid request: " + err.Error()})
+			return
+		}
+		respondJSON(w, http.StatusOK, schema.Exec(r.Context(), params.Query, params.OperationName, params.Variables))
+	})
+}
@@ -0,0 +1,12 @@
+//go:build minimal
+
+package lib
+
+import (
+	"net/http"
+)
+
+// newGraphQLHandler is never served in minimal builds, NewRouter rejects WithGraphQL
+func newGraphQLHandler(*RelayService) http.Handler {
+	return http.NotFoundHandler()
+}
@@ -0,0 +1,84 @@
+//go:build !minimal
+
+package lib
+
+import (
+	"bytes"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGraphQL(t *testing.T) {
+	service, err := newRelayService(WithRelayURLs("http://a.example", "http://b.example"), WithStore(NewStore()), WithLogger(testLog))
+	require.Nil(t, err)
+	deliveredAt := time.Date(2022, 6, 1, 12, 0, 0, 0, time.UTC)
+	for slot := uint64(1); slot <= 4; slot++ {
+		relayURL := "http://a.example"
+		if slot%2 == 0 {
+			relayURL = "http://b.example"
+		}
+		service.deliveries.add(&DeliveredPayload{
+			Slot:          slot,
+			ProposerIndex: 100 + slot%2,
+			BlockHash:     common.BigToHash(new(big.Int).SetUint64(slot)),
+			RelayURL:      relayURL,
+			FeeRecipient:  common.HexToAddress("0x01"),
+			Value:         new(big.Int).Mul(big.NewInt(int64(slot)), big.NewInt(1e18)),
+			DeliveredAt:   deliveredAt,
+		})
+		service.bids.add(&ArchivedBid{Slot: slot, RelayURL: relayURL, Value: big.NewInt(int64(slot)), Result: BidResultWon})
+		service.bids.add(&ArchivedBid{Slot: slot, RelayURL: relayURL, Result: BidResultInvalid, Error: "bad header"})
+	}
+	handler := newGraphQLHandler(service)
+	query := func(query string, variables map[string]interface{}) map[string]interface{} {
+		body, err := json.Marshal(map[string]interface{}{"query": query, "variables": variables})
+		require.Nil(t, err)
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/mev-boost/v1/graphql", bytes.NewReader(body)))
+		require.Equal(t, http.StatusOK, rr.Code)
+		var resp map[string]interface{}
+		require.Nil(t, json.Unmarshal(rr.Body.Bytes(), &resp))
+		return resp
+	}
+
+	t.Run("deliveries by slot range and value", func(t *testing.T) {
+		resp := query(`{ deliveries(fromSlot: 2, toSlot: 4, minValue: "3000000000000000000") { slot relay value deliveredAt } }`, nil)
+		require.Nil(t, resp["errors"])
+		require.Equal(t, map[string]interface{}{"deliveries": []interface{}{
+			map[string]interface{}{"slot": "4", "relay": "http://b.example", "value": "4000000000000000000", "deliveredAt": "2022-06-01T12:00:00Z"},
+			map[string]interface{}{"slot": "3", "relay": "http://a.example", "value": "3000000000000000000", "deliveredAt": "2022-06-01T12:00:00Z"},
+		}}, resp["data"])
+	})
+
+	t.Run("deliveries by validator with variables", func(t *testing.T) {
+		resp := query(`query($proposer: Long, $limit: Int) { deliveries(proposerIndex: $proposer, limit: $limit) { slot proposerIndex } }`,
+			map[string]interface{}{"proposer": "101", "limit": 1})
+		require.Nil(t, resp["errors"])
+		require.Equal(t, map[string]interface{}{"deliveries": []interface{}{
+			map[string]interface{}{"slot": "3", "proposerIndex": "101"},
+		}}, resp["data"])
+	})
+
+	t.Run("bids by relay and result", func(t *testing.T) {
+		resp := query(`{ bids(relay: "b.example", result: "invalid") { slot result error value } }`, nil)
+		require.Nil(t, resp["errors"])
+		require.Equal(t, map[string]interface{}{"bids": []interface{}{
+			map[string]interface{}{"slot": "4", "result": BidResultInvalid, "error": "bad header", "value": nil},
+			map[string]interface{}{"slot": "2", "result": BidResultInvalid, "error": "bad header", "value": nil},
+		}}, resp["data"])
+	})
+
+	t.Run("invalid queries", func(t *testing.T) {
+		resp := query(`{ deliveries(fromSlot: -1) { slot } }`, nil)
+		require.NotNil(t, resp["errors"])
+		resp = query(`{ deliveries { unknown } }`, nil)
+		require.NotNil(t, resp["errors"])
+	})
+}
@@ -0,0 +1,316 @@
+package lib
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/flashbots/mev-boost/lib/builderpb"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// grpcCodes are the gRPC status codes of the errors returned by the RelayService methods
+var grpcCodes = map[error]codes.Code{
+	ErrNoBids:             codes.NotFound,
+	ErrRelayTimeout:       codes.DeadlineExceeded,
+	ErrValidationFailed:   codes.FailedPrecondition,
+	ErrHeaderMismatch:     codes.FailedPrecondition,
+	ErrInvalidSignature:   codes.InvalidArgument,
+	ErrUnknownPayload:     codes.NotFound,
+	ErrStalePayloadID:     codes.FailedPrecondition,
+	ErrChainStateMismatch: codes.FailedPrecondition,
+	ErrBuildLocally:       codes.NotFound,
+	ErrEquivocation:       codes.FailedPrecondition,
+}
+
+// builderServer serves the builder API over gRPC with the same RelayService methods as the JSON-RPC endpoint
+type builderServer struct {
+	builderpb.UnimplementedBuilderServer
+	service *RelayService
+}
+
+// contextRequest is the request the RelayService methods get for calls that don't come from the JSON-RPC endpoint, only
+// its context is used
+func contextRequest(ctx context.Context) *http.Request {
+	return new(http.Request).WithContext(ctx)
+}
+
+// grpcError converts an error of a RelayService method to a gRPC status
+func grpcError(err error) error {
+	for kind, code := range grpcCodes {
+		if errors.Is(err, kind) {
+			return status.Error(code, err.Error())
+		}
+	}
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return status.FromContextError(err).Err()
+	}
+	return status.Error(codes.Unknown, err.Error())
+}
+
+// Register implements builderpb.BuilderServer
+func (s *builderServer) Register(ctx context.Context, req *builderpb.RegisterRequest) (*builderpb.RegisterResponse, error) {
+	var d pbDecoder
+	state := req.GetForkchoiceState()
+	args := []interface{}{ForkchoiceStateV1{
+		HeadBlockHash:      d.hash("head_block_hash", state.GetHeadBlockHash()),
+		SafeBlockHash:      d.hash("safe_block_hash", state.GetSafeBlockHash()),
+		FinalizedBlockHash: d.hash("finalized_block_hash", state.GetFinalizedBlockHash()),
+	}, nil}
+	if attributes := req.GetPayloadAttributes(); attributes != nil {
+		args[1] = &PayloadAttributesV1{
+			Timestamp:             hexutil.Uint64(attributes.Timestamp),
+			PrevRandao:            d.hash("prev_randao", attributes.PrevRandao),
+			SuggestedFeeRecipient: d.address("suggested_fee_recipient", attributes.SuggestedFeeRecipient),
+		}
+	}
+	if d.err != nil {
+		return nil, status.Error(codes.InvalidArgument, d.err.Error())
+	}
+
+	result := new(ForkChoiceResponse)
+	if err := s.service.ForkchoiceUpdatedV1(contextRequest(ctx), &args, result); err != nil {
+		return nil, grpcError(err)
+	}
+	return &builderpb.RegisterResponse{PayloadId: *result.PayloadID}, nil
+}
+
+// GetHeader implements builderpb.BuilderServer
+func (s *builderServer) GetHeader(ctx context.Context, req *builderpb.GetHeaderRequest) (*builderpb.ExecutionPayloadHeader, error) {
+	payloadID := hexutil.Encode(req.PayloadId)
+	result := new(ExecutionPayloadWithTxRootV1)
+	if err := s.service.GetPayloadHeaderV1(contextRequest(ctx), &payloadID, result); err != nil {
+		return nil, grpcError(err)
+	}
+	return pbHeader(result), nil
+}
+
+// SubmitBlindedBlock implements builderpb.BuilderServer
+func (s *builderServer) SubmitBlindedBlock(ctx context.Context, req *builderpb.SignedBlindedBeaconBlock) (*builderpb.ExecutionPayload, error) {
+	var d pbDecoder
+	block := d.signedBlindedBlock(req)
+	if d.err != nil {
+		return nil, status.Error(codes.InvalidArgument, d.err.Error())
+	}
+
+	result := new(ExecutionPayloadWithTxRootV1)
+	if err := s.service.ProposeBlindedBlockV1(contextRequest(ctx), block, result); err != nil {
+		return nil, grpcError(err)
+	}
+	payload := &builderpb.ExecutionPayload{Header: pbHeader(result)}
+	if result.Transactions != nil {
+		for _, tx := range *result.Transactions {
+			decoded, err := hexutil.Decode(tx)
+			if err != nil {
+				return nil, status.Errorf(codes.Internal, "invalid transaction in payload: %v", err)
+			}
+			payload.Transactions = append(payload.Transactions, decoded)
+		}
+	}
+	return payload, nil
+}
+
+// Status implements builderpb.BuilderServer
+func (s *builderServer) Status(ctx context.Context, _ *builderpb.StatusRequest) (*builderpb.StatusResponse, error) {
+	resp := new(builderpb.StatusResponse)
+	for _, url := range s.service.relays.all() {
+		resp.Relays = append(resp.Relays, &builderpb.RelayStatus{Url: url, Suspended: s.service.blacklist.isSuspended(url)})
+	}
+	sizes := s.service.store.Sizes(ctx)
+	resp.Store = &builderpb.StoreStatus{
+		Payloads:            uint64(sizes.Payloads),
+		ForkchoiceResponses: uint64(sizes.ForkchoiceResponses),
+		Bids:                uint64(sizes.Bids),
+		Registrations:       uint64(sizes.Registrations),
+	}
+	return resp, nil
+}
+
+// pbHeader converts a payload or header returned by relays to its protobuf message
+func pbHeader(p *ExecutionPayloadWithTxRootV1) *builderpb.ExecutionPayloadHeader {
+	header := &builderpb.ExecutionPayloadHeader{
+		ParentHash:       p.ParentHash.Bytes(),
+		FeeRecipient:     p.FeeRecipient.Bytes(),
+		StateRoot:        p.StateRoot.Bytes(),
+		ReceiptsRoot:     p.ReceiptsRoot.Bytes(),
+		LogsBloom:        p.LogsBloom,
+		PrevRandao:       p.PrevRandao.Bytes(),
+		BlockNumber:      p.Number,
+		GasLimit:         p.GasLimit,
+		GasUsed:          p.GasUsed,
+		Timestamp:        p.Timestamp,
+		ExtraData:        p.ExtraData,
+		BlockHash:        p.BlockHash.Bytes(),
+		TransactionsRoot: p.TransactionsRoot.Bytes(),
+	}
+	if p.BaseFeePerGas != nil {
+		header.BaseFeePerGas = p.BaseFeePerGas.Bytes()
+	}
+	if p.FeeRecipientDiff != nil {
+		header.Value = p.FeeRecipientDiff.Bytes()
+	}
+	return header
+}
+
+// pbDecoder converts protobuf messages to the types of the JSON-RPC API, keeping the first invalid field
+type pbDecoder struct {
+	err error
+}
+
+func (d *pbDecoder) fixed(name string, dst, src []byte) {
+	if len(src) != len(dst) && d.err == nil {
+		d.err = fmt.Errorf("%s: expected %d bytes, got %d", name, len(dst), len(src))
+	}
+	copy(dst, src)
+}
+
+func (d *pbDecoder) hash(name string, src []byte) (hash common.Hash) {
+	d.fixed(name, hash[:], src)
+	return hash
+}
+
+func (d *pbDecoder) address(name string, src []byte) (address common.Address) {
+	d.fixed(name, address[:], src)
+	return address
+}
+
+func (d *pbDecoder) pubkey(name string, src []byte) (pubkey BLSPubkey) {
+	d.fixed(name, pubkey[:], src)
+	return pubkey
+}
+
+func (d *pbDecoder) signature(name string, src []byte) (signature BLSSignature) {
+	d.fixed(name, signature[:], src)
+	return signature
+}
+
+func (d *pbDecoder) signedBlindedBlock(b *builderpb.SignedBlindedBeaconBlock) *SignedBlindedBeaconBlock {
+	block := &SignedBlindedBeaconBlock{Signature: d.signature("signature", b.Signature)}
+	if message := b.Message; message != nil {
+		block.Message = &BlindedBeaconBlock{
+			Slot:          message.Slot,
+			ProposerIndex: message.ProposerIndex,
+			ParentRoot:    d.hash("parent_root", message.ParentRoot),
+			StateRoot:     d.hash("state_root", message.StateRoot),
+			Body:          d.body(message.Body),
+		}
+	}
+	return block
+}
+
+func (d *pbDecoder) body(b *builderpb.BlindedBeaconBlockBody) *BlindedBeaconBlockBody {
+	if b == nil {
+		return nil
+	}
+	body := &BlindedBeaconBlockBody{
+		RandaoReveal: d.signature("randao_reveal", b.RandaoReveal),
+		Eth1Data: Eth1Data{
+			DepositRoot:  d.hash("eth1_data.deposit_root", b.GetEth1Data().GetDepositRoot()),
+			DepositCount: b.GetEth1Data().GetDepositCount(),
+			BlockHash:    d.hash("eth1_data.block_hash", b.GetEth1Data().GetBlockHash()),
+		},
+		Graffiti: d.hash("graffiti", b.Graffiti),
+		SyncAggregate: SyncAggregate{
+			SyncCommitteeBits:      b.GetSyncAggregate().GetSyncCommitteeBits(),
+			SyncCommitteeSignature: d.signature("sync_aggregate.sync_committee_signature", b.GetSyncAggregate().GetSyncCommitteeSignature()),
+		},
+		ExecutionPayloadHeader: d.header(b.ExecutionPayloadHeader),
+	}
+	for _, slashing := range b.ProposerSlashings {
+		body.ProposerSlashings = append(body.ProposerSlashings, ProposerSlashing{
+			SignedHeader1: d.signedBeaconBlockHeader(slashing.GetSignedHeader_1()),
+			SignedHeader2: d.signedBeaconBlockHeader(slashing.GetSignedHeader_2()),
+		})
+	}
+	for _, slashing := range b.AttesterSlashings {
+		body.AttesterSlashings = append(body.AttesterSlashings, AttesterSlashing{
+			Attestation1: d.indexedAttestation(slashing.GetAttestation_1()),
+			Attestation2: d.indexedAttestation(slashing.GetAttestation_2()),
+		})
+	}
+	for _, attestation := range b.Attestations {
+		body.Attestations = append(body.Attestations, Attestation{
+			AggregationBits: attestation.AggregationBits,
+			Data:            d.attestationData(attestation.Data),
+			Signature:       d.signature("attestation.signature", attestation.Signature),
+		})
+	}
+	for _, deposit := range b.Deposits {
+		decoded := Deposit{Data: DepositData{
+			Pubkey:                d.pubkey("deposit.data.pubkey", deposit.GetData().GetPubkey()),
+			WithdrawalCredentials: d.hash("deposit.data.withdrawal_credentials", deposit.GetData().GetWithdrawalCredentials()),
+			Amount:                deposit.GetData().GetAmount(),
+			Signature:             d.signature("deposit.data.signature", deposit.GetData().GetSignature()),
+		}}
+		for _, node := range deposit.Proof {
+			decoded.Proof = append(decoded.Proof, d.hash("deposit.proof", node))
+		}
+		body.Deposits = append(body.Deposits, decoded)
+	}
+	for _, exit := range b.VoluntaryExits {
+		body.VoluntaryExits = append(body.VoluntaryExits, SignedVoluntaryExit{
+			Message:   VoluntaryExit{Epoch: exit.GetMessage().GetEpoch(), ValidatorIndex: exit.GetMessage().GetValidatorIndex()},
+			Signature: d.signature("voluntary_exit.signature", exit.Signature),
+		})
+	}
+	return body
+}
+
+func (d *pbDecoder) signedBeaconBlockHeader(h *builderpb.SignedBeaconBlockHeader) SignedBeaconBlockHeader {
+	return SignedBeaconBlockHeader{
+		Message: BeaconBlockHeader{
+			Slot:          h.GetMessage().GetSlot(),
+			ProposerIndex: h.GetMessage().GetProposerIndex(),
+			ParentRoot:    d.hash("proposer_slashing.parent_root", h.GetMessage().GetParentRoot()),
+			StateRoot:     d.hash("proposer_slashing.state_root", h.GetMessage().GetStateRoot()),
+			BodyRoot:      d.hash("proposer_slashing.body_root", h.GetMessage().GetBodyRoot()),
+		},
+		Signature: d.signature("proposer_slashing.signature", h.GetSignature()),
+	}
+}
+
+func (d *pbDecoder) indexedAttestation(a *builderpb.IndexedAttestation) IndexedAttestation {
+	return IndexedAttestation{
+		AttestingIndices: a.GetAttestingIndices(),
+		Data:             d.attestationData(a.GetData()),
+		Signature:        d.signature("attester_slashing.signature", a.GetSignature()),
+	}
+}
+
+func (d *pbDecoder) attestationData(a *builderpb.AttestationData) AttestationData {
+	return AttestationData{
+		Slot:            a.GetSlot(),
+		Index:           a.GetIndex(),
+		BeaconBlockRoot: d.hash("attestation.beacon_block_root", a.GetBeaconBlockRoot()),
+		Source:          Checkpoint{Epoch: a.GetSource().GetEpoch(), Root: d.hash("attestation.source.root", a.GetSource().GetRoot())},
+		Target:          Checkpoint{Epoch: a.GetTarget().GetEpoch(), Root: d.hash("attestation.target.root", a.GetTarget().GetRoot())},
+	}
+}
+
+func (d *pbDecoder) header(h *builderpb.ExecutionPayloadHeader) *ExecutionPayloadHeaderV1 {
+	if h == nil {
+		return nil
+	}
+	header := &ExecutionPayloadHeaderV1{
+		ParentHash:       d.hash("execution_payload_header.parent_hash", h.ParentHash),
+		FeeRecipient:     d.address("execution_payload_header.fee_recipient", h.FeeRecipient),
+		StateRoot:        d.hash("execution_payload_header.state_root", h.StateRoot),
+		ReceiptsRoot:     d.hash("execution_payload_header.receipts_root", h.ReceiptsRoot),
+		PrevRandao:       d.hash("execution_payload_header.prev_randao", h.PrevRandao),
+		BlockNumber:      h.BlockNumber,
+		GasLimit:         h.GasLimit,
+		GasUsed:          h.GasUsed,
+		Timestamp:        h.Timestamp,
+		ExtraData:        h.ExtraData,
+		BaseFeePerGas:    new(big.Int).SetBytes(h.BaseFeePerGas),
+		BlockHash:        d.hash("execution_payload_header.block_hash", h.BlockHash),
+		TransactionsRoot: d.hash("execution_payload_header.transactions_root", h.TransactionsRoot),
+	}
+	d.fixed("execution_payload_header.logs_bloom", header.LogsBloom[:], h.LogsBloom)
+	return header
+}
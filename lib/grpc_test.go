@@ -0,0 +1,141 @@
+package lib
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/flashbots/mev-boost/lib/builderpb"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+func TestBuilderServer(t *testing.T) {
+	payload := withBlockHash(ExecutionPayloadWithTxRootV1{
+		BlockHash:        common.HexToHash("0x01"),
+		StateRoot:        common.HexToHash("0x03"),
+		LogsBloom:        make([]byte, 256),
+		ExtraData:        []byte{},
+		BaseFeePerGas:    big.NewInt(4),
+		Transactions:     &[]string{},
+		TransactionsRoot: common.HexToHash("0x7ffe241ea60187fdb0187bfa22de35d1f9bed7ab061d9401fd47e34a54fbede1"),
+		FeeRecipientDiff: big.NewInt(7),
+	})
+	var mu sync.Mutex
+	var proposed []json.RawMessage
+	relay := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		require.Nil(t, err)
+		var req struct {
+			Method string            `json:"method"`
+			Params []json.RawMessage `json:"params"`
+		}
+		require.Nil(t, json.Unmarshal(body, &req))
+		var result interface{}
+		switch req.Method {
+		case methodForkchoiceUpdated:
+			result = ForkChoiceResponse{PayloadID: strToBytes("0x01"), PayloadStatus: PayloadStatus{Status: ForkchoiceStatusValid}}
+		case methodRelayGetHeader:
+			header := payload
+			header.Transactions = nil
+			result = header
+		case methodRelayProposeBlock:
+			mu.Lock()
+			proposed = append(proposed, req.Params[0])
+			mu.Unlock()
+			result = payload
+		}
+		resp, err := formatResponse(result)
+		require.Nil(t, err)
+		w.Write(resp)
+	}))
+	defer relay.Close()
+
+	server := grpc.NewServer()
+	_, err := NewRouter(context.Background(), WithRelayURLs(relay.URL), WithStore(NewStore()), WithLogger(testLog), WithCapabilityCheckInterval(0), WithGRPCServer(server))
+	require.Nil(t, err)
+	listener := bufconn.Listen(1 << 20)
+	go server.Serve(listener)
+	defer server.Stop()
+	conn, err := grpc.Dial("bufconn", grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return listener.DialContext(ctx) }))
+	require.Nil(t, err)
+	defer conn.Close()
+	client := builderpb.NewBuilderClient(conn)
+	ctx := context.Background()
+
+	registered, err := client.Register(ctx, &builderpb.RegisterRequest{
+		ForkchoiceState:   &builderpb.ForkchoiceState{HeadBlockHash: make([]byte, 32), SafeBlockHash: make([]byte, 32), FinalizedBlockHash: make([]byte, 32)},
+		PayloadAttributes: &builderpb.PayloadAttributes{Timestamp: 1, PrevRandao: make([]byte, 32), SuggestedFeeRecipient: common.HexToAddress("0x01").Bytes()},
+	})
+	require.Nil(t, err)
+	require.Len(t, registered.PayloadId, 8)
+
+	header, err := client.GetHeader(ctx, &builderpb.GetHeaderRequest{PayloadId: registered.PayloadId})
+	require.Nil(t, err)
+	require.Equal(t, payload.BlockHash.Bytes(), header.BlockHash)
+	require.Equal(t, payload.TransactionsRoot.Bytes(), header.TransactionsRoot)
+	require.Equal(t, []byte{7}, header.Value)
+
+	signature := bytes.Repeat([]byte{0x11}, 96)
+	block := &builderpb.SignedBlindedBeaconBlock{
+		Message: &builderpb.BlindedBeaconBlock{
+			Slot:       5,
+			ParentRoot: common.HexToHash("0x01").Bytes(),
+			StateRoot:  make([]byte, 32),
+			Body: &builderpb.BlindedBeaconBlockBody{
+				RandaoReveal:           signature,
+				Eth1Data:               &builderpb.Eth1Data{DepositRoot: make([]byte, 32), DepositCount: 10, BlockHash: make([]byte, 32)},
+				Graffiti:               make([]byte, 32),
+				VoluntaryExits:         []*builderpb.SignedVoluntaryExit{{Message: &builderpb.VoluntaryExit{Epoch: 5, ValidatorIndex: 9}, Signature: signature}},
+				SyncAggregate:          &builderpb.SyncAggregate{SyncCommitteeBits: make([]byte, 64), SyncCommitteeSignature: signature},
+				ExecutionPayloadHeader: header,
+			},
+		},
+		Signature: signature,
+	}
+	revealed, err := client.SubmitBlindedBlock(ctx, block)
+	require.Nil(t, err)
+	require.Equal(t, payload.BlockHash.Bytes(), revealed.Header.BlockHash)
+	require.Empty(t, revealed.Transactions)
+
+	mu.Lock()
+	require.Len(t, proposed, 1)
+	var received SignedBlindedBeaconBlock
+	require.Nil(t, json.Unmarshal(proposed[0], &received))
+	mu.Unlock()
+	require.Equal(t, uint64(5), received.Message.Slot)
+	require.Equal(t, []SignedVoluntaryExit{{Message: VoluntaryExit{Epoch: 5, ValidatorIndex: 9}, Signature: received.Signature}}, received.Message.Body.VoluntaryExits)
+	require.Equal(t, uint64(10), received.Message.Body.Eth1Data.DepositCount)
+	require.Equal(t, payload.Header(), received.Message.Body.ExecutionPayloadHeader)
+
+	relays, err := client.Status(ctx, &builderpb.StatusRequest{})
+	require.Nil(t, err)
+	require.Equal(t, []*builderpb.RelayStatus{{Url: relay.URL}}, relays.Relays)
+	require.Equal(t, uint64(1), relays.Store.Bids)
+
+	t.Run("unknown payload id", func(t *testing.T) {
+		_, err := client.GetHeader(ctx, &builderpb.GetHeaderRequest{PayloadId: hexutil.MustDecode("0x0102030405060708")})
+		require.Equal(t, codes.NotFound, status.Code(err), err)
+	})
+
+	t.Run("invalid field", func(t *testing.T) {
+		block.Message.ParentRoot = make([]byte, 31)
+		_, err := client.SubmitBlindedBlock(ctx, block)
+		require.Equal(t, codes.InvalidArgument, status.Code(err))
+		require.Contains(t, err.Error(), "parent_root: expected 32 bytes, got 31")
+	})
+}
@@ -0,0 +1,270 @@
+package lib
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"reflect"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+// ExecutionPayloadHeaderV1 is the execution payload header of a blinded beacon block, see
+// https://github.com/ethereum/consensus-specs/blob/dev/specs/bellatrix/beacon-chain.md#executionpayloadheader
+type ExecutionPayloadHeaderV1 struct {
+	ParentHash       common.Hash
+	FeeRecipient     common.Address
+	StateRoot        common.Hash
+	ReceiptsRoot     common.Hash
+	LogsBloom        [256]byte `ssz-size:"256"`
+	PrevRandao       common.Hash
+	BlockNumber      uint64
+	GasLimit         uint64
+	GasUsed          uint64
+	Timestamp        uint64
+	ExtraData        []byte   `ssz-max:"32"`
+	BaseFeePerGas    *big.Int `ssz-size:"32"`
+	BlockHash        common.Hash
+	TransactionsRoot common.Hash
+	// WithdrawalsRoot is the hash tree root of the withdrawals of capella headers, nil before capella
+	WithdrawalsRoot *common.Hash
+}
+
+// executionPayloadHeaderJSON is the beacon API encoding of ExecutionPayloadHeaderV1
+type executionPayloadHeaderJSON struct {
+	ParentHash       common.Hash    `json:"parent_hash" gencodec:"required"`
+	FeeRecipient     common.Address `json:"fee_recipient" gencodec:"required"`
+	StateRoot        common.Hash    `json:"state_root" gencodec:"required"`
+	ReceiptsRoot     common.Hash    `json:"receipts_root" gencodec:"required"`
+	LogsBloom        hexutil.Bytes  `json:"logs_bloom" gencodec:"required"`
+	PrevRandao       common.Hash    `json:"prev_randao" gencodec:"required"`
+	BlockNumber      uint64         `json:"block_number,string" gencodec:"required"`
+	GasLimit         uint64         `json:"gas_limit,string" gencodec:"required"`
+	GasUsed          uint64         `json:"gas_used,string" gencodec:"required"`
+	Timestamp        uint64         `json:"timestamp,string" gencodec:"required"`
+	ExtraData        hexutil.Bytes  `json:"extra_data" gencodec:"required"`
+	BaseFeePerGas    string         `json:"base_fee_per_gas" gencodec:"required"`
+	BlockHash        common.Hash    `json:"block_hash" gencodec:"required"`
+	TransactionsRoot common.Hash    `json:"transactions_root" gencodec:"required"`
+	WithdrawalsRoot  *common.Hash   `json:"withdrawals_root,omitempty"`
+}
+
+// MarshalJSON encodes the header like the beacon API
+func (h ExecutionPayloadHeaderV1) MarshalJSON() ([]byte, error) {
+	baseFee := "0"
+	if h.BaseFeePerGas != nil {
+		baseFee = h.BaseFeePerGas.String()
+	}
+	return json.Marshal(&executionPayloadHeaderJSON{
+		ParentHash:       h.ParentHash,
+		FeeRecipient:     h.FeeRecipient,
+		StateRoot:        h.StateRoot,
+		ReceiptsRoot:     h.ReceiptsRoot,
+		LogsBloom:        h.LogsBloom[:],
+		PrevRandao:       h.PrevRandao,
+		BlockNumber:      h.BlockNumber,
+		GasLimit:         h.GasLimit,
+		GasUsed:          h.GasUsed,
+		Timestamp:        h.Timestamp,
+		ExtraData:        h.ExtraData,
+		BaseFeePerGas:    baseFee,
+		BlockHash:        h.BlockHash,
+		TransactionsRoot: h.TransactionsRoot,
+		WithdrawalsRoot:  h.WithdrawalsRoot,
+	})
+}
+
+// headerQuantities are the quantities of the beacon API encoding of headers with their size in bits
+var headerQuantities = map[string]int{"block_number": 64, "gas_limit": 64, "gas_used": 64, "timestamp": 64, "base_fee_per_gas": 256}
+
+// UnmarshalJSON decodes a header encoded like the beacon API, with snake_case field names and decimal quantities, or
+// like the engine API, with camelCase field names and hex quantities. Either name is accepted for each field, and either
+// form for each quantity, but headers with a field under both names, missing fields or oversized values are rejected.
+// The header is marshalled back in the beacon API encoding.
+func (h *ExecutionPayloadHeaderV1) UnmarshalJSON(input []byte) error {
+	normalized, err := normalizeHeaderJSON(input)
+	if err != nil {
+		return err
+	}
+	var dec executionPayloadHeaderJSON
+	if err := json.Unmarshal(normalized, &dec); err != nil {
+		return err
+	}
+	if len(dec.LogsBloom) != len(h.LogsBloom) {
+		return fmt.Errorf("invalid logs_bloom length %d", len(dec.LogsBloom))
+	}
+	if len(dec.ExtraData) > 32 {
+		return fmt.Errorf("invalid extra_data length %d", len(dec.ExtraData))
+	}
+	baseFee, _ := new(big.Int).SetString(dec.BaseFeePerGas, 10) // checked by normalizeHeaderJSON
+
+	*h = ExecutionPayloadHeaderV1{
+		ParentHash:       dec.ParentHash,
+		FeeRecipient:     dec.FeeRecipient,
+		StateRoot:        dec.StateRoot,
+		ReceiptsRoot:     dec.ReceiptsRoot,
+		PrevRandao:       dec.PrevRandao,
+		BlockNumber:      dec.BlockNumber,
+		GasLimit:         dec.GasLimit,
+		GasUsed:          dec.GasUsed,
+		Timestamp:        dec.Timestamp,
+		ExtraData:        dec.ExtraData,
+		BaseFeePerGas:    baseFee,
+		BlockHash:        dec.BlockHash,
+		TransactionsRoot: dec.TransactionsRoot,
+		WithdrawalsRoot:  dec.WithdrawalsRoot,
+	}
+	copy(h.LogsBloom[:], dec.LogsBloom)
+	return nil
+}
+
+// normalizeHeaderJSON converts a header in either encoding to the beacon API encoding, and checks that it has all
+// required fields, each under one name, and quantities that fit their size
+func normalizeHeaderJSON(input []byte) ([]byte, error) {
+	var object map[string]json.RawMessage
+	if err := json.Unmarshal(input, &object); err != nil {
+		return nil, err
+	}
+	if object == nil {
+		return nil, errors.New("header is null")
+	}
+
+	normalized := make(map[string]json.RawMessage, len(object))
+	for key, value := range object {
+		name := snakeCase(key)
+		if _, ok := normalized[name]; ok {
+			return nil, fmt.Errorf("header has %s under two names", name)
+		}
+		if bits, ok := headerQuantities[name]; ok && !isNull(value) {
+			quantity, err := unmarshalQuantity(value, bits)
+			if err != nil {
+				return nil, fmt.Errorf("invalid %s: %v", key, err)
+			}
+			value, _ = json.Marshal(quantity.String())
+		}
+		normalized[name] = value
+	}
+	for _, field := range jsonFieldsOf(reflect.TypeOf(executionPayloadHeaderJSON{})) {
+		if field.required && isNull(normalized[field.name]) {
+			return nil, fmt.Errorf("header has no %s", field.name)
+		}
+	}
+	return json.Marshal(normalized)
+}
+
+// unmarshalQuantity decodes an unsigned quantity of at most bits bits, encoded as decimal string like the beacon API or
+// as hex string like the engine API
+func unmarshalQuantity(value json.RawMessage, bits int) (*big.Int, error) {
+	var s string
+	if err := json.Unmarshal(value, &s); err != nil {
+		return nil, fmt.Errorf("quantity must be a string: %v", err)
+	}
+	var n *big.Int
+	if strings.HasPrefix(s, "0x") {
+		decoded, err := hexutil.DecodeBig(s)
+		if err != nil {
+			return nil, err
+		}
+		n = decoded
+	} else {
+		decoded, ok := new(big.Int).SetString(s, 10)
+		if !ok || s == "" || s[0] < '0' || s[0] > '9' {
+			return nil, fmt.Errorf("%q is neither decimal nor hex", s)
+		}
+		n = decoded
+	}
+	if n.BitLen() > bits {
+		return nil, fmt.Errorf("%s exceeds %d bits", s, bits)
+	}
+	return n, nil
+}
+
+// HashTreeRoot returns the SSZ hash tree root of the header, of the capella header if it has a withdrawals root
+func (h *ExecutionPayloadHeaderV1) HashTreeRoot() ([32]byte, error) {
+	if len(h.ExtraData) > 32 {
+		return [32]byte{}, fmt.Errorf("invalid extra_data length %d", len(h.ExtraData))
+	}
+	if h.BaseFeePerGas == nil || h.BaseFeePerGas.Sign() < 0 || h.BaseFeePerGas.BitLen() > 256 {
+		return [32]byte{}, fmt.Errorf("invalid base_fee_per_gas %v", h.BaseFeePerGas)
+	}
+
+	chunks := make([][32]byte, 14, 15)
+	chunks[0] = h.ParentHash
+	copy(chunks[1][:], h.FeeRecipient[:])
+	chunks[2] = h.StateRoot
+	chunks[3] = h.ReceiptsRoot
+
+	bloomChunks := make([][32]byte, len(h.LogsBloom)/32)
+	for i := range bloomChunks {
+		copy(bloomChunks[i][:], h.LogsBloom[i*32:])
+	}
+	chunks[4] = merkleize(bloomChunks)
+
+	chunks[5] = h.PrevRandao
+	binary.LittleEndian.PutUint64(chunks[6][:], h.BlockNumber)
+	binary.LittleEndian.PutUint64(chunks[7][:], h.GasLimit)
+	binary.LittleEndian.PutUint64(chunks[8][:], h.GasUsed)
+	binary.LittleEndian.PutUint64(chunks[9][:], h.Timestamp)
+
+	// a ByteList[32] is a single chunk mixed in with its length
+	var extraData, extraDataLength [32]byte
+	copy(extraData[:], h.ExtraData)
+	binary.LittleEndian.PutUint64(extraDataLength[:], uint64(len(h.ExtraData)))
+	chunks[10] = sha256.Sum256(append(extraData[:], extraDataLength[:]...))
+
+	// uint256 is little endian
+	baseFee := h.BaseFeePerGas.FillBytes(make([]byte, 32))
+	for i := range baseFee {
+		chunks[11][i] = baseFee[31-i]
+	}
+
+	chunks[12] = h.BlockHash
+	chunks[13] = h.TransactionsRoot
+	if h.WithdrawalsRoot != nil {
+		chunks = append(chunks, *h.WithdrawalsRoot)
+	}
+	return merkleize(chunks), nil
+}
+
+// Header returns the header of the payload, TransactionsRoot must be set, and WithdrawalsRoot for payloads of capella
+func (p *ExecutionPayloadWithTxRootV1) Header() *ExecutionPayloadHeaderV1 {
+	header := &ExecutionPayloadHeaderV1{
+		ParentHash:       p.ParentHash,
+		FeeRecipient:     p.FeeRecipient,
+		StateRoot:        p.StateRoot,
+		ReceiptsRoot:     p.ReceiptsRoot,
+		PrevRandao:       p.PrevRandao,
+		BlockNumber:      p.Number,
+		GasLimit:         p.GasLimit,
+		GasUsed:          p.GasUsed,
+		Timestamp:        p.Timestamp,
+		ExtraData:        p.ExtraData,
+		BaseFeePerGas:    p.BaseFeePerGas,
+		BlockHash:        p.BlockHash,
+		TransactionsRoot: p.TransactionsRoot,
+		WithdrawalsRoot:  p.WithdrawalsRoot,
+	}
+	copy(header.LogsBloom[:], p.LogsBloom)
+	return header
+}
+
+// matchHeader checks that the payload is the one committed to by the header of a signed block, by hash tree root
+func matchHeader(header *ExecutionPayloadHeaderV1, payload *ExecutionPayloadWithTxRootV1) error {
+	want, err := header.HashTreeRoot()
+	if err != nil {
+		return err
+	}
+	got, err := payload.Header().HashTreeRoot()
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrHeaderMismatch, err)
+	}
+	if got != want {
+		return fmt.Errorf("%w: block %s has root %#x, signed header %s has root %#x", ErrHeaderMismatch, payload.BlockHash, got, header.BlockHash, want)
+	}
+	return nil
+}
@@ -0,0 +1,164 @@
+package lib
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/require"
+)
+
+func testHeader() *ExecutionPayloadHeaderV1 {
+	header := &ExecutionPayloadHeaderV1{
+		ParentHash:       common.BytesToHash(bytes.Repeat([]byte{1}, 32)),
+		FeeRecipient:     common.BytesToAddress(bytes.Repeat([]byte{2}, 20)),
+		StateRoot:        common.BytesToHash(bytes.Repeat([]byte{3}, 32)),
+		ReceiptsRoot:     common.BytesToHash(bytes.Repeat([]byte{4}, 32)),
+		PrevRandao:       common.BytesToHash(bytes.Repeat([]byte{5}, 32)),
+		BlockNumber:      100,
+		GasLimit:         30000000,
+		GasUsed:          21000,
+		Timestamp:        1650000000,
+		ExtraData:        []byte("mev-boost"),
+		BaseFeePerGas:    big.NewInt(7000000000),
+		BlockHash:        common.BytesToHash(bytes.Repeat([]byte{6}, 32)),
+		TransactionsRoot: common.BytesToHash(bytes.Repeat([]byte{7}, 32)),
+	}
+	for i := range header.LogsBloom {
+		header.LogsBloom[i] = byte(i)
+	}
+	return header
+}
+
+func TestExecutionPayloadHeaderV1_HashTreeRoot(t *testing.T) {
+	root, err := testHeader().HashTreeRoot()
+	require.Nil(t, err)
+	require.Equal(t, common.HexToHash("0x10503404a912f98a1cad60a9dc0739ba50f80e3a6511f2bcfc77a638fab1fbf0"), common.Hash(root))
+
+	header := testHeader()
+	header.ExtraData = make([]byte, 33)
+	_, err = header.HashTreeRoot()
+	require.Error(t, err)
+}
+
+func TestExecutionPayloadHeaderV1_JSON(t *testing.T) {
+	encoded, err := json.Marshal(testHeader())
+	require.Nil(t, err)
+	require.Contains(t, string(encoded), `"block_number":"100"`)
+	require.Contains(t, string(encoded), `"base_fee_per_gas":"7000000000"`)
+
+	decoded := new(ExecutionPayloadHeaderV1)
+	require.Nil(t, json.Unmarshal(encoded, decoded))
+	require.Equal(t, testHeader(), decoded)
+
+	err = json.Unmarshal([]byte(`{"block_hash":"0x0000000000000000000000000000000000000000000000000000000000000001"}`), decoded)
+	require.Error(t, err, "partial headers are rejected")
+}
+
+func TestExecutionPayloadHeaderV1_JSONNormalization(t *testing.T) {
+	header := testHeader()
+	canonical, err := json.Marshal(header)
+	require.Nil(t, err)
+	engineEncoded, err := json.Marshal(&ExecutionPayloadWithTxRootV1{
+		ParentHash:       header.ParentHash,
+		FeeRecipient:     header.FeeRecipient,
+		StateRoot:        header.StateRoot,
+		ReceiptsRoot:     header.ReceiptsRoot,
+		LogsBloom:        header.LogsBloom[:],
+		PrevRandao:       header.PrevRandao,
+		Number:           header.BlockNumber,
+		GasLimit:         header.GasLimit,
+		GasUsed:          header.GasUsed,
+		Timestamp:        header.Timestamp,
+		ExtraData:        header.ExtraData,
+		BaseFeePerGas:    header.BaseFeePerGas,
+		BlockHash:        header.BlockHash,
+		TransactionsRoot: header.TransactionsRoot,
+	})
+	require.Nil(t, err)
+	require.Contains(t, string(engineEncoded), `"baseFeePerGas":"0x1a13b8600"`)
+
+	decoded := new(ExecutionPayloadHeaderV1)
+	require.Nil(t, json.Unmarshal(engineEncoded, decoded))
+	require.Equal(t, header, decoded)
+	reencoded, err := json.Marshal(decoded)
+	require.Nil(t, err)
+	require.Equal(t, string(canonical), string(reencoded), "headers are marshalled in the beacon API encoding")
+
+	// withField returns the canonical header with a field replaced, or removed if value is empty
+	withField := func(name, value string) []byte {
+		var object map[string]json.RawMessage
+		require.Nil(t, json.Unmarshal(canonical, &object))
+		delete(object, name)
+		if value != "" {
+			object[name] = json.RawMessage(value)
+		}
+		encoded, err := json.Marshal(object)
+		require.Nil(t, err)
+		return encoded
+	}
+	for _, tt := range []struct {
+		name    string
+		input   []byte
+		baseFee *big.Int
+		wantErr string
+	}{
+		{"mixed names", withField("blockNumber", `"0x64"`), nil, "block_number under two names"},
+		{"hex quantity in the beacon API encoding", withField("base_fee_per_gas", `"0x10"`), big.NewInt(16), ""},
+		{"max uint256 base fee", withField("base_fee_per_gas", `"`+new(big.Int).Sub(new(big.Int).Lsh(common.Big1, 256), common.Big1).String()+`"`), new(big.Int).Sub(new(big.Int).Lsh(common.Big1, 256), common.Big1), ""},
+		{"base fee over uint256", withField("base_fee_per_gas", `"`+new(big.Int).Lsh(common.Big1, 256).String()+`"`), nil, "exceeds 256 bits"},
+		{"negative base fee", withField("base_fee_per_gas", `"-1"`), nil, "neither decimal nor hex"},
+		{"numeric base fee", withField("base_fee_per_gas", `7`), nil, "must be a string"},
+		{"block number over uint64", withField("block_number", `"18446744073709551616"`), nil, "exceeds 64 bits"},
+		{"missing field", withField("state_root", ""), nil, "no state_root"},
+		{"null field", withField("fee_recipient", "null"), nil, "no fee_recipient"},
+		{"oversized extra data", withField("extra_data", `"0x`+strings.Repeat("00", 33)+`"`), nil, "extra_data length 33"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			decoded := new(ExecutionPayloadHeaderV1)
+			err := json.Unmarshal(tt.input, decoded)
+			if tt.wantErr != "" {
+				require.Error(t, err)
+				require.Contains(t, err.Error(), tt.wantErr)
+				return
+			}
+			require.Nil(t, err)
+			require.Equal(t, tt.baseFee, decoded.BaseFeePerGas)
+		})
+	}
+
+	body := new(BlindedBeaconBlockBody)
+	require.Nil(t, json.Unmarshal([]byte(`{"executionPayloadHeader":`+string(engineEncoded)+`}`), body))
+	require.Equal(t, header, body.ExecutionPayloadHeader)
+	err = json.Unmarshal([]byte(`{"executionPayloadHeader":`+string(engineEncoded)+`,"execution_payload_header":`+string(canonical)+`}`), body)
+	require.Error(t, err, "bodies with the header under both names are rejected")
+}
+
+func Test_matchHeader(t *testing.T) {
+	header := testHeader()
+	payload := &ExecutionPayloadWithTxRootV1{
+		ParentHash:       header.ParentHash,
+		FeeRecipient:     header.FeeRecipient,
+		StateRoot:        header.StateRoot,
+		ReceiptsRoot:     header.ReceiptsRoot,
+		LogsBloom:        header.LogsBloom[:],
+		PrevRandao:       header.PrevRandao,
+		Number:           header.BlockNumber,
+		GasLimit:         header.GasLimit,
+		GasUsed:          header.GasUsed,
+		Timestamp:        header.Timestamp,
+		ExtraData:        header.ExtraData,
+		BaseFeePerGas:    header.BaseFeePerGas,
+		BlockHash:        header.BlockHash,
+		TransactionsRoot: header.TransactionsRoot,
+	}
+	require.Nil(t, matchHeader(header, payload))
+
+	// same block hash, but a field the relay changed
+	payload.GasUsed++
+	require.True(t, errors.Is(matchHeader(header, payload), ErrHeaderMismatch))
+}
@@ -0,0 +1,152 @@
+package lib
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// header checks, the labels of mevboost_header_check_failures_total
+const (
+	headerCheckParent       = "parent"
+	headerCheckTimestamp    = "timestamp"
+	headerCheckGasLimit     = "gas_limit"
+	headerCheckFeeRecipient = "fee_recipient"
+)
+
+// gasLimitBoundDivisor bounds the change of the gas limit from one block to the next to less than 1/1024 of the parent's
+const gasLimitBoundDivisor = 1024
+
+var headerCheckFailuresTotal = metricsFactory.NewCounterVec(prometheus.CounterOpts{
+	Name: "mevboost_header_check_failures_total",
+	Help: "Relay headers discarded as inconsistent with the forkchoice state and registration of their payload, by relay and failed check",
+}, []string{"relay", "check"})
+
+// headerChecks compares relay headers with what the consensus client asked for, see WithHeaderChecks and
+// WithGasLimitChecks
+type headerChecks struct {
+	el           *ExecutionClient // nil unless parent gas limits are looked up on an execution client
+	gasLimitOnly bool             // only gas limits are checked
+}
+
+// headerExpectations are the values the headers of a payload id must match, zero values are unknown and not checked
+type headerExpectations struct {
+	parent         common.Hash    // head of the forkchoiceUpdated call of the payload id
+	timestamp      uint64         // timestamp of the payload attributes
+	feeRecipient   common.Address // registered fee recipient of the proposer
+	gasLimit       uint64         // registered gas limit of the proposer
+	parentGasLimit uint64
+}
+
+// check returns the failed check and why, or an empty check if the header matches the expectations. The fee recipient
+// is only checked if the header pays the proposer verifiably, as the fee recipient of the block or by the transactions it
+// came with, and the gas limit only if the gas limit of the parent is known.
+func (e headerExpectations) check(header *ExecutionPayloadWithTxRootV1) (string, error) {
+	if e.parent != nilHash && header.ParentHash != e.parent {
+		return headerCheckParent, fmt.Errorf("parent %s is not the forkchoice head %s", header.ParentHash, e.parent)
+	}
+	if e.timestamp != 0 && header.Timestamp != e.timestamp {
+		return headerCheckTimestamp, fmt.Errorf("timestamp %d is not the slot time %d", header.Timestamp, e.timestamp)
+	}
+	if e.gasLimit != 0 && e.parentGasLimit != 0 {
+		if err := checkGasLimit(header.GasLimit, e.parentGasLimit, e.gasLimit); err != nil {
+			return headerCheckGasLimit, err
+		}
+	}
+	if e.feeRecipient != (common.Address{}) && header.FeeRecipient != e.feeRecipient && header.Transactions != nil {
+		if _, err := verifyProposerPayment(header, e.feeRecipient, nil); err != nil {
+			return headerCheckFeeRecipient, fmt.Errorf("doesn't pay the registered fee recipient: %w", err)
+		}
+	}
+	return "", nil
+}
+
+// checkGasLimit checks that a gas limit is a valid successor of the parent gas limit that doesn't move away from the
+// registered target
+func checkGasLimit(gasLimit, parent, target uint64) error {
+	if absDiff(gasLimit, parent) >= parent/gasLimitBoundDivisor {
+		return fmt.Errorf("gas limit %d changes the parent gas limit %d by more than 1/%d", gasLimit, parent, gasLimitBoundDivisor)
+	}
+	if absDiff(gasLimit, target) > absDiff(parent, target) {
+		return fmt.Errorf("gas limit %d moves away from the registered gas limit %d", gasLimit, target)
+	}
+	return nil
+}
+
+func absDiff(a, b uint64) uint64 {
+	if a > b {
+		return a - b
+	}
+	return b - a
+}
+
+// headerExpectations returns the expectations of the headers of a payload id, from the head of its forkchoiceUpdated
+// call, its payload attributes, and the registration and preferences of its proposer
+func (m *RelayService) headerExpectations(ctx context.Context, payloadID string, attributes *PayloadAttributesV1, log Logger) headerExpectations {
+	var e headerExpectations
+	e.parent, _ = m.heads.get(payloadID)
+	if attributes == nil {
+		return e
+	}
+	e.timestamp = uint64(attributes.Timestamp)
+	e.feeRecipient = attributes.SuggestedFeeRecipient
+
+	pubkey := m.proposerOf(ctx, m.chain.SlotAt(e.timestamp), attributes.SuggestedFeeRecipient, log)
+	if pubkey == "" {
+		return e
+	}
+	if registration := m.store.GetValidatorRegistration(ctx, pubkey); registration != nil && registration.Message != nil {
+		e.feeRecipient = registration.Message.FeeRecipient
+		e.gasLimit = registration.Message.GasLimit
+	}
+	if preferences := m.preferences.get(pubkey); preferences != nil {
+		if preferences.FeeRecipient != nil {
+			e.feeRecipient = *preferences.FeeRecipient
+		}
+		if preferences.GasLimit != nil {
+			e.gasLimit = *preferences.GasLimit
+		}
+	}
+	if e.gasLimit != 0 && e.parent != nilHash {
+		e.parentGasLimit = m.headerChecks.parentGasLimit(ctx, m.store, e.parent, log)
+	}
+	if m.headerChecks.gasLimitOnly {
+		return headerExpectations{gasLimit: e.gasLimit, parentGasLimit: e.parentGasLimit}
+	}
+	return e
+}
+
+// parentGasLimit returns the gas limit of the parent block from the store, or the execution client, 0 if it's unknown
+func (c *headerChecks) parentGasLimit(ctx context.Context, store Store, parent common.Hash, log Logger) uint64 {
+	if payload := store.GetExecutionPayload(ctx, parent); payload != nil {
+		return payload.GasLimit
+	}
+	if c.el == nil {
+		return 0
+	}
+	gasLimit, err := c.el.GasLimit(ctx, parent)
+	if err != nil {
+		log.WithFields(Fields{"parent": parent, "error": err}).Warn("could not look up the gas limit of the parent block, not checking header gas limits")
+		return 0
+	}
+	return gasLimit
+}
+
+// rejectInconsistentHeaders drops the candidates that don't match the forkchoice state and registration of the payload
+func (m *RelayService) rejectInconsistentHeaders(candidates []BidCandidate, expected headerExpectations, failures *relayFailures, log Logger) []BidCandidate {
+	consistent := make([]BidCandidate, 0, len(candidates))
+	for _, candidate := range candidates {
+		check, err := expected.check(candidate.Header)
+		if err == nil {
+			consistent = append(consistent, candidate)
+			continue
+		}
+		headerCheckFailuresTotal.WithLabelValues(candidate.RelayURL, check).Inc()
+		m.bids.setResult(candidate.RelayURL, candidate.Header.BlockHash, BidResultInvalid, err)
+		failures.invalid()
+		log.WithFields(Fields{"error": err, "url": candidate.RelayURL, "blockHash": candidate.Header.BlockHash, "check": check}).Warn("GetPayloadHeaderV1: header inconsistent with the forkchoice state")
+	}
+	return consistent
+}
@@ -0,0 +1,135 @@
+package lib
+
+import (
+	"bytes"
+	"context"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckGasLimit(t *testing.T) {
+	tests := []struct {
+		name                     string
+		gasLimit, parent, target uint64
+		ok                       bool
+	}{
+		{"at the target", 30_000_000, 30_000_000, 30_000_000, true},
+		{"towards a higher target", 30_029_000, 30_000_000, 36_000_000, true},
+		{"towards a lower target", 29_971_000, 30_000_000, 20_000_000, true},
+		{"away from the target", 29_990_000, 30_000_000, 36_000_000, false},
+		{"past the target", 30_012_000, 30_000_000, 30_005_000, false},
+		{"more than 1/1024 of the parent", 30_030_000, 30_000_000, 36_000_000, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkGasLimit(tt.gasLimit, tt.parent, tt.target)
+			require.Equal(t, tt.ok, err == nil, "%v", err)
+		})
+	}
+}
+
+func TestHeaderExpectations_FeeRecipient(t *testing.T) {
+	e := headerExpectations{feeRecipient: common.HexToAddress("0xfee")}
+	header := &ExecutionPayloadWithTxRootV1{FeeRecipient: common.HexToAddress("0xb1d"), Transactions: &[]string{}}
+	check, err := e.check(header)
+	require.Equal(t, headerCheckFeeRecipient, check)
+	require.Error(t, err)
+
+	header.Transactions = nil
+	check, err = e.check(header)
+	require.Nil(t, err, "the payment of headers without transactions is only verified after delivery")
+	require.Empty(t, check)
+}
+
+func TestGetPayloadHeaderV1_HeaderChecks(t *testing.T) {
+	head := common.HexToHash("0xaa")
+	feeRecipient := common.HexToAddress("0xfee")
+	header := func(blockHash string, value int64) ExecutionPayloadWithTxRootV1 {
+		return ExecutionPayloadWithTxRootV1{
+			BlockHash:        common.HexToHash(blockHash),
+			ParentHash:       head,
+			Timestamp:        1200,
+			GasLimit:         30_000_000,
+			BaseFeePerGas:    big.NewInt(1),
+			FeeRecipient:     feeRecipient,
+			FeeRecipientDiff: big.NewInt(value),
+		}
+	}
+	valid := header("0x01", 1)
+	otherParent := header("0x02", 5)
+	otherParent.ParentHash = common.HexToHash("0xbb")
+	otherTimestamp := header("0x03", 4)
+	otherTimestamp.Timestamp = 1212
+	gasLimitAway := header("0x04", 3)
+	gasLimitAway.GasLimit = 29_990_000
+
+	store := NewStore()
+	var relayURLs []string
+	for _, h := range []ExecutionPayloadWithTxRootV1{valid, otherParent, otherTimestamp, gasLimitAway} {
+		relay := newHeaderRelay(t, h)
+		defer relay.Close()
+		relayURLs = append(relayURLs, relay.URL)
+		store.SetForkchoiceResponse(context.Background(), "0x01", relay.URL, "0x01")
+	}
+	store.SetPayloadAttributes(context.Background(), "0x01", &PayloadAttributesV1{Timestamp: 1200, SuggestedFeeRecipient: feeRecipient})
+	store.SetExecutionPayload(context.Background(), head, &ExecutionPayloadWithTxRootV1{BlockHash: head, GasLimit: 30_000_000})
+	service, err := newRelayService(WithRelayURLs(relayURLs...), WithStore(store), WithLogger(testLog), WithHeaderChecks(nil))
+	require.Nil(t, err)
+	service.heads.set("0x01", service.chain.SlotAt(1200), head)
+	registration := &ValidatorRegistrationV1{FeeRecipient: feeRecipient, GasLimit: 36_000_000, Pubkey: hexutil.Bytes(bytes.Repeat([]byte{1}, 48))}
+	service.proposers.register(registration)
+	store.SetValidatorRegistration(context.Background(), &SignedValidatorRegistrationV1{Message: registration})
+
+	payloadID := "0x01"
+	result := new(ExecutionPayloadWithTxRootV1)
+	require.Nil(t, service.GetPayloadHeaderV1(nil, &payloadID, result))
+	require.Equal(t, valid.BlockHash, result.BlockHash)
+
+	results := make(map[common.Hash]string)
+	for _, bid := range service.bids.slot(service.chain.SlotAt(1200)) {
+		results[bid.BlockHash] = bid.Result
+	}
+	require.Equal(t, map[common.Hash]string{
+		valid.BlockHash:          BidResultWon,
+		otherParent.BlockHash:    BidResultInvalid,
+		otherTimestamp.BlockHash: BidResultInvalid,
+		gasLimitAway.BlockHash:   BidResultInvalid,
+	}, results)
+}
+
+func TestGetPayloadHeaderV1_GasLimitChecks(t *testing.T) {
+	head := common.HexToHash("0xaa")
+	feeRecipient := common.HexToAddress("0xfee")
+	otherTimestamp := ExecutionPayloadWithTxRootV1{BlockHash: common.HexToHash("0x01"), ParentHash: head, Timestamp: 1212, GasLimit: 30_000_000, BaseFeePerGas: big.NewInt(1), FeeRecipientDiff: big.NewInt(1)}
+	gasLimitAway := otherTimestamp
+	gasLimitAway.BlockHash, gasLimitAway.Timestamp, gasLimitAway.GasLimit, gasLimitAway.FeeRecipientDiff = common.HexToHash("0x02"), 1200, 29_990_000, big.NewInt(2)
+
+	store := NewStore()
+	var relayURLs []string
+	for _, h := range []ExecutionPayloadWithTxRootV1{otherTimestamp, gasLimitAway} {
+		relay := newHeaderRelay(t, h)
+		defer relay.Close()
+		relayURLs = append(relayURLs, relay.URL)
+		store.SetForkchoiceResponse(context.Background(), "0x01", relay.URL, "0x01")
+	}
+	store.SetPayloadAttributes(context.Background(), "0x01", &PayloadAttributesV1{Timestamp: 1200, SuggestedFeeRecipient: feeRecipient})
+	store.SetExecutionPayload(context.Background(), head, &ExecutionPayloadWithTxRootV1{BlockHash: head, GasLimit: 30_000_000})
+	service, err := newRelayService(WithRelayURLs(relayURLs...), WithStore(store), WithLogger(testLog), WithGasLimitChecks(nil))
+	require.Nil(t, err)
+	service.heads.set("0x01", service.chain.SlotAt(1200), head)
+	registration := &ValidatorRegistrationV1{FeeRecipient: feeRecipient, GasLimit: 36_000_000, Pubkey: hexutil.Bytes(bytes.Repeat([]byte{1}, 48))}
+	service.proposers.register(registration)
+	store.SetValidatorRegistration(context.Background(), &SignedValidatorRegistrationV1{Message: registration})
+
+	payloadID := "0x01"
+	result := new(ExecutionPayloadWithTxRootV1)
+	require.Nil(t, service.GetPayloadHeaderV1(nil, &payloadID, result))
+	require.Equal(t, otherTimestamp.BlockHash, result.BlockHash, "only the gas limit is checked")
+
+	cfg := newRouterConfig(context.Background(), WithHeaderChecks(nil), WithGasLimitChecks(nil))
+	require.False(t, cfg.headerChecks.gasLimitOnly, "header checks include gas limit checks")
+}
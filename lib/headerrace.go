@@ -0,0 +1,80 @@
+package lib
+
+import (
+	"context"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var relayLateHeadersTotal = metricsFactory.NewCounterVec(prometheus.CounterOpts{
+	Name: "mevboost_relay_late_headers_total",
+	Help: "Header responses of relays that arrived after the race cutoff and weren't offered, by relay",
+}, []string{"relay"})
+
+// headerRace tracks the race cutoff of a header call. Without a cutoff configured, the call waits for all relays, whose
+// calls are cancelled with the call.
+type headerRace struct {
+	cutoff <-chan time.Time
+	done   <-chan struct{}
+	timer  *time.Timer
+	passed bool
+	gone   bool
+}
+
+func (m *RelayService) newHeaderRace(ctx context.Context) *headerRace {
+	race := new(headerRace)
+	if m.raceCutoff > 0 {
+		race.timer = time.NewTimer(m.raceCutoff)
+		race.cutoff, race.done = race.timer.C, ctx.Done()
+	}
+	return race
+}
+
+// over reports whether the call stops waiting for the other relays: the cutoff passed and a valid header arrived, or
+// the caller is gone
+func (r *headerRace) over(fetched *headerFetch) bool {
+	return r.gone || (r.passed && len(fetched.candidates) > 0)
+}
+
+func (r *headerRace) cutoffPassed() {
+	r.passed, r.cutoff = true, nil
+}
+
+// callerGone ends the race, in race mode the relay calls outlive the caller so the call needs to stop waiting itself
+func (r *headerRace) callerGone() {
+	r.gone, r.done = true, nil
+}
+
+func (r *headerRace) stop() {
+	if r.timer != nil {
+		r.timer.Stop()
+	}
+}
+
+// recordLateHeaders processes the header responses still pending when a header call returned. They're recorded like
+// any other header response, bids are archived as late but nobody gets to see them.
+func (m *RelayService) recordLateHeaders(ctx context.Context, cancel context.CancelFunc, resultC <-chan *rpcResponseContainer, pending int, slot uint64, logMethod Logger) {
+	defer cancel()
+	late := newHeaderFetch()
+	for ; pending > 0; pending-- {
+		res := <-resultC
+		relayLateHeadersTotal.WithLabelValues(res.url).Inc()
+		m.processHeaderResponse(ctx, res, slot, late, logMethod)
+	}
+	for _, candidate := range late.candidates {
+		m.bids.setResult(candidate.RelayURL, candidate.Header.BlockHash, BidResultLate, nil)
+		logMethod.WithFields(Fields{"url": candidate.RelayURL, "blockHash": candidate.Header.BlockHash, "value": candidate.Header.FeeRecipientDiff}).Info("header arrived after the race cutoff")
+	}
+}
+
+// detachedContext keeps the values of its parent but not its cancellation or deadline
+type detachedContext struct{ context.Context }
+
+func detachContext(parent context.Context) context.Context { return detachedContext{parent} }
+
+func (detachedContext) Deadline() (time.Time, bool) { return time.Time{}, false }
+
+func (detachedContext) Done() <-chan struct{} { return nil }
+
+func (detachedContext) Err() error { return nil }
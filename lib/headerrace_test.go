@@ -0,0 +1,70 @@
+package lib
+
+import (
+	"context"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRelayService_HeaderRace(t *testing.T) {
+	fast := newHeaderRelay(t, ExecutionPayloadWithTxRootV1{BlockHash: common.HexToHash("0x01"), BaseFeePerGas: big.NewInt(1), FeeRecipientDiff: big.NewInt(1)})
+	defer fast.Close()
+	release := make(chan struct{})
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+		resp, err := formatResponse(ExecutionPayloadWithTxRootV1{BlockHash: common.HexToHash("0x02"), BaseFeePerGas: big.NewInt(1), FeeRecipientDiff: big.NewInt(5)})
+		require.Nil(t, err)
+		w.Write(resp)
+	}))
+	defer slow.Close()
+
+	store := NewStore()
+	for _, relayURL := range []string{fast.URL, slow.URL} {
+		store.SetForkchoiceResponse(context.Background(), "0x01", relayURL, "0x01")
+	}
+	service, err := newRelayService(WithRelayURLs(fast.URL, slow.URL), WithStore(store), WithLogger(testLog), WithHeaderRace(50*time.Millisecond))
+	require.Nil(t, err)
+
+	payloadID := "0x01"
+	result := new(ExecutionPayloadWithTxRootV1)
+	start := time.Now()
+	require.Nil(t, service.GetPayloadHeaderV1(nil, &payloadID, result))
+	require.Less(t, time.Since(start), time.Second, "the slow relay isn't waited for")
+	require.Equal(t, common.HexToHash("0x01"), result.BlockHash, "the more valuable late bid isn't offered")
+
+	close(release)
+	require.Eventually(t, func() bool {
+		for _, bid := range service.bids.all() {
+			if bid.RelayURL == slow.URL {
+				return bid.Result == BidResultLate
+			}
+		}
+		return false
+	}, time.Second, 10*time.Millisecond, "the late bid is archived")
+}
+
+func TestRelayService_HeaderRaceWaitsForFirstHeader(t *testing.T) {
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(100 * time.Millisecond)
+		resp, err := formatResponse(ExecutionPayloadWithTxRootV1{BlockHash: common.HexToHash("0x02"), BaseFeePerGas: big.NewInt(1), FeeRecipientDiff: big.NewInt(5)})
+		require.Nil(t, err)
+		w.Write(resp)
+	}))
+	defer slow.Close()
+
+	store := NewStore()
+	store.SetForkchoiceResponse(context.Background(), "0x01", slow.URL, "0x01")
+	service, err := newRelayService(WithRelayURLs(slow.URL), WithStore(store), WithLogger(testLog), WithHeaderRace(10*time.Millisecond))
+	require.Nil(t, err)
+
+	payloadID := "0x01"
+	result := new(ExecutionPayloadWithTxRootV1)
+	require.Nil(t, service.GetPayloadHeaderV1(nil, &payloadID, result), "the cutoff is soft without any header")
+	require.Equal(t, common.HexToHash("0x02"), result.BlockHash)
+}
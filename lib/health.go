@@ -0,0 +1,140 @@
+package lib
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	pathLiveness   = "/mev-boost/v1/livez"
+	pathReadiness  = "/mev-boost/v1/readyz"
+	pathSpecStatus = "/eth/v1/builder/status"
+
+	// readinessWindow is how many check intervals ago a relay or engine may have last answered for mev-boost to be ready,
+	// so a single lost probe doesn't take it out of a load balancer
+	readinessWindow = 3
+)
+
+// readiness keeps when relays and the engine endpoints last answered a check, see WithReadinessChecks
+type readiness struct {
+	interval time.Duration
+	engines  []*ExecutionClient
+
+	mu       sync.RWMutex
+	answered map[string]time.Time // map[relay or engine url]time of the last answer
+	errors   map[string]string    // map[relay or engine url]error of the last check, if it failed
+}
+
+func newReadiness(interval time.Duration, engines []*ExecutionClient) *readiness {
+	return &readiness{interval: interval, engines: engines, answered: make(map[string]time.Time), errors: make(map[string]string)}
+}
+
+func (r *readiness) observe(url string, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err != nil {
+		r.errors[url] = err.Error()
+		return
+	}
+	delete(r.errors, url)
+	r.answered[url] = now()
+}
+
+// ReadinessCheck is the state of a relay or engine endpoint in the readiness response
+type ReadinessCheck struct {
+	URL          string     `json:"url"`
+	Ready        bool       `json:"ready"`
+	LastAnswerAt *time.Time `json:"lastAnswerAt,omitempty"`
+	Error        string     `json:"error,omitempty"`
+}
+
+// ReadinessStatus is the response of the readiness endpoint. mev-boost is ready if a relay answered recently, and an
+// engine endpoint if any are checked.
+type ReadinessStatus struct {
+	Ready   bool             `json:"ready"`
+	Relays  []ReadinessCheck `json:"relays"`
+	Engines []ReadinessCheck `json:"engines,omitempty"`
+}
+
+func (r *readiness) check(url string) ReadinessCheck {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	check := ReadinessCheck{URL: url, Error: r.errors[url]}
+	if answered, ok := r.answered[url]; ok {
+		check.LastAnswerAt = &answered
+		check.Ready = now().Sub(answered) <= readinessWindow*r.interval
+	}
+	return check
+}
+
+// status returns the readiness of the relays and engine endpoints
+func (r *readiness) status(relayURLs []string) ReadinessStatus {
+	var status ReadinessStatus
+	relaysReady := false
+	for _, url := range relayURLs {
+		check := r.check(url)
+		relaysReady = relaysReady || check.Ready
+		status.Relays = append(status.Relays, check)
+	}
+	enginesReady := len(r.engines) == 0
+	for _, engine := range r.engines {
+		check := r.check(engine.URL())
+		enginesReady = enginesReady || check.Ready
+		status.Engines = append(status.Engines, check)
+	}
+	status.Ready = relaysReady && enginesReady
+	return status
+}
+
+// checkReadiness sends a relay_getCapabilitiesV1 probe to each relay and asks each engine endpoint for its chain id.
+// Any JSON-RPC answer of a relay counts, including method not found.
+func (m *RelayService) checkReadiness(ctx context.Context) {
+	var wg sync.WaitGroup
+	for _, url := range m.relays.all() {
+		wg.Add(1)
+		go func(url string) {
+			defer wg.Done()
+			err := m.endpoints.call(ctx, url, m.log, func(endpoint string) error {
+				_, err := makeRequest(ctx, m.client, endpoint, methodRelayGetCapabilities, []interface{}{}, m.responseLimits.forMethod(methodRelayGetCapabilities))
+				return err
+			})
+			m.readiness.observe(url, err)
+		}(url)
+	}
+	for _, engine := range m.readiness.engines {
+		wg.Add(1)
+		go func(engine *ExecutionClient) {
+			defer wg.Done()
+			_, err := engine.ChainID(ctx)
+			if err != nil {
+				m.log.WithFields(Fields{"url": engine.URL(), "error": err}).Warn("engine endpoint readiness check failed")
+			}
+			m.readiness.observe(engine.URL(), err)
+		}(engine)
+	}
+	wg.Wait()
+}
+
+// startReadinessChecks checks the relays and engine endpoints right away, and then every interval until ctx is done
+func (m *RelayService) startReadinessChecks(ctx context.Context) {
+	runLoop(ctx, m.log, "readiness", m.readiness.interval, true, m.checkReadiness)
+}
+
+// handleLiveness answers as long as the server handles requests, for liveness probes
+func handleLiveness(w http.ResponseWriter, _ *http.Request) {
+	respondJSON(w, http.StatusOK, struct {
+		Status string `json:"status"`
+	}{"ok"})
+}
+
+// handleReadiness responds with the ReadinessStatus, with status 503 if mev-boost isn't ready
+func (m *RelayService) handleReadiness(w http.ResponseWriter, _ *http.Request) {
+	status := m.readiness.status(m.relays.all())
+	code := http.StatusOK
+	if !status.Ready {
+		code = http.StatusServiceUnavailable
+	}
+	respondJSON(w, code, status)
+}
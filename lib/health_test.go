@@ -0,0 +1,89 @@
+package lib
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadiness_Status(t *testing.T) {
+	defer func() { now = time.Now }()
+	start := time.Unix(1000, 0)
+	now = func() time.Time { return start }
+
+	r := newReadiness(time.Second, []*ExecutionClient{NewExecutionClient("http://engine")})
+	require.False(t, r.status([]string{"http://relay-a", "http://relay-b"}).Ready, "nothing answered yet")
+
+	r.observe("http://relay-a", nil)
+	r.observe("http://relay-b", errors.New("connection refused"))
+	require.False(t, r.status([]string{"http://relay-a", "http://relay-b"}).Ready, "the engine didn't answer")
+
+	r.observe("http://engine", nil)
+	status := r.status([]string{"http://relay-a", "http://relay-b"})
+	require.True(t, status.Ready)
+	require.Equal(t, "connection refused", status.Relays[1].Error)
+	require.False(t, status.Relays[1].Ready)
+
+	now = func() time.Time { return start.Add(readinessWindow*time.Second + time.Millisecond) }
+	require.False(t, r.status([]string{"http://relay-a", "http://relay-b"}).Ready, "answers are stale")
+}
+
+func TestRouter_HealthEndpoints(t *testing.T) {
+	relay := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		resp, err := formatResponse(RelayCapabilities{SpecVersion: builderSpecVersion})
+		require.Nil(t, err)
+		w.Write(resp)
+	}))
+	defer relay.Close()
+	engine := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		resp, err := formatResponse("0x1")
+		require.Nil(t, err)
+		w.Write(resp)
+	}))
+	defer engine.Close()
+	down := httptest.NewServer(http.NotFoundHandler())
+	down.Close()
+
+	get := func(handler http.Handler, path string) (int, ReadinessStatus) {
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, path, nil))
+		var status ReadinessStatus
+		_ = json.Unmarshal(rr.Body.Bytes(), &status)
+		return rr.Code, status
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	router, err := NewRouter(ctx, WithRelayURLs(relay.URL), WithLogger(testLog), WithCapabilityCheckInterval(0),
+		WithReadinessChecks(time.Hour, NewExecutionClient(engine.URL)))
+	require.Nil(t, err)
+	code, _ := get(router, pathLiveness)
+	require.Equal(t, http.StatusOK, code)
+	require.Eventually(t, func() bool {
+		code, _ := get(router, pathReadiness)
+		return code == http.StatusOK
+	}, 5*time.Second, 10*time.Millisecond)
+	code, _ = get(router, pathSpecStatus)
+	require.Equal(t, http.StatusOK, code)
+
+	router, err = NewRouter(ctx, WithRelayURLs(relay.URL), WithLogger(testLog), WithCapabilityCheckInterval(0),
+		WithReadinessChecks(time.Hour, NewExecutionClient(down.URL)))
+	require.Nil(t, err)
+	require.Eventually(t, func() bool {
+		_, status := get(router, pathReadiness)
+		return len(status.Engines) == 1 && status.Engines[0].Error != "" && status.Relays[0].Ready
+	}, 5*time.Second, 10*time.Millisecond)
+	code, _ = get(router, pathReadiness)
+	require.Equal(t, http.StatusServiceUnavailable, code, "the engine endpoint is down")
+
+	router, err = NewRouter(ctx, WithRelayURLs(relay.URL), WithLogger(testLog), WithCapabilityCheckInterval(0))
+	require.Nil(t, err)
+	code, _ = get(router, pathReadiness)
+	require.Equal(t, http.StatusNotFound, code, "readiness checks are disabled")
+}
@@ -0,0 +1,243 @@
+package lib
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+// pathProposalInterchange exports the proposal history on GET and imports it on POST
+const pathProposalInterchange = "/mev-boost/v1/proposals/interchange"
+
+// proposalInterchangeVersion is the interchange_format_version of EIP-3076 the proposal interchange follows
+const proposalInterchangeVersion = "5"
+
+// maxProposalInterchangeSize bounds imports, a full delivery log is a few megabytes
+const maxProposalInterchangeSize = 32 << 20
+
+// ProposalInterchange is the proposal history of mev-boost in a format modeled on the EIP-3076 slashing protection
+// interchange format, so operators moving mev-boost to another host keep the record of the payloads it revealed.
+// Instead of the genesis validators root, which mev-boost doesn't know, the network is identified by its genesis time
+// and fork version, and blocks are identified by their block hash instead of their signing root. Besides the revealed
+// payloads it carries the headers returned for proposals and what the equivocation guard saw, so the new host returns
+// the same header to a proposer asking again and still recognizes conflicting requests of recent slots.
+type ProposalInterchange struct {
+	Metadata        ProposalInterchangeMetadata `json:"metadata"`
+	Data            []ProposerProposals         `json:"data"`
+	ProposalHeaders []ProposalHeader            `json:"proposal_headers"`
+	Equivocation    EquivocationHistory         `json:"equivocation"`
+}
+
+// ProposalInterchangeMetadata identifies the format and the network of a proposal interchange
+type ProposalInterchangeMetadata struct {
+	InterchangeFormatVersion string        `json:"interchange_format_version"`
+	GenesisTime              uint64        `json:"genesis_time,string"`
+	GenesisForkVersion       hexutil.Bytes `json:"genesis_fork_version"`
+}
+
+// ProposerProposals are the blocks of a proposer whose payloads mev-boost revealed, oldest first
+type ProposerProposals struct {
+	ProposerIndex uint64          `json:"proposer_index,string"`
+	SignedBlocks  []ProposedBlock `json:"signed_blocks"`
+}
+
+// ProposedBlock is a block whose payload mev-boost revealed to its proposer
+type ProposedBlock struct {
+	Slot         uint64         `json:"slot,string"`
+	BlockHash    common.Hash    `json:"block_hash"`
+	BlockNumber  uint64         `json:"block_number,string"`
+	ParentHash   common.Hash    `json:"parent_hash"`
+	RelayURL     string         `json:"relay_url,omitempty"`
+	FeeRecipient common.Address `json:"fee_recipient"`
+	Value        *big.Int       `json:"value,omitempty"`
+	DeliveredAt  time.Time      `json:"delivered_at"`
+	Reconciled   bool           `json:"reconciled"`
+}
+
+// ProposalHeader is the header returned for a proposal, see ProposalKey
+type ProposalHeader struct {
+	Slot       uint64                        `json:"slot,string"`
+	ParentHash common.Hash                   `json:"parent_hash"`
+	Proposer   string                        `json:"proposer"`
+	Header     *ExecutionPayloadWithTxRootV1 `json:"header"`
+}
+
+// EquivocationHistory is what an EquivocationGuard remembers of recent slots
+type EquivocationHistory struct {
+	Headers []EquivocationHeader `json:"headers"`
+	Blocks  []EquivocationBlock  `json:"blocks"`
+}
+
+// EquivocationHeader is the parent of the first header returned for a slot and proposer
+type EquivocationHeader struct {
+	Slot       uint64      `json:"slot,string"`
+	Proposer   string      `json:"proposer"`
+	ParentHash common.Hash `json:"parent_hash"`
+}
+
+// EquivocationBlock is the first signed block of a slot and proposer index
+type EquivocationBlock struct {
+	Slot          uint64      `json:"slot,string"`
+	ProposerIndex uint64      `json:"proposer_index,string"`
+	ParentRoot    common.Hash `json:"parent_root"`
+	StateRoot     common.Hash `json:"state_root"`
+	BlockHash     common.Hash `json:"block_hash"`
+}
+
+// exportProposals returns the delivery log, the headers returned for proposals and the history of the equivocation
+// guard as proposal interchange
+func (m *RelayService) exportProposals(ctx context.Context) *ProposalInterchange {
+	interchange := &ProposalInterchange{
+		Metadata: ProposalInterchangeMetadata{
+			InterchangeFormatVersion: proposalInterchangeVersion,
+			GenesisTime:              m.chain.GenesisTime,
+			GenesisForkVersion:       m.chain.GenesisForkVersion[:],
+		},
+		Data:            []ProposerProposals{},
+		ProposalHeaders: []ProposalHeader{},
+		Equivocation:    EquivocationHistory{Headers: []EquivocationHeader{}, Blocks: []EquivocationBlock{}},
+	}
+	proposers := make(map[uint64]int) // map[proposer index]position in Data
+	for _, delivery := range m.deliveries.all() {
+		i, ok := proposers[delivery.ProposerIndex]
+		if !ok {
+			i = len(interchange.Data)
+			proposers[delivery.ProposerIndex] = i
+			interchange.Data = append(interchange.Data, ProposerProposals{ProposerIndex: delivery.ProposerIndex})
+		}
+		interchange.Data[i].SignedBlocks = append(interchange.Data[i].SignedBlocks, ProposedBlock{
+			Slot:         delivery.Slot,
+			BlockHash:    delivery.BlockHash,
+			BlockNumber:  delivery.BlockNumber,
+			ParentHash:   delivery.ParentHash,
+			RelayURL:     delivery.RelayURL,
+			FeeRecipient: delivery.FeeRecipient,
+			Value:        delivery.Value,
+			DeliveredAt:  delivery.DeliveredAt,
+			Reconciled:   delivery.Reconciled,
+		})
+	}
+	for entry, header := range m.store.Dump(ctx).ProposalHeaders {
+		key, err := parseProposalKey(entry)
+		if err != nil {
+			m.log.WithField("error", err).Error("could not export proposal header")
+			continue
+		}
+		interchange.ProposalHeaders = append(interchange.ProposalHeaders, ProposalHeader{
+			Slot:       key.Slot,
+			ParentHash: key.ParentHash,
+			Proposer:   key.Proposer,
+			Header:     header,
+		})
+	}
+	sort.Slice(interchange.ProposalHeaders, func(i, j int) bool {
+		return interchange.ProposalHeaders[i].Slot < interchange.ProposalHeaders[j].Slot
+	})
+	if m.equivocation != nil {
+		interchange.Equivocation = m.equivocation.history()
+	}
+	return interchange
+}
+
+// importProposals adds the blocks of a proposal interchange of the same network to the delivery log, its proposal
+// headers to the store and its equivocation history to the guard, and returns how many entries weren't known yet
+func (m *RelayService) importProposals(ctx context.Context, interchange *ProposalInterchange) (int, error) {
+	metadata := interchange.Metadata
+	if metadata.InterchangeFormatVersion != proposalInterchangeVersion {
+		return 0, fmt.Errorf("unsupported interchange format version %q, expected %q", metadata.InterchangeFormatVersion, proposalInterchangeVersion)
+	}
+	if metadata.GenesisTime != m.chain.GenesisTime || string(metadata.GenesisForkVersion) != string(m.chain.GenesisForkVersion[:]) {
+		return 0, fmt.Errorf("interchange is of another network than %s", m.chain.Name)
+	}
+
+	var deliveries []*DeliveredPayload
+	for _, proposer := range interchange.Data {
+		for _, block := range proposer.SignedBlocks {
+			if block.BlockHash == nilHash {
+				return 0, fmt.Errorf("block of slot %d of proposer %d has no block hash", block.Slot, proposer.ProposerIndex)
+			}
+			deliveries = append(deliveries, &DeliveredPayload{
+				Slot:          block.Slot,
+				ProposerIndex: proposer.ProposerIndex,
+				BlockHash:     block.BlockHash,
+				BlockNumber:   block.BlockNumber,
+				ParentHash:    block.ParentHash,
+				RelayURL:      block.RelayURL,
+				FeeRecipient:  block.FeeRecipient,
+				Value:         block.Value,
+				DeliveredAt:   block.DeliveredAt,
+				Reconciled:    block.Reconciled,
+			})
+		}
+	}
+	for _, proposal := range interchange.ProposalHeaders {
+		if proposal.Header == nil || proposal.Header.BlockHash == nilHash {
+			return 0, fmt.Errorf("proposal header of slot %d of proposer %s has no block hash", proposal.Slot, proposal.Proposer)
+		}
+	}
+
+	imported := m.deliveries.merge(deliveries)
+	for _, proposal := range interchange.ProposalHeaders {
+		key := ProposalKey{Slot: proposal.Slot, ParentHash: proposal.ParentHash, Proposer: proposal.Proposer}
+		if m.store.GetProposalHeader(ctx, key) == nil {
+			m.store.SetProposalHeader(ctx, key, proposal.Header)
+			imported++
+		}
+	}
+	if m.equivocation != nil {
+		imported += m.equivocation.restore(interchange.Equivocation)
+	}
+	return imported, nil
+}
+
+// merge adds the deliveries whose block hash isn't known yet, keeping the log ordered by slot, and returns how many were
+// added
+func (l *deliveryLog) merge(deliveries []*DeliveredPayload) int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	known := make(map[common.Hash]bool, len(l.deliveries))
+	for _, delivery := range l.deliveries {
+		known[delivery.BlockHash] = true
+	}
+	added := 0
+	for _, delivery := range deliveries {
+		if known[delivery.BlockHash] {
+			continue
+		}
+		known[delivery.BlockHash] = true
+		l.deliveries = append(l.deliveries, delivery)
+		added++
+	}
+	sort.SliceStable(l.deliveries, func(i, j int) bool { return l.deliveries[i].Slot < l.deliveries[j].Slot })
+	if len(l.deliveries) > maxDeliveredPayloads {
+		l.deliveries = l.deliveries[len(l.deliveries)-maxDeliveredPayloads:]
+	}
+	return added
+}
+
+func (m *RelayService) handleExportProposals(w http.ResponseWriter, r *http.Request) {
+	respondJSON(w, http.StatusOK, m.exportProposals(r.Context()))
+}
+
+func (m *RelayService) handleImportProposals(w http.ResponseWriter, r *http.Request) {
+	interchange := new(ProposalInterchange)
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxProposalInterchangeSize)).Decode(interchange); err != nil {
+		respondJSON(w, http.StatusBadRequest, keymanagerError{"invalid interchange: " + err.Error()})
+		return
+	}
+	imported, err := m.importProposals(r.Context(), interchange)
+	if err != nil {
+		respondJSON(w, http.StatusBadRequest, keymanagerError{err.Error()})
+		return
+	}
+	m.log.WithField("imported", imported).Info("imported proposal history")
+	respondJSON(w, http.StatusOK, map[string]int{"imported": imported})
+}
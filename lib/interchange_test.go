@@ -0,0 +1,127 @@
+package lib
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProposalInterchange(t *testing.T) {
+	old, err := newRelayService(WithRelayURLs("http://a.example"), WithStore(NewStore()), WithLogger(testLog), WithEquivocationGuard(NewEquivocationGuard(true)))
+	require.Nil(t, err)
+	proposal := ProposalKey{Slot: 3, ParentHash: common.HexToHash("0x03"), Proposer: "0xabcd"}
+	old.store.SetProposalHeader(context.Background(), proposal, &ExecutionPayloadWithTxRootV1{BlockHash: common.HexToHash("0x33"), ParentHash: proposal.ParentHash, BaseFeePerGas: big.NewInt(1), FeeRecipientDiff: big.NewInt(1)})
+	old.equivocation.headerReturned(proposal)
+	block := &BlindedBeaconBlock{Slot: 3, ProposerIndex: 101, ParentRoot: common.HexToHash("0x01"), StateRoot: common.HexToHash("0x02")}
+	old.equivocation.blockRevealed(block, common.HexToHash("0x33"))
+	deliveredAt := time.Date(2022, 6, 1, 12, 0, 0, 0, time.UTC)
+	for slot := uint64(1); slot <= 3; slot++ {
+		old.deliveries.add(&DeliveredPayload{
+			Slot:          slot,
+			ProposerIndex: 100 + slot%2,
+			BlockHash:     common.BigToHash(new(big.Int).SetUint64(slot)),
+			RelayURL:      "http://a.example",
+			FeeRecipient:  common.HexToAddress("0x01"),
+			Value:         big.NewInt(int64(slot)),
+			DeliveredAt:   deliveredAt,
+			Reconciled:    slot == 1,
+		})
+	}
+	interchange := old.exportProposals(context.Background())
+	require.Equal(t, "5", interchange.Metadata.InterchangeFormatVersion)
+	require.Len(t, interchange.Data, 2)
+	require.Equal(t, uint64(101), interchange.Data[0].ProposerIndex)
+	require.Len(t, interchange.Data[0].SignedBlocks, 2)
+	require.Equal(t, []ProposalHeader{{Slot: 3, ParentHash: proposal.ParentHash, Proposer: "0xabcd", Header: old.store.GetProposalHeader(context.Background(), proposal)}}, interchange.ProposalHeaders)
+	require.Equal(t, []EquivocationHeader{{Slot: 3, Proposer: "0xabcd", ParentHash: proposal.ParentHash}}, interchange.Equivocation.Headers)
+	require.Equal(t, []EquivocationBlock{{Slot: 3, ProposerIndex: 101, ParentRoot: block.ParentRoot, StateRoot: block.StateRoot, BlockHash: common.HexToHash("0x33")}}, interchange.Equivocation.Blocks)
+
+	// the export survives a round trip through json
+	body, err := json.Marshal(interchange)
+	require.Nil(t, err)
+	interchange = new(ProposalInterchange)
+	require.Nil(t, json.Unmarshal(body, interchange))
+
+	migrated, err := newRelayService(WithRelayURLs("http://a.example"), WithStore(NewStore()), WithLogger(testLog), WithEquivocationGuard(NewEquivocationGuard(true)))
+	require.Nil(t, err)
+	migrated.deliveries.add(&DeliveredPayload{Slot: 2, ProposerIndex: 100, BlockHash: common.BigToHash(big.NewInt(2))})
+	migrated.deliveries.add(&DeliveredPayload{Slot: 4, ProposerIndex: 100, BlockHash: common.BigToHash(big.NewInt(4))})
+
+	imported, err := migrated.importProposals(context.Background(), interchange)
+	require.Nil(t, err)
+	require.Equal(t, 5, imported, "two deliveries, a proposal header, an equivocation header and block")
+	var slots []uint64
+	for _, delivery := range migrated.deliveries.all() {
+		slots = append(slots, delivery.Slot)
+	}
+	require.Equal(t, []uint64{1, 2, 3, 4}, slots)
+	require.Equal(t, old.deliveries.all()[0], migrated.deliveries.all()[0])
+	require.Equal(t, common.HexToHash("0x33"), migrated.store.GetProposalHeader(context.Background(), proposal).BlockHash)
+
+	// the guard of the new host refuses what conflicts with the requests the old host saw
+	require.Error(t, migrated.equivocation.checkHeader(ProposalKey{Slot: 3, ParentHash: common.HexToHash("0x04"), Proposer: "0xabcd"}, testLog))
+	conflicting := *block
+	conflicting.StateRoot = common.HexToHash("0x05")
+	require.Error(t, migrated.equivocation.checkBlock(&conflicting, common.HexToHash("0x55"), testLog))
+
+	imported, err = migrated.importProposals(context.Background(), interchange)
+	require.Nil(t, err)
+	require.Equal(t, 0, imported)
+
+	interchange.ProposalHeaders = append(interchange.ProposalHeaders, ProposalHeader{Slot: 4})
+	_, err = migrated.importProposals(context.Background(), interchange)
+	require.Error(t, err)
+
+	interchange.Metadata.GenesisTime++
+	_, err = migrated.importProposals(context.Background(), interchange)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "another network")
+}
+
+func TestRouter_ProposalInterchange(t *testing.T) {
+	router, err := NewRouter(context.Background(), WithRelayURLs("http://a.example"), WithStore(NewStore()), WithLogger(testLog), WithAdminAPI("secret"))
+	require.Nil(t, err)
+
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/mev-boost/v1/proposals/interchange", nil))
+	require.Equal(t, http.StatusOK, rr.Code)
+	interchange := new(ProposalInterchange)
+	require.Nil(t, json.Unmarshal(rr.Body.Bytes(), interchange))
+	require.Equal(t, uint64(1606824023), interchange.Metadata.GenesisTime)
+
+	interchange.Data = []ProposerProposals{{ProposerIndex: 7, SignedBlocks: []ProposedBlock{{Slot: 10, BlockHash: common.HexToHash("0x0a")}}}}
+	body, err := json.Marshal(interchange)
+	require.Nil(t, err)
+
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/mev-boost/v1/proposals/interchange", bytes.NewReader(body)))
+	require.Equal(t, http.StatusUnauthorized, rr.Code)
+
+	req := httptest.NewRequest(http.MethodPost, "/mev-boost/v1/proposals/interchange", bytes.NewReader(body))
+	req.Header.Set("Authorization", "Bearer secret")
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	require.Equal(t, http.StatusOK, rr.Code)
+	require.JSONEq(t, `{"imported": 1}`, rr.Body.String())
+
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/mev-boost/v1/deliveries", nil))
+	require.Contains(t, rr.Body.String(), `"proposerIndex":"7"`)
+
+	// without the admin API there is no way to import
+	router, err = NewRouter(context.Background(), WithRelayURLs("http://a.example"), WithStore(NewStore()), WithLogger(testLog), WithAdminToken("secret"))
+	require.Nil(t, err)
+	req = httptest.NewRequest(http.MethodPost, "/mev-boost/v1/proposals/interchange", bytes.NewReader(body))
+	req.Header.Set("Authorization", "Bearer secret")
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	require.Equal(t, http.StatusMethodNotAllowed, rr.Code)
+}
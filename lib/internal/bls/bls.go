@@ -0,0 +1,284 @@
+// Package bls decodes and hashes to the points of BLS12-381 as the consensus specs encode them, on top of the curve
+// arithmetic of go-ethereum. The subgroup checks, map_to_curve, cofactor clearing and pairing all come from
+// go-ethereum's crypto/bls12381; only the compressed point encoding and hash_to_field are implemented here, as that
+// package has neither and blst or herumi aren't dependencies of this module.
+package bls
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/crypto/bls12381"
+)
+
+// SignatureDST is the domain separation tag of the proof-of-possession BLS scheme used by the consensus specs
+var SignatureDST = []byte("BLS_SIG_BLS12381G2_XMD:SHA-256_SSWU_RO_POP_")
+
+var (
+	modulus, _ = new(big.Int).SetString("1a0111ea397fe69a4b1ba7b6434bacd764774b84f38512bf6730d2a0f6b0f6241eabfffeb153ffffb9feffffffffaaab", 16)
+	// sqrtExp is (p+1)/4, a square root of x in Fp is x^sqrtExp as p = 3 mod 4
+	sqrtExp = new(big.Int).Rsh(new(big.Int).Add(modulus, big.NewInt(1)), 2)
+	// halfModulus is (p-1)/2, y is the larger of the two square roots if y > halfModulus
+	halfModulus = new(big.Int).Rsh(modulus, 1)
+)
+
+// DecompressG1 decodes a point in the compressed zcash encoding and checks that it is in G1
+func DecompressG1(g1 *bls12381.G1, in []byte) (*bls12381.PointG1, error) {
+	if len(in) != 48 {
+		return nil, fmt.Errorf("expected 48 bytes, got %d", len(in))
+	}
+	infinity, larger, x, err := decodeCompressedFlags(in)
+	if err != nil {
+		return nil, err
+	}
+	if infinity {
+		if x.Sign() != 0 || larger {
+			return nil, errors.New("invalid point at infinity")
+		}
+		return g1.Zero(), nil
+	}
+	if x.Cmp(modulus) >= 0 {
+		return nil, errors.New("x is not a field element")
+	}
+
+	// y^2 = x^3 + 4
+	rhs := new(big.Int).Exp(x, big.NewInt(3), modulus)
+	rhs.Add(rhs, big.NewInt(4)).Mod(rhs, modulus)
+	y := new(big.Int).Exp(rhs, sqrtExp, modulus)
+	if new(big.Int).Exp(y, big.NewInt(2), modulus).Cmp(rhs) != 0 {
+		return nil, errors.New("point is not on curve")
+	}
+	if (y.Cmp(halfModulus) > 0) != larger {
+		y.Sub(modulus, y)
+	}
+
+	uncompressed := make([]byte, 96)
+	x.FillBytes(uncompressed[:48])
+	y.FillBytes(uncompressed[48:])
+	p, err := g1.FromBytes(uncompressed)
+	if err != nil {
+		return nil, err
+	}
+	if !g1.InCorrectSubgroup(p) {
+		return nil, errors.New("point is not in the subgroup")
+	}
+	return p, nil
+}
+
+// DecompressG2 decodes a point in the compressed zcash encoding and checks that it is in G2
+func DecompressG2(g2 *bls12381.G2, in []byte) (*bls12381.PointG2, error) {
+	if len(in) != 96 {
+		return nil, fmt.Errorf("expected 96 bytes, got %d", len(in))
+	}
+	infinity, larger, x1, err := decodeCompressedFlags(in[:48])
+	if err != nil {
+		return nil, err
+	}
+	x := fp2{new(big.Int).SetBytes(in[48:]), x1}
+	if infinity {
+		if x[0].Sign() != 0 || x[1].Sign() != 0 || larger {
+			return nil, errors.New("invalid point at infinity")
+		}
+		return g2.Zero(), nil
+	}
+	if x[0].Cmp(modulus) >= 0 || x[1].Cmp(modulus) >= 0 {
+		return nil, errors.New("x is not a field element")
+	}
+
+	// y^2 = x^3 + 4(1+u)
+	rhs := x.mul(x).mul(x).add(fp2{big.NewInt(4), big.NewInt(4)})
+	y, ok := rhs.sqrt()
+	if !ok {
+		return nil, errors.New("point is not on curve")
+	}
+	if y.larger() != larger {
+		y = y.neg()
+	}
+
+	uncompressed := make([]byte, 192)
+	x[1].FillBytes(uncompressed[:48])
+	x[0].FillBytes(uncompressed[48:96])
+	y[1].FillBytes(uncompressed[96:144])
+	y[0].FillBytes(uncompressed[144:])
+	p, err := g2.FromBytes(uncompressed)
+	if err != nil {
+		return nil, err
+	}
+	if !g2.InCorrectSubgroup(p) {
+		return nil, errors.New("point is not in the subgroup")
+	}
+	return p, nil
+}
+
+// CompressG1 encodes p in the compressed zcash encoding
+func CompressG1(g1 *bls12381.G1, p *bls12381.PointG1) []byte {
+	if g1.IsZero(p) {
+		out := make([]byte, 48)
+		out[0] = 0xc0
+		return out
+	}
+	uncompressed := g1.ToBytes(p)
+	out := append([]byte{}, uncompressed[:48]...)
+	out[0] |= 0x80
+	if new(big.Int).SetBytes(uncompressed[48:]).Cmp(halfModulus) > 0 {
+		out[0] |= 0x20
+	}
+	return out
+}
+
+// CompressG2 encodes p in the compressed zcash encoding
+func CompressG2(g2 *bls12381.G2, p *bls12381.PointG2) []byte {
+	if g2.IsZero(p) {
+		out := make([]byte, 96)
+		out[0] = 0xc0
+		return out
+	}
+	uncompressed := g2.ToBytes(p) // x.c1, x.c0, y.c1, y.c0
+	out := append([]byte{}, uncompressed[:96]...)
+	out[0] |= 0x80
+	if (fp2{new(big.Int).SetBytes(uncompressed[144:]), new(big.Int).SetBytes(uncompressed[96:144])}).larger() {
+		out[0] |= 0x20
+	}
+	return out
+}
+
+// decodeCompressedFlags splits the first 48 bytes of a compressed point into its flags and x coordinate
+func decodeCompressedFlags(in []byte) (infinity bool, larger bool, x *big.Int, err error) {
+	if in[0]&0x80 == 0 {
+		return false, false, nil, errors.New("point is not compressed")
+	}
+	infinity = in[0]&0x40 != 0
+	larger = in[0]&0x20 != 0
+	xBytes := make([]byte, 48)
+	copy(xBytes, in[:48])
+	xBytes[0] &= 0x1f
+	return infinity, larger, new(big.Int).SetBytes(xBytes), nil
+}
+
+// HashToG2 implements hash_to_curve of the BLS12381G2_XMD:SHA-256_SSWU_RO_ suite, see RFC 9380
+func HashToG2(g2 *bls12381.G2, msg, dst []byte) (*bls12381.PointG2, error) {
+	uniform, err := ExpandMessageXMD(msg, dst, 256)
+	if err != nil {
+		return nil, err
+	}
+
+	// hash_to_field with L = 64 yields two Fp2 elements. MapToCurve takes them as c1 || c0 and clears the cofactor,
+	// which is the same as clearing it once for the sum, as both are multiplications by the same scalar.
+	q := make([]*bls12381.PointG2, 2)
+	for i := range q {
+		in := make([]byte, 96)
+		for j := 0; j < 2; j++ {
+			offset := 64 * (j + 2*i)
+			e := new(big.Int).SetBytes(uniform[offset : offset+64])
+			e.Mod(e, modulus)
+			e.FillBytes(in[48*(1-j) : 48*(2-j)])
+		}
+		if q[i], err = g2.MapToCurve(in); err != nil {
+			return nil, err
+		}
+	}
+	return g2.Add(g2.New(), q[0], q[1]), nil
+}
+
+// ExpandMessageXMD implements expand_message_xmd of RFC 9380 with SHA-256
+func ExpandMessageXMD(msg, dst []byte, length int) ([]byte, error) {
+	ell := (length + sha256.Size - 1) / sha256.Size
+	if ell > 255 || len(dst) > 255 {
+		return nil, errors.New("invalid expand_message_xmd parameters")
+	}
+	dstPrime := append(append([]byte{}, dst...), byte(len(dst)))
+
+	h := sha256.New()
+	h.Write(make([]byte, 64)) // Z_pad of the SHA-256 block size
+	h.Write(msg)
+	h.Write([]byte{byte(length >> 8), byte(length), 0})
+	h.Write(dstPrime)
+	b0 := h.Sum(nil)
+
+	h.Reset()
+	h.Write(b0)
+	h.Write([]byte{1})
+	h.Write(dstPrime)
+	bi := h.Sum(nil)
+
+	out := make([]byte, 0, ell*sha256.Size)
+	out = append(out, bi...)
+	for i := 2; i <= ell; i++ {
+		xored := make([]byte, sha256.Size)
+		for j := range xored {
+			xored[j] = b0[j] ^ bi[j]
+		}
+		h.Reset()
+		h.Write(xored)
+		h.Write([]byte{byte(i)})
+		h.Write(dstPrime)
+		bi = h.Sum(nil)
+		out = append(out, bi...)
+	}
+	return out[:length], nil
+}
+
+// fp2 is an element c0 + c1*u of the quadratic extension field with u^2 = -1
+type fp2 [2]*big.Int
+
+func (a fp2) add(b fp2) fp2 {
+	return fp2{
+		new(big.Int).Mod(new(big.Int).Add(a[0], b[0]), modulus),
+		new(big.Int).Mod(new(big.Int).Add(a[1], b[1]), modulus),
+	}
+}
+
+func (a fp2) mul(b fp2) fp2 {
+	c0 := new(big.Int).Sub(new(big.Int).Mul(a[0], b[0]), new(big.Int).Mul(a[1], b[1]))
+	c1 := new(big.Int).Add(new(big.Int).Mul(a[0], b[1]), new(big.Int).Mul(a[1], b[0]))
+	return fp2{c0.Mod(c0, modulus), c1.Mod(c1, modulus)}
+}
+
+func (a fp2) neg() fp2 {
+	return fp2{
+		new(big.Int).Mod(new(big.Int).Neg(a[0]), modulus),
+		new(big.Int).Mod(new(big.Int).Neg(a[1]), modulus),
+	}
+}
+
+func (a fp2) exp(e *big.Int) fp2 {
+	result := fp2{big.NewInt(1), big.NewInt(0)}
+	for i := e.BitLen() - 1; i >= 0; i-- {
+		result = result.mul(result)
+		if e.Bit(i) == 1 {
+			result = result.mul(a)
+		}
+	}
+	return result
+}
+
+func (a fp2) equal(b fp2) bool {
+	return a[0].Cmp(b[0]) == 0 && a[1].Cmp(b[1]) == 0
+}
+
+// larger reports whether a is lexicographically larger than its negation, comparing c1 first
+func (a fp2) larger() bool {
+	if a[1].Sign() != 0 {
+		return a[1].Cmp(halfModulus) > 0
+	}
+	return a[0].Cmp(halfModulus) > 0
+}
+
+// sqrt returns a square root of a, using algorithm 9 of https://eprint.iacr.org/2012/685 for p = 3 mod 4
+func (a fp2) sqrt() (fp2, bool) {
+	a1 := a.exp(new(big.Int).Rsh(new(big.Int).Sub(modulus, big.NewInt(3)), 2))
+	alpha := a1.mul(a1).mul(a)
+	x0 := a1.mul(a)
+
+	var x fp2
+	minusOne := fp2{new(big.Int).Sub(modulus, big.NewInt(1)), big.NewInt(0)}
+	if alpha.equal(minusOne) {
+		x = fp2{big.NewInt(0), big.NewInt(1)}.mul(x0)
+	} else {
+		b := alpha.add(fp2{big.NewInt(1), big.NewInt(0)}).exp(halfModulus)
+		x = b.mul(x0)
+	}
+	return x, x.mul(x).equal(a)
+}
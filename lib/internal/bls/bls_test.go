@@ -0,0 +1,124 @@
+package bls
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto/bls12381"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExpandMessageXMD(t *testing.T) {
+	// expand_message_xmd(SHA-256) test vectors of RFC 9380 appendix K.1
+	dst := []byte("QUUX-V01-CS02-with-expander-SHA256-128")
+	for _, tt := range []struct {
+		msg    string
+		length int
+		want   string
+	}{
+		{"", 0x20, "0x68a985b87eb6b46952128911f2a4412bbc302a9d759667f87f7a21d803f07235"},
+		{"abc", 0x20, "0xd8ccab23b5985ccea865c6c97b6e5b8350e794e603b4b97902f53a8a0d605615"},
+		{"", 0x80, "0xaf84c27ccfd45d41914fdff5df25293e221afc53d8ad2ac06d5e3e29485dadbee0d121587713a3e0dd4d5e69e93eb7cd4f5df4cd103e188cf60cb02edc3edf18eda8576c412b18ffb658e3dd6ec849469b979d444cf7b26911a08e63cf31f9dcc541708d3491184472c2c29bb749d4286b004ceb5ee6b9a7fa5b646c993f0ced"},
+		{"abc", 0x80, "0xabba86a6129e366fc877aab32fc4ffc70120d8996c88aee2fe4b32d6c7b6437a647e6c3163d40b76a73cf6a5674ef1d890f95b664ee0afa5359a5c4e07985635bbecbac65d747d3d2da7ec2b8221b17b0ca9dc8a1ac1c07ea6a1e60583e2cb00058e77b7b72a298425cd1b941ad4ec65e8afc50303a22c0f99b0509b4c895f40"},
+	} {
+		out, err := ExpandMessageXMD([]byte(tt.msg), dst, tt.length)
+		require.Nil(t, err)
+		require.Equal(t, common.FromHex(tt.want), out, "msg %q, length %d", tt.msg, tt.length)
+	}
+}
+
+func TestHashToG2(t *testing.T) {
+	// BLS12381G2_XMD:SHA-256_SSWU_RO_ test vectors of RFC 9380 appendix J.10.1, coordinates as c0, c1
+	dst := []byte("QUUX-V01-CS02-with-BLS12381G2_XMD:SHA-256_SSWU_RO_")
+	for _, tt := range []struct {
+		msg  string
+		x, y [2]string
+	}{
+		{
+			"",
+			[2]string{"0x0141ebfbdca40eb85b87142e130ab689c673cf60f1a3e98d69335266f30d9b8d4ac44c1038e9dcdd5393faf5c41fb78a", "0x05cb8437535e20ecffaef7752baddf98034139c38452458baeefab379ba13dff5bf5dd71b72418717047f5b0f37da03d"},
+			[2]string{"0x0503921d7f6a12805e72940b963c0cf3471c7b2a524950ca195d11062ee75ec076daf2d4bc358c4b190c0c98064fdd92", "0x12424ac32561493f3fe3c260708a12b7c620e7be00099a974e259ddc7d1f6395c3c811cdd19f1e8dbf3e9ecfdcbab8d6"},
+		},
+		{
+			"abc",
+			[2]string{"0x02c2d18e033b960562aae3cab37a27ce00d80ccd5ba4b7fe0e7a210245129dbec7780ccc7954725f4168aff2787776e6", "0x139cddbccdc5e91b9623efd38c49f81a6f83f175e80b06fc374de9eb4b41dfe4ca3a230ed250fbe3a2acf73a41177fd8"},
+			[2]string{"0x1787327b68159716a37440985269cf584bcb1e621d3a7202be6ea05c4cfe244aeb197642555a0645fb87bf7466b2ba48", "0x00aa65dae3c8d732d10ecd2c50f8a1baf3001578f71c694e03866e9f3d49ac1e1ce70dd94a733534f106d4cec0eddd16"},
+		},
+	} {
+		g2 := bls12381.NewG2()
+		p, err := HashToG2(g2, []byte(tt.msg), dst)
+		require.Nil(t, err)
+		out := g2.ToBytes(p) // x.c1, x.c0, y.c1, y.c0
+		require.Equal(t, common.FromHex(tt.x[1]+tt.x[0][2:]+tt.y[1][2:]+tt.y[0][2:]), out, "msg %q", tt.msg)
+	}
+}
+
+func TestDecompressG1(t *testing.T) {
+	g1 := bls12381.NewG1()
+	generator := "0x97f1d3a73197d7942695638c4fa9ac0fc3688c4f9774b905a14e3a3f171bac586c55e83ff97a1aeffb3af00adb22c6bb"
+	p, err := DecompressG1(g1, common.FromHex(generator))
+	require.Nil(t, err)
+	require.True(t, g1.Equal(p, g1.One()))
+	p, err = DecompressG1(g1, common.FromHex("0xb7"+generator[4:]))
+	require.Nil(t, err)
+	require.True(t, g1.Equal(p, g1.Neg(g1.New(), g1.One())), "the sign flag selects the larger y")
+	p, err = DecompressG1(g1, common.FromHex("0xc0"+strings.Repeat("00", 47)))
+	require.Nil(t, err)
+	require.True(t, g1.IsZero(p))
+	require.Equal(t, common.FromHex(generator), CompressG1(g1, g1.One()))
+	require.Equal(t, common.FromHex("0xb7"+generator[4:]), CompressG1(g1, g1.Neg(g1.New(), g1.One())))
+	require.Equal(t, common.FromHex("0xc0"+strings.Repeat("00", 47)), CompressG1(g1, g1.Zero()))
+
+	// the failing cases of the deserialization_G1 tests of the consensus specs
+	for name, in := range map[string]string{
+		"too few bytes":                  generator[:len(generator)-2],
+		"too many bytes":                 generator + "00",
+		"infinity with sign flag":        "0xe0" + strings.Repeat("00", 47),
+		"infinity with nonzero x":        "0xc0" + strings.Repeat("00", 46) + "01",
+		"infinity flag without compress": "0x40" + strings.Repeat("00", 47),
+		"not compressed":                 "0x17" + generator[4:],
+		"x equal to the modulus":         "0x9a0111ea397fe69a4b1ba7b6434bacd764774b84f38512bf6730d2a0f6b0f6241eabfffeb153ffffb9feffffffffaaab",
+		"x greater than the modulus":     "0x9a0111ea397fe69a4b1ba7b6434bacd764774b84f38512bf6730d2a0f6b0f6241eabfffeb153ffffb9feffffffffaaac",
+		"not on the curve":               "0x80" + strings.Repeat("00", 46) + "01",
+		"not in the subgroup":            "0x80" + strings.Repeat("00", 47),
+	} {
+		_, err := DecompressG1(g1, common.FromHex(in))
+		require.Error(t, err, name)
+	}
+}
+
+func TestDecompressG2(t *testing.T) {
+	g2 := bls12381.NewG2()
+	generator := "0x93e02b6052719f607dacd3a088274f65596bd0d09920b61ab5da61bbdc7f5049334cf11213945d57e5ac7d055d042b7e024aa2b2f08f0a91260805272dc51051c6e47ad4fa403b02b4510b647ae3d1770bac0326a805bbefd48056c8c121bdb8"
+	p, err := DecompressG2(g2, common.FromHex(generator))
+	require.Nil(t, err)
+	require.True(t, g2.Equal(p, g2.One()))
+	p, err = DecompressG2(g2, common.FromHex("0xb3"+generator[4:]))
+	require.Nil(t, err)
+	require.True(t, g2.Equal(p, g2.Neg(g2.New(), g2.One())), "the sign flag selects the larger y")
+	p, err = DecompressG2(g2, common.FromHex("0xc0"+strings.Repeat("00", 95)))
+	require.Nil(t, err)
+	require.True(t, g2.IsZero(p))
+	require.Equal(t, common.FromHex(generator), CompressG2(g2, g2.One()))
+	require.Equal(t, common.FromHex("0xb3"+generator[4:]), CompressG2(g2, g2.Neg(g2.New(), g2.One())))
+	require.Equal(t, common.FromHex("0xc0"+strings.Repeat("00", 95)), CompressG2(g2, g2.Zero()))
+
+	// the failing cases of the deserialization_G2 tests of the consensus specs
+	modulus := "9a0111ea397fe69a4b1ba7b6434bacd764774b84f38512bf6730d2a0f6b0f6241eabfffeb153ffffb9feffffffffaaab"
+	for name, in := range map[string]string{
+		"too few bytes":                  generator[:len(generator)-2],
+		"too many bytes":                 generator + "00",
+		"infinity with sign flag":        "0xe0" + strings.Repeat("00", 95),
+		"infinity with nonzero x":        "0xc0" + strings.Repeat("00", 94) + "01",
+		"infinity flag without compress": "0x40" + strings.Repeat("00", 95),
+		"not compressed":                 "0x13" + generator[4:],
+		"x.c1 equal to the modulus":      "0x" + modulus + strings.Repeat("00", 48),
+		"x.c0 equal to the modulus":      "0x80" + strings.Repeat("00", 47) + "1a" + modulus[2:],
+		"not on the curve":               "0x80" + strings.Repeat("00", 95),
+		"not in the subgroup":            "0x80" + strings.Repeat("00", 94) + "02",
+	} {
+		_, err := DecompressG2(g2, common.FromHex(in))
+		require.Error(t, err, name)
+	}
+}
@@ -0,0 +1,24 @@
+// Package blstest signs like validators and relays do, for tests and mocks of mev-boost. Secret keys aren't protected,
+// it must not be used outside of tests.
+package blstest
+
+import (
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/crypto/bls12381"
+	"github.com/flashbots/mev-boost/lib/internal/bls"
+)
+
+// Sign signs signingRoot with secretKey, a scalar of BLS12-381, and returns the compressed pubkey and signature as
+// lib.VerifySignature expects them
+func Sign(secretKey *big.Int, signingRoot [32]byte) (pubkey []byte, signature []byte, err error) {
+	g1, g2 := bls12381.NewG1(), bls12381.NewG2()
+	pubkey = bls.CompressG1(g1, g1.MulScalar(g1.New(), g1.One(), secretKey))
+
+	msg, err := bls.HashToG2(g2, signingRoot[:], bls.SignatureDST)
+	if err != nil {
+		return nil, nil, err
+	}
+	signature = bls.CompressG2(g2, g2.MulScalar(g2.New(), msg, secretKey))
+	return pubkey, signature, nil
+}
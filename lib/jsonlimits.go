@@ -0,0 +1,113 @@
+package lib
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// jsonLimits bound the nesting depth and the number of tokens of a JSON document. They're checked by a scan of the raw
+// bytes before decoding, so pathological documents are rejected before they reach encoding/json.
+type jsonLimits struct {
+	maxDepth  int
+	maxTokens int
+}
+
+// defaultJSONLimits leave ample room for payloads with thousands of transactions
+var defaultJSONLimits = jsonLimits{maxDepth: 32, maxTokens: 100000}
+
+// jsonScanner checks a JSON document against jsonLimits as it's read. It only tracks strings and nesting, the syntax
+// is left to the decoder.
+type jsonScanner struct {
+	limits   jsonLimits
+	depth    int
+	tokens   int
+	inString bool
+	escaped  bool
+	inScalar bool // inside a number or literal
+}
+
+func (s *jsonScanner) scan(p []byte) error {
+	for _, c := range p {
+		if s.inString {
+			switch {
+			case s.escaped:
+				s.escaped = false
+			case c == '\\':
+				s.escaped = true
+			case c == '"':
+				s.inString = false
+			}
+			continue
+		}
+
+		switch c {
+		case '{', '[':
+			s.inScalar = false
+			s.depth++
+			if s.limits.maxDepth > 0 && s.depth > s.limits.maxDepth {
+				return fmt.Errorf("%w: JSON nested deeper than %d levels", ErrValidationFailed, s.limits.maxDepth)
+			}
+			if err := s.token(); err != nil {
+				return err
+			}
+		case '}', ']':
+			s.inScalar = false
+			s.depth--
+		case '"':
+			s.inScalar = false
+			s.inString = true
+			if err := s.token(); err != nil {
+				return err
+			}
+		case ' ', '\t', '\n', '\r', ',', ':':
+			s.inScalar = false
+		default:
+			if !s.inScalar {
+				s.inScalar = true
+				if err := s.token(); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func (s *jsonScanner) token() error {
+	s.tokens++
+	if s.limits.maxTokens > 0 && s.tokens > s.limits.maxTokens {
+		return fmt.Errorf("%w: JSON has more than %d tokens", ErrValidationFailed, s.limits.maxTokens)
+	}
+	return nil
+}
+
+// jsonLimitReader fails reads once the JSON read so far exceeds its limits
+type jsonLimitReader struct {
+	r       io.Reader
+	scanner jsonScanner
+}
+
+func newJSONLimitReader(r io.Reader, limits jsonLimits) io.Reader {
+	return &jsonLimitReader{r: r, scanner: jsonScanner{limits: limits}}
+}
+
+func (j *jsonLimitReader) Read(p []byte) (int, error) {
+	n, err := j.r.Read(p)
+	if scanErr := j.scanner.scan(p[:n]); scanErr != nil {
+		return n, scanErr
+	}
+	return n, err
+}
+
+// jsonLimitHandler applies limits to the JSON bodies of incoming requests, the rpc server answers a violation with a
+// parse error
+func jsonLimitHandler(limits jsonLimits, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.Body = struct {
+			io.Reader
+			io.Closer
+		}{newJSONLimitReader(r.Body, limits), r.Body}
+		next.ServeHTTP(w, r)
+	})
+}
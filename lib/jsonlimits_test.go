@@ -0,0 +1,70 @@
+package lib
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestJSONLimitReader(t *testing.T) {
+	limits := jsonLimits{maxDepth: 3, maxTokens: 10}
+	tests := []struct {
+		name    string
+		json    string
+		wantErr bool
+	}{
+		{"within limits", `{"a":[1,true,"x"]}`, false},
+		{"brackets in strings", `{"a":"[[[[{{{{"}`, false},
+		{"escaped quotes", `{"a":"\"[[[[\\"}`, false},
+		{"too deep", `{"a":[[[1]]]}`, true},
+		{"too many tokens", `[1,2,3,4,5,6,7,8,9,10]`, true},
+		{"depth resets", `[[[1]],[[2]]]`, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// read byte by byte, so state carries across reads
+			_, err := ioutil.ReadAll(newJSONLimitReader(&oneByteReader{strings.NewReader(tt.json)}, limits))
+			require.Equal(t, tt.wantErr, err != nil, err)
+			if err != nil {
+				require.ErrorIs(t, err, ErrValidationFailed)
+			}
+		})
+	}
+}
+
+// oneByteReader reads a single byte at a time
+type oneByteReader struct {
+	r *strings.Reader
+}
+
+func (r *oneByteReader) Read(p []byte) (int, error) {
+	if len(p) == 0 {
+		return 0, nil
+	}
+	return r.r.Read(p[:1])
+}
+
+func TestRouter_JSONLimits(t *testing.T) {
+	deep := strings.Repeat("[", 40) + strings.Repeat("]", 40)
+	relay := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"id":"1","result":` + deep + `}`))
+	}))
+	defer relay.Close()
+
+	router, err := NewRouter(context.Background(), WithRelayURLs(relay.URL), WithLogger(testLog), WithCapabilityCheckInterval(0))
+	require.Nil(t, err)
+	req := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader([]byte(`{"id":1,"method":"engine_forkchoiceUpdatedV1","params":[`+deep+`]}`)))
+	req.Header.Set("Content-Type", "application/json")
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	require.Equal(t, http.StatusBadRequest, rr.Code)
+
+	_, err = makeRequest(context.Background(), &httpClient, relay.URL, methodForkchoiceUpdated, []interface{}{}, defaultResponseLimit)
+	require.ErrorIs(t, err, ErrValidationFailed)
+}
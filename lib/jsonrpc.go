@@ -20,6 +20,7 @@ type rpcResponse struct {
 	JSONRPC string          `json:"jsonrpc"`
 	Result  json.RawMessage `json:"result"`
 	Error   *rpcError       `json:"error"`
+	SSZ     []byte          `json:"-"` // the result, if the relay answered with SSZ
 }
 
 type rpcRequest struct {
@@ -0,0 +1,115 @@
+package lib
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// jwtMaxIssuedAtDrift is how far the issued at claim of an incoming JWT may be from the local time, per the engine API
+const jwtMaxIssuedAtDrift = 60 * time.Second
+
+// jwtHeader is the base64url encoded header {"alg":"HS256","typ":"JWT"}
+var jwtHeader = base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"HS256","typ":"JWT"}`))
+
+// LoadJWTSecret reads the hex encoded 256-bit JWT secret a consensus client shares with its execution client
+func LoadJWTSecret(path string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	secret, err := hex.DecodeString(strings.TrimPrefix(strings.TrimSpace(string(data)), "0x"))
+	if err != nil {
+		return nil, fmt.Errorf("JWT secret of %s isn't hex encoded: %w", path, err)
+	}
+	if len(secret) != 32 {
+		return nil, fmt.Errorf("JWT secret of %s has %d bytes, expected 32", path, len(secret))
+	}
+	return secret, nil
+}
+
+// newJWT returns an HS256 token signed with secret that only claims its issued at time, as the engine API expects
+func newJWT(secret []byte, issuedAt time.Time) string {
+	claims := base64.RawURLEncoding.EncodeToString([]byte(fmt.Sprintf(`{"iat":%d}`, issuedAt.Unix())))
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(jwtHeader + "." + claims))
+	return jwtHeader + "." + claims + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// verifyJWT checks that token is an HS256 token signed with secret, issued within jwtMaxIssuedAtDrift of now
+func verifyJWT(secret []byte, token string) error {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return errors.New("malformed token")
+	}
+	var header struct {
+		Alg string `json:"alg"`
+	}
+	if err := decodeJWTPart(parts[0], &header); err != nil {
+		return fmt.Errorf("malformed header: %w", err)
+	}
+	if header.Alg != "HS256" {
+		return fmt.Errorf("unsupported algorithm %q, expected HS256", header.Alg)
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(parts[0] + "." + parts[1]))
+	if err != nil || !hmac.Equal(signature, mac.Sum(nil)) {
+		return errors.New("invalid signature")
+	}
+
+	var claims struct {
+		IssuedAt *int64 `json:"iat"`
+	}
+	if err := decodeJWTPart(parts[1], &claims); err != nil {
+		return fmt.Errorf("malformed claims: %w", err)
+	}
+	if claims.IssuedAt == nil {
+		return errors.New("missing iat claim")
+	}
+	if drift := now().Sub(time.Unix(*claims.IssuedAt, 0)); drift > jwtMaxIssuedAtDrift || drift < -jwtMaxIssuedAtDrift {
+		return fmt.Errorf("issued %s from now, more than %s", drift.Round(time.Second), jwtMaxIssuedAtDrift)
+	}
+	return nil
+}
+
+func decodeJWTPart(part string, v interface{}) error {
+	data, err := base64.RawURLEncoding.DecodeString(part)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+type jwtVerifiedKey struct{}
+
+// jwtHandler rejects requests without a valid JWT signed with secret in their Authorization header, with 401 if the
+// header is missing and 403 if the token is invalid. The calls of a WebSocket connection carry the token of its upgrade
+// request, which was verified when the connection was opened and isn't checked again.
+func jwtHandler(secret []byte, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if verified, _ := r.Context().Value(jwtVerifiedKey{}).(bool); verified {
+			next.ServeHTTP(w, r)
+			return
+		}
+		auth := r.Header.Get("Authorization")
+		if !strings.HasPrefix(auth, "Bearer ") {
+			respondJSON(w, http.StatusUnauthorized, keymanagerError{"missing JWT"})
+			return
+		}
+		if err := verifyJWT(secret, strings.TrimPrefix(auth, "Bearer ")); err != nil {
+			respondJSON(w, http.StatusForbidden, keymanagerError{"invalid JWT: " + err.Error()})
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), jwtVerifiedKey{}, true)))
+	})
+}
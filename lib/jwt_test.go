@@ -0,0 +1,122 @@
+package lib
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVerifyJWT(t *testing.T) {
+	secret := bytes.Repeat([]byte{1}, 32)
+	issued := time.Now()
+	unsigned := func(header, claims string) string {
+		return base64.RawURLEncoding.EncodeToString([]byte(header)) + "." + base64.RawURLEncoding.EncodeToString([]byte(claims)) + "."
+	}
+	signed := func(claims string) string {
+		token := jwtHeader + "." + base64.RawURLEncoding.EncodeToString([]byte(claims))
+		mac := hmac.New(sha256.New, secret)
+		mac.Write([]byte(token))
+		return token + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+	}
+	tests := []struct {
+		name  string
+		token string
+		err   string
+	}{
+		{"valid", newJWT(secret, issued), ""},
+		{"issued a minute ago", newJWT(secret, issued.Add(-jwtMaxIssuedAtDrift+time.Second)), ""},
+		{"stale", newJWT(secret, issued.Add(-jwtMaxIssuedAtDrift-time.Second)), "from now"},
+		{"issued in the future", newJWT(secret, issued.Add(jwtMaxIssuedAtDrift+time.Second)), "from now"},
+		{"other secret", newJWT(bytes.Repeat([]byte{2}, 32), issued), "invalid signature"},
+		{"unsigned", unsigned(`{"alg":"none"}`, `{"iat":1}`), "unsupported algorithm"},
+		{"without issued at", signed(`{"exp":1}`), "missing iat claim"},
+		{"malformed", "token", "malformed token"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := verifyJWT(secret, tt.token)
+			if tt.err == "" {
+				require.Nil(t, err)
+			} else {
+				require.Error(t, err)
+				require.Contains(t, err.Error(), tt.err)
+			}
+		})
+	}
+}
+
+func TestLoadJWTSecret(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		require.Nil(t, os.WriteFile(path, []byte(content), 0o600))
+		return path
+	}
+
+	secret, err := LoadJWTSecret(write("jwt.hex", "0x"+strings.Repeat("ab", 32)+"\n"))
+	require.Nil(t, err)
+	require.Equal(t, bytes.Repeat([]byte{0xab}, 32), secret)
+	_, err = LoadJWTSecret(write("short.hex", strings.Repeat("ab", 16)))
+	require.Error(t, err)
+	_, err = LoadJWTSecret(write("invalid.hex", strings.Repeat("xy", 32)))
+	require.Error(t, err)
+}
+
+func TestRouter_JWTAuthentication(t *testing.T) {
+	defer func() { now = time.Now }()
+	relay := newHeaderRelay(t, ExecutionPayloadWithTxRootV1{BlockHash: common.HexToHash("0x01"), BaseFeePerGas: big.NewInt(7), FeeRecipientDiff: big.NewInt(1)})
+	defer relay.Close()
+
+	secret := bytes.Repeat([]byte{1}, 32)
+	store := NewStore()
+	store.SetForkchoiceResponse(context.Background(), "0x01", relay.URL, "0x01")
+	router, err := NewRouter(context.Background(), WithRelayURLs(relay.URL), WithStore(store), WithLogger(testLog),
+		WithCapabilityCheckInterval(0), WithWebSocketRPC(0), WithJWTAuthentication(secret))
+	require.Nil(t, err)
+
+	call := func(token string) int {
+		body, err := formatRequestBody("builder_getPayloadHeaderV1", []interface{}{"0x01"})
+		require.Nil(t, err)
+		req := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		return rr.Code
+	}
+	require.Equal(t, http.StatusOK, call(newJWT(secret, time.Now())))
+	require.Equal(t, http.StatusUnauthorized, call(""))
+	require.Equal(t, http.StatusForbidden, call(newJWT(bytes.Repeat([]byte{2}, 32), time.Now())))
+
+	server := httptest.NewServer(router)
+	defer server.Close()
+	wsURL := "ws" + strings.TrimPrefix(server.URL, "http")
+	_, resp, err := websocket.DefaultDialer.Dial(wsURL, nil)
+	require.Error(t, err)
+	require.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+
+	ws, _, err := websocket.DefaultDialer.Dial(wsURL, http.Header{"Authorization": {"Bearer " + newJWT(secret, time.Now())}})
+	require.Nil(t, err)
+	defer ws.Close()
+	now = func() time.Time { return time.Now().Add(time.Hour) }
+	require.Nil(t, ws.WriteJSON(map[string]interface{}{"jsonrpc": "2.0", "id": "1", "method": "builder_getPayloadHeaderV1", "params": []string{"0x01"}}))
+	var wsResp subscriptionResponse
+	require.Nil(t, ws.ReadJSON(&wsResp))
+	require.Nil(t, wsResp.Error, "the token of a connection is only checked when it's opened")
+}
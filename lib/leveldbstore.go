@@ -0,0 +1,387 @@
+package lib
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/syndtr/goleveldb/leveldb"
+	"github.com/syndtr/goleveldb/leveldb/util"
+)
+
+// Key prefixes of the entries of a LevelDBStore
+var (
+	levelDBPayloadPrefix      = []byte("payload/")
+	levelDBForkchoicePrefix   = []byte("forkchoice/")
+	levelDBBidPrefix          = []byte("bid/")
+	levelDBProposalPrefix     = []byte("proposal/")
+	levelDBReputationPrefix   = []byte("reputation/")
+	levelDBRegistrationPrefix = []byte("registration/")
+)
+
+// LevelDBStore is a Store backed by an embedded LevelDB database, so payloads, forkchoice responses, bids and relay
+// reputations survive a restart between getPayloadHeader and proposeBlindedBlock. Entries are kept as JSON and removed
+// ttl after they were added, except reputations, which are kept until they are reset, and validator registrations,
+// which are kept until they are replaced.
+type LevelDBStore struct {
+	db  *leveldb.DB
+	ttl time.Duration
+	log Logger
+
+	forkchoiceMutex sync.Mutex // serializes the read-modify-write of forkchoice responses
+	proposalMutex   sync.Mutex // serializes the check-and-set of proposal headers
+}
+
+// NewLevelDBStore opens the database in the directory path, creating it if needed, and removes expired entries every
+// few minutes until ctx is done, logging to log. The database must be closed with Close.
+func NewLevelDBStore(ctx context.Context, path string, ttl time.Duration, log Logger) (*LevelDBStore, error) {
+	db, err := leveldb.OpenFile(path, nil)
+	if err != nil {
+		return nil, err
+	}
+	s := &LevelDBStore{db: db, ttl: ttl, log: log.WithField("prefix", "lib/leveldbstore")}
+	runLoop(ctx, s.log, "store_cleanup", cleanupLoopInterval, false, s.Cleanup)
+	return s, nil
+}
+
+// Close closes the database
+func (s *LevelDBStore) Close() error {
+	return s.db.Close()
+}
+
+func levelDBKey(prefix []byte, id []byte) []byte {
+	return append(append([]byte(nil), prefix...), id...)
+}
+
+// get decodes the entry of key into v, and returns false if there is none or it can't be read
+func (s *LevelDBStore) get(key []byte, v interface{}) bool {
+	data, err := s.db.Get(key, nil)
+	if err == nil {
+		err = json.Unmarshal(data, v)
+	}
+	if err != nil && !errors.Is(err, leveldb.ErrNotFound) {
+		s.log.WithFields(Fields{"key": hexutil.Encode(key), "error": err}).Error("could not read store entry")
+	}
+	return err == nil
+}
+
+func (s *LevelDBStore) put(key []byte, v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return s.db.Put(key, data, nil)
+}
+
+// set is put for the methods of Store that can't return errors, which are logged instead
+func (s *LevelDBStore) set(key []byte, v interface{}) {
+	if err := s.put(key, v); err != nil {
+		s.log.WithFields(Fields{"key": hexutil.Encode(key), "error": err}).Error("could not write store entry")
+	}
+}
+
+// GetExecutionPayload implements Store
+func (s *LevelDBStore) GetExecutionPayload(_ context.Context, blockHash common.Hash) *ExecutionPayloadWithTxRootV1 {
+	var container executionPayloadContainer
+	ok := s.get(levelDBKey(levelDBPayloadPrefix, blockHash[:]), &container)
+	recordStoreLookup("payload", ok)
+	if !ok {
+		return nil
+	}
+	return container.Payload
+}
+
+// SetExecutionPayload implements Store
+func (s *LevelDBStore) SetExecutionPayload(_ context.Context, blockHash common.Hash, payload *ExecutionPayloadWithTxRootV1) {
+	if payload == nil {
+		return
+	}
+	s.set(levelDBKey(levelDBPayloadPrefix, blockHash[:]), executionPayloadContainer{Payload: payload, AddedAt: now()})
+}
+
+// GetForkchoiceResponse implements Store
+func (s *LevelDBStore) GetForkchoiceResponse(_ context.Context, boostPayloadID string) (map[string]string, bool) {
+	var container forkchoiceResponseContainer
+	ok := s.get(levelDBKey(levelDBForkchoicePrefix, []byte(boostPayloadID)), &container)
+	recordStoreLookup("forkchoice", ok)
+	if !ok {
+		return nil, false
+	}
+	return container.Payload, true
+}
+
+// SetForkchoiceResponse implements Store
+func (s *LevelDBStore) SetForkchoiceResponse(_ context.Context, boostPayloadID, relayURL, relayPayloadID string) {
+	s.updateForkchoice(boostPayloadID, func(container *forkchoiceResponseContainer) {
+		container.Payload[relayURL] = relayPayloadID
+	})
+}
+
+// GetPayloadAttributes implements Store
+func (s *LevelDBStore) GetPayloadAttributes(_ context.Context, boostPayloadID string) *PayloadAttributesV1 {
+	var container forkchoiceResponseContainer
+	if !s.get(levelDBKey(levelDBForkchoicePrefix, []byte(boostPayloadID)), &container) {
+		return nil
+	}
+	return container.Attributes
+}
+
+// SetPayloadAttributes implements Store
+func (s *LevelDBStore) SetPayloadAttributes(_ context.Context, boostPayloadID string, attributes *PayloadAttributesV1) {
+	s.updateForkchoice(boostPayloadID, func(container *forkchoiceResponseContainer) {
+		container.Attributes = attributes
+	})
+}
+
+// updateForkchoice changes the forkchoice responses of boostPayloadID with update, creating them if needed
+func (s *LevelDBStore) updateForkchoice(boostPayloadID string, update func(container *forkchoiceResponseContainer)) {
+	s.forkchoiceMutex.Lock()
+	defer s.forkchoiceMutex.Unlock()
+	key := levelDBKey(levelDBForkchoicePrefix, []byte(boostPayloadID))
+	var container forkchoiceResponseContainer
+	if !s.get(key, &container) || container.Payload == nil {
+		container = newForkchoiceResponseContainer()
+	}
+	update(&container)
+	s.set(key, container)
+}
+
+// GetBid implements Store
+func (s *LevelDBStore) GetBid(_ context.Context, blockHash common.Hash) *Bid {
+	var container bidContainer
+	ok := s.get(levelDBKey(levelDBBidPrefix, blockHash[:]), &container)
+	recordStoreLookup("bid", ok)
+	if !ok {
+		return nil
+	}
+	return container.Bid
+}
+
+// SetBid implements Store
+func (s *LevelDBStore) SetBid(_ context.Context, blockHash common.Hash, bid *Bid) {
+	if bid == nil {
+		return
+	}
+	s.set(levelDBKey(levelDBBidPrefix, blockHash[:]), bidContainer{bid, now()})
+}
+
+// GetProposalHeader implements Store
+func (s *LevelDBStore) GetProposalHeader(_ context.Context, key ProposalKey) *ExecutionPayloadWithTxRootV1 {
+	var container proposalHeaderContainer
+	ok := s.get(levelDBKey(levelDBProposalPrefix, []byte(key.String())), &container)
+	recordStoreLookup("proposal_header", ok)
+	if !ok {
+		return nil
+	}
+	return container.Header
+}
+
+// SetProposalHeader implements Store
+func (s *LevelDBStore) SetProposalHeader(_ context.Context, key ProposalKey, header *ExecutionPayloadWithTxRootV1) *ExecutionPayloadWithTxRootV1 {
+	if header == nil {
+		return nil
+	}
+	s.proposalMutex.Lock()
+	defer s.proposalMutex.Unlock()
+	var container proposalHeaderContainer
+	if s.get(levelDBKey(levelDBProposalPrefix, []byte(key.String())), &container) {
+		return container.Header
+	}
+	s.set(levelDBKey(levelDBProposalPrefix, []byte(key.String())), proposalHeaderContainer{header, now()})
+	return header
+}
+
+// GetRelayReputation implements Store
+func (s *LevelDBStore) GetRelayReputation(_ context.Context, relayURL string) (*RelayReputation, error) {
+	data, err := s.db.Get(levelDBKey(levelDBReputationPrefix, []byte(relayURL)), nil)
+	if errors.Is(err, leveldb.ErrNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	reputation := new(RelayReputation)
+	if err := json.Unmarshal(data, reputation); err != nil {
+		return nil, err
+	}
+	return reputation, nil
+}
+
+// SetRelayReputation implements Store
+func (s *LevelDBStore) SetRelayReputation(_ context.Context, relayURL string, reputation *RelayReputation) error {
+	key := levelDBKey(levelDBReputationPrefix, []byte(relayURL))
+	if reputation == nil {
+		return s.db.Delete(key, nil)
+	}
+	return s.put(key, reputation)
+}
+
+// GetValidatorRegistration implements Store
+func (s *LevelDBStore) GetValidatorRegistration(_ context.Context, pubkey string) *SignedValidatorRegistrationV1 {
+	registration := new(SignedValidatorRegistrationV1)
+	ok := s.get(levelDBKey(levelDBRegistrationPrefix, []byte(strings.ToLower(pubkey))), registration)
+	recordStoreLookup("registration", ok)
+	if !ok {
+		return nil
+	}
+	return registration
+}
+
+// SetValidatorRegistration implements Store
+func (s *LevelDBStore) SetValidatorRegistration(_ context.Context, registration *SignedValidatorRegistrationV1) {
+	if registration == nil || registration.Message == nil {
+		return
+	}
+	s.set(levelDBKey(levelDBRegistrationPrefix, []byte(registration.Message.Pubkey.String())), registration)
+}
+
+// Cleanup implements Store, it removes the entries added more than the ttl ago
+func (s *LevelDBStore) Cleanup(_ context.Context) {
+	expired := func(key, value []byte) bool {
+		var container struct{ AddedAt time.Time }
+		return json.Unmarshal(value, &container) == nil && now().Sub(container.AddedAt) > s.ttl
+	}
+	for _, prefix := range [][]byte{levelDBPayloadPrefix, levelDBForkchoicePrefix, levelDBBidPrefix, levelDBProposalPrefix} {
+		s.deleteWhere(prefix, expired)
+	}
+}
+
+// EvictBefore implements Evicter, it removes payloads and payload attributes with an older timestamp, and entries without
+// one that were added earlier
+func (s *LevelDBStore) EvictBefore(_ context.Context, timestamp uint64) {
+	s.deleteWhere(levelDBPayloadPrefix, func(key, value []byte) bool {
+		var container struct {
+			Payload struct {
+				Timestamp hexutil.Uint64 `json:"timestamp"`
+			}
+		}
+		return json.Unmarshal(value, &container) == nil && uint64(container.Payload.Timestamp) < timestamp
+	})
+	s.deleteWhere(levelDBForkchoicePrefix, func(key, value []byte) bool {
+		var container forkchoiceResponseContainer
+		if json.Unmarshal(value, &container) != nil {
+			return false
+		}
+		added := uint64(container.AddedAt.Unix())
+		if container.Attributes != nil {
+			added = uint64(container.Attributes.Timestamp)
+		}
+		return added < timestamp
+	})
+	s.deleteWhere(levelDBBidPrefix, func(key, value []byte) bool {
+		var container bidContainer
+		return json.Unmarshal(value, &container) == nil && uint64(container.AddedAt.Unix()) < timestamp
+	})
+	s.deleteWhere(levelDBProposalPrefix, func(key, value []byte) bool {
+		var container proposalHeaderContainer
+		return json.Unmarshal(value, &container) == nil && container.Header != nil && container.Header.Timestamp < timestamp
+	})
+}
+
+// deleteWhere deletes the entries with the key prefix that match, in one batch
+func (s *LevelDBStore) deleteWhere(prefix []byte, match func(key, value []byte) bool) {
+	batch := new(leveldb.Batch)
+	it := s.db.NewIterator(util.BytesPrefix(prefix), nil)
+	for it.Next() {
+		if match(it.Key(), it.Value()) {
+			batch.Delete(append([]byte(nil), it.Key()...))
+		}
+	}
+	it.Release()
+	err := it.Error()
+	if err == nil && batch.Len() > 0 {
+		err = s.db.Write(batch, nil)
+	}
+	if err != nil {
+		s.log.WithFields(Fields{"prefix": string(prefix), "error": err}).Error("could not remove store entries")
+	}
+}
+
+// Sizes implements Store, it counts the entries by iterating over their keys
+func (s *LevelDBStore) Sizes(_ context.Context) StoreSizes {
+	return StoreSizes{
+		Payloads:            s.count(levelDBPayloadPrefix),
+		ForkchoiceResponses: s.count(levelDBForkchoicePrefix),
+		Bids:                s.count(levelDBBidPrefix),
+		Registrations:       s.count(levelDBRegistrationPrefix),
+	}
+}
+
+// Dump implements Store
+func (s *LevelDBStore) Dump(_ context.Context) StoreDump {
+	dump := newStoreDump()
+	s.each(levelDBPayloadPrefix, func(_ []byte, value []byte) error {
+		var container executionPayloadContainer
+		if err := json.Unmarshal(value, &container); err != nil || container.Payload == nil {
+			return err
+		}
+		dump.Payloads = append(dump.Payloads, container.stored())
+		return nil
+	})
+	s.each(levelDBForkchoicePrefix, func(key []byte, value []byte) error {
+		var container forkchoiceResponseContainer
+		if err := json.Unmarshal(value, &container); err != nil {
+			return err
+		}
+		dump.ForkchoiceResponses[string(key[len(levelDBForkchoicePrefix):])] = container.Payload
+		return nil
+	})
+	s.each(levelDBBidPrefix, func(key []byte, value []byte) error {
+		var container bidContainer
+		if err := json.Unmarshal(value, &container); err != nil {
+			return err
+		}
+		dump.Bids[common.BytesToHash(key[len(levelDBBidPrefix):]).Hex()] = container.Bid
+		return nil
+	})
+	s.each(levelDBRegistrationPrefix, func(_ []byte, value []byte) error {
+		registration := new(SignedValidatorRegistrationV1)
+		if err := json.Unmarshal(value, registration); err != nil {
+			return err
+		}
+		dump.Registrations = append(dump.Registrations, registration)
+		return nil
+	})
+	s.each(levelDBProposalPrefix, func(key []byte, value []byte) error {
+		var container proposalHeaderContainer
+		if err := json.Unmarshal(value, &container); err != nil || container.Header == nil {
+			return err
+		}
+		dump.ProposalHeaders[string(key[len(levelDBProposalPrefix):])] = container.Header
+		return nil
+	})
+	return dump
+}
+
+// each calls read with the key and value of the entries with the key prefix, entries it can't read are logged
+func (s *LevelDBStore) each(prefix []byte, read func(key, value []byte) error) {
+	it := s.db.NewIterator(util.BytesPrefix(prefix), nil)
+	for it.Next() {
+		if err := read(it.Key(), it.Value()); err != nil {
+			s.log.WithFields(Fields{"key": hexutil.Encode(it.Key()), "error": err}).Error("could not read store entry")
+		}
+	}
+	it.Release()
+	if err := it.Error(); err != nil {
+		s.log.WithFields(Fields{"prefix": string(prefix), "error": err}).Error("could not read store entries")
+	}
+}
+
+// count returns the number of entries with the key prefix
+func (s *LevelDBStore) count(prefix []byte) int {
+	n := 0
+	it := s.db.NewIterator(util.BytesPrefix(prefix), nil)
+	for it.Next() {
+		n++
+	}
+	it.Release()
+	if err := it.Error(); err != nil {
+		s.log.WithFields(Fields{"prefix": string(prefix), "error": err}).Error("could not count store entries")
+	}
+	return n
+}
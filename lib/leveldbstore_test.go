@@ -0,0 +1,116 @@
+package lib
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/require"
+)
+
+// storedPayload is a payload with the fields required to decode it
+func storedPayload(blockHash common.Hash, timestamp uint64) *ExecutionPayloadWithTxRootV1 {
+	return &ExecutionPayloadWithTxRootV1{BlockHash: blockHash, Timestamp: timestamp, LogsBloom: []byte{}, ExtraData: []byte{}, BaseFeePerGas: big.NewInt(1), FeeRecipientDiff: big.NewInt(1)}
+}
+
+func TestLevelDBStore(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	dir := t.TempDir()
+	s, err := NewLevelDBStore(ctx, dir, time.Minute, testLog)
+	require.Nil(t, err)
+
+	h := common.HexToHash("0x01")
+	require.Nil(t, s.GetExecutionPayload(ctx, h))
+	require.Nil(t, s.GetBid(ctx, h))
+	_, found := s.GetForkchoiceResponse(ctx, "0x01")
+	require.False(t, found)
+
+	payload := &ExecutionPayloadWithTxRootV1{
+		BlockHash:        h,
+		Timestamp:        1000,
+		LogsBloom:        []byte{},
+		ExtraData:        []byte{},
+		BaseFeePerGas:    big.NewInt(1),
+		FeeRecipientDiff: big.NewInt(2),
+		Transactions:     &[]string{"0x02"},
+	}
+	s.SetExecutionPayload(ctx, h, payload)
+	s.SetBid(ctx, h, &Bid{RelayURL: "http://relay", FeeRecipient: common.HexToAddress("0x03"), Value: big.NewInt(2)})
+	s.SetPayloadAttributes(ctx, "0x01", &PayloadAttributesV1{Timestamp: 1000})
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			s.SetForkchoiceResponse(ctx, "0x01", fmt.Sprintf("http://relay%d", i), fmt.Sprintf("0x%02x", i))
+		}(i)
+	}
+	wg.Wait()
+	reputation := &RelayReputation{Payments: []RelayPayment{{Promised: big.NewInt(2), Paid: big.NewInt(1)}}}
+	require.Nil(t, s.SetRelayReputation(ctx, "http://relay", reputation))
+	require.Nil(t, s.Close())
+
+	// everything survives a restart
+	s, err = NewLevelDBStore(ctx, dir, time.Minute, testLog)
+	require.Nil(t, err)
+	defer s.Close()
+	require.Equal(t, payload, s.GetExecutionPayload(ctx, h))
+	require.Equal(t, &Bid{RelayURL: "http://relay", FeeRecipient: common.HexToAddress("0x03"), Value: big.NewInt(2)}, s.GetBid(ctx, h))
+	require.Equal(t, &PayloadAttributesV1{Timestamp: 1000}, s.GetPayloadAttributes(ctx, "0x01"))
+	responses, found := s.GetForkchoiceResponse(ctx, "0x01")
+	require.True(t, found)
+	require.Len(t, responses, 10, "concurrent forkchoice responses are all kept")
+	require.Equal(t, "0x03", responses["http://relay3"])
+	stored, err := s.GetRelayReputation(ctx, "http://relay")
+	require.Nil(t, err)
+	require.Equal(t, reputation, stored)
+	require.Equal(t, StoreSizes{Payloads: 1, ForkchoiceResponses: 1, Bids: 1}, s.Sizes(ctx))
+
+	// entries expire after the ttl, reputations don't
+	defer func() { now = time.Now }()
+	now = func() time.Time { return time.Now().Add(-2 * time.Minute) }
+	h2 := common.HexToHash("0x02")
+	s.SetExecutionPayload(ctx, h2, storedPayload(h2, 2000))
+	s.SetBid(ctx, h2, &Bid{Value: big.NewInt(1), Local: true})
+	now = time.Now
+	s.Cleanup(ctx)
+	require.Nil(t, s.GetExecutionPayload(ctx, h2))
+	require.Nil(t, s.GetBid(ctx, h2))
+	require.NotNil(t, s.GetExecutionPayload(ctx, h))
+	now = func() time.Time { return time.Now().Add(2 * time.Minute) }
+	s.Cleanup(ctx)
+	require.Nil(t, s.GetExecutionPayload(ctx, h), "the ttl is measured on the store clock")
+	stored, err = s.GetRelayReputation(ctx, "http://relay")
+	require.Nil(t, err)
+	require.NotNil(t, stored)
+
+	require.Nil(t, s.SetRelayReputation(ctx, "http://relay", nil))
+	stored, err = s.GetRelayReputation(ctx, "http://relay")
+	require.Nil(t, err)
+	require.Nil(t, stored)
+}
+
+func TestLevelDBStore_EvictBefore(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	s, err := NewLevelDBStore(ctx, t.TempDir(), time.Minute, testLog)
+	require.Nil(t, err)
+	defer s.Close()
+
+	old, recent := common.HexToHash("0x01"), common.HexToHash("0x02")
+	s.SetExecutionPayload(ctx, old, storedPayload(old, 1000))
+	s.SetExecutionPayload(ctx, recent, storedPayload(recent, 3000))
+	s.SetPayloadAttributes(ctx, "0x01", &PayloadAttributesV1{Timestamp: 1000})
+	s.SetPayloadAttributes(ctx, "0x02", &PayloadAttributesV1{Timestamp: 3000})
+
+	s.EvictBefore(ctx, 2000)
+	require.Nil(t, s.GetExecutionPayload(ctx, old))
+	require.NotNil(t, s.GetExecutionPayload(ctx, recent))
+	require.Nil(t, s.GetPayloadAttributes(ctx, "0x01"))
+	require.NotNil(t, s.GetPayloadAttributes(ctx, "0x02"))
+}
@@ -0,0 +1,237 @@
+package lib
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// localPayloadRetentionSlots is how many slots the payload ids of local execution clients are kept
+const localPayloadRetentionSlots = 2
+
+var localPayloadsTotal = metricsFactory.NewCounterVec(prometheus.CounterOpts{
+	Name: "mevboost_local_payloads_total",
+	Help: "Payloads requested from local execution clients when no relay returned a valid bid, by client and result",
+}, []string{"url", "result"})
+
+// localBuilders are execution clients of the operator that build payloads for the proposals, so a proposal without a
+// valid relay bid still gets the most valuable of their payloads instead of falling back to a single one
+type localBuilders struct {
+	clients []*ExecutionClient
+
+	mu         sync.Mutex
+	payloadIDs map[string]*localPayloadIDs // map[boost payload id]
+}
+
+// localPayloadIDs are the payload ids the local execution clients returned for a proposal
+type localPayloadIDs struct {
+	slot uint64
+	ids  map[string]string // map[execution client url]payload id
+}
+
+// localPayload is a payload of a local execution client
+type localPayload struct {
+	url     string
+	payload *ExecutionPayloadWithTxRootV1
+}
+
+func newLocalBuilders(clients []*ExecutionClient) *localBuilders {
+	return &localBuilders{clients: clients, payloadIDs: make(map[string]*localPayloadIDs)}
+}
+
+// forward sends a forkchoiceUpdated call with payload attributes to the local execution clients, and keeps the payload
+// ids they return for boostPayloadID. It returns the number of clients that started building a payload.
+func (b *localBuilders) forward(ctx context.Context, params []interface{}, boostPayloadID string, slot uint64, log Logger) int {
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	started := 0
+	for _, client := range b.clients {
+		wg.Add(1)
+		go func(client *ExecutionClient) {
+			defer wg.Done()
+			response, err := client.ForkchoiceUpdated(ctx, params)
+			if err == nil && response.PayloadID == nil {
+				err = fmt.Errorf("no payload id, status %s", response.PayloadStatus.Status)
+			}
+			if err != nil {
+				log.WithFields(Fields{"error": err, "url": client.URL()}).Warn("local execution client didn't start building a payload")
+				return
+			}
+			b.register(boostPayloadID, slot, client.URL(), response.PayloadID.String())
+			mu.Lock()
+			started++
+			mu.Unlock()
+		}(client)
+	}
+	wg.Wait()
+	return started
+}
+
+func (b *localBuilders) register(boostPayloadID string, slot uint64, url, payloadID string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for id, ids := range b.payloadIDs {
+		if ids.slot+localPayloadRetentionSlots < slot {
+			delete(b.payloadIDs, id)
+		}
+	}
+	ids, ok := b.payloadIDs[boostPayloadID]
+	if !ok {
+		ids = &localPayloadIDs{slot: slot, ids: make(map[string]string)}
+		b.payloadIDs[boostPayloadID] = ids
+	}
+	ids.ids[url] = payloadID
+}
+
+// has reports whether a local execution client builds a payload for boostPayloadID
+func (b *localBuilders) has(boostPayloadID string) bool {
+	if b == nil {
+		return false
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	_, ok := b.payloadIDs[boostPayloadID]
+	return ok
+}
+
+// best requests the payloads of boostPayloadID from all local execution clients and returns the valid one with the
+// highest estimated value for the fee recipient, or nil if there is none. Payloads have to build on head and match the
+// payload attributes, if they are known.
+func (b *localBuilders) best(ctx context.Context, boostPayloadID string, attributes *PayloadAttributesV1, head common.Hash, log Logger) *localPayload {
+	b.mu.Lock()
+	var ids map[string]string
+	if entry, ok := b.payloadIDs[boostPayloadID]; ok {
+		ids = entry.ids
+	}
+	b.mu.Unlock()
+
+	resultC := make(chan *localPayload, len(b.clients))
+	requested := 0
+	for _, client := range b.clients {
+		payloadID, ok := ids[client.URL()]
+		if !ok {
+			continue
+		}
+		requested++
+		go func(client *ExecutionClient, payloadID string) {
+			payload, err := client.GetPayload(ctx, payloadID)
+			if err == nil {
+				err = checkLocalPayload(payload, attributes, head)
+			}
+			if err != nil {
+				localPayloadsTotal.WithLabelValues(client.URL(), "invalid").Inc()
+				log.WithFields(Fields{"error": err, "url": client.URL()}).Warn("no valid payload from local execution client")
+				resultC <- nil
+				return
+			}
+			payload.FeeRecipientDiff = estimatePriorityFees(payload)
+			resultC <- &localPayload{url: client.URL(), payload: payload}
+		}(client, payloadID)
+	}
+
+	var valid []*localPayload
+	for i := 0; i < requested; i++ {
+		if res := <-resultC; res != nil {
+			valid = append(valid, res)
+		}
+	}
+	var best *localPayload
+	for _, res := range valid {
+		if best == nil || res.payload.FeeRecipientDiff.Cmp(best.payload.FeeRecipientDiff) > 0 {
+			best = res
+		}
+	}
+	for _, res := range valid {
+		result := "outbid"
+		if res == best {
+			result = "selected"
+		}
+		localPayloadsTotal.WithLabelValues(res.url, result).Inc()
+	}
+	return best
+}
+
+// serveLocalPayload answers a header request without a valid relay bid with the best payload of the local execution
+// clients. The payload is kept in the store, so it's revealed from there when the block is proposed.
+func (m *RelayService) serveLocalPayload(ctx context.Context, payloadID string, attributes *PayloadAttributesV1, result *ExecutionPayloadWithTxRootV1, logMethod Logger) {
+	head, _ := m.heads.get(payloadID)
+	slot := m.chain.CurrentSlot()
+	if attributes != nil {
+		slot = m.chain.SlotAt(uint64(attributes.Timestamp))
+	}
+	best := m.local.best(withCapella(ctx, m.chain, slot), payloadID, attributes, head, logMethod)
+	if best == nil {
+		return
+	}
+	if err := m.fillTransactionsRoot(best.payload, logMethod); err != nil {
+		logMethod.WithFields(Fields{"error": err, "url": best.url}).Error("could not compute the transactions root of the local payload")
+		return
+	}
+	if err := fillWithdrawalsRoot(best.payload); err != nil {
+		logMethod.WithFields(Fields{"error": err, "url": best.url}).Error("could not compute the withdrawals root of the local payload")
+		return
+	}
+	m.store.SetExecutionPayload(ctx, best.payload.BlockHash, best.payload)
+	m.store.SetBid(ctx, best.payload.BlockHash, &Bid{Value: best.payload.FeeRecipientDiff, Local: true})
+	*result = *best.payload
+	result.Transactions = nil
+
+	logMethod.WithFields(Fields{
+		"slot":      m.chain.SlotAt(result.Timestamp),
+		"blockHash": result.BlockHash,
+		"url":       best.url,
+		"estimated": result.FeeRecipientDiff,
+	}).Warn("GetPayloadHeaderV1: no valid relay bid, returning the payload of a local execution client")
+}
+
+// checkLocalPayload rejects payloads without block hash, on another parent than head, or that don't match the attributes
+func checkLocalPayload(payload *ExecutionPayloadWithTxRootV1, attributes *PayloadAttributesV1, head common.Hash) error {
+	switch {
+	case payload.BlockHash == nilHash:
+		return errors.New("payload has no block hash")
+	case head != nilHash && payload.ParentHash != head:
+		return fmt.Errorf("payload builds on %s instead of the head %s", payload.ParentHash, head)
+	case attributes == nil:
+		return nil
+	case payload.Timestamp != uint64(attributes.Timestamp):
+		return fmt.Errorf("payload has timestamp %d instead of %d", payload.Timestamp, attributes.Timestamp)
+	case payload.PrevRandao != attributes.PrevRandao:
+		return fmt.Errorf("payload has prevRandao %s instead of %s", payload.PrevRandao, attributes.PrevRandao)
+	case payload.FeeRecipient != attributes.SuggestedFeeRecipient:
+		return fmt.Errorf("payload pays %s instead of the fee recipient %s", payload.FeeRecipient, attributes.SuggestedFeeRecipient)
+	}
+	return nil
+}
+
+// estimatePriorityFees estimates the priority fees a payload pays its fee recipient. The gas used by each transaction
+// isn't in the payload, so the tips at the gas limit of the transactions are scaled to the gas used by the block.
+func estimatePriorityFees(payload *ExecutionPayloadWithTxRootV1) *big.Int {
+	fees, gasLimits := new(big.Int), new(big.Int)
+	if payload.Transactions == nil || payload.BaseFeePerGas == nil {
+		return fees
+	}
+	for _, encoded := range *payload.Transactions {
+		var tx types.Transaction
+		if err := tx.UnmarshalBinary(common.FromHex(encoded)); err != nil {
+			continue
+		}
+		tip, err := tx.EffectiveGasTip(payload.BaseFeePerGas)
+		if err != nil {
+			continue
+		}
+		gas := new(big.Int).SetUint64(tx.Gas())
+		fees.Add(fees, tip.Mul(tip, gas))
+		gasLimits.Add(gasLimits, gas)
+	}
+	if gasLimits.Sign() == 0 {
+		return fees
+	}
+	fees.Mul(fees, new(big.Int).SetUint64(payload.GasUsed))
+	return fees.Div(fees, gasLimits)
+}
@@ -0,0 +1,177 @@
+package lib
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/stretchr/testify/require"
+)
+
+// newMockEngineNode serves payload as the payload of every build process, to requests with a JWT signed with secret
+func newMockEngineNode(t *testing.T, secret []byte, payload executionPayloadV1) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token := strings.Split(strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer "), ".")
+		require.Len(t, token, 3)
+		mac := hmac.New(sha256.New, secret)
+		mac.Write([]byte(token[0] + "." + token[1]))
+		require.Equal(t, base64.RawURLEncoding.EncodeToString(mac.Sum(nil)), token[2], "JWT signature")
+
+		var req struct {
+			Method string `json:"method"`
+		}
+		require.Nil(t, json.NewDecoder(r.Body).Decode(&req))
+		var result interface{}
+		switch req.Method {
+		case methodForkchoiceUpdated:
+			result = ForkChoiceResponse{PayloadStatus: PayloadStatus{Status: ForkchoiceStatusValid}, PayloadID: &hexutil.Bytes{1, 2}}
+		case "engine_getPayloadV1":
+			result = payload
+		case "engine_getPayloadV2":
+			result = map[string]interface{}{"executionPayload": payload, "blockValue": "0x1"}
+		}
+		resp, err := formatResponse(result)
+		require.Nil(t, err)
+		w.Write(resp)
+	}))
+}
+
+func TestRelayService_localPayloadFallback(t *testing.T) {
+	head, feeRecipient, randao := common.HexToHash("0x0a"), common.HexToAddress("0x0b"), common.HexToHash("0x0c")
+	key, err := crypto.GenerateKey()
+	require.Nil(t, err)
+	signer := types.NewLondonSigner(big.NewInt(1))
+	payloadWithTip := func(blockHash common.Hash, tip int64) executionPayloadV1 {
+		tx, err := types.SignNewTx(key, signer, &types.DynamicFeeTx{
+			ChainID:   big.NewInt(1),
+			Gas:       21000,
+			GasFeeCap: big.NewInt(100),
+			GasTipCap: big.NewInt(tip),
+		})
+		require.Nil(t, err)
+		encoded, err := tx.MarshalBinary()
+		require.Nil(t, err)
+		return executionPayloadV1{
+			ParentHash:    head,
+			FeeRecipient:  feeRecipient,
+			PrevRandao:    randao,
+			Timestamp:     1000,
+			GasUsed:       21000,
+			BaseFeePerGas: (*hexutil.Big)(big.NewInt(10)),
+			BlockHash:     blockHash,
+			Transactions:  []hexutil.Bytes{encoded},
+		}
+	}
+	secret := []byte("secret")
+	low := newMockEngineNode(t, secret, payloadWithTip(common.HexToHash("0x01"), 2))
+	defer low.Close()
+	high := newMockEngineNode(t, secret, payloadWithTip(common.HexToHash("0x02"), 5))
+	defer high.Close()
+	wrongRecipient := payloadWithTip(common.HexToHash("0x03"), 50)
+	wrongRecipient.FeeRecipient = common.HexToAddress("0x0d")
+	invalid := newMockEngineNode(t, secret, wrongRecipient)
+	defer invalid.Close()
+
+	relay := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		resp, err := formatErrorResponse("relay is down")
+		require.Nil(t, err)
+		w.Write(resp)
+	}))
+	defer relay.Close()
+
+	store := NewStore()
+	service, err := newRelayService(WithRelayURLs(relay.URL), WithStore(store), WithLogger(testLog),
+		WithLocalExecutionClients(NewEngineClient(low.URL, secret), NewEngineClient(high.URL, secret), NewEngineClient(invalid.URL, secret)))
+	require.Nil(t, err)
+
+	args := []interface{}{
+		map[string]interface{}{"headBlockHash": head.Hex()},
+		map[string]interface{}{"timestamp": "0x3e8", "prevRandao": randao.Hex(), "suggestedFeeRecipient": feeRecipient.Hex()},
+	}
+	fcu := new(ForkChoiceResponse)
+	require.Nil(t, service.ForkchoiceUpdatedV1(nil, &args, fcu), "local execution clients building is enough")
+
+	payloadID := fcu.PayloadID.String()
+	header := new(ExecutionPayloadWithTxRootV1)
+	require.Nil(t, service.GetPayloadHeaderV1(nil, &payloadID, header))
+	require.Equal(t, common.HexToHash("0x02"), header.BlockHash)
+	require.Equal(t, big.NewInt(5*21000), header.FeeRecipientDiff)
+	require.Nil(t, header.Transactions)
+	require.NotEqual(t, nilHash, header.TransactionsRoot)
+
+	payload := store.GetExecutionPayload(context.Background(), header.BlockHash)
+	require.NotNil(t, payload, "the payload is revealed from the store")
+	require.Len(t, *payload.Transactions, 1)
+	require.Equal(t, &Bid{Value: big.NewInt(5 * 21000), Local: true}, store.GetBid(context.Background(), header.BlockHash))
+}
+
+func TestExecutionClient_GetPayload(t *testing.T) {
+	secret := []byte("secret")
+	withdrawals := []*Withdrawal{{Index: 1, ValidatorIndex: 2, Address: common.HexToAddress("0x03"), Amount: 4}}
+	node := newMockEngineNode(t, secret, executionPayloadV1{
+		BlockHash:     common.HexToHash("0x01"),
+		BaseFeePerGas: (*hexutil.Big)(big.NewInt(10)),
+		Transactions:  []hexutil.Bytes{{0x02}},
+		Withdrawals:   &withdrawals,
+	})
+	defer node.Close()
+	client := NewEngineClient(node.URL, secret)
+
+	chain := &ChainConfig{SecondsPerSlot: 12, SlotsPerEpoch: 32, CapellaForkEpoch: 1}
+	payload, err := client.GetPayload(withCapella(context.Background(), chain, 32), "0x01")
+	require.Nil(t, err, "engine_getPayloadV2 for capella")
+	require.Equal(t, common.HexToHash("0x01"), payload.BlockHash)
+	require.Equal(t, []string{"0x02"}, *payload.Transactions)
+	require.Equal(t, &withdrawals, payload.Withdrawals)
+
+	payload, err = client.GetPayload(withCapella(context.Background(), chain, 31), "0x01")
+	require.Nil(t, err, "engine_getPayloadV1 before capella")
+	require.Equal(t, common.HexToHash("0x01"), payload.BlockHash)
+}
+
+func TestRelayService_ProposeBlindedBlockV1_EvictedLocalPayload(t *testing.T) {
+	var calls int32
+	relay := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+	}))
+	defer relay.Close()
+
+	store := NewStore()
+	service, err := newRelayService(WithRelayURLs(relay.URL), WithStore(store), WithLogger(testLog))
+	require.Nil(t, err)
+
+	header := &ExecutionPayloadHeaderV1{BlockHash: common.HexToHash("0x01"), BaseFeePerGas: big.NewInt(1)}
+	store.SetBid(context.Background(), header.BlockHash, &Bid{Value: big.NewInt(1), Local: true})
+	block := &SignedBlindedBeaconBlock{Message: &BlindedBeaconBlock{Body: &BlindedBeaconBlockBody{ExecutionPayloadHeader: header}}}
+	err = service.ProposeBlindedBlockV1(nil, block, new(ExecutionPayloadWithTxRootV1))
+	require.ErrorIs(t, err, ErrUnknownPayload)
+	require.Equal(t, int32(0), atomic.LoadInt32(&calls), "the signed block of a local payload isn't sent to relays")
+}
+
+func TestEstimatePriorityFees(t *testing.T) {
+	key, err := crypto.GenerateKey()
+	require.Nil(t, err)
+	var transactions []string
+	for _, tip := range []int64{1, 3} {
+		tx, err := types.SignNewTx(key, types.NewLondonSigner(big.NewInt(1)), &types.DynamicFeeTx{ChainID: big.NewInt(1), Gas: 100000, GasFeeCap: big.NewInt(12), GasTipCap: big.NewInt(tip)})
+		require.Nil(t, err)
+		encoded, err := tx.MarshalBinary()
+		require.Nil(t, err)
+		transactions = append(transactions, hexutil.Encode(encoded))
+	}
+	payload := &ExecutionPayloadWithTxRootV1{BaseFeePerGas: big.NewInt(10), GasUsed: 100000, Transactions: &transactions}
+	// tips of 1 and 2, the second capped by the fee cap, at half the gas limit
+	require.Equal(t, big.NewInt(150000), estimatePriorityFees(payload))
+}
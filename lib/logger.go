@@ -0,0 +1,155 @@
+package lib
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Fields are structured fields of a log entry
+type Fields map[string]interface{}
+
+// Logger is the logging interface used by lib. The logrusadapter package adapts logrus, other loggers need a few lines of glue.
+type Logger interface {
+	WithField(key string, value interface{}) Logger
+	WithFields(fields Fields) Logger
+	WithError(err error) Logger
+
+	Debug(args ...interface{})
+	Info(args ...interface{})
+	Warn(args ...interface{})
+	Error(args ...interface{})
+}
+
+// jsonLogger is a Logger writing an object with the time, level, message and fields of each entry as JSON lines, for
+// log pipelines. Debug entries are dropped.
+type jsonLogger struct {
+	out    *jsonWriter
+	fields Fields
+}
+
+type jsonWriter struct {
+	mu  sync.Mutex
+	out io.Writer
+}
+
+// NewJSONLogger creates a Logger writing JSON lines to out
+func NewJSONLogger(out io.Writer) Logger {
+	return &jsonLogger{out: &jsonWriter{out: out}}
+}
+
+func (l *jsonLogger) WithField(key string, value interface{}) Logger {
+	return l.WithFields(Fields{key: value})
+}
+
+func (l *jsonLogger) WithFields(fields Fields) Logger {
+	merged := make(Fields, len(l.fields)+len(fields))
+	for key, value := range l.fields {
+		merged[key] = value
+	}
+	for key, value := range fields {
+		merged[key] = value
+	}
+	return &jsonLogger{out: l.out, fields: merged}
+}
+
+func (l *jsonLogger) WithError(err error) Logger {
+	return l.WithField("error", err)
+}
+
+func (l *jsonLogger) Debug(args ...interface{}) {}
+
+func (l *jsonLogger) Info(args ...interface{}) {
+	l.print("info", args)
+}
+
+func (l *jsonLogger) Warn(args ...interface{}) {
+	l.print("warning", args)
+}
+
+func (l *jsonLogger) Error(args ...interface{}) {
+	l.print("error", args)
+}
+
+func (l *jsonLogger) print(level string, args []interface{}) {
+	entry := make(map[string]interface{}, len(l.fields)+3)
+	for key, value := range l.fields {
+		if err, ok := value.(error); ok { // errors have no exported fields, they'd be encoded as {}
+			value = err.Error()
+		}
+		entry[key] = value
+	}
+	entry["time"], entry["level"], entry["msg"] = now().UTC().Format(time.RFC3339Nano), level, fmt.Sprint(args...)
+
+	line, err := json.Marshal(entry)
+	if err != nil {
+		line, _ = json.Marshal(map[string]string{"level": "error", "msg": "could not encode log entry: " + err.Error()})
+	}
+	l.out.mu.Lock()
+	defer l.out.mu.Unlock()
+	l.out.out.Write(append(line, '\n'))
+}
+
+// stdLogger is the default Logger, writing logfmt-like lines through the standard library log package. Debug entries are dropped.
+type stdLogger struct {
+	log    *log.Logger
+	fields Fields
+}
+
+// NewStdLogger creates a Logger writing to l
+func NewStdLogger(l *log.Logger) Logger {
+	return &stdLogger{log: l}
+}
+
+func (l *stdLogger) WithField(key string, value interface{}) Logger {
+	return l.WithFields(Fields{key: value})
+}
+
+func (l *stdLogger) WithFields(fields Fields) Logger {
+	merged := make(Fields, len(l.fields)+len(fields))
+	for key, value := range l.fields {
+		merged[key] = value
+	}
+	for key, value := range fields {
+		merged[key] = value
+	}
+	return &stdLogger{log: l.log, fields: merged}
+}
+
+func (l *stdLogger) WithError(err error) Logger {
+	return l.WithField("error", err)
+}
+
+func (l *stdLogger) Debug(args ...interface{}) {}
+
+func (l *stdLogger) Info(args ...interface{}) {
+	l.print("info", args)
+}
+
+func (l *stdLogger) Warn(args ...interface{}) {
+	l.print("warning", args)
+}
+
+func (l *stdLogger) Error(args ...interface{}) {
+	l.print("error", args)
+}
+
+func (l *stdLogger) print(level string, args []interface{}) {
+	keys := make([]string, 0, len(l.fields))
+	for key := range l.fields {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var line strings.Builder
+	fmt.Fprintf(&line, "level=%s msg=%q", level, fmt.Sprint(args...))
+	for _, key := range keys {
+		fmt.Fprintf(&line, " %s=%q", key, fmt.Sprint(l.fields[key]))
+	}
+	l.log.Print(line.String())
+}
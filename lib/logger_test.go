@@ -0,0 +1,46 @@
+package lib
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"log"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+var testLog = NewStdLogger(log.Default()).WithField("testing", true)
+
+func TestStdLogger(t *testing.T) {
+	var out bytes.Buffer
+	logger := NewStdLogger(log.New(&out, "", 0)).WithField("url", "http://relay")
+
+	logger.WithFields(Fields{"slot": 1}).WithError(errors.New("timeout")).Warn("relay failed")
+	require.Equal(t, "level=warning msg=\"relay failed\" error=\"timeout\" slot=\"1\" url=\"http://relay\"\n", out.String())
+
+	out.Reset()
+	logger.Debug("dropped")
+	require.Empty(t, out.String())
+}
+
+func TestJSONLogger(t *testing.T) {
+	defer func() { now = time.Now }()
+	now = func() time.Time { return time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC) }
+	var out bytes.Buffer
+	logger := NewJSONLogger(&out).WithField("url", "http://relay")
+
+	logger.WithFields(Fields{"slot": 1}).WithError(errors.New("timeout")).Warn("relay failed")
+	require.JSONEq(t, `{"time":"2026-03-01T12:00:00Z","level":"warning","msg":"relay failed","error":"timeout","slot":1,"url":"http://relay"}`, out.String())
+
+	out.Reset()
+	logger.WithField("unencodable", make(chan int)).Info("dropped fields")
+	var entry map[string]string
+	require.Nil(t, json.Unmarshal(out.Bytes(), &entry))
+	require.Equal(t, "error", entry["level"])
+
+	out.Reset()
+	logger.Debug("dropped")
+	require.Empty(t, out.String())
+}
@@ -0,0 +1,43 @@
+package lib
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRotatingFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "mev-boost.log")
+	f, err := NewRotatingFile(path, 10, 2)
+	require.Nil(t, err)
+
+	for _, line := range []string{"first\n", "second\n", "third\n", "fourth\n"} {
+		n, err := f.Write([]byte(line))
+		require.Nil(t, err)
+		require.Equal(t, len(line), n)
+	}
+	require.Nil(t, f.Close())
+
+	read := func(name string) string {
+		content, err := os.ReadFile(name)
+		require.Nil(t, err)
+		return string(content)
+	}
+	require.Equal(t, "fourth\n", read(path))
+	require.Equal(t, "third\n", read(path+".1"))
+	require.Equal(t, "second\n", read(path+".2"))
+	_, err = os.Stat(path + ".3")
+	require.True(t, os.IsNotExist(err), "only two rotated files are kept")
+
+	f, err = NewRotatingFile(path, 0, 2)
+	require.Nil(t, err)
+	_, err = f.Write([]byte("appended\n"))
+	require.Nil(t, err)
+	require.Nil(t, f.Close())
+	require.Equal(t, "fourth\nappended\n", read(path), "reopened files are appended to")
+
+	_, err = f.Write([]byte("closed\n"))
+	require.Error(t, err)
+}
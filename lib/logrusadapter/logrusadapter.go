@@ -0,0 +1,59 @@
+// Package logrusadapter makes a logrus logger usable as lib.Logger
+package logrusadapter
+
+import (
+	"github.com/flashbots/mev-boost/lib"
+	"github.com/sirupsen/logrus"
+)
+
+type logger struct {
+	entry *logrus.Entry
+}
+
+// New adapts a logrus entry to lib.Logger
+func New(entry *logrus.Entry) lib.Logger {
+	return &logger{entry}
+}
+
+func (l *logger) WithField(key string, value interface{}) lib.Logger {
+	return &logger{l.entry.WithField(key, value)}
+}
+
+func (l *logger) WithFields(fields lib.Fields) lib.Logger {
+	return &logger{l.entry.WithFields(logrus.Fields(fields))}
+}
+
+func (l *logger) WithError(err error) lib.Logger {
+	return &logger{l.entry.WithError(err)}
+}
+
+func (l *logger) Debug(args ...interface{}) {
+	l.entry.Debug(args...)
+}
+
+func (l *logger) Info(args ...interface{}) {
+	l.entry.Info(args...)
+}
+
+func (l *logger) Warn(args ...interface{}) {
+	l.entry.Warn(args...)
+}
+
+func (l *logger) Error(args ...interface{}) {
+	l.entry.Error(args...)
+}
+
+// LevelControl reads and changes the level of l, for lib.WithLogLevelControl
+func LevelControl(l *logrus.Logger) lib.LogLevelControl {
+	return lib.LogLevelControl{
+		Get: func() string { return l.GetLevel().String() },
+		Set: func(level string) error {
+			parsed, err := logrus.ParseLevel(level)
+			if err != nil {
+				return err
+			}
+			l.SetLevel(parsed)
+			return nil
+		},
+	}
+}
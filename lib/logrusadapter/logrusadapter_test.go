@@ -0,0 +1,30 @@
+package logrusadapter
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/flashbots/mev-boost/lib"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLogger(t *testing.T) {
+	var out bytes.Buffer
+	base := logrus.New()
+	base.SetOutput(&out)
+	base.SetFormatter(&logrus.TextFormatter{DisableTimestamp: true})
+
+	New(logrus.NewEntry(base)).WithFields(lib.Fields{"url": "http://relay"}).WithField("slot", 1).Warn("relay failed")
+	require.Equal(t, "level=warning msg=\"relay failed\" slot=1 url=\"http://relay\"\n", out.String())
+}
+
+func TestLevelControl(t *testing.T) {
+	base := logrus.New()
+	control := LevelControl(base)
+	require.Equal(t, "info", control.Get())
+	require.Nil(t, control.Set("debug"))
+	require.Equal(t, logrus.DebugLevel, base.GetLevel())
+	require.NotNil(t, control.Set("loud"))
+	require.Equal(t, "debug", control.Get())
+}
@@ -0,0 +1,150 @@
+package lib
+
+import (
+	"context"
+	"math/big"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	metricsRegistry = prometheus.NewRegistry()
+	metricsFactory  = promauto.With(metricsRegistry)
+
+	relayPayloadsTotal = metricsFactory.NewCounterVec(prometheus.CounterOpts{
+		Name: "mevboost_relay_payloads_total",
+		Help: "Revealed payloads per relay for which a bid was known",
+	}, []string{"relay"})
+	relayUnverifiedPayloadsTotal = metricsFactory.NewCounterVec(prometheus.CounterOpts{
+		Name: "mevboost_relay_unverified_payloads_total",
+		Help: "Revealed payloads per relay whose payment could not be verified",
+	}, []string{"relay"})
+	relayUnderpaidPayloadsTotal = metricsFactory.NewCounterVec(prometheus.CounterOpts{
+		Name: "mevboost_relay_underpaid_payloads_total",
+		Help: "Revealed payloads per relay that paid the proposer less than promised",
+	}, []string{"relay"})
+	relayPromisedGweiTotal = metricsFactory.NewCounterVec(prometheus.CounterOpts{
+		Name: "mevboost_relay_promised_gwei_total",
+		Help: "Sum of bid values promised by a relay for verified payloads, in gwei",
+	}, []string{"relay"})
+	relayPaidGweiTotal = metricsFactory.NewCounterVec(prometheus.CounterOpts{
+		Name: "mevboost_relay_paid_gwei_total",
+		Help: "Sum of payments realized by payloads of a relay, in gwei",
+	}, []string{"relay"})
+	relaySuspended = metricsFactory.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "mevboost_relay_suspended",
+		Help: "Whether a relay is suspended for underpaying its bids",
+	}, []string{"relay"})
+	deliveryDiscrepanciesTotal = metricsFactory.NewCounterVec(prometheus.CounterOpts{
+		Name: "mevboost_delivery_discrepancies_total",
+		Help: "Disagreements between delivered payloads and relay data APIs, by relay and kind",
+	}, []string{"relay", "kind"})
+	storePayloadBytes = metricsFactory.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "mevboost_store_payload_bytes",
+		Help: "Approximate memory used by cached payloads, by store",
+	}, []string{"store"})
+	storePayloadEvictionsTotal = metricsFactory.NewCounterVec(prometheus.CounterOpts{
+		Name: "mevboost_store_payload_evictions_total",
+		Help: "Cached payloads evicted because the payload memory budget was exceeded, by store",
+	}, []string{"store"})
+	bidAnomaliesTotal = metricsFactory.NewCounterVec(prometheus.CounterOpts{
+		Name: "mevboost_bid_anomalies_total",
+		Help: "Anomalies detected when comparing bids across relays, by kind",
+	}, []string{"kind"})
+	relayProbeLatencySeconds = metricsFactory.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "mevboost_relay_probe_latency_seconds",
+		Help: "Moving average of the round trip latency of the probes sent to a relay between proposals",
+	}, []string{"relay"})
+	eventsTotal = metricsFactory.NewCounterVec(prometheus.CounterOpts{
+		Name: "mevboost_events_total",
+		Help: "Events published while serving proposals, by kind",
+	}, []string{"kind"})
+	relayFaultsTotal = metricsFactory.NewCounterVec(prometheus.CounterOpts{
+		Name: "mevboost_relay_faults_total",
+		Help: "Failed relay requests and relay suspensions, by relay and fault",
+	}, []string{"relay", "fault"})
+	relayTimeoutSeconds = metricsFactory.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "mevboost_relay_timeout_seconds",
+		Help: "Timeout of the last call of a relay and method, adapted to the recent latency of the relay",
+	}, []string{"relay", "method"})
+	relayRequestsTotal = metricsFactory.NewCounterVec(prometheus.CounterOpts{
+		Name: "mevboost_relay_requests_total",
+		Help: "Requests sent to relays, by relay, method and result: success or error",
+	}, []string{"relay", "method", "result"})
+	relayRequestDuration = metricsFactory.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "mevboost_relay_request_duration_seconds",
+		Help:    "Duration of relay requests until their response was decoded, by relay and method",
+		Buckets: []float64{0.025, 0.05, 0.1, 0.25, 0.5, 1, 2, 5},
+	}, []string{"relay", "method"})
+	relayBidsTotal = metricsFactory.NewCounterVec(prometheus.CounterOpts{
+		Name: "mevboost_relay_bids_total",
+		Help: "Valid headers received from a relay",
+	}, []string{"relay"})
+	relayBidValueGwei = metricsFactory.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "mevboost_relay_bid_value_gwei",
+		Help:    "Values of the valid headers received from a relay, in gwei",
+		Buckets: prometheus.ExponentialBuckets(1e6, 4, 10), // 0.001 to 262 ETH
+	}, []string{"relay"})
+	headersServedTotal = metricsFactory.NewCounterVec(prometheus.CounterOpts{
+		Name: "mevboost_headers_served_total",
+		Help: "Headers returned to the consensus client, by the relay of the bid",
+	}, []string{"relay"})
+	blocksProposedTotal = metricsFactory.NewCounterVec(prometheus.CounterOpts{
+		Name: "mevboost_blocks_proposed_total",
+		Help: "Payloads revealed to the consensus client for a signed blinded block, by relay",
+	}, []string{"relay"})
+	storeLookupsTotal = metricsFactory.NewCounterVec(prometheus.CounterOpts{
+		Name: "mevboost_store_lookups_total",
+		Help: "Lookups in the store, by entry (payload, forkchoice or bid) and result: hit or miss",
+	}, []string{"entry", "result"})
+)
+
+var gwei = big.NewFloat(1e9)
+
+func weiToGwei(wei *big.Int) float64 {
+	if wei == nil {
+		return 0
+	}
+	res, _ := new(big.Float).Quo(new(big.Float).SetInt(wei), gwei).Float64()
+	return res
+}
+
+// recordEventMetrics counts the events of the event bus
+func recordEventMetrics(_ context.Context, event *Event) {
+	eventsTotal.WithLabelValues(string(event.Kind)).Inc()
+	switch event.Kind {
+	case EventBidReceived:
+		relayBidsTotal.WithLabelValues(event.RelayURL).Inc()
+		relayBidValueGwei.WithLabelValues(event.RelayURL).Observe(weiToGwei(event.Value))
+	case EventBidSelected:
+		headersServedTotal.WithLabelValues(event.RelayURL).Inc()
+	case EventPayloadRevealed:
+		blocksProposedTotal.WithLabelValues(event.RelayURL).Inc()
+	case EventRelayFault:
+		relayFaultsTotal.WithLabelValues(event.RelayURL, event.Fault).Inc()
+		if event.Fault == RelayFaultSuspended {
+			relaySuspended.WithLabelValues(event.RelayURL).Set(1)
+		}
+	}
+}
+
+// recordRelayRequest counts a relay request sent at sentAt. Failed requests include error replies of the relay.
+func recordRelayRequest(relayURL, method string, sentAt time.Time, failed bool) {
+	result := "success"
+	if failed {
+		result = "error"
+	}
+	relayRequestsTotal.WithLabelValues(relayURL, method, result).Inc()
+	relayRequestDuration.WithLabelValues(relayURL, method).Observe(now().Sub(sentAt).Seconds())
+}
+
+// recordStoreLookup counts a lookup of an entry of the in-memory store
+func recordStoreLookup(entry string, hit bool) {
+	result := "miss"
+	if hit {
+		result = "hit"
+	}
+	storeLookupsTotal.WithLabelValues(entry, result).Inc()
+}
@@ -0,0 +1,28 @@
+//go:build !minimal
+
+package lib
+
+import (
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMetricsRegistry_RuntimeMetrics(t *testing.T) {
+	families, err := metricsRegistry.Gather()
+	require.Nil(t, err)
+
+	names := make(map[string]bool, len(families))
+	for _, family := range families {
+		names[family.GetName()] = true
+	}
+
+	wantNames := []string{"go_goroutines", "go_memstats_heap_alloc_bytes", "go_gc_duration_seconds"}
+	if runtime.GOOS == "linux" {
+		wantNames = append(wantNames, "process_open_fds", "process_max_fds", "process_resident_memory_bytes")
+	}
+	for _, name := range wantNames {
+		require.True(t, names[name], name)
+	}
+}
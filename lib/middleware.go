@@ -0,0 +1,149 @@
+package lib
+
+import (
+	"context"
+	"crypto/subtle"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus"
+	"golang.org/x/time/rate"
+)
+
+// Middleware wraps the handlers of the router, for cross-cutting concerns like auth, rate limits or tracing
+type Middleware func(next http.Handler) http.Handler
+
+var (
+	httpRequestsTotal = metricsFactory.NewCounterVec(prometheus.CounterOpts{
+		Name: "mevboost_http_requests_total",
+		Help: "HTTP requests served, by route and status code",
+	}, []string{"route", "code"})
+	httpRequestDuration = metricsFactory.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "mevboost_http_request_duration_seconds",
+		Help:    "Duration of HTTP requests, by route",
+		Buckets: []float64{0.01, 0.05, 0.1, 0.25, 0.5, 1, 2, 5},
+	}, []string{"route"})
+)
+
+// statusRecorder remembers the status code written by a handler
+type statusRecorder struct {
+	http.ResponseWriter
+	code int
+}
+
+func (r *statusRecorder) WriteHeader(code int) {
+	r.code = code
+	r.ResponseWriter.WriteHeader(code)
+}
+
+// Flush passes flushes of streaming handlers through
+func (r *statusRecorder) Flush() {
+	if flusher, ok := r.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// RecoveryMiddleware turns a panicking handler into a 500 response instead of a dropped connection
+func RecoveryMiddleware(log Logger) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			defer func() {
+				if err := recover(); err != nil {
+					log.WithFields(Fields{"panic": err, "path": r.URL.Path}).Error("handler panicked")
+					http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+				}
+			}()
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// BearerTokenMiddleware rejects requests without the token as "Authorization: Bearer <token>" header, with 401 if the header is missing and 403 if the token is wrong
+func BearerTokenMiddleware(token string) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			auth := r.Header.Get("Authorization")
+			if !strings.HasPrefix(auth, "Bearer ") {
+				respondJSON(w, http.StatusUnauthorized, keymanagerError{"missing bearer token"})
+				return
+			}
+			if !matchesToken(strings.TrimPrefix(auth, "Bearer "), []string{token}) {
+				respondJSON(w, http.StatusForbidden, keymanagerError{"invalid bearer token"})
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// matchesToken reports whether token is one of known. All of known are compared, so the timing doesn't tell which
+// token matched.
+func matchesToken(token string, known []string) bool {
+	valid := false
+	for _, k := range known {
+		if subtle.ConstantTimeCompare([]byte(token), []byte(k)) == 1 {
+			valid = true
+		}
+	}
+	return valid
+}
+
+// RateLimitMiddleware answers with 429 when more than perSecond requests arrive on average, allowing bursts of up to burst requests
+func RateLimitMiddleware(perSecond float64, burst int) Middleware {
+	limiter := rate.NewLimiter(rate.Limit(perSecond), burst)
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !limiter.Allow() {
+				http.Error(w, http.StatusText(http.StatusTooManyRequests), http.StatusTooManyRequests)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// MetricsMiddleware records the count and duration of requests per route on /metrics
+func MetricsMiddleware() Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			route := routeOf(r)
+			start := time.Now()
+			recorder := &statusRecorder{ResponseWriter: w, code: http.StatusOK}
+			next.ServeHTTP(recorder, r)
+
+			httpRequestsTotal.WithLabelValues(route, strconv.Itoa(recorder.code)).Inc()
+			httpRequestDuration.WithLabelValues(route).Observe(time.Since(start).Seconds())
+		})
+	}
+}
+
+// SpanStarter starts a span for a request named after its route, e.g. with the OpenTelemetry tracer of a program
+// embedding mev-boost. It returns the context carrying the span, which the handler runs with, and a func ending the
+// span with the status code of the response.
+type SpanStarter func(r *http.Request, route string) (context.Context, func(code int))
+
+// TracingMiddleware starts a span with startSpan for each request, so the relay calls of a handler can be traced as
+// children of the request
+func TracingMiddleware(startSpan SpanStarter) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx, end := startSpan(r, routeOf(r))
+			recorder := &statusRecorder{ResponseWriter: w, code: http.StatusOK}
+			next.ServeHTTP(recorder, r.WithContext(ctx))
+			end(recorder.code)
+		})
+	}
+}
+
+// routeOf returns the path template of the route of r, or its path if it matched no route
+func routeOf(r *http.Request) string {
+	if current := mux.CurrentRoute(r); current != nil {
+		if template, err := current.GetPathTemplate(); err == nil {
+			return template
+		}
+	}
+	return r.URL.Path
+}
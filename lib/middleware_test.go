@@ -0,0 +1,94 @@
+package lib
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestWithMiddleware(t *testing.T) {
+	var order []string
+	tag := func(name string) Middleware {
+		return func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				order = append(order, name)
+				next.ServeHTTP(w, r)
+			})
+		}
+	}
+
+	router, err := NewRouter(context.Background(), WithRelayURLs("http://bar"), WithStore(NewStore()), WithLogger(testLog),
+		WithMiddleware(tag("outer"), BearerTokenMiddleware("secret")), WithMiddleware(tag("inner")))
+	require.Nil(t, err)
+
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/mev-boost/v1/deliveries", nil))
+	require.Equal(t, http.StatusUnauthorized, rr.Code)
+	require.Equal(t, []string{"outer"}, order)
+
+	req := httptest.NewRequest(http.MethodGet, "/mev-boost/v1/deliveries", nil)
+	req.Header.Set("Authorization", "Bearer secret")
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	require.Equal(t, http.StatusOK, rr.Code)
+	require.Equal(t, []string{"outer", "outer", "inner"}, order)
+}
+
+func TestRecoveryMiddleware(t *testing.T) {
+	handler := RecoveryMiddleware(testLog)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic("boom")
+	}))
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/", nil))
+	require.Equal(t, http.StatusInternalServerError, rr.Code)
+}
+
+func TestRateLimitMiddleware(t *testing.T) {
+	handler := RateLimitMiddleware(0.001, 2)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	codes := make([]int, 3)
+	for i := range codes {
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/", nil))
+		codes[i] = rr.Code
+	}
+	require.Equal(t, []int{http.StatusOK, http.StatusOK, http.StatusTooManyRequests}, codes)
+}
+
+func TestTracingMiddleware(t *testing.T) {
+	type spanKey struct{}
+	var routes []string
+	var codes []int
+	startSpan := func(r *http.Request, route string) (context.Context, func(code int)) {
+		routes = append(routes, route)
+		return context.WithValue(r.Context(), spanKey{}, route), func(code int) { codes = append(codes, code) }
+	}
+
+	router, err := NewRouter(context.Background(), WithRelayURLs("http://bar"), WithStore(NewStore()), WithLogger(testLog),
+		WithMiddleware(TracingMiddleware(startSpan), func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				require.NotNil(t, r.Context().Value(spanKey{}), "the handler runs with the context of the span")
+				next.ServeHTTP(w, r)
+			})
+		}))
+	require.Nil(t, err)
+
+	// the preferences API isn't served without its token, requests that match no route bypass the middleware
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/eth/v1/validator/0x01/preferences", nil))
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/mev-boost/v1/deliveries", nil))
+	require.Equal(t, []string{"/mev-boost/v1/deliveries"}, routes)
+	require.Equal(t, []int{http.StatusOK}, codes)
+}
+
+func TestMatchesToken(t *testing.T) {
+	require.True(t, matchesToken("b", []string{"a", "b", "c"}))
+	require.False(t, matchesToken("d", []string{"a", "b", "c"}))
+	require.False(t, matchesToken("", []string{"a"}))
+	require.False(t, matchesToken("a", nil))
+}
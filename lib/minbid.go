@@ -0,0 +1,57 @@
+package lib
+
+import (
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+// weiUnits are the units of ParseWei, by suffix
+var weiUnits = []struct {
+	suffix string
+	wei    *big.Int
+}{
+	{"gwei", big.NewInt(1e9)},
+	{"eth", big.NewInt(1e18)},
+	{"wei", big.NewInt(1)},
+}
+
+// ParseWei parses a value in wei, or in gwei or eth with a suffix, e.g. "50000000gwei" or "0.05eth". Fractions of gwei
+// and eth are allowed as long as the value is a whole number of wei.
+func ParseWei(value string) (*big.Int, error) {
+	number, unit := strings.TrimSpace(value), big.NewInt(1)
+	for _, u := range weiUnits {
+		if strings.HasSuffix(strings.ToLower(number), u.suffix) {
+			number, unit = strings.TrimSpace(number[:len(number)-len(u.suffix)]), u.wei
+			break
+		}
+	}
+	rat, ok := new(big.Rat).SetString(number)
+	if !ok || strings.ContainsAny(number, "/eE") {
+		return nil, fmt.Errorf("invalid value %q, expected wei, or gwei or eth with a suffix", value)
+	}
+	rat.Mul(rat, new(big.Rat).SetInt(unit))
+	if !rat.IsInt() || rat.Sign() < 0 {
+		return nil, fmt.Errorf("invalid value %q, must be a positive whole number of wei", value)
+	}
+	return rat.Num(), nil
+}
+
+// acceptsBid reports whether a bid of value reaches the min bid of WithMinBid and the min bid preference of the
+// proposer with pubkey, if the proposer is known
+func (m *RelayService) acceptsBid(pubkey string, value *big.Int) bool {
+	if m.minBid != nil && value.Cmp(m.minBid) < 0 {
+		return false
+	}
+	if pubkey == "" {
+		return true
+	}
+	preferences := m.preferences.get(pubkey)
+	return preferences == nil || preferences.MinBid == nil || value.Cmp(preferences.MinBid) >= 0
+}
+
+// satisfiesFloor reports whether the effective min bid of tenant, the higher one of the tenant and WithMinBid, meets
+// the bid floor of a relay
+func (m *RelayService) satisfiesFloor(tenant *Tenant, floor *big.Int) bool {
+	return tenant.satisfiesFloor(floor) || floor == nil || m.minBid != nil && m.minBid.Cmp(floor) >= 0
+}
@@ -0,0 +1,111 @@
+package lib
+
+import (
+	"context"
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseWei(t *testing.T) {
+	for value, wei := range map[string]string{
+		"1000":          "1000",
+		"1000wei":       "1000",
+		"50000000gwei":  "50000000000000000",
+		"0.5gwei":       "500000000",
+		"0.05eth":       "50000000000000000",
+		" 1 ETH ":       "1000000000000000000",
+		"0":             "0",
+		"12.000000gwei": "12000000000",
+	} {
+		parsed, err := ParseWei(value)
+		require.Nil(t, err, value)
+		require.Equal(t, wei, parsed.String(), value)
+	}
+	for _, invalid := range []string{"", "eth", "0.5", "0.1wei", "1e18", "1/2eth", "-1gwei", "1 finney"} {
+		_, err := ParseWei(invalid)
+		require.Error(t, err, invalid)
+	}
+}
+
+func TestGetPayloadHeaderV1_MinBid(t *testing.T) {
+	low := newHeaderRelay(t, ExecutionPayloadWithTxRootV1{BlockHash: common.HexToHash("0x01"), BaseFeePerGas: big.NewInt(1), FeeRecipientDiff: big.NewInt(1)})
+	defer low.Close()
+	high := newHeaderRelay(t, ExecutionPayloadWithTxRootV1{BlockHash: common.HexToHash("0x02"), BaseFeePerGas: big.NewInt(1), FeeRecipientDiff: big.NewInt(3)})
+	defer high.Close()
+
+	getHeader := func(minBid int64) (*ExecutionPayloadWithTxRootV1, map[string]string, error) {
+		store := NewStore()
+		for _, relayURL := range []string{low.URL, high.URL} {
+			store.SetForkchoiceResponse(context.Background(), "0x01", relayURL, "0x01")
+		}
+		service, err := newRelayService(WithRelayURLs(low.URL, high.URL), WithStore(store), WithLogger(testLog), WithMinBid(big.NewInt(minBid)))
+		require.Nil(t, err)
+
+		payloadID := "0x01"
+		header := new(ExecutionPayloadWithTxRootV1)
+		err = service.GetPayloadHeaderV1(nil, &payloadID, header)
+		results := make(map[string]string)
+		for _, bid := range service.bids.slot(0) {
+			results[bid.RelayURL] = bid.Result
+		}
+		return header, results, err
+	}
+
+	header, results, err := getHeader(3)
+	require.Nil(t, err)
+	require.Equal(t, common.HexToHash("0x02"), header.BlockHash)
+	require.Equal(t, BidResultWon, results[high.URL])
+
+	_, results, err = getHeader(4)
+	require.Error(t, err, "no bid reaches the min bid")
+	require.Equal(t, map[string]string{low.URL: BidResultBelowMinBid, high.URL: BidResultBelowMinBid}, results)
+}
+
+func TestRelayService_satisfiesFloor(t *testing.T) {
+	service := &RelayService{minBid: big.NewInt(5)}
+	require.True(t, service.satisfiesFloor(nil, nil))
+	require.True(t, service.satisfiesFloor(nil, big.NewInt(5)))
+	require.False(t, service.satisfiesFloor(nil, big.NewInt(6)))
+	require.True(t, service.satisfiesFloor(&Tenant{MinBid: big.NewInt(6)}, big.NewInt(6)), "the higher min bid of the tenant counts")
+	require.True(t, service.satisfiesFloor(&Tenant{MinBid: big.NewInt(1)}, big.NewInt(5)), "the higher global min bid counts")
+	require.False(t, (&RelayService{}).satisfiesFloor(nil, big.NewInt(1)))
+}
+
+func TestGetPayloadHeaderV1_MinBidPreference(t *testing.T) {
+	relay := newHeaderRelay(t, ExecutionPayloadWithTxRootV1{BlockHash: common.HexToHash("0x02"), BaseFeePerGas: big.NewInt(1), FeeRecipientDiff: big.NewInt(3)})
+	defer relay.Close()
+	feeRecipient := common.HexToAddress("0x03")
+	pubkey := hexutil.Bytes(common.FromHex("0x" + strings.Repeat("ab", 48)))
+
+	getHeader := func(preferredMinBid int64) (map[string]string, error) {
+		store := NewStore()
+		store.SetForkchoiceResponse(context.Background(), "0x01", relay.URL, "0x01")
+		store.SetPayloadAttributes(context.Background(), "0x01", &PayloadAttributesV1{Timestamp: 1200, SuggestedFeeRecipient: feeRecipient})
+		service, err := newRelayService(WithRelayURLs(relay.URL), WithStore(store), WithLogger(testLog), WithMinBid(big.NewInt(2)))
+		require.Nil(t, err)
+		service.proposers.register(&ValidatorRegistrationV1{FeeRecipient: feeRecipient, Pubkey: pubkey})
+		service.preferences.update(pubkey.String(), func(p *ValidatorPreferences) { p.MinBid = big.NewInt(preferredMinBid) })
+
+		payloadID := "0x01"
+		err = service.GetPayloadHeaderV1(nil, &payloadID, new(ExecutionPayloadWithTxRootV1))
+		results := make(map[string]string)
+		for _, bid := range service.bids.slot(service.chain.SlotAt(1200)) {
+			results[bid.RelayURL] = bid.Result
+		}
+		return results, err
+	}
+
+	results, err := getHeader(3)
+	require.Nil(t, err)
+	require.Equal(t, BidResultWon, results[relay.URL])
+
+	// above the global min bid, but below the one of the validator
+	results, err = getHeader(4)
+	require.Error(t, err)
+	require.Equal(t, BidResultBelowMinBid, results[relay.URL])
+}
@@ -0,0 +1,120 @@
+package lib
+
+import (
+	"math/big"
+	"net/http"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var maxMissedValues = 10000
+
+var (
+	missedBidsTotal = metricsFactory.NewCounterVec(prometheus.CounterOpts{
+		Name: "mevboost_missed_bids_total",
+		Help: "Delivered payloads for which another relay bid more, by that relay and the result of its bid",
+	}, []string{"relay", "result"})
+	missedValueGweiTotal = metricsFactory.NewCounterVec(prometheus.CounterOpts{
+		Name: "mevboost_missed_value_gwei_total",
+		Help: "Sum of the value other relays bid above delivered payloads, by the relay of the better bid, in gwei",
+	}, []string{"relay"})
+)
+
+// MissedValue is a delivered payload that another relay outbid for the same slot
+type MissedValue struct {
+	Slot      uint64      `json:"slot,string"`
+	BlockHash common.Hash `json:"blockHash"`
+	RelayURL  string      `json:"relayUrl"` // empty if the payload was built locally
+	Value     *big.Int    `json:"value"`
+	// BestRelayURL, BestBlockHash and BestValue are the most valuable bid of another relay for the slot, and BestResult
+	// the result of its validation, see ArchivedBid. Valid bids were ranked lower, e.g. for the reputation of their relay,
+	// or arrived after the header was served.
+	BestRelayURL  string      `json:"bestRelayUrl"`
+	BestBlockHash common.Hash `json:"bestBlockHash"`
+	BestValue     *big.Int    `json:"bestValue"`
+	BestResult    string      `json:"bestResult"`
+	Missed        *big.Int    `json:"missed"` // BestValue minus Value
+}
+
+// missedValueLog keeps the most recent deliveries that were outbid
+type missedValueLog struct {
+	mu     sync.RWMutex
+	missed []*MissedValue
+}
+
+func (l *missedValueLog) add(missed *MissedValue) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.missed = append(l.missed, missed)
+	if len(l.missed) > maxMissedValues {
+		l.missed = l.missed[len(l.missed)-maxMissedValues:]
+	}
+}
+
+// all returns copies of all missed values, oldest first
+func (l *missedValueLog) all() []MissedValue {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	missed := make([]MissedValue, len(l.missed))
+	for i, m := range l.missed {
+		missed[i] = *m
+	}
+	return missed
+}
+
+// checkMissedValue compares a delivery to the bids other relays made for its slot, and records and logs the value
+// missed if one of them was more valuable
+func (m *RelayService) checkMissedValue(delivery *DeliveredPayload) {
+	if m.missedValues == nil {
+		return
+	}
+	value := delivery.Value
+	if value == nil {
+		value = new(big.Int)
+	}
+	var best *ArchivedBid
+	for _, bid := range m.bids.slot(delivery.Slot) {
+		bid := bid
+		if bid.RelayURL == delivery.RelayURL || bid.Value == nil || bid.Value.Cmp(value) <= 0 {
+			continue
+		}
+		if best == nil || bid.Value.Cmp(best.Value) > 0 {
+			best = &bid
+		}
+	}
+	if best == nil {
+		return
+	}
+
+	missed := &MissedValue{
+		Slot:          delivery.Slot,
+		BlockHash:     delivery.BlockHash,
+		RelayURL:      delivery.RelayURL,
+		Value:         value,
+		BestRelayURL:  best.RelayURL,
+		BestBlockHash: best.BlockHash,
+		BestValue:     best.Value,
+		BestResult:    best.Result,
+		Missed:        new(big.Int).Sub(best.Value, value),
+	}
+	m.missedValues.add(missed)
+	missedBidsTotal.WithLabelValues(best.RelayURL, best.Result).Inc()
+	missedValueGweiTotal.WithLabelValues(best.RelayURL).Add(weiToGwei(missed.Missed))
+	m.log.WithFields(Fields{
+		"slot":      missed.Slot,
+		"url":       missed.RelayURL,
+		"value":     missed.Value,
+		"bestUrl":   missed.BestRelayURL,
+		"bestValue": missed.BestValue,
+		"result":    missed.BestResult,
+		"missed":    missed.Missed,
+	}).Info("another relay bid more than the delivered payload")
+}
+
+func (m *RelayService) handleMissedValues(w http.ResponseWriter, _ *http.Request) {
+	respondJSON(w, http.StatusOK, m.missedValues.all())
+}
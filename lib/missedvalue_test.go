@@ -0,0 +1,55 @@
+package lib
+
+import (
+	"context"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRelayService_MissedValue(t *testing.T) {
+	service, err := newRelayService(WithRelayURLs("http://relay-a", "http://relay-b", "http://relay-c"), WithStore(NewStore()), WithLogger(testLog), WithMissedValueTracking())
+	require.Nil(t, err)
+	for _, bid := range []*ArchivedBid{
+		{Slot: 5, RelayURL: "http://relay-a", BlockHash: common.HexToHash("0x0a"), Value: big.NewInt(10), Result: BidResultWon},
+		{Slot: 5, RelayURL: "http://relay-a", BlockHash: common.HexToHash("0x0b"), Value: big.NewInt(20), Result: BidResultValid},
+		{Slot: 5, RelayURL: "http://relay-b", BlockHash: common.HexToHash("0x0c"), Value: big.NewInt(12), Result: BidResultValid},
+		{Slot: 5, RelayURL: "http://relay-c", BlockHash: common.HexToHash("0x0d"), Value: big.NewInt(15), Result: BidResultInvalid},
+		{Slot: 6, RelayURL: "http://relay-c", BlockHash: common.HexToHash("0x0e"), Value: big.NewInt(30), Result: BidResultInvalid},
+	} {
+		service.bids.add(bid)
+	}
+
+	deliver := func(slot uint64, blockHash string, value int64) {
+		payload := &ExecutionPayloadWithTxRootV1{BlockHash: common.HexToHash(blockHash), FeeRecipientDiff: big.NewInt(value)}
+		service.recordDelivery(context.Background(), &BlindedBeaconBlock{Slot: slot}, payload, "http://relay-a")
+	}
+	deliver(5, "0x0a", 10)
+	deliver(6, "0x0f", 30)
+
+	missed := service.missedValues.all()
+	require.Len(t, missed, 1, "only more valuable bids of other relays count")
+	require.Equal(t, MissedValue{
+		Slot:          5,
+		BlockHash:     common.HexToHash("0x0a"),
+		RelayURL:      "http://relay-a",
+		Value:         big.NewInt(10),
+		BestRelayURL:  "http://relay-c",
+		BestBlockHash: common.HexToHash("0x0d"),
+		BestValue:     big.NewInt(15),
+		BestResult:    BidResultInvalid,
+		Missed:        big.NewInt(5),
+	}, missed[0])
+
+	rr := httptest.NewRecorder()
+	service.handleMissedValues(rr, httptest.NewRequest(http.MethodGet, "/mev-boost/v1/bids/missed", nil))
+	require.Equal(t, http.StatusOK, rr.Code)
+	var listed []MissedValue
+	require.Nil(t, json.Unmarshal(rr.Body.Bytes(), &listed))
+	require.Equal(t, missed, listed)
+}
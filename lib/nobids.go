@@ -0,0 +1,45 @@
+package lib
+
+import (
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+// NoBidsBehavior selects how builder_getPayloadHeaderV1 answers when no relay returned a valid bid, since consensus
+// clients differ in what makes them fall back to their own execution client
+type NoBidsBehavior string
+
+// Behaviors of WithNoBidsBehavior
+const (
+	// NoBidsError returns the error of the failure, ErrNoBids, ErrRelayTimeout or ErrValidationFailed
+	NoBidsError NoBidsBehavior = "error"
+	// NoBidsLocal always returns ErrBuildLocally, asking the consensus client to propose the payload of its own execution
+	// client, whatever made the relays fail
+	NoBidsLocal NoBidsBehavior = "local"
+	// NoBidsEmpty returns a zero-value header without error, for consensus clients that treat a zero block hash as no bid
+	NoBidsEmpty NoBidsBehavior = "empty"
+)
+
+// ParseNoBidsBehavior parses the name of a no bids behavior
+func ParseNoBidsBehavior(name string) (NoBidsBehavior, error) {
+	switch behavior := NoBidsBehavior(strings.ToLower(name)); behavior {
+	case NoBidsError, NoBidsLocal, NoBidsEmpty:
+		return behavior, nil
+	}
+	return "", fmt.Errorf("unknown no bids behavior %q, expected error, local or empty", name)
+}
+
+// noBids answers a header request for payloadID that got no valid bid, failures are the relay failures of the request
+func (b NoBidsBehavior) noBids(payloadID *hexutil.Bytes, failures *relayFailures, result *ExecutionPayloadWithTxRootV1) error {
+	switch b {
+	case NoBidsLocal:
+		return newMethodError(ErrBuildLocally, "no valid response from relay for payloadID %s, build the payload locally", payloadID)
+	case NoBidsEmpty:
+		*result = ExecutionPayloadWithTxRootV1{BaseFeePerGas: new(big.Int), FeeRecipientDiff: new(big.Int)}
+		return nil
+	}
+	return newMethodError(failures.kind(ErrNoBids), "no valid response from relay for payloadID %s", payloadID)
+}
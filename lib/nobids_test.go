@@ -0,0 +1,53 @@
+package lib
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetPayloadHeaderV1_NoBidsBehavior(t *testing.T) {
+	relay := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		resp, err := formatErrorResponse("no bid")
+		require.Nil(t, err)
+		w.Write(resp)
+	}))
+	defer relay.Close()
+
+	getHeader := func(opts ...Option) (*ExecutionPayloadWithTxRootV1, error) {
+		store := NewStore()
+		store.SetForkchoiceResponse(context.Background(), "0x01", relay.URL, "0x01")
+		service, err := newRelayService(append([]Option{WithRelayURLs(relay.URL), WithStore(store), WithLogger(testLog)}, opts...)...)
+		require.Nil(t, err)
+		payloadID := "0x01"
+		header := new(ExecutionPayloadWithTxRootV1)
+		return header, service.GetPayloadHeaderV1(nil, &payloadID, header)
+	}
+
+	_, err := getHeader()
+	require.ErrorIs(t, err, ErrNoBids)
+	_, err = getHeader(WithNoBidsBehavior(NoBidsError))
+	require.ErrorIs(t, err, ErrNoBids)
+
+	_, err = getHeader(WithNoBidsBehavior(NoBidsLocal))
+	require.ErrorIs(t, err, ErrBuildLocally)
+	require.Equal(t, ErrorCodeBuildLocally, err.(*MethodError).ErrorCode())
+
+	header, err := getHeader(WithNoBidsBehavior(NoBidsEmpty))
+	require.Nil(t, err)
+	require.Equal(t, common.Hash{}, header.BlockHash)
+	_, err = formatResponse(header)
+	require.Nil(t, err)
+}
+
+func TestParseNoBidsBehavior(t *testing.T) {
+	behavior, err := ParseNoBidsBehavior("Local")
+	require.Nil(t, err)
+	require.Equal(t, NoBidsLocal, behavior)
+	_, err = ParseNoBidsBehavior("fallback")
+	require.Error(t, err)
+}
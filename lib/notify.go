@@ -0,0 +1,71 @@
+package lib
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+)
+
+// Notification is posted to the operator webhook when something needs attention
+type Notification struct {
+	Event   string                 `json:"event"`
+	Message string                 `json:"message"`
+	Fields  map[string]interface{} `json:"fields,omitempty"`
+}
+
+// webhookNotifier posts notifications as JSON to a webhook URL. A nil or unconfigured notifier only logs.
+type webhookNotifier struct {
+	url string
+	log Logger
+}
+
+func newWebhookNotifier(url string, log Logger) *webhookNotifier {
+	return &webhookNotifier{
+		url: url,
+		log: log.WithField("prefix", "lib/notify"),
+	}
+}
+
+func (n *webhookNotifier) notify(notification *Notification) {
+	if n == nil || n.url == "" {
+		return
+	}
+
+	go func() {
+		body, err := json.Marshal(notification)
+		if err != nil {
+			n.log.WithError(err).Error("could not encode notification")
+			return
+		}
+
+		req, err := http.NewRequestWithContext(context.Background(), http.MethodPost, n.url, bytes.NewReader(body))
+		if err != nil {
+			n.log.WithError(err).Error("could not create notification request")
+			return
+		}
+		req.Header.Add("Content-Type", "application/json")
+
+		resp, err := httpClient.Do(req)
+		if err != nil {
+			n.log.WithError(err).Error("could not send notification")
+			return
+		}
+		resp.Body.Close()
+		if resp.StatusCode >= 300 {
+			n.log.WithField("status", resp.StatusCode).Error("notification webhook returned an error")
+		}
+	}()
+}
+
+// onEvent notifies the suspension of relays
+func (n *webhookNotifier) onEvent(_ context.Context, event *Event) {
+	if event.Kind != EventRelayFault || event.Fault != RelayFaultSuspended {
+		return
+	}
+	n.notify(&Notification{
+		Event:   "relay_suspended",
+		Message: "relay suspended because its payments fell short of promised bids",
+		Fields:  event.Fields,
+	})
+}
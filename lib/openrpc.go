@@ -0,0 +1,328 @@
+package lib
+
+import (
+	"encoding"
+	"math/big"
+	"net/http"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+const (
+	openRPCVersion = "1.2.6"
+	pathOpenRPC    = "/mev-boost/v1/openrpc.json"
+)
+
+// OpenRPCDocument describes the JSON-RPC methods an instance serves, see https://spec.open-rpc.org
+type OpenRPCDocument struct {
+	OpenRPC    string            `json:"openrpc"`
+	Info       OpenRPCInfo       `json:"info"`
+	Methods    []OpenRPCMethod   `json:"methods"`
+	Components OpenRPCComponents `json:"components"`
+}
+
+// OpenRPCInfo is the title and API version of an OpenRPCDocument, the version is the builder spec version
+type OpenRPCInfo struct {
+	Title   string `json:"title"`
+	Version string `json:"version"`
+}
+
+// OpenRPCMethod is a JSON-RPC method. Methods only served over WebSocket have the x-transport extension.
+type OpenRPCMethod struct {
+	Name       string                     `json:"name"`
+	Summary    string                     `json:"summary"`
+	Params     []OpenRPCContentDescriptor `json:"params"`
+	Result     OpenRPCContentDescriptor   `json:"result"`
+	Deprecated bool                       `json:"deprecated,omitempty"`
+	Transport  string                     `json:"x-transport,omitempty"`
+}
+
+// OpenRPCContentDescriptor is a param or result of a method
+type OpenRPCContentDescriptor struct {
+	Name     string     `json:"name"`
+	Required bool       `json:"required,omitempty"`
+	Schema   JSONSchema `json:"schema"`
+}
+
+// OpenRPCComponents holds the schemas of the named types referenced by the methods
+type OpenRPCComponents struct {
+	Schemas map[string]JSONSchema `json:"schemas"`
+}
+
+// JSONSchema is a JSON schema of a param or result type
+type JSONSchema map[string]interface{}
+
+// rpcMethodSpec is a method served on the JSON-RPC endpoint, the types of params and result are given as values
+type rpcMethodSpec struct {
+	name    string
+	summary string
+	params  []rpcParamSpec
+	result  rpcParamSpec
+}
+
+type rpcParamSpec struct {
+	name     string
+	value    interface{}
+	optional bool
+}
+
+var (
+	specForkchoiceUpdated = rpcMethodSpec{
+		name:    methodForkchoiceUpdated,
+		summary: "Forwards the forkchoice state and payload attributes to the relays and returns a payload id for the bids",
+		params: []rpcParamSpec{
+			{name: "forkchoiceState", value: ForkchoiceStateV1{}},
+			{name: "payloadAttributes", value: PayloadAttributesV1{}, optional: true},
+		},
+		result: rpcParamSpec{name: "response", value: ForkChoiceResponse{}},
+	}
+	specGetPayloadHeader = rpcMethodSpec{
+		name:    "builder_getPayloadHeaderV1",
+		summary: "Returns the header of the best bid of the relays for a payload id",
+		params:  []rpcParamSpec{{name: "payloadId", value: hexutil.Bytes{}}},
+		result:  rpcParamSpec{name: "header", value: ExecutionPayloadWithTxRootV1{}},
+	}
+	specProposeBlindedBlock = rpcMethodSpec{
+		name:    "builder_proposeBlindedBlockV1",
+		summary: "Submits the signed blinded block and returns the payload revealed by the relay of its header",
+		params:  []rpcParamSpec{{name: "signedBlindedBeaconBlock", value: SignedBlindedBeaconBlock{}}},
+		result:  rpcParamSpec{name: "payload", value: ExecutionPayloadWithTxRootV1{}},
+	}
+	specRegisterValidator = rpcMethodSpec{
+		name:    "builder_registerValidatorV1",
+		summary: "Verifies and caches the signed fee recipient and gas limit registrations of validators and broadcasts them to the relays",
+		params:  []rpcParamSpec{{name: "signedValidatorRegistrations", value: []SignedValidatorRegistrationV1{}}},
+		result:  rpcParamSpec{name: "status", value: ""},
+	}
+	specGetCapabilities = rpcMethodSpec{
+		name:    methodRelayGetCapabilities,
+		summary: "Returns the relay methods served to downstream mev-boost instances and the bid floor of the relays",
+		result:  rpcParamSpec{name: "capabilities", value: RelayCapabilities{}},
+	}
+	specSubscribe = rpcMethodSpec{
+		name:    methodSubscribe,
+		summary: `Pushes the header of the best bid for a payload id with builder_subscription notifications whenever a more valuable bid arrives, until a third into its slot. The kind is "bestBid".`,
+		params: []rpcParamSpec{
+			{name: "kind", value: ""},
+			{name: "payloadId", value: hexutil.Bytes{}},
+		},
+		result: rpcParamSpec{name: "subscriptionId", value: ""},
+	}
+	specUnsubscribe = rpcMethodSpec{
+		name:    methodUnsubscribe,
+		summary: "Closes a best bid subscription",
+		params:  []rpcParamSpec{{name: "subscriptionId", value: ""}},
+		result:  rpcParamSpec{name: "closed", value: true},
+	}
+)
+
+// renamed returns the spec of the method under another name
+func (s rpcMethodSpec) renamed(name string) rpcMethodSpec {
+	s.name = name
+	return s
+}
+
+// servedMethods returns the methods served with the configuration of the service, and the schemas of the named types
+// they reference
+func (m *RelayService) servedMethods(policy DeprecatedMethodPolicy) ([]OpenRPCMethod, map[string]JSONSchema) {
+	schemas := newSchemaGenerator()
+	var methods []OpenRPCMethod
+	add := func(spec rpcMethodSpec, deprecated bool, transport string) {
+		method := OpenRPCMethod{
+			Name:       spec.name,
+			Summary:    spec.summary,
+			Params:     []OpenRPCContentDescriptor{},
+			Result:     OpenRPCContentDescriptor{Name: spec.result.name, Schema: schemas.schemaOf(reflect.TypeOf(spec.result.value))},
+			Deprecated: deprecated,
+			Transport:  transport,
+		}
+		for _, param := range spec.params {
+			method.Params = append(method.Params, OpenRPCContentDescriptor{
+				Name:     param.name,
+				Required: !param.optional,
+				Schema:   schemas.schemaOf(reflect.TypeOf(param.value)),
+			})
+		}
+		methods = append(methods, method)
+	}
+
+	current := []rpcMethodSpec{
+		specForkchoiceUpdated, specGetPayloadHeader, specProposeBlindedBlock, specRegisterValidator,
+		specForkchoiceUpdated.renamed(methodForkchoiceUpdatedV2), specGetPayloadHeader.renamed("builder_getPayloadHeaderV2"), specProposeBlindedBlock.renamed("builder_proposeBlindedBlockV2"),
+	}
+	for _, spec := range current {
+		add(spec, false, "")
+	}
+	if policy == DeprecatedTranslate {
+		names := make([]string, 0, len(deprecatedMethods))
+		for name := range deprecatedMethods {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			for _, spec := range current {
+				if spec.name == deprecatedMethods[name] {
+					add(spec.renamed(name), true, "")
+				}
+			}
+		}
+	}
+	if m.aggregator {
+		add(specGetPayloadHeader.renamed(methodRelayGetHeader), false, "")
+		add(specProposeBlindedBlock.renamed(methodRelayProposeBlock), false, "")
+		add(specRegisterValidator.renamed(methodRelayRegisterValidator), false, "")
+		add(specGetPayloadHeader.renamed(methodRelayGetHeaderV2), false, "")
+		add(specProposeBlindedBlock.renamed(methodRelayProposeBlockV2), false, "")
+		add(specGetCapabilities, false, "")
+	}
+	if m.pushInterval > 0 {
+		add(specSubscribe, false, "websocket")
+		add(specUnsubscribe, false, "websocket")
+	}
+	return methods, schemas.components
+}
+
+// openRPCDocument describes the methods the service serves
+func (m *RelayService) openRPCDocument(policy DeprecatedMethodPolicy) *OpenRPCDocument {
+	methods, schemas := m.servedMethods(policy)
+	return &OpenRPCDocument{
+		OpenRPC:    openRPCVersion,
+		Info:       OpenRPCInfo{Title: "mev-boost", Version: builderSpecVersion},
+		Methods:    methods,
+		Components: OpenRPCComponents{Schemas: schemas},
+	}
+}
+
+// handleOpenRPC serves the OpenRPC document of the service, it doesn't change while the service runs
+func handleOpenRPC(document *OpenRPCDocument) http.HandlerFunc {
+	return func(w http.ResponseWriter, _ *http.Request) {
+		respondJSON(w, http.StatusOK, document)
+	}
+}
+
+// schemaEncodings are types whose JSON encoding is described by the fields of another type
+var schemaEncodings = map[reflect.Type]reflect.Type{
+	reflect.TypeOf(ExecutionPayloadHeaderV1{}): reflect.TypeOf(executionPayloadHeaderJSON{}),
+}
+
+// schemaFieldOverrides are types generated by gencodec, whose fields are encoded as the fields of the same name of
+// another type
+var schemaFieldOverrides = map[reflect.Type]reflect.Type{
+	reflect.TypeOf(ExecutionPayloadWithTxRootV1{}): reflect.TypeOf(executionPayloadHeaderMarshaling{}),
+}
+
+var (
+	bigIntType        = reflect.TypeOf(big.Int{})
+	quotedUint64sType = reflect.TypeOf(QuotedUint64s{})
+	textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+)
+
+// schemaGenerator derives JSON schemas from Go types by their encoding/json encoding. Named structs are added to the
+// components and referenced.
+type schemaGenerator struct {
+	components map[string]JSONSchema
+}
+
+func newSchemaGenerator() *schemaGenerator {
+	return &schemaGenerator{components: make(map[string]JSONSchema)}
+}
+
+func (g *schemaGenerator) schemaOf(t reflect.Type) JSONSchema {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	switch {
+	case t == bigIntType:
+		return JSONSchema{"type": "integer"}
+	case t == quotedUint64sType:
+		return JSONSchema{"type": "array", "items": JSONSchema{"type": "string", "pattern": "^[0-9]+$"}}
+	case t.Implements(textMarshalerType) || reflect.PtrTo(t).Implements(textMarshalerType):
+		if t.PkgPath() == "github.com/ethereum/go-ethereum/common/hexutil" || t.Kind() == reflect.Array {
+			return JSONSchema{"type": "string", "pattern": "^0x[0-9a-fA-F]*$"}
+		}
+		return JSONSchema{"type": "string"}
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return JSONSchema{"type": "boolean"}
+	case reflect.String:
+		return JSONSchema{"type": "string"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return JSONSchema{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return JSONSchema{"type": "number"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return JSONSchema{"type": "string", "contentEncoding": "base64"}
+		}
+		return JSONSchema{"type": "array", "items": g.schemaOf(t.Elem())}
+	case reflect.Map:
+		return JSONSchema{"type": "object", "additionalProperties": g.schemaOf(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return g.structSchema(t)
+		}
+		if _, ok := g.components[t.Name()]; !ok {
+			g.components[t.Name()] = nil // referenced while its fields are generated
+			g.components[t.Name()] = g.structSchema(t)
+		}
+		return JSONSchema{"$ref": "#/components/schemas/" + t.Name()}
+	}
+	return JSONSchema{}
+}
+
+// structSchema is the schema of the fields of a struct. Fields tagged gencodec:"required" are required.
+func (g *schemaGenerator) structSchema(t reflect.Type) JSONSchema {
+	overrides := schemaFieldOverrides[t]
+	if encoded, ok := schemaEncodings[t]; ok {
+		t = encoded
+	}
+
+	properties := JSONSchema{}
+	required := []string{}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.PkgPath != "" { // unexported
+			continue
+		}
+		name, opts := field.Name, ""
+		if tag, ok := field.Tag.Lookup("json"); ok {
+			if tag == "-" {
+				continue
+			}
+			name, opts = tag, ""
+			if i := strings.Index(tag, ","); i >= 0 {
+				name, opts = tag[:i], tag[i:]
+			}
+			if name == "" {
+				name = field.Name
+			}
+		}
+
+		fieldType := field.Type
+		if overrides != nil {
+			if override, ok := overrides.FieldByName(field.Name); ok {
+				fieldType = override.Type
+			}
+		}
+		if strings.Contains(opts, ",string") {
+			properties[name] = JSONSchema{"type": "string", "pattern": "^[0-9]+$"}
+		} else {
+			properties[name] = g.schemaOf(fieldType)
+		}
+		if field.Tag.Get("gencodec") == "required" {
+			required = append(required, name)
+		}
+	}
+
+	schema := JSONSchema{"type": "object", "properties": properties}
+	if len(required) > 0 {
+		schema["required"] = required
+	}
+	return schema
+}
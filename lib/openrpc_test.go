@@ -0,0 +1,76 @@
+package lib
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRelayService_openRPCDocument(t *testing.T) {
+	methodNames := func(document *OpenRPCDocument) []string {
+		names := []string{}
+		for _, method := range document.Methods {
+			names = append(names, method.Name)
+		}
+		return names
+	}
+
+	service, err := newRelayService(WithRelayURLs("http://relay"), WithLogger(testLog))
+	require.Nil(t, err)
+	document := service.openRPCDocument(DeprecatedReject)
+	require.Equal(t, []string{
+		"engine_forkchoiceUpdatedV1", "builder_getPayloadHeaderV1", "builder_proposeBlindedBlockV1", "builder_registerValidatorV1",
+		"engine_forkchoiceUpdatedV2", "builder_getPayloadHeaderV2", "builder_proposeBlindedBlockV2",
+	}, methodNames(document))
+	require.Equal(t, builderSpecVersion, document.Info.Version)
+	require.False(t, document.Methods[0].Params[1].Required, "payload attributes are optional")
+
+	document = service.openRPCDocument(DeprecatedTranslate)
+	require.Len(t, document.Methods, 7+len(deprecatedMethods))
+	require.Equal(t, "builder_getHeaderV1", document.Methods[7].Name)
+	require.True(t, document.Methods[7].Deprecated)
+
+	service, err = newRelayService(WithRelayURLs("http://relay"), WithLogger(testLog), WithAggregatorMode(), WithBidSubscriptions(time.Second))
+	require.Nil(t, err)
+	require.Equal(t, []string{
+		"engine_forkchoiceUpdatedV1", "builder_getPayloadHeaderV1", "builder_proposeBlindedBlockV1", "builder_registerValidatorV1",
+		"engine_forkchoiceUpdatedV2", "builder_getPayloadHeaderV2", "builder_proposeBlindedBlockV2",
+		"relay_getPayloadHeaderV1", "relay_proposeBlindedBlockV1", "relay_registerValidatorV1", "relay_getPayloadHeaderV2", "relay_proposeBlindedBlockV2", "relay_getCapabilitiesV1",
+		"builder_subscribe", "builder_unsubscribe",
+	}, methodNames(service.openRPCDocument(DeprecatedReject)))
+
+	// schemas follow the JSON encoding of the types
+	payload := document.Components.Schemas["ExecutionPayloadWithTxRootV1"]
+	properties := payload["properties"].(JSONSchema)
+	require.Equal(t, JSONSchema{"type": "string", "pattern": "^0x[0-9a-fA-F]*$"}, properties["blockNumber"], "gencodec field overrides apply")
+	require.Equal(t, JSONSchema{"type": "integer"}, properties["feeRecipientDiff"])
+	require.Contains(t, payload["required"], "blockHash")
+	require.NotContains(t, payload["required"], "transactions")
+	header := document.Components.Schemas["ExecutionPayloadHeaderV1"]["properties"].(JSONSchema)
+	require.Equal(t, JSONSchema{"type": "string", "pattern": "^[0-9]+$"}, header["block_number"], "the beacon API encoding applies")
+	for name, schema := range document.Components.Schemas {
+		require.NotNil(t, schema, name)
+	}
+}
+
+func TestOpenRPCEndpoint(t *testing.T) {
+	router, err := NewRouter(context.Background(), WithRelayURLs("http://relay"), WithLogger(testLog))
+	require.Nil(t, err)
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + pathOpenRPC)
+	require.Nil(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	document := new(OpenRPCDocument)
+	require.Nil(t, json.NewDecoder(resp.Body).Decode(document))
+	require.Equal(t, openRPCVersion, document.OpenRPC)
+	require.Equal(t, "engine_forkchoiceUpdatedV1", document.Methods[0].Name)
+	require.Contains(t, document.Components.Schemas, "SignedBlindedBeaconBlock")
+}
@@ -0,0 +1,859 @@
+package lib
+
+import (
+	"context"
+	"io"
+	"log"
+	"math/big"
+	"net/http"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"google.golang.org/grpc"
+)
+
+// Option configures the router created by NewRouter
+type Option func(*routerConfig)
+
+// routerConfig holds the settings of NewRouter, the defaults are set by newRouterConfig
+type routerConfig struct {
+	relayURLs               []string
+	relays                  []RelayConfig // nil unless set by WithRelays, with the options of relayURLs at the same index
+	relayReloader           *RelayReloader
+	store                   Store
+	log                     Logger
+	httpClient              *http.Client
+	relayTransport          *RelayTransportConfig
+	relayRetries            *RetryPolicy
+	chain                   *ChainConfig
+	underpaymentTolerance   float64
+	underpaymentWindow      int
+	suspensionExpiry        time.Duration
+	notifyWebhookURL        string
+	relayJitter             time.Duration
+	deterministicRelayOrder bool
+	capabilityCheckInterval time.Duration
+	relayProbeInterval      time.Duration
+	readinessInterval       time.Duration
+	readinessEngines        []*ExecutionClient
+	reconcileInterval       time.Duration
+	reconcileGrace          time.Duration
+	finalityBeacon          *BeaconClient
+	verifyBeacon            *BeaconClient
+	verifyExecution         *ExecutionClient
+	signatureBeacon         *BeaconClient
+	signatureFailOpen       bool
+	bidSignatures           bool
+	prefetchBeacon          *BeaconClient
+	chainCheckBeacon        *BeaconClient
+	headerChecks            *headerChecks
+	paymentExecutionClient  *ExecutionClient
+	localExecutionClients   []*ExecutionClient
+	payloadIDExpirySlots    int
+	registrationInterval    time.Duration
+	forkchoiceDedupWindow   time.Duration
+	requestBudget           time.Duration
+	headerRaceCutoff        time.Duration
+	bidSubscriptionInterval time.Duration
+	webSocketRPC            bool
+	webSocketReadTimeout    time.Duration
+	jwtSecret               []byte
+	proposerAuth            *ProposerAuth
+	methodRateLimits        map[string]MethodRateLimit
+	maxConcurrentRequests   int
+	shedQueued              int
+	shedWait                time.Duration
+	ntpServer               string
+	clockSkewThreshold      time.Duration
+	adjustForClockSkew      bool
+	relayTimings            bool
+	relayCallLog            bool
+	relayTimingsOut         io.Writer
+	minRelayTimeout         time.Duration
+	maxRelayTimeout         time.Duration
+	relayMethodTimeouts     map[string]time.Duration
+	relayFailureThreshold   int
+	relayCooldown           time.Duration
+	graphql                 bool
+	grpcServer              *grpc.Server
+	maxHeaderResponseSize   int64
+	maxPayloadResponseSize  int64
+	payloadCompression      bool
+	payloadEscrow           bool
+	simulationClient        *ExecutionClient
+	simulationTimeout       time.Duration
+	proposalFanOut          bool
+	builderAPI              bool
+	relaySSZ                bool
+	minBid                  *big.Int
+	unknownFields           FieldPolicy
+	missingFields           FieldPolicy
+	jsonLimits              jsonLimits
+	maxRequestBodySize      int64
+	strictContentTypes      bool
+	traceContext            bool
+	spanEndpoint            string
+	spanService             string
+	cors                    *corsPolicy
+	adminToken              string
+	pprof                   bool
+	separateAdmin           bool
+	clientCompat            ClientCompat
+	noBids                  NoBidsBehavior
+	deprecatedMethods       DeprecatedMethodPolicy
+	aggregator              bool
+	whitelabel              *whitelabelUsers
+	tenants                 []Tenant
+	relayGroups             *RelayGroups
+	validatorRelays         *ValidatorRelays
+	revealWeighting         *revealWeighting
+	defaultFeeRecipient     common.Address
+	validatorPubkeys        []string
+	signer                  Signer
+	preferencesAPIToken     string
+	adminAPIToken           string
+	logLevel                *LogLevelControl
+	stableHeaders           bool
+	equivocation            *EquivocationGuard
+	validation              ValidationPolicy
+	hooks                   Hooks
+	eventSubscribers        []eventSubscription
+	auditSinks              []AuditSink
+	payoutCSV               *PayoutCSVFile
+	passthrough             *methodPassthrough
+	missedValue             bool
+	middleware              []Middleware
+	bidDecision             BidDecision
+	bidSelector             BidSelector
+}
+
+// newRouterConfig applies opts, ctx bounds the cleanup loop of the default store
+func newRouterConfig(ctx context.Context, opts ...Option) *routerConfig {
+	cfg := &routerConfig{
+		httpClient:   &httpClient,
+		chain:        MainnetChainConfig,
+		clientCompat: CompatAuto,
+		noBids:       NoBidsError,
+
+		maxHeaderResponseSize:  maxRelayHeaderResponseSize,
+		maxPayloadResponseSize: maxRelayResponseSize,
+		unknownFields:          FieldsIgnore,
+		deprecatedMethods:      DeprecatedTranslate,
+		missingFields:          FieldsIgnore,
+		jsonLimits:             defaultJSONLimits,
+		maxRequestBodySize:     defaultMaxRequestBodySize,
+		reconcileGrace:         defaultReconcileGrace,
+	}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+
+	if cfg.relayTransport != nil {
+		cfg.httpClient = tunedRelayClient(cfg.httpClient, *cfg.relayTransport)
+	}
+	if cfg.log == nil {
+		cfg.log = NewStdLogger(log.Default())
+	}
+	if cfg.store == nil {
+		chain := cfg.chain
+		if chain == nil {
+			chain = MainnetChainConfig
+		}
+		cfg.store = NewStoreWithCleanup(ctx, WithSlotDuration(chain.SlotDuration()), WithStoreLogger(cfg.log))
+	}
+	return cfg
+}
+
+// ValidationPolicy adds checks of relay responses to the built-in ones. A non-nil error rejects the response.
+type ValidationPolicy struct {
+	// Header checks a payload header returned by getPayloadHeader, it's called concurrently for the headers of different relays
+	Header func(ctx context.Context, relayURL string, header *ExecutionPayloadWithTxRootV1) error
+	// Payload checks a payload revealed by proposeBlindedBlock
+	Payload func(ctx context.Context, relayURL string, payload *ExecutionPayloadWithTxRootV1) error
+}
+
+func (p ValidationPolicy) validateHeader(ctx context.Context, relayURL string, header *ExecutionPayloadWithTxRootV1) error {
+	if p.Header == nil {
+		return nil
+	}
+	return p.Header(ctx, relayURL, header)
+}
+
+func (p ValidationPolicy) validatePayload(ctx context.Context, relayURL string, payload *ExecutionPayloadWithTxRootV1) error {
+	if p.Payload == nil {
+		return nil
+	}
+	return p.Payload(ctx, relayURL, payload)
+}
+
+// Hooks are called when the router accepts relay responses. They run synchronously and must not block.
+type Hooks struct {
+	// OnHeader is called for each valid payload header a relay returned
+	OnHeader func(ctx context.Context, relayURL string, header *ExecutionPayloadWithTxRootV1)
+	// OnPayload is called with the payload revealed to the consensus client
+	OnPayload func(ctx context.Context, relayURL string, payload *ExecutionPayloadWithTxRootV1)
+}
+
+// onEvent calls the hooks of bid received and payload revealed events
+func (h Hooks) onEvent(ctx context.Context, event *Event) {
+	switch {
+	case event.Kind == EventBidReceived && h.OnHeader != nil:
+		h.OnHeader(ctx, event.RelayURL, event.Payload)
+	case event.Kind == EventPayloadRevealed && h.OnPayload != nil:
+		h.OnPayload(ctx, event.RelayURL, event.Payload)
+	}
+}
+
+// WithRelayURLs sets the relays to proxy to, at least one is required
+func WithRelayURLs(relayURLs ...string) Option {
+	return func(c *routerConfig) {
+		c.relayURLs = relayURLs
+		c.relays = nil
+	}
+}
+
+// WithRelays sets the relays to proxy to with options of their own, instead of WithRelayURLs
+func WithRelays(relays ...RelayConfig) Option {
+	return func(c *routerConfig) {
+		c.relayURLs = make([]string, len(relays))
+		for i, relay := range relays {
+			c.relayURLs[i] = relay.URL
+		}
+		c.relays = relays
+	}
+}
+
+// WithRelayReloader lets reloader replace the relays while the router runs
+func WithRelayReloader(reloader *RelayReloader) Option {
+	return func(c *routerConfig) { c.relayReloader = reloader }
+}
+
+// WithStore sets the store of payloads and bids, defaults to NewStoreWithCleanup with the context of NewRouter
+func WithStore(store Store) Option {
+	return func(c *routerConfig) { c.store = store }
+}
+
+// WithLogger sets the logger, defaults to NewStdLogger writing to the standard library logger
+func WithLogger(log Logger) Option {
+	return func(c *routerConfig) { c.log = log }
+}
+
+// WithHTTPClient sets the client of relay requests, defaults to a client with a 5 second timeout and the transport of
+// DefaultRelayTransportConfig
+func WithHTTPClient(client *http.Client) Option {
+	return func(c *routerConfig) { c.httpClient = client }
+}
+
+// WithRelayTransport tunes the connection pool of the relay client by cfg. The transport of a WithHTTPClient client is
+// cloned with its other settings kept, the client itself isn't changed. With cfg.Prewarm, NewRouter connects to every
+// relay endpoint in the background.
+func WithRelayTransport(cfg RelayTransportConfig) Option {
+	return func(c *routerConfig) { c.relayTransport = &cfg }
+}
+
+// WithRelayRetries retries getPayloadHeader and registerValidator calls to relays that fail with a connection error or
+// a 5xx status, as set by policy. Retries are counted in the mevboost_relay_retries_total metric.
+func WithRelayRetries(policy RetryPolicy) Option {
+	return func(c *routerConfig) { c.relayRetries = &policy }
+}
+
+// WithChainConfig sets the network mev-boost runs on, defaults to mainnet
+func WithChainConfig(chain *ChainConfig) Option {
+	return func(c *routerConfig) { c.chain = chain }
+}
+
+// WithUnderpaymentSuspension suspends relays that fail to pay more than tolerance, a fraction of promised bid value,
+// over their last window verified payloads. A window of 0 disables suspension.
+func WithUnderpaymentSuspension(tolerance float64, window int) Option {
+	return func(c *routerConfig) {
+		c.underpaymentTolerance = tolerance
+		c.underpaymentWindow = window
+	}
+}
+
+// WithSuspensionExpiry lets relays suspended for underpayment back in once they were suspended for expiry, with their
+// payments forgotten. An expiry of 0 keeps relays suspended until their reputation is reset.
+func WithSuspensionExpiry(expiry time.Duration) Option {
+	return func(c *routerConfig) { c.suspensionExpiry = expiry }
+}
+
+// WithNotifyWebhook sets the url receiving a JSON POST for events that need operator attention
+func WithNotifyWebhook(url string) Option {
+	return func(c *routerConfig) { c.notifyWebhookURL = url }
+}
+
+// WithRelayJitter sets the upper bound of a random delay before each relay request. Signed blocks are sent without delay.
+func WithRelayJitter(maxJitter time.Duration) Option {
+	return func(c *routerConfig) { c.relayJitter = maxJitter }
+}
+
+// WithDeterministicRelayOrder queries relays in configured order without jitter, for debugging
+func WithDeterministicRelayOrder() Option {
+	return func(c *routerConfig) { c.deterministicRelayOrder = true }
+}
+
+// WithCapabilityCheckInterval sets how often relays are asked which methods they support, 0 disables the checks
+func WithCapabilityCheckInterval(interval time.Duration) Option {
+	return func(c *routerConfig) { c.capabilityCheckInterval = interval }
+}
+
+// WithRelayProbes sends a relay_getCapabilitiesV1 probe to each relay every interval and keeps a moving average of their
+// round trip latency. Until a relay revealed a payload or enough of its calls were seen, the reveal weighting and the
+// adaptive relay timeouts use its probe latency.
+func WithRelayProbes(interval time.Duration) Option {
+	return func(c *routerConfig) { c.relayProbeInterval = interval }
+}
+
+// WithReadinessChecks probes each relay with relay_getCapabilitiesV1 and asks each of engines for its chain id every
+// interval, and serves the result on /mev-boost/v1/readyz and the /eth/v1/builder/status endpoint of the builder spec.
+// mev-boost is ready if a relay answered within the last 3 intervals, and one of engines too if any are given.
+func WithReadinessChecks(interval time.Duration, engines ...*ExecutionClient) Option {
+	return func(c *routerConfig) {
+		c.readinessInterval = interval
+		c.readinessEngines = engines
+	}
+}
+
+// WithReconcileInterval sets how often delivered payloads are checked against relay data APIs, 0 disables the checks
+func WithReconcileInterval(interval time.Duration) Option {
+	return func(c *routerConfig) { c.reconcileInterval = interval }
+}
+
+// WithReconcileGrace sets how long after a delivery a relay's data API may still not report it before it's flagged
+// as missing at the relay, defaults to 2 minutes
+func WithReconcileGrace(grace time.Duration) Option {
+	return func(c *routerConfig) { c.reconcileGrace = grace }
+}
+
+// WithFinalizedEviction evicts store entries of finalized slots once per epoch, using the finalized checkpoint of the beacon node
+func WithFinalizedEviction(beacon *BeaconClient) Option {
+	return func(c *routerConfig) { c.finalityBeacon = beacon }
+}
+
+// WithDeliveryVerification checks delivered payloads once their slot is finalized: whether the beacon node has their
+// block in the slot, and by how much it increased the balance of the fee recipient on the execution client. The outcome
+// annotates the deliveries, and the deliveries recorded before are backfilled at the start.
+func WithDeliveryVerification(beacon *BeaconClient, el *ExecutionClient) Option {
+	return func(c *routerConfig) { c.verifyBeacon, c.verifyExecution = beacon, el }
+}
+
+// WithHeaderPrefetch requests headers from the relays at the start of slots a validator of WithValidatorPubkeys
+// proposes in, according to the proposer duties of the beacon node, so getPayloadHeader is answered without waiting for
+// the relays
+func WithHeaderPrefetch(beacon *BeaconClient) Option {
+	return func(c *routerConfig) { c.prefetchBeacon = beacon }
+}
+
+// WithChainStateChecks confirms on the beacon node that the slot of a header request has no block yet and, if
+// WithValidatorPubkeys is set, that a local validator proposes in it, before headers are served. Headers not building on
+// the head of the beacon node are rejected. The checks are skipped if the beacon node can't be reached.
+func WithChainStateChecks(beacon *BeaconClient) Option {
+	return func(c *routerConfig) { c.chainCheckBeacon = beacon }
+}
+
+// WithHeaderChecks discards relay headers that don't build on the head of the forkchoiceUpdated call of their payload
+// id, have another timestamp than its payload attributes, move the gas limit away from the registered gas limit of the
+// proposer, or came with transactions that don't pay its registered fee recipient. The gas limit of the parent block is
+// taken from the stored payloads, or looked up on el if it isn't nil, otherwise the gas limit isn't checked.
+func WithHeaderChecks(el *ExecutionClient) Option {
+	return func(c *routerConfig) { c.headerChecks = &headerChecks{el: el} }
+}
+
+// WithGasLimitChecks discards relay headers that move the gas limit away from the registered gas limit of the proposer,
+// or change the gas limit of the parent block by more than a block may, like WithHeaderChecks but without its other
+// checks. It has no effect together with WithHeaderChecks, which checks gas limits too.
+func WithGasLimitChecks(el *ExecutionClient) Option {
+	return func(c *routerConfig) {
+		if c.headerChecks == nil {
+			c.headerChecks = &headerChecks{el: el, gasLimitOnly: true}
+		}
+	}
+}
+
+// WithProposerSignatureVerification rejects blinded blocks whose signature doesn't match their proposer, whose pubkey is
+// looked up on the beacon node. Blocks are rejected if the lookup fails, see WithProposerSignatureFailOpen.
+func WithProposerSignatureVerification(beacon *BeaconClient) Option {
+	return func(c *routerConfig) { c.signatureBeacon = beacon }
+}
+
+// WithProposerSignatureFailOpen lets blinded blocks through to the relays if their proposer pubkey can't be looked up
+// on the beacon node, instead of rejecting them.
+func WithProposerSignatureFailOpen() Option {
+	return func(c *routerConfig) { c.signatureFailOpen = true }
+}
+
+// WithBidSignatureVerification requires relays to sign their headers with the pubkey in the user part of their url, as
+// a signature field of the header over the BuilderBid of the header and its FeeRecipientDiff. Headers with a missing or
+// invalid signature are dropped, so the next best bid is used. NewRouter fails if a relay url has no pubkey.
+func WithBidSignatureVerification() Option {
+	return func(c *routerConfig) { c.bidSignatures = true }
+}
+
+// WithStateDiffPaymentVerification checks proposer payments by the balance increase of the fee recipient in revealed
+// blocks, looked up on the execution client once it imported the block. Payments are checked from the payload alone if
+// the block isn't imported within a few slots.
+func WithStateDiffPaymentVerification(el *ExecutionClient) Option {
+	return func(c *routerConfig) { c.paymentExecutionClient = el }
+}
+
+// WithPayloadIDExpiry rejects getPayloadHeader calls with ErrStalePayloadID if their payload id was issued more than
+// slots slots ago, so ids of an old head left over by a reorg aren't proposed on. 0 disables the expiry.
+func WithPayloadIDExpiry(slots int) Option {
+	return func(c *routerConfig) { c.payloadIDExpirySlots = slots }
+}
+
+// WithResponseSizeLimits aborts relay responses larger than the given sizes in bytes, payload for
+// relay_proposeBlindedBlockV1 and header for all other methods, so a compromised relay can't exhaust memory
+func WithResponseSizeLimits(header, payload int64) Option {
+	return func(c *routerConfig) {
+		c.maxHeaderResponseSize = header
+		c.maxPayloadResponseSize = payload
+	}
+}
+
+// WithMaxRequestBodySize rejects JSON-RPC requests, including batches, with bodies over size bytes with status 413.
+// The default is 5 MiB.
+func WithMaxRequestBodySize(size int64) Option {
+	return func(c *routerConfig) { c.maxRequestBodySize = size }
+}
+
+// WithJSONLimits rejects JSON requests and relay responses nested deeper than maxDepth or with more than maxTokens
+// strings, numbers, literals, objects and arrays, before they're decoded. 0 disables a limit.
+func WithJSONLimits(maxDepth, maxTokens int) Option {
+	return func(c *routerConfig) { c.jsonLimits = jsonLimits{maxDepth: maxDepth, maxTokens: maxTokens} }
+}
+
+// WithStrictContentTypes rejects requests and relay responses that aren't application/json, so content confusion is
+// caught at the boundary. Without it, they're served and accepted with a warning.
+func WithStrictContentTypes() Option {
+	return func(c *routerConfig) { c.strictContentTypes = true }
+}
+
+// WithTraceContext passes the W3C traceparent and tracestate headers of incoming requests on to the relays, with a new
+// span for each relay call, and logs the trace id, for end-to-end traces across consensus client, mev-boost and relays
+func WithTraceContext() Option {
+	return func(c *routerConfig) { c.traceContext = true }
+}
+
+// WithSpanExport exports a span for each JSON-RPC call and each relay call it makes to the OpenTelemetry collector at
+// endpoint, with OTLP over HTTP, as service. Relays are sent the traceparent of their calls, so their spans become
+// children of mev-boost's. With WithTraceContext, the spans continue the traces of the consensus client.
+func WithSpanExport(endpoint, service string) Option {
+	return func(c *routerConfig) { c.spanEndpoint, c.spanService = endpoint, service }
+}
+
+// WithCORS lets browsers on the given origins read the mev-boost APIs, the validator preferences API and /metrics,
+// e.g. for a monitoring dashboard hosted elsewhere. "*" allows any origin. Methods default to GET.
+func WithCORS(origins []string, methods []string) Option {
+	return func(c *routerConfig) { c.cors = newCORSPolicy(origins, methods) }
+}
+
+// WithAdminToken requires token as bearer token for /metrics and /debug/pprof, which leak operational details like
+// relay latencies and memory contents to anyone who can reach the port
+func WithAdminToken(token string) Option {
+	return func(c *routerConfig) { c.adminToken = token }
+}
+
+// WithAdminAPI serves the admin API under /mev-boost/v1/admin behind token as bearer token: it lists the relays and turns
+// them on or off, dumps a summary of the store, and reads and changes the log level if WithLogLevelControl is given.
+// Proposal interchange imports and relay reputation resets are served behind the same token. The API isn't served with an empty token.
+func WithAdminAPI(token string) Option {
+	return func(c *routerConfig) { c.adminAPIToken = token }
+}
+
+// WithLogLevelControl lets the admin API read and change the log level
+func WithLogLevelControl(control LogLevelControl) Option {
+	return func(c *routerConfig) { c.logLevel = &control }
+}
+
+// WithPprof serves the runtime profiles of net/http/pprof under /debug/pprof. Not available in minimal builds.
+func WithPprof() Option {
+	return func(c *routerConfig) { c.pprof = true }
+}
+
+// WithoutAdminEndpoints leaves /metrics and /debug/pprof out of the router, for a separate listener serving NewAdminRouter
+func WithoutAdminEndpoints() Option {
+	return func(c *routerConfig) { c.separateAdmin = true }
+}
+
+// WithRequestBudget bounds each call of the consensus client to budget, shared by all relay requests and fallbacks of
+// the call. Relays that haven't answered when it runs out count as timed out.
+func WithRequestBudget(budget time.Duration) Option {
+	return func(c *routerConfig) { c.requestBudget = budget }
+}
+
+// WithHeaderRace stops waiting for the headers of slow relays once cutoff passed since the relays were asked, and picks
+// the best of the headers received so far, so a single laggard doesn't delay the header. The cutoff is soft: without a
+// valid header by then, the first one that arrives is taken. Headers arriving later are still recorded, in the bid
+// archive as late and in the mevboost_relay_late_headers_total metric, but not offered.
+func WithHeaderRace(cutoff time.Duration) Option {
+	return func(c *routerConfig) { c.headerRaceCutoff = cutoff }
+}
+
+// WithMaxConcurrentRequests serves at most n requests at once. Further requests wait, with getPayloadHeader and
+// proposeBlindedBlock calls of the current slot admitted before registrations, status calls and the other endpoints.
+func WithMaxConcurrentRequests(n int) Option {
+	return func(c *routerConfig) { c.maxConcurrentRequests = n }
+}
+
+// WithLoadShedding rejects registrations, status calls, metrics scrapes and the other background requests with 503
+// while the limit of WithMaxConcurrentRequests is reached and maxQueued background requests wait already, or once one
+// waited for maxWait. 0 disables either threshold. getPayloadHeader and proposeBlindedBlock calls of the current slot
+// are never shed.
+func WithLoadShedding(maxQueued int, maxWait time.Duration) Option {
+	return func(c *routerConfig) { c.shedQueued, c.shedWait = maxQueued, maxWait }
+}
+
+// WithRelayTimings records when each relay call was sent, got its first response byte, and was decoded and validated,
+// for latency studies. The timings of recent calls are served by /mev-boost/v1/relays/timings and written as JSON lines
+// to out, if not nil.
+func WithRelayTimings(out io.Writer) Option {
+	return func(c *routerConfig) {
+		c.relayTimings = true
+		c.relayTimingsOut = out
+	}
+}
+
+// WithRelayCallLog logs each relay call once its response was checked, with the fields slot, relay, method,
+// duration_ms and value (of the bid or payload, null if the call returned none), for log pipelines following the
+// auction.
+func WithRelayCallLog() Option {
+	return func(c *routerConfig) { c.relayCallLog = true }
+}
+
+// WithAdaptiveRelayTimeouts times out each relay call after twice the 95th percentile latency of the recent calls of the
+// relay and method, bounded by min and max. Relays get max until enough of their calls were observed.
+func WithAdaptiveRelayTimeouts(min, max time.Duration) Option {
+	return func(c *routerConfig) {
+		c.minRelayTimeout = min
+		c.maxRelayTimeout = max
+	}
+}
+
+// WithRelayCircuitBreaker takes relays out of the rotation of forkchoiceUpdated and getPayloadHeader calls after
+// threshold consecutive failed calls, timeouts or malformed responses. They are probed once cooldown is over, and put back
+// when they answer.
+func WithRelayCircuitBreaker(threshold int, cooldown time.Duration) Option {
+	return func(c *routerConfig) {
+		c.relayFailureThreshold = threshold
+		c.relayCooldown = cooldown
+	}
+}
+
+// WithRelayMethodTimeouts times out relay calls of the methods in timeouts, as parsed by ParseRelayMethodTimeouts,
+// after a fixed time. Relays that haven't answered by then are skipped like failed ones, on top of WithRequestBudget and
+// WithAdaptiveRelayTimeouts.
+func WithRelayMethodTimeouts(timeouts map[string]time.Duration) Option {
+	return func(c *routerConfig) { c.relayMethodTimeouts = timeouts }
+}
+
+// WithGraphQL serves a read-only GraphQL API over the delivered payloads and the archived bids under
+// /mev-boost/v1/graphql, for dashboards that filter by slot range, relay, validator or value. Not available in minimal
+// builds.
+func WithGraphQL() Option {
+	return func(c *routerConfig) { c.graphql = true }
+}
+
+// WithGRPCServer registers the builder API on server, with the same relays, store and policies as the JSON-RPC
+// endpoint. The caller serves it, after NewRouter returns. Tenants and the request queue only apply to JSON-RPC.
+func WithGRPCServer(server *grpc.Server) Option {
+	return func(c *routerConfig) { c.grpcServer = server }
+}
+
+// WithBidSubscriptions lets consensus clients open a WebSocket connection to / and subscribe to the best bid of their
+// next proposal with builder_subscribe("bestBid", payloadId). The relays are asked for headers every interval, and the
+// best header is pushed whenever it improves, so the consensus client can sign as late as it dares.
+func WithBidSubscriptions(interval time.Duration) Option {
+	return func(c *routerConfig) { c.bidSubscriptionInterval = interval }
+}
+
+// WithJWTAuthentication requires the JSON-RPC calls over HTTP and WebSocket to carry a JWT signed with secret, the way
+// the engine API authenticates consensus clients, see LoadJWTSecret
+func WithJWTAuthentication(secret []byte) Option {
+	return func(c *routerConfig) { c.jwtSecret = secret }
+}
+
+// WithProposerAuth only serves the JSON-RPC calls over HTTP and WebSocket and the builder REST routes to clients that
+// pass auth. Its bearer tokens conflict with JWT authentication, whitelabel users and tenants.
+func WithProposerAuth(auth ProposerAuth) Option {
+	return func(c *routerConfig) { c.proposerAuth = &auth }
+}
+
+// WithMethodRateLimits limits the JSON-RPC calls of each method of limits, see ParseMethodRateLimits. Calls over the
+// limit are answered with a limit exceeded error, so a client that can reach mev-boost can't flood the relays.
+func WithMethodRateLimits(limits map[string]MethodRateLimit) Option {
+	return func(c *routerConfig) { c.methodRateLimits = limits }
+}
+
+// WithWebSocketRPC serves the JSON-RPC methods over WebSocket connections to / too, for consensus clients that keep a
+// connection open. Each message is a call or a batch, served by the router like a request to / with the headers of the
+// upgrade request. Connections are pinged and closed if they send nothing, not even a pong, for readTimeout (0 disables).
+func WithWebSocketRPC(readTimeout time.Duration) Option {
+	return func(c *routerConfig) {
+		c.webSocketRPC = true
+		c.webSocketReadTimeout = readTimeout
+	}
+}
+
+// WithRelayGroups only uses the relays of the active groups for a proposal, the groups chosen for its fee recipient or
+// globally. Bids are offered by group priority, and within a group by its selection policy.
+func WithRelayGroups(groups *RelayGroups) Option {
+	return func(c *routerConfig) { c.relayGroups = groups }
+}
+
+// WithValidatorRelays only uses the relays of its proposer for a proposal. The proposer is the one of the proposer
+// duties with WithChainStateChecks, and otherwise the validator that registered the fee recipient of the proposal.
+// Validator registrations are only sent to the relays of their validator.
+func WithValidatorRelays(relays *ValidatorRelays) Option {
+	return func(c *routerConfig) { c.validatorRelays = relays }
+}
+
+// WithRevealLatencyWeighting favors relays that historically revealed payloads fast as the attestation deadline
+// approaches. Within window of the deadline, bids are discounted by tradeoff, a fraction of their value, per second
+// their relay reveals slower on average than the fastest candidate. The discount per second grows from 0 to tradeoff
+// at the deadline along curve, an exponent: 1 grows linearly, higher values only discount close to the deadline.
+func WithRevealLatencyWeighting(window time.Duration, tradeoff, curve float64) Option {
+	return func(c *routerConfig) { c.revealWeighting = newRevealWeighting(window, tradeoff, curve) }
+}
+
+// WithDefaultFeeRecipient attributes bids to feeRecipient if no fee recipient was registered for their payload id, so
+// their payment is still verified, instead of skipping the verification
+func WithDefaultFeeRecipient(feeRecipient common.Address) Option {
+	return func(c *routerConfig) { c.defaultFeeRecipient = feeRecipient }
+}
+
+// WithNoBidsBehavior sets how header requests without a valid bid are answered: with the error of the failure, the
+// default, with ErrBuildLocally, or with an empty header
+func WithNoBidsBehavior(behavior NoBidsBehavior) Option {
+	return func(c *routerConfig) { c.noBids = behavior }
+}
+
+// WithRegistrationInterval answers forkchoiceUpdated calls that repeat the params of the last call for the same fee
+// recipient within interval with the payload id of that call, instead of forwarding them to relays. This keeps a
+// consensus client stuck in a loop from getting the operator banned by relays.
+func WithRegistrationInterval(interval time.Duration) Option {
+	return func(c *routerConfig) { c.registrationInterval = interval }
+}
+
+// WithForkchoiceDeduplication answers a forkchoiceUpdated call that repeats the previous call with the response of
+// that call if it's still being forwarded to relays or succeeded less than window ago, so retries of consensus clients
+// don't fan out to the relays again
+func WithForkchoiceDeduplication(window time.Duration) Option {
+	return func(c *routerConfig) { c.forkchoiceDedupWindow = window }
+}
+
+// WithPayloadCompression negotiates the transfer of payloads from relays with snappy, deflate or gzip compression.
+// Relays that don't support it answer uncompressed as before.
+func WithPayloadCompression() Option {
+	return func(c *routerConfig) { c.payloadCompression = true }
+}
+
+// WithRelayFieldPolicy sets what happens to relay responses with fields the decoded type doesn't declare, and with
+// required fields missing. The default FieldsIgnore for both decodes responses as they are. Headers and payloads are
+// decoded by generated code that rejects missing required fields under any policy.
+func WithRelayFieldPolicy(unknown, missing FieldPolicy) Option {
+	return func(c *routerConfig) { c.unknownFields, c.missingFields = unknown, missing }
+}
+
+// WithDeprecatedMethodPolicy sets how calls of deprecated method versions are answered. The default
+// DeprecatedTranslate serves them with the current version and warns about them.
+func WithDeprecatedMethodPolicy(policy DeprecatedMethodPolicy) Option {
+	return func(c *routerConfig) { c.deprecatedMethods = policy }
+}
+
+// WithClockSkewCheck compares the local clock with ntpServer periodically and logs an error when they are more than
+// threshold apart, since slot deadlines are judged on the local clock. With adjust, the deadlines are also moved by the
+// offset, so bids are cut off on time by the clock of the network.
+func WithClockSkewCheck(ntpServer string, threshold time.Duration, adjust bool) Option {
+	return func(c *routerConfig) {
+		c.ntpServer, c.clockSkewThreshold, c.adjustForClockSkew = ntpServer, threshold, adjust
+	}
+}
+
+// WithLocalExecutionClients forwards forkchoiceUpdated calls with payload attributes to execution clients of the
+// operator, e.g. clients created with NewEngineClient. When no relay returns a valid bid, their payloads are requested
+// and the one with the highest estimated priority fees is returned instead.
+func WithLocalExecutionClients(clients ...*ExecutionClient) Option {
+	return func(c *routerConfig) { c.localExecutionClients = clients }
+}
+
+// WithPayloadSimulation submits each payload revealed by proposeBlindedBlock to el, an execution client of the operator
+// created with NewEngineClient, with engine_newPayloadV1, and only returns it to the consensus client if el reports it
+// as VALID within timeout. Relays revealing payloads el rejects, or can't execute in time, are treated like relays
+// revealing invalid payloads.
+func WithPayloadSimulation(el *ExecutionClient, timeout time.Duration) Option {
+	return func(c *routerConfig) { c.simulationClient, c.simulationTimeout = el, timeout }
+}
+
+// WithPayloadEscrow only forwards signed blocks to relays that proved the availability of their payload before the
+// proposer signed: only bids that came with their transactions are selected, their payload is revealed by mev-boost,
+// and the signed block is sent to the relay afterwards. Relays that serve headers without transactions get no
+// signatures, so they can't take one and never reveal the payload.
+func WithPayloadEscrow() Option {
+	return func(c *routerConfig) { c.payloadEscrow = true }
+}
+
+// WithProposalFanOut sends signed blocks to every relay that bid for the slot, not only to the relay of the selected bid,
+// and reveals the payload of the first one to answer with it. Builders submit their blocks to several relays, so the
+// payload can still be revealed if the relay of the bid goes down after serving the header.
+func WithProposalFanOut() Option {
+	return func(c *routerConfig) { c.proposalFanOut = true }
+}
+
+// WithBuilderAPI serves the getHeader, blinded block and validator registration routes of the builder REST API next to
+// JSON-RPC. The headers of the REST API carry the signature of the relay, so it requires WithBidSignatures, and it
+// isn't available to whitelabel users or tenants.
+func WithBuilderAPI() Option {
+	return func(c *routerConfig) { c.builderAPI = true }
+}
+
+// WithRelaySSZ asks relays for SSZ encoded headers and payloads, which are smaller and faster to decode than JSON.
+// Relays that don't speak SSZ keep answering with JSON, the formats of their responses are counted in the
+// mevboost_relay_response_formats_total metric.
+func WithRelaySSZ() Option {
+	return func(c *routerConfig) { c.relaySSZ = true }
+}
+
+// WithMinBid ignores bids worth less than minBid wei to the proposer. Without a higher bid, header requests are
+// answered like without bids, by the local builders or the behavior of WithNoBidsBehavior. Tenants with a higher min
+// bid of their own keep it.
+func WithMinBid(minBid *big.Int) Option {
+	return func(c *routerConfig) { c.minBid = minBid }
+}
+
+// WithAuditSink writes deliveries, underpayments, relay suspensions and delivery verifications to sink, in batches
+// every 10 seconds. Several sinks can be added, records are buffered for each while it fails.
+func WithAuditSink(sink AuditSink) Option {
+	return func(c *routerConfig) { c.auditSinks = append(c.auditSinks, sink) }
+}
+
+// WithMethodPassthrough forwards the JSON-RPC calls of methods mev-boost doesn't serve, like
+// engine_exchangeTransitionConfigurationV1 or eth_ queries, to the execution client el, instead of answering them with
+// method not found. Only methods of allow, DefaultPassthroughMethods if it's empty, that aren't in deny are forwarded.
+// Entries are method names, or prefixes ending in *, like eth_*.
+func WithMethodPassthrough(el *ExecutionClient, allow, deny []string) Option {
+	if len(allow) == 0 {
+		allow = DefaultPassthroughMethods
+	}
+	return func(c *routerConfig) { c.passthrough = &methodPassthrough{el: el, allow: allow, deny: deny} }
+}
+
+// WithPayoutCSVFile appends a row to file for each block proposed through mev-boost, with its proposer, slot, winning
+// relay and selected bid value, for reconciliation against the payments on chain
+func WithPayoutCSVFile(file *PayoutCSVFile) Option {
+	return func(c *routerConfig) { c.payoutCSV = file }
+}
+
+// WithMissedValueTracking compares each delivered payload to the bids other relays made for its slot. If one of them
+// was more valuable, the value missed is logged, counted per relay, and listed at /mev-boost/v1/bids/missed.
+func WithMissedValueTracking() Option {
+	return func(c *routerConfig) { c.missedValue = true }
+}
+
+// WithClientCompat works around the quirks of a consensus client, e.g. method name variants or field encodings.
+// The default CompatAuto detects the client from the User-Agent of each request.
+func WithClientCompat(mode ClientCompat) Option {
+	return func(c *routerConfig) { c.clientCompat = mode }
+}
+
+// WithAggregatorMode lets other mev-boost instances use this one as a relay: it reports the capabilities of its relays
+// and serves its deliveries on the relay data API, so one instance can hold the relay connections and policy of many
+func WithAggregatorMode() Option {
+	return func(c *routerConfig) { c.aggregator = true }
+}
+
+// WithWhitelabel serves only the relay API, to users authenticated by one of tokens as bearer token, so mev-boost can be
+// offered as a curated relay endpoint. Each user may make perSecond requests on average with bursts of up to burst,
+// perSecond 0 disables rate limiting. It implies WithAggregatorMode, the consensus client methods and the mev-boost
+// APIs, including /metrics, aren't served.
+func WithWhitelabel(tokens []string, perSecond float64, burst int) Option {
+	return func(c *routerConfig) {
+		c.aggregator = true
+		c.whitelabel = newWhitelabelUsers(tokens, perSecond, burst)
+	}
+}
+
+// WithValidatorPubkeys sets the validators of the operator, relays are asked for deliveries to them that mev-boost didn't record
+func WithValidatorPubkeys(pubkeys ...string) Option {
+	return func(c *routerConfig) { c.validatorPubkeys = pubkeys }
+}
+
+// WithSigner sets the signer of messages mev-boost creates itself, like validator registrations
+func WithSigner(signer Signer) Option {
+	return func(c *routerConfig) { c.signer = signer }
+}
+
+// WithPreferencesAPI enables the keymanager-style validator preferences API, requests need token as bearer token
+func WithPreferencesAPI(token string) Option {
+	return func(c *routerConfig) { c.preferencesAPIToken = token }
+}
+
+// WithTenants serves each tenant with its own relays, min bid and validation policy. JSON-RPC requests must carry the
+// token of a tenant, other requests are rejected. Tenants can't be combined with WithStableHeaders, which would share
+// headers across tenants.
+func WithTenants(tenants ...Tenant) Option {
+	return func(c *routerConfig) { c.tenants = append(c.tenants, tenants...) }
+}
+
+// WithStableHeaders returns the same header to all getPayloadHeader calls of a proposal, by slot, parent and proposer,
+// for redundant consensus clients and distributed validators whose nodes co-sign the header. Concurrent calls ask the
+// relays once, and the headers are kept in the store, so routers sharing it return the same header too.
+func WithStableHeaders() Option {
+	return func(c *routerConfig) { c.stableHeaders = true }
+}
+
+// WithEquivocationGuard checks header requests and signed blocks against the earlier ones of their slot with guard, see
+// EquivocationGuard
+func WithEquivocationGuard(guard *EquivocationGuard) Option {
+	return func(c *routerConfig) { c.equivocation = guard }
+}
+
+// WithValidationPolicy adds checks of relay responses to the built-in ones
+func WithValidationPolicy(policy ValidationPolicy) Option {
+	return func(c *routerConfig) { c.validation = policy }
+}
+
+// BidCandidate is a valid payload header offered by a relay in getPayloadHeader
+type BidCandidate struct {
+	RelayURL string
+	Header   *ExecutionPayloadWithTxRootV1
+}
+
+// BidDecision is called with all candidates of a getPayloadHeader call, most valuable first unless a bid selector, reveal
+// latency weighting, relay groups or diverging parents order them otherwise, and the winner about to be returned to the proposer.
+// A non-nil error vetoes the winner and the next-best candidate is offered. Candidates must not be modified.
+type BidDecision func(ctx context.Context, candidates []BidCandidate, winner BidCandidate) error
+
+func (d BidDecision) decide(ctx context.Context, candidates []BidCandidate, winner BidCandidate) error {
+	if d == nil {
+		return nil
+	}
+	return d(ctx, candidates, winner)
+}
+
+// WithBidSelector orders the bids of each getPayloadHeader call by selector, e.g. to prefer some relays among bids of
+// near-equal value. By default bids are offered by value weighted with the weight of their relay.
+func WithBidSelector(selector BidSelector) Option {
+	return func(c *routerConfig) { c.bidSelector = selector }
+}
+
+// WithBidDecision sets a callback that can log or veto the bid returned to the proposer
+func WithBidDecision(decision BidDecision) Option {
+	return func(c *routerConfig) { c.bidDecision = decision }
+}
+
+// WithMiddleware wraps the handlers of the router in middleware, the first one is the outermost. Requests that match no route bypass it.
+func WithMiddleware(middleware ...Middleware) Option {
+	return func(c *routerConfig) { c.middleware = append(c.middleware, middleware...) }
+}
+
+// WithHooks sets callbacks for accepted relay responses
+func WithHooks(hooks Hooks) Option {
+	return func(c *routerConfig) { c.hooks = hooks }
+}
+
+// WithEventSubscriber calls fn with the events of the given kinds, or all events if no kind is given. Events are
+// published while the request that caused them is served, fn must not block.
+func WithEventSubscriber(fn EventSubscriber, kinds ...EventKind) Option {
+	return func(c *routerConfig) { c.eventSubscribers = append(c.eventSubscribers, eventSubscription{fn, kinds}) }
+}
@@ -0,0 +1,129 @@
+package lib
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRelayService_ValidationPolicyAndHooks(t *testing.T) {
+	relay := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		resp, err := formatResponse(ExecutionPayloadWithTxRootV1{
+			BlockHash:        common.HexToHash("0x01"),
+			BaseFeePerGas:    big.NewInt(1),
+			FeeRecipientDiff: big.NewInt(10),
+		})
+		require.Nil(t, err)
+		w.Write(resp)
+	}))
+	defer relay.Close()
+
+	tests := []struct {
+		name       string
+		policy     ValidationPolicy
+		wantErr    error
+		wantHeader bool
+	}{
+		{"default policy", ValidationPolicy{}, nil, true},
+		{"header rejected", ValidationPolicy{
+			Header: func(_ context.Context, relayURL string, header *ExecutionPayloadWithTxRootV1) error {
+				return errors.New("bid too low")
+			},
+		}, ErrValidationFailed, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var hookedHeaders []common.Hash
+			store := NewStore()
+			store.SetForkchoiceResponse(context.Background(), "0x01", relay.URL, "0x01")
+			service, err := newRelayService(
+				WithRelayURLs(relay.URL),
+				WithStore(store),
+				WithLogger(testLog),
+				WithValidationPolicy(tt.policy),
+				WithHooks(Hooks{OnHeader: func(_ context.Context, relayURL string, header *ExecutionPayloadWithTxRootV1) {
+					require.Equal(t, relay.URL, relayURL)
+					hookedHeaders = append(hookedHeaders, header.BlockHash)
+				}}),
+			)
+			require.Nil(t, err)
+
+			payloadID := "0x01"
+			err = service.GetPayloadHeaderV1(nil, &payloadID, new(ExecutionPayloadWithTxRootV1))
+			if tt.wantErr != nil {
+				require.ErrorIs(t, err, tt.wantErr)
+			} else {
+				require.Nil(t, err)
+			}
+			require.Equal(t, tt.wantHeader, len(hookedHeaders) == 1)
+		})
+	}
+}
+
+func TestRelayService_BidDecision(t *testing.T) {
+	newRelay := func(blockHash string, value int64) *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			resp, err := formatResponse(ExecutionPayloadWithTxRootV1{
+				BlockHash:        common.HexToHash(blockHash),
+				BaseFeePerGas:    big.NewInt(1),
+				FeeRecipientDiff: big.NewInt(value),
+			})
+			require.Nil(t, err)
+			w.Write(resp)
+		}))
+	}
+	low, high := newRelay("0x01", 1), newRelay("0x02", 2)
+	defer low.Close()
+	defer high.Close()
+
+	tests := []struct {
+		name          string
+		veto          map[common.Hash]bool
+		wantBlockHash common.Hash
+		wantErr       error
+	}{
+		{"best bid wins", nil, common.HexToHash("0x02"), nil},
+		{"veto falls through to next-best", map[common.Hash]bool{common.HexToHash("0x02"): true}, common.HexToHash("0x01"), nil},
+		{"all vetoed", map[common.Hash]bool{common.HexToHash("0x01"): true, common.HexToHash("0x02"): true}, common.Hash{}, ErrNoBids},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var offered []common.Hash
+			store := NewStore()
+			store.SetForkchoiceResponse(context.Background(), "0x01", low.URL, "0x01")
+			store.SetForkchoiceResponse(context.Background(), "0x01", high.URL, "0x01")
+			service, err := newRelayService(
+				WithRelayURLs(low.URL, high.URL),
+				WithStore(store),
+				WithLogger(testLog),
+				WithBidDecision(func(_ context.Context, candidates []BidCandidate, winner BidCandidate) error {
+					require.Len(t, candidates, 2)
+					require.Equal(t, high.URL, candidates[0].RelayURL)
+					offered = append(offered, winner.Header.BlockHash)
+					if tt.veto[winner.Header.BlockHash] {
+						return errors.New("vetoed")
+					}
+					return nil
+				}),
+			)
+			require.Nil(t, err)
+
+			payloadID := "0x01"
+			result := new(ExecutionPayloadWithTxRootV1)
+			err = service.GetPayloadHeaderV1(nil, &payloadID, result)
+			if tt.wantErr != nil {
+				require.ErrorIs(t, err, tt.wantErr)
+			} else {
+				require.Nil(t, err)
+			}
+			require.Equal(t, tt.wantBlockHash, result.BlockHash)
+			require.Equal(t, common.HexToHash("0x02"), offered[0])
+		})
+	}
+}
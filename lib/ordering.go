@@ -0,0 +1,66 @@
+package lib
+
+import (
+	"context"
+	"math/rand"
+	"sort"
+	"time"
+)
+
+// relayOrdering randomizes the order and pacing of relay requests, so that relays can't infer the operator's
+// topology or relay preference from consistent timing patterns
+type relayOrdering struct {
+	deterministic bool          // query relays in configured order without delay, for debugging
+	maxJitter     time.Duration // upper bound of the random delay before each relay request
+}
+
+// order returns a copy of urls in the order they should be queried
+func (o relayOrdering) order(urls []string) []string {
+	ordered := make([]string, len(urls))
+	copy(ordered, urls)
+	if !o.deterministic {
+		rand.Shuffle(len(ordered), func(i, j int) { ordered[i], ordered[j] = ordered[j], ordered[i] })
+	}
+	return ordered
+}
+
+// inConfiguredOrder returns the relay urls of forkchoiceResponses in the order of the configured relays, so order keeps
+// it with deterministic ordering. Relays removed by a reload since the forkchoice update follow in sorted order.
+func (m *RelayService) inConfiguredOrder(forkchoiceResponses map[string]string) []string {
+	urls := make([]string, 0, len(forkchoiceResponses))
+	for _, relayURL := range m.relays.all() {
+		if _, ok := forkchoiceResponses[relayURL]; ok {
+			urls = append(urls, relayURL)
+		}
+	}
+	var removed []string
+	for relayURL := range forkchoiceResponses {
+		if !m.relays.contains(relayURL) {
+			removed = append(removed, relayURL)
+		}
+	}
+	sort.Strings(removed)
+	return append(urls, removed...)
+}
+
+// unjitteredMethods are sent without a random delay, the signed block has to reach the relay before the slot's
+// deadline and the relay learns nothing from its timing that the proposal doesn't tell it anyway
+var unjitteredMethods = map[string]bool{
+	methodRelayProposeBlock: true,
+}
+
+// wait sleeps a random delay before a relay request of method, returning early with an error if ctx is done
+func (o relayOrdering) wait(ctx context.Context, method string) error {
+	if o.deterministic || o.maxJitter <= 0 || unjitteredMethods[method] {
+		return nil
+	}
+
+	timer := time.NewTimer(time.Duration(rand.Int63n(int64(o.maxJitter))))
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
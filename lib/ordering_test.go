@@ -0,0 +1,28 @@
+package lib
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRelayService_inConfiguredOrder(t *testing.T) {
+	service, err := newRelayService(WithRelayURLs("http://c", "http://a", "http://b"), WithStore(NewStore()), WithLogger(testLog), WithDeterministicRelayOrder())
+	require.Nil(t, err)
+
+	forkchoiceResponses := map[string]string{"http://a": "0x01", "http://b": "0x02", "http://c": "0x03", "http://removed": "0x04"}
+	for i := 0; i < 10; i++ {
+		require.Equal(t, []string{"http://c", "http://a", "http://b", "http://removed"}, service.ordering.order(service.inConfiguredOrder(forkchoiceResponses)))
+	}
+}
+
+func Test_relayOrdering_wait(t *testing.T) {
+	ordering := relayOrdering{maxJitter: time.Hour}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	require.Nil(t, ordering.wait(ctx, methodRelayProposeBlock))
+	require.Equal(t, context.DeadlineExceeded, ordering.wait(ctx, methodRelayGetHeader))
+}
@@ -0,0 +1,96 @@
+package lib
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// DefaultPassthroughMethods are the methods forwarded by WithMethodPassthrough without an allowlist
+var DefaultPassthroughMethods = []string{"engine_*", "eth_*"}
+
+var (
+	passthroughCallsTotal = metricsFactory.NewCounterVec(prometheus.CounterOpts{
+		Name: "mevboost_passthrough_calls_total",
+		Help: "Calls of methods mev-boost doesn't serve, by method and result: forwarded to the execution client, error if it couldn't be reached, or denied by the allow and deny lists with method other",
+	}, []string{"method", "result"})
+	passthroughDuration = metricsFactory.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "mevboost_passthrough_duration_seconds",
+		Help:    "Duration of calls forwarded to the execution client, by method",
+		Buckets: []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1},
+	}, []string{"method"})
+)
+
+// methodPassthrough forwards calls of methods mev-boost doesn't serve to an execution client, if the allowlist has
+// them and the denylist doesn't. Entries are method names, or prefixes ending in *.
+type methodPassthrough struct {
+	el    *ExecutionClient
+	allow []string
+	deny  []string
+}
+
+// validate checks that the entries of the lists are method names or prefixes
+func (p *methodPassthrough) validate() error {
+	for _, pattern := range append(append([]string{}, p.allow...), p.deny...) {
+		if pattern == "" || strings.Contains(strings.TrimSuffix(pattern, "*"), "*") {
+			return fmt.Errorf("invalid passthrough method %q, expected a method name or a prefix ending in *", pattern)
+		}
+	}
+	return nil
+}
+
+// forwards reports whether calls of method are forwarded
+func (p *methodPassthrough) forwards(method string) bool {
+	return matchesMethod(p.allow, method) && !matchesMethod(p.deny, method)
+}
+
+// matchesMethod reports whether method is one of the names, or starts with one of the prefixes, of patterns
+func matchesMethod(patterns []string, method string) bool {
+	for _, pattern := range patterns {
+		if pattern == method || strings.HasSuffix(pattern, "*") && strings.HasPrefix(method, strings.TrimSuffix(pattern, "*")) {
+			return true
+		}
+	}
+	return false
+}
+
+// handler forwards calls of methods served doesn't report as served to the execution client. Calls of other methods,
+// denied methods and requests that aren't calls go to next, which answers them or rejects them.
+func (p *methodPassthrough) handler(served func(method string) bool, log Logger, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(io.LimitReader(r.Body, maxRelayResponseSize))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		req := new(compatRequest)
+		if err := json.Unmarshal(body, req); err != nil || req.Method == "" || served(req.Method) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if !p.forwards(req.Method) {
+			passthroughCallsTotal.WithLabelValues("other", "denied").Inc()
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		start := now()
+		resp, err := p.el.Forward(r.Context(), body)
+		if err != nil {
+			passthroughCallsTotal.WithLabelValues(req.Method, "error").Inc()
+			log.WithFields(Fields{"method": req.Method, "url": p.el.URL(), "error": err}).Warn("could not forward call to the execution client")
+			respondJSON(w, http.StatusOK, batchErrorResponse(req.ID, rpcErrInternal, fmt.Sprintf("could not forward %s to the execution client", req.Method)))
+			return
+		}
+		passthroughCallsTotal.WithLabelValues(req.Method, "forwarded").Inc()
+		passthroughDuration.WithLabelValues(req.Method).Observe(now().Sub(start).Seconds())
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(resp)
+	})
+}
@@ -0,0 +1,71 @@
+package lib
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMethodPassthrough(t *testing.T) {
+	var calls int32
+	el := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		require.NotEmpty(t, r.Header.Get("Authorization"), "the engine API is authenticated")
+		body, err := io.ReadAll(r.Body)
+		require.Nil(t, err)
+		req := new(compatRequest)
+		require.Nil(t, json.Unmarshal(body, req))
+		w.Write([]byte(`{"jsonrpc":"2.0","id":` + string(req.ID) + `,"result":"` + req.Method + `"}`))
+	}))
+	defer el.Close()
+
+	router, err := NewRouter(context.Background(), WithRelayURLs("http://localhost:1"), WithStore(NewStore()), WithLogger(testLog), WithCapabilityCheckInterval(0),
+		WithMethodPassthrough(NewEngineClient(el.URL, make([]byte, 32)), nil, []string{"eth_sendRawTransaction"}))
+	require.Nil(t, err)
+
+	call := func(t *testing.T, method string) map[string]interface{} {
+		body, err := formatRequestBody(method, []interface{}{"0x01"})
+		require.Nil(t, err)
+		req := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		var resp map[string]interface{}
+		require.Nil(t, json.Unmarshal(rr.Body.Bytes(), &resp), rr.Body.String())
+		return resp
+	}
+
+	for _, method := range []string{"engine_exchangeTransitionConfigurationV1", "eth_chainId"} {
+		resp := call(t, method)
+		require.Nil(t, resp["error"], method)
+		require.Equal(t, method, resp["result"], method)
+	}
+	require.Equal(t, int32(2), atomic.LoadInt32(&calls))
+
+	for _, method := range []string{"engine_getPayloadHeaderV1", "builder_getHeaderV1", "eth_sendRawTransaction", "debug_traceBlock"} {
+		resp := call(t, method)
+		require.NotNil(t, resp["error"], method)
+	}
+	require.Equal(t, int32(2), atomic.LoadInt32(&calls), "served and denied methods aren't forwarded")
+
+	el.Close()
+	resp := call(t, "eth_chainId")
+	require.Equal(t, float64(rpcErrInternal), resp["error"].(map[string]interface{})["code"])
+}
+
+func TestMethodPassthrough_InvalidMethods(t *testing.T) {
+	for _, methods := range [][]string{{""}, {"eth_*_call"}, {"*eth"}} {
+		_, err := NewRouter(context.Background(), WithRelayURLs("http://localhost:1"), WithStore(NewStore()), WithLogger(testLog), WithCapabilityCheckInterval(0),
+			WithMethodPassthrough(NewExecutionClient("http://localhost:1"), methods, nil))
+		require.Error(t, err, methods)
+	}
+	require.True(t, matchesMethod(DefaultPassthroughMethods, "engine_exchangeCapabilities"))
+	require.False(t, matchesMethod([]string{"eth_call"}, "eth_callMany"))
+}
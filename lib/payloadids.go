@@ -0,0 +1,51 @@
+package lib
+
+import (
+	"sync"
+	"time"
+)
+
+// payloadIDFreshness remembers when payload ids were issued, so ids issued for an older head, e.g. before a reorg,
+// can't be used to get a header
+type payloadIDFreshness struct {
+	maxAge time.Duration
+	expiry time.Duration // ids issued longer ago are forgotten
+
+	mu     sync.Mutex
+	issued map[string]time.Time // by boost payload id
+}
+
+func newPayloadIDFreshness(maxAge, expiry time.Duration) *payloadIDFreshness {
+	return &payloadIDFreshness{maxAge: maxAge, expiry: expiry, issued: make(map[string]time.Time)}
+}
+
+// issue records that id was returned to the consensus client. Ids the store already forgot are dropped.
+func (f *payloadIDFreshness) issue(id string) {
+	if f == nil {
+		return
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.issued[id] = now()
+	for entry, issuedAt := range f.issued {
+		if now().Sub(issuedAt) > f.expiry {
+			delete(f.issued, entry)
+		}
+	}
+}
+
+// check returns ErrStalePayloadID if id was issued more than maxAge ago
+func (f *payloadIDFreshness) check(id string) error {
+	if f == nil {
+		return nil
+	}
+	f.mu.Lock()
+	issuedAt, ok := f.issued[id]
+	f.mu.Unlock()
+
+	if age := now().Sub(issuedAt); ok && age > f.maxAge {
+		return newMethodError(ErrStalePayloadID, "payloadID %s was issued %s ago, more than the allowed %s", id, age.Round(time.Second), f.maxAge)
+	}
+	return nil
+}
@@ -0,0 +1,60 @@
+package lib
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRelayService_PayloadIDExpiry(t *testing.T) {
+	relay := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := ioutil.ReadAll(r.Body)
+		require.Nil(t, err)
+		req := new(rpcRequest)
+		require.Nil(t, json.Unmarshal(body, req))
+
+		var resp []byte
+		switch req.Method {
+		case methodForkchoiceUpdated:
+			resp, err = formatResponse(ForkChoiceResponse{PayloadStatus: PayloadStatus{Status: ForkchoiceStatusValid}, PayloadID: &hexutil.Bytes{1}})
+		case methodRelayGetHeader:
+			resp, err = formatResponse(ExecutionPayloadWithTxRootV1{BlockHash: common.HexToHash("0x01"), BaseFeePerGas: big.NewInt(1), FeeRecipientDiff: big.NewInt(1)})
+		}
+		require.Nil(t, err)
+		w.Write(resp)
+	}))
+	defer relay.Close()
+
+	defer func() { now = time.Now }()
+	start := time.Now()
+	now = func() time.Time { return start }
+
+	service, err := newRelayService(WithRelayURLs(relay.URL), WithStore(NewStore()), WithLogger(testLog), WithPayloadIDExpiry(2))
+	require.Nil(t, err)
+	fcu := new(ForkChoiceResponse)
+	require.Nil(t, service.ForkchoiceUpdatedV1(nil, &[]interface{}{map[string]interface{}{}}, fcu))
+	payloadID := fcu.PayloadID.String()
+
+	now = func() time.Time { return start.Add(24 * time.Second) }
+	require.Nil(t, service.GetPayloadHeaderV1(nil, &payloadID, new(ExecutionPayloadWithTxRootV1)), "ids are fresh for 2 slots")
+
+	now = func() time.Time { return start.Add(25 * time.Second) }
+	err = service.GetPayloadHeaderV1(nil, &payloadID, new(ExecutionPayloadWithTxRootV1))
+	require.ErrorIs(t, err, ErrStalePayloadID)
+	require.Equal(t, ErrorCodeStalePayloadID, err.(*MethodError).ErrorCode())
+
+	service, err = newRelayService(WithRelayURLs(relay.URL), WithStore(NewStore()), WithLogger(testLog))
+	require.Nil(t, err)
+	require.Nil(t, service.ForkchoiceUpdatedV1(nil, &[]interface{}{map[string]interface{}{}}, fcu))
+	payloadID = fcu.PayloadID.String()
+	now = func() time.Time { return start.Add(time.Hour) }
+	require.Nil(t, service.GetPayloadHeaderV1(nil, &payloadID, new(ExecutionPayloadWithTxRootV1)), "ids don't expire by default")
+}
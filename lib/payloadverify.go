@@ -0,0 +1,106 @@
+package lib
+
+import (
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/trie"
+	"github.com/flashbots/mev-boost/lib/txroot"
+)
+
+// decodeTransactions decodes the 0x prefixed transactions of a payload. Unlike execution clients, which drop nothing, it
+// rejects the whole list if any transaction doesn't decode, as skipping it would change the roots.
+func decodeTransactions(encoded []string) ([][]byte, types.Transactions, error) {
+	raw := make([][]byte, 0, len(encoded))
+	txs := make(types.Transactions, 0, len(encoded))
+	for i, otx := range encoded {
+		b, err := hexutil.Decode(otx)
+		if err != nil {
+			return nil, nil, fmt.Errorf("transaction %d: %w", i, err)
+		}
+		tx := new(types.Transaction)
+		if err := tx.UnmarshalBinary(b); err != nil {
+			return nil, nil, fmt.Errorf("transaction %d: %w", i, err)
+		}
+		raw = append(raw, b)
+		txs = append(txs, tx)
+	}
+	return raw, txs, nil
+}
+
+// transactionsRoot returns the hash tree root of a transaction list, the root committed to by an execution payload header
+func transactionsRoot(raw [][]byte) (common.Hash, error) {
+	root, err := txroot.TransactionsRoot(raw)
+	if err != nil {
+		return nilHash, err
+	}
+	return common.BytesToHash(root[:]), nil
+}
+
+// computeBlockHash returns the execution block hash of a payload with the transactions txs: the hash of the RLP encoded
+// block header, with the fields fixed by the merge (no uncles, difficulty or nonce) and the trie root of txs. Headers of
+// payloads with withdrawals also have the trie root of the withdrawals.
+func computeBlockHash(payload *ExecutionPayloadWithTxRootV1, txs types.Transactions) common.Hash {
+	if payload.Withdrawals != nil {
+		return computeCapellaBlockHash(payload, txs)
+	}
+	header := &types.Header{
+		ParentHash:  payload.ParentHash,
+		UncleHash:   types.EmptyUncleHash,
+		Coinbase:    payload.FeeRecipient,
+		Root:        payload.StateRoot,
+		TxHash:      types.DeriveSha(txs, trie.NewStackTrie(nil)),
+		ReceiptHash: payload.ReceiptsRoot,
+		Bloom:       types.BytesToBloom(payload.LogsBloom),
+		Difficulty:  new(big.Int),
+		Number:      new(big.Int).SetUint64(payload.Number),
+		GasLimit:    payload.GasLimit,
+		GasUsed:     payload.GasUsed,
+		Time:        payload.Timestamp,
+		Extra:       payload.ExtraData,
+		MixDigest:   payload.PrevRandao,
+		BaseFee:     payload.BaseFeePerGas,
+	}
+	return header.Hash()
+}
+
+// verifyPayload recomputes the transactions root, withdrawals root and block hash of a payload that came with its
+// transactions, and returns ErrHeaderMismatch if any differs from the payload's own. A payload without transactions isn't checked.
+func verifyPayload(payload *ExecutionPayloadWithTxRootV1) error {
+	if payload.Transactions == nil {
+		return nil
+	}
+	if payload.BaseFeePerGas == nil {
+		return fmt.Errorf("%w: payload %s has no base fee", ErrHeaderMismatch, payload.BlockHash)
+	}
+	if len(payload.LogsBloom) != types.BloomByteLength {
+		return fmt.Errorf("%w: payload %s has a logs bloom of %d bytes", ErrHeaderMismatch, payload.BlockHash, len(payload.LogsBloom))
+	}
+	raw, txs, err := decodeTransactions(*payload.Transactions)
+	if err != nil {
+		return fmt.Errorf("%w: payload %s: %v", ErrHeaderMismatch, payload.BlockHash, err)
+	}
+	root, err := transactionsRoot(raw)
+	if err != nil {
+		return fmt.Errorf("%w: payload %s: %v", ErrHeaderMismatch, payload.BlockHash, err)
+	}
+	if payload.TransactionsRoot != nilHash && root != payload.TransactionsRoot {
+		return fmt.Errorf("%w: transactions of payload %s have root %s, not %s", ErrHeaderMismatch, payload.BlockHash, root, payload.TransactionsRoot)
+	}
+	if payload.Withdrawals != nil {
+		root, err := withdrawalsRoot(*payload.Withdrawals)
+		if err != nil {
+			return fmt.Errorf("%w: payload %s: %v", ErrHeaderMismatch, payload.BlockHash, err)
+		}
+		if payload.WithdrawalsRoot != nil && root != *payload.WithdrawalsRoot {
+			return fmt.Errorf("%w: withdrawals of payload %s have root %s, not %s", ErrHeaderMismatch, payload.BlockHash, root, payload.WithdrawalsRoot)
+		}
+	}
+	if hash := computeBlockHash(payload, txs); hash != payload.BlockHash {
+		return fmt.Errorf("%w: payload %s hashes to %s", ErrHeaderMismatch, payload.BlockHash, hash)
+	}
+	return nil
+}
@@ -0,0 +1,191 @@
+package lib
+
+import (
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/trie"
+	"github.com/stretchr/testify/require"
+)
+
+// withBlockHash returns payload with the block hash of its fields and transactions, and an empty logs bloom if it has none
+func withBlockHash(payload ExecutionPayloadWithTxRootV1) ExecutionPayloadWithTxRootV1 {
+	if len(payload.LogsBloom) == 0 {
+		payload.LogsBloom = make([]byte, types.BloomByteLength)
+	}
+	var txs types.Transactions
+	if payload.Transactions != nil {
+		var err error
+		if _, txs, err = decodeTransactions(*payload.Transactions); err != nil {
+			panic(err)
+		}
+	}
+	payload.BlockHash = computeBlockHash(&payload, txs)
+	return payload
+}
+
+// testBlock returns a block of two transactions and their receipts, built and hashed by geth
+func testBlock(t *testing.T) *types.Block {
+	to := common.HexToAddress("0x0000000000000000000000000000000000000002")
+	var txs types.Transactions
+	var receipts []*types.Receipt
+	for i := int64(1); i <= 2; i++ {
+		tx := types.NewTx(&types.LegacyTx{Nonce: uint64(i), To: &to, Value: big.NewInt(i), Gas: 21000, GasPrice: big.NewInt(7)})
+		txs = append(txs, tx)
+		receipts = append(receipts, &types.Receipt{Status: types.ReceiptStatusSuccessful, CumulativeGasUsed: uint64(i) * 21000, TxHash: tx.Hash()})
+	}
+	return types.NewBlock(&types.Header{
+		ParentHash: common.HexToHash("0x1111111111111111111111111111111111111111111111111111111111111111"),
+		Coinbase:   common.HexToAddress("0x0000000000000000000000000000000000000001"),
+		Root:       common.HexToHash("0x2222222222222222222222222222222222222222222222222222222222222222"),
+		Difficulty: new(big.Int),
+		Number:     big.NewInt(15537394),
+		GasLimit:   30000000,
+		GasUsed:    42000,
+		Time:       1663224179,
+		Extra:      []byte("mev-boost"),
+		MixDigest:  common.HexToHash("0x4444444444444444444444444444444444444444444444444444444444444444"),
+		BaseFee:    big.NewInt(7),
+	}, txs, nil, receipts, trie.NewStackTrie(nil))
+}
+
+// blockPayload returns the execution payload of a block
+func blockPayload(t *testing.T, block *types.Block) ExecutionPayloadWithTxRootV1 {
+	txs := []string{}
+	for _, tx := range block.Transactions() {
+		encoded, err := tx.MarshalBinary()
+		require.Nil(t, err)
+		txs = append(txs, hexutil.Encode(encoded))
+	}
+	bloom := block.Bloom()
+	return ExecutionPayloadWithTxRootV1{
+		ParentHash:       block.ParentHash(),
+		FeeRecipient:     block.Coinbase(),
+		StateRoot:        block.Root(),
+		ReceiptsRoot:     block.ReceiptHash(),
+		LogsBloom:        bloom[:],
+		PrevRandao:       block.MixDigest(),
+		Number:           block.NumberU64(),
+		GasLimit:         block.GasLimit(),
+		GasUsed:          block.GasUsed(),
+		Timestamp:        block.Time(),
+		ExtraData:        block.Extra(),
+		BaseFeePerGas:    block.BaseFee(),
+		BlockHash:        block.Hash(),
+		Transactions:     &txs,
+		FeeRecipientDiff: new(big.Int),
+	}
+}
+
+func Test_transactionsRoot(t *testing.T) {
+	root, err := transactionsRoot(nil)
+	require.Nil(t, err)
+	require.Equal(t, common.HexToHash("0x7ffe241ea60187fdb0187bfa22de35d1f9bed7ab061d9401fd47e34a54fbede1"), root)
+
+	payload := blockPayload(t, testBlock(t))
+	raw, txs, err := decodeTransactions(*payload.Transactions)
+	require.Nil(t, err)
+	require.Len(t, txs, 2)
+	root, err = transactionsRoot(raw)
+	require.Nil(t, err)
+	require.Equal(t, common.HexToHash("0x21d183c2d4c5d66372e16a7369ca212f6e5a1a4430d336516af12f6ff05b93e7"), root)
+
+	_, _, err = decodeTransactions([]string{(*payload.Transactions)[0][2:]})
+	require.NotNil(t, err, "transactions are 0x prefixed")
+	_, _, err = decodeTransactions([]string{(*payload.Transactions)[0], "0x02"})
+	require.NotNil(t, err, "invalid transactions aren't skipped")
+}
+
+func Test_computeBlockHash(t *testing.T) {
+	block := testBlock(t)
+	payload := blockPayload(t, block)
+	require.Equal(t, common.HexToHash("0x9960cb62b3aa277c72eba7ee3c9d5a3e62cc9c3a5b6d73b985514ca935868be5"), block.Hash())
+	require.Equal(t, block.Hash(), computeBlockHash(&payload, block.Transactions()))
+
+	_, txs, err := decodeTransactions(*payload.Transactions)
+	require.Nil(t, err)
+	require.Equal(t, block.Hash(), computeBlockHash(&payload, txs))
+	require.Equal(t, block.TxHash(), types.DeriveSha(txs, trie.NewStackTrie(nil)))
+}
+
+func Test_verifyPayload(t *testing.T) {
+	valid := blockPayload(t, testBlock(t))
+	require.Nil(t, verifyPayload(&valid))
+
+	withRoot := valid
+	raw, _, err := decodeTransactions(*valid.Transactions)
+	require.Nil(t, err)
+	withRoot.TransactionsRoot, err = transactionsRoot(raw)
+	require.Nil(t, err)
+	require.Nil(t, verifyPayload(&withRoot))
+
+	withoutTransactions := valid
+	withoutTransactions.Transactions = nil
+	withoutTransactions.BlockHash = common.HexToHash("0x01")
+	require.Nil(t, verifyPayload(&withoutTransactions), "payloads without transactions can't be checked")
+
+	txs := *valid.Transactions
+	tests := map[string]func(p *ExecutionPayloadWithTxRootV1){
+		"block hash":        func(p *ExecutionPayloadWithTxRootV1) { p.BlockHash = common.HexToHash("0x01") },
+		"state root":        func(p *ExecutionPayloadWithTxRootV1) { p.StateRoot = common.HexToHash("0x01") },
+		"transactions root": func(p *ExecutionPayloadWithTxRootV1) { p.TransactionsRoot = common.HexToHash("0x01") },
+		"reordered txs":     func(p *ExecutionPayloadWithTxRootV1) { p.Transactions = &[]string{txs[1], txs[0]} },
+		"dropped tx":        func(p *ExecutionPayloadWithTxRootV1) { p.Transactions = &[]string{txs[0]} },
+		"invalid tx":        func(p *ExecutionPayloadWithTxRootV1) { p.Transactions = &[]string{txs[0], "0x02"} },
+		"logs bloom":        func(p *ExecutionPayloadWithTxRootV1) { p.LogsBloom = p.LogsBloom[1:] },
+		"base fee":          func(p *ExecutionPayloadWithTxRootV1) { p.BaseFeePerGas = nil },
+	}
+	for name, tamper := range tests {
+		t.Run(name, func(t *testing.T) {
+			payload := valid
+			tamper(&payload)
+			require.True(t, errors.Is(verifyPayload(&payload), ErrHeaderMismatch))
+		})
+	}
+}
+
+func TestRelayService_ProposeBlindedBlockV1_VerifiesPayload(t *testing.T) {
+	valid := blockPayload(t, testBlock(t))
+	raw, _, err := decodeTransactions(*valid.Transactions)
+	require.Nil(t, err)
+	valid.TransactionsRoot, err = transactionsRoot(raw)
+	require.Nil(t, err)
+	forged := valid
+	forged.BlockHash = common.HexToHash("0x01")
+
+	for _, tt := range []struct {
+		name    string
+		payload ExecutionPayloadWithTxRootV1
+		err     error
+	}{
+		{"valid", valid, nil},
+		{"block hash not of its contents", forged, ErrHeaderMismatch},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			relay := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				resp, err := formatResponse(tt.payload)
+				require.Nil(t, err)
+				w.Write(resp)
+			}))
+			defer relay.Close()
+			service, err := newRelayService(WithRelayURLs(relay.URL), WithStore(NewStore()), WithLogger(testLog))
+			require.Nil(t, err)
+
+			block := &SignedBlindedBeaconBlock{Message: &BlindedBeaconBlock{Body: &BlindedBeaconBlockBody{ExecutionPayloadHeader: tt.payload.Header()}}}
+			var result ExecutionPayloadWithTxRootV1
+			err = service.ProposeBlindedBlockV1(nil, block, &result)
+			if tt.err == nil {
+				require.Nil(t, err)
+				require.Equal(t, valid.BlockHash, result.BlockHash)
+				return
+			}
+			require.True(t, errors.Is(err, tt.err), "the signed header matches the payload, but the beacon node would publish an invalid block")
+		})
+	}
+}
@@ -0,0 +1,89 @@
+package lib
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+var (
+	maxPaymentRecords = 1000
+
+	errPaymentUnverifiable = errors.New("payment can't be verified from the payload alone")
+	errPaymentMissing      = errors.New("payload has no transactions, proposer payment missing")
+)
+
+// PaymentRecord is the result of checking a revealed payload against the bid it was selected for
+type PaymentRecord struct {
+	BlockHash    common.Hash    `json:"blockHash"`
+	BlockNumber  uint64         `json:"blockNumber"`
+	RelayURL     string         `json:"relayUrl"`
+	Builder      common.Address `json:"builder"` // fee recipient of the block, i.e. the builder when it pays the proposer in a transaction
+	FeeRecipient common.Address `json:"feeRecipient"`
+	Promised     *big.Int       `json:"promised"`
+	Paid         *big.Int       `json:"paid"`
+	Error        string         `json:"error,omitempty"`
+	VerifiedAt   time.Time      `json:"verifiedAt"`
+}
+
+// paymentLog keeps the most recent payment records of relays and builders that underpaid
+type paymentLog struct {
+	mu      sync.RWMutex
+	records []*PaymentRecord
+}
+
+func (l *paymentLog) add(record *PaymentRecord) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.records = append(l.records, record)
+	if len(l.records) > maxPaymentRecords {
+		l.records = l.records[len(l.records)-maxPaymentRecords:]
+	}
+}
+
+func (l *paymentLog) underpayments() []*PaymentRecord {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	records := make([]*PaymentRecord, len(l.records))
+	copy(records, l.records)
+	return records
+}
+
+func decodeTransaction(otx string) (*types.Transaction, error) {
+	tx := new(types.Transaction)
+	if err := tx.UnmarshalBinary(common.FromHex(otx)); err != nil {
+		return nil, err
+	}
+	return tx, nil
+}
+
+// verifyProposerPayment checks that the last transaction of the payload pays at least value to feeRecipient, and returns the amount paid
+func verifyProposerPayment(payload *ExecutionPayloadWithTxRootV1, feeRecipient common.Address, value *big.Int) (*big.Int, error) {
+	if payload.FeeRecipient == feeRecipient {
+		return nil, fmt.Errorf("proposer is the block fee recipient, %w", errPaymentUnverifiable)
+	}
+	if payload.Transactions == nil || len(*payload.Transactions) == 0 {
+		return big.NewInt(0), errPaymentMissing
+	}
+
+	txs := *payload.Transactions
+	tx, err := decodeTransaction(txs[len(txs)-1])
+	if err != nil {
+		return nil, fmt.Errorf("could not decode payment transaction (%v), %w", err, errPaymentUnverifiable)
+	}
+
+	if tx.To() == nil || *tx.To() != feeRecipient {
+		return big.NewInt(0), fmt.Errorf("last transaction is not a payment to the fee recipient %s", feeRecipient.Hex())
+	}
+	if value != nil && tx.Value().Cmp(value) < 0 {
+		return tx.Value(), fmt.Errorf("payment of %s is below the promised %s", tx.Value(), value)
+	}
+	return tx.Value(), nil
+}
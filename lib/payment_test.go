@@ -0,0 +1,156 @@
+package lib
+
+import (
+	"context"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/stretchr/testify/require"
+)
+
+func encodeTx(t *testing.T, to common.Address, value int64) string {
+	tx := types.NewTx(&types.LegacyTx{To: &to, Value: big.NewInt(value), Gas: 21000})
+	txBytes, err := tx.MarshalBinary()
+	require.Nil(t, err)
+	return hexutil.Encode(txBytes)
+}
+
+func Test_verifyProposerPayment(t *testing.T) {
+	builder := common.HexToAddress("0x0000000000000000000000000000000000000001")
+	proposer := common.HexToAddress("0x0000000000000000000000000000000000000002")
+	other := common.HexToAddress("0x0000000000000000000000000000000000000003")
+
+	tests := []struct {
+		name         string
+		feeRecipient common.Address
+		txs          *[]string
+		wantPaid     *big.Int
+		wantErr      bool
+	}{
+		{
+			"pays promised value",
+			builder,
+			&[]string{encodeTx(t, other, 1), encodeTx(t, proposer, 10)},
+			big.NewInt(10),
+			false,
+		},
+		{
+			"pays more than promised",
+			builder,
+			&[]string{encodeTx(t, proposer, 11)},
+			big.NewInt(11),
+			false,
+		},
+		{
+			"underpays",
+			builder,
+			&[]string{encodeTx(t, proposer, 9)},
+			big.NewInt(9),
+			true,
+		},
+		{
+			"last tx pays someone else",
+			builder,
+			&[]string{encodeTx(t, proposer, 10), encodeTx(t, other, 10)},
+			big.NewInt(0),
+			true,
+		},
+		{
+			"no transactions",
+			builder,
+			&[]string{},
+			big.NewInt(0),
+			true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			payload := &ExecutionPayloadWithTxRootV1{FeeRecipient: tt.feeRecipient, Transactions: tt.txs}
+			paid, err := verifyProposerPayment(payload, proposer, big.NewInt(10))
+			if (err != nil) != tt.wantErr {
+				t.Errorf("verifyProposerPayment() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			require.Equal(t, tt.wantPaid, paid)
+		})
+	}
+
+	payload := &ExecutionPayloadWithTxRootV1{FeeRecipient: proposer}
+	_, err := verifyProposerPayment(payload, proposer, big.NewInt(10))
+	require.ErrorIs(t, err, errPaymentUnverifiable)
+
+	payload = &ExecutionPayloadWithTxRootV1{FeeRecipient: builder, Transactions: &[]string{"0x02ff"}}
+	paid, err := verifyProposerPayment(payload, proposer, big.NewInt(10))
+	require.ErrorIs(t, err, errPaymentUnverifiable, "undecodable payment transactions can't be verified")
+	require.Nil(t, paid)
+}
+
+func TestRelayService_verifyPaymentUndecodableTransaction(t *testing.T) {
+	store := NewStore()
+	relay, err := newRelayService(WithRelayURLs("http://relay"), WithStore(store), WithLogger(testLog), WithUnderpaymentSuspension(0, 1))
+	require.Nil(t, err)
+
+	proposer := common.HexToAddress("0x0000000000000000000000000000000000000002")
+	payload := &ExecutionPayloadWithTxRootV1{
+		BlockHash:    common.HexToHash("0x01"),
+		FeeRecipient: common.HexToAddress("0x0000000000000000000000000000000000000001"),
+		Transactions: &[]string{"0x02ff"},
+	}
+	store.SetBid(context.Background(), payload.BlockHash, &Bid{RelayURL: "http://relay", FeeRecipient: proposer, Value: big.NewInt(10)})
+
+	relay.verifyPayment(context.Background(), payload, relay.log)
+	require.Empty(t, relay.payments.underpayments())
+	accounts := relay.accounting.snapshot()
+	require.Equal(t, uint64(1), accounts[0].Unverified)
+	require.Zero(t, accounts[0].Underpaid)
+	require.False(t, relay.blacklist.isSuspended("http://relay"))
+}
+
+func TestRelayService_verifyPaymentRecordsUnderpayment(t *testing.T) {
+	store := NewStore()
+	relay, err := newRelayService(WithRelayURLs("http://relay"), WithStore(store), WithLogger(testLog))
+	require.Nil(t, err)
+
+	proposer := common.HexToAddress("0x0000000000000000000000000000000000000002")
+	payload := &ExecutionPayloadWithTxRootV1{
+		BlockHash:    common.HexToHash("0x01"),
+		FeeRecipient: common.HexToAddress("0x0000000000000000000000000000000000000001"),
+		Transactions: &[]string{encodeTx(t, proposer, 5)},
+	}
+	store.SetBid(context.Background(), payload.BlockHash, &Bid{RelayURL: "http://relay", FeeRecipient: proposer, Value: big.NewInt(10)})
+
+	relay.verifyPayment(context.Background(), payload, relay.log)
+	records := relay.payments.underpayments()
+	require.Equal(t, 1, len(records))
+	require.Equal(t, "http://relay", records[0].RelayURL)
+	require.Equal(t, big.NewInt(5), records[0].Paid)
+
+	accounts := relay.accounting.snapshot()
+	require.Equal(t, 1, len(accounts))
+	require.Equal(t, uint64(1), accounts[0].Underpaid)
+	require.Equal(t, big.NewInt(10), accounts[0].Promised)
+	require.Equal(t, big.NewInt(5), accounts[0].Paid)
+	require.Equal(t, big.NewInt(5), accounts[0].Discrepancy)
+}
+
+func TestGetPayloadHeaderV1_DefaultFeeRecipient(t *testing.T) {
+	relay := newHeaderRelay(t, ExecutionPayloadWithTxRootV1{BlockHash: common.HexToHash("0x01"), BaseFeePerGas: big.NewInt(1), FeeRecipientDiff: big.NewInt(1)})
+	defer relay.Close()
+
+	getBid := func(opts ...Option) *Bid {
+		store := NewStore()
+		store.SetForkchoiceResponse(context.Background(), "0x01", relay.URL, "0x01")
+		service, err := newRelayService(append([]Option{WithRelayURLs(relay.URL), WithStore(store), WithLogger(testLog)}, opts...)...)
+		require.Nil(t, err)
+
+		payloadID := "0x01"
+		require.Nil(t, service.GetPayloadHeaderV1(nil, &payloadID, new(ExecutionPayloadWithTxRootV1)))
+		return store.GetBid(context.Background(), common.HexToHash("0x01"))
+	}
+
+	require.Equal(t, common.Address{}, getBid().FeeRecipient, "payments of bids without fee recipient aren't verified")
+	require.Equal(t, common.HexToAddress("0x0f"), getBid(WithDefaultFeeRecipient(common.HexToAddress("0x0f"))).FeeRecipient)
+}
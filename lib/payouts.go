@@ -0,0 +1,222 @@
+package lib
+
+import (
+	"context"
+	"encoding/csv"
+	"math/big"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// pathAdminPayouts serves the payout report on GET, as CSV with format=csv, for the proposer of the pubkey query
+// parameter if set
+const pathAdminPayouts = "/mev-boost/v1/admin/payouts"
+
+// ProposerPayout is a block proposed through mev-boost with the bid it was selected by
+type ProposerPayout struct {
+	Slot          uint64         `json:"slot,string"`
+	Pubkey        string         `json:"pubkey"` // empty if the proposer isn't known
+	ProposerIndex uint64         `json:"proposerIndex,string"`
+	BlockHash     common.Hash    `json:"blockHash"`
+	RelayURL      string         `json:"relayUrl"` // empty for payloads of local execution clients
+	FeeRecipient  common.Address `json:"feeRecipient"`
+	Value         *big.Int       `json:"value"`
+	ProposedAt    time.Time      `json:"proposedAt"`
+}
+
+// ProposerPayouts totals the values of the blocks a proposer proposed through mev-boost since it started
+type ProposerPayouts struct {
+	Pubkey       string              `json:"pubkey"`
+	Blocks       uint64              `json:"blocks"`
+	Value        *big.Int            `json:"value"`        // sum of the selected bid values in wei
+	ValueByRelay map[string]*big.Int `json:"valueByRelay"` // Value by winning relay
+	LastSlot     uint64              `json:"lastSlot,string"`
+}
+
+// PayoutReport is the payout report of the admin API
+type PayoutReport struct {
+	Proposers []*ProposerPayouts `json:"proposers"`
+	// Blocks are the most recent proposed blocks, oldest first
+	Blocks []ProposerPayout `json:"blocks"`
+}
+
+// payoutCSVHeader are the columns of payout CSV files and exports
+var payoutCSVHeader = []string{"slot", "pubkey", "proposer_index", "block_hash", "relay_url", "fee_recipient", "value_wei", "proposed_at"}
+
+func (p *ProposerPayout) csvRecord() []string {
+	value := "0"
+	if p.Value != nil {
+		value = p.Value.String()
+	}
+	return []string{
+		strconv.FormatUint(p.Slot, 10),
+		p.Pubkey,
+		strconv.FormatUint(p.ProposerIndex, 10),
+		p.BlockHash.Hex(),
+		p.RelayURL,
+		p.FeeRecipient.Hex(),
+		value,
+		p.ProposedAt.UTC().Format(time.RFC3339),
+	}
+}
+
+// PayoutCSVFile appends a row for each block proposed through mev-boost to a CSV file
+type PayoutCSVFile struct {
+	mu     sync.Mutex
+	file   *os.File
+	writer *csv.Writer
+}
+
+// OpenPayoutCSVFile opens path for appending payouts, creating it with a header row if needed
+func OpenPayoutCSVFile(path string) (*PayoutCSVFile, error) {
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	f := &PayoutCSVFile{file: file, writer: csv.NewWriter(file)}
+	if info.Size() == 0 {
+		if err := f.write(payoutCSVHeader); err != nil {
+			file.Close()
+			return nil, err
+		}
+	}
+	return f, nil
+}
+
+func (f *PayoutCSVFile) write(record []string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.writer.Write(record); err != nil {
+		return err
+	}
+	f.writer.Flush()
+	return f.writer.Error()
+}
+
+// payoutLedger keeps the payout totals of each proposer and the most recent proposed blocks
+type payoutLedger struct {
+	mu     sync.RWMutex
+	totals map[string]*ProposerPayouts // map[lowercase pubkey]payouts
+	blocks []*ProposerPayout
+	csv    *PayoutCSVFile // nil unless payouts are appended to a CSV file
+	log    Logger
+}
+
+func newPayoutLedger(csv *PayoutCSVFile, log Logger) *payoutLedger {
+	return &payoutLedger{totals: make(map[string]*ProposerPayouts), csv: csv, log: log}
+}
+
+// add records a proposed block, blocks that were already recorded are ignored
+func (l *payoutLedger) add(payout *ProposerPayout) {
+	l.mu.Lock()
+	for _, existing := range l.blocks {
+		if existing.BlockHash == payout.BlockHash {
+			l.mu.Unlock()
+			return
+		}
+	}
+	l.blocks = append(l.blocks, payout)
+	if len(l.blocks) > maxDeliveredPayloads {
+		l.blocks = l.blocks[len(l.blocks)-maxDeliveredPayloads:]
+	}
+
+	totals, ok := l.totals[payout.Pubkey]
+	if !ok {
+		totals = &ProposerPayouts{Pubkey: payout.Pubkey, Value: new(big.Int), ValueByRelay: make(map[string]*big.Int)}
+		l.totals[payout.Pubkey] = totals
+	}
+	totals.Blocks++
+	if payout.Slot > totals.LastSlot {
+		totals.LastSlot = payout.Slot
+	}
+	if payout.Value != nil {
+		totals.Value.Add(totals.Value, payout.Value)
+		if totals.ValueByRelay[payout.RelayURL] == nil {
+			totals.ValueByRelay[payout.RelayURL] = new(big.Int)
+		}
+		totals.ValueByRelay[payout.RelayURL].Add(totals.ValueByRelay[payout.RelayURL], payout.Value)
+	}
+	l.mu.Unlock()
+
+	if l.csv != nil {
+		if err := l.csv.write(payout.csvRecord()); err != nil {
+			l.log.WithFields(Fields{"slot": payout.Slot, "error": err}).Error("could not append payout to the CSV file")
+		}
+	}
+}
+
+// report returns copies of the totals, sorted by pubkey, and of the recent blocks, of the proposer of pubkey if it isn't
+// empty
+func (l *payoutLedger) report(pubkey string) PayoutReport {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	report := PayoutReport{Proposers: []*ProposerPayouts{}, Blocks: []ProposerPayout{}}
+	for _, totals := range l.totals {
+		if pubkey != "" && totals.Pubkey != pubkey {
+			continue
+		}
+		byRelay := make(map[string]*big.Int, len(totals.ValueByRelay))
+		for relayURL, value := range totals.ValueByRelay {
+			byRelay[relayURL] = new(big.Int).Set(value)
+		}
+		report.Proposers = append(report.Proposers, &ProposerPayouts{
+			Pubkey:       totals.Pubkey,
+			Blocks:       totals.Blocks,
+			Value:        new(big.Int).Set(totals.Value),
+			ValueByRelay: byRelay,
+			LastSlot:     totals.LastSlot,
+		})
+	}
+	sort.Slice(report.Proposers, func(i, j int) bool { return report.Proposers[i].Pubkey < report.Proposers[j].Pubkey })
+	for _, block := range l.blocks {
+		if pubkey == "" || block.Pubkey == pubkey {
+			report.Blocks = append(report.Blocks, *block)
+		}
+	}
+	return report
+}
+
+// recordPayout adds a delivered payload to the payout ledger, with the pubkey of its proposer if it's known
+func (m *RelayService) recordPayout(ctx context.Context, delivery *DeliveredPayload) {
+	m.payouts.add(&ProposerPayout{
+		Slot:          delivery.Slot,
+		Pubkey:        m.proposerOf(ctx, delivery.Slot, delivery.FeeRecipient, m.log),
+		ProposerIndex: delivery.ProposerIndex,
+		BlockHash:     delivery.BlockHash,
+		RelayURL:      delivery.RelayURL,
+		FeeRecipient:  delivery.FeeRecipient,
+		Value:         delivery.Value,
+		ProposedAt:    delivery.DeliveredAt,
+	})
+}
+
+func (m *RelayService) handleAdminPayouts(w http.ResponseWriter, r *http.Request) {
+	report := m.payouts.report(strings.ToLower(r.URL.Query().Get("pubkey")))
+	if r.URL.Query().Get("format") != "csv" {
+		respondJSON(w, http.StatusOK, report)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/csv")
+	w.Header().Set("Content-Disposition", `attachment; filename="payouts.csv"`)
+	writer := csv.NewWriter(w)
+	writer.Write(payoutCSVHeader)
+	for _, block := range report.Blocks {
+		writer.Write(block.csvRecord())
+	}
+	writer.Flush()
+}
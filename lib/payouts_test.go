@@ -0,0 +1,78 @@
+package lib
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRelayService_Payouts(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "payouts.csv")
+	file, err := OpenPayoutCSVFile(path)
+	require.Nil(t, err)
+	service, err := newRelayService(WithRelayURLs("http://relay-a", "http://relay-b"), WithLogger(testLog), WithPayoutCSVFile(file))
+	require.Nil(t, err)
+
+	pubkey := hexutil.Bytes(common.FromHex("0x" + strings.Repeat("ab", 48)))
+	feeRecipient := common.HexToAddress("0x02")
+	service.proposers.register(&ValidatorRegistrationV1{FeeRecipient: feeRecipient, Pubkey: pubkey})
+
+	deliver := func(slot uint64, blockHash common.Hash, relayURL string, value int64, feeRecipient common.Address) {
+		service.store.SetBid(context.Background(), blockHash, &Bid{RelayURL: relayURL, FeeRecipient: feeRecipient, Value: big.NewInt(value)})
+		service.recordDelivery(context.Background(), &BlindedBeaconBlock{Slot: slot, ProposerIndex: 7}, &ExecutionPayloadWithTxRootV1{BlockHash: blockHash}, "")
+	}
+	deliver(5, common.HexToHash("0x05"), "http://relay-a", 10, feeRecipient)
+	deliver(6, common.HexToHash("0x06"), "http://relay-b", 20, feeRecipient)
+	deliver(6, common.HexToHash("0x06"), "http://relay-b", 20, feeRecipient)
+	deliver(8, common.HexToHash("0x08"), "http://relay-a", 40, common.HexToAddress("0x03"))
+
+	router := mux.NewRouter()
+	service.handleAdminAPI(router, "secret", nil)
+	w := adminRequest(t, router, http.MethodGet, pathAdminPayouts, "")
+	require.Equal(t, http.StatusOK, w.Code)
+	var report PayoutReport
+	require.Nil(t, json.Unmarshal(w.Body.Bytes(), &report))
+	require.Len(t, report.Blocks, 3, "blocks revealed twice are counted once")
+	require.Len(t, report.Proposers, 2)
+	require.Equal(t, "", report.Proposers[0].Pubkey, "blocks of unknown proposers are reported without pubkey")
+	proposer := report.Proposers[1]
+	require.Equal(t, pubkey.String(), proposer.Pubkey)
+	require.Equal(t, uint64(2), proposer.Blocks)
+	require.Equal(t, big.NewInt(30), proposer.Value)
+	require.Equal(t, map[string]*big.Int{"http://relay-a": big.NewInt(10), "http://relay-b": big.NewInt(20)}, proposer.ValueByRelay)
+	require.Equal(t, uint64(6), proposer.LastSlot)
+
+	w = adminRequest(t, router, http.MethodGet, pathAdminPayouts+"?format=csv&pubkey=0x"+strings.ToUpper(pubkey.String()[2:]), "")
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Equal(t, "text/csv", w.Header().Get("Content-Type"))
+	records, err := csv.NewReader(w.Body).ReadAll()
+	require.Nil(t, err)
+	require.Len(t, records, 3)
+	require.Equal(t, payoutCSVHeader, records[0])
+	require.Equal(t, []string{"6", pubkey.String(), "7", common.HexToHash("0x06").Hex(), "http://relay-b", feeRecipient.Hex(), "20"}, records[2][:7])
+
+	exported, err := os.ReadFile(path)
+	require.Nil(t, err)
+	records, err = csv.NewReader(strings.NewReader(string(exported))).ReadAll()
+	require.Nil(t, err)
+	require.Len(t, records, 4, "the file has a header and a row per block")
+	require.Equal(t, "40", records[3][6])
+
+	file, err = OpenPayoutCSVFile(path)
+	require.Nil(t, err)
+	require.Nil(t, file.write([]string{"9"}))
+	exported, err = os.ReadFile(path)
+	require.Nil(t, err)
+	require.Equal(t, 1, strings.Count(string(exported), "slot,"), "reopened files get no second header")
+}
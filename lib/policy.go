@@ -0,0 +1,131 @@
+package lib
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var policyDecisionsTotal = metricsFactory.NewCounterVec(prometheus.CounterOpts{
+	Name: "mevboost_policy_decisions_total",
+	Help: "Bids evaluated by the policy engine, by decision: allow, deny or error",
+}, []string{"decision"})
+
+// PolicyBid is the input document of a bid evaluated by the policy engine
+type PolicyBid struct {
+	Relay       string         `json:"relay"`                  // scheme and host of the relay
+	RelayPubkey string         `json:"relay_pubkey,omitempty"` // from the user part of the relay url
+	Builder     common.Address `json:"builder"`                // fee recipient of the payload
+	Slot        uint64         `json:"slot"`
+	BlockNumber uint64         `json:"block_number"`
+	BlockHash   common.Hash    `json:"block_hash"`
+	Value       string         `json:"value"` // in wei, as a decimal string because policies can't handle 256-bit numbers
+	ExtraData   hexutil.Bytes  `json:"extra_data"`
+	GasLimit    uint64         `json:"gas_limit"`
+	GasUsed     uint64         `json:"gas_used"`
+	Rank        int            `json:"rank"`       // of the bid among the candidates, 0 is the most valuable
+	Candidates  int            `json:"candidates"` // number of candidates
+}
+
+// policyDecision is the result of a policy, either a bool or a document with an allow field and an optional reason
+type policyDecision struct {
+	Allow  bool   `json:"allow"`
+	Reason string `json:"reason"`
+}
+
+func (d *policyDecision) UnmarshalJSON(data []byte) error {
+	if err := json.Unmarshal(data, &d.Allow); err == nil {
+		return nil
+	}
+	type document policyDecision
+	return json.Unmarshal(data, (*document)(d))
+}
+
+// NewOPABidDecision returns a BidDecision that asks an Open Policy Agent for each winning bid, by POSTing a PolicyBid as
+// input to policyURL, e.g. http://127.0.0.1:8181/v1/data/mevboost/allow. A result of false, or of an object whose allow field
+// isn't true, vetoes the bid. If the policy engine can't be asked or has no result, the bid is vetoed unless failOpen.
+func NewOPABidDecision(policyURL string, chain *ChainConfig, failOpen bool) BidDecision {
+	return func(ctx context.Context, candidates []BidCandidate, winner BidCandidate) error {
+		input := newPolicyBid(chain, candidates, winner)
+		decision, err := evaluatePolicy(ctx, policyURL, input)
+		if err != nil {
+			policyDecisionsTotal.WithLabelValues("error").Inc()
+			if failOpen {
+				return nil
+			}
+			return fmt.Errorf("policy engine: %w", err)
+		}
+		if !decision.Allow {
+			policyDecisionsTotal.WithLabelValues("deny").Inc()
+			return fmt.Errorf("denied by policy: %s", decision.Reason)
+		}
+		policyDecisionsTotal.WithLabelValues("allow").Inc()
+		return nil
+	}
+}
+
+func newPolicyBid(chain *ChainConfig, candidates []BidCandidate, winner BidCandidate) *PolicyBid {
+	header := winner.Header
+	bid := &PolicyBid{
+		Relay:       winner.RelayURL,
+		Builder:     header.FeeRecipient,
+		Slot:        chain.SlotAt(header.Timestamp),
+		BlockNumber: header.Number,
+		BlockHash:   header.BlockHash,
+		Value:       bidValue(header).String(),
+		ExtraData:   header.ExtraData,
+		GasLimit:    header.GasLimit,
+		GasUsed:     header.GasUsed,
+		Candidates:  len(candidates),
+	}
+	if u, err := url.Parse(winner.RelayURL); err == nil {
+		bid.Relay = u.Scheme + "://" + u.Host
+		bid.RelayPubkey = u.User.Username()
+	}
+	for i, candidate := range candidates {
+		if candidate.Header == header {
+			bid.Rank = i
+		}
+	}
+	return bid
+}
+
+// evaluatePolicy posts input to the OPA data API at policyURL and returns its result
+func evaluatePolicy(ctx context.Context, policyURL string, input *PolicyBid) (*policyDecision, error) {
+	body, err := json.Marshal(map[string]interface{}{"input": input})
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, policyURL, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("responded with status %d", resp.StatusCode)
+	}
+
+	var res struct {
+		Result *policyDecision `json:"result"`
+	}
+	if err := json.NewDecoder(defaultResponseLimit.reader(resp.Body)).Decode(&res); err != nil {
+		return nil, fmt.Errorf("could not decode response: %w", err)
+	}
+	if res.Result == nil {
+		return nil, fmt.Errorf("no result, is the policy at %s defined?", policyURL)
+	}
+	return res.Result, nil
+}
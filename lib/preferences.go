@@ -0,0 +1,315 @@
+package lib
+
+import (
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/gorilla/mux"
+)
+
+// ValidatorPreferences are the settings of a single validator, managed at runtime through the keymanager-style API.
+// Unset fields fall back to the mev-boost defaults.
+type ValidatorPreferences struct {
+	Pubkey         string          `json:"pubkey"`
+	FeeRecipient   *common.Address `json:"fee_recipient,omitempty"`
+	GasLimit       *uint64         `json:"gas_limit,string,omitempty"`
+	MinBid         *big.Int        `json:"min_bid,omitempty"`
+	RelayAllowlist []string        `json:"relays,omitempty"`
+}
+
+// validatorPreferences keeps the preferences per validator pubkey
+type validatorPreferences struct {
+	mu          sync.RWMutex
+	preferences map[string]*ValidatorPreferences // map[lowercase pubkey]
+}
+
+func newValidatorPreferences() *validatorPreferences {
+	return &validatorPreferences{preferences: make(map[string]*ValidatorPreferences)}
+}
+
+// get returns a copy of the preferences of a validator, or nil if none are set
+func (p *validatorPreferences) get(pubkey string) *ValidatorPreferences {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	preferences, ok := p.preferences[strings.ToLower(pubkey)]
+	if !ok {
+		return nil
+	}
+	return preferences.copy()
+}
+
+// update applies fn to the preferences of a validator, creating them if needed
+func (p *validatorPreferences) update(pubkey string, fn func(*ValidatorPreferences)) *ValidatorPreferences {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	pubkey = strings.ToLower(pubkey)
+	preferences, ok := p.preferences[pubkey]
+	if !ok {
+		preferences = &ValidatorPreferences{Pubkey: pubkey}
+		p.preferences[pubkey] = preferences
+	}
+	fn(preferences)
+
+	if preferences.FeeRecipient == nil && preferences.GasLimit == nil && preferences.MinBid == nil && len(preferences.RelayAllowlist) == 0 {
+		delete(p.preferences, pubkey)
+	}
+	return preferences.copy()
+}
+
+func (p *validatorPreferences) delete(pubkey string) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	pubkey = strings.ToLower(pubkey)
+	_, ok := p.preferences[pubkey]
+	delete(p.preferences, pubkey)
+	return ok
+}
+
+// all returns copies of all preferences, sorted by pubkey
+func (p *validatorPreferences) all() []*ValidatorPreferences {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	all := make([]*ValidatorPreferences, 0, len(p.preferences))
+	for _, preferences := range p.preferences {
+		all = append(all, preferences.copy())
+	}
+	sort.Slice(all, func(i, j int) bool { return all[i].Pubkey < all[j].Pubkey })
+	return all
+}
+
+// hasMinBid reports whether any validator has a min bid
+func (p *validatorPreferences) hasMinBid() bool {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	for _, preferences := range p.preferences {
+		if preferences.MinBid != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// hasRelayAllowlist reports whether any validator has a relay allowlist
+func (p *validatorPreferences) hasRelayAllowlist() bool {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	for _, preferences := range p.preferences {
+		if len(preferences.RelayAllowlist) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+func (v *ValidatorPreferences) copy() *ValidatorPreferences {
+	c := &ValidatorPreferences{Pubkey: v.Pubkey}
+	if v.FeeRecipient != nil {
+		feeRecipient := *v.FeeRecipient
+		c.FeeRecipient = &feeRecipient
+	}
+	if v.GasLimit != nil {
+		gasLimit := *v.GasLimit
+		c.GasLimit = &gasLimit
+	}
+	if v.MinBid != nil {
+		c.MinBid = new(big.Int).Set(v.MinBid)
+	}
+	c.RelayAllowlist = append(c.RelayAllowlist, v.RelayAllowlist...)
+	return c
+}
+
+// keymanagerError is the error body of the keymanager API
+type keymanagerError struct {
+	Message string `json:"message"`
+}
+
+// keymanagerData wraps responses like the keymanager API
+type keymanagerData struct {
+	Data interface{} `json:"data"`
+}
+
+// requireBearerToken rejects requests that don't carry the API token, like the keymanager API
+func requireBearerToken(token string, next http.HandlerFunc) http.HandlerFunc {
+	return BearerTokenMiddleware(token)(next).ServeHTTP
+}
+
+// pubkeyFromRequest returns the validator pubkey of the request path, or writes an error response
+func pubkeyFromRequest(w http.ResponseWriter, r *http.Request) (string, bool) {
+	pubkey := mux.Vars(r)["pubkey"]
+	if b, err := hexutil.Decode(pubkey); err != nil || len(b) != 48 {
+		respondJSON(w, http.StatusBadRequest, keymanagerError{"invalid validator pubkey"})
+		return "", false
+	}
+	return strings.ToLower(pubkey), true
+}
+
+func (m *RelayService) handleListPreferences(w http.ResponseWriter, _ *http.Request) {
+	respondJSON(w, http.StatusOK, keymanagerData{m.preferences.all()})
+}
+
+func (m *RelayService) handleGetPreferences(w http.ResponseWriter, r *http.Request) {
+	pubkey, ok := pubkeyFromRequest(w, r)
+	if !ok {
+		return
+	}
+	preferences := m.preferences.get(pubkey)
+	if preferences == nil {
+		respondJSON(w, http.StatusNotFound, keymanagerError{"no preferences for validator"})
+		return
+	}
+	respondJSON(w, http.StatusOK, keymanagerData{preferences})
+}
+
+func (m *RelayService) handleSetPreferences(w http.ResponseWriter, r *http.Request) {
+	pubkey, ok := pubkeyFromRequest(w, r)
+	if !ok {
+		return
+	}
+	req := new(ValidatorPreferences)
+	if err := json.NewDecoder(r.Body).Decode(req); err != nil {
+		respondJSON(w, http.StatusBadRequest, keymanagerError{"invalid preferences: " + err.Error()})
+		return
+	}
+	if req.MinBid != nil && req.MinBid.Sign() < 0 {
+		respondJSON(w, http.StatusBadRequest, keymanagerError{"min_bid must not be negative"})
+		return
+	}
+	for _, entry := range req.RelayAllowlist {
+		if _, err := resolveRelay(entry, m.relays.all()); err != nil {
+			respondJSON(w, http.StatusBadRequest, keymanagerError{"invalid relays: " + err.Error()})
+			return
+		}
+	}
+
+	preferences := m.preferences.update(pubkey, func(p *ValidatorPreferences) {
+		p.FeeRecipient = req.FeeRecipient
+		p.GasLimit = req.GasLimit
+		p.MinBid = req.MinBid
+		p.RelayAllowlist = req.RelayAllowlist
+	})
+	m.log.WithField("pubkey", pubkey).Info("updated validator preferences")
+	m.registerPreferences(r.Context(), pubkey, m.log)
+	m.checkGasLimitPreference(r.Context(), pubkey, m.log)
+	respondJSON(w, http.StatusOK, keymanagerData{preferences})
+}
+
+func (m *RelayService) handleDeletePreferences(w http.ResponseWriter, r *http.Request) {
+	pubkey, ok := pubkeyFromRequest(w, r)
+	if !ok {
+		return
+	}
+	if !m.preferences.delete(pubkey) {
+		respondJSON(w, http.StatusNotFound, keymanagerError{"no preferences for validator"})
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// feeRecipientResponse is the body of the keymanager feerecipient endpoint
+type feeRecipientResponse struct {
+	Pubkey     string         `json:"pubkey"`
+	EthAddress common.Address `json:"ethaddress"`
+}
+
+func (m *RelayService) handleGetFeeRecipient(w http.ResponseWriter, r *http.Request) {
+	pubkey, ok := pubkeyFromRequest(w, r)
+	if !ok {
+		return
+	}
+	preferences := m.preferences.get(pubkey)
+	if preferences == nil || preferences.FeeRecipient == nil {
+		respondJSON(w, http.StatusNotFound, keymanagerError{"no fee recipient for validator"})
+		return
+	}
+	respondJSON(w, http.StatusOK, keymanagerData{feeRecipientResponse{pubkey, *preferences.FeeRecipient}})
+}
+
+func (m *RelayService) handleSetFeeRecipient(w http.ResponseWriter, r *http.Request) {
+	pubkey, ok := pubkeyFromRequest(w, r)
+	if !ok {
+		return
+	}
+	req := new(struct {
+		EthAddress *common.Address `json:"ethaddress"`
+	})
+	if err := json.NewDecoder(r.Body).Decode(req); err != nil || req.EthAddress == nil {
+		respondJSON(w, http.StatusBadRequest, keymanagerError{"invalid ethaddress"})
+		return
+	}
+	m.preferences.update(pubkey, func(p *ValidatorPreferences) { p.FeeRecipient = req.EthAddress })
+	m.registerPreferences(r.Context(), pubkey, m.log)
+	w.WriteHeader(http.StatusAccepted)
+}
+
+func (m *RelayService) handleDeleteFeeRecipient(w http.ResponseWriter, r *http.Request) {
+	pubkey, ok := pubkeyFromRequest(w, r)
+	if !ok {
+		return
+	}
+	m.preferences.update(pubkey, func(p *ValidatorPreferences) { p.FeeRecipient = nil })
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// gasLimitResponse is the body of the keymanager gas_limit endpoint
+type gasLimitResponse struct {
+	Pubkey   string `json:"pubkey"`
+	GasLimit string `json:"gas_limit"`
+}
+
+func (m *RelayService) handleGetGasLimit(w http.ResponseWriter, r *http.Request) {
+	pubkey, ok := pubkeyFromRequest(w, r)
+	if !ok {
+		return
+	}
+	preferences := m.preferences.get(pubkey)
+	if preferences == nil || preferences.GasLimit == nil {
+		respondJSON(w, http.StatusNotFound, keymanagerError{"no gas limit for validator"})
+		return
+	}
+	respondJSON(w, http.StatusOK, keymanagerData{gasLimitResponse{pubkey, strconv.FormatUint(*preferences.GasLimit, 10)}})
+}
+
+func (m *RelayService) handleSetGasLimit(w http.ResponseWriter, r *http.Request) {
+	pubkey, ok := pubkeyFromRequest(w, r)
+	if !ok {
+		return
+	}
+	req := new(struct {
+		GasLimit string `json:"gas_limit"`
+	})
+	if err := json.NewDecoder(r.Body).Decode(req); err != nil {
+		respondJSON(w, http.StatusBadRequest, keymanagerError{"invalid gas_limit"})
+		return
+	}
+	gasLimit, err := strconv.ParseUint(req.GasLimit, 10, 64)
+	if err != nil {
+		respondJSON(w, http.StatusBadRequest, keymanagerError{"invalid gas_limit"})
+		return
+	}
+	m.preferences.update(pubkey, func(p *ValidatorPreferences) { p.GasLimit = &gasLimit })
+	m.registerPreferences(r.Context(), pubkey, m.log)
+	m.checkGasLimitPreference(r.Context(), pubkey, m.log)
+	w.WriteHeader(http.StatusAccepted)
+}
+
+func (m *RelayService) handleDeleteGasLimit(w http.ResponseWriter, r *http.Request) {
+	pubkey, ok := pubkeyFromRequest(w, r)
+	if !ok {
+		return
+	}
+	m.preferences.update(pubkey, func(p *ValidatorPreferences) { p.GasLimit = nil })
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,59 @@
+package lib
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPreferencesAPI(t *testing.T) {
+	pubkey := "0x" + strings.Repeat("ab", 48)
+	router, err := NewRouter(context.Background(), WithRelayURLs("http://bar"), WithStore(NewStore()), WithLogger(testLog), WithPreferencesAPI("secret"))
+	require.Nil(t, err)
+
+	request := func(method, path, token, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		return rr
+	}
+
+	tests := []struct {
+		name         string
+		method       string
+		path         string
+		token        string
+		body         string
+		expectedCode int
+		expectedBody string
+	}{
+		{"missing token", http.MethodGet, "/eth/v1/validator/preferences", "", "", http.StatusUnauthorized, ""},
+		{"invalid token", http.MethodGet, "/eth/v1/validator/preferences", "wrong", "", http.StatusForbidden, ""},
+		{"invalid pubkey", http.MethodGet, "/eth/v1/validator/0x1234/preferences", "secret", "", http.StatusBadRequest, ""},
+		{"no preferences", http.MethodGet, "/eth/v1/validator/" + pubkey + "/preferences", "secret", "", http.StatusNotFound, ""},
+		{"set fee recipient", http.MethodPost, "/eth/v1/validator/" + pubkey + "/feerecipient", "secret", `{"ethaddress":"0xdb65fed33dc262fe09d9a2ba8f80b329ba25f941"}`, http.StatusAccepted, ""},
+		{"set gas limit", http.MethodPost, "/eth/v1/validator/" + pubkey + "/gas_limit", "secret", `{"gas_limit":"30000000"}`, http.StatusAccepted, ""},
+		{"get gas limit", http.MethodGet, "/eth/v1/validator/" + pubkey + "/gas_limit", "secret", "", http.StatusOK, `{"data":{"pubkey":"` + pubkey + `","gas_limit":"30000000"}}`},
+		{"list", http.MethodGet, "/eth/v1/validator/preferences", "secret", "", http.StatusOK, `{"data":[{"pubkey":"` + pubkey + `","fee_recipient":"0xdb65fed33dc262fe09d9a2ba8f80b329ba25f941","gas_limit":"30000000"}]}`},
+		{"set preferences", http.MethodPost, "/eth/v1/validator/" + pubkey + "/preferences", "secret", `{"min_bid":1000,"relays":["http://bar"]}`, http.StatusOK, `{"data":{"pubkey":"` + pubkey + `","min_bid":1000,"relays":["http://bar"]}}`},
+		{"fee recipient replaced", http.MethodGet, "/eth/v1/validator/" + pubkey + "/feerecipient", "secret", "", http.StatusNotFound, ""},
+		{"delete", http.MethodDelete, "/eth/v1/validator/" + pubkey + "/preferences", "secret", "", http.StatusNoContent, ""},
+		{"list empty", http.MethodGet, "/eth/v1/validator/preferences", "secret", "", http.StatusOK, `{"data":[]}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := request(tt.method, tt.path, tt.token, tt.body)
+			require.Equal(t, tt.expectedCode, rr.Code, rr.Body.String())
+			if tt.expectedBody != "" {
+				require.JSONEq(t, tt.expectedBody, rr.Body.String())
+			}
+		})
+	}
+}
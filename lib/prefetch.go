@@ -0,0 +1,176 @@
+package lib
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// headerPrefetchMaxAge is how long prefetched headers are used, later getPayloadHeader calls ask the relays again for
+// bids that may have improved
+var headerPrefetchMaxAge = 2 * time.Second
+
+// headerPrefetch is a header request started at the slot start, before the consensus client asks for the header
+type headerPrefetch struct {
+	slot      uint64
+	done      chan struct{}
+	fetched   *headerFetch
+	fetchedAt time.Time
+}
+
+// headerPrefetcher requests headers from the relays at the start of the slots local validators propose in, so
+// getPayloadHeader is answered from warm state. The proposer slots are taken from the duties of the beacon node.
+type headerPrefetcher struct {
+	beacon  *BeaconClient
+	pubkeys map[string]bool // lowercase pubkeys of local validators
+
+	mu            sync.Mutex
+	proposerSlots map[uint64]bool
+	dutyEpochs    map[uint64]bool            // epochs whose duties are known
+	prefetches    map[string]*headerPrefetch // map[boost payload id]
+}
+
+func newHeaderPrefetcher(beacon *BeaconClient, validatorPubkeys []string) *headerPrefetcher {
+	pubkeys := make(map[string]bool, len(validatorPubkeys))
+	for _, pubkey := range validatorPubkeys {
+		pubkeys[strings.ToLower(pubkey)] = true
+	}
+	return &headerPrefetcher{
+		beacon:        beacon,
+		pubkeys:       pubkeys,
+		proposerSlots: make(map[uint64]bool),
+		dutyEpochs:    make(map[uint64]bool),
+		prefetches:    make(map[string]*headerPrefetch),
+	}
+}
+
+// updateDuties fetches the proposer duties of the current and the next epoch, unless already known
+func (p *headerPrefetcher) updateDuties(ctx context.Context, chain *ChainConfig) error {
+	current := chain.CurrentSlot() / chain.SlotsPerEpoch
+	for epoch := current; epoch <= current+1; epoch++ {
+		p.mu.Lock()
+		known := p.dutyEpochs[epoch]
+		p.mu.Unlock()
+		if known {
+			continue
+		}
+
+		duties, err := p.beacon.ProposerDuties(ctx, epoch)
+		if err != nil {
+			return err
+		}
+		p.mu.Lock()
+		for _, duty := range duties {
+			if !p.pubkeys[strings.ToLower(duty.Pubkey)] {
+				continue
+			}
+			slot, err := strconv.ParseUint(duty.Slot, 10, 64)
+			if err != nil {
+				p.mu.Unlock()
+				return fmt.Errorf("invalid slot %q of proposer duty: %w", duty.Slot, err)
+			}
+			p.proposerSlots[slot] = true
+		}
+		p.dutyEpochs[epoch] = true
+		p.mu.Unlock()
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for epoch := range p.dutyEpochs {
+		if epoch < current {
+			delete(p.dutyEpochs, epoch)
+		}
+	}
+	for slot := range p.proposerSlots {
+		if slot/chain.SlotsPerEpoch < current {
+			delete(p.proposerSlots, slot)
+		}
+	}
+	return nil
+}
+
+// isProposer reports whether a local validator proposes in slot
+func (p *headerPrefetcher) isProposer(slot uint64) bool {
+	if p == nil {
+		return false
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.proposerSlots[slot]
+}
+
+// start registers a prefetch for a payload id, prefetches of earlier slots are dropped
+func (p *headerPrefetcher) start(payloadID string, slot uint64) *headerPrefetch {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for id, prefetch := range p.prefetches {
+		if prefetch.slot < slot {
+			delete(p.prefetches, id)
+		}
+	}
+	prefetch := &headerPrefetch{slot: slot, done: make(chan struct{})}
+	p.prefetches[payloadID] = prefetch
+	return prefetch
+}
+
+// wait returns the prefetched headers of a payload id, waiting for a prefetch in progress. It returns false if there
+// is no prefetch, the prefetched headers are older than headerPrefetchMaxAge or ctx is done first.
+func (p *headerPrefetcher) wait(ctx context.Context, payloadID string) (*headerFetch, bool) {
+	if p == nil {
+		return nil, false
+	}
+	p.mu.Lock()
+	prefetch, ok := p.prefetches[payloadID]
+	delete(p.prefetches, payloadID) // the headers of a prefetch are handed out once
+	p.mu.Unlock()
+	if !ok {
+		return nil, false
+	}
+
+	select {
+	case <-prefetch.done:
+	case <-ctx.Done():
+		return nil, false
+	}
+	if now().Sub(prefetch.fetchedAt) > headerPrefetchMaxAge {
+		return nil, false
+	}
+	return prefetch.fetched, true
+}
+
+// prefetchHeaders requests the headers of a payload id at the start of slot, if a local validator proposes in it
+func (m *RelayService) prefetchHeaders(payloadID string, slot uint64) {
+	if !m.prefetcher.isProposer(slot) {
+		return
+	}
+	prefetch := m.prefetcher.start(payloadID, slot)
+	log := m.log.WithFields(Fields{"method": "engine_getPayloadV1", "payloadID": payloadID, "slot": slot})
+
+	time.AfterFunc(m.chain.SlotStartTime(slot).Sub(now()), func() {
+		defer close(prefetch.done)
+		ctx, cancel := context.WithTimeout(context.Background(), m.chain.SlotDuration())
+		defer cancel()
+
+		forkchoiceResponses, found := m.store.GetForkchoiceResponse(ctx, payloadID)
+		if !found {
+			return
+		}
+		log.Debug("prefetching headers")
+		prefetch.fetched = m.fetchHeaders(ctx, forkchoiceResponses, slot, log)
+		prefetch.fetchedAt = now()
+	})
+}
+
+// startHeaderPrefetch keeps the proposer duties of local validators up to date until ctx is done
+func (m *RelayService) startHeaderPrefetch(ctx context.Context) {
+	runLoop(ctx, m.log, "proposer_duties", m.chain.SlotDuration(), true, func(ctx context.Context) {
+		if err := m.prefetcher.updateDuties(ctx, m.chain); err != nil {
+			m.log.WithError(err).Warn("could not get proposer duties")
+		}
+	})
+}
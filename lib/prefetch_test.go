@@ -0,0 +1,90 @@
+package lib
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRelayService_prefetchHeaders(t *testing.T) {
+	defer func() { now = time.Now }()
+	now = func() time.Time { return time.Unix(102, 0) } // slot 17 of epoch 2
+
+	const localPubkey = "0xa1"
+	beaconNode := newMockBeaconNode(t, map[string]string{
+		"/eth/v1/validator/duties/proposer/2": `{"data":[{"pubkey":"0xA1","validator_index":"1","slot":"17"},{"pubkey":"0xb2","validator_index":"2","slot":"18"}]}`,
+		"/eth/v1/validator/duties/proposer/3": `{"data":[]}`,
+	})
+	defer beaconNode.Close()
+
+	var getHeaderCalls int32
+	relay := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := ioutil.ReadAll(r.Body)
+		require.Nil(t, err)
+		var req rpcRequest
+		require.Nil(t, json.Unmarshal(body, &req))
+
+		var resp []byte
+		switch req.Method {
+		case methodForkchoiceUpdated:
+			resp, err = formatResponse(ForkChoiceResponse{PayloadStatus: PayloadStatus{Status: ForkchoiceStatusValid}, PayloadID: &hexutil.Bytes{1}})
+		case methodRelayGetHeader:
+			atomic.AddInt32(&getHeaderCalls, 1)
+			resp, err = formatResponse(ExecutionPayloadWithTxRootV1{BlockHash: common.HexToHash("0x01"), BaseFeePerGas: big.NewInt(7), FeeRecipientDiff: big.NewInt(1)})
+		}
+		require.Nil(t, err)
+		w.Write(resp)
+	}))
+	defer relay.Close()
+
+	chain := &ChainConfig{GenesisTime: 0, SecondsPerSlot: 6, SlotsPerEpoch: 8}
+	service, err := newRelayService(WithRelayURLs(relay.URL), WithStore(NewStore()), WithLogger(testLog), WithChainConfig(chain),
+		WithValidatorPubkeys(localPubkey), WithHeaderPrefetch(NewBeaconClient(beaconNode.URL)))
+	require.Nil(t, err)
+	require.Nil(t, service.prefetcher.updateDuties(context.Background(), chain))
+
+	getHeader := func(timestamp uint64) {
+		req, _ := http.NewRequest(http.MethodPost, "/", nil)
+		args := []interface{}{map[string]interface{}{}, map[string]interface{}{"timestamp": hexutil.Uint64(timestamp).String()}}
+		fcu := new(ForkChoiceResponse)
+		require.Nil(t, service.ForkchoiceUpdatedV1(req, &args, fcu))
+		if timestamp == 102 {
+			require.Eventually(t, func() bool { return atomic.LoadInt32(&getHeaderCalls) == 1 }, time.Second, time.Millisecond, "headers are prefetched at the slot start")
+		}
+		payloadID := fcu.PayloadID.String()
+		header := new(ExecutionPayloadWithTxRootV1)
+		require.Nil(t, service.GetPayloadHeaderV1(req, &payloadID, header))
+		require.Equal(t, common.HexToHash("0x01"), header.BlockHash)
+	}
+
+	getHeader(102)
+	require.Equal(t, int32(1), atomic.LoadInt32(&getHeaderCalls), "prefetched headers are used")
+
+	getHeader(108) // another validator proposes in slot 18
+	require.Equal(t, int32(2), atomic.LoadInt32(&getHeaderCalls))
+}
+
+func TestHeaderFetch_of(t *testing.T) {
+	fetched := newHeaderFetch()
+	for i, url := range []string{"http://a", "http://b", "http://c"} {
+		header := &ExecutionPayloadWithTxRootV1{BlockHash: common.BigToHash(big.NewInt(int64(i + 1))), FeeRecipientDiff: big.NewInt(int64(i + 1))}
+		fetched.bids = append(fetched.bids, bidObservation{url, header.BlockHash, header.FeeRecipientDiff})
+		fetched.candidates = append(fetched.candidates, BidCandidate{url, header})
+	}
+
+	// prefetched headers of relays outside the tenant or relay groups of the request are dropped
+	filtered := fetched.of(map[string]string{"http://a": "0x01", "http://c": "0x03"})
+	require.Equal(t, []bidObservation{fetched.bids[0], fetched.bids[2]}, filtered.bids)
+	require.Equal(t, []BidCandidate{fetched.candidates[0], fetched.candidates[2]}, filtered.candidates)
+	require.Len(t, fetched.candidates, 3, "the prefetch is unchanged")
+}
@@ -0,0 +1,233 @@
+package lib
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/gorilla/websocket"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Priorities of incoming requests
+const (
+	priorityBackground = iota // registrations, status, data API, metrics
+	priorityCritical          // getPayloadHeader and proposeBlindedBlock of the current slot
+)
+
+var priorityNames = [...]string{priorityBackground: "background", priorityCritical: "critical"}
+
+// criticalWeight is the number of critical requests admitted in a row while background requests wait, so a steady
+// stream of critical requests doesn't starve background traffic
+const criticalWeight = 4
+
+// proposalMethods are the methods of a proposal, their deprecated versions are looked up in deprecatedMethods
+var proposalMethods = map[string]bool{
+	"builder_getPayloadHeaderV1":    true,
+	"builder_proposeBlindedBlockV1": true,
+	"builder_getPayloadHeaderV2":    true,
+	"builder_proposeBlindedBlockV2": true,
+}
+
+var requestsQueued = metricsFactory.NewGaugeVec(prometheus.GaugeOpts{
+	Name: "mevboost_requests_queued",
+	Help: "Incoming requests waiting for one of -maxConcurrentRequests, by priority",
+}, []string{"priority"})
+
+var requestsShedTotal = metricsFactory.NewCounterVec(prometheus.CounterOpts{
+	Name: "mevboost_requests_shed_total",
+	Help: "Background requests rejected under overload, by reason: queue (too many waiting) or wait (waited too long)",
+}, []string{"reason"})
+
+// errRequestShed means a background request was rejected because mev-boost is overloaded
+var errRequestShed = errors.New("request shed under overload")
+
+// priorityQueue serves at most limit requests at once. Waiting critical requests are admitted before background ones,
+// in a weighted round of criticalWeight critical requests per background request. Under overload, background requests
+// are shed instead of waiting; critical requests always wait for their turn.
+type priorityQueue struct {
+	limit      int
+	shedQueued int           // background requests waiting before further ones are shed, 0 never sheds
+	shedWait   time.Duration // how long a background request waits before it's shed, 0 waits as long as its client
+
+	mu      sync.Mutex
+	running int
+	waiting [2][]chan struct{} // FIFO per priority
+	streak  int                // critical requests admitted in a row while background requests waited
+}
+
+func newPriorityQueue(limit int) *priorityQueue {
+	return &priorityQueue{limit: limit}
+}
+
+// acquire waits until a request of priority may run, or ctx is done. Background requests return errRequestShed if
+// too many of them wait already, or if they waited for shedWait.
+func (q *priorityQueue) acquire(ctx context.Context, priority int) error {
+	q.mu.Lock()
+	if q.running < q.limit {
+		q.running++
+		q.mu.Unlock()
+		return nil
+	}
+	if priority == priorityBackground && q.shedQueued > 0 && len(q.waiting[priority]) >= q.shedQueued {
+		q.mu.Unlock()
+		requestsShedTotal.WithLabelValues("queue").Inc()
+		return errRequestShed
+	}
+	admitted := make(chan struct{})
+	q.waiting[priority] = append(q.waiting[priority], admitted)
+	requestsQueued.WithLabelValues(priorityNames[priority]).Inc()
+	q.mu.Unlock()
+
+	var shed <-chan time.Time
+	if priority == priorityBackground && q.shedWait > 0 {
+		timer := time.NewTimer(q.shedWait)
+		defer timer.Stop()
+		shed = timer.C
+	}
+	var err error
+	select {
+	case <-admitted:
+		return nil
+	case <-ctx.Done():
+		err = ctx.Err()
+	case <-shed:
+		requestsShedTotal.WithLabelValues("wait").Inc()
+		err = errRequestShed
+	}
+
+	q.mu.Lock()
+	for i, waiting := range q.waiting[priority] {
+		if waiting == admitted {
+			q.waiting[priority] = append(q.waiting[priority][:i], q.waiting[priority][i+1:]...)
+			requestsQueued.WithLabelValues(priorityNames[priority]).Dec()
+			q.mu.Unlock()
+			return err
+		}
+	}
+	q.mu.Unlock()
+	q.release() // admitted while giving up, pass the slot on
+	return err
+}
+
+// release ends a request and hands its slot to the next waiting request
+func (q *priorityQueue) release() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	critical, background := len(q.waiting[priorityCritical]) > 0, len(q.waiting[priorityBackground]) > 0
+	var next int
+	switch {
+	case critical && (!background || q.streak < criticalWeight):
+		next = priorityCritical
+		if background {
+			q.streak++
+		}
+	case background:
+		next = priorityBackground
+		q.streak = 0
+	default:
+		q.running--
+		return
+	}
+	admitted := q.waiting[next][0]
+	q.waiting[next] = q.waiting[next][1:]
+	requestsQueued.WithLabelValues(priorityNames[next]).Dec()
+	close(admitted)
+}
+
+// handler queues requests by the priority of classify
+func (q *priorityQueue) handler(classify func(*http.Request) int, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if websocket.IsWebSocketUpgrade(r) { // subscriptions would hold on to their slot as long as they are connected
+			next.ServeHTTP(w, r)
+			return
+		}
+		if err := q.acquire(r.Context(), classify(r)); errors.Is(err, errRequestShed) {
+			w.Header().Set("Retry-After", "1")
+			http.Error(w, err.Error(), http.StatusServiceUnavailable)
+			return
+		} else if err != nil {
+			return // the client is gone
+		}
+		defer q.release()
+		next.ServeHTTP(w, r)
+	})
+}
+
+// requestPriority classifies a request as critical if it's a getPayloadHeader or proposeBlindedBlock call that isn't
+// for a past slot, or a batch holding one. The JSON-RPC body is peeked at and left for the handler.
+func (m *RelayService) requestPriority(r *http.Request) int {
+	if r.Method != http.MethodPost || r.URL.Path != "/" {
+		return priorityBackground
+	}
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxRelayResponseSize))
+	r.Body = struct {
+		io.Reader
+		io.Closer
+	}{io.MultiReader(bytes.NewReader(body), r.Body), r.Body}
+	if err != nil {
+		return priorityBackground
+	}
+	if !isBatch(body) {
+		return m.callPriority(r.Context(), body)
+	}
+	var calls []json.RawMessage
+	if err := json.Unmarshal(body, &calls); err != nil {
+		return priorityBackground
+	}
+	for _, call := range calls {
+		if m.callPriority(r.Context(), call) == priorityCritical {
+			return priorityCritical
+		}
+	}
+	return priorityBackground
+}
+
+// callPriority classifies a single JSON-RPC call
+func (m *RelayService) callPriority(ctx context.Context, body []byte) int {
+	var req struct {
+		Method string            `json:"method"`
+		Params []json.RawMessage `json:"params"`
+	}
+	if err := json.Unmarshal(body, &req); err != nil || len(req.Params) == 0 {
+		return priorityBackground
+	}
+	if current, ok := deprecatedMethods[req.Method]; ok {
+		req.Method = current
+	}
+	if !proposalMethods[req.Method] {
+		return priorityBackground
+	}
+	if slot, ok := m.requestSlot(ctx, req.Params[0]); ok && slot < m.chain.CurrentSlot() {
+		return priorityBackground
+	}
+	return priorityCritical
+}
+
+// requestSlot returns the slot of the first param of a proposal method, a payload id or a signed blinded block
+func (m *RelayService) requestSlot(ctx context.Context, param json.RawMessage) (uint64, bool) {
+	var payloadID hexutil.Bytes
+	if json.Unmarshal(param, &payloadID) == nil {
+		attributes := m.store.GetPayloadAttributes(ctx, payloadID.String())
+		if attributes == nil {
+			return 0, false
+		}
+		return m.chain.SlotAt(uint64(attributes.Timestamp)), true
+	}
+	var block struct {
+		Message struct {
+			Slot uint64 `json:"slot,string"`
+		} `json:"message"`
+	}
+	if json.Unmarshal(param, &block) != nil {
+		return 0, false
+	}
+	return block.Message.Slot, true
+}
@@ -0,0 +1,146 @@
+package lib
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPriorityQueue(t *testing.T) {
+	q := newPriorityQueue(1)
+	require.Nil(t, q.acquire(context.Background(), priorityBackground))
+
+	var mu sync.Mutex
+	var admitted []string
+	var wg sync.WaitGroup
+	enqueue := func(name string, priority int) {
+		q.mu.Lock()
+		queued := len(q.waiting[priority])
+		q.mu.Unlock()
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			require.Nil(t, q.acquire(context.Background(), priority))
+			mu.Lock()
+			admitted = append(admitted, name)
+			mu.Unlock()
+			q.release()
+		}()
+		require.Eventually(t, func() bool {
+			q.mu.Lock()
+			defer q.mu.Unlock()
+			return len(q.waiting[priority]) == queued+1
+		}, time.Second, time.Millisecond)
+	}
+
+	enqueue("b1", priorityBackground)
+	enqueue("b2", priorityBackground)
+	for _, name := range []string{"c1", "c2", "c3", "c4", "c5"} {
+		enqueue(name, priorityCritical)
+	}
+	q.release()
+	wg.Wait()
+	require.Equal(t, []string{"c1", "c2", "c3", "c4", "b1", "c5", "b2"}, admitted)
+	require.Equal(t, 0, q.running)
+}
+
+func TestPriorityQueue_cancel(t *testing.T) {
+	q := newPriorityQueue(1)
+	require.Nil(t, q.acquire(context.Background(), priorityCritical))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	require.ErrorIs(t, q.acquire(ctx, priorityBackground), context.DeadlineExceeded)
+	require.Empty(t, q.waiting[priorityBackground])
+
+	q.release()
+	require.Equal(t, 0, q.running)
+}
+
+func TestPriorityQueue_shed(t *testing.T) {
+	q := newPriorityQueue(1)
+	q.shedQueued, q.shedWait = 1, 20*time.Millisecond
+	require.Nil(t, q.acquire(context.Background(), priorityCritical))
+
+	waited := make(chan error)
+	go func() { waited <- q.acquire(context.Background(), priorityBackground) }()
+	require.Eventually(t, func() bool {
+		q.mu.Lock()
+		defer q.mu.Unlock()
+		return len(q.waiting[priorityBackground]) == 1
+	}, time.Second, time.Millisecond)
+	require.ErrorIs(t, q.acquire(context.Background(), priorityBackground), errRequestShed, "too many background requests wait")
+	require.ErrorIs(t, <-waited, errRequestShed, "the background request waited too long")
+	require.Empty(t, q.waiting[priorityBackground])
+
+	// critical requests aren't shed
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	require.ErrorIs(t, q.acquire(ctx, priorityCritical), context.DeadlineExceeded)
+
+	q.release()
+	require.Equal(t, 0, q.running)
+
+	rr := httptest.NewRecorder()
+	require.Nil(t, q.acquire(context.Background(), priorityCritical))
+	q.handler(func(*http.Request) int { return priorityBackground }, http.NotFoundHandler()).ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	require.Equal(t, http.StatusServiceUnavailable, rr.Code)
+	require.Equal(t, "1", rr.Header().Get("Retry-After"))
+}
+
+func TestRelayService_requestPriority(t *testing.T) {
+	defer func() { now = time.Now }()
+	chain := *MainnetChainConfig
+	now = func() time.Time { return chain.SlotStartTime(100) }
+
+	store := NewStore()
+	store.SetPayloadAttributes(context.Background(), "0x01", &PayloadAttributesV1{Timestamp: hexutil.Uint64(chain.SlotStartTime(100).Unix())})
+	store.SetPayloadAttributes(context.Background(), "0x02", &PayloadAttributesV1{Timestamp: hexutil.Uint64(chain.SlotStartTime(99).Unix())})
+	service, err := newRelayService(WithRelayURLs("http://relay"), WithStore(store), WithLogger(testLog), WithChainConfig(&chain))
+	require.Nil(t, err)
+
+	tests := []struct {
+		name   string
+		method string
+		params []interface{}
+		want   int
+	}{
+		{"header of the current slot", "builder_getPayloadHeaderV1", []interface{}{"0x01"}, priorityCritical},
+		{"header of a past slot", "builder_getPayloadHeaderV1", []interface{}{"0x02"}, priorityBackground},
+		{"header of an unknown payload id", "builder_getPayloadHeaderV1", []interface{}{"0x03"}, priorityCritical},
+		{"teku alias", "builder_getHeaderV1", []interface{}{"0x01"}, priorityCritical},
+		{"block of the current slot", "builder_proposeBlindedBlockV1", []interface{}{map[string]interface{}{"message": map[string]interface{}{"slot": "100"}}}, priorityCritical},
+		{"block of a past slot", "builder_proposeBlindedBlockV1", []interface{}{map[string]interface{}{"message": map[string]interface{}{"slot": "99"}}}, priorityBackground},
+		{"registration", "engine_forkchoiceUpdatedV1", []interface{}{map[string]interface{}{}}, priorityBackground},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body, err := formatRequestBody(tt.method, tt.params)
+			require.Nil(t, err)
+			req := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(body))
+			require.Equal(t, tt.want, service.requestPriority(req))
+
+			rest, err := io.ReadAll(req.Body)
+			require.Nil(t, err)
+			require.Equal(t, body, rest, "the body is left for the handler")
+		})
+	}
+
+	batch := func(calls ...string) *http.Request {
+		return httptest.NewRequest(http.MethodPost, "/", strings.NewReader("["+strings.Join(calls, ",")+"]"))
+	}
+	registration := `{"method": "engine_forkchoiceUpdatedV1", "params": [{}]}`
+	require.Equal(t, priorityCritical, service.requestPriority(batch(registration, `{"method": "builder_getPayloadHeaderV1", "params": ["0x01"]}`)))
+	require.Equal(t, priorityBackground, service.requestPriority(batch(registration, `{"method": "builder_getPayloadHeaderV1", "params": ["0x02"]}`)))
+
+	require.Equal(t, priorityBackground, service.requestPriority(httptest.NewRequest(http.MethodGet, "/metrics", nil)))
+}
@@ -0,0 +1,86 @@
+package lib
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// relayProbeAlpha is the weight of the latest probe in the moving average of the probe latency of a relay
+const relayProbeAlpha = 0.2
+
+// relayProbeTimeoutFactor scales the probe latency of a relay to the timeout of its calls until they were observed,
+// since a relay takes longer to answer a header or payload request than a probe
+const relayProbeTimeoutFactor = 4
+
+// relayProbes keeps the round trip latency of lightweight relay_getCapabilitiesV1 probes sent between proposals, so the
+// reveal weighting and the relay timeouts have a data point before the first real request of a proposal
+type relayProbes struct {
+	mu        sync.RWMutex
+	latencies map[string]time.Duration // map[relay url]moving average of probe latency
+}
+
+func newRelayProbes() *relayProbes {
+	return &relayProbes{latencies: make(map[string]time.Duration)}
+}
+
+func (p *relayProbes) observe(relayURL string, latency time.Duration) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	average, ok := p.latencies[relayURL]
+	if ok {
+		latency = time.Duration(relayProbeAlpha*float64(latency) + (1-relayProbeAlpha)*float64(average))
+	}
+	p.latencies[relayURL] = latency
+	relayProbeLatencySeconds.WithLabelValues(relayURL).Set(latency.Seconds())
+}
+
+// latency returns the moving average of the probe latency of a relay, false if it wasn't probed successfully yet
+func (p *relayProbes) latency(relayURL string) (time.Duration, bool) {
+	if p == nil {
+		return 0, false
+	}
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	latency, ok := p.latencies[relayURL]
+	return latency, ok
+}
+
+// probeRelays sends a probe to each endpoint of the relays that aren't suspended. Any JSON-RPC answer counts, including
+// method not found. The probe latency of a relay is the one of its fastest endpoint.
+func (m *RelayService) probeRelays(ctx context.Context) {
+	var wg sync.WaitGroup
+	for _, url := range m.relays.all() {
+		if m.blacklist.isSuspended(url) {
+			continue
+		}
+		wg.Add(1)
+		go func(url string) {
+			defer wg.Done()
+			fastest := time.Duration(-1)
+			for _, endpoint := range m.endpoints.all(url) {
+				sentAt := now()
+				_, err := makeRequest(ctx, m.client, endpoint, methodRelayGetCapabilities, []interface{}{}, m.responseLimits.forMethod(methodRelayGetCapabilities))
+				if err != nil {
+					m.log.WithFields(Fields{"url": url, "endpoint": endpoint, "error": err}).Debug("relay probe failed")
+					m.endpoints.fail(endpoint)
+					continue
+				}
+				latency := now().Sub(sentAt)
+				m.endpoints.observe(endpoint, latency)
+				if fastest < 0 || latency < fastest {
+					fastest = latency
+				}
+			}
+			if fastest >= 0 {
+				m.probes.observe(url, fastest)
+			}
+		}(url)
+	}
+	wg.Wait()
+}
+
+// startRelayProbes probes the relays right away, and then every interval until ctx is done
+func (m *RelayService) startRelayProbes(ctx context.Context, interval time.Duration) {
+	runLoop(ctx, m.log, "probes", interval, true, m.probeRelays)
+}
@@ -0,0 +1,49 @@
+package lib
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRelayProbes_observe(t *testing.T) {
+	probes := newRelayProbes()
+	_, ok := probes.latency("https://a.example.com")
+	require.False(t, ok)
+
+	probes.observe("https://a.example.com", time.Second)
+	probes.observe("https://a.example.com", 2*time.Second)
+	latency, ok := probes.latency("https://a.example.com")
+	require.True(t, ok)
+	require.Equal(t, 1200*time.Millisecond, latency)
+
+	var disabled *relayProbes
+	_, ok = disabled.latency("https://a.example.com")
+	require.False(t, ok)
+}
+
+func TestRelayService_probeRelays(t *testing.T) {
+	relay := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"jsonrpc": "2.0", "id": "1", "error": {"code": -32601, "message": "method not found"}}`))
+	}))
+	defer relay.Close()
+	down := httptest.NewServer(http.NotFoundHandler())
+	down.Close()
+
+	service, err := newRelayService(WithRelayURLs(relay.URL, down.URL), WithStore(NewStore()), WithLogger(testLog),
+		WithRelayProbes(time.Minute), WithAdaptiveRelayTimeouts(time.Nanosecond, time.Minute))
+	require.Nil(t, err)
+	service.probeRelays(context.Background())
+
+	latency, ok := service.probes.latency(relay.URL)
+	require.True(t, ok, "relays not supporting the probe method still answer it")
+	_, ok = service.probes.latency(down.URL)
+	require.False(t, ok)
+
+	require.Equal(t, latency*relayProbeTimeoutFactor, service.timeouts.timeout(relay.URL, methodRelayGetHeader), "timeouts start from the probe latency")
+	require.Equal(t, time.Minute, service.timeouts.timeout(down.URL, methodRelayGetHeader))
+}
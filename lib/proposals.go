@@ -0,0 +1,169 @@
+package lib
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+var maxProposals = 1000
+
+// ProposalState is the stage a proposal reached
+type ProposalState string
+
+// States of a proposal, in the order a proposal goes through them. Stages without an event are skipped, for example
+// when a repeated forkchoiceUpdated call wasn't forwarded, or deliveries aren't verified.
+const (
+	// ProposalAttributesReceived means the consensus client sent payload attributes for the slot
+	ProposalAttributesReceived ProposalState = "attributesReceived"
+	// ProposalBidsCollected means at least one relay returned a valid header for the slot
+	ProposalBidsCollected ProposalState = "bidsCollected"
+	// ProposalHeaderServed means a header was returned to the consensus client
+	ProposalHeaderServed ProposalState = "headerServed"
+	// ProposalBlockSigned means the consensus client sent the signed blinded block
+	ProposalBlockSigned ProposalState = "blockSigned"
+	// ProposalPayloadRevealed means the payload was returned to the consensus client
+	ProposalPayloadRevealed ProposalState = "payloadRevealed"
+	// ProposalVerified means the delivery was checked against the finalized chain, see VerificationStatus
+	ProposalVerified ProposalState = "verified"
+)
+
+var proposalStateOrder = map[ProposalState]int{
+	ProposalAttributesReceived: 1,
+	ProposalBidsCollected:      2,
+	ProposalHeaderServed:       3,
+	ProposalBlockSigned:        4,
+	ProposalPayloadRevealed:    5,
+	ProposalVerified:           6,
+}
+
+// proposalStateOf is the state an event moves a proposal to
+var proposalStateOf = map[EventKind]ProposalState{
+	EventAttributesReceived: ProposalAttributesReceived,
+	EventBidReceived:        ProposalBidsCollected,
+	EventBidSelected:        ProposalHeaderServed,
+	EventBlockSigned:        ProposalBlockSigned,
+	EventPayloadRevealed:    ProposalPayloadRevealed,
+	EventDeliveryVerified:   ProposalVerified,
+}
+
+// ProposalTransition is the time a proposal reached a state
+type ProposalTransition struct {
+	State ProposalState `json:"state"`
+	Time  time.Time     `json:"time"`
+}
+
+// Proposal is the progress of the proposal of a slot, so a proposal that went wrong shows the last stage it reached
+type Proposal struct {
+	Slot        uint64               `json:"slot,string"`
+	State       ProposalState        `json:"state"`
+	Transitions []ProposalTransition `json:"transitions"`
+	Bids        int                  `json:"bids"`
+	// RelayURL and BlockHash are of the header served, or of the payload revealed if it differs
+	RelayURL           string       `json:"relayUrl,omitempty"`
+	BlockHash          *common.Hash `json:"blockHash,omitempty"`
+	VerificationStatus string       `json:"verificationStatus,omitempty"`
+}
+
+// proposalLog follows the events of the most recent slots through the proposal states. States only move forward: a
+// late bid doesn't take a proposal back to bidsCollected.
+type proposalLog struct {
+	mu        sync.RWMutex
+	proposals map[uint64]*Proposal
+	slots     []uint64 // in the order they were first seen, to evict the oldest
+}
+
+func newProposalLog() *proposalLog {
+	return &proposalLog{proposals: make(map[uint64]*Proposal)}
+}
+
+// onEvent is the EventSubscriber of the proposal log
+func (l *proposalLog) onEvent(_ context.Context, event *Event) {
+	state, ok := proposalStateOf[event.Kind]
+	if !ok || event.Slot == 0 {
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	proposal := l.proposals[event.Slot]
+	if proposal == nil {
+		proposal = &Proposal{Slot: event.Slot, Transitions: []ProposalTransition{}}
+		l.proposals[event.Slot] = proposal
+		l.slots = append(l.slots, event.Slot)
+		if len(l.slots) > maxProposals {
+			delete(l.proposals, l.slots[0])
+			l.slots = l.slots[1:]
+		}
+	}
+
+	switch event.Kind {
+	case EventBidReceived:
+		proposal.Bids++
+	case EventBidSelected, EventPayloadRevealed:
+		proposal.RelayURL, proposal.BlockHash = event.RelayURL, event.BlockHash
+	case EventBlockSigned:
+		if proposal.BlockHash == nil {
+			proposal.BlockHash = event.BlockHash
+		}
+	case EventDeliveryVerified:
+		proposal.VerificationStatus, _ = event.Fields["status"].(string)
+	}
+	if proposalStateOrder[state] > proposalStateOrder[proposal.State] {
+		proposal.State = state
+		proposal.Transitions = append(proposal.Transitions, ProposalTransition{State: state, Time: event.Time})
+	}
+}
+
+// get returns a copy of the proposal of slot, or nil if none is known
+func (l *proposalLog) get(slot uint64) *Proposal {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	proposal, ok := l.proposals[slot]
+	if !ok {
+		return nil
+	}
+	proposalCopy := *proposal
+	proposalCopy.Transitions = append([]ProposalTransition{}, proposal.Transitions...)
+	return &proposalCopy
+}
+
+// all returns copies of all proposals, in the order their slots were first seen
+func (l *proposalLog) all() []Proposal {
+	l.mu.RLock()
+	slots := append([]uint64{}, l.slots...)
+	l.mu.RUnlock()
+
+	proposals := []Proposal{}
+	for _, slot := range slots {
+		if proposal := l.get(slot); proposal != nil {
+			proposals = append(proposals, *proposal)
+		}
+	}
+	return proposals
+}
+
+func (m *RelayService) handleProposals(w http.ResponseWriter, r *http.Request) {
+	value := r.URL.Query().Get("slot")
+	if value == "" {
+		respondJSON(w, http.StatusOK, m.proposals.all())
+		return
+	}
+	slot, err := strconv.ParseUint(value, 10, 64)
+	if err != nil {
+		respondJSON(w, http.StatusBadRequest, keymanagerError{"invalid slot " + strconv.Quote(value)})
+		return
+	}
+	proposal := m.proposals.get(slot)
+	if proposal == nil {
+		respondJSON(w, http.StatusNotFound, keymanagerError{"no proposal known for slot " + value})
+		return
+	}
+	respondJSON(w, http.StatusOK, proposal)
+}
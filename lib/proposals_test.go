@@ -0,0 +1,91 @@
+package lib
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProposalLog(t *testing.T) {
+	bus := newEventBus()
+	proposals := newProposalLog()
+	bus.subscribe(proposals.onEvent)
+	blockHash := common.HexToHash("0x01")
+	publish := func(kind EventKind, slot uint64) {
+		bus.publish(context.Background(), &Event{Kind: kind, Slot: slot, RelayURL: "http://relay", BlockHash: &blockHash})
+	}
+
+	publish(EventAttributesReceived, 10)
+	publish(EventBidReceived, 10)
+	publish(EventBidReceived, 10)
+	publish(EventBidSelected, 10)
+	publish(EventBidReceived, 10) // late bids don't move the proposal back
+	publish(EventRelayFault, 10)
+	publish(EventAttributesReceived, 11)
+
+	proposal := proposals.get(10)
+	require.NotNil(t, proposal)
+	require.Equal(t, ProposalHeaderServed, proposal.State)
+	require.Equal(t, 3, proposal.Bids)
+	require.Equal(t, "http://relay", proposal.RelayURL)
+	require.Equal(t, blockHash, *proposal.BlockHash)
+	states := make([]ProposalState, len(proposal.Transitions))
+	for i, transition := range proposal.Transitions {
+		states[i] = transition.State
+	}
+	require.Equal(t, []ProposalState{ProposalAttributesReceived, ProposalBidsCollected, ProposalHeaderServed}, states)
+
+	bus.publish(context.Background(), &Event{Kind: EventDeliveryVerified, Slot: 10, Fields: map[string]interface{}{"status": DeliveryIncluded}})
+	proposal = proposals.get(10)
+	require.Equal(t, ProposalVerified, proposal.State)
+	require.Equal(t, DeliveryIncluded, proposal.VerificationStatus)
+	require.Len(t, proposal.Transitions, 4, "skipped states aren't recorded")
+
+	require.Equal(t, ProposalAttributesReceived, proposals.get(11).State)
+	require.Nil(t, proposals.get(12))
+	require.Len(t, proposals.all(), 2)
+
+	defer func(max int) { maxProposals = max }(maxProposals)
+	maxProposals = 2
+	publish(EventAttributesReceived, 12)
+	require.Nil(t, proposals.get(10), "the oldest slot is evicted")
+	require.Len(t, proposals.all(), 2)
+}
+
+func TestRelayService_handleProposals(t *testing.T) {
+	defer func() { now = time.Now }()
+	now = func() time.Time { return time.Unix(1000, 0).UTC() }
+
+	service, err := newRelayService(WithRelayURLs("http://relay"), WithLogger(testLog))
+	require.Nil(t, err)
+	blockHash := common.HexToHash("0x01")
+	service.events.publish(context.Background(), &Event{Kind: EventBlockSigned, Slot: 5, BlockHash: &blockHash})
+	server := httptest.NewServer(http.HandlerFunc(service.handleProposals))
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "?slot=5")
+	require.Nil(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	proposal := new(Proposal)
+	require.Nil(t, json.NewDecoder(resp.Body).Decode(proposal))
+	require.Equal(t, &Proposal{
+		Slot:        5,
+		State:       ProposalBlockSigned,
+		Transitions: []ProposalTransition{{State: ProposalBlockSigned, Time: time.Unix(1000, 0).UTC()}},
+		BlockHash:   &blockHash,
+	}, proposal)
+
+	for query, code := range map[string]int{"": http.StatusOK, "?slot=6": http.StatusNotFound, "?slot=x": http.StatusBadRequest} {
+		resp, err := http.Get(server.URL + query)
+		require.Nil(t, err)
+		resp.Body.Close()
+		require.Equal(t, code, resp.StatusCode, query)
+	}
+}
@@ -0,0 +1,195 @@
+package lib
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"golang.org/x/time/rate"
+)
+
+// defaultRateLimitMethod is the key of MethodRateLimits that applies to the methods without a limit of their own
+const defaultRateLimitMethod = "*"
+
+var proposerRequestsRejectedTotal = metricsFactory.NewCounterVec(prometheus.CounterOpts{
+	Name: "mevboost_proposer_requests_rejected_total",
+	Help: "Requests to the proposer-facing API rejected by reason: unauthorized, forbidden or rate_limited",
+}, []string{"reason"})
+
+// ProposerAuth restricts the proposer-facing API, the JSON-RPC endpoint with its WebSocket and the builder REST routes,
+// to clients presenting one of Tokens as bearer token, and connecting from one of the Allowlist networks. An empty
+// field isn't checked.
+type ProposerAuth struct {
+	Tokens    []string
+	Allowlist []*net.IPNet
+}
+
+// ParseAllowlist parses IP addresses and CIDR networks like "127.0.0.1" or "10.0.0.0/8"
+func ParseAllowlist(entries []string) ([]*net.IPNet, error) {
+	networks := make([]*net.IPNet, 0, len(entries))
+	for _, entry := range entries {
+		if !strings.Contains(entry, "/") {
+			ip := net.ParseIP(entry)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid IP address %q", entry)
+			}
+			bits := 8 * net.IPv6len
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 8*net.IPv4len
+			}
+			networks = append(networks, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, network, err := net.ParseCIDR(entry)
+		if err != nil {
+			return nil, fmt.Errorf("invalid network %q: %w", entry, err)
+		}
+		networks = append(networks, network)
+	}
+	return networks, nil
+}
+
+// allows reports whether the remote address of a request is in the allowlist. Forwarded headers aren't trusted, behind
+// a proxy the address of the proxy has to be allowed.
+func (a *ProposerAuth) allows(remoteAddr string) bool {
+	if len(a.Allowlist) == 0 {
+		return true
+	}
+	host, _, err := net.SplitHostPort(remoteAddr)
+	if err != nil {
+		host = remoteAddr
+	}
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return false
+	}
+	for _, network := range a.Allowlist {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// authenticates reports whether the request has one of the tokens as bearer token
+func (a *ProposerAuth) authenticates(r *http.Request) (present, valid bool) {
+	if len(a.Tokens) == 0 {
+		return true, true
+	}
+	auth := r.Header.Get("Authorization")
+	if !strings.HasPrefix(auth, "Bearer ") {
+		return false, false
+	}
+	return true, matchesToken(strings.TrimPrefix(auth, "Bearer "), a.Tokens)
+}
+
+// handler rejects requests from addresses outside the allowlist with 403, and requests without a valid token with 401
+// if there is none and 403 otherwise
+func (a *ProposerAuth) handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !a.allows(r.RemoteAddr) {
+			proposerRequestsRejectedTotal.WithLabelValues("forbidden").Inc()
+			respondJSON(w, http.StatusForbidden, keymanagerError{"address not allowed"})
+			return
+		}
+		present, valid := a.authenticates(r)
+		switch {
+		case !present:
+			proposerRequestsRejectedTotal.WithLabelValues("unauthorized").Inc()
+			respondJSON(w, http.StatusUnauthorized, keymanagerError{"missing bearer token"})
+			return
+		case !valid:
+			proposerRequestsRejectedTotal.WithLabelValues("forbidden").Inc()
+			respondJSON(w, http.StatusForbidden, keymanagerError{"invalid bearer token"})
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// MethodRateLimit is a token bucket of JSON-RPC calls: PerSecond calls on average, in bursts of up to Burst calls
+type MethodRateLimit struct {
+	PerSecond float64
+	Burst     int
+}
+
+// ParseMethodRateLimits parses rate limits of JSON-RPC methods like "builder_getPayloadHeaderV1=2:4,*=50:100", as
+// method=perSecond:burst. The method * limits all methods without a limit of their own, together. The empty string
+// sets no limits.
+func ParseMethodRateLimits(value string) (map[string]MethodRateLimit, error) {
+	limits := make(map[string]MethodRateLimit)
+	for _, entry := range strings.Split(value, ",") {
+		if entry = strings.TrimSpace(entry); entry == "" {
+			continue
+		}
+		method, limit, ok := strings.Cut(entry, "=")
+		perSecond, burst, hasBurst := strings.Cut(limit, ":")
+		if !ok || !hasBurst || strings.TrimSpace(method) == "" {
+			return nil, fmt.Errorf("invalid method rate limit %q, expected method=perSecond:burst", entry)
+		}
+		var parsed MethodRateLimit
+		var err error
+		if parsed.PerSecond, err = strconv.ParseFloat(strings.TrimSpace(perSecond), 64); err != nil || parsed.PerSecond <= 0 {
+			return nil, fmt.Errorf("invalid calls per second of %s: %q", method, perSecond)
+		}
+		if parsed.Burst, err = strconv.Atoi(strings.TrimSpace(burst)); err != nil || parsed.Burst < 1 {
+			return nil, fmt.Errorf("invalid burst of %s: %q", method, burst)
+		}
+		limits[strings.TrimSpace(method)] = parsed
+	}
+	return limits, nil
+}
+
+// methodRateLimiter limits the calls of each JSON-RPC method with a token bucket. Deprecated method names share the
+// bucket of the current method.
+type methodRateLimiter map[string]*rate.Limiter
+
+func newMethodRateLimiter(limits map[string]MethodRateLimit) methodRateLimiter {
+	limiters := make(methodRateLimiter, len(limits))
+	for method, limit := range limits {
+		limiters[method] = rate.NewLimiter(rate.Limit(limit.PerSecond), limit.Burst)
+	}
+	return limiters
+}
+
+// handler answers single calls over the rate limit of their method with ErrorCodeRateLimited and status 429.
+// It runs after batchHandler, so each call of a batch is limited on its own.
+func (l methodRateLimiter) handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(io.LimitReader(r.Body, maxRelayResponseSize))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		var req struct {
+			ID     json.RawMessage `json:"id"`
+			Method string          `json:"method"`
+		}
+		if err := json.Unmarshal(body, &req); err != nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+		method := req.Method
+		if current, ok := deprecatedMethods[method]; ok {
+			method = current
+		}
+		limiter, ok := l[method]
+		if !ok {
+			limiter = l[defaultRateLimitMethod]
+		}
+		if limiter != nil && !limiter.Allow() {
+			proposerRequestsRejectedTotal.WithLabelValues("rate_limited").Inc()
+			w.Header().Set("Retry-After", "1")
+			respondJSON(w, http.StatusTooManyRequests, batchErrorResponse(req.ID, ErrorCodeRateLimited, fmt.Sprintf("rate limit of %s exceeded", method)))
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,130 @@
+package lib
+
+import (
+	"context"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseAllowlist(t *testing.T) {
+	allowlist, err := ParseAllowlist([]string{"127.0.0.1", "10.0.0.0/8", "::1"})
+	require.Nil(t, err)
+	auth := &ProposerAuth{Allowlist: allowlist}
+	require.True(t, auth.allows("127.0.0.1:4000"))
+	require.True(t, auth.allows("10.1.2.3:4000"))
+	require.True(t, auth.allows("[::1]:4000"))
+	require.False(t, auth.allows("192.0.2.1:4000"))
+	require.False(t, auth.allows("not an address"))
+
+	_, err = ParseAllowlist([]string{"localhost"})
+	require.Error(t, err)
+	_, err = ParseAllowlist([]string{"10.0.0.0/33"})
+	require.Error(t, err)
+}
+
+func TestParseMethodRateLimits(t *testing.T) {
+	limits, err := ParseMethodRateLimits("builder_getPayloadHeaderV1=2:4, *=0.5:10")
+	require.Nil(t, err)
+	require.Equal(t, map[string]MethodRateLimit{
+		"builder_getPayloadHeaderV1": {PerSecond: 2, Burst: 4},
+		"*":                          {PerSecond: 0.5, Burst: 10},
+	}, limits)
+
+	limits, err = ParseMethodRateLimits("")
+	require.Nil(t, err)
+	require.Empty(t, limits)
+
+	for _, invalid := range []string{"builder_getPayloadHeaderV1=2", "=2:4", "builder_getPayloadHeaderV1=0:4", "builder_getPayloadHeaderV1=2:0", "builder_getPayloadHeaderV1=x:4"} {
+		_, err := ParseMethodRateLimits(invalid)
+		require.Error(t, err, invalid)
+	}
+}
+
+func TestRouter_ProposerAuth(t *testing.T) {
+	relay := newHeaderRelay(t, ExecutionPayloadWithTxRootV1{BlockHash: common.HexToHash("0x01"), BaseFeePerGas: big.NewInt(7), FeeRecipientDiff: big.NewInt(1)})
+	defer relay.Close()
+	store := NewStore()
+	store.SetForkchoiceResponse(context.Background(), "0x01", relay.URL, "0x01")
+	allowlist, err := ParseAllowlist([]string{"192.0.2.0/24"})
+	require.Nil(t, err)
+	router, err := NewRouter(context.Background(), WithRelayURLs(relay.URL), WithStore(store), WithLogger(testLog), WithCapabilityCheckInterval(0),
+		WithProposerAuth(ProposerAuth{Tokens: []string{"secret"}, Allowlist: allowlist}))
+	require.Nil(t, err)
+
+	call := func(remoteAddr, auth string) int {
+		req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"jsonrpc":"2.0","id":1,"method":"builder_getPayloadHeaderV1","params":["0x01"]}`))
+		req.Header.Set("Content-Type", "application/json")
+		req.RemoteAddr = remoteAddr
+		if auth != "" {
+			req.Header.Set("Authorization", auth)
+		}
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		return rr.Code
+	}
+	require.Equal(t, http.StatusOK, call("192.0.2.1:4000", "Bearer secret"))
+	require.Equal(t, http.StatusUnauthorized, call("192.0.2.1:4000", ""))
+	require.Equal(t, http.StatusForbidden, call("192.0.2.1:4000", "Bearer guess"))
+	require.Equal(t, http.StatusForbidden, call("198.51.100.1:4000", "Bearer secret"), "address outside the allowlist")
+
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, pathLiveness, nil))
+	require.Equal(t, http.StatusOK, rr.Code, "only the proposer-facing API is restricted")
+
+	_, err = NewRouter(context.Background(), WithRelayURLs("http://relay"), WithLogger(testLog), WithCapabilityCheckInterval(0),
+		WithProposerAuth(ProposerAuth{Tokens: []string{"secret"}}), WithJWTAuthentication(make([]byte, 32)))
+	require.Error(t, err, "bearer tokens conflict with JWTs")
+}
+
+func TestRouter_MethodRateLimits(t *testing.T) {
+	relay := newHeaderRelay(t, ExecutionPayloadWithTxRootV1{BlockHash: common.HexToHash("0x01"), BaseFeePerGas: big.NewInt(7), FeeRecipientDiff: big.NewInt(1)})
+	defer relay.Close()
+	store := NewStore()
+	store.SetForkchoiceResponse(context.Background(), "0x01", relay.URL, "0x01")
+	router, err := NewRouter(context.Background(), WithRelayURLs(relay.URL), WithStore(store), WithLogger(testLog), WithCapabilityCheckInterval(0),
+		WithMethodRateLimits(map[string]MethodRateLimit{"builder_getPayloadHeaderV1": {PerSecond: 0.001, Burst: 2}}))
+	require.Nil(t, err)
+
+	call := func(body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		return rr
+	}
+	rr := call(`{"jsonrpc":"2.0","id":1,"method":"builder_getPayloadHeaderV1","params":["0x01"]}`)
+	require.Equal(t, http.StatusOK, rr.Code)
+
+	rr = call(`[
+		{"jsonrpc":"2.0","id":2,"method":"engine_getPayloadHeaderV1","params":["0x01"]},
+		{"jsonrpc":"2.0","id":3,"method":"builder_getPayloadHeaderV1","params":["0x01"]}
+	]`)
+	require.Equal(t, http.StatusOK, rr.Code)
+	var responses []struct {
+		ID    json.RawMessage `json:"id"`
+		Error *rpcError       `json:"error"`
+	}
+	require.Nil(t, json.Unmarshal(rr.Body.Bytes(), &responses), rr.Body.String())
+	require.Len(t, responses, 2)
+	limited := 0
+	for _, response := range responses {
+		if response.Error != nil {
+			require.Equal(t, ErrorCodeRateLimited, response.Error.Code)
+			limited++
+		}
+	}
+	require.Equal(t, 1, limited, "the deprecated method shares the bucket, the burst of 2 is used up by one call of the batch")
+
+	rr = call(`{"jsonrpc":"2.0","id":4,"method":"builder_getPayloadHeaderV1","params":["0x01"]}`)
+	require.Equal(t, http.StatusTooManyRequests, rr.Code)
+	require.Equal(t, "1", rr.Header().Get("Retry-After"))
+	rr = call(`{"jsonrpc":"2.0","id":5,"method":"builder_unknownV1","params":[]}`)
+	require.NotEqual(t, http.StatusTooManyRequests, rr.Code, "methods without a limit aren't limited")
+}
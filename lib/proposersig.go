@@ -0,0 +1,120 @@
+package lib
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+// proposerSignatures verifies the proposer signature of blinded blocks, looking up proposer pubkeys on the beacon node
+type proposerSignatures struct {
+	beacon   *BeaconClient
+	chain    *ChainConfig
+	failOpen bool // let blocks through if the proposer pubkey or signing domain can't be looked up
+
+	mu                    sync.Mutex
+	genesisValidatorsRoot *[32]byte
+	pubkeys               map[uint64][]byte // by validator index, the pubkey of an index never changes
+}
+
+func newProposerSignatures(beacon *BeaconClient, chain *ChainConfig, failOpen bool) *proposerSignatures {
+	return &proposerSignatures{
+		beacon:   beacon,
+		chain:    chain,
+		failOpen: failOpen,
+		pubkeys:  make(map[uint64][]byte),
+	}
+}
+
+// verify checks the signature of block against the key of its proposer. Blocks whose proposer pubkey or signing domain
+// can't be looked up are rejected, unless failOpen is set and they are let through for the relays to check.
+func (s *proposerSignatures) verify(ctx context.Context, block *SignedBlindedBeaconBlock, log Logger) error {
+	if s == nil {
+		return nil
+	}
+
+	slot, proposerIndex := block.Message.Slot, block.Message.ProposerIndex
+	pubkey, err := s.pubkey(ctx, proposerIndex)
+	if err != nil {
+		return s.unverifiable(log.WithField("proposerIndex", proposerIndex), fmt.Errorf("could not look up pubkey of proposer %d: %w", proposerIndex, err))
+	}
+	genesisValidatorsRoot, err := s.getGenesisValidatorsRoot(ctx)
+	if err != nil {
+		return s.unverifiable(log, fmt.Errorf("could not look up genesis validators root: %w", err))
+	}
+
+	root, err := block.Message.HashTreeRoot()
+	if err != nil {
+		return newMethodError(ErrInvalidSignature, "could not compute block root: %v", err)
+	}
+	domain := ComputeDomain(DomainTypeBeaconProposer, s.chain.ForkVersion(slot/s.chain.SlotsPerEpoch), genesisValidatorsRoot)
+	ok, err := VerifySignature(pubkey, ComputeSigningRoot(root, domain), block.Signature[:])
+	if err != nil {
+		return newMethodError(ErrInvalidSignature, "%v", err)
+	}
+	if !ok {
+		return newMethodError(ErrInvalidSignature, "signature of block at slot %d doesn't match proposer %d", slot, proposerIndex)
+	}
+	return nil
+}
+
+// unverifiable rejects a block whose signature can't be checked, or lets it through if failOpen is set
+func (s *proposerSignatures) unverifiable(log Logger, err error) error {
+	if s.failOpen {
+		log.WithError(err).Warn("skipping signature verification")
+		return nil
+	}
+	log.WithError(err).Warn("rejecting block whose signature can't be verified")
+	return newMethodError(ErrInvalidSignature, "%v", err)
+}
+
+func (s *proposerSignatures) pubkey(ctx context.Context, index uint64) ([]byte, error) {
+	s.mu.Lock()
+	pubkey, ok := s.pubkeys[index]
+	s.mu.Unlock()
+	if ok {
+		return pubkey, nil
+	}
+
+	validator, err := s.beacon.Validator(ctx, strconv.FormatUint(index, 10))
+	if err != nil {
+		return nil, err
+	}
+	pubkey, err = hexutil.Decode(validator.Validator.Pubkey)
+	if err != nil {
+		return nil, fmt.Errorf("invalid pubkey of validator %d: %w", index, err)
+	}
+
+	s.mu.Lock()
+	s.pubkeys[index] = pubkey
+	s.mu.Unlock()
+	return pubkey, nil
+}
+
+func (s *proposerSignatures) getGenesisValidatorsRoot(ctx context.Context) ([32]byte, error) {
+	s.mu.Lock()
+	cached := s.genesisValidatorsRoot
+	s.mu.Unlock()
+	if cached != nil {
+		return *cached, nil
+	}
+
+	genesis, err := s.beacon.Genesis(ctx)
+	if err != nil {
+		return [32]byte{}, err
+	}
+	decoded, err := hexutil.Decode(genesis.GenesisValidatorsRoot)
+	if err != nil || len(decoded) != 32 {
+		return [32]byte{}, fmt.Errorf("invalid genesis validators root %q", genesis.GenesisValidatorsRoot)
+	}
+	root := common.BytesToHash(decoded)
+
+	s.mu.Lock()
+	s.genesisValidatorsRoot = (*[32]byte)(&root)
+	s.mu.Unlock()
+	return root, nil
+}
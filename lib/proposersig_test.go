@@ -0,0 +1,146 @@
+package lib
+
+import (
+	"context"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/stretchr/testify/require"
+)
+
+func testBlindedBlockBody(header *ExecutionPayloadHeaderV1) *BlindedBeaconBlockBody {
+	var signature BLSSignature
+	copy(signature[:], strings.Repeat("\x11", 96))
+	data := AttestationData{Slot: 63, Index: 1, BeaconBlockRoot: common.HexToHash("0x03"), Source: Checkpoint{Epoch: 6}, Target: Checkpoint{Epoch: 7, Root: common.HexToHash("0x04")}}
+	proof := make([]common.Hash, depositProofLength)
+	return &BlindedBeaconBlockBody{
+		RandaoReveal: signature,
+		Eth1Data:     Eth1Data{DepositRoot: common.HexToHash("0x05"), DepositCount: 10, BlockHash: common.HexToHash("0x06")},
+		Graffiti:     common.HexToHash("0x6d65762d626f6f7374"),
+		ProposerSlashings: []ProposerSlashing{{
+			SignedHeader1: SignedBeaconBlockHeader{Message: BeaconBlockHeader{Slot: 60, ProposerIndex: 3}, Signature: signature},
+			SignedHeader2: SignedBeaconBlockHeader{Message: BeaconBlockHeader{Slot: 60, ProposerIndex: 3, StateRoot: common.HexToHash("0x07")}, Signature: signature},
+		}},
+		AttesterSlashings: []AttesterSlashing{{
+			Attestation1: IndexedAttestation{AttestingIndices: QuotedUint64s{1, 2, 3, 4, 5}, Data: data, Signature: signature},
+			Attestation2: IndexedAttestation{AttestingIndices: QuotedUint64s{2}, Data: data, Signature: signature},
+		}},
+		Attestations:           []Attestation{{AggregationBits: hexutil.Bytes{0xff, 0x05}, Data: data, Signature: signature}},
+		Deposits:               []Deposit{{Proof: proof, Data: DepositData{Amount: 32e9, Signature: signature}}},
+		VoluntaryExits:         []SignedVoluntaryExit{{Message: VoluntaryExit{Epoch: 5, ValidatorIndex: 9}, Signature: signature}},
+		SyncAggregate:          SyncAggregate{SyncCommitteeBits: make(hexutil.Bytes, syncCommitteeSize/8), SyncCommitteeSignature: signature},
+		ExecutionPayloadHeader: header,
+	}
+}
+
+func TestBlindedBeaconBlockBody_JSON(t *testing.T) {
+	body := testBlindedBlockBody(testHeader())
+	encoded, err := json.Marshal(body)
+	require.Nil(t, err)
+	require.Contains(t, string(encoded), `"attesting_indices":["1","2","3","4","5"]`)
+	require.Contains(t, string(encoded), `"deposit_count":"10"`)
+
+	decoded := new(BlindedBeaconBlockBody)
+	require.Nil(t, json.Unmarshal(encoded, decoded))
+	expectedRoot, err := body.HashTreeRoot()
+	require.Nil(t, err)
+	root, err := decoded.HashTreeRoot()
+	require.Nil(t, err)
+	require.Equal(t, expectedRoot, root)
+
+	decoded.Attestations[0].AggregationBits = hexutil.Bytes{0xff, 0x00}
+	_, err = decoded.HashTreeRoot()
+	require.Error(t, err, "bitlist without length bit")
+}
+
+func TestRelayService_ProposerSignature(t *testing.T) {
+	payload := &ExecutionPayloadWithTxRootV1{BlockHash: common.HexToHash("0x01"), BaseFeePerGas: big.NewInt(1), FeeRecipientDiff: big.NewInt(1)}
+	var relayCalls int32
+	relay := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&relayCalls, 1)
+		resp, err := formatResponse(payload)
+		require.Nil(t, err)
+		w.Write(resp)
+	}))
+	defer relay.Close()
+
+	secretKey := big.NewInt(1234567)
+	pubkey, _ := blsSign(secretKey, [32]byte{})
+	genesisValidatorsRoot := common.HexToHash("0x4b363db94e286120d76eb905340fdd4e54bfe9f06bf33ff6cf5ad27f511bfe95")
+	beaconNode := newMockBeaconNode(t, map[string]string{
+		"/eth/v1/beacon/genesis":                  `{"data":{"genesis_time":"1606824023","genesis_validators_root":"` + genesisValidatorsRoot.Hex() + `","genesis_fork_version":"0x00000000"}}`,
+		"/eth/v1/beacon/states/head/validators/7": `{"data":{"index":"7","status":"active_ongoing","validator":{"pubkey":"` + hexutil.Encode(pubkey) + `"}}}`,
+	})
+	defer beaconNode.Close()
+
+	chain := &ChainConfig{SecondsPerSlot: 12, SlotsPerEpoch: 32, GenesisForkVersion: [4]byte{0x00, 0x00, 0x00, 0x01}, BellatrixForkVersion: [4]byte{0x02, 0x00, 0x00, 0x01}, BellatrixForkEpoch: 2}
+	signedBlock := func(proposerIndex uint64, modify func(body *BlindedBeaconBlockBody)) *SignedBlindedBeaconBlock {
+		body := testBlindedBlockBody(payload.Header())
+		block := &BlindedBeaconBlock{
+			Slot:          64,
+			ProposerIndex: proposerIndex,
+			ParentRoot:    common.HexToHash("0x08"),
+			StateRoot:     common.HexToHash("0x09"),
+			Body:          body,
+		}
+		root, err := block.HashTreeRoot()
+		require.Nil(t, err)
+		domain := ComputeDomain(DomainTypeBeaconProposer, chain.BellatrixForkVersion, genesisValidatorsRoot)
+		_, signature := blsSign(secretKey, ComputeSigningRoot(root, domain))
+
+		signed := &SignedBlindedBeaconBlock{Message: block}
+		copy(signed.Signature[:], signature)
+		if modify != nil {
+			modify(body)
+		}
+		return signed
+	}
+
+	tests := []struct {
+		name      string
+		block     *SignedBlindedBeaconBlock
+		wantErr   error
+		wantRelay bool
+		failOpen  bool
+	}{
+		{"valid signature", signedBlock(7, nil), nil, true, false},
+		{"modified body", signedBlock(7, func(body *BlindedBeaconBlockBody) { body.Graffiti[0] = 1 }), ErrInvalidSignature, false, false},
+		{"modified attestation", signedBlock(7, func(body *BlindedBeaconBlockBody) { body.Attestations[0].AggregationBits[0] = 0x7f }), ErrInvalidSignature, false, false},
+		{"unknown proposer is rejected", signedBlock(8, nil), ErrInvalidSignature, false, false},
+		{"unknown proposer is let through with fail open", signedBlock(8, nil), nil, true, true},
+		{"modified body is rejected with fail open", signedBlock(7, func(body *BlindedBeaconBlockBody) { body.Graffiti[0] = 1 }), ErrInvalidSignature, false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			atomic.StoreInt32(&relayCalls, 0)
+			opts := []Option{
+				WithRelayURLs(relay.URL),
+				WithLogger(testLog),
+				WithChainConfig(chain),
+				WithProposerSignatureVerification(NewBeaconClient(beaconNode.URL)),
+			}
+			if tt.failOpen {
+				opts = append(opts, WithProposerSignatureFailOpen())
+			}
+			service, err := newRelayService(opts...)
+			require.Nil(t, err)
+
+			err = service.ProposeBlindedBlockV1(nil, tt.block, new(ExecutionPayloadWithTxRootV1))
+			if tt.wantErr != nil {
+				require.ErrorIs(t, err, tt.wantErr)
+			} else {
+				require.Nil(t, err)
+			}
+			require.Equal(t, tt.wantRelay, atomic.LoadInt32(&relayCalls) == 1)
+		})
+	}
+
+	require.Nil(t, (*proposerSignatures)(nil).verify(context.Background(), signedBlock(7, func(body *BlindedBeaconBlockBody) { body.Graffiti[0] = 1 }), testLog), "verification is disabled by default")
+}
@@ -0,0 +1,250 @@
+package lib
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+const (
+	pathProposerPayloadDelivered = "/relay/v1/data/bidtraces/proposer_payload_delivered"
+
+	discrepancyMissing    = "missing_at_relay"    // mev-boost delivered a payload the relay doesn't report
+	discrepancyBlockHash  = "block_hash_mismatch" // the relay reports a different block for the slot
+	discrepancyValue      = "value_mismatch"      // the relay reports a different value than it bid
+	discrepancyUnrecorded = "unrecorded_delivery" // the relay reports a delivery for our validators mev-boost didn't record
+)
+
+var maxDeliveryDiscrepancies = 1000
+
+// defaultReconcileGrace is how long a delivery may be missing from a relay's data API before it's flagged, relays
+// update their data API some time after they delivered the payload
+const defaultReconcileGrace = 2 * time.Minute
+
+// BidTrace is an entry of a relay's proposer_payload_delivered data API
+type BidTrace struct {
+	Slot                 string `json:"slot"`
+	ParentHash           string `json:"parent_hash"`
+	BlockHash            string `json:"block_hash"`
+	BuilderPubkey        string `json:"builder_pubkey"`
+	ProposerPubkey       string `json:"proposer_pubkey"`
+	ProposerFeeRecipient string `json:"proposer_fee_recipient"`
+	GasLimit             string `json:"gas_limit"`
+	GasUsed              string `json:"gas_used"`
+	Value                string `json:"value"`
+}
+
+// DeliveryDiscrepancy is a mismatch between what mev-boost recorded and what a relay's data API reports
+type DeliveryDiscrepancy struct {
+	Kind           string    `json:"kind"`
+	RelayURL       string    `json:"relayUrl"`
+	Slot           uint64    `json:"slot,string"`
+	BlockHash      string    `json:"blockHash,omitempty"`
+	RelayBlockHash string    `json:"relayBlockHash,omitempty"`
+	Value          string    `json:"value,omitempty"`
+	RelayValue     string    `json:"relayValue,omitempty"`
+	DetectedAt     time.Time `json:"detectedAt"`
+}
+
+// deliveryReconciler periodically checks relay data APIs against the local delivery log
+type deliveryReconciler struct {
+	validatorPubkeys []string
+	grace            time.Duration // how long after a delivery its absence from the data API isn't flagged yet
+
+	mu            sync.RWMutex
+	discrepancies []*DeliveryDiscrepancy
+}
+
+func (r *deliveryReconciler) add(discrepancy *DeliveryDiscrepancy) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.discrepancies = append(r.discrepancies, discrepancy)
+	if len(r.discrepancies) > maxDeliveryDiscrepancies {
+		r.discrepancies = r.discrepancies[len(r.discrepancies)-maxDeliveryDiscrepancies:]
+	}
+}
+
+func (r *deliveryReconciler) all() []*DeliveryDiscrepancy {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	discrepancies := make([]*DeliveryDiscrepancy, len(r.discrepancies))
+	copy(discrepancies, r.discrepancies)
+	return discrepancies
+}
+
+// fetchDeliveredPayloads queries the proposer_payload_delivered data API of a relay
+func fetchDeliveredPayloads(ctx context.Context, client *http.Client, relayURL string, query url.Values) ([]BidTrace, error) {
+	reqURL := strings.TrimRight(relayURL, "/") + pathProposerPayloadDelivered + "?" + query.Encode()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, reqURL, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(responseLimit{size: maxRelayHeaderResponseSize, json: defaultJSONLimits}.reader(resp.Body))
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("data API returned status %d: %s", resp.StatusCode, string(body))
+	}
+
+	var traces []BidTrace
+	if err := json.Unmarshal(body, &traces); err != nil {
+		return nil, err
+	}
+	return traces, nil
+}
+
+// fetchDeliveredPayloads queries the data API of a relay on the endpoint it's reachable on
+func (m *RelayService) fetchDeliveredPayloads(ctx context.Context, relayURL string, query url.Values) (traces []BidTrace, err error) {
+	err = m.endpoints.call(ctx, relayURL, m.log, func(endpoint string) error {
+		traces, err = fetchDeliveredPayloads(ctx, m.client, endpoint, query)
+		return err
+	})
+	return traces, err
+}
+
+// reconcileDeliveries compares the delivered payloads mev-boost recorded against what each relay reports
+func (m *RelayService) reconcileDeliveries(ctx context.Context) {
+	for _, relayURL := range m.relays.all() {
+		log := m.log.WithFields(Fields{"url": relayURL, "prefix": "lib/reconcile"})
+
+		for _, delivery := range m.deliveries.unreconciled(relayURL) {
+			traces, err := m.fetchDeliveredPayloads(ctx, relayURL, url.Values{"slot": {strconv.FormatUint(delivery.Slot, 10)}})
+			if err != nil {
+				log.WithError(err).Warn("could not query relay data API")
+				break
+			}
+			if len(traces) == 0 && now().Sub(delivery.DeliveredAt) < m.reconciler.grace {
+				continue // the data API may not have caught up yet, the delivery is checked again on the next run
+			}
+			for _, discrepancy := range compareDelivery(delivery, traces) {
+				m.flagDiscrepancy(discrepancy, log)
+			}
+			m.deliveries.markReconciled(delivery.BlockHash)
+		}
+
+		for _, pubkey := range m.reconciler.validatorPubkeys {
+			traces, err := m.fetchDeliveredPayloads(ctx, relayURL, url.Values{"proposer_pubkey": {pubkey}})
+			if err != nil {
+				log.WithError(err).Warn("could not query relay data API")
+				break
+			}
+			for _, trace := range traces {
+				slot, err := strconv.ParseUint(trace.Slot, 10, 64)
+				if err != nil || m.deliveries.hasSlot(slot) || m.reconciler.known(relayURL, slot) {
+					continue
+				}
+				m.flagDiscrepancy(&DeliveryDiscrepancy{
+					Kind:           discrepancyUnrecorded,
+					RelayURL:       relayURL,
+					Slot:           slot,
+					RelayBlockHash: trace.BlockHash,
+					RelayValue:     trace.Value,
+				}, log)
+			}
+		}
+	}
+}
+
+func (r *deliveryReconciler) known(relayURL string, slot uint64) bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, discrepancy := range r.discrepancies {
+		if discrepancy.RelayURL == relayURL && discrepancy.Slot == slot {
+			return true
+		}
+	}
+	return false
+}
+
+func (m *RelayService) flagDiscrepancy(discrepancy *DeliveryDiscrepancy, log Logger) {
+	discrepancy.DetectedAt = now()
+	m.reconciler.add(discrepancy)
+	deliveryDiscrepanciesTotal.WithLabelValues(discrepancy.RelayURL, discrepancy.Kind).Inc()
+	log.WithFields(Fields{
+		"kind":           discrepancy.Kind,
+		"slot":           discrepancy.Slot,
+		"blockHash":      discrepancy.BlockHash,
+		"relayBlockHash": discrepancy.RelayBlockHash,
+		"value":          discrepancy.Value,
+		"relayValue":     discrepancy.RelayValue,
+	}).Warn("relay data API disagrees with delivered payload")
+}
+
+// compareDelivery checks a delivered payload against the relay's bid traces for the same slot
+func compareDelivery(delivery DeliveredPayload, traces []BidTrace) []*DeliveryDiscrepancy {
+	value := ""
+	if delivery.Value != nil {
+		value = delivery.Value.String()
+	}
+
+	if len(traces) == 0 {
+		return []*DeliveryDiscrepancy{{
+			Kind:      discrepancyMissing,
+			RelayURL:  delivery.RelayURL,
+			Slot:      delivery.Slot,
+			BlockHash: delivery.BlockHash.Hex(),
+			Value:     value,
+		}}
+	}
+
+	var discrepancies []*DeliveryDiscrepancy
+	for _, trace := range traces {
+		if common.HexToHash(trace.BlockHash) != delivery.BlockHash {
+			discrepancies = append(discrepancies, &DeliveryDiscrepancy{
+				Kind:           discrepancyBlockHash,
+				RelayURL:       delivery.RelayURL,
+				Slot:           delivery.Slot,
+				BlockHash:      delivery.BlockHash.Hex(),
+				RelayBlockHash: trace.BlockHash,
+			})
+			continue
+		}
+
+		relayValue, ok := new(big.Int).SetString(trace.Value, 10)
+		if delivery.Value != nil && (!ok || relayValue.Cmp(delivery.Value) != 0) {
+			discrepancies = append(discrepancies, &DeliveryDiscrepancy{
+				Kind:       discrepancyValue,
+				RelayURL:   delivery.RelayURL,
+				Slot:       delivery.Slot,
+				BlockHash:  delivery.BlockHash.Hex(),
+				Value:      value,
+				RelayValue: trace.Value,
+			})
+		}
+	}
+	return discrepancies
+}
+
+// startDeliveryReconciliation reconciles deliveries every interval until ctx is done
+func (m *RelayService) startDeliveryReconciliation(ctx context.Context, interval time.Duration) {
+	runLoop(ctx, m.log, "reconciliation", interval, false, m.reconcileDeliveries)
+}
+
+func (m *RelayService) handleDeliveries(w http.ResponseWriter, _ *http.Request) {
+	respondJSON(w, http.StatusOK, m.deliveries.all())
+}
+
+func (m *RelayService) handleDeliveryDiscrepancies(w http.ResponseWriter, _ *http.Request) {
+	respondJSON(w, http.StatusOK, m.reconciler.all())
+}
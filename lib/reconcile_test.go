@@ -0,0 +1,94 @@
+package lib
+
+import (
+	"context"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_compareDelivery(t *testing.T) {
+	blockHash := common.HexToHash("0x01")
+	delivery := DeliveredPayload{Slot: 1, BlockHash: blockHash, Value: big.NewInt(10)}
+
+	tests := []struct {
+		name      string
+		traces    []BidTrace
+		wantKinds []string
+	}{
+		{"match", []BidTrace{{Slot: "1", BlockHash: blockHash.Hex(), Value: "10"}}, nil},
+		{"missing", nil, []string{discrepancyMissing}},
+		{"other block", []BidTrace{{Slot: "1", BlockHash: common.HexToHash("0x02").Hex(), Value: "10"}}, []string{discrepancyBlockHash}},
+		{"other value", []BidTrace{{Slot: "1", BlockHash: blockHash.Hex(), Value: "9"}}, []string{discrepancyValue}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var kinds []string
+			for _, discrepancy := range compareDelivery(delivery, tt.traces) {
+				kinds = append(kinds, discrepancy.Kind)
+			}
+			require.Equal(t, tt.wantKinds, kinds)
+		})
+	}
+}
+
+func TestRelayService_reconcileDeliveries(t *testing.T) {
+	relayServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, pathProposerPayloadDelivered, r.URL.Path)
+		traces := []BidTrace{}
+		if r.URL.Query().Get("proposer_pubkey") == "0xabc" {
+			traces = append(traces, BidTrace{Slot: "7", BlockHash: common.HexToHash("0x07").Hex(), Value: "1"})
+		}
+		json.NewEncoder(w).Encode(traces)
+	}))
+	defer relayServer.Close()
+
+	relay, err := newRelayService(WithRelayURLs(relayServer.URL), WithStore(NewStore()), WithLogger(testLog), WithValidatorPubkeys("0xabc"))
+	require.Nil(t, err)
+	relay.deliveries.add(&DeliveredPayload{Slot: 5, BlockHash: common.HexToHash("0x05"), RelayURL: relayServer.URL, Value: big.NewInt(1)})
+
+	relay.reconcileDeliveries(context.Background())
+	discrepancies := relay.reconciler.all()
+	require.Equal(t, 2, len(discrepancies))
+	require.Equal(t, discrepancyMissing, discrepancies[0].Kind)
+	require.Equal(t, discrepancyUnrecorded, discrepancies[1].Kind)
+	require.Equal(t, true, relay.deliveries.all()[0].Reconciled)
+
+	// discrepancies are only flagged once
+	relay.reconcileDeliveries(context.Background())
+	require.Equal(t, 2, len(relay.reconciler.all()))
+}
+
+func TestRelayService_reconcileDeliveries_grace(t *testing.T) {
+	defer func() { now = time.Now }()
+	start := time.Unix(1000, 0)
+	now = func() time.Time { return start }
+
+	relayServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode([]BidTrace{})
+	}))
+	defer relayServer.Close()
+
+	relay, err := newRelayService(WithRelayURLs(relayServer.URL), WithStore(NewStore()), WithLogger(testLog), WithReconcileGrace(time.Minute))
+	require.Nil(t, err)
+	relay.deliveries.add(&DeliveredPayload{Slot: 5, BlockHash: common.HexToHash("0x05"), RelayURL: relayServer.URL, DeliveredAt: start})
+
+	// the data API may lag behind the delivery
+	now = func() time.Time { return start.Add(time.Minute - time.Second) }
+	relay.reconcileDeliveries(context.Background())
+	require.Equal(t, 0, len(relay.reconciler.all()))
+	require.Equal(t, false, relay.deliveries.all()[0].Reconciled)
+
+	now = func() time.Time { return start.Add(time.Minute) }
+	relay.reconcileDeliveries(context.Background())
+	discrepancies := relay.reconciler.all()
+	require.Equal(t, 1, len(discrepancies))
+	require.Equal(t, discrepancyMissing, discrepancies[0].Kind)
+	require.Equal(t, true, relay.deliveries.all()[0].Reconciled)
+}
@@ -0,0 +1,76 @@
+package lib
+
+import (
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var registrationsThrottledTotal = metricsFactory.NewCounter(prometheus.CounterOpts{
+	Name: "mevboost_registrations_throttled_total",
+	Help: "Repeated engine_forkchoiceUpdatedV1 registrations answered without forwarding them to relays",
+})
+
+// registrationThrottle limits how often the registration of a fee recipient, a forkchoiceUpdated call with payload
+// attributes, is forwarded to relays. A consensus client repeating the same call in a loop is answered with the
+// payload id of the first call, so relays don't see, and possibly ban, the flood.
+type registrationThrottle struct {
+	interval time.Duration
+
+	mu     sync.Mutex
+	recent map[common.Address]*registration // by fee recipient
+}
+
+// registration is the last forwarded registration of a fee recipient
+type registration struct {
+	params    string
+	payloadID hexutil.Bytes
+	at        time.Time
+}
+
+func newRegistrationThrottle(interval time.Duration) *registrationThrottle {
+	return &registrationThrottle{interval: interval, recent: make(map[common.Address]*registration)}
+}
+
+// registrationParams identifies a registration by its forkchoiceUpdated params, calls for a new head aren't repeats
+func registrationParams(args []interface{}) string {
+	params, _ := json.Marshal(args) // the params were decoded from JSON, they encode again
+	return string(params)
+}
+
+// repeated returns the payload id of the last registration of feeRecipient if it had the same params and was
+// forwarded less than the interval ago
+func (t *registrationThrottle) repeated(feeRecipient common.Address, params string) (hexutil.Bytes, bool) {
+	if t == nil {
+		return nil, false
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	last, ok := t.recent[feeRecipient]
+	if !ok || last.params != params || now().Sub(last.at) >= t.interval {
+		return nil, false
+	}
+	registrationsThrottledTotal.Inc()
+	return last.payloadID, true
+}
+
+// forwarded records a registration forwarded to relays
+func (t *registrationThrottle) forwarded(feeRecipient common.Address, params string, payloadID hexutil.Bytes) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.recent[feeRecipient] = &registration{params: params, payloadID: payloadID, at: now()}
+	for recipient, last := range t.recent {
+		if now().Sub(last.at) >= t.interval {
+			delete(t.recent, recipient)
+		}
+	}
+}
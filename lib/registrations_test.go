@@ -0,0 +1,53 @@
+package lib
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRelayService_RegistrationInterval(t *testing.T) {
+	defer func() { now = time.Now }()
+	start := time.Unix(1000, 0)
+	now = func() time.Time { return start }
+
+	var relayCalls int32
+	relay := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&relayCalls, 1)
+		resp, err := formatResponse(ForkChoiceResponse{PayloadStatus: PayloadStatus{Status: ForkchoiceStatusValid}, PayloadID: &hexutil.Bytes{1}})
+		require.Nil(t, err)
+		w.Write(resp)
+	}))
+	defer relay.Close()
+
+	service, err := newRelayService(WithRelayURLs(relay.URL), WithStore(NewStore()), WithLogger(testLog), WithRegistrationInterval(12*time.Second))
+	require.Nil(t, err)
+
+	register := func(head string) string {
+		req, _ := http.NewRequest(http.MethodPost, "/", nil)
+		args := []interface{}{
+			map[string]interface{}{"headBlockHash": common.HexToHash(head).Hex()},
+			map[string]interface{}{"timestamp": "0x10", "suggestedFeeRecipient": common.HexToAddress("0x02").Hex()},
+		}
+		result := new(ForkChoiceResponse)
+		require.Nil(t, service.ForkchoiceUpdatedV1(req, &args, result))
+		return result.PayloadID.String()
+	}
+
+	first := register("0x01")
+	require.Equal(t, first, register("0x01"), "a repeated registration gets the same payload id")
+	require.Equal(t, int32(1), atomic.LoadInt32(&relayCalls))
+
+	require.NotEqual(t, first, register("0x02"), "registrations for a new head are forwarded")
+	require.Equal(t, int32(2), atomic.LoadInt32(&relayCalls))
+
+	now = func() time.Time { return start.Add(12 * time.Second) }
+	register("0x02")
+	require.Equal(t, int32(3), atomic.LoadInt32(&relayCalls), "registrations are forwarded again after the interval")
+}
@@ -0,0 +1,210 @@
+package lib
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// FieldPolicy selects what happens to relay responses whose fields drifted from the types mev-boost decodes them into
+type FieldPolicy string
+
+// Policies of WithRelayFieldPolicy
+const (
+	// FieldsIgnore decodes the response as it is: unknown fields are dropped and missing fields are zero
+	FieldsIgnore FieldPolicy = "ignore"
+	// FieldsWarn decodes the response, but logs and counts the fields that drifted
+	FieldsWarn FieldPolicy = "warn"
+	// FieldsReject rejects the response as invalid
+	FieldsReject FieldPolicy = "reject"
+)
+
+// ParseFieldPolicy parses the name of a field policy
+func ParseFieldPolicy(name string) (FieldPolicy, error) {
+	switch policy := FieldPolicy(strings.ToLower(name)); policy {
+	case FieldsIgnore, FieldsWarn, FieldsReject:
+		return policy, nil
+	}
+	return "", fmt.Errorf("unknown field policy %q, expected ignore, warn or reject", name)
+}
+
+var relayFieldDriftTotal = metricsFactory.NewCounterVec(prometheus.CounterOpts{
+	Name: "mevboost_relay_field_drift_total",
+	Help: "Relay responses with unknown fields or missing required fields, by relay and kind",
+}, []string{"relay", "kind"})
+
+// relayDecoder decodes relay responses. Like encoding/json, it doesn't care about the order of fields or the casing of
+// their names, but it detects fields the type doesn't declare and fields tagged gencodec:"required" that are missing
+// or null, and applies a policy to each, so changes of the protocol on the relay side don't go unnoticed.
+type relayDecoder struct {
+	unknown FieldPolicy
+	missing FieldPolicy
+	log     Logger
+}
+
+// decode decodes data returned by relayURL into v
+func (d relayDecoder) decode(relayURL string, data []byte, v interface{}) error {
+	if d.checks() {
+		var drift fieldDrift
+		drift.walk("", data, reflect.TypeOf(v))
+		if err := d.apply(relayURL, "unknown", d.unknown, drift.unknown); err != nil {
+			return err
+		}
+		if err := d.apply(relayURL, "missing", d.missing, drift.missing); err != nil {
+			return err
+		}
+	}
+	return json.Unmarshal(data, v)
+}
+
+// checks is true if responses are checked for drifted fields at all
+func (d relayDecoder) checks() bool {
+	return d.unknown != FieldsIgnore || d.missing != FieldsIgnore
+}
+
+func (d relayDecoder) apply(relayURL string, kind string, policy FieldPolicy, fields []string) error {
+	if len(fields) == 0 || policy == FieldsIgnore {
+		return nil
+	}
+	relayFieldDriftTotal.WithLabelValues(relayURL, kind).Inc()
+	if policy == FieldsReject {
+		return fmt.Errorf("%w: %s fields %s", ErrValidationFailed, kind, strings.Join(fields, ", "))
+	}
+	d.log.WithFields(Fields{"url": relayURL, kind + "Fields": strings.Join(fields, ", ")}).Warn("relay response doesn't match the expected fields")
+	return nil
+}
+
+// fieldDrift collects the JSON paths of unknown and missing fields of a response
+type fieldDrift struct {
+	unknown []string
+	missing []string
+}
+
+// walk compares the JSON value data at path with the type it's decoded into. Values that don't have the shape of the
+// type are left to encoding/json to reject.
+func (d *fieldDrift) walk(path string, data json.RawMessage, t reflect.Type) {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	switch t.Kind() {
+	case reflect.Struct:
+		var object map[string]json.RawMessage
+		if json.Unmarshal(data, &object) != nil || object == nil {
+			return
+		}
+		fields := jsonFieldsOf(t)
+		for key, value := range object {
+			field := fields.lookup(key)
+			if field == nil {
+				d.unknown = append(d.unknown, path+"."+key)
+				continue
+			}
+			d.walk(path+"."+field.name, value, field.typ)
+		}
+		for _, field := range fields {
+			if field.required && isNull(object[objectKey(object, field.name)]) {
+				d.missing = append(d.missing, path+"."+field.name)
+			}
+		}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 { // bytes are hex strings
+			return
+		}
+		var elems []json.RawMessage
+		if json.Unmarshal(data, &elems) != nil {
+			return
+		}
+		for i, elem := range elems {
+			d.walk(fmt.Sprintf("%s[%d]", path, i), elem, t.Elem())
+		}
+	case reflect.Map:
+		var object map[string]json.RawMessage
+		if json.Unmarshal(data, &object) != nil {
+			return
+		}
+		for key, value := range object {
+			d.walk(path+"."+key, value, t.Elem())
+		}
+	}
+}
+
+func isNull(value json.RawMessage) bool {
+	return value == nil || strings.TrimSpace(string(value)) == "null"
+}
+
+// jsonField is a field of a struct as encoding/json sees it
+type jsonField struct {
+	name     string
+	typ      reflect.Type
+	required bool
+}
+
+type jsonFields []*jsonField
+
+// lookup returns the field a key decodes into, preferring an exact match over a case-insensitive one like encoding/json
+func (f jsonFields) lookup(key string) *jsonField {
+	var folded *jsonField
+	for _, field := range f {
+		if field.name == key {
+			return field
+		}
+		if folded == nil && strings.EqualFold(field.name, key) {
+			folded = field
+		}
+	}
+	return folded
+}
+
+// objectKey returns the key of object that decodes into the field name, or name if there is none
+func objectKey(object map[string]json.RawMessage, name string) string {
+	if _, ok := object[name]; ok {
+		return name
+	}
+	for key := range object {
+		if strings.EqualFold(key, name) {
+			return key
+		}
+	}
+	return name
+}
+
+var jsonFieldsCache sync.Map // map[reflect.Type]jsonFields
+
+// jsonFieldsOf returns the fields of struct type t, including those of embedded structs without a name
+func jsonFieldsOf(t reflect.Type) jsonFields {
+	if cached, ok := jsonFieldsCache.Load(t); ok {
+		return cached.(jsonFields)
+	}
+	var fields jsonFields
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		tag := sf.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name := strings.Split(tag, ",")[0]
+		if sf.Anonymous && name == "" {
+			embedded := sf.Type
+			if embedded.Kind() == reflect.Ptr {
+				embedded = embedded.Elem()
+			}
+			if embedded.Kind() == reflect.Struct {
+				fields = append(fields, jsonFieldsOf(embedded)...)
+				continue
+			}
+		}
+		if !sf.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = sf.Name
+		}
+		fields = append(fields, &jsonField{name: name, typ: sf.Type, required: sf.Tag.Get("gencodec") == "required"})
+	}
+	jsonFieldsCache.Store(t, fields)
+	return fields
+}
@@ -0,0 +1,53 @@
+package lib
+
+import (
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRelayDecoder(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		unknown FieldPolicy
+		missing FieldPolicy
+		wantErr string
+	}{
+		{"any order and casing", `{"Methods":["a"],"specversion":"1"}`, FieldsReject, FieldsReject, ""},
+		{"unknown field ignored", `{"specVersion":"1","methods":[],"maxBid":"1"}`, FieldsIgnore, FieldsReject, ""},
+		{"unknown field warned", `{"specVersion":"1","methods":[],"maxBid":"1"}`, FieldsWarn, FieldsReject, ""},
+		{"unknown field rejected", `{"specVersion":"1","methods":[],"maxBid":"1"}`, FieldsReject, FieldsReject, "unknown fields .maxBid"},
+		{"missing field ignored", `{"specVersion":"1"}`, FieldsReject, FieldsIgnore, ""},
+		{"missing field rejected", `{"specVersion":"1"}`, FieldsReject, FieldsReject, "missing fields .methods"},
+		{"null field rejected", `{"specVersion":"1","methods":null}`, FieldsReject, FieldsReject, "missing fields .methods"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			decoder := relayDecoder{unknown: tt.unknown, missing: tt.missing, log: testLog}
+			capabilities := new(RelayCapabilities)
+			err := decoder.decode("http://relay", []byte(tt.data), capabilities)
+			if tt.wantErr != "" {
+				require.ErrorIs(t, err, ErrValidationFailed)
+				require.Contains(t, err.Error(), tt.wantErr)
+				return
+			}
+			require.Nil(t, err)
+			require.Equal(t, "1", capabilities.SpecVersion)
+		})
+	}
+}
+
+func TestRelayDecoder_nested(t *testing.T) {
+	decoder := relayDecoder{unknown: FieldsReject, missing: FieldsReject, log: testLog}
+	response := new(ForkChoiceResponse)
+	err := decoder.decode("http://relay", []byte(`{"payloadStatus":{"status":"VALID","witness":"0x01"},"payloadId":"0x01"}`), response)
+	require.ErrorIs(t, err, ErrValidationFailed)
+	require.Contains(t, err.Error(), ".payloadStatus.witness")
+
+	header := ExecutionPayloadWithTxRootV1{BlockHash: common.HexToHash("0x01"), BaseFeePerGas: common.Big1, FeeRecipientDiff: common.Big1}
+	data, err := header.MarshalJSON()
+	require.Nil(t, err)
+	require.Nil(t, decoder.decode("http://relay", data, new(ExecutionPayloadWithTxRootV1)))
+}
@@ -0,0 +1,201 @@
+package lib
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"sort"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// Selection policies of relay groups
+const (
+	// RelayGroupMaxValue offers the bids of the group most valuable first
+	RelayGroupMaxValue = "max-value"
+	// RelayGroupRelayOrder offers the bids of the group in the order its relays are listed
+	RelayGroupRelayOrder = "relay-order"
+)
+
+// RelayGroup is a named subset of the configured relays with the policy its bids are selected by
+type RelayGroup struct {
+	Name string `json:"name"`
+	// RelayURLs are configured relays, by url or by host, so relay credentials don't need to be repeated
+	RelayURLs []string `json:"relays"`
+	// Policy is RelayGroupMaxValue, the default, or RelayGroupRelayOrder
+	Policy string `json:"policy,omitempty"`
+	// Priority orders the active groups, bids of a group are only used if no higher priority group has a valid bid
+	Priority int `json:"priority,omitempty"`
+
+	relays map[string]int // map[configured url]position in the group
+}
+
+// RelayGroups are the relay groups and which of them are active for a proposal
+type RelayGroups struct {
+	Groups []RelayGroup `json:"groups"`
+	// Active are the names of the groups used for proposals, all groups if empty
+	Active []string `json:"active,omitempty"`
+	// Validators are the names of the groups used instead of Active for the proposals of a fee recipient
+	Validators map[common.Address][]string `json:"validators,omitempty"`
+}
+
+// LoadRelayGroups reads relay groups from a JSON file at path
+func LoadRelayGroups(path string) (*RelayGroups, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	groups := new(RelayGroups)
+	if err := json.Unmarshal(data, groups); err != nil {
+		return nil, fmt.Errorf("could not parse relay groups: %w", err)
+	}
+	return groups, nil
+}
+
+// relayGroups selects the relays and orders the bids of a proposal by the active groups of its fee recipient
+type relayGroups struct {
+	active     []*RelayGroup // by priority
+	validators map[common.Address][]*RelayGroup
+}
+
+func newRelayGroups(config *RelayGroups, relayURLs []string) (*relayGroups, error) {
+	groups := make(map[string]*RelayGroup, len(config.Groups))
+	var all []string
+	for i := range config.Groups {
+		group := config.Groups[i]
+		if group.Name == "" || groups[group.Name] != nil {
+			return nil, fmt.Errorf("relay group %d: name must be set and unique", i)
+		}
+		if group.Policy == "" {
+			group.Policy = RelayGroupMaxValue
+		}
+		if group.Policy != RelayGroupMaxValue && group.Policy != RelayGroupRelayOrder {
+			return nil, fmt.Errorf("relay group %s: unknown policy %q", group.Name, group.Policy)
+		}
+		if len(group.RelayURLs) == 0 {
+			return nil, fmt.Errorf("relay group %s: no relays", group.Name)
+		}
+		group.relays = make(map[string]int, len(group.RelayURLs))
+		for _, entry := range group.RelayURLs {
+			relayURL, err := resolveRelay(entry, relayURLs)
+			if err != nil {
+				return nil, fmt.Errorf("relay group %s: %w", group.Name, err)
+			}
+			if _, ok := group.relays[relayURL]; !ok {
+				group.relays[relayURL] = len(group.relays)
+			}
+		}
+		groups[group.Name] = &group
+		all = append(all, group.Name)
+	}
+
+	resolve := func(names []string) ([]*RelayGroup, error) {
+		resolved := make([]*RelayGroup, 0, len(names))
+		for _, name := range names {
+			group, ok := groups[name]
+			if !ok {
+				return nil, fmt.Errorf("unknown relay group %s", name)
+			}
+			resolved = append(resolved, group)
+		}
+		sort.SliceStable(resolved, func(i, j int) bool { return resolved[i].Priority > resolved[j].Priority })
+		return resolved, nil
+	}
+	active := config.Active
+	if len(active) == 0 {
+		active = all
+	}
+	g := &relayGroups{validators: make(map[common.Address][]*RelayGroup, len(config.Validators))}
+	var err error
+	if g.active, err = resolve(active); err != nil {
+		return nil, err
+	}
+	for feeRecipient, names := range config.Validators {
+		if g.validators[feeRecipient], err = resolve(names); err != nil {
+			return nil, fmt.Errorf("validator %s: %w", feeRecipient, err)
+		}
+	}
+	return g, nil
+}
+
+// resolveRelay returns the configured relay with url or host entry
+func resolveRelay(entry string, relayURLs []string) (string, error) {
+	var found []string
+	for _, relayURL := range relayURLs {
+		if relayURL == entry {
+			return relayURL, nil
+		}
+		if u, err := url.Parse(relayURL); err == nil && u.Host == entry {
+			found = append(found, relayURL)
+		}
+	}
+	switch len(found) {
+	case 0:
+		return "", fmt.Errorf("relay %s is not configured", entry)
+	case 1:
+		return found[0], nil
+	default:
+		return "", fmt.Errorf("relay %s is ambiguous, several relays have this host", entry)
+	}
+}
+
+// of returns the active groups of the proposals of feeRecipient, by priority
+func (g *relayGroups) of(feeRecipient common.Address) []*RelayGroup {
+	if groups, ok := g.validators[feeRecipient]; ok {
+		return groups
+	}
+	return g.active
+}
+
+// usesRelay reports whether the relay at url is in an active group of feeRecipient, all relays are used without groups
+func (g *relayGroups) usesRelay(feeRecipient common.Address, url string) bool {
+	if g == nil {
+		return true
+	}
+	for _, group := range g.of(feeRecipient) {
+		if _, ok := group.relays[url]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// relaysOf returns the forkchoice responses of the relays in the active groups of feeRecipient, in a new map
+func (g *relayGroups) relaysOf(feeRecipient common.Address, forkchoiceResponses map[string]string) map[string]string {
+	filtered := make(map[string]string, len(forkchoiceResponses))
+	for relayURL, payloadID := range forkchoiceResponses {
+		if g.usesRelay(feeRecipient, relayURL) {
+			filtered[relayURL] = payloadID
+		}
+	}
+	return filtered
+}
+
+// order returns the candidates to offer for a proposal of feeRecipient: those of the active groups by priority, each
+// group ordered by its policy. candidates must be sorted most valuable first, they are returned unchanged without groups.
+func (g *relayGroups) order(feeRecipient common.Address, candidates []BidCandidate) []BidCandidate {
+	if g == nil {
+		return candidates
+	}
+	ordered := make([]BidCandidate, 0, len(candidates))
+	offered := make(map[*ExecutionPayloadWithTxRootV1]bool, len(candidates))
+	for _, group := range g.of(feeRecipient) {
+		var members []BidCandidate
+		for _, candidate := range candidates {
+			if _, ok := group.relays[candidate.RelayURL]; ok && !offered[candidate.Header] {
+				members = append(members, candidate)
+			}
+		}
+		if group.Policy == RelayGroupRelayOrder {
+			sort.SliceStable(members, func(i, j int) bool {
+				return group.relays[members[i].RelayURL] < group.relays[members[j].RelayURL]
+			})
+		}
+		for _, candidate := range members {
+			offered[candidate.Header] = true
+			ordered = append(ordered, candidate)
+		}
+	}
+	return ordered
+}
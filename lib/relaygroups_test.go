@@ -0,0 +1,124 @@
+package lib
+
+import (
+	"bytes"
+	"context"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewRelayGroups(t *testing.T) {
+	relayURLs := []string{"https://0xabc@relay-a.example.com", "https://relay-b.example.com", "https://0x1@relay-c.example.com", "https://0x2@relay-c.example.com"}
+	tests := []struct {
+		name   string
+		config RelayGroups
+		err    string
+	}{
+		{"by url and host", RelayGroups{Groups: []RelayGroup{{Name: "a", RelayURLs: []string{"relay-a.example.com", "https://relay-b.example.com"}}}}, ""},
+		{"unnamed", RelayGroups{Groups: []RelayGroup{{RelayURLs: []string{"relay-a.example.com"}}}}, "name must be set"},
+		{"duplicate", RelayGroups{Groups: []RelayGroup{{Name: "a", RelayURLs: []string{"relay-a.example.com"}}, {Name: "a", RelayURLs: []string{"relay-b.example.com"}}}}, "unique"},
+		{"no relays", RelayGroups{Groups: []RelayGroup{{Name: "a"}}}, "no relays"},
+		{"unknown policy", RelayGroups{Groups: []RelayGroup{{Name: "a", RelayURLs: []string{"relay-a.example.com"}, Policy: "random"}}}, "unknown policy"},
+		{"unknown relay", RelayGroups{Groups: []RelayGroup{{Name: "a", RelayURLs: []string{"relay-x.example.com"}}}}, "not configured"},
+		{"ambiguous host", RelayGroups{Groups: []RelayGroup{{Name: "a", RelayURLs: []string{"relay-c.example.com"}}}}, "ambiguous"},
+		{"unknown active group", RelayGroups{Groups: []RelayGroup{{Name: "a", RelayURLs: []string{"relay-a.example.com"}}}, Active: []string{"b"}}, "unknown relay group b"},
+		{"unknown validator group", RelayGroups{
+			Groups:     []RelayGroup{{Name: "a", RelayURLs: []string{"relay-a.example.com"}}},
+			Validators: map[common.Address][]string{common.HexToAddress("0x01"): {"b"}},
+		}, "unknown relay group b"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := newRelayGroups(&tt.config, relayURLs)
+			if tt.err == "" {
+				require.Nil(t, err)
+			} else {
+				require.Error(t, err)
+				require.Contains(t, err.Error(), tt.err)
+			}
+		})
+	}
+}
+
+func TestRelayGroups_order(t *testing.T) {
+	relayURLs := []string{"https://a.example.com", "https://b.example.com", "https://c.example.com"}
+	groups, err := newRelayGroups(&RelayGroups{
+		Groups: []RelayGroup{
+			{Name: "max-profit", RelayURLs: []string{"a.example.com", "b.example.com", "c.example.com"}},
+			{Name: "regulated", RelayURLs: []string{"c.example.com", "b.example.com"}, Policy: RelayGroupRelayOrder, Priority: 1},
+		},
+		Validators: map[common.Address][]string{common.HexToAddress("0x01"): {"max-profit"}},
+	}, relayURLs)
+	require.Nil(t, err)
+
+	candidate := func(relayURL string, value int64) BidCandidate {
+		return BidCandidate{relayURL, &ExecutionPayloadWithTxRootV1{FeeRecipientDiff: big.NewInt(value)}}
+	}
+	a, b, c := candidate(relayURLs[0], 3), candidate(relayURLs[1], 2), candidate(relayURLs[2], 1)
+	candidates := []BidCandidate{a, b, c} // most valuable first
+
+	require.Equal(t, []BidCandidate{c, b, a}, groups.order(common.Address{}, candidates), "regulated relays in their order, then the rest")
+	require.Equal(t, candidates, groups.order(common.HexToAddress("0x01"), candidates), "validator only uses max-profit")
+	require.True(t, groups.usesRelay(common.Address{}, relayURLs[0]))
+
+	var none *relayGroups
+	require.Equal(t, candidates, none.order(common.Address{}, candidates))
+	require.True(t, none.usesRelay(common.Address{}, relayURLs[0]))
+}
+
+func TestRouter_RelayGroups(t *testing.T) {
+	newRelay := func(blockHash string, value int64) *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			resp, err := formatResponse(ExecutionPayloadWithTxRootV1{
+				BlockHash:        common.HexToHash(blockHash),
+				BaseFeePerGas:    big.NewInt(1),
+				FeeRecipientDiff: big.NewInt(value),
+			})
+			require.Nil(t, err)
+			w.Write(resp)
+		}))
+	}
+	regulated, unregulated := newRelay("0x01", 1), newRelay("0x02", 2)
+	defer regulated.Close()
+	defer unregulated.Close()
+
+	validator := common.HexToAddress("0x01")
+	store := NewStore()
+	for _, payloadID := range []string{"0x01", "0x02"} {
+		store.SetForkchoiceResponse(context.Background(), payloadID, regulated.URL, "0x01")
+		store.SetForkchoiceResponse(context.Background(), payloadID, unregulated.URL, "0x01")
+	}
+	store.SetPayloadAttributes(context.Background(), "0x02", &PayloadAttributesV1{SuggestedFeeRecipient: validator})
+	router, err := NewRouter(context.Background(), WithRelayURLs(regulated.URL, unregulated.URL), WithStore(store), WithLogger(testLog), WithCapabilityCheckInterval(0),
+		WithRelayGroups(&RelayGroups{
+			Groups: []RelayGroup{
+				{Name: "regulated", RelayURLs: []string{regulated.URL}},
+				{Name: "max-profit", RelayURLs: []string{regulated.URL, unregulated.URL}},
+			},
+			Active:     []string{"max-profit"},
+			Validators: map[common.Address][]string{validator: {"regulated"}},
+		}))
+	require.Nil(t, err)
+
+	getHeader := func(payloadID string) common.Hash {
+		body, err := formatRequestBody("builder_getPayloadHeaderV1", []interface{}{payloadID})
+		require.Nil(t, err)
+		req := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		resp, err := parseRPCResponse(rr.Body.Bytes())
+		require.Nil(t, err)
+		require.Nil(t, resp.Error)
+		header := new(ExecutionPayloadWithTxRootV1)
+		require.Nil(t, header.UnmarshalJSON(resp.Result))
+		return header.BlockHash
+	}
+	require.Equal(t, common.HexToHash("0x02"), getHeader("0x01"), "globally active group takes the best bid")
+	require.Equal(t, common.HexToHash("0x01"), getHeader("0x02"), "validator uses its own group")
+}
@@ -0,0 +1,164 @@
+package lib
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// relayHealthCheckInterval is how often relays out of the rotation are checked for a finished cooldown
+var relayHealthCheckInterval = time.Second
+
+// Reasons of failed relay calls counted by the relay health
+const (
+	relayHealthError     = "error"
+	relayHealthTimeout   = "timeout"
+	relayHealthMalformed = "malformed"
+)
+
+var (
+	relayHealthFailuresTotal = metricsFactory.NewCounterVec(prometheus.CounterOpts{
+		Name: "mevboost_relay_health_failures_total",
+		Help: "Failed relay calls counted towards taking a relay out of the rotation, by relay and reason: error, timeout or malformed",
+	}, []string{"relay", "reason"})
+	relayUnhealthy = metricsFactory.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "mevboost_relay_unhealthy",
+		Help: "Whether a relay is out of the rotation after consecutive failures",
+	}, []string{"relay"})
+)
+
+// relayHealth is a circuit breaker of the relays. A relay whose calls failed, timed out or returned malformed responses
+// threshold times in a row is taken out of the rotation of forkchoiceUpdated and getPayloadHeader calls. After cooldown
+// it's probed with relay_getCapabilitiesV1, and put back once it answers; until then it's probed again every cooldown.
+// Signed blocks are still sent to relays out of the rotation, since they may hold the payload.
+type relayHealth struct {
+	threshold int
+	cooldown  time.Duration
+	events    *eventBus
+	log       Logger
+
+	mu       sync.Mutex
+	failures map[string]int       // consecutive failures by relay url
+	openedAt map[string]time.Time // relays out of the rotation, by the time they were taken out or last probed
+}
+
+func newRelayHealth(threshold int, cooldown time.Duration, events *eventBus, log Logger) *relayHealth {
+	return &relayHealth{
+		threshold: threshold,
+		cooldown:  cooldown,
+		events:    events,
+		log:       log.WithField("prefix", "lib/relayhealth"),
+		failures:  make(map[string]int),
+		openedAt:  make(map[string]time.Time),
+	}
+}
+
+// available returns whether a relay is in the rotation
+func (h *relayHealth) available(relayURL string) bool {
+	if h == nil {
+		return true
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	_, open := h.openedAt[relayURL]
+	return !open
+}
+
+// success resets the failures of a relay, and puts it back into the rotation
+func (h *relayHealth) success(relayURL string) {
+	if h == nil {
+		return
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.failures[relayURL] = 0
+	if _, open := h.openedAt[relayURL]; open {
+		delete(h.openedAt, relayURL)
+		relayUnhealthy.WithLabelValues(relayURL).Set(0)
+		h.log.WithField("url", relayURL).Info("relay is healthy again, putting it back into the rotation")
+	}
+}
+
+// failure counts a failed call of a relay, and takes the relay out of the rotation once it failed threshold times in a row
+func (h *relayHealth) failure(relayURL, reason string, err error) {
+	if h == nil {
+		return
+	}
+	relayHealthFailuresTotal.WithLabelValues(relayURL, reason).Inc()
+	h.mu.Lock()
+	h.failures[relayURL]++
+	failures := h.failures[relayURL]
+	_, open := h.openedAt[relayURL]
+	if open || failures < h.threshold {
+		h.mu.Unlock()
+		return
+	}
+	h.openedAt[relayURL] = now()
+	h.mu.Unlock()
+
+	relayUnhealthy.WithLabelValues(relayURL).Set(1)
+	fields := Fields{"url": relayURL, "failures": failures, "reason": reason, "error": err, "cooldown": h.cooldown}
+	h.log.WithFields(fields).Error("taking relay out of the rotation after consecutive failures")
+	event := &Event{Kind: EventRelayFault, RelayURL: relayURL, Fault: RelayFaultUnhealthy, Fields: Fields{"failures": failures, "reason": reason}}
+	if err != nil {
+		event.Error = err.Error()
+	}
+	h.events.publish(context.Background(), event)
+}
+
+// call counts a relay call that failed with err, if it failed. Calls cancelled by mev-boost, because another relay
+// answered or the consensus client went away, don't say anything about the relay.
+func (h *relayHealth) call(relayURL string, err error) {
+	switch {
+	case err == nil || errors.Is(err, context.Canceled):
+	case errors.Is(err, ErrValidationFailed):
+		h.failure(relayURL, relayHealthMalformed, err)
+	case errors.Is(err, context.DeadlineExceeded):
+		h.failure(relayURL, relayHealthTimeout, err)
+	default:
+		h.failure(relayURL, relayHealthError, err)
+	}
+}
+
+// due returns the relays out of the rotation whose cooldown is over, and restarts their cooldown
+func (h *relayHealth) due() []string {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	var due []string
+	for relayURL, openedAt := range h.openedAt {
+		if now().Sub(openedAt) >= h.cooldown {
+			due = append(due, relayURL)
+			h.openedAt[relayURL] = now()
+		}
+	}
+	return due
+}
+
+// probeUnhealthyRelays probes the relays out of the rotation whose cooldown is over, any JSON-RPC answer of one of the
+// endpoints of a relay puts it back
+func (m *RelayService) probeUnhealthyRelays(ctx context.Context) {
+	var wg sync.WaitGroup
+	for _, url := range m.health.due() {
+		wg.Add(1)
+		go func(url string) {
+			defer wg.Done()
+			for _, endpoint := range m.endpoints.all(url) {
+				_, err := makeRequest(ctx, m.client, endpoint, methodRelayGetCapabilities, []interface{}{}, m.responseLimits.forMethod(methodRelayGetCapabilities))
+				if err == nil {
+					m.health.success(url)
+					return
+				}
+				m.log.WithFields(Fields{"url": url, "endpoint": endpoint, "error": err}).Debug("relay out of the rotation failed its probe")
+			}
+		}(url)
+	}
+	wg.Wait()
+}
+
+// startRelayHealthChecks probes the relays out of the rotation once their cooldown is over, until ctx is done
+func (m *RelayService) startRelayHealthChecks(ctx context.Context) {
+	runLoop(ctx, m.log, "relay_health", relayHealthCheckInterval, false, m.probeUnhealthyRelays)
+}
@@ -0,0 +1,84 @@
+package lib
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRelayHealth(t *testing.T) {
+	var faults []*Event
+	events := newEventBus()
+	events.subscribe(func(_ context.Context, event *Event) { faults = append(faults, event) }, EventRelayFault)
+	health := newRelayHealth(3, time.Minute, events, testLog)
+
+	health.call("a", errors.New("connection refused"))
+	health.call("a", fmt.Errorf("%w: could not decode response", ErrValidationFailed))
+	health.success("a")
+	health.call("a", context.DeadlineExceeded)
+	health.call("a", context.Canceled)
+	health.call("a", context.DeadlineExceeded)
+	require.True(t, health.available("a"), "failures only count in a row, cancelled calls don't count")
+	require.Empty(t, faults)
+
+	health.failure("a", relayHealthMalformed, errors.New("unexpected end of JSON input"))
+	require.False(t, health.available("a"))
+	require.True(t, health.available("b"))
+	require.Len(t, faults, 1)
+	require.Equal(t, RelayFaultUnhealthy, faults[0].Fault)
+	require.Equal(t, relayHealthMalformed, faults[0].Fields["reason"])
+
+	health.failure("a", relayHealthError, nil)
+	require.Len(t, faults, 1, "relays out of the rotation aren't taken out again")
+	health.success("a")
+	require.True(t, health.available("a"))
+
+	var disabled *relayHealth
+	disabled.call("a", errors.New("connection refused"))
+	require.True(t, disabled.available("a"))
+}
+
+func TestRelayService_RelayHealth(t *testing.T) {
+	defer func() { now = time.Now }()
+	start := time.Now()
+	now = func() time.Time { return start }
+
+	var healthy int32
+	var calls int32
+	relay := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.LoadInt32(&healthy) == 0 {
+			atomic.AddInt32(&calls, 1)
+			w.Write([]byte(`{"jsonrpc":"2.0","id":"1","result":`))
+			return
+		}
+		resp, err := formatResponse(map[string]interface{}{"payloadStatus": map[string]string{"status": "VALID"}, "payloadId": "0x01"})
+		require.Nil(t, err)
+		w.Write(resp)
+	}))
+	defer relay.Close()
+
+	service, err := newRelayService(WithRelayURLs(relay.URL), WithStore(NewStore()), WithLogger(testLog), WithRelayCircuitBreaker(2, time.Minute))
+	require.Nil(t, err)
+
+	args := []interface{}{map[string]interface{}{}}
+	for i := 0; i < 3; i++ {
+		require.NotNil(t, service.ForkchoiceUpdatedV1(nil, &args, new(ForkChoiceResponse)))
+	}
+	require.Equal(t, int32(2), atomic.LoadInt32(&calls), "the relay is out of the rotation after two malformed responses")
+
+	atomic.StoreInt32(&healthy, 1)
+	service.probeUnhealthyRelays(context.Background())
+	require.False(t, service.health.available(relay.URL), "relays are probed after their cooldown")
+
+	now = func() time.Time { return start.Add(time.Minute) }
+	service.probeUnhealthyRelays(context.Background())
+	require.True(t, service.health.available(relay.URL))
+	require.Nil(t, service.ForkchoiceUpdatedV1(nil, &args, new(ForkChoiceResponse)))
+}
@@ -0,0 +1,243 @@
+package lib
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"sync"
+	"time"
+)
+
+// RelayConfig is a relay with options of its own, see WithRelays
+type RelayConfig struct {
+	// URL is the relay entry, like the entries of WithRelayURLs
+	URL string
+	// Timeout bounds every call to the relay on top of the other timeouts, 0 for none
+	Timeout time.Duration
+	// Weight is the factor the value of the bids of the relay is multiplied with when bids are ranked, e.g. 0.9 to only
+	// pick the relay over others if it bids 11% more. 0 stands for 1. The proposer is still paid the full value.
+	Weight float64
+	// Shadow relays get forkchoiceUpdated calls and validator registrations, and are asked for headers, but their bids
+	// are only logged and compared with the bids of the other relays. They never see a signed block.
+	Shadow bool
+}
+
+// relaySet is the relays of a RelayService with their options, which a RelayReloader replaces while mev-boost runs.
+// Calls in flight finish with the relays they started with.
+type relaySet struct {
+	mu       sync.RWMutex
+	urls     []string
+	timeouts map[string]time.Duration // by relay url, only relays with a timeout
+	weights  map[string]float64       // by relay url, only relays with a weight other than 1
+	shadows  map[string]bool          // by relay url, only shadow relays
+	disabled map[string]bool          // by relay url, relays turned off with the admin API, kept across reloads
+}
+
+// newRelaySet returns the relays of relayURLs, the urls the relays are identified by, with the options of relays at the
+// same index
+func newRelaySet(relayURLs []string, relays []RelayConfig) (*relaySet, error) {
+	s := new(relaySet)
+	if err := s.set(relayURLs, relays); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *relaySet) set(relayURLs []string, relays []RelayConfig) error {
+	timeouts := make(map[string]time.Duration)
+	weights := make(map[string]float64)
+	shadows := make(map[string]bool)
+	for i, relay := range relays {
+		switch {
+		case relay.Timeout < 0:
+			return fmt.Errorf("negative timeout of relay %s", relayURLs[i])
+		case relay.Weight < 0:
+			return fmt.Errorf("negative weight of relay %s", relayURLs[i])
+		}
+		if relay.Timeout > 0 {
+			timeouts[relayURLs[i]] = relay.Timeout
+		}
+		if relay.Weight > 0 && relay.Weight != 1 {
+			weights[relayURLs[i]] = relay.Weight
+		}
+		if relay.Shadow {
+			shadows[relayURLs[i]] = true
+		}
+	}
+	if len(shadows) > 0 && len(shadows) == len(relayURLs) {
+		return errors.New("all relays are shadow relays, at least one relay must be used for proposals")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.urls = append([]string(nil), relayURLs...)
+	s.timeouts = timeouts
+	s.weights = weights
+	s.shadows = shadows
+	return nil
+}
+
+// all returns the urls of the relays in configured order. set replaces the slice rather than changing it, callers
+// must not change it either.
+func (s *relaySet) all() []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.urls
+}
+
+// contains reports whether relayURL is one of the relays
+func (s *relaySet) contains(relayURL string) bool {
+	for _, url := range s.all() {
+		if url == relayURL {
+			return true
+		}
+	}
+	return false
+}
+
+// enabled reports whether the relay at relayURL wasn't turned off with the admin API
+func (s *relaySet) enabled(relayURL string) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return !s.disabled[relayURL]
+}
+
+// setEnabled turns the relay at relayURL on or off. Relays turned off get no forkchoiceUpdated calls, header requests or
+// validator registrations, but signed blocks of their bids still reach them.
+func (s *relaySet) setEnabled(relayURL string, enabled bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if enabled {
+		delete(s.disabled, relayURL)
+		return
+	}
+	if s.disabled == nil {
+		s.disabled = make(map[string]bool)
+	}
+	s.disabled[relayURL] = true
+}
+
+// timeout returns the timeout of the relay at relayURL, 0 if it has none
+func (s *relaySet) timeout(relayURL string) time.Duration {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.timeouts[relayURL]
+}
+
+// bound limits ctx to the timeout of a relay, if it has one
+func (s *relaySet) bound(ctx context.Context, relayURL string) (context.Context, context.CancelFunc) {
+	s.mu.RLock()
+	timeout, ok := s.timeouts[relayURL]
+	s.mu.RUnlock()
+	if ok {
+		return context.WithTimeout(ctx, timeout)
+	}
+	return ctx, func() {}
+}
+
+// shadow reports whether the relay at relayURL is a shadow relay, whose bids are never offered to the proposer
+func (s *relaySet) shadow(relayURL string) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.shadows[relayURL]
+}
+
+// weight returns the weight of the relay at relayURL, 1 unless configured otherwise
+func (s *relaySet) weight(relayURL string) float64 {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if weight, ok := s.weights[relayURL]; ok {
+		return weight
+	}
+	return 1
+}
+
+// weighted returns the value of a bid of a relay multiplied with the weight of the relay, for ranking bids
+func (s *relaySet) weighted(relayURL string, value *big.Int) *big.Int {
+	s.mu.RLock()
+	weight, ok := s.weights[relayURL]
+	s.mu.RUnlock()
+	if !ok {
+		return value
+	}
+	weighted, _ := new(big.Float).Mul(new(big.Float).SetInt(value), big.NewFloat(weight)).Int(nil)
+	return weighted
+}
+
+// RelayReloader replaces the relays of the router it was passed to with WithRelayReloader while it runs, e.g. to add or
+// remove relays from a config file without a restart
+type RelayReloader struct {
+	mu      sync.Mutex
+	service *RelayService
+}
+
+// NewRelayReloader returns a RelayReloader to pass to NewRouter with WithRelayReloader
+func NewRelayReloader() *RelayReloader {
+	return new(RelayReloader)
+}
+
+// Reload replaces the relays with relays. Relays that are kept keep their reputation, latencies and capabilities, and
+// payloads of bids of removed relays can still be revealed. It fails without changing the relays if an entry is invalid.
+func (r *RelayReloader) Reload(ctx context.Context, relays []RelayConfig) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.service == nil {
+		return errors.New("relay reloader isn't used by a router")
+	}
+	return r.service.setRelays(ctx, relays)
+}
+
+// setRelays replaces the relays of the service. Relay groups, tenants and validator relays refer to the relays by url,
+// they are configured at startup and rule out a reload.
+func (m *RelayService) setRelays(ctx context.Context, relays []RelayConfig) error {
+	if m.groups != nil || m.tenants != nil || m.validatorRelays != nil {
+		return errors.New("relay groups, tenants and validator relays refer to the relays, restart mev-boost to change them")
+	}
+	if len(relays) == 0 {
+		return errors.New("no relays")
+	}
+	entries := make([]string, len(relays))
+	for i, relay := range relays {
+		entries[i] = relay.URL
+	}
+	endpoints, relayURLs, err := parseRelayEntries(entries)
+	if err != nil {
+		return err
+	}
+	next, err := newRelaySet(relayURLs, relays)
+	if err != nil {
+		return err
+	}
+	var pubkeys map[string]BLSPubkey
+	if m.bidSignatures != nil {
+		if pubkeys, err = relayPubkeys(relayURLs); err != nil {
+			return err
+		}
+	}
+
+	var added, removed []string
+	for _, url := range relayURLs {
+		if !m.relays.contains(url) {
+			added = append(added, url)
+		}
+	}
+	for _, url := range m.relays.all() {
+		if !next.contains(url) {
+			removed = append(removed, url)
+		}
+	}
+	if err := m.blacklist.load(ctx, added); err != nil {
+		return fmt.Errorf("could not load relay reputations: %w", err)
+	}
+
+	m.endpoints.replace(endpoints)
+	if m.bidSignatures != nil {
+		m.bidSignatures.replace(pubkeys)
+	}
+	if err := m.relays.set(relayURLs, relays); err != nil {
+		return err // checked by newRelaySet
+	}
+	m.log.WithFields(Fields{"relays": len(relayURLs), "added": added, "removed": removed}).Info("relays reloaded")
+	return nil
+}
@@ -0,0 +1,76 @@
+package lib
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRelaySet(t *testing.T) {
+	relays, err := newRelaySet([]string{"http://relay-a", "http://relay-b"}, []RelayConfig{
+		{URL: "http://relay-a", Timeout: time.Second, Weight: 0.5},
+		{URL: "http://relay-b|http://relay-b2", Weight: 1},
+	})
+	require.Nil(t, err)
+	require.Equal(t, []string{"http://relay-a", "http://relay-b"}, relays.all())
+	require.Equal(t, big.NewInt(50), relays.weighted("http://relay-a", big.NewInt(100)))
+	require.Equal(t, big.NewInt(100), relays.weighted("http://relay-b", big.NewInt(100)))
+
+	ctx, cancel := relays.bound(context.Background(), "http://relay-a")
+	defer cancel()
+	_, ok := ctx.Deadline()
+	require.True(t, ok)
+	ctx, cancel = relays.bound(context.Background(), "http://relay-b")
+	defer cancel()
+	_, ok = ctx.Deadline()
+	require.False(t, ok)
+
+	_, err = newRelaySet([]string{"http://relay-a"}, []RelayConfig{{URL: "http://relay-a", Weight: -1}})
+	require.Error(t, err)
+}
+
+func TestRelayReloader(t *testing.T) {
+	low := newHeaderRelay(t, ExecutionPayloadWithTxRootV1{BlockHash: common.HexToHash("0x01"), BaseFeePerGas: big.NewInt(7), FeeRecipientDiff: big.NewInt(10)})
+	defer low.Close()
+	high := newHeaderRelay(t, ExecutionPayloadWithTxRootV1{BlockHash: common.HexToHash("0x02"), BaseFeePerGas: big.NewInt(7), FeeRecipientDiff: big.NewInt(15)})
+	defer high.Close()
+
+	store := NewStore()
+	store.SetForkchoiceResponse(context.Background(), "0x01", low.URL, "0x01")
+	store.SetForkchoiceResponse(context.Background(), "0x01", high.URL, "0x01")
+	reloader := NewRelayReloader()
+	router, err := NewRouter(context.Background(), WithRelays(RelayConfig{URL: low.URL}, RelayConfig{URL: high.URL, Weight: 0.5}),
+		WithStore(store), WithLogger(testLog), WithCapabilityCheckInterval(0), WithRelayReloader(reloader))
+	require.Nil(t, err)
+
+	getHeader := func() common.Hash {
+		body, err := formatRequestBody("builder_getPayloadHeaderV1", []interface{}{"0x01"})
+		require.Nil(t, err)
+		rr := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		router.ServeHTTP(rr, req)
+		require.Equal(t, http.StatusOK, rr.Code)
+		var resp struct {
+			Result ExecutionPayloadWithTxRootV1 `json:"result"`
+		}
+		require.Nil(t, json.Unmarshal(rr.Body.Bytes(), &resp))
+		return resp.Result.BlockHash
+	}
+	require.Equal(t, common.HexToHash("0x01"), getHeader(), "the weight halves the bid of the other relay")
+
+	require.Nil(t, reloader.Reload(context.Background(), []RelayConfig{{URL: low.URL}, {URL: high.URL}}))
+	require.Equal(t, common.HexToHash("0x02"), getHeader())
+
+	require.Error(t, reloader.Reload(context.Background(), []RelayConfig{{URL: low.URL, Timeout: -time.Second}}))
+	require.Error(t, reloader.Reload(context.Background(), nil))
+	require.Equal(t, common.HexToHash("0x02"), getHeader(), "failed reloads keep the relays")
+}
@@ -0,0 +1,151 @@
+package lib
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	// maxRelayTimeoutSamples is how many recent calls of a relay and method the timeout is derived from
+	maxRelayTimeoutSamples = 100
+	// minRelayTimeoutSamples is how many calls of a relay and method are needed before its timeout is lowered
+	minRelayTimeoutSamples = 20
+)
+
+// relayTimeoutPercentile and relayTimeoutHeadroom set the timeout of a relay call to twice the 95th percentile latency
+// of the recent calls of the relay and method
+const (
+	relayTimeoutPercentile = 0.95
+	relayTimeoutHeadroom   = 2
+)
+
+// relayTimeouts adjusts the timeout of relay calls to the recent latency of each relay and method, within bounds, so
+// fast relays are cut off early when they stall and slow relays get the time they usually need, without one relay taking
+// the whole request budget. Calls that time out count as taking the timeout, so a relay that slows down gets more time.
+type relayTimeouts struct {
+	min, max time.Duration
+	probes   *relayProbes // nil unless relays are probed
+
+	mu      sync.Mutex
+	samples map[relayMethod][]time.Duration // ring buffers of the latencies of recent calls
+	next    map[relayMethod]int             // position of the next sample in a full ring buffer
+}
+
+type relayMethod struct {
+	url    string
+	method string
+}
+
+func newRelayTimeouts(min, max time.Duration, probes *relayProbes) *relayTimeouts {
+	return &relayTimeouts{
+		min:     min,
+		max:     max,
+		probes:  probes,
+		samples: make(map[relayMethod][]time.Duration),
+		next:    make(map[relayMethod]int),
+	}
+}
+
+// timeout returns the current timeout of calls of method to a relay. Until enough calls were observed, it's derived from
+// the probe latency of the relay, or the upper bound if the relay wasn't probed.
+func (t *relayTimeouts) timeout(url, method string) time.Duration {
+	t.mu.Lock()
+	samples := append([]time.Duration(nil), t.samples[relayMethod{url, method}]...)
+	t.mu.Unlock()
+
+	var timeout time.Duration
+	if len(samples) >= minRelayTimeoutSamples {
+		sort.Slice(samples, func(i, j int) bool { return samples[i] < samples[j] })
+		timeout = samples[int(float64(len(samples)-1)*relayTimeoutPercentile)] * relayTimeoutHeadroom
+	} else if probe, ok := t.probes.latency(url); ok {
+		timeout = probe * relayProbeTimeoutFactor
+	} else {
+		return t.max
+	}
+	if timeout < t.min {
+		return t.min
+	}
+	if timeout > t.max {
+		return t.max
+	}
+	return timeout
+}
+
+func (t *relayTimeouts) observe(url, method string, latency time.Duration) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	key := relayMethod{url, method}
+	if samples := t.samples[key]; len(samples) < maxRelayTimeoutSamples {
+		t.samples[key] = append(samples, latency)
+		return
+	}
+	t.samples[key][t.next[key]] = latency
+	t.next[key] = (t.next[key] + 1) % maxRelayTimeoutSamples
+}
+
+// bound limits ctx to the timeout of the relay and method. The returned function ends the call with its error and
+// records its latency; failed calls are only recorded if they ran out of their timeout.
+func (t *relayTimeouts) bound(ctx context.Context, url, method string) (context.Context, func(error)) {
+	if t == nil {
+		return ctx, func(error) {}
+	}
+	timeout := t.timeout(url, method)
+	relayTimeoutSeconds.WithLabelValues(url, method).Set(timeout.Seconds())
+	bounded, cancel := context.WithTimeout(ctx, timeout)
+	sentAt := now()
+	return bounded, func(err error) {
+		defer cancel()
+		switch {
+		case err == nil:
+			t.observe(url, method, now().Sub(sentAt))
+		case errors.Is(bounded.Err(), context.DeadlineExceeded) && ctx.Err() == nil:
+			t.observe(url, method, timeout)
+		}
+	}
+}
+
+// relayMethodNames are the names of the relay methods in ParseRelayMethodTimeouts
+var relayMethodNames = map[string]string{
+	"forkchoiceUpdated": methodForkchoiceUpdated,
+	"getHeader":         methodRelayGetHeader,
+	"propose":           methodRelayProposeBlock,
+}
+
+// ParseRelayMethodTimeouts parses per-method relay timeouts like "getHeader=500ms,propose=2s", with the methods
+// forkchoiceUpdated, getHeader and propose. The empty string sets no timeouts.
+func ParseRelayMethodTimeouts(value string) (map[string]time.Duration, error) {
+	timeouts := make(map[string]time.Duration)
+	for _, entry := range strings.Split(value, ",") {
+		if entry = strings.TrimSpace(entry); entry == "" {
+			continue
+		}
+		name, duration, ok := strings.Cut(entry, "=")
+		method, known := relayMethodNames[strings.TrimSpace(name)]
+		if !ok || !known {
+			return nil, fmt.Errorf("invalid relay method timeout %q, expected forkchoiceUpdated, getHeader or propose=duration", entry)
+		}
+		timeout, err := time.ParseDuration(strings.TrimSpace(duration))
+		if err != nil || timeout <= 0 {
+			return nil, fmt.Errorf("invalid timeout of %s: %q", name, duration)
+		}
+		timeouts[method] = timeout
+	}
+	return timeouts, nil
+}
+
+// relayMethodTimeouts are fixed timeouts of relay calls by method. They apply on top of the request budget and the
+// adaptive timeouts, so a slow relay is skipped once the timeout of the method runs out, whatever it took before.
+type relayMethodTimeouts map[string]time.Duration
+
+// bound limits ctx to the timeout of method, if it has one
+func (t relayMethodTimeouts) bound(ctx context.Context, method string) (context.Context, context.CancelFunc) {
+	if timeout, ok := t[method]; ok {
+		return context.WithTimeout(ctx, timeout)
+	}
+	return ctx, func() {}
+}
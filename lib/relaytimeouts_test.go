@@ -0,0 +1,114 @@
+package lib
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRelayTimeouts(t *testing.T) {
+	timeouts := newRelayTimeouts(100*time.Millisecond, time.Second, nil)
+	require.Equal(t, time.Second, timeouts.timeout("a", methodRelayGetHeader), "the upper bound applies until enough calls were seen")
+
+	for i := 0; i < minRelayTimeoutSamples; i++ {
+		timeouts.observe("a", methodRelayGetHeader, 200*time.Millisecond)
+		timeouts.observe("b", methodRelayGetHeader, 10*time.Millisecond)
+		timeouts.observe("c", methodRelayGetHeader, 3*time.Second)
+	}
+	require.Equal(t, 400*time.Millisecond, timeouts.timeout("a", methodRelayGetHeader))
+	require.Equal(t, 100*time.Millisecond, timeouts.timeout("b", methodRelayGetHeader))
+	require.Equal(t, time.Second, timeouts.timeout("c", methodRelayGetHeader))
+	require.Equal(t, time.Second, timeouts.timeout("a", methodRelayProposeBlock), "methods are timed separately")
+
+	// the oldest samples are replaced once the ring buffer is full
+	for i := 0; i < maxRelayTimeoutSamples; i++ {
+		timeouts.observe("a", methodRelayGetHeader, 300*time.Millisecond)
+	}
+	require.Equal(t, 600*time.Millisecond, timeouts.timeout("a", methodRelayGetHeader))
+}
+
+func TestRelayTimeouts_Bound(t *testing.T) {
+	timeouts := newRelayTimeouts(10*time.Millisecond, 50*time.Millisecond, nil)
+	for i := 0; i < minRelayTimeoutSamples; i++ {
+		timeouts.observe("a", methodRelayGetHeader, time.Millisecond)
+	}
+
+	ctx, done := timeouts.bound(context.Background(), "a", methodRelayGetHeader)
+	<-ctx.Done()
+	done(ctx.Err())
+	require.Len(t, timeouts.samples[relayMethod{"a", methodRelayGetHeader}], minRelayTimeoutSamples+1, "timed out calls count with their timeout")
+
+	parent, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, done = timeouts.bound(parent, "a", methodRelayGetHeader)
+	done(errors.New("cancelled"))
+	require.Len(t, timeouts.samples[relayMethod{"a", methodRelayGetHeader}], minRelayTimeoutSamples+1, "calls failing otherwise aren't counted")
+}
+
+func TestRelayService_AdaptiveRelayTimeouts(t *testing.T) {
+	stalled := make(chan struct{})
+	relay := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-stalled
+	}))
+	defer relay.Close()
+	defer close(stalled)
+
+	service, err := newRelayService(WithRelayURLs(relay.URL), WithStore(NewStore()), WithLogger(testLog), WithAdaptiveRelayTimeouts(10*time.Millisecond, 50*time.Millisecond))
+	require.Nil(t, err)
+
+	start := time.Now()
+	_, _, err = service.requestRelay(context.Background(), relay.URL, methodRelayGetHeader, nil)
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+	require.Less(t, time.Since(start), time.Second)
+}
+
+func TestParseRelayMethodTimeouts(t *testing.T) {
+	timeouts, err := ParseRelayMethodTimeouts("getHeader=500ms, propose=2s,forkchoiceUpdated=1s")
+	require.Nil(t, err)
+	require.Equal(t, map[string]time.Duration{
+		methodRelayGetHeader:    500 * time.Millisecond,
+		methodRelayProposeBlock: 2 * time.Second,
+		methodForkchoiceUpdated: time.Second,
+	}, timeouts)
+
+	timeouts, err = ParseRelayMethodTimeouts("")
+	require.Nil(t, err)
+	require.Empty(t, timeouts)
+
+	for _, invalid := range []string{"getHeader", "getPayload=1s", "getHeader=soon", "propose=-1s"} {
+		_, err := ParseRelayMethodTimeouts(invalid)
+		require.NotNil(t, err, invalid)
+	}
+}
+
+func TestGetPayloadHeaderV1_RelayMethodTimeouts(t *testing.T) {
+	fast := newHeaderRelay(t, ExecutionPayloadWithTxRootV1{BlockHash: common.HexToHash("0x01"), BaseFeePerGas: big.NewInt(1), FeeRecipientDiff: big.NewInt(1)})
+	defer fast.Close()
+	stalled := make(chan struct{})
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-stalled
+	}))
+	defer slow.Close()
+	defer close(stalled)
+
+	store := NewStore()
+	store.SetForkchoiceResponse(context.Background(), "0x01", fast.URL, "0x01")
+	store.SetForkchoiceResponse(context.Background(), "0x01", slow.URL, "0x01")
+	timeouts := map[string]time.Duration{methodRelayGetHeader: 50 * time.Millisecond}
+	service, err := newRelayService(WithRelayURLs(fast.URL, slow.URL), WithStore(store), WithLogger(testLog), WithRelayMethodTimeouts(timeouts))
+	require.Nil(t, err)
+
+	start := time.Now()
+	payloadID := "0x01"
+	header := new(ExecutionPayloadWithTxRootV1)
+	require.Nil(t, service.GetPayloadHeaderV1(nil, &payloadID, header))
+	require.Equal(t, common.HexToHash("0x01"), header.BlockHash)
+	require.Less(t, time.Since(start), time.Second, "the slow relay is skipped after the timeout of the method")
+}
@@ -0,0 +1,175 @@
+package lib
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// pathRelayReputation resets the reputation of the relay of the url query parameter on DELETE
+const pathRelayReputation = "/mev-boost/v1/relays/reputation"
+
+// RelayReputation is the state of the underpayment blacklist of a relay, kept in the store so a restart doesn't let a
+// suspended relay back in or forget its recent payments
+type RelayReputation struct {
+	Payments    []RelayPayment `json:"payments"`              // most recent last, at most the underpayment window
+	SuspendedAt *time.Time     `json:"suspendedAt,omitempty"` // nil unless the relay is suspended
+}
+
+// RelayPayment is the verified payment of a payload revealed by a relay
+type RelayPayment struct {
+	Promised *big.Int `json:"promised"`
+	Paid     *big.Int `json:"paid"`
+}
+
+// WithReputationFile keeps relay reputations in a JSON file, so they survive restarts
+func WithReputationFile(path string) StoreOption {
+	return func(s *store) { s.reputationFile = path }
+}
+
+// GetRelayReputation implements Store
+func (s *store) GetRelayReputation(_ context.Context, relayURL string) (*RelayReputation, error) {
+	s.reputationMutex.Lock()
+	defer s.reputationMutex.Unlock()
+	if err := s.loadReputations(); err != nil {
+		return nil, err
+	}
+	return s.reputations[relayURL], nil
+}
+
+// SetRelayReputation implements Store
+func (s *store) SetRelayReputation(_ context.Context, relayURL string, reputation *RelayReputation) error {
+	s.reputationMutex.Lock()
+	defer s.reputationMutex.Unlock()
+	if err := s.loadReputations(); err != nil {
+		return err
+	}
+	if reputation == nil {
+		delete(s.reputations, relayURL)
+	} else {
+		s.reputations[relayURL] = reputation
+	}
+	return s.saveReputations()
+}
+
+// loadReputations reads the reputation file once, a missing file is an empty one
+func (s *store) loadReputations() error {
+	if s.reputationsLoaded || s.reputationFile == "" {
+		return nil
+	}
+	data, err := ioutil.ReadFile(s.reputationFile)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	if err == nil {
+		if err := json.Unmarshal(data, &s.reputations); err != nil {
+			return fmt.Errorf("invalid reputation file %s: %w", s.reputationFile, err)
+		}
+	}
+	s.reputationsLoaded = true
+	return nil
+}
+
+// saveReputations replaces the reputation file, through a temporary file so a crash doesn't leave it truncated
+func (s *store) saveReputations() error {
+	if s.reputationFile == "" {
+		return nil
+	}
+	data, err := json.MarshalIndent(s.reputations, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp, err := ioutil.TempFile(filepath.Dir(s.reputationFile), filepath.Base(s.reputationFile)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), s.reputationFile)
+}
+
+// load restores the reputations of relayURLs from the store
+func (b *relayBlacklist) load(ctx context.Context, relayURLs []string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for _, relayURL := range relayURLs {
+		reputation, err := b.store.GetRelayReputation(ctx, relayURL)
+		if err != nil {
+			return err
+		}
+		if reputation == nil {
+			continue
+		}
+		outcomes := make([]paymentOutcome, 0, len(reputation.Payments))
+		for _, payment := range reputation.Payments {
+			outcomes = append(outcomes, paymentOutcome{payment.Promised, payment.Paid})
+		}
+		b.outcomes[relayURL] = outcomes
+		if reputation.SuspendedAt != nil {
+			b.suspended[relayURL] = *reputation.SuspendedAt
+			relaySuspended.WithLabelValues(relayURL).Set(1)
+			b.log.WithFields(Fields{"url": relayURL, "suspendedAt": *reputation.SuspendedAt}).Warn("relay is still suspended from before the restart")
+		}
+	}
+	return nil
+}
+
+// persist writes the reputation of a relay to the store, the caller holds b.mu
+func (b *relayBlacklist) persist(relayURL string) {
+	reputation := &RelayReputation{Payments: make([]RelayPayment, 0, len(b.outcomes[relayURL]))}
+	for _, outcome := range b.outcomes[relayURL] {
+		reputation.Payments = append(reputation.Payments, RelayPayment{outcome.promised, outcome.paid})
+	}
+	if suspendedAt, ok := b.suspended[relayURL]; ok {
+		reputation.SuspendedAt = &suspendedAt
+	}
+	if err := b.store.SetRelayReputation(context.Background(), relayURL, reputation); err != nil {
+		b.log.WithError(err).WithField("url", relayURL).Error("could not store relay reputation")
+	}
+}
+
+// reset forgets the payments of a relay and lifts its suspension, returning whether it was suspended
+func (b *relayBlacklist) reset(ctx context.Context, relayURL string) (bool, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	_, suspended := b.suspended[relayURL]
+	if err := b.store.SetRelayReputation(ctx, relayURL, nil); err != nil {
+		return suspended, err
+	}
+	delete(b.outcomes, relayURL)
+	delete(b.suspended, relayURL)
+	relaySuspended.WithLabelValues(relayURL).Set(0)
+	return suspended, nil
+}
+
+func (m *RelayService) handleResetRelayReputation(w http.ResponseWriter, r *http.Request) {
+	relayURL := r.URL.Query().Get("url")
+	known := false
+	for _, url := range m.relays.all() {
+		known = known || url == relayURL
+	}
+	if !known {
+		respondJSON(w, http.StatusNotFound, keymanagerError{fmt.Sprintf("unknown relay %q", relayURL)})
+		return
+	}
+	suspended, err := m.blacklist.reset(r.Context(), relayURL)
+	if err != nil {
+		respondJSON(w, http.StatusInternalServerError, keymanagerError{err.Error()})
+		return
+	}
+	m.log.WithFields(Fields{"url": relayURL, "wasSuspended": suspended}).Warn("relay reputation reset by admin")
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,84 @@
+package lib
+
+import (
+	"context"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestStore_ReputationFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "reputation.json")
+	ctx := context.Background()
+
+	s := NewStore(WithReputationFile(path))
+	reputation, err := s.GetRelayReputation(ctx, "http://relay")
+	require.Nil(t, err)
+	require.Nil(t, reputation, "a missing file has no reputations")
+
+	stored := &RelayReputation{Payments: []RelayPayment{{big.NewInt(100), big.NewInt(80)}}}
+	require.Nil(t, s.SetRelayReputation(ctx, "http://relay", stored))
+	require.Nil(t, s.SetRelayReputation(ctx, "http://other", stored))
+	require.Nil(t, s.SetRelayReputation(ctx, "http://other", nil))
+
+	restarted := NewStore(WithReputationFile(path))
+	reputation, err = restarted.GetRelayReputation(ctx, "http://relay")
+	require.Nil(t, err)
+	require.Equal(t, stored, reputation)
+	reputation, err = restarted.GetRelayReputation(ctx, "http://other")
+	require.Nil(t, err)
+	require.Nil(t, reputation)
+}
+
+func TestRelayService_ReputationSurvivesRestart(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "reputation.json")
+	newService := func() *RelayService {
+		service, err := newRelayService(WithRelayURLs("http://relay"), WithStore(NewStore(WithReputationFile(path))), WithLogger(testLog),
+			WithUnderpaymentSuspension(0.05, 2))
+		require.Nil(t, err)
+		return service
+	}
+
+	service := newService()
+	service.blacklist.record("http://relay", big.NewInt(100), big.NewInt(100))
+	require.False(t, newService().blacklist.isSuspended("http://relay"))
+
+	service.blacklist.record("http://relay", big.NewInt(100), big.NewInt(0))
+	require.True(t, service.blacklist.isSuspended("http://relay"))
+	restarted := newService()
+	require.True(t, restarted.blacklist.isSuspended("http://relay"), "a restart doesn't lift the suspension")
+
+	router, err := NewRouter(context.Background(), WithRelayURLs("http://relay"), WithStore(NewStore(WithReputationFile(path))), WithLogger(testLog),
+		WithUnderpaymentSuspension(0.05, 2), WithAdminAPI("secret"))
+	require.Nil(t, err)
+
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest(http.MethodDelete, "/mev-boost/v1/relays/reputation?url=http://relay", nil))
+	require.Equal(t, http.StatusUnauthorized, rr.Code)
+
+	req := httptest.NewRequest(http.MethodDelete, "/mev-boost/v1/relays/reputation?url=http://unknown", nil)
+	req.Header.Set("Authorization", "Bearer secret")
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	require.Equal(t, http.StatusNotFound, rr.Code)
+
+	req = httptest.NewRequest(http.MethodDelete, "/mev-boost/v1/relays/reputation?url=http://relay", nil)
+	req.Header.Set("Authorization", "Bearer secret")
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	require.Equal(t, http.StatusNoContent, rr.Code)
+	require.False(t, newService().blacklist.isSuspended("http://relay"), "the reset is stored")
+
+	// without the admin API there is no way to reset a reputation
+	router, err = NewRouter(context.Background(), WithRelayURLs("http://relay"), WithStore(NewStore()), WithLogger(testLog), WithAdminToken("secret"))
+	require.Nil(t, err)
+	req = httptest.NewRequest(http.MethodDelete, "/mev-boost/v1/relays/reputation?url=http://relay", nil)
+	req.Header.Set("Authorization", "Bearer secret")
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	require.Equal(t, http.StatusNotFound, rr.Code)
+}
@@ -0,0 +1,58 @@
+package lib
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+)
+
+// requestIDHeader carries the correlation id of a request of the consensus client, in the response and in all relay
+// requests made for it
+const requestIDHeader = "X-Request-Id"
+
+// maxRequestIDLength bounds the correlation ids taken from incoming requests
+const maxRequestIDLength = 64
+
+type requestIDKey struct{}
+
+// requestIDFromContext returns the correlation id of an incoming request, empty if ctx isn't one of a request
+func requestIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// validRequestID reports whether an incoming correlation id can be passed on in headers and logs as is
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for _, c := range id {
+		if (c < '0' || c > '9') && (c < 'a' || c > 'z') && (c < 'A' || c > 'Z') && c != '-' && c != '_' && c != '.' {
+			return false
+		}
+	}
+	return true
+}
+
+// randomHex returns n random bytes in hex, or an empty string if the system randomness fails
+func randomHex(n int) string {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return ""
+	}
+	return hex.EncodeToString(b)
+}
+
+// requestIDMiddleware assigns each request a correlation id, the X-Request-Id of the consensus client if it sent a
+// valid one and a random one otherwise, and returns it in the X-Request-Id response header
+func requestIDMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(requestIDHeader)
+		if !validRequestID(id) {
+			id = randomHex(8)
+		}
+		w.Header().Set(requestIDHeader, id)
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), requestIDKey{}, id)))
+	})
+}
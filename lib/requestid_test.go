@@ -0,0 +1,60 @@
+package lib
+
+import (
+	"bytes"
+	"context"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidRequestID(t *testing.T) {
+	require.True(t, validRequestID("b1c2-d3_e4.f5"))
+	require.True(t, validRequestID(strings.Repeat("a", maxRequestIDLength)))
+	require.False(t, validRequestID(""))
+	require.False(t, validRequestID(strings.Repeat("a", maxRequestIDLength+1)))
+	require.False(t, validRequestID("id with spaces"))
+	require.False(t, validRequestID("id\r\nX-Injected: 1"))
+}
+
+func TestRouter_RequestID(t *testing.T) {
+	var relayRequestIDs []string
+	relay := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		relayRequestIDs = append(relayRequestIDs, r.Header.Get(requestIDHeader))
+		resp, err := formatResponse(ExecutionPayloadWithTxRootV1{BlockHash: common.HexToHash("0x01"), BaseFeePerGas: big.NewInt(1), FeeRecipientDiff: big.NewInt(1)})
+		require.Nil(t, err)
+		w.Write(resp)
+	}))
+	defer relay.Close()
+
+	store := NewStore()
+	store.SetForkchoiceResponse(context.Background(), "0x01", relay.URL, "0x01")
+	router, err := NewRouter(context.Background(), WithRelayURLs(relay.URL), WithStore(store), WithLogger(testLog), WithCapabilityCheckInterval(0))
+	require.Nil(t, err)
+
+	getHeader := func(requestID string) string {
+		body, err := formatRequestBody("builder_getPayloadHeaderV1", []interface{}{"0x01"})
+		require.Nil(t, err)
+		req := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		if requestID != "" {
+			req.Header.Set(requestIDHeader, requestID)
+		}
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		return rr.Header().Get(requestIDHeader)
+	}
+
+	require.Equal(t, "beacon-1", getHeader("beacon-1"), "the id of the consensus client is kept")
+	generated := getHeader("")
+	require.Len(t, generated, 16)
+	require.NotEqual(t, generated, getHeader(""), "each request gets an id of its own")
+	require.Len(t, getHeader("not a valid id"), 16)
+	require.Equal(t, "beacon-1", relayRequestIDs[0])
+	require.Equal(t, generated, relayRequestIDs[1], "relay calls carry the id of their request")
+}
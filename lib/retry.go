@@ -0,0 +1,127 @@
+package lib
+
+import (
+	"context"
+	"io"
+	"math/rand"
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var relayRetriesTotal = metricsFactory.NewCounterVec(prometheus.CounterOpts{
+	Name: "mevboost_relay_retries_total",
+	Help: "Relay calls sent again after a transient failure, by relay, method and reason (connection or status)",
+}, []string{"url", "method", "reason"})
+
+// retryableMethods are the relay methods that are safe to send twice. Signed blocks aren't sent again: a relay that
+// failed with a 5xx may still have published the block.
+var retryableMethods = map[string]bool{
+	methodRelayGetHeader:         true,
+	methodRelayRegisterValidator: true,
+}
+
+// RetryPolicy retries relay calls that failed transiently, with a connection error or a 5xx status. Only idempotent
+// calls, getPayloadHeader and registerValidator, are retried, and never past the deadline of the call.
+type RetryPolicy struct {
+	// MaxAttempts is the number of times a call is sent at most, 1 disables retries
+	MaxAttempts int
+	// BaseDelay is the wait before the first retry, it doubles with each retry
+	BaseDelay time.Duration
+	// Jitter randomizes each wait by up to this fraction of it, e.g. 0.2 for ±20%, so that calls failing at once don't
+	// hit the relay again at once
+	Jitter float64
+}
+
+// delay returns the wait before attempt, the first retry being attempt 2
+func (p RetryPolicy) delay(attempt int) time.Duration {
+	delay := p.BaseDelay << (attempt - 2)
+	if p.Jitter > 0 {
+		delay += time.Duration(float64(delay) * p.Jitter * (2*rand.Float64() - 1))
+	}
+	return delay
+}
+
+type retryContextKey struct{}
+
+// withRetries marks the relay calls of ctx to be retried by the retry policy of the relay client, as method
+func withRetries(ctx context.Context, method string) context.Context {
+	if !retryableMethods[method] {
+		return ctx
+	}
+	return context.WithValue(ctx, retryContextKey{}, method)
+}
+
+// withRelayRetries returns a copy of client whose calls marked by withRetries are retried by policy, or client itself
+// if policy is nil or doesn't retry
+func withRelayRetries(client *http.Client, policy *RetryPolicy) *http.Client {
+	if policy == nil || policy.MaxAttempts <= 1 {
+		return client
+	}
+	next := client.Transport
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	retrying := *client
+	retrying.Transport = &retryTransport{next: next, policy: *policy}
+	return &retrying
+}
+
+// retryTransport sends requests marked by withRetries again when they fail with a connection error or a 5xx status.
+// The response of the last attempt is returned.
+type retryTransport struct {
+	next   http.RoundTripper
+	policy RetryPolicy
+}
+
+func (t *retryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx := req.Context()
+	method, ok := ctx.Value(retryContextKey{}).(string)
+	if !ok || (req.Body != nil && req.GetBody == nil) {
+		return t.next.RoundTrip(req)
+	}
+	attemptReq := req
+	for attempt := 1; ; attempt++ {
+		resp, err := t.next.RoundTrip(attemptReq)
+		reason := ""
+		switch {
+		case err != nil && ctx.Err() == nil:
+			reason = "connection"
+		case err == nil && resp.StatusCode >= http.StatusInternalServerError:
+			reason = "status"
+		}
+		if reason == "" || attempt == t.policy.MaxAttempts {
+			return resp, err
+		}
+		delay := t.policy.delay(attempt + 1)
+		if deadline, ok := ctx.Deadline(); ok && now().Add(delay).After(deadline) {
+			return resp, err
+		}
+		var body io.ReadCloser
+		if req.GetBody != nil {
+			var bodyErr error
+			if body, bodyErr = req.GetBody(); bodyErr != nil {
+				return resp, err
+			}
+		}
+		if resp != nil {
+			io.Copy(io.Discard, io.LimitReader(resp.Body, 4096)) // let the connection be reused
+			resp.Body.Close()
+		}
+		relayRetriesTotal.WithLabelValues(hostOf(req), method, reason).Inc()
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			if body != nil {
+				body.Close()
+			}
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+		attemptReq = req.Clone(ctx)
+		attemptReq.Body = body
+	}
+}
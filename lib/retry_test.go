@@ -0,0 +1,100 @@
+package lib
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRelayService_RelayRetries(t *testing.T) {
+	var calls int32
+	relay := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		require.Nil(t, err)
+		require.Contains(t, string(body), `"method"`, "retries send the request body again")
+		if atomic.AddInt32(&calls, 1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		resp, err := formatResponse("0x01")
+		require.Nil(t, err)
+		w.Write(resp)
+	}))
+	defer relay.Close()
+
+	policy := RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond, Jitter: 0.5}
+	service, err := newRelayService(WithRelayURLs(relay.URL), WithStore(NewStore()), WithLogger(testLog), WithRelayRetries(policy))
+	require.Nil(t, err)
+
+	res, _, err := service.requestRelay(context.Background(), relay.URL, methodRelayGetHeader, nil)
+	require.Nil(t, err)
+	require.Nil(t, res.Error)
+	require.Equal(t, int32(3), atomic.LoadInt32(&calls), "5xx responses of idempotent calls are retried")
+
+	atomic.StoreInt32(&calls, 0)
+	_, _, err = service.requestRelay(context.Background(), relay.URL, methodRelayProposeBlock, nil)
+	require.NotNil(t, err)
+	require.Equal(t, int32(1), atomic.LoadInt32(&calls), "signed blocks aren't sent again")
+
+	atomic.StoreInt32(&calls, -10)
+	_, _, err = service.requestRelay(context.Background(), relay.URL, methodRelayGetHeader, nil)
+	require.NotNil(t, err, "the response of the last attempt is returned")
+	require.Equal(t, int32(-7), atomic.LoadInt32(&calls), "calls are sent at most MaxAttempts times")
+}
+
+func TestRelayService_RelayRetriesDeadline(t *testing.T) {
+	var calls int32
+	relay := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer relay.Close()
+
+	policy := RetryPolicy{MaxAttempts: 5, BaseDelay: time.Second}
+	service, err := newRelayService(WithRelayURLs(relay.URL), WithStore(NewStore()), WithLogger(testLog), WithRelayRetries(policy))
+	require.Nil(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, _, err = service.requestRelay(ctx, relay.URL, methodRelayGetHeader, nil)
+	require.NotNil(t, err)
+	require.Less(t, time.Since(start), 100*time.Millisecond, "retries that can't finish before the deadline aren't waited for")
+	require.Equal(t, int32(1), atomic.LoadInt32(&calls))
+}
+
+func TestRelayService_RelayRetriesConnectionErrors(t *testing.T) {
+	relay := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	url := relay.URL
+	relay.Close()
+
+	policy := RetryPolicy{MaxAttempts: 2, BaseDelay: time.Millisecond}
+	service, err := newRelayService(WithRelayURLs(url), WithStore(NewStore()), WithLogger(testLog), WithRelayRetries(policy))
+	require.Nil(t, err)
+
+	retries := relayRetriesTotal.WithLabelValues(url, methodRelayRegisterValidator, "connection")
+	before := testutil.ToFloat64(retries)
+	_, _, err = service.requestRelay(context.Background(), url, methodRelayRegisterValidator, nil)
+	require.NotNil(t, err)
+	require.Equal(t, before+1, testutil.ToFloat64(retries))
+}
+
+func TestRetryPolicy_delay(t *testing.T) {
+	policy := RetryPolicy{BaseDelay: 100 * time.Millisecond}
+	require.Equal(t, 100*time.Millisecond, policy.delay(2))
+	require.Equal(t, 400*time.Millisecond, policy.delay(4))
+
+	policy.Jitter = 0.2
+	for i := 0; i < 100; i++ {
+		delay := policy.delay(3)
+		require.GreaterOrEqual(t, delay, 160*time.Millisecond)
+		require.LessOrEqual(t, delay, 240*time.Millisecond)
+	}
+}
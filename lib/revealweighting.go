@@ -0,0 +1,121 @@
+package lib
+
+import (
+	"math"
+	"math/big"
+	"sort"
+	"sync"
+	"time"
+)
+
+// revealLatencyAlpha is the weight of the latest reveal in the moving average of the reveal latency of a relay
+const revealLatencyAlpha = 0.2
+
+// revealWeighting trades bid value for reveal latency as the attestation deadline approaches: within window of the
+// deadline, bids of relays that historically revealed payloads slower than the fastest candidate are discounted, so a
+// slightly lower bid of a fast relay wins over a bid that risks missing the slot.
+type revealWeighting struct {
+	window   time.Duration
+	tradeoff float64 // fraction of bid value given up per second of extra reveal latency at the deadline
+	curve    float64 // exponent of the urgency, 1 discounts linearly over window, higher values later and steeper
+
+	clock *clockSkew // nil unless deadlines are adjusted for clock skew
+
+	mu        sync.Mutex
+	latencies map[string]time.Duration // map[relay url]moving average of reveal latency
+}
+
+func newRevealWeighting(window time.Duration, tradeoff, curve float64) *revealWeighting {
+	return &revealWeighting{
+		window:    window,
+		tradeoff:  tradeoff,
+		curve:     curve,
+		latencies: make(map[string]time.Duration),
+	}
+}
+
+// observe records how long a relay took to reveal a payload
+func (w *revealWeighting) observe(relayURL string, latency time.Duration) {
+	if w == nil {
+		return
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	average, ok := w.latencies[relayURL]
+	if !ok {
+		w.latencies[relayURL] = latency
+		return
+	}
+	w.latencies[relayURL] = time.Duration(revealLatencyAlpha*float64(latency) + (1-revealLatencyAlpha)*float64(average))
+}
+
+// urgency is 0 until window before deadline and grows to 1 at the deadline, shaped by curve
+func (w *revealWeighting) urgency(deadline time.Time) float64 {
+	remaining := deadline.Sub(now())
+	if remaining >= w.window {
+		return 0
+	}
+	if remaining <= 0 {
+		return 1
+	}
+	return math.Pow(1-float64(remaining)/float64(w.window), w.curve)
+}
+
+// order returns candidates, sorted most valuable first, sorted by value discounted for reveal latency if the deadline of
+// their payload is within window. Relays that never revealed a payload count with their probe latency, or as the slowest
+// candidate if they weren't probed either.
+func (w *revealWeighting) order(chain *ChainConfig, candidates []BidCandidate, probes *relayProbes) []BidCandidate {
+	if w == nil || len(candidates) < 2 {
+		return candidates
+	}
+	urgency := w.urgency(w.clock.local(chain.AttestationDeadline(candidates[0].Header.Timestamp)))
+	if urgency == 0 {
+		return candidates
+	}
+
+	w.mu.Lock()
+	latencies := make([]time.Duration, len(candidates))
+	fastest, slowest := time.Duration(math.MaxInt64), time.Duration(-1)
+	for i, candidate := range candidates {
+		latency, ok := w.latencies[candidate.RelayURL]
+		if !ok {
+			latency, ok = probes.latency(candidate.RelayURL)
+		}
+		if !ok {
+			latencies[i] = -1
+			continue
+		}
+		latencies[i] = latency
+		if latency < fastest {
+			fastest = latency
+		}
+		if latency > slowest {
+			slowest = latency
+		}
+	}
+	w.mu.Unlock()
+	if slowest < 0 {
+		return candidates
+	}
+
+	scores := make([]*big.Float, len(candidates))
+	for i, candidate := range candidates {
+		if latencies[i] < 0 {
+			latencies[i] = slowest
+		}
+		discount := w.tradeoff * urgency * (latencies[i] - fastest).Seconds()
+		scores[i] = new(big.Float).SetInt(bidValue(candidate.Header))
+		scores[i].Mul(scores[i], big.NewFloat(math.Max(0, 1-discount)))
+	}
+
+	ordered := make([]BidCandidate, len(candidates))
+	indices := make([]int, len(candidates))
+	for i := range indices {
+		indices[i] = i
+	}
+	sort.SliceStable(indices, func(i, j int) bool { return scores[indices[i]].Cmp(scores[indices[j]]) > 0 })
+	for i, index := range indices {
+		ordered[i] = candidates[index]
+	}
+	return ordered
+}
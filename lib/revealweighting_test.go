@@ -0,0 +1,73 @@
+package lib
+
+import (
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRevealWeighting_observe(t *testing.T) {
+	w := newRevealWeighting(2*time.Second, 0.1, 1)
+	w.observe("https://a.example.com", time.Second)
+	require.Equal(t, time.Second, w.latencies["https://a.example.com"])
+	w.observe("https://a.example.com", 2*time.Second)
+	require.Equal(t, 1200*time.Millisecond, w.latencies["https://a.example.com"])
+
+	var disabled *revealWeighting
+	disabled.observe("https://a.example.com", time.Second)
+}
+
+func TestRevealWeighting_order(t *testing.T) {
+	defer func() { now = time.Now }()
+	slotStart := time.Unix(1700000000, 0)
+	deadline := slotStart.Add(4 * time.Second)
+
+	w := newRevealWeighting(2*time.Second, 0.1, 1)
+	w.observe("https://fast.example.com", 100*time.Millisecond)
+	w.observe("https://slow.example.com", 600*time.Millisecond)
+
+	candidate := func(relayURL string, value int64) BidCandidate {
+		return BidCandidate{relayURL, &ExecutionPayloadWithTxRootV1{Timestamp: uint64(slotStart.Unix()), FeeRecipientDiff: big.NewInt(value)}}
+	}
+	candidates := []BidCandidate{
+		candidate("https://slow.example.com", 1030),
+		candidate("https://fast.example.com", 1000),
+		candidate("https://unknown.example.com", 1020),
+	}
+	relays := func(candidates []BidCandidate) []string {
+		var urls []string
+		for _, candidate := range candidates {
+			urls = append(urls, candidate.RelayURL)
+		}
+		return urls
+	}
+
+	// far from the deadline, bids are ordered by value
+	now = func() time.Time { return slotStart }
+	require.Equal(t, candidates, w.order(MainnetChainConfig, candidates, nil))
+
+	// halfway through the window, the slow relays lose 2.5% of their value
+	now = func() time.Time { return deadline.Add(-time.Second) }
+	require.Equal(t, []string{"https://slow.example.com", "https://fast.example.com", "https://unknown.example.com"}, relays(w.order(MainnetChainConfig, candidates, nil)))
+	now = func() time.Time { return deadline.Add(-time.Second / 2) }
+	require.Equal(t, []string{"https://fast.example.com", "https://slow.example.com", "https://unknown.example.com"}, relays(w.order(MainnetChainConfig, candidates, nil)))
+
+	// a steeper curve weights later
+	steep := newRevealWeighting(2*time.Second, 0.1, 3)
+	steep.latencies = w.latencies
+	require.Equal(t, []string{"https://slow.example.com", "https://fast.example.com", "https://unknown.example.com"}, relays(steep.order(MainnetChainConfig, candidates, nil)))
+
+	// past the deadline, the full tradeoff applies
+	now = func() time.Time { return deadline.Add(time.Second) }
+	require.Equal(t, []string{"https://fast.example.com", "https://slow.example.com", "https://unknown.example.com"}, relays(w.order(MainnetChainConfig, candidates, nil)))
+
+	// relays that didn't reveal a payload yet count with their probe latency
+	probes := newRelayProbes()
+	probes.observe("https://unknown.example.com", 100*time.Millisecond)
+	require.Equal(t, []string{"https://unknown.example.com", "https://fast.example.com", "https://slow.example.com"}, relays(w.order(MainnetChainConfig, candidates, probes)))
+
+	var disabled *revealWeighting
+	require.Equal(t, candidates, disabled.order(MainnetChainConfig, candidates, nil))
+}
@@ -1,23 +1,108 @@
 package lib
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/flashbots/mev-boost/lib/builderpb"
 	"github.com/gorilla/mux"
 	"github.com/gorilla/rpc"
-	"github.com/gorilla/rpc/json"
-	"github.com/sirupsen/logrus"
+	rpcjson "github.com/gorilla/rpc/json"
 )
 
-// NewRouter creates a json rpc router that handles all methods
-func NewRouter(relayURLs []string, store Store, log *logrus.Entry) (*mux.Router, error) {
-	relay, err := newRelayService(relayURLs, store, log)
+// NewRouter creates a json rpc router that handles all methods. WithRelayURLs or WithRelays is required, all other options
+// have defaults.
+// Background work like capability checks and reconciliation stops when ctx is done.
+func NewRouter(ctx context.Context, opts ...Option) (*mux.Router, error) {
+	cfg := newRouterConfig(ctx, opts...)
+	if MinimalBuild && (cfg.graphql || cfg.pprof) {
+		return nil, errors.New("the GraphQL API and pprof are not available in minimal builds")
+	}
+	if cfg.jwtSecret != nil && (cfg.whitelabel != nil || len(cfg.tenants) > 0) {
+		return nil, errors.New("JWT authentication conflicts with whitelabel users and tenants, whose tokens are in the Authorization header too")
+	}
+	if cfg.proposerAuth != nil && len(cfg.proposerAuth.Tokens) > 0 && (cfg.jwtSecret != nil || cfg.whitelabel != nil || len(cfg.tenants) > 0) {
+		return nil, errors.New("proposer bearer tokens conflict with JWT authentication, whitelabel users and tenants, whose tokens are in the Authorization header too")
+	}
+	if cfg.builderAPI && !cfg.bidSignatures {
+		return nil, errors.New("the builder REST API requires bid signature verification, its headers carry the signature of the relay")
+	}
+	if cfg.builderAPI && (cfg.whitelabel != nil || len(cfg.tenants) > 0) {
+		return nil, errors.New("the builder REST API conflicts with whitelabel users and tenants")
+	}
+	if cfg.passthrough != nil {
+		if err := cfg.passthrough.validate(); err != nil {
+			return nil, err
+		}
+	}
+	relay, err := newRelayServiceWithConfig(cfg)
 	if err != nil {
 		return nil, err
 	}
+	if cfg.relayReloader != nil {
+		cfg.relayReloader.mu.Lock()
+		cfg.relayReloader.service = relay
+		cfg.relayReloader.mu.Unlock()
+	}
+
+	if cfg.capabilityCheckInterval > 0 {
+		relay.startRelayCapabilityChecks(ctx, cfg.capabilityCheckInterval)
+	}
+
+	if relay.probes != nil {
+		relay.startRelayProbes(ctx, cfg.relayProbeInterval)
+	}
+
+	if relay.health != nil {
+		relay.startRelayHealthChecks(ctx)
+	}
+
+	if relay.readiness != nil {
+		relay.startReadinessChecks(ctx)
+	}
+
+	if cfg.relayTransport != nil && cfg.relayTransport.Prewarm {
+		var endpoints []string
+		for _, url := range relay.relays.all() {
+			endpoints = append(endpoints, relay.endpoints.all(url)...)
+		}
+		go prewarmRelayConnections(ctx, cfg.httpClient, endpoints, relay.log)
+	}
+
+	if cfg.reconcileInterval > 0 {
+		relay.startDeliveryReconciliation(ctx, cfg.reconcileInterval)
+	}
+
+	if cfg.finalityBeacon != nil {
+		relay.startFinalizedEviction(ctx, cfg.finalityBeacon)
+	}
+
+	if relay.prefetcher != nil {
+		relay.startHeaderPrefetch(ctx)
+	}
+
+	if relay.stateDiffs != nil {
+		relay.startStateDiffVerification(ctx)
+	}
+
+	if relay.clock != nil {
+		relay.startClockSkewChecks(ctx)
+	}
+
+	if relay.verifier != nil {
+		relay.startDeliveryVerification(ctx)
+	}
+
+	if relay.audit != nil {
+		relay.startAuditLog(ctx)
+	}
 
 	rpcServer := rpc.NewServer()
 
-	rpcServer.RegisterCodec(json.NewCodec(), "application/json")
-	rpcServer.RegisterCodec(json.NewCodec(), "application/json;charset=UTF-8")
+	rpcServer.RegisterCodec(rpcjson.NewCodec(), "application/json")
+	rpcServer.RegisterCodec(rpcjson.NewCodec(), "application/json;charset=UTF-8")
 
 	if err := rpcServer.RegisterService(relay, "engine"); err != nil {
 		return nil, err
@@ -29,8 +114,148 @@ func NewRouter(relayURLs []string, store Store, log *logrus.Entry) (*mux.Router,
 		return nil, err
 	}
 
+	if cfg.grpcServer != nil {
+		builderpb.RegisterBuilderServer(cfg.grpcServer, &builderServer{service: relay})
+	}
+
+	if relay.spans != nil {
+		relay.spans.start(ctx)
+	}
+
 	router := mux.NewRouter()
-	router.Handle("/", rpcServer)
+	router.Use(requestIDMiddleware)
+	for _, middleware := range cfg.middleware {
+		router.Use(mux.MiddlewareFunc(middleware))
+	}
+	if cfg.traceContext {
+		router.Use(traceContextMiddleware)
+	}
+	if relay.spans != nil {
+		router.Use(relay.spans.middleware)
+	}
+	if cfg.maxConcurrentRequests > 0 {
+		queue := newPriorityQueue(cfg.maxConcurrentRequests)
+		queue.shedQueued, queue.shedWait = cfg.shedQueued, cfg.shedWait
+		router.Use(func(next http.Handler) http.Handler { return queue.handler(relay.requestPriority, next) })
+	}
+	if users := cfg.whitelabel; users != nil {
+		router.Use(users.middleware)
+		router.Handle("/", contentTypeHandler(cfg.strictContentTypes, cfg.log, jsonLimitHandler(cfg.jsonLimits, batchHandler(cfg.maxRequestBodySize, relayMethodsOnly(rpcServer)))))
+		router.HandleFunc(pathProposerPayloadDelivered, relay.handleProposerPayloadDelivered).Methods(http.MethodGet)
+		return router, nil
+	}
+	if cors := cfg.cors; cors != nil {
+		router.Use(cors.middleware)
+		router.Methods(http.MethodOptions).HandlerFunc(cors.preflight)
+	}
+	var served http.Handler = rpcServer
+	if cfg.passthrough != nil {
+		served = cfg.passthrough.handler(rpcServer.HasMethod, cfg.log, rpcServer)
+	}
+	var rpcHandler http.Handler = clientCompatHandler(cfg.clientCompat, cfg.log, deprecatedMethodHandler(cfg.deprecatedMethods, cfg.log, served))
+	var webSocketHandler http.Handler = http.HandlerFunc(relay.handleWebSocket)
+	if relay.tenants != nil {
+		rpcHandler = relay.tenants.handler(rpcHandler)
+		webSocketHandler = relay.tenants.handler(webSocketHandler)
+	}
+	if len(cfg.methodRateLimits) > 0 {
+		rpcHandler = newMethodRateLimiter(cfg.methodRateLimits).handler(rpcHandler)
+	}
+	rpcHandler = contentTypeHandler(cfg.strictContentTypes, cfg.log, jsonLimitHandler(cfg.jsonLimits, batchHandler(cfg.maxRequestBodySize, rpcHandler)))
+	if cfg.jwtSecret != nil {
+		rpcHandler = jwtHandler(cfg.jwtSecret, rpcHandler)
+		webSocketHandler = jwtHandler(cfg.jwtSecret, webSocketHandler)
+	}
+	if cfg.proposerAuth != nil {
+		rpcHandler = cfg.proposerAuth.handler(rpcHandler)
+		webSocketHandler = cfg.proposerAuth.handler(webSocketHandler)
+	}
+	if cfg.webSocketRPC {
+		relay.webSocketRPC = router
+	}
+	if relay.pushInterval > 0 || relay.webSocketRPC != nil {
+		router.Handle("/", webSocketHandler).Methods(http.MethodGet).MatcherFunc(isWebSocketUpgrade)
+	}
+	router.Handle("/", rpcHandler)
+	router.HandleFunc(pathLiveness, handleLiveness).Methods(http.MethodGet)
+	if relay.readiness != nil {
+		router.HandleFunc(pathReadiness, relay.handleReadiness).Methods(http.MethodGet)
+		router.HandleFunc(pathSpecStatus, relay.handleReadiness).Methods(http.MethodGet)
+	}
+	if cfg.builderAPI {
+		if relay.readiness == nil {
+			router.HandleFunc(pathSpecStatus, handleLiveness).Methods(http.MethodGet)
+		}
+		builderRoute := func(handler http.Handler) http.Handler {
+			if cfg.jwtSecret != nil {
+				handler = jwtHandler(cfg.jwtSecret, handler)
+			}
+			if cfg.proposerAuth != nil {
+				handler = cfg.proposerAuth.handler(handler)
+			}
+			return handler
+		}
+		router.Handle(pathBuilderHeader, builderRoute(http.HandlerFunc(relay.handleGetHeader))).Methods(http.MethodGet)
+		router.Handle(pathBuilderBlindedBlocks, builderRoute(jsonLimitHandler(cfg.jsonLimits, http.HandlerFunc(relay.handleSubmitBlindedBlock)))).Methods(http.MethodPost)
+		router.Handle(pathBuilderValidators, builderRoute(jsonLimitHandler(cfg.jsonLimits, http.HandlerFunc(relay.handleRegisterValidators)))).Methods(http.MethodPost)
+	}
+	router.HandleFunc("/mev-boost/v1/relays/accounting", relay.handleRelayAccounting).Methods(http.MethodGet)
+	router.HandleFunc("/mev-boost/v1/deliveries", relay.handleDeliveries).Methods(http.MethodGet)
+	router.HandleFunc("/mev-boost/v1/deliveries/discrepancies", relay.handleDeliveryDiscrepancies).Methods(http.MethodGet)
+	router.HandleFunc("/mev-boost/v1/bids", relay.handleBids).Methods(http.MethodGet)
+	router.HandleFunc("/mev-boost/v1/proposals", relay.handleProposals).Methods(http.MethodGet)
+	router.HandleFunc(pathOpenRPC, handleOpenRPC(relay.openRPCDocument(cfg.deprecatedMethods))).Methods(http.MethodGet)
+	router.HandleFunc(pathProposalInterchange, relay.handleExportProposals).Methods(http.MethodGet)
+	var events http.Handler = http.HandlerFunc(relay.handleEvents)
+	if cfg.adminToken != "" {
+		events = BearerTokenMiddleware(cfg.adminToken)(events)
+	}
+	router.Handle("/mev-boost/v1/events", events).Methods(http.MethodGet)
+	if relay.missedValues != nil {
+		var missed http.Handler = http.HandlerFunc(relay.handleMissedValues)
+		if cfg.adminToken != "" {
+			missed = BearerTokenMiddleware(cfg.adminToken)(missed)
+		}
+		router.Handle("/mev-boost/v1/bids/missed", missed).Methods(http.MethodGet)
+	}
+	if cfg.relayTimings {
+		router.HandleFunc("/mev-boost/v1/relays/timings", relay.handleRelayTimings).Methods(http.MethodGet)
+	}
+	if cfg.graphql {
+		router.Handle("/mev-boost/v1/graphql", newGraphQLHandler(relay)).Methods(http.MethodPost)
+	}
+	if cfg.aggregator {
+		router.HandleFunc(pathProposerPayloadDelivered, relay.handleProposerPayloadDelivered).Methods(http.MethodGet)
+	}
+	if !cfg.separateAdmin {
+		handleAdminEndpoints(router, cfg.adminToken, cfg.pprof)
+	}
+
+	if token := cfg.adminAPIToken; token != "" {
+		relay.handleAdminAPI(router, token, cfg.logLevel)
+		router.HandleFunc(pathProposalInterchange, requireBearerToken(token, relay.handleImportProposals)).Methods(http.MethodPost)
+		router.HandleFunc(pathRelayReputation, requireBearerToken(token, relay.handleResetRelayReputation)).Methods(http.MethodDelete)
+	}
+
+	if token := cfg.preferencesAPIToken; token != "" {
+		router.HandleFunc("/eth/v1/validator/preferences", requireBearerToken(token, relay.handleListPreferences)).Methods(http.MethodGet)
+		router.HandleFunc("/eth/v1/validator/{pubkey}/preferences", requireBearerToken(token, relay.handleGetPreferences)).Methods(http.MethodGet)
+		router.HandleFunc("/eth/v1/validator/{pubkey}/preferences", requireBearerToken(token, relay.handleSetPreferences)).Methods(http.MethodPost)
+		router.HandleFunc("/eth/v1/validator/{pubkey}/preferences", requireBearerToken(token, relay.handleDeletePreferences)).Methods(http.MethodDelete)
+		router.HandleFunc("/eth/v1/validator/{pubkey}/feerecipient", requireBearerToken(token, relay.handleGetFeeRecipient)).Methods(http.MethodGet)
+		router.HandleFunc("/eth/v1/validator/{pubkey}/feerecipient", requireBearerToken(token, relay.handleSetFeeRecipient)).Methods(http.MethodPost)
+		router.HandleFunc("/eth/v1/validator/{pubkey}/feerecipient", requireBearerToken(token, relay.handleDeleteFeeRecipient)).Methods(http.MethodDelete)
+		router.HandleFunc("/eth/v1/validator/{pubkey}/gas_limit", requireBearerToken(token, relay.handleGetGasLimit)).Methods(http.MethodGet)
+		router.HandleFunc("/eth/v1/validator/{pubkey}/gas_limit", requireBearerToken(token, relay.handleSetGasLimit)).Methods(http.MethodPost)
+		router.HandleFunc("/eth/v1/validator/{pubkey}/gas_limit", requireBearerToken(token, relay.handleDeleteGasLimit)).Methods(http.MethodDelete)
+	}
 
 	return router, nil
 }
+
+// respondJSON writes v as the JSON body of a response with the given status code
+func respondJSON(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(v) // the client is gone if writing fails, nobody to tell
+}
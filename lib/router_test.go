@@ -2,18 +2,18 @@ package lib
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"io/ioutil"
 	"math/big"
 	"net/http"
 	"net/http/httptest"
-	"strings"
+	"sync"
 	"testing"
 
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/common/hexutil"
 	"github.com/ethereum/go-ethereum/eth/catalyst"
-	"github.com/sirupsen/logrus"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -83,7 +83,7 @@ func TestNewRouter(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			_, err := NewRouter(tt.relayURLs, NewStore(), logrus.WithField("testing", true))
+			_, err := NewRouter(context.Background(), WithRelayURLs(tt.relayURLs...), WithStore(NewStore()), WithLogger(testLog))
 			if (err != nil) != tt.wantErr {
 				t.Errorf("NewRouter() error = %v, wantErr %v", err, tt.wantErr)
 				return
@@ -158,11 +158,11 @@ func testHTTPMethodWithDifferentRPC(t *testing.T, jsonRPCMethodCaller string, js
 
 		if store == nil {
 			store = NewStore()
-			store.SetForkchoiceResponse("0x01", mockRelayHTTP.URL, "0x01")
+			store.SetForkchoiceResponse(context.Background(), "0x01", mockRelayHTTP.URL, "0x01")
 		}
 
 		// Create the router pointing at the mock server
-		r, err := NewRouter([]string{mockRelayHTTP.URL}, store, logrus.WithField("testing", true))
+		r, err := NewRouter(context.Background(), WithRelayURLs(mockRelayHTTP.URL), WithStore(store), WithLogger(testLog))
 		require.Nil(t, err, "error creating router")
 
 		// Craft a JSON-RPC request to the router
@@ -225,22 +225,25 @@ func TestMevService_ForkChoiceUpdated(t *testing.T) {
 }
 
 func TestRelayService_ProposeBlindedBlockV1(t *testing.T) {
+	payload := withBlockHash(ExecutionPayloadWithTxRootV1{
+		BlockHash:        common.HexToHash("0x0000000000000000000000000000000000000000000000000000000000000001"),
+		BaseFeePerGas:    big.NewInt(4),
+		Transactions:     &[]string{},
+		TransactionsRoot: common.HexToHash("0x7ffe241ea60187fdb0187bfa22de35d1f9bed7ab061d9401fd47e34a54fbede1"),
+		FeeRecipientDiff: big.NewInt(0),
+	})
+
 	tests := []httpTest{
 		{
 			"basic success",
 			[]interface{}{SignedBlindedBeaconBlock{
 				Message: &BlindedBeaconBlock{
-					ParentRoot: "0x0000000000000000000000000000000000000000000000000000000000000001",
+					ParentRoot: common.HexToHash("0x0000000000000000000000000000000000000000000000000000000000000001"),
+					Body:       &BlindedBeaconBlockBody{ExecutionPayloadHeader: payload.Header()},
 				},
-				Signature: "0x0000000000000000000000000000000000000000000000000000000000000002",
 			}},
 
-			ExecutionPayloadWithTxRootV1{
-				BlockHash:        common.HexToHash("0x0000000000000000000000000000000000000000000000000000000000000001"),
-				BaseFeePerGas:    big.NewInt(4),
-				Transactions:     &[]string{},
-				FeeRecipientDiff: big.NewInt(0),
-			},
+			payload,
 			nil,
 			200,
 			200,
@@ -253,6 +256,91 @@ func TestRelayService_ProposeBlindedBlockV1(t *testing.T) {
 	}
 }
 
+func TestRelayService_ProposeBlindedBlockV1_RelayOfBid(t *testing.T) {
+	payload := withBlockHash(ExecutionPayloadWithTxRootV1{
+		BlockHash:        common.HexToHash("0x01"),
+		BaseFeePerGas:    big.NewInt(4),
+		Transactions:     &[]string{},
+		TransactionsRoot: common.HexToHash("0x7ffe241ea60187fdb0187bfa22de35d1f9bed7ab061d9401fd47e34a54fbede1"),
+		FeeRecipientDiff: big.NewInt(0),
+	})
+	var mu sync.Mutex
+	calls := make(map[string]int)
+	newRelay := func() *httptest.Server {
+		var server *httptest.Server
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			mu.Lock()
+			calls[server.URL]++
+			mu.Unlock()
+			resp, err := formatResponse(payload)
+			require.Nil(t, err)
+			w.Write(resp)
+		}))
+		return server
+	}
+	relayA, relayB := newRelay(), newRelay()
+	defer relayA.Close()
+	defer relayB.Close()
+
+	store := NewStore()
+	service, err := newRelayService(WithRelayURLs(relayA.URL, relayB.URL), WithStore(store), WithLogger(testLog))
+	require.Nil(t, err)
+	block := &SignedBlindedBeaconBlock{Message: &BlindedBeaconBlock{Body: &BlindedBeaconBlockBody{ExecutionPayloadHeader: payload.Header()}}}
+
+	store.SetBid(context.Background(), payload.BlockHash, &Bid{RelayURL: relayB.URL, Value: big.NewInt(1)})
+	var result ExecutionPayloadWithTxRootV1
+	require.Nil(t, service.ProposeBlindedBlockV1(nil, block, &result))
+	require.Equal(t, payload.BlockHash, result.BlockHash)
+	require.Equal(t, map[string]int{relayB.URL: 1}, calls, "only the relay of the bid gets the signed block")
+}
+
+func TestRelayService_ProposeBlindedBlockV1_FanOut(t *testing.T) {
+	payload := withBlockHash(ExecutionPayloadWithTxRootV1{
+		BlockHash:        common.HexToHash("0x01"),
+		BaseFeePerGas:    big.NewInt(4),
+		Transactions:     &[]string{},
+		TransactionsRoot: common.HexToHash("0x7ffe241ea60187fdb0187bfa22de35d1f9bed7ab061d9401fd47e34a54fbede1"),
+		FeeRecipientDiff: big.NewInt(0),
+	})
+	var mu sync.Mutex
+	calls := make(map[string]int)
+	newRelay := func(status int) *httptest.Server {
+		var server *httptest.Server
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			mu.Lock()
+			calls[server.URL]++
+			mu.Unlock()
+			if status != http.StatusOK {
+				w.WriteHeader(status)
+				return
+			}
+			resp, err := formatResponse(payload)
+			require.Nil(t, err)
+			w.Write(resp)
+		}))
+		return server
+	}
+	relayA, relayB, relayC := newRelay(http.StatusInternalServerError), newRelay(http.StatusOK), newRelay(http.StatusOK)
+	defer relayA.Close()
+	defer relayB.Close()
+	defer relayC.Close()
+
+	store := NewStore()
+	service, err := newRelayService(WithRelayURLs(relayA.URL, relayB.URL, relayC.URL), WithStore(store), WithLogger(testLog), WithProposalFanOut())
+	require.Nil(t, err)
+	block := &SignedBlindedBeaconBlock{Message: &BlindedBeaconBlock{Slot: 7, Body: &BlindedBeaconBlockBody{ExecutionPayloadHeader: payload.Header()}}}
+
+	store.SetBid(context.Background(), payload.BlockHash, &Bid{RelayURL: relayA.URL, Value: big.NewInt(2)})
+	service.bids.add(&ArchivedBid{Slot: 7, RelayURL: relayA.URL, BlockHash: payload.BlockHash, Value: big.NewInt(2), Result: BidResultWon})
+	service.bids.add(&ArchivedBid{Slot: 7, RelayURL: relayB.URL, BlockHash: payload.BlockHash, Value: big.NewInt(1), Result: BidResultValid})
+	service.bids.add(&ArchivedBid{Slot: 6, RelayURL: relayC.URL, BlockHash: payload.BlockHash, Value: big.NewInt(1), Result: BidResultValid})
+	var result ExecutionPayloadWithTxRootV1
+	require.Nil(t, service.ProposeBlindedBlockV1(nil, block, &result))
+	require.Equal(t, payload.BlockHash, result.BlockHash, "the payload is revealed by another relay that bid for the slot")
+	require.Equal(t, 0, calls[relayC.URL], "relays that didn't bid for the slot don't get the signed block")
+	require.Equal(t, 1, calls[relayB.URL])
+}
+
 func TestRelayService_GetPayloadHeaderV1(t *testing.T) {
 	tests := []httpTest{
 		{
@@ -279,18 +367,14 @@ func TestRelayService_GetPayloadHeaderV1(t *testing.T) {
 func TestRelayService_GetPayloadAndPropose(t *testing.T) {
 	store := NewStore()
 
-	payload := ExecutionPayloadWithTxRootV1{
+	payload := withBlockHash(ExecutionPayloadWithTxRootV1{
 		BlockHash:        common.HexToHash("0x0000000000000000000000000000000000000000000000000000000000000001"),
 		StateRoot:        common.HexToHash("0x0000000000000000000000000000000000000000000000000000000000000003"),
 		BaseFeePerGas:    big.NewInt(4),
 		Transactions:     &[]string{},
 		TransactionsRoot: common.HexToHash("0x7ffe241ea60187fdb0187bfa22de35d1f9bed7ab061d9401fd47e34a54fbede1"),
 		FeeRecipientDiff: big.NewInt(0),
-	}
-	payloadBytes, err := json.Marshal(payload)
-	// make block_hash be snake_case
-	payloadBytes = []byte(strings.Replace(string(payloadBytes), "blockHash", "block_hash", -1))
-	require.Nil(t, err)
+	})
 
 	tests := []httpTestWithMethods{
 		{
@@ -313,11 +397,10 @@ func TestRelayService_GetPayloadAndPropose(t *testing.T) {
 				"block cache hit",
 				[]interface{}{SignedBlindedBeaconBlock{
 					Message: &BlindedBeaconBlock{
-						ParentRoot: "0x0000000000000000000000000000000000000000000000000000000000000001",
-						StateRoot:  "0x0000000000000000000000000000000000000000000000000000000000000003",
-						Body:       []byte(`{"execution_payload_header": ` + string(payloadBytes) + `}`),
+						ParentRoot: common.HexToHash("0x0000000000000000000000000000000000000000000000000000000000000001"),
+						StateRoot:  common.HexToHash("0x0000000000000000000000000000000000000000000000000000000000000003"),
+						Body:       &BlindedBeaconBlockBody{ExecutionPayloadHeader: payload.Header()},
 					},
-					Signature: "0x0000000000000000000000000000000000000000000000000000000000000002",
 				}},
 				payload,
 				nil,
@@ -336,60 +419,58 @@ func TestRelayService_GetPayloadAndPropose(t *testing.T) {
 	}
 }
 
-func TestRelayService_GetPayloadAndProposeCamelCase(t *testing.T) {
-	store := NewStore()
-
-	payload := ExecutionPayloadWithTxRootV1{
+func TestRelayService_ProposeCamelCaseBody(t *testing.T) {
+	payload := withBlockHash(ExecutionPayloadWithTxRootV1{
 		BlockHash:        common.HexToHash("0x0000000000000000000000000000000000000000000000000000000000000001"),
 		StateRoot:        common.HexToHash("0x0000000000000000000000000000000000000000000000000000000000000003"),
 		BaseFeePerGas:    big.NewInt(4),
 		Transactions:     &[]string{},
 		TransactionsRoot: common.HexToHash("0x7ffe241ea60187fdb0187bfa22de35d1f9bed7ab061d9401fd47e34a54fbede1"),
 		FeeRecipientDiff: big.NewInt(0),
-	}
+	})
 	payloadBytes, err := json.Marshal(payload)
 	require.Nil(t, err)
 
-	tests := []httpTestWithMethods{
-		{
-			httpTest{
-				"get payload and store it",
-				[]interface{}{"0x1"},
-				payload,
-				nil,
-				200,
-				200,
-				0,
-				true,
-			},
-			"builder_getPayloadHeaderV1",
-			"relay_getPayloadHeaderV1",
-			true, // this endpoint transforms Transactions into TransactionsRoot, so skip equality check
-		},
-		{
-			httpTest{
-				"block cache hit",
-				[]interface{}{SignedBlindedBeaconBlock{
-					Message: &BlindedBeaconBlock{
-						ParentRoot: "0x0000000000000000000000000000000000000000000000000000000000000001",
-						StateRoot:  "0x0000000000000000000000000000000000000000000000000000000000000003",
-						Body:       []byte(`{"executionPayloadHeader": ` + string(payloadBytes) + `}`),
-					},
-					Signature: "0x0000000000000000000000000000000000000000000000000000000000000002",
-				}},
-				payload,
-				nil,
-				200,
-				200,
-				1,
-				false,
-			},
-			"builder_proposeBlindedBlockV1",
-			"relay_proposeBlindedBlockV1",
-			false,
-		},
-	}
-	for _, tt := range tests {
-		testHTTPMethodWithDifferentRPC(t, tt.jsonRPCMethodCaller, tt.jsonRPCMethodRelayProxy, &tt.httpTest, tt.skipRespCheck, store)
+	var relayRequest struct {
+		Params []SignedBlindedBeaconBlock `json:"params"`
 	}
+	relay := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := ioutil.ReadAll(r.Body)
+		require.Nil(t, err)
+		require.Contains(t, string(body), `"execution_payload_header":{`, "relays get the beacon API encoding")
+		require.Nil(t, json.Unmarshal(body, &relayRequest))
+		resp, err := formatResponse(payload)
+		require.Nil(t, err)
+		w.Write(resp)
+	}))
+	defer relay.Close()
+
+	router, err := NewRouter(context.Background(), WithRelayURLs(relay.URL), WithStore(NewStore()), WithLogger(testLog))
+	require.Nil(t, err)
+
+	body, err := formatRequestBody("builder_proposeBlindedBlockV1", []interface{}{json.RawMessage(`{
+		"message": {
+			"slot": "0",
+			"proposer_index": "0",
+			"parent_root": "0x0000000000000000000000000000000000000000000000000000000000000001",
+			"state_root": "0x0000000000000000000000000000000000000000000000000000000000000003",
+			"body": {"executionPayloadHeader": ` + string(payloadBytes) + `}
+		},
+		"signature": "` + BLSSignature{}.String() + `"
+	}`)})
+	require.Nil(t, err)
+	req := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(body))
+	req.Header.Add("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	rpcResp, err := parseRPCResponse(w.Body.Bytes())
+	require.Nil(t, err)
+	require.Nil(t, rpcResp.Error)
+	require.Len(t, relayRequest.Params, 1)
+	expectedRoot, err := payload.Header().HashTreeRoot()
+	require.Nil(t, err)
+	root, err := relayRequest.Params[0].Message.Body.ExecutionPayloadHeader.HashTreeRoot()
+	require.Nil(t, err)
+	require.Equal(t, expectedRoot, root)
 }
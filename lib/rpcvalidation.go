@@ -0,0 +1,56 @@
+package lib
+
+import (
+	"errors"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+// maxPayloadIDSize is the size of the payload ids of the engine API, shorter ones are accepted for relays that trim
+// leading zeros
+const maxPayloadIDSize = 8
+
+// errInvalidParams is returned for calls whose params decoded, but aren't valid arguments of the method
+var errInvalidParams = errors.New("invalid params")
+
+// parsePayloadID decodes the payload id param of getPayloadHeader
+func parsePayloadID(param string) (*hexutil.Bytes, error) {
+	payloadID := new(hexutil.Bytes)
+	if err := payloadID.UnmarshalText([]byte(param)); err != nil {
+		return nil, newMethodError(errInvalidParams, "invalid payload id %q: %v", param, err)
+	}
+	if len(*payloadID) == 0 || len(*payloadID) > maxPayloadIDSize {
+		return nil, newMethodError(errInvalidParams, "payload id %s must have 1 to %d bytes", payloadID, maxPayloadIDSize)
+	}
+	return payloadID, nil
+}
+
+// validateForkchoiceParams checks the params of forkchoiceUpdated: a forkchoice state and optional payload attributes,
+// before they're forwarded to the relays
+func validateForkchoiceParams(params []interface{}) error {
+	if len(params) < 1 || len(params) > 2 {
+		return newMethodError(errInvalidParams, "forkchoiceUpdated takes a forkchoice state and payload attributes, got %d params", len(params))
+	}
+	state, err := parseForkchoiceState(params)
+	if err != nil {
+		return newMethodError(errInvalidParams, "invalid forkchoice state: %v", err)
+	}
+	if state == nil {
+		return newMethodError(errInvalidParams, "missing forkchoice state")
+	}
+	if _, err := parsePayloadAttributes(params); err != nil {
+		return newMethodError(errInvalidParams, "invalid payload attributes: %v", err)
+	}
+	return nil
+}
+
+// validateSignedBlindedBlock checks that a signed blinded block has the parts proposeBlindedBlock reads
+func validateSignedBlindedBlock(block *SignedBlindedBeaconBlock) error {
+	switch {
+	case block == nil || block.Message == nil:
+		return newMethodError(errInvalidParams, "SignedBlindedBeaconBlock or SignedBlindedBeaconBlock.Message is nil")
+	case block.Message.Body == nil || block.Message.Body.ExecutionPayloadHeader == nil:
+		return newMethodError(errInvalidParams, "block body has no execution payload header")
+	}
+	return nil
+}
@@ -0,0 +1,90 @@
+package lib
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRouter_RPCErrors(t *testing.T) {
+	router, err := NewRouter(context.Background(), WithRelayURLs("http://127.0.0.1:1"), WithStore(NewStore()), WithLogger(testLog), WithCapabilityCheckInterval(0), WithMaxRequestBodySize(1024))
+	require.Nil(t, err)
+
+	for _, tc := range []struct {
+		name   string
+		body   string
+		status int
+		code   int
+	}{
+		{"parse error", `{"jsonrpc":"2.0","id":1,`, http.StatusBadRequest, rpcErrParse},
+		{"unknown method", `{"jsonrpc":"2.0","id":1,"method":"engine_fooV1","params":[]}`, http.StatusBadRequest, rpcErrMethodNotFound},
+		{"method without service", `{"jsonrpc":"2.0","id":1,"method":"foo","params":[]}`, http.StatusBadRequest, rpcErrMethodNotFound},
+		{"missing params", `{"jsonrpc":"2.0","id":1,"method":"engine_forkchoiceUpdatedV1"}`, http.StatusBadRequest, rpcErrInvalidRequest},
+		{"params of the wrong type", `{"jsonrpc":"2.0","id":1,"method":"builder_getPayloadHeaderV1","params":[1]}`, http.StatusBadRequest, rpcErrInvalidParams},
+		{"short hash", `{"jsonrpc":"2.0","id":1,"method":"builder_proposeBlindedBlockV1","params":[{"message":{"slot":"1","body":{"execution_payload_header":{"block_hash":"0x01"}}}}]}`, http.StatusBadRequest, rpcErrInvalidParams},
+		{"long payload id", `{"jsonrpc":"2.0","id":1,"method":"builder_getPayloadHeaderV1","params":["0x010203040506070809"]}`, http.StatusOK, rpcErrInvalidParams},
+		{"missing block", `{"jsonrpc":"2.0","id":1,"method":"builder_proposeBlindedBlockV1","params":[{"message":null}]}`, http.StatusOK, rpcErrInvalidParams},
+		{"missing forkchoice state", `{"jsonrpc":"2.0","id":1,"method":"engine_forkchoiceUpdatedV1","params":[null,null]}`, http.StatusOK, rpcErrInvalidParams},
+		{"short head", `{"jsonrpc":"2.0","id":1,"method":"engine_forkchoiceUpdatedV1","params":[{"headBlockHash":"0x01"}]}`, http.StatusOK, rpcErrInvalidParams},
+		{"too large", `{"jsonrpc":"2.0","id":1,"method":"builder_getPayloadHeaderV1","params":["` + strings.Repeat("0", 1024) + `"]}`, http.StatusRequestEntityTooLarge, rpcErrInvalidRequest},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(tc.body))
+			req.Header.Set("Content-Type", "application/json")
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, req)
+			require.Equal(t, tc.status, rr.Code)
+
+			var resp struct {
+				ID    json.RawMessage `json:"id"`
+				Error *rpcError       `json:"error"`
+			}
+			require.Nil(t, json.Unmarshal(rr.Body.Bytes(), &resp), rr.Body.String())
+			require.NotNil(t, resp.Error)
+			require.Equal(t, tc.code, resp.Error.Code, resp.Error.Message)
+			if tc.code != rpcErrParse && tc.status != http.StatusRequestEntityTooLarge {
+				require.Equal(t, "1", string(resp.ID))
+			}
+		})
+	}
+}
+
+func Test_parsePayloadID(t *testing.T) {
+	payloadID, err := parsePayloadID("0x0102030405060708")
+	require.Nil(t, err)
+	require.Equal(t, "0x0102030405060708", payloadID.String())
+	_, err = parsePayloadID("0x01")
+	require.Nil(t, err)
+
+	for _, param := range []string{"", "0x", "01", "0x010203040506070809", "0xzz"} {
+		_, err := parsePayloadID(param)
+		require.True(t, errors.Is(err, errInvalidParams), param)
+	}
+}
+
+func Test_batchHandler_NoTruncation(t *testing.T) {
+	var served []byte
+	handler := batchHandler(16, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var err error
+		served, err = io.ReadAll(r.Body)
+		require.Nil(t, err)
+		w.Write([]byte(`{}`))
+	}))
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"method":"a.b"}`)))
+	require.Equal(t, http.StatusOK, rr.Code)
+	require.Equal(t, `{"method":"a.b"}`, string(served), "bodies of the limit are served")
+
+	served = nil
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"method":"a.bc"}`)))
+	require.Equal(t, http.StatusRequestEntityTooLarge, rr.Code)
+	require.Nil(t, served, "bodies over the limit are rejected, not truncated")
+}
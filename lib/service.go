@@ -7,42 +7,418 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"io/ioutil"
+	"math/big"
 	"net/http"
+	"sort"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/common/hexutil"
-	"github.com/ethereum/go-ethereum/core/types"
-	"github.com/flashbots/mev-boost/lib/txroot"
-	"github.com/sirupsen/logrus"
 )
 
 var httpClient = http.Client{
-	Timeout: 5 * time.Second,
+	Timeout:   5 * time.Second,
+	Transport: NewRelayTransport(DefaultRelayTransportConfig),
+}
+
+// maxRelayResponseSize bounds the memory a single relay response can use, it's the default limit of payload responses
+var maxRelayResponseSize int64 = 16 << 20
+
+// maxRelayHeaderResponseSize is the default limit of responses other than payloads. Headers are small, but some relays
+// include the transactions of the payload.
+var maxRelayHeaderResponseSize int64 = 8 << 20
+
+// responseLimit bounds a relay response, which is aborted once it exceeds size bytes or the JSON limits
+type responseLimit struct {
+	size int64
+	json jsonLimits
+}
+
+// defaultResponseLimit is the limit of responses of other servers than relays
+var defaultResponseLimit = responseLimit{size: maxRelayResponseSize, json: defaultJSONLimits}
+
+// reader applies the limit to body
+func (l responseLimit) reader(body io.Reader) io.Reader {
+	return newJSONLimitReader(newCappedReader(body, l.size), l.json)
+}
+
+// relayResponseLimits are the limits relay responses are aborted at, so a compromised relay can't exhaust memory
+type relayResponseLimits struct {
+	header  int64
+	payload int64
+	json    jsonLimits
+}
+
+// forMethod returns the response limit of a relay method
+func (l relayResponseLimits) forMethod(method string) responseLimit {
+	if method == methodRelayProposeBlock {
+		return responseLimit{size: l.payload, json: l.json}
+	}
+	return responseLimit{size: l.header, json: l.json}
+}
+
+// cappedReader fails reads past limit bytes, where io.LimitReader would end them silently with a truncated body
+type cappedReader struct {
+	r         io.Reader
+	limit     int64
+	remaining int64
+}
+
+func newCappedReader(r io.Reader, limit int64) *cappedReader {
+	return &cappedReader{r: r, limit: limit, remaining: limit}
+}
+
+func (c *cappedReader) Read(p []byte) (int, error) {
+	if int64(len(p)) > c.remaining+1 {
+		p = p[:c.remaining+1] // one byte more than allowed tells an oversized body from one of exactly limit bytes
+	}
+	n, err := c.r.Read(p)
+	c.remaining -= int64(n)
+	if c.remaining < 0 {
+		return n, fmt.Errorf("%w: response exceeds %d bytes", ErrValidationFailed, c.limit)
+	}
+	return n, err
 }
 
 // RelayService TODO
 type RelayService struct {
-	relayURLs []string
-	store     Store
-	log       *logrus.Entry
+	relays               *relaySet
+	store                Store
+	client               *http.Client
+	chain                *ChainConfig
+	payments             *paymentLog
+	accounting           *relayAccounting
+	deliveries           *deliveryLog
+	payouts              *payoutLedger
+	proposals            *proposalLog
+	bids                 *bidArchive
+	bidValidators        chan struct{} // bounds the relay bids validated at once
+	heads                *forkchoiceHeads
+	reconciler           *deliveryReconciler
+	blacklist            *relayBlacklist
+	capabilities         *relayCapabilities
+	endpoints            *relayEndpoints
+	ordering             relayOrdering
+	signer               Signer // nil if no signer is configured
+	preferences          *validatorPreferences
+	stableHeaders        *stableHeaders // nil unless headers are kept stable per slot
+	equivocation         *EquivocationGuard
+	validation           ValidationPolicy
+	simulation           *payloadSimulator // nil unless revealed payloads are simulated on a local execution client
+	events               *eventBus
+	stream               *eventStream
+	bidDecision          BidDecision
+	selector             BidSelector           // nil unless bids are selected by a policy other than the highest weighted value
+	signatures           *proposerSignatures   // nil unless proposer signatures are verified
+	bidSignatures        *bidSignatures        // nil unless relay bids are verified against the relay pubkeys
+	stateDiffs           *stateDiffVerifier    // nil unless payments are verified on an execution client
+	verifier             *deliveryVerifier     // nil unless deliveries are verified against the finalized chain
+	payloadIDs           *payloadIDFreshness   // nil unless payload ids expire
+	aggregator           bool                  // serve the relay API to downstream mev-boost instances
+	prefetcher           *headerPrefetcher     // nil unless headers are prefetched
+	chainChecks          *chainSanity          // nil unless the chain state is checked on the beacon node
+	headerChecks         *headerChecks         // nil unless headers are checked against the forkchoice state
+	registrations        *registrationThrottle // nil unless repeated registrations are throttled
+	fcuDedup             *forkchoiceDedup      // nil unless back-to-back forkchoiceUpdated calls are deduplicated
+	tenants              *tenantSet            // nil unless consensus clients are served as tenants
+	groups               *relayGroups          // nil unless relays are grouped
+	validatorRelays      *validatorRelays      // nil unless relays are chosen per validator
+	proposers            *registeredProposers
+	revealWeights        *revealWeighting    // nil unless bids are weighted by reveal latency near the deadline
+	feeFallback          common.Address      // zero unless bids without registered fee recipient go to a default one
+	timings              *relayTimings       // nil unless relay call timings are recorded
+	timeouts             *relayTimeouts      // nil unless relay timeouts adapt to their latency
+	health               *relayHealth        // nil unless relays are taken out of the rotation after consecutive failures
+	methodTimeouts       relayMethodTimeouts // empty unless relay methods have fixed timeouts
+	probes               *relayProbes        // nil unless relays are probed between proposals
+	readiness            *readiness          // nil unless readiness checks run
+	clock                *clockSkew          // nil unless the local clock is compared with an NTP server
+	local                *localBuilders      // nil unless local execution clients build payloads without relay bids
+	audit                *auditLog           // nil unless audit records are written to sinks
+	missedValues         *missedValueLog     // nil unless deliveries are compared to the bids of other relays
+	responseLimits       relayResponseLimits
+	noBids               NoBidsBehavior
+	compression          bool
+	decoder              relayDecoder
+	escrow               bool
+	proposalFanOut       bool          // send signed blocks to all relays that bid for the slot
+	ssz                  bool          // request SSZ encoded headers and payloads from relays
+	minBid               *big.Int      // nil unless bids below a min value are rejected
+	spans                *spanExporter // nil unless spans are exported to an OpenTelemetry collector
+	requestBudget        time.Duration // 0 unless consensus client calls are bounded
+	raceCutoff           time.Duration // 0 unless header calls stop waiting for slow relays, see WithHeaderRace
+	pushInterval         time.Duration // 0 unless consensus clients can subscribe to the best bid
+	webSocketRPC         http.Handler  // nil unless the JSON-RPC methods are served over WebSocket, set by NewRouter
+	webSocketReadTimeout time.Duration // 0 unless idle WebSocket connections are closed
+	log                  Logger
+}
+
+// newRelayService creates a relay service with the given options, see NewRouter
+func newRelayService(opts ...Option) (*RelayService, error) {
+	return newRelayServiceWithConfig(newRouterConfig(context.Background(), opts...))
 }
 
-func newRelayService(relayURLs []string, store Store, log *logrus.Entry) (*RelayService, error) {
-	if len(relayURLs) == 0 || relayURLs[0] == "" {
+func newRelayServiceWithConfig(cfg *routerConfig) (*RelayService, error) {
+	if len(cfg.relayURLs) == 0 || cfg.relayURLs[0] == "" {
 		return nil, errors.New("no relayURLs")
 	}
+	relayConfigs := cfg.relays
+	if relayConfigs == nil {
+		for _, entry := range cfg.relayURLs {
+			relayConfigs = append(relayConfigs, RelayConfig{URL: entry})
+		}
+	}
+	endpoints, relayURLs, err := newRelayEndpoints(cfg.relayURLs)
+	if err != nil {
+		return nil, err
+	}
+	cfg.relayURLs = relayURLs
+	relays, err := newRelaySet(relayURLs, relayConfigs)
+	if err != nil {
+		return nil, err
+	}
+
+	chain := cfg.chain
+	if chain == nil {
+		chain = MainnetChainConfig
+	}
+
+	events := newEventBus()
+	events.subscribe(recordEventMetrics)
+	events.subscribe(cfg.hooks.onEvent, EventBidReceived, EventPayloadRevealed)
+	events.subscribe(newWebhookNotifier(cfg.notifyWebhookURL, cfg.log).onEvent, EventRelayFault)
+	stream := newEventStream()
+	events.subscribe(stream.onEvent)
+	proposals := newProposalLog()
+	events.subscribe(proposals.onEvent)
+	for _, subscription := range cfg.eventSubscribers {
+		events.subscribe(subscription.fn, subscription.kinds...)
+	}
+
+	var stable *stableHeaders
+	if cfg.stableHeaders {
+		stable = newStableHeaders()
+	}
+
+	var signatures *proposerSignatures
+	if cfg.signatureBeacon != nil {
+		signatures = newProposerSignatures(cfg.signatureBeacon, chain, cfg.signatureFailOpen)
+	}
+
+	var bidSigs *bidSignatures
+	if cfg.bidSignatures {
+		var err error
+		if bidSigs, err = newBidSignatures(cfg.relayURLs, chain); err != nil {
+			return nil, err
+		}
+	}
+
+	var payloadIDs *payloadIDFreshness
+	if cfg.payloadIDExpirySlots > 0 {
+		payloadIDs = newPayloadIDFreshness(chain.SlotDuration()*time.Duration(cfg.payloadIDExpirySlots), chain.stateExpiry())
+	}
+
+	var prefetcher *headerPrefetcher
+	if cfg.prefetchBeacon != nil {
+		prefetcher = newHeaderPrefetcher(cfg.prefetchBeacon, cfg.validatorPubkeys)
+	}
+
+	var chainChecks *chainSanity
+	if cfg.chainCheckBeacon != nil {
+		chainChecks = newChainSanity(cfg.chainCheckBeacon, chain, cfg.validatorPubkeys)
+	}
+
+	var local *localBuilders
+	if len(cfg.localExecutionClients) > 0 {
+		local = newLocalBuilders(cfg.localExecutionClients)
+	}
 
+	var audit *auditLog
+	if len(cfg.auditSinks) > 0 {
+		audit = newAuditLog(cfg.auditSinks, cfg.log)
+		events.subscribe(audit.onEvent, EventRelayFault, EventDeliveryVerified)
+	}
+
+	var missedValues *missedValueLog
+	if cfg.missedValue {
+		missedValues = new(missedValueLog)
+	}
+
+	var clock *clockSkew
+	if cfg.ntpServer != "" {
+		clock = newClockSkew(cfg.ntpServer, cfg.clockSkewThreshold, cfg.adjustForClockSkew, cfg.log)
+		if cfg.revealWeighting != nil {
+			cfg.revealWeighting.clock = clock
+		}
+	}
+
+	var fcuDedup *forkchoiceDedup
+	if cfg.forkchoiceDedupWindow > 0 {
+		fcuDedup = newForkchoiceDedup(cfg.forkchoiceDedupWindow)
+	}
+
+	var registrations *registrationThrottle
+	if cfg.registrationInterval > 0 {
+		registrations = newRegistrationThrottle(cfg.registrationInterval)
+	}
+
+	var tenants *tenantSet
+	if len(cfg.tenants) > 0 {
+		if cfg.stableHeaders {
+			return nil, errors.New("stable headers can't be combined with tenants")
+		}
+		var err error
+		if tenants, err = newTenantSet(cfg.tenants, cfg.relayURLs); err != nil {
+			return nil, err
+		}
+	}
+
+	var groups *relayGroups
+	if cfg.relayGroups != nil {
+		var err error
+		if groups, err = newRelayGroups(cfg.relayGroups, cfg.relayURLs); err != nil {
+			return nil, err
+		}
+	}
+
+	var spans *spanExporter
+	if cfg.spanEndpoint != "" {
+		spans = newSpanExporter(cfg.spanEndpoint, cfg.spanService, cfg.log)
+	}
+
+	var validatorRelays *validatorRelays
+	if cfg.validatorRelays != nil {
+		var err error
+		if validatorRelays, err = newValidatorRelays(cfg.validatorRelays, cfg.relayURLs); err != nil {
+			return nil, err
+		}
+	}
+
+	var simulation *payloadSimulator
+	if cfg.simulationClient != nil {
+		simulation = &payloadSimulator{el: cfg.simulationClient, timeout: cfg.simulationTimeout}
+	}
+
+	var timings *relayTimings
+	if cfg.relayTimings || cfg.relayCallLog {
+		timings = newRelayTimings(cfg.relayTimingsOut, cfg.log)
+		timings.logCalls = cfg.relayCallLog
+	}
+
+	var probes *relayProbes
+	if cfg.relayProbeInterval > 0 {
+		probes = newRelayProbes()
+	}
+
+	var readiness *readiness
+	if cfg.readinessInterval > 0 {
+		readiness = newReadiness(cfg.readinessInterval, cfg.readinessEngines)
+	}
+
+	var timeouts *relayTimeouts
+	if cfg.maxRelayTimeout > 0 {
+		timeouts = newRelayTimeouts(cfg.minRelayTimeout, cfg.maxRelayTimeout, probes)
+	}
+
+	var health *relayHealth
+	if cfg.relayFailureThreshold > 0 {
+		health = newRelayHealth(cfg.relayFailureThreshold, cfg.relayCooldown, events, cfg.log)
+	}
+
+	var stateDiffs *stateDiffVerifier
+	if cfg.paymentExecutionClient != nil {
+		stateDiffs = newStateDiffVerifier(cfg.paymentExecutionClient)
+	}
+
+	var verifier *deliveryVerifier
+	if cfg.verifyBeacon != nil && cfg.verifyExecution != nil {
+		verifier = &deliveryVerifier{beacon: cfg.verifyBeacon, el: cfg.verifyExecution}
+	}
+
+	blacklist := newRelayBlacklist(cfg.underpaymentTolerance, cfg.underpaymentWindow, cfg.suspensionExpiry, cfg.store, events, cfg.log)
+	if err := blacklist.load(context.Background(), cfg.relayURLs); err != nil {
+		return nil, fmt.Errorf("could not load relay reputations: %w", err)
+	}
+
+	log := cfg.log.WithField("prefix", "lib/service")
 	return &RelayService{
-		relayURLs: relayURLs,
-		store:     store,
-		log:       log.WithField("prefix", "lib/service"),
+		relays:               relays,
+		store:                cfg.store,
+		client:               withContentTypeCheck(withRelayRetries(cfg.httpClient, cfg.relayRetries), cfg.strictContentTypes, log),
+		chain:                chain,
+		payments:             new(paymentLog),
+		accounting:           newRelayAccounting(),
+		deliveries:           new(deliveryLog),
+		payouts:              newPayoutLedger(cfg.payoutCSV, log),
+		proposals:            proposals,
+		bids:                 new(bidArchive),
+		bidValidators:        make(chan struct{}, maxBidValidators),
+		heads:                newForkchoiceHeads(),
+		reconciler:           &deliveryReconciler{validatorPubkeys: cfg.validatorPubkeys, grace: cfg.reconcileGrace},
+		blacklist:            blacklist,
+		capabilities:         newRelayCapabilities(),
+		endpoints:            endpoints,
+		ordering:             relayOrdering{deterministic: cfg.deterministicRelayOrder, maxJitter: cfg.relayJitter},
+		signer:               cfg.signer,
+		preferences:          newValidatorPreferences(),
+		stableHeaders:        stable,
+		equivocation:         cfg.equivocation,
+		validation:           cfg.validation,
+		events:               events,
+		stream:               stream,
+		bidDecision:          cfg.bidDecision,
+		selector:             cfg.bidSelector,
+		signatures:           signatures,
+		bidSignatures:        bidSigs,
+		stateDiffs:           stateDiffs,
+		verifier:             verifier,
+		payloadIDs:           payloadIDs,
+		aggregator:           cfg.aggregator,
+		prefetcher:           prefetcher,
+		chainChecks:          chainChecks,
+		headerChecks:         cfg.headerChecks,
+		registrations:        registrations,
+		fcuDedup:             fcuDedup,
+		tenants:              tenants,
+		groups:               groups,
+		validatorRelays:      validatorRelays,
+		proposers:            newRegisteredProposers(),
+		revealWeights:        cfg.revealWeighting,
+		feeFallback:          cfg.defaultFeeRecipient,
+		noBids:               cfg.noBids,
+		compression:          cfg.payloadCompression,
+		escrow:               cfg.payloadEscrow,
+		simulation:           simulation,
+		proposalFanOut:       cfg.proposalFanOut,
+		ssz:                  cfg.relaySSZ,
+		spans:                spans,
+		minBid:               cfg.minBid,
+		decoder:              relayDecoder{unknown: cfg.unknownFields, missing: cfg.missingFields, log: log},
+		timings:              timings,
+		timeouts:             timeouts,
+		health:               health,
+		methodTimeouts:       cfg.relayMethodTimeouts,
+		probes:               probes,
+		readiness:            readiness,
+		clock:                clock,
+		local:                local,
+		audit:                audit,
+		missedValues:         missedValues,
+		requestBudget:        cfg.requestBudget,
+		raceCutoff:           cfg.headerRaceCutoff,
+		pushInterval:         cfg.bidSubscriptionInterval,
+		webSocketReadTimeout: cfg.webSocketReadTimeout,
+		responseLimits:       relayResponseLimits{header: cfg.maxHeaderResponseSize, payload: cfg.maxPayloadResponseSize, json: cfg.jsonLimits},
+		log:                  log,
 	}, nil
 }
 
-func makeRequest(ctx context.Context, url string, method string, params []interface{}) (*rpcResponse, error) {
+// makeRequest makes a JSON-RPC request, responses exceeding limit are aborted
+func makeRequest(ctx context.Context, client *http.Client, url string, method string, params []interface{}, limit responseLimit) (*rpcResponse, error) {
 	reqJSON := rpcRequest{
 		ID:      "1",
 		JSONRPC: "2.0",
@@ -59,13 +435,19 @@ func makeRequest(ctx context.Context, url string, method string, params []interf
 		return nil, err
 	}
 	req.Header.Add("Content-Type", "application/json")
+	setTraceHeaders(ctx, req)
+	setSSZAccept(ctx, req)
 
-	resp, err := httpClient.Do(req)
+	resp, err := client.Do(req)
 	if err != nil {
 		return nil, err
 	}
+	defer resp.Body.Close()
 
-	respBody, err := ioutil.ReadAll(resp.Body)
+	if result, ok, err := readSSZResponse(ctx, url, resp, resp.Body, limit); ok {
+		return &rpcResponse{SSZ: result}, err
+	}
+	respBody, err := ioutil.ReadAll(limit.reader(resp.Body))
 	if err != nil {
 		return nil, err
 	}
@@ -73,68 +455,512 @@ func makeRequest(ctx context.Context, url string, method string, params []interf
 	return parseRPCResponse(respBody)
 }
 
+// makeRequestInto is like makeRequest, but decodes the result from the response body as it arrives, straight into result,
+// so a payload is held once in decoded form instead of also as raw and intermediate bytes. The body isn't passed on to
+// the consensus client as it arrives, since the payload has to be checked against the header first. It returns the
+// error reply of the relay, if any.
+// With compressed set, the response is negotiated in one of the payloadEncodings. SSZ responses are decoded with the
+// UnmarshalSSZ method of result.
+func makeRequestInto(ctx context.Context, client *http.Client, url string, method string, params []interface{}, result interface{}, limit responseLimit, compressed bool) (*rpcError, error) {
+	body, err := json.Marshal(rpcRequest{
+		ID:      "1",
+		JSONRPC: "2.0",
+		Method:  method,
+		Params:  params,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Add("Content-Type", "application/json")
+	setTraceHeaders(ctx, req)
+	setSSZAccept(ctx, req)
+	if compressed {
+		// setting Accept-Encoding turns off the transparent gzip decoding of the transport, decodeBody takes over
+		req.Header.Set("Accept-Encoding", payloadEncodings)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	respBody := io.Reader(resp.Body)
+	if compressed {
+		decoded, encoding, err := decodeBody(resp)
+		if err != nil {
+			return nil, err
+		}
+		defer decoded.Close()
+		relayPayloadEncodingsTotal.WithLabelValues(url, encoding).Inc()
+		respBody = decoded
+	}
+	if data, ok, err := readSSZResponse(ctx, url, resp, respBody, limit); ok {
+		if err != nil {
+			return nil, err
+		}
+		return nil, unmarshalSSZ(data, result)
+	}
+
+	res := struct {
+		Result interface{} `json:"result"`
+		Error  *rpcError   `json:"error"`
+	}{Result: result}
+	if err := json.NewDecoder(limit.reader(respBody)).Decode(&res); err != nil {
+		return nil, fmt.Errorf("%w: could not decode response: %v", ErrValidationFailed, err)
+	}
+	return res.Error, nil
+}
+
+// requestRelay makes a request to a relay after the random delay of the relay ordering, which signed blocks are sent
+// without. The caller passes the timing of the call to m.timings.finish once it checked the response. Calls of
+// contexts marked by withCapella are sent with the V2 version of method.
+func (m *RelayService) requestRelay(ctx context.Context, url string, method string, params []interface{}) (*rpcResponse, *RelayCallTiming, error) {
+	if err := m.ordering.wait(ctx, method); err != nil {
+		return nil, nil, err
+	}
+	ctx, timing := m.timings.start(ctx, url, method)
+	parent := traceContextFromContext(ctx)
+	ctx = startRelaySpan(ctx, timing)
+	if m.ssz && sszMethods[method] && !isCapella(ctx) {
+		ctx = withSSZ(ctx)
+	}
+	ctx = withRetries(ctx, method)
+	ctx, cancel := m.methodTimeouts.bound(ctx, method)
+	defer cancel()
+	ctx, cancelRelay := m.relays.bound(ctx, url)
+	defer cancelRelay()
+	ctx, done := m.timeouts.bound(ctx, url, method)
+	sentAt := now()
+	var res *rpcResponse
+	err := m.endpoints.call(ctx, url, withTraceFields(ctx, m.log), func(endpoint string) (err error) {
+		res, err = makeRequest(ctx, m.client, endpoint, forkMethod(ctx, method), params, m.responseLimits.forMethod(method))
+		return err
+	})
+	if err == nil && res.Error == nil && res.Result != nil && m.capabilities.encoding(url) == PayloadEncodingBeacon {
+		if res.Result, err = engineEncodedResult(res.Result); err != nil {
+			err = fmt.Errorf("%w: could not convert beacon API encoded result: %v", ErrValidationFailed, err)
+		}
+	}
+	done(err)
+	m.spans.relayCall(ctx, parent, url, method, sentAt, err)
+	recordRelayRequest(url, method, sentAt, err != nil || res.Error != nil)
+	m.health.call(url, err)
+	if err == nil && res.Error != nil { // the result is checked by the caller
+		m.health.success(url)
+	}
+	if err == nil {
+		timing.parsed()
+	}
+	return res, timing, err
+}
+
+// requestRelayInto is requestRelay with the result decoded by makeRequestInto
+func (m *RelayService) requestRelayInto(ctx context.Context, url string, method string, params []interface{}, result interface{}) (*rpcError, *RelayCallTiming, error) {
+	if err := m.ordering.wait(ctx, method); err != nil {
+		return nil, nil, err
+	}
+	ctx, timing := m.timings.start(ctx, url, method)
+	parent := traceContextFromContext(ctx)
+	ctx = startRelaySpan(ctx, timing)
+	if m.ssz && sszMethods[method] && !isCapella(ctx) {
+		ctx = withSSZ(ctx)
+	}
+	ctx = withRetries(ctx, method)
+	ctx, cancel := m.methodTimeouts.bound(ctx, method)
+	defer cancel()
+	ctx, cancelRelay := m.relays.bound(ctx, url)
+	defer cancelRelay()
+	ctx, done := m.timeouts.bound(ctx, url, method)
+	sentAt := now()
+	var rpcErr *rpcError
+	into := result
+	checked := &checkedResult{result: result}
+	beaconEncoded := m.capabilities.encoding(url) == PayloadEncodingBeacon
+	if m.decoder.checks() || beaconEncoded {
+		into = checked // buffered, the fields are converted and compared before decoding
+	}
+	err := m.endpoints.call(ctx, url, withTraceFields(ctx, m.log), func(endpoint string) (err error) {
+		rpcErr, err = makeRequestInto(ctx, m.client, endpoint, forkMethod(ctx, method), params, into, m.responseLimits.forMethod(method), m.compression)
+		return err
+	})
+	if err == nil && rpcErr == nil && into == checked && !checked.ssz {
+		err = m.decodeChecked(url, checked.raw, result, beaconEncoded)
+	}
+	done(err)
+	m.spans.relayCall(ctx, parent, url, method, sentAt, err)
+	recordRelayRequest(url, method, sentAt, err != nil || rpcErr != nil)
+	m.health.call(url, err)
+	if err == nil {
+		m.health.success(url)
+	}
+	if err == nil {
+		timing.parsed()
+	}
+	return rpcErr, timing, err
+}
+
+// decodeChecked decodes a buffered relay result into result, converted to the engine API encoding if the relay returns
+// the beacon API encoding
+func (m *RelayService) decodeChecked(url string, raw json.RawMessage, result interface{}, beaconEncoded bool) error {
+	if beaconEncoded {
+		converted, err := engineEncodedResult(raw)
+		if err != nil {
+			return fmt.Errorf("%w: could not convert beacon API encoded result: %v", ErrValidationFailed, err)
+		}
+		raw = converted
+	}
+	if m.decoder.checks() {
+		return m.decoder.decode(url, raw, result)
+	}
+	return json.Unmarshal(raw, result)
+}
+
+// requestContext returns the context of an incoming request, which is cancelled when the consensus client disconnects
+func requestContext(req *http.Request) context.Context {
+	if req == nil {
+		return context.Background()
+	}
+	return req.Context()
+}
+
 type rpcResponseContainer struct {
-	url string
-	err error
-	res *rpcResponse
+	url       string
+	err       error
+	res       *rpcResponse
+	timing    *RelayCallTiming
+	header    *ExecutionPayloadWithTxRootV1 // decoded result, nil if it couldn't be decoded
+	signature []byte                        // verified bid signature of the header, nil unless bid signatures are verified
+	invalid   error                         // why decoding or validating the header failed
+}
+
+type payloadResponseContainer struct {
+	url     string
+	err     error
+	rpcErr  *rpcError
+	payload *ExecutionPayloadWithTxRootV1
+	timing  *RelayCallTiming
+}
+
+// parseForkchoiceState returns the forkchoice state of forkchoiceUpdated params, or nil if there is none
+func parseForkchoiceState(args []interface{}) (*ForkchoiceStateV1, error) {
+	if len(args) < 1 || args[0] == nil {
+		return nil, nil
+	}
+
+	stateJSON, err := json.Marshal(args[0])
+	if err != nil {
+		return nil, err
+	}
+
+	state := new(ForkchoiceStateV1)
+	if err := json.Unmarshal(stateJSON, state); err != nil {
+		return nil, err
+	}
+	return state, nil
+}
+
+// parsePayloadAttributes returns the payload attributes of forkchoiceUpdated params, or nil if there are none
+func parsePayloadAttributes(args []interface{}) (*PayloadAttributesV1, error) {
+	if len(args) < 2 || args[1] == nil {
+		return nil, nil
+	}
+
+	attributesJSON, err := json.Marshal(args[1])
+	if err != nil {
+		return nil, err
+	}
+
+	attributes := new(PayloadAttributesV1)
+	if err := json.Unmarshal(attributesJSON, attributes); err != nil {
+		return nil, err
+	}
+	return attributes, nil
+}
+
+// recordDelivery adds a revealed payload to the delivery log. relayURL is the relay that revealed it, if known.
+func (m *RelayService) recordDelivery(ctx context.Context, block *BlindedBeaconBlock, payload *ExecutionPayloadWithTxRootV1, relayURL string) {
+	slot := block.Slot
+	if slot == 0 {
+		slot = m.chain.SlotAt(payload.Timestamp)
+	}
+
+	delivery := &DeliveredPayload{
+		Slot:          slot,
+		ProposerIndex: block.ProposerIndex,
+		BlockHash:     payload.BlockHash,
+		BlockNumber:   payload.Number,
+		ParentHash:    payload.ParentHash,
+		RelayURL:      relayURL,
+		Value:         payload.FeeRecipientDiff,
+		DeliveredAt:   now(),
+	}
+	if bid := m.store.GetBid(ctx, payload.BlockHash); bid != nil {
+		delivery.RelayURL = bid.RelayURL
+		delivery.FeeRecipient = bid.FeeRecipient
+		delivery.Value = bid.Value
+	}
+	m.deliveries.add(delivery)
+	if m.equivocation != nil {
+		m.equivocation.blockRevealed(block, payload.BlockHash)
+	}
+	m.recordPayout(ctx, delivery)
+	m.checkMissedValue(delivery)
+	m.audit.record(AuditRecord{Kind: AuditDelivery, Slot: slot, RelayURL: delivery.RelayURL, BlockHash: &delivery.BlockHash, Data: *delivery})
+}
+
+// verifyPayment checks that a revealed payload pays the proposer what the relay promised, and records underpayments.
+// With an execution client, the payment is checked later from the balance increase of the fee recipient.
+func (m *RelayService) verifyPayment(ctx context.Context, payload *ExecutionPayloadWithTxRootV1, log Logger) {
+	bid := m.store.GetBid(ctx, payload.BlockHash)
+	if bid == nil || bid.FeeRecipient == (common.Address{}) {
+		log.WithField("blockHash", payload.BlockHash).Debug("no bid known for payload, skipping payment verification")
+		return
+	}
+	if m.stateDiffs != nil {
+		m.stateDiffs.add(bid, payload)
+		return
+	}
+
+	paid, err := verifyProposerPayment(payload, bid.FeeRecipient, bid.Value)
+	m.recordPayment(bid, payload, paid, err, log)
+}
+
+// recordPayment adds the outcome of a payment verification to the accounting, blacklist and payment log. Payments that
+// can't be verified are only counted as unverified, they are no underpayment of the relay.
+func (m *RelayService) recordPayment(bid *Bid, payload *ExecutionPayloadWithTxRootV1, paid *big.Int, err error, log Logger) {
+	if errors.Is(err, errPaymentUnverifiable) {
+		m.accounting.record(bid, nil)
+		log.WithField("blockHash", payload.BlockHash).Debug(err.Error())
+		return
+	}
+	if paid == nil {
+		paid = big.NewInt(0)
+	}
+	m.accounting.record(bid, paid)
+	m.blacklist.record(bid.RelayURL, bid.Value, paid)
+	if err == nil {
+		log.WithFields(Fields{
+			"blockHash": payload.BlockHash,
+			"url":       bid.RelayURL,
+			"paid":      paid,
+		}).Info("verified proposer payment")
+		return
+	}
+
+	record := &PaymentRecord{
+		BlockHash:    payload.BlockHash,
+		BlockNumber:  payload.Number,
+		RelayURL:     bid.RelayURL,
+		Builder:      payload.FeeRecipient,
+		FeeRecipient: bid.FeeRecipient,
+		Promised:     bid.Value,
+		Paid:         paid,
+		Error:        err.Error(),
+		VerifiedAt:   now(),
+	}
+	m.payments.add(record)
+	m.audit.record(AuditRecord{Kind: AuditUnderpayment, RelayURL: bid.RelayURL, BlockHash: &record.BlockHash, Data: *record})
+
+	log.WithFields(Fields{
+		"error":     err,
+		"blockHash": payload.BlockHash,
+		"url":       bid.RelayURL,
+		"builder":   payload.FeeRecipient,
+		"promised":  bid.Value,
+		"paid":      paid,
+	}).Error("proposer payment verification failed")
 }
 
 // ForkchoiceUpdatedV1 TODO
-func (m *RelayService) ForkchoiceUpdatedV1(_ *http.Request, args *[]interface{}, result *ForkChoiceResponse) error {
-	method := "engine_forkchoiceUpdatedV1"
-	logMethod := m.log.WithField("method", method)
+func (m *RelayService) ForkchoiceUpdatedV1(req *http.Request, args *[]interface{}, result *ForkChoiceResponse) error {
+	if err := validateForkchoiceParams(*args); err != nil {
+		return err
+	}
+	if m.fcuDedup == nil {
+		return m.forkchoiceUpdated(req, args, result)
+	}
+	params := registrationParams(*args)
+	if tenant := tenantFromContext(requestContext(req)); tenant != nil {
+		params = tenant.Name + " " + params
+	}
+	call, forward := m.fcuDedup.join(params)
+	if !forward {
+		withTraceFields(requestContext(req), m.log.WithField("method", methodForkchoiceUpdated)).Debug("ForkchoiceUpdatedV1: repeated call, serving the response of the previous one")
+		return call.wait(requestContext(req), result)
+	}
+
+	// the repeats wait for this call, so it's forwarded even if this caller disconnects, within the request budget
+	timeout := m.requestBudget
+	if timeout <= 0 {
+		timeout = m.chain.SlotDuration()
+	}
+	ctx, cancel := context.WithTimeout(detachContext(requestContext(req)), timeout)
+	defer cancel()
+	if req == nil {
+		req = new(http.Request)
+	}
+	err := m.forkchoiceUpdated(req.WithContext(ctx), args, result)
+	m.fcuDedup.finish(call, result, err)
+	return err
+}
+
+// forkchoiceUpdated forwards a forkchoiceUpdated call to the relays
+func (m *RelayService) forkchoiceUpdated(req *http.Request, args *[]interface{}, result *ForkChoiceResponse) error {
+	method := methodForkchoiceUpdated
+	logMethod := withTraceFields(requestContext(req), m.log.WithField("method", method))
+	ctx, cancel := m.withBudget(requestContext(req))
+	defer cancel()
+	tenant := tenantFromContext(ctx)
 
 	boostPayloadID := make(hexutil.Bytes, 8)
 	if _, err := rand.Read(boostPayloadID); err != nil {
 		return err
 	}
 
+	attributes, err := parsePayloadAttributes(*args)
+	if err != nil {
+		logMethod.WithField("error", err).Warn("could not parse payload attributes")
+	}
+	if attributes != nil {
+		m.events.publish(ctx, &Event{Kind: EventAttributesReceived, Slot: m.chain.SlotAt(uint64(attributes.Timestamp))})
+	}
+	var params string
+	if attributes != nil && m.registrations != nil {
+		params = registrationParams(*args)
+		if tenant != nil { // tenants with the same fee recipient still get payload ids of their own relays
+			params = tenant.Name + " " + params
+		}
+		if payloadID, ok := m.registrations.repeated(attributes.SuggestedFeeRecipient, params); ok {
+			logMethod.WithFields(Fields{"feeRecipient": attributes.SuggestedFeeRecipient, "payloadID": payloadID}).Debug("ForkchoiceUpdatedV1: repeated registration, not forwarding it to relays")
+			*result = ForkChoiceResponse{
+				PayloadStatus: PayloadStatus{Status: ForkchoiceStatusValid},
+				PayloadID:     &payloadID,
+			}
+			return nil
+		}
+	}
+	if attributes != nil {
+		m.store.SetPayloadAttributes(ctx, boostPayloadID.String(), attributes)
+		if state, err := parseForkchoiceState(*args); err == nil && state != nil {
+			m.heads.set(boostPayloadID.String(), m.chain.SlotAt(uint64(attributes.Timestamp)), state.HeadBlockHash)
+		}
+	}
+
 	var wg sync.WaitGroup
-	hasValidResponse := false
-	for _, url := range m.relayURLs {
+	var failures relayFailures
+	var validResponses int32 // written by the relay goroutines
+	var slot uint64
+	var feeRecipient common.Address
+	if attributes != nil {
+		slot = m.chain.SlotAt(uint64(attributes.Timestamp))
+		feeRecipient = attributes.SuggestedFeeRecipient
+	}
+	var proposer string
+	if attributes != nil && m.restrictsRelays() {
+		proposer = m.proposerOf(ctx, slot, feeRecipient, logMethod)
+	}
+	if attributes != nil {
+		ctx = withCapella(ctx, m.chain, slot)
+	} else {
+		ctx = withCapella(ctx, m.chain, m.chain.CurrentSlot())
+	}
+	for _, url := range m.ordering.order(m.relays.all()) {
+		if m.blacklist.isSuspended(url) {
+			logMethod.WithField("url", url).Debug("skipping suspended relay")
+			continue
+		}
+		if !m.relays.enabled(url) {
+			logMethod.WithField("url", url).Debug("skipping relay turned off by admin")
+			continue
+		}
+		if !m.health.available(url) {
+			logMethod.WithField("url", url).Debug("skipping relay out of the rotation")
+			continue
+		}
+		if !m.capabilities.supports(url, forkMethod(ctx, method)) || !tenant.usesRelay(url) || !m.groups.usesRelay(feeRecipient, url) || !m.allowsRelay(proposer, url) {
+			continue
+		}
+
 		wg.Add(1)
 		go func(url string) {
 			defer wg.Done()
-			res, err := makeRequest(context.Background(), url, method, *args)
+			res, timing, err := m.requestRelay(ctx, url, method, *args)
+			defer func() { m.timings.finish(timing, slot, err) }()
 
 			// Check for errors
+			if gone := clientGone(req); gone != nil { // nobody will ask for this payload id
+				err = gone
+				return
+			}
 			if err != nil {
-				logMethod.WithFields(logrus.Fields{"error": err, "url": url}).Error("error making request to relay")
+				failures.request(err)
+				logMethod.WithFields(Fields{"error": err, "url": url}).Error("error making request to relay")
 				return
 			}
 			if res.Error != nil {
-				logMethod.WithFields(logrus.Fields{"error": res.Error, "url": url}).Warn("error reply from relay")
+				err = res.Error
+				failures.request(res.Error)
+				logMethod.WithFields(Fields{"error": res.Error, "url": url}).Warn("error reply from relay")
 				return
 			}
 
 			// Decode response
 			forkchoiceResponse := new(ForkChoiceResponse)
-			err = json.Unmarshal(res.Result, forkchoiceResponse)
+			err = m.decoder.decode(url, res.Result, forkchoiceResponse)
 			if err != nil {
-				logMethod.WithFields(logrus.Fields{"error": err, "data": string(res.Result)}).Error("Could not unmarshal response")
+				failures.invalid()
+				m.health.failure(url, relayHealthMalformed, err)
+				logMethod.WithFields(Fields{"error": err, "data": string(res.Result)}).Error("Could not unmarshal response")
 				return
 			}
+			m.health.success(url)
 
 			status := forkchoiceResponse.PayloadStatus.Status
 			if status != ForkchoiceStatusValid && status != "SUCCESS" && status != "" { // SUCCESS is used by mergemock, although it's not in the engine spec (also accept empty status because mergemock)
-				logMethod.WithFields(logrus.Fields{"error": err, "url": url, "status": status}).Warn("status not valid")
+				err = fmt.Errorf("status %s", status)
+				failures.invalid()
+				logMethod.WithFields(Fields{"error": err, "url": url, "status": status}).Warn("status not valid")
 				return
 			}
 
 			if forkchoiceResponse.PayloadID != nil {
-				m.store.SetForkchoiceResponse(boostPayloadID.String(), url, forkchoiceResponse.PayloadID.String())
-				hasValidResponse = true
+				m.store.SetForkchoiceResponse(ctx, boostPayloadID.String(), url, forkchoiceResponse.PayloadID.String())
+				atomic.AddInt32(&validResponses, 1)
 			}
 		}(url)
 	}
 
+	if m.local != nil && attributes != nil {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			started := m.local.forward(ctx, *args, boostPayloadID.String(), slot, logMethod)
+			atomic.AddInt32(&validResponses, int32(started))
+		}()
+	}
+
 	wg.Wait()
-	if !hasValidResponse {
+	if err := clientGone(req); err != nil {
+		logMethod.WithError(err).Warn("ForkchoiceUpdatedV1: consensus client disconnected")
+		return err
+	}
+	if atomic.LoadInt32(&validResponses) == 0 {
 		logMethod.Error("ForkchoiceUpdatedV1: no valid relay response")
-		return errors.New("no valid relay response")
+		return newMethodError(failures.kind(ErrNoBids), "no valid relay response")
 	}
 
 	// Compile the response
+	m.payloadIDs.issue(boostPayloadID.String())
+	if attributes != nil {
+		m.registrations.forwarded(attributes.SuggestedFeeRecipient, params, boostPayloadID)
+		m.prefetchHeaders(boostPayloadID.String(), m.chain.SlotAt(uint64(attributes.Timestamp)))
+	}
 	*result = ForkChoiceResponse{
 		PayloadStatus: PayloadStatus{Status: ForkchoiceStatusValid},
 		PayloadID:     &boostPayloadID,
@@ -144,209 +970,617 @@ func (m *RelayService) ForkchoiceUpdatedV1(_ *http.Request, args *[]interface{},
 }
 
 // ProposeBlindedBlockV1 TODO
-func (m *RelayService) ProposeBlindedBlockV1(_ *http.Request, args *SignedBlindedBeaconBlock, result *ExecutionPayloadWithTxRootV1) error {
+func (m *RelayService) ProposeBlindedBlockV1(req *http.Request, args *SignedBlindedBeaconBlock, result *ExecutionPayloadWithTxRootV1) error {
 	method := "builder_proposeBlindedBlockV1"
-	logMethod := m.log.WithField("method", method)
+	logMethod := withTraceFields(requestContext(req), m.log.WithField("method", method))
+	ctx, cancel := m.withBudget(requestContext(req))
+	defer cancel()
 
-	if args == nil || args.Message == nil {
-		logMethod.Errorf("SignedBlindedBeaconBlock or SignedBlindedBeaconBlock.Message is nil: %+v", args)
-		return errors.New("SignedBlindedBeaconBlock or SignedBlindedBeaconBlock.Message is nil")
+	if err := validateSignedBlindedBlock(args); err != nil {
+		logMethod.WithError(err).Error("ProposeBlindedBlockV1: invalid signed block")
+		return err
 	}
 
-	var body BlindedBeaconBlockBodyPartial
-	err := json.Unmarshal(args.Message.Body, &body)
-	if err != nil {
-		logMethod.WithField("err", err).Error("Could not unmarshal blinded body")
+	if err := m.signatures.verify(ctx, args, logMethod); err != nil {
+		logMethod.WithError(err).Error("ProposeBlindedBlockV1: rejected block with invalid proposer signature")
 		return err
 	}
-
-	var blockHash string
-	// Deal with allowing both camelCase and snake_case in BlindedBlock
-	if body.ExecutionPayload.BlockHash != "" {
-		blockHash = body.ExecutionPayload.BlockHash
-	} else if body.ExecutionPayload.BlockHashCamel != "" {
-		blockHash = body.ExecutionPayload.BlockHashCamel
-	} else if body.ExecutionPayloadCamel.BlockHash != "" {
-		blockHash = body.ExecutionPayloadCamel.BlockHash
-	} else if body.ExecutionPayloadCamel.BlockHashCamel != "" {
-		blockHash = body.ExecutionPayloadCamel.BlockHashCamel
+	header := args.Message.Body.ExecutionPayloadHeader
+	blockHash := header.BlockHash
+	m.events.publish(ctx, &Event{Kind: EventBlockSigned, Slot: args.Message.Slot, BlockHash: &blockHash})
+	if m.equivocation != nil {
+		if err := m.equivocation.checkBlock(args.Message, blockHash, logMethod); err != nil {
+			return err
+		}
 	}
 
-	payloadCached := m.store.GetExecutionPayload(common.HexToHash(blockHash))
+	payloadCached := m.store.GetExecutionPayload(ctx, blockHash)
 	if payloadCached != nil {
-		logMethod.WithFields(logrus.Fields{
+		if err := matchHeader(header, payloadCached); err != nil {
+			logMethod.WithError(err).Error("ProposeBlindedBlockV1: cached payload doesn't match the signed header")
+			return newMethodError(ErrHeaderMismatch, "%v", err)
+		}
+		logMethod.WithFields(Fields{
+			"slot":      m.chain.SlotAt(payloadCached.Timestamp),
 			"blockHash": payloadCached.BlockHash,
 			"number":    payloadCached.Number,
 			"txRoot":    fmt.Sprintf("%#x", payloadCached.TransactionsRoot),
 		}).Info("ProposeBlindedBlockV1: revealed previous payload")
+		relayURL := ""
+		if bid := m.store.GetBid(ctx, payloadCached.BlockHash); bid != nil {
+			relayURL = bid.RelayURL
+		}
+		if err := m.simulation.simulate(ctx, relayURL, payloadCached); err != nil {
+			logMethod.WithFields(Fields{"error": err, "url": relayURL, "blockHash": blockHash}).Error("ProposeBlindedBlockV1: cached payload failed simulation")
+			return newMethodError(ErrValidationFailed, "%v", err)
+		}
 		*result = *payloadCached
+		m.recordDelivery(ctx, args.Message, result, "")
+		m.verifyPayment(ctx, result, logMethod)
+		m.events.publish(ctx, payloadEvent(EventPayloadRevealed, relayURL, args.Message.Slot, result))
+		if m.escrow && relayURL != "" {
+			m.forwardEscrowedBlock(args, relayURL, blockHash, logMethod)
+		}
 		return nil
 	}
+	if bid := m.store.GetBid(ctx, blockHash); bid != nil && bid.Local {
+		logMethod.WithField("blockHash", blockHash).Error("ProposeBlindedBlockV1: payload of a local execution client is no longer cached, not sending the signed block to relays")
+		return newMethodError(ErrUnknownPayload, "payload of locally built block %s is no longer cached", blockHash)
+	}
+	if m.escrow {
+		logMethod.WithField("blockHash", blockHash).Error("ProposeBlindedBlockV1: payload wasn't received before the block was signed, not forwarding the signature in escrow mode")
+		return newMethodError(ErrUnknownPayload, "payload of block %s wasn't received before signing, not forwarding the signed block in escrow mode", blockHash)
+	}
 
-	requestCtx, requestCtxCancel := context.WithCancel(context.Background())
+	requestCtx, requestCtxCancel := context.WithCancel(withCapella(ctx, m.chain, args.Message.Slot))
 	defer requestCtxCancel()
 
-	resultC := make(chan *rpcResponseContainer, len(m.relayURLs))
-	for _, url := range m.relayURLs {
+	var relayURLs []string
+	tenant := tenantFromContext(ctx)
+	for _, url := range m.relays.all() {
+		if m.capabilities.supports(url, forkMethod(requestCtx, methodRelayProposeBlock)) && tenant.usesRelay(url) && !m.relays.shadow(url) {
+			relayURLs = append(relayURLs, url)
+		}
+	}
+	if bid := m.store.GetBid(ctx, blockHash); bid != nil {
+		relayURLs = m.proposalRelays(relayURLs, bid.RelayURL, args.Message.Slot)
+	}
+	logMethod.WithFields(Fields{"blockHash": blockHash, "urls": relayURLs}).Debug("ProposeBlindedBlockV1: sending signed block to relays")
+
+	// payloads are decoded while they are received, so only the decoded payload of each relay is kept in memory
+	resultC := make(chan *payloadResponseContainer, len(relayURLs))
+	for _, url := range m.ordering.order(relayURLs) {
 		go func(url string) {
-			res, err := makeRequest(requestCtx, url, "relay_proposeBlindedBlockV1", []interface{}{args})
-			resultC <- &rpcResponseContainer{url, err, res}
+			payload := new(ExecutionPayloadWithTxRootV1)
+			sentAt := now()
+			rpcErr, timing, err := m.requestRelayInto(requestCtx, url, methodRelayProposeBlock, []interface{}{args}, payload)
+			if err == nil && rpcErr == nil {
+				m.revealWeights.observe(url, now().Sub(sentAt))
+			}
+			resultC <- &payloadResponseContainer{url, err, rpcErr, payload, timing}
 		}(url)
 	}
 
+	var failures relayFailures
 	for i := 0; i < cap(resultC); i++ {
 		res := <-resultC
 
 		// Check for errors
-		if requestCtx.Err() != nil { // request has been cancelled
+		if errors.Is(requestCtx.Err(), context.Canceled) { // another relay answered or the client disconnected
+			m.timings.finish(res.timing, args.Message.Slot, requestCtx.Err())
 			continue
 		}
-		if err != nil {
-			logMethod.WithFields(logrus.Fields{"error": err, "url": res.url}).Error("error making request to relay")
+		if res.err != nil {
+			m.timings.finish(res.timing, args.Message.Slot, res.err)
+			failures.request(res.err)
+			m.relayFault(ctx, res.url, RelayFaultRequest, res.err)
+			logMethod.WithFields(Fields{"error": res.err, "url": res.url}).Error("error making request to relay")
 			continue
 		}
-		if res.res.Error != nil {
-			logMethod.WithFields(logrus.Fields{"error": res.res.Error, "url": res.url}).Warn("error reply from relay")
+		if res.rpcErr != nil {
+			m.timings.finish(res.timing, args.Message.Slot, res.rpcErr)
+			failures.request(res.rpcErr)
+			m.relayFault(ctx, res.url, RelayFaultRequest, res.rpcErr)
+			logMethod.WithFields(Fields{"error": res.rpcErr, "url": res.url}).Warn("error reply from relay")
 			continue
 		}
-
-		// Decode response
-		err = json.Unmarshal(res.res.Result, result)
+		if err := m.fillTransactionsRoot(res.payload, logMethod); err != nil {
+			m.timings.finish(res.timing, args.Message.Slot, err)
+			failures.invalid()
+			m.relayFault(ctx, res.url, RelayFaultInvalid, err)
+			continue
+		}
+		err := matchHeader(header, res.payload)
+		if err == nil {
+			err = verifyPayload(res.payload)
+		}
+		if err != nil {
+			m.timings.finish(res.timing, args.Message.Slot, err)
+			failures.mismatched()
+			m.relayFault(ctx, res.url, RelayFaultMismatch, err)
+			logMethod.WithFields(Fields{
+				"error":            err,
+				"url":              res.url,
+				"blockHash":        blockHash,
+				"payloadBlockHash": res.payload.BlockHash,
+			}).Error("relay revealed a payload that doesn't match the signed header")
+			continue
+		}
+		err = m.validation.validatePayload(ctx, res.url, res.payload)
+		if err == nil {
+			err = tenant.validatePayload(ctx, res.url, res.payload)
+		}
+		if err == nil {
+			err = m.simulation.simulate(ctx, res.url, res.payload)
+		}
+		res.timing.bid(res.payload.FeeRecipientDiff)
+		m.timings.finish(res.timing, args.Message.Slot, err)
 		if err != nil {
-			logMethod.WithFields(logrus.Fields{"error": err, "data": string(res.res.Result)}).Error("Could not unmarshal response")
+			failures.invalid()
+			m.relayFault(ctx, res.url, RelayFaultInvalid, err)
+			logMethod.WithFields(Fields{"error": err, "url": res.url, "blockHash": res.payload.BlockHash}).Warn("payload rejected by validation policy")
 			continue
 		}
+		*result = *res.payload
 
 		// Cancel other requests
 		requestCtxCancel()
-		logMethod.WithFields(logrus.Fields{
+		logMethod.WithFields(Fields{
+			"slot":      m.chain.SlotAt(result.Timestamp),
 			"blockHash": result.BlockHash,
 			"number":    result.Number,
 			"txRoot":    fmt.Sprintf("%#x", result.TransactionsRoot),
 		}).Info("ProposeBlindedBlockV1: revealed new payload from relay")
+		m.recordDelivery(ctx, args.Message, result, res.url)
+		m.verifyPayment(ctx, result, logMethod)
+		m.events.publish(ctx, payloadEvent(EventPayloadRevealed, res.url, args.Message.Slot, result))
 		return nil
 	}
 
-	logMethod.WithFields(logrus.Fields{
+	if err := clientGone(req); err != nil {
+		logMethod.WithError(err).Warn("ProposeBlindedBlockV1: consensus client disconnected")
+		return err
+	}
+
+	logMethod.WithFields(Fields{
 		"blockHash": blockHash,
 	}).Error("ProposeBlindedBlockV1: no valid response from relay")
-	return fmt.Errorf("no valid response from relay for block with hash %s", blockHash)
+	return newMethodError(failures.kind(ErrUnknownPayload), "no valid response from relay for block with hash %s", blockHash)
+}
+
+// proposalRelays returns the relays of relayURLs a signed block of a bid of bidRelayURL is sent to. Only the relay of the
+// bid is known to hold its payload, the others don't need to see the signed block, unless signed blocks fan out to all
+// relays that bid for the slot. All relays get the block if the relay of the bid isn't one of them.
+func (m *RelayService) proposalRelays(relayURLs []string, bidRelayURL string, slot uint64) []string {
+	bidders := map[string]bool{bidRelayURL: true}
+	if m.proposalFanOut {
+		for _, bid := range m.bids.slot(slot) {
+			bidders[bid.RelayURL] = true
+		}
+	}
+	var selected []string
+	found := false
+	for _, url := range relayURLs {
+		if bidders[url] {
+			selected = append(selected, url)
+			found = found || url == bidRelayURL
+		}
+	}
+	if !found {
+		return relayURLs
+	}
+	return selected
 }
 
 // GetPayloadHeaderV1 TODO
-func (m *RelayService) GetPayloadHeaderV1(_ *http.Request, args *string, result *ExecutionPayloadWithTxRootV1) error {
+func (m *RelayService) GetPayloadHeaderV1(req *http.Request, args *string, result *ExecutionPayloadWithTxRootV1) error {
 	method := "engine_getPayloadV1"
-	logMethod := m.log.WithField("method", method)
+	logMethod := withTraceFields(requestContext(req), m.log.WithField("method", method))
+	ctx, cancel := m.withBudget(requestContext(req))
+	defer cancel()
 
-	payloadID := new(hexutil.Bytes)
-	err := payloadID.UnmarshalText([]byte(*args))
+	payloadID, err := parsePayloadID(*args)
 	if err != nil {
 		return err
 	}
 
-	forkchoiceResponses, found := m.store.GetForkchoiceResponse(payloadID.String())
-	if !found {
-		return fmt.Errorf("no ForkChoiceResponses for payloadID %s", payloadID)
+	if err := m.payloadIDs.check(payloadID.String()); err != nil {
+		logMethod.WithError(err).Warn("GetPayloadHeaderV1: rejecting stale payloadID")
+		return err
 	}
 
-	// Call the relay
-	resultC := make(chan *rpcResponseContainer, len(forkchoiceResponses))
-	for relayURL, relayPayloadID := range forkchoiceResponses {
-		go func(url, payloadID string) {
-			res, err := makeRequest(context.Background(), url, "relay_getPayloadHeaderV1", []interface{}{payloadID})
-			resultC <- &rpcResponseContainer{url, err, res}
-		}(relayURL, relayPayloadID)
+	forkchoiceResponses, found := m.store.GetForkchoiceResponse(ctx, payloadID.String())
+	if !found && !m.local.has(payloadID.String()) {
+		return newMethodError(ErrUnknownPayload, "no ForkChoiceResponses for payloadID %s", payloadID)
+	}
+	tenant := tenantFromContext(ctx)
+	if tenant != nil {
+		forkchoiceResponses = tenant.relaysOf(forkchoiceResponses)
 	}
 
-	// Process the responses
-	for i := 0; i < cap(resultC); i++ {
-		res := <-resultC
+	var feeRecipient common.Address
+	attributes := m.store.GetPayloadAttributes(ctx, payloadID.String())
+	if attributes != nil {
+		feeRecipient = attributes.SuggestedFeeRecipient
+	}
+	if feeRecipient == (common.Address{}) && m.feeFallback != (common.Address{}) {
+		feeRecipient = m.feeFallback
+		logMethod.WithFields(Fields{"payloadID": payloadID, "feeRecipient": feeRecipient}).Warn("GetPayloadHeaderV1: no fee recipient registered for payloadID, the bid is verified against the default fee recipient")
+	}
+	if m.groups != nil {
+		forkchoiceResponses = m.groups.relaysOf(feeRecipient, forkchoiceResponses)
+	}
 
-		// Check for errors
-		if res.err != nil {
-			logMethod.WithFields(logrus.Fields{"error": res.err, "url": res.url}).Warn("error making request to relay")
+	var proposal ProposalKey
+	if attributes != nil && (m.stableHeaders != nil || m.equivocation != nil) {
+		proposal = m.proposalKey(ctx, payloadID.String(), attributes, logMethod)
+	}
+	if m.equivocation != nil && attributes != nil {
+		if err := m.equivocation.checkHeader(proposal, logMethod); err != nil {
+			return err
+		}
+		defer func() {
+			if result.BlockHash != nilHash {
+				m.equivocation.headerReturned(proposal)
+			}
+		}()
+	}
+	if m.stableHeaders != nil && attributes != nil {
+		defer m.stableHeaders.lock(proposal)()
+
+		if header := m.store.GetProposalHeader(ctx, proposal); header != nil {
+			logMethod.WithFields(Fields{
+				"payloadID": payloadID,
+				"blockHash": header.BlockHash,
+			}).Info("GetPayloadHeaderV1: returning header already returned for this proposal")
+			*result = *header
+			return nil
+		}
+		defer func() {
+			if result.BlockHash == nilHash {
+				return
+			}
+			header := new(ExecutionPayloadWithTxRootV1)
+			*header = *result
+			if kept := m.store.SetProposalHeader(ctx, proposal, header); kept.BlockHash != header.BlockHash {
+				// another router sharing the store returned a header for the proposal in the meantime
+				logMethod.WithFields(Fields{"payloadID": payloadID, "blockHash": kept.BlockHash}).Warn("GetPayloadHeaderV1: returning header another frontend returned for this proposal")
+				*result = *kept
+			}
+		}()
+	}
+
+	var slot uint64
+	if attributes != nil {
+		slot = m.chain.SlotAt(uint64(attributes.Timestamp))
+	}
+	// the chain state is looked up while the relays are asked for headers, lookup failures don't block the proposal
+	waitView := func() (*beaconView, error) { return nil, nil }
+	if attributes != nil {
+		waitView = m.chainChecks.viewAsync(ctx, slot)
+	}
+
+	fetched, ok := m.prefetcher.wait(ctx, payloadID.String())
+	if ok {
+		// the prefetch asked all relays of the payload id, the tenant and relay groups of the request may use fewer
+		fetched = fetched.of(forkchoiceResponses)
+		logMethod.WithField("payloadID", payloadID).Debug("GetPayloadHeaderV1: using prefetched headers")
+	} else {
+		fetched = m.fetchHeaders(ctx, forkchoiceResponses, slot, logMethod)
+	}
+	bids, candidates, failures := fetched.bids, fetched.candidates, fetched.failures
+
+	if err := clientGone(req); err != nil {
+		logMethod.WithError(err).Warn("GetPayloadHeaderV1: consensus client disconnected")
+		return err
+	}
+
+	view, err := waitView()
+	if err != nil {
+		logMethod.WithError(err).Warn("GetPayloadHeaderV1: could not look up chain state on the beacon node, skipping chain state checks")
+	} else if view != nil {
+		if err := m.chainChecks.check(view, slot); err != nil {
+			logMethod.WithError(err).WithField("headRoot", view.headRoot).Error("GetPayloadHeaderV1: chain state of the beacon node doesn't match the header request")
+			return err
+		}
+		candidates = m.rejectOffHead(candidates, view, failures, logMethod)
+	}
+	if m.headerChecks != nil {
+		candidates = m.rejectInconsistentHeaders(candidates, m.headerExpectations(ctx, payloadID.String(), attributes, logMethod), failures, logMethod)
+	}
+
+	candidates = m.compareShadowBids(candidates, logMethod)
+
+	// Offer the candidates most profitable first, by the value weighted with the weight of their relay. On equal value the
+	// first response wins, unless a bid selector breaks ties or picks among bids close to the best. Near the deadline, slow
+	// relays are discounted, and relay groups may reorder them. If relays built on different parents, the candidates
+	// building on the head of the consensus client come first.
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return m.relays.weighted(candidates[i].RelayURL, bidValue(candidates[i].Header)).Cmp(m.relays.weighted(candidates[j].RelayURL, bidValue(candidates[j].Header))) > 0
+	})
+	if m.selector != nil {
+		candidates = m.selector.SelectBids(candidates, m.relays.weight)
+	}
+	candidates = m.revealWeights.order(m.chain, candidates, m.probes)
+	candidates = m.groups.order(feeRecipient, candidates)
+	if parents := parentDivergence(candidates); parents != nil {
+		head, ok := m.heads.get(payloadID.String())
+		bidAnomaliesTotal.WithLabelValues(bidAnomalyDivergentParents).Inc()
+		fields := Fields{"payloadID": payloadID, "kind": bidAnomalyDivergentParents, "parents": parents}
+		if ok {
+			fields["localHead"] = head
+			candidates = preferParent(candidates, head)
+		}
+		logMethod.WithFields(fields).Warn("GetPayloadHeaderV1: relays built on different parents, possibly a reorg or a misbehaving relay")
+	}
+	// the min bid preference of the proposer applies if the proposer is known
+	var proposer string
+	if attributes != nil && m.preferences.hasMinBid() {
+		proposer = m.proposerOf(ctx, slot, feeRecipient, logMethod)
+	}
+	for _, candidate := range candidates {
+		if !tenant.usesRelay(candidate.RelayURL) {
 			continue
 		}
-		if res.res.Error != nil {
-			logMethod.WithFields(logrus.Fields{"error": res.res.Error, "url": res.url}).Warn("error reply from relay")
+		if m.escrow && candidate.Header.Transactions == nil {
+			m.bids.setResult(candidate.RelayURL, candidate.Header.BlockHash, BidResultNotEscrowed, nil)
 			continue
 		}
-
-		// Decode response
-		_result := new(ExecutionPayloadWithTxRootV1)
-		err := json.Unmarshal(res.res.Result, _result)
-		if err != nil {
-			logMethod.WithFields(logrus.Fields{"error": err, "data": string(res.res.Result)}).Warn("Could not unmarshal response")
+		if !tenant.acceptsBid(bidValue(candidate.Header)) || !m.acceptsBid(proposer, bidValue(candidate.Header)) {
+			m.bids.setResult(candidate.RelayURL, candidate.Header.BlockHash, BidResultBelowMinBid, nil)
 			continue
 		}
-
-		// Skip processing this result if lower fee than previous
-		if result.FeeRecipientDiff != nil {
-			if _result.FeeRecipientDiff == nil || _result.FeeRecipientDiff.Cmp(result.FeeRecipientDiff) < 1 {
-				continue
-			}
+		if err := tenant.validateHeader(ctx, candidate.RelayURL, candidate.Header); err != nil {
+			m.bids.setResult(candidate.RelayURL, candidate.Header.BlockHash, BidResultInvalid, err)
+			failures.invalid()
+			logMethod.WithFields(Fields{"error": err, "url": candidate.RelayURL, "blockHash": candidate.Header.BlockHash}).Warn("header rejected by validation policy of tenant")
+			continue
+		}
+		if err := m.bidDecision.decide(ctx, candidates, candidate); err != nil {
+			m.bids.setResult(candidate.RelayURL, candidate.Header.BlockHash, BidResultVetoed, err)
+			logMethod.WithFields(Fields{"error": err, "url": candidate.RelayURL, "blockHash": candidate.Header.BlockHash}).Warn("GetPayloadHeaderV1: bid vetoed by decision callback")
+			continue
 		}
 
-		// Use this relay's response as mev-boost response because it's most profitable
-		*result = *_result
-
+		*result = *candidate.Header
+		m.bids.setResult(candidate.RelayURL, candidate.Header.BlockHash, BidResultWon, nil)
+		m.store.SetBid(ctx, result.BlockHash, &Bid{
+			RelayURL:     candidate.RelayURL,
+			FeeRecipient: feeRecipient,
+			Value:        result.FeeRecipientDiff,
+			Signature:    fetched.signatures[candidate.Header],
+		})
+		m.events.publish(ctx, payloadEvent(EventBidSelected, candidate.RelayURL, m.chain.SlotAt(result.Timestamp), candidate.Header))
 		if result.Transactions != nil {
-			logMethod.WithFields(logrus.Fields{
-				"blockHash": result.BlockHash,
-				"number":    result.Number,
-			}).Info("GetPayloadHeaderV1: calculating tx root from tx list")
-
-			var byteTxs [][]byte
-			for i, otx := range *result.Transactions {
-				var tx types.Transaction
-				bytesTx := common.Hex2Bytes(otx)
-				if err := tx.UnmarshalBinary(bytesTx); err != nil {
-					logMethod.WithFields(logrus.Fields{
-						"err":   err,
-						"tx":    string(bytesTx),
-						"count": i,
-					}).Error("Failed to decode tx")
-					continue
-				}
-				byteTxs = append(byteTxs, bytesTx)
-			}
-
-			newRootBytes, err := txroot.TransactionsRoot(byteTxs)
-			if err != nil {
-				logMethod.WithField("err", err).Error("Error calculating tx root")
-				continue
-			}
-			newRoot := common.BytesToHash(newRootBytes[:])
-
-			if result.TransactionsRoot != nilHash {
-				if newRoot != result.TransactionsRoot {
-					err := fmt.Errorf("mismatched tx root: %s, %s", newRoot.String(), result.TransactionsRoot.String())
-					logMethod.WithField("err", err).Error("Mismatched tx root")
-					continue
-				}
-			}
-			result.TransactionsRoot = newRoot
-
 			// copy this payload for later retrieval in proposeBlindedBlock
 			payload := new(ExecutionPayloadWithTxRootV1)
 			*payload = *result
-			m.store.SetExecutionPayload(result.BlockHash, payload)
+			m.store.SetExecutionPayload(ctx, result.BlockHash, payload)
 		}
 		result.Transactions = nil
 
-		logMethod.WithFields(logrus.Fields{
+		logMethod.WithFields(Fields{
+			"slot":      m.chain.SlotAt(result.Timestamp),
 			"blockHash": result.BlockHash,
 			"number":    result.Number,
 			"txRoot":    fmt.Sprintf("%#x", result.TransactionsRoot),
 		}).Info("GetPayloadHeaderV1: successfully got payload header")
+		break
+	}
+
+	for _, anomaly := range detectBidAnomalies(bids) {
+		bidAnomaliesTotal.WithLabelValues(anomaly.Kind).Inc()
+		logMethod.WithFields(Fields{
+			"payloadID": payloadID,
+			"kind":      anomaly.Kind,
+			"urls":      anomaly.RelayURLs,
+			"blockHash": anomaly.BlockHash,
+			"value":     anomaly.Value,
+			"median":    anomaly.Median,
+		}).Warn("GetPayloadHeaderV1: anomalous bids across relays")
 	}
 
+	if result.BlockHash == nilHash && m.local != nil {
+		m.serveLocalPayload(ctx, payloadID.String(), attributes, result, logMethod)
+	}
 	if result.BlockHash == nilHash {
-		logMethod.WithFields(logrus.Fields{
+		logMethod.WithFields(Fields{
 			"payloadID": payloadID,
 		}).Error("GetPayloadHeaderV1: no valid response from relay")
-		return fmt.Errorf("no valid response from relay for payloadID %s", payloadID)
+		return m.noBids.noBids(payloadID, failures, result)
+	}
+
+	return nil
+}
+
+// headerFetch is the outcome of requesting headers from the relays for a payload id
+type headerFetch struct {
+	bids       []bidObservation
+	candidates []BidCandidate
+	signatures map[*ExecutionPayloadWithTxRootV1][]byte // verified bid signatures of the candidates, by header
+	failures   *relayFailures
+}
+
+// fetchHeaders requests headers from the relays of forkchoiceResponses and returns the valid ones. slot is the slot of
+// the payload, if known, the fork of the current slot is assumed otherwise.
+func (m *RelayService) fetchHeaders(ctx context.Context, forkchoiceResponses map[string]string, slot uint64, logMethod Logger) *headerFetch {
+	if slot == 0 {
+		ctx = withCapella(ctx, m.chain, m.chain.CurrentSlot())
+	} else {
+		ctx = withCapella(ctx, m.chain, slot)
+	}
+
+	// Call the relay, unless it would reject the request because the min bid is below its floor
+	tenant := tenantFromContext(ctx)
+	relayURLs := make([]string, 0, len(forkchoiceResponses))
+	for _, relayURL := range m.inConfiguredOrder(forkchoiceResponses) {
+		if !m.capabilities.supports(relayURL, forkMethod(ctx, methodRelayGetHeader)) || !m.health.available(relayURL) || !m.relays.enabled(relayURL) {
+			continue
+		}
+		if floor := m.capabilities.minBid(relayURL); !m.satisfiesFloor(tenant, floor) {
+			logMethod.WithFields(Fields{"url": relayURL, "relayMinBid": floor}).Debug("min bid is below the bid floor of the relay, not requesting a header")
+			continue
+		}
+		relayURLs = append(relayURLs, relayURL)
+	}
+	// In race mode the calls outlive the cutoff and the caller, so late headers are still recorded
+	requestCtx, cancelRequests := ctx, context.CancelFunc(func() {})
+	if m.raceCutoff > 0 {
+		requestCtx, cancelRequests = context.WithTimeout(detachContext(ctx), m.chain.SlotDuration())
+	}
+	resultC := make(chan *rpcResponseContainer, len(relayURLs))
+	for _, relayURL := range m.ordering.order(relayURLs) {
+		go func(url, payloadID string) {
+			res, timing, err := m.requestRelay(requestCtx, url, methodRelayGetHeader, []interface{}{payloadID})
+			container := &rpcResponseContainer{url: url, err: err, res: res, timing: timing}
+			if err == nil && res.Error == nil {
+				m.prevalidateHeader(requestCtx, container, logMethod)
+			}
+			resultC <- container
+		}(relayURL, forkchoiceResponses[relayURL])
+	}
+
+	// Process the responses, in race mode until the cutoff passed and a valid header arrived
+	fetched := newHeaderFetch()
+	race := m.newHeaderRace(ctx)
+	defer race.stop()
+	pending := cap(resultC)
+	for pending > 0 && !race.over(fetched) {
+		select {
+		case res := <-resultC:
+			pending--
+			m.processHeaderResponse(ctx, res, slot, fetched, logMethod)
+		case <-race.cutoff:
+			race.cutoffPassed()
+		case <-race.done:
+			race.callerGone()
+		}
+	}
+	if pending == 0 {
+		cancelRequests()
+		return fetched
+	}
+	logMethod.WithFields(Fields{"pending": pending, "received": len(fetched.candidates)}).Debug("race cutoff passed, not waiting for the other relays")
+	go m.recordLateHeaders(requestCtx, cancelRequests, resultC, pending, slot, logMethod)
+	return fetched
+}
+
+func newHeaderFetch() *headerFetch {
+	return &headerFetch{signatures: make(map[*ExecutionPayloadWithTxRootV1][]byte), failures: new(relayFailures)}
+}
+
+// of returns the bids and candidates of the relays of forkchoiceResponses, in a new headerFetch
+func (f *headerFetch) of(forkchoiceResponses map[string]string) *headerFetch {
+	filtered := &headerFetch{signatures: f.signatures, failures: f.failures}
+	for _, bid := range f.bids {
+		if _, ok := forkchoiceResponses[bid.RelayURL]; ok {
+			filtered.bids = append(filtered.bids, bid)
+		}
+	}
+	for _, candidate := range f.candidates {
+		if _, ok := forkchoiceResponses[candidate.RelayURL]; ok {
+			filtered.candidates = append(filtered.candidates, candidate)
+		}
+	}
+	return filtered
+}
+
+// processHeaderResponse checks a header response of a relay and adds its header to fetched if it's valid
+func (m *RelayService) processHeaderResponse(ctx context.Context, res *rpcResponseContainer, slot uint64, fetched *headerFetch, logMethod Logger) {
+	// Check for errors
+	if errors.Is(ctx.Err(), context.Canceled) { // the caller gave up, don't record bids nobody will see
+		m.timings.finish(res.timing, slot, ctx.Err())
+		return
+	}
+	if res.err != nil {
+		m.timings.finish(res.timing, slot, res.err)
+		fetched.failures.request(res.err)
+		m.relayFault(ctx, res.url, RelayFaultRequest, res.err)
+		logMethod.WithFields(Fields{"error": res.err, "url": res.url}).Warn("error making request to relay")
+		return
+	}
+	if res.res.Error != nil {
+		m.timings.finish(res.timing, slot, res.res.Error)
+		fetched.failures.request(res.res.Error)
+		m.relayFault(ctx, res.url, RelayFaultRequest, res.res.Error)
+		logMethod.WithFields(Fields{"error": res.res.Error, "url": res.url}).Warn("error reply from relay")
+		return
+	}
+
+	// The header was decoded and validated as soon as it arrived
+	_result := res.header
+	if _result == nil {
+		m.timings.finish(res.timing, slot, res.invalid)
+		fetched.failures.invalid()
+		m.relayFault(ctx, res.url, RelayFaultInvalid, res.invalid)
+		logMethod.WithFields(Fields{"error": res.invalid, "data": string(res.res.Result)}).Warn("Could not unmarshal response")
+		return
+	}
+	res.timing.bid(_result.FeeRecipientDiff)
+	m.timings.finish(res.timing, m.chain.SlotAt(_result.Timestamp), res.invalid)
+	if res.invalid != nil {
+		m.archiveBid(res.url, _result, res.invalid)
+		fetched.failures.invalid()
+		m.relayFault(ctx, res.url, RelayFaultInvalid, res.invalid)
+		logMethod.WithFields(Fields{"error": res.invalid, "url": res.url, "blockHash": _result.BlockHash}).Warn("header rejected by validation")
+		return
+	}
+	m.archiveBid(res.url, _result, nil)
+	m.events.publish(ctx, payloadEvent(EventBidReceived, res.url, m.chain.SlotAt(_result.Timestamp), _result))
+	fetched.bids = append(fetched.bids, bidObservation{res.url, _result.BlockHash, _result.FeeRecipientDiff})
+	fetched.candidates = append(fetched.candidates, BidCandidate{res.url, _result})
+	if res.signature != nil {
+		fetched.signatures[_result] = res.signature
+	}
+}
+
+// bidValue returns the value a header promises to the proposer, zero if unknown
+func bidValue(header *ExecutionPayloadWithTxRootV1) *big.Int {
+	if header.FeeRecipientDiff == nil {
+		return new(big.Int)
+	}
+	return header.FeeRecipientDiff
+}
+
+// fillTransactionsRoot computes the transactions root of a header that came with a transaction list, and rejects it if it contradicts the root the relay sent.
+// The withdrawals root of a capella header that came with its withdrawals is filled in the same way.
+func (m *RelayService) fillTransactionsRoot(header *ExecutionPayloadWithTxRootV1, logMethod Logger) error {
+	if header.Transactions == nil {
+		return nil
+	}
+	logMethod.WithFields(Fields{
+		"blockHash": header.BlockHash,
+		"number":    header.Number,
+	}).Info("calculating tx root from tx list")
+
+	byteTxs, _, err := decodeTransactions(*header.Transactions)
+	if err != nil {
+		logMethod.WithField("err", err).Error("Failed to decode tx")
+		return err
+	}
+	newRoot, err := transactionsRoot(byteTxs)
+	if err != nil {
+		logMethod.WithField("err", err).Error("Error calculating tx root")
+		return err
 	}
 
+	if header.TransactionsRoot != nilHash && newRoot != header.TransactionsRoot {
+		err := fmt.Errorf("mismatched tx root: %s, %s", newRoot.String(), header.TransactionsRoot.String())
+		logMethod.WithField("err", err).Error("Mismatched tx root")
+		return err
+	}
+	header.TransactionsRoot = newRoot
+	if err := fillWithdrawalsRoot(header); err != nil {
+		logMethod.WithField("err", err).Error("Mismatched withdrawals root")
+		return err
+	}
 	return nil
 }
+
+func (m *RelayService) handleRelayAccounting(w http.ResponseWriter, _ *http.Request) {
+	accounts := m.accounting.snapshot()
+	for _, account := range accounts {
+		account.Suspended = m.blacklist.isSuspended(account.RelayURL)
+	}
+	respondJSON(w, http.StatusOK, accounts)
+}
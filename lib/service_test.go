@@ -0,0 +1,100 @@
+package lib
+
+import (
+	"context"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_makeRequestInto(t *testing.T) {
+	payload := ExecutionPayloadWithTxRootV1{
+		BlockHash:        common.HexToHash("0x1"),
+		BaseFeePerGas:    big.NewInt(4),
+		FeeRecipientDiff: big.NewInt(1),
+	}
+	resp, err := formatResponse(payload)
+	require.Nil(t, err)
+	errResp, err := formatErrorResponse("no payload")
+	require.Nil(t, err)
+
+	tests := []struct {
+		name        string
+		response    []byte
+		maxSize     int64
+		wantRPCErr  bool
+		wantErr     bool
+		wantPayload bool
+	}{
+		{"decodes result", resp, maxRelayResponseSize, false, false, true},
+		{"error reply", errResp, maxRelayResponseSize, true, false, false},
+		{"response of exactly the limit", resp, int64(len(resp)), false, false, true},
+		{"response too large", resp, 16, false, true, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Write(tt.response)
+			}))
+			defer server.Close()
+
+			result := new(ExecutionPayloadWithTxRootV1)
+			rpcErr, err := makeRequestInto(context.Background(), &httpClient, server.URL, methodRelayProposeBlock, []interface{}{}, result, responseLimit{size: tt.maxSize}, false)
+			require.Equal(t, tt.wantErr, err != nil, err)
+			require.Equal(t, tt.wantRPCErr, rpcErr != nil)
+			if tt.wantPayload {
+				require.Equal(t, payload.BlockHash, result.BlockHash)
+				require.Equal(t, payload.FeeRecipientDiff, result.FeeRecipientDiff)
+			}
+		})
+	}
+}
+
+func Test_makeRequest_limit(t *testing.T) {
+	resp, err := formatResponse(ForkChoiceResponse{PayloadStatus: PayloadStatus{Status: ForkchoiceStatusValid}})
+	require.Nil(t, err)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(resp)
+	}))
+	defer server.Close()
+
+	_, err = makeRequest(context.Background(), &httpClient, server.URL, methodForkchoiceUpdated, []interface{}{}, responseLimit{size: int64(len(resp))})
+	require.Nil(t, err)
+	_, err = makeRequest(context.Background(), &httpClient, server.URL, methodForkchoiceUpdated, []interface{}{}, responseLimit{size: int64(len(resp) - 1)})
+	require.ErrorIs(t, err, ErrValidationFailed)
+}
+
+func TestRelayService_ClientDisconnect(t *testing.T) {
+	blockRelay := make(chan struct{})
+	relay := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-blockRelay
+	}))
+	defer relay.Close()
+	defer close(blockRelay)
+
+	store := NewStore()
+	store.SetForkchoiceResponse(context.Background(), "0x0102030405060708", relay.URL, "0x01")
+	service, err := newRelayService(WithRelayURLs(relay.URL), WithStore(store), WithLogger(testLog))
+	require.Nil(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	req := httptest.NewRequest(http.MethodPost, "/", nil).WithContext(ctx)
+	done := make(chan error)
+	go func() {
+		payloadID := "0x0102030405060708"
+		done <- service.GetPayloadHeaderV1(req, &payloadID, new(ExecutionPayloadWithTxRootV1))
+	}()
+
+	cancel()
+	select {
+	case err := <-done:
+		require.ErrorIs(t, err, context.Canceled)
+	case <-time.After(time.Second):
+		t.Fatal("request wasn't aborted after the client disconnected")
+	}
+}
@@ -0,0 +1,65 @@
+package lib
+
+import (
+	"math/big"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Outcomes of shadow bids compared with the best bid of the relays that are used for proposals
+const (
+	shadowBidHigher = "higher" // more valuable than the best bid
+	shadowBidEqual  = "equal"
+	shadowBidLower  = "lower"
+	shadowBidOnly   = "only" // no relay used for proposals had a valid bid
+)
+
+var shadowBidsTotal = metricsFactory.NewCounterVec(prometheus.CounterOpts{
+	Name: "mevboost_shadow_bids_total",
+	Help: "Valid bids of shadow relays, by relay and how they compare with the best bid of the other relays: higher, equal, lower or only",
+}, []string{"relay", "outcome"})
+
+// compareShadowBids removes the candidates of shadow relays, which are never offered to the proposer, and logs and
+// counts how their value compares with the best candidate of the other relays. Shadow bids are archived as
+// BidResultShadow.
+func (m *RelayService) compareShadowBids(candidates []BidCandidate, logMethod Logger) []BidCandidate {
+	var shadows, used []BidCandidate
+	for _, candidate := range candidates {
+		if m.relays.shadow(candidate.RelayURL) {
+			shadows = append(shadows, candidate)
+		} else {
+			used = append(used, candidate)
+		}
+	}
+	if len(shadows) == 0 {
+		return candidates
+	}
+
+	var best *big.Int
+	for _, candidate := range used {
+		if value := bidValue(candidate.Header); best == nil || value.Cmp(best) > 0 {
+			best = value
+		}
+	}
+	for _, shadow := range shadows {
+		value := bidValue(shadow.Header)
+		fields := Fields{"url": shadow.RelayURL, "blockHash": shadow.Header.BlockHash, "value": value}
+		outcome := shadowBidOnly
+		if best != nil {
+			fields["bestValue"], fields["difference"] = best, new(big.Int).Sub(value, best)
+			switch value.Cmp(best) {
+			case 1:
+				outcome = shadowBidHigher
+			case 0:
+				outcome = shadowBidEqual
+			default:
+				outcome = shadowBidLower
+			}
+		}
+		fields["outcome"] = outcome
+		shadowBidsTotal.WithLabelValues(shadow.RelayURL, outcome).Inc()
+		m.bids.setResult(shadow.RelayURL, shadow.Header.BlockHash, BidResultShadow, nil)
+		logMethod.WithFields(fields).Info("GetPayloadHeaderV1: bid of shadow relay, not offered to the proposer")
+	}
+	return used
+}
@@ -0,0 +1,51 @@
+package lib
+
+import (
+	"context"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRelayService_ShadowRelays(t *testing.T) {
+	used := newHeaderRelay(t, ExecutionPayloadWithTxRootV1{BlockHash: common.HexToHash("0x01"), BaseFeePerGas: big.NewInt(1), FeeRecipientDiff: big.NewInt(1)})
+	defer used.Close()
+	var shadowCalls int64
+	shadow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt64(&shadowCalls, 1)
+		resp, err := formatResponse(ExecutionPayloadWithTxRootV1{BlockHash: common.HexToHash("0x02"), BaseFeePerGas: big.NewInt(1), FeeRecipientDiff: big.NewInt(5)})
+		require.Nil(t, err)
+		w.Write(resp)
+	}))
+	defer shadow.Close()
+
+	store := NewStore()
+	for _, relayURL := range []string{used.URL, shadow.URL} {
+		store.SetForkchoiceResponse(context.Background(), "0x01", relayURL, "0x01")
+	}
+	service, err := newRelayService(WithRelays(RelayConfig{URL: used.URL}, RelayConfig{URL: shadow.URL, Shadow: true}), WithStore(store), WithLogger(testLog))
+	require.Nil(t, err)
+
+	payloadID := "0x01"
+	result := new(ExecutionPayloadWithTxRootV1)
+	require.Nil(t, service.GetPayloadHeaderV1(nil, &payloadID, result))
+	require.Equal(t, common.HexToHash("0x01"), result.BlockHash, "the more valuable bid of the shadow relay isn't offered")
+	require.Equal(t, int64(1), atomic.LoadInt64(&shadowCalls))
+	for _, bid := range service.bids.all() {
+		if bid.RelayURL == shadow.URL {
+			require.Equal(t, BidResultShadow, bid.Result)
+		}
+	}
+
+	block := &SignedBlindedBeaconBlock{Message: &BlindedBeaconBlock{Body: &BlindedBeaconBlockBody{ExecutionPayloadHeader: (&ExecutionPayloadWithTxRootV1{BlockHash: common.HexToHash("0x03")}).Header()}}}
+	_ = service.ProposeBlindedBlockV1(nil, block, new(ExecutionPayloadWithTxRootV1))
+	require.Equal(t, int64(1), atomic.LoadInt64(&shadowCalls), "shadow relays never get signed blocks")
+
+	_, err = newRelayService(WithRelays(RelayConfig{URL: shadow.URL, Shadow: true}), WithLogger(testLog))
+	require.Error(t, err, "no relay is used for proposals")
+}
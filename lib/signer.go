@@ -0,0 +1,138 @@
+package lib
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+// Signer signs messages mev-boost creates itself on behalf of a validator
+type Signer interface {
+	// PublicKeys returns the validator keys the signer can sign with
+	PublicKeys(ctx context.Context) ([]string, error)
+	// SignValidatorRegistration signs a registration with the key of registration.Pubkey
+	SignValidatorRegistration(ctx context.Context, registration *ValidatorRegistrationV1) (*SignedValidatorRegistrationV1, error)
+}
+
+// Web3Signer is a Signer that delegates signing to a Web3Signer compatible remote signer, so mev-boost never holds validator keys
+type Web3Signer struct {
+	url    string
+	domain [32]byte
+}
+
+// NewWeb3Signer creates a signer for the Web3Signer at url, signing builder messages of the given chain
+func NewWeb3Signer(url string, chain *ChainConfig) *Web3Signer {
+	if chain == nil {
+		chain = MainnetChainConfig
+	}
+	return &Web3Signer{
+		url:    strings.TrimRight(url, "/"),
+		domain: chain.BuilderDomain(),
+	}
+}
+
+// PublicKeys implements Signer
+func (s *Web3Signer) PublicKeys(ctx context.Context) ([]string, error) {
+	body, err := s.do(ctx, http.MethodGet, "/api/v1/eth2/publicKeys", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var pubkeys []string
+	if err := json.Unmarshal(body, &pubkeys); err != nil {
+		return nil, err
+	}
+	return pubkeys, nil
+}
+
+// web3SignerRegistrationRequest is the body of a VALIDATOR_REGISTRATION signing request
+type web3SignerRegistrationRequest struct {
+	Type                  string                   `json:"type"`
+	SigningRoot           hexutil.Bytes            `json:"signingRoot"`
+	ValidatorRegistration *ValidatorRegistrationV1 `json:"validator_registration"`
+}
+
+// SignValidatorRegistration implements Signer
+func (s *Web3Signer) SignValidatorRegistration(ctx context.Context, registration *ValidatorRegistrationV1) (*SignedValidatorRegistrationV1, error) {
+	root, err := registration.HashTreeRoot()
+	if err != nil {
+		return nil, err
+	}
+	signingRoot := ComputeSigningRoot(root, s.domain)
+
+	reqBody, err := json.Marshal(web3SignerRegistrationRequest{
+		Type:                  "VALIDATOR_REGISTRATION",
+		SigningRoot:           signingRoot[:],
+		ValidatorRegistration: registration,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	body, err := s.do(ctx, http.MethodPost, "/api/v1/eth2/sign/"+registration.Pubkey.String(), reqBody)
+	if err != nil {
+		return nil, err
+	}
+
+	signature, err := parseWeb3SignerSignature(body)
+	if err != nil {
+		return nil, err
+	}
+	return &SignedValidatorRegistrationV1{Message: registration, Signature: signature}, nil
+}
+
+// parseWeb3SignerSignature accepts both the JSON and the plain text response of the sign endpoint
+func parseWeb3SignerSignature(body []byte) (hexutil.Bytes, error) {
+	signature := strings.TrimSpace(string(body))
+	if strings.HasPrefix(signature, "{") {
+		container := struct {
+			Signature string `json:"signature"`
+		}{}
+		if err := json.Unmarshal(body, &container); err != nil {
+			return nil, err
+		}
+		signature = container.Signature
+	}
+
+	decoded, err := hexutil.Decode(signature)
+	if err != nil {
+		return nil, fmt.Errorf("invalid signature from remote signer: %w", err)
+	}
+	if len(decoded) != 96 {
+		return nil, errors.New("invalid signature length from remote signer")
+	}
+	return decoded, nil
+}
+
+func (s *Web3Signer) do(ctx context.Context, method, path string, reqBody []byte) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, method, s.url+path, bytes.NewReader(reqBody))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Add("Accept", "application/json")
+	if reqBody != nil {
+		req.Header.Add("Content-Type", "application/json")
+	}
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("remote signer returned status %d for %s: %s", resp.StatusCode, path, string(body))
+	}
+	return body, nil
+}
@@ -0,0 +1,117 @@
+package lib
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWeb3Signer_SignValidatorRegistration(t *testing.T) {
+	pubkey := hexutil.Bytes(common.FromHex("0x" + strings.Repeat("ab", 48)))
+	signature := "0x" + strings.Repeat("cd", 96)
+	registration := &ValidatorRegistrationV1{
+		FeeRecipient: common.HexToAddress("0xdb65fEd33dc262Fe09D9a2Ba8F80b329BA25f941"),
+		GasLimit:     30000000,
+		Timestamp:    1655000000,
+		Pubkey:       pubkey,
+	}
+	root, err := registration.HashTreeRoot()
+	require.Nil(t, err)
+	expectedSigningRoot := ComputeSigningRoot(root, SepoliaChainConfig.BuilderDomain())
+
+	tests := []struct {
+		name     string
+		response string
+	}{
+		{"json response", `{"signature":"` + signature + `"}`},
+		{"plain text response", signature},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				require.Equal(t, "/api/v1/eth2/sign/"+pubkey.String(), r.URL.Path)
+				body, err := ioutil.ReadAll(r.Body)
+				require.Nil(t, err)
+
+				req := new(web3SignerRegistrationRequest)
+				require.Nil(t, json.Unmarshal(body, req))
+				require.Equal(t, "VALIDATOR_REGISTRATION", req.Type)
+				require.Equal(t, hexutil.Bytes(expectedSigningRoot[:]), req.SigningRoot)
+				require.Equal(t, registration, req.ValidatorRegistration)
+				w.Write([]byte(tt.response))
+			}))
+			defer server.Close()
+
+			signed, err := NewWeb3Signer(server.URL, SepoliaChainConfig).SignValidatorRegistration(context.Background(), registration)
+			require.Nil(t, err)
+			require.Equal(t, signature, signed.Signature.String())
+			require.Equal(t, registration, signed.Message)
+		})
+	}
+}
+
+// newMockWeb3Signer signs registrations with secretKey like a Web3Signer holding the key
+func newMockWeb3Signer(t *testing.T, secretKey int64) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		req := new(web3SignerRegistrationRequest)
+		require.Nil(t, json.NewDecoder(r.Body).Decode(req))
+		var signingRoot [32]byte
+		copy(signingRoot[:], req.SigningRoot)
+		_, signature := blsSign(big.NewInt(secretKey), signingRoot)
+		json.NewEncoder(w).Encode(map[string]string{"signature": hexutil.Encode(signature)})
+	}))
+}
+
+func TestRelayService_withPreferences(t *testing.T) {
+	signer := newMockWeb3Signer(t, 1)
+	defer signer.Close()
+	received := make(chan []SignedValidatorRegistrationV1, 4)
+	relay := newRegistrationRelay(t, 0, received)
+	defer relay.Close()
+	service, err := newRelayService(WithRelayURLs(relay.URL), WithLogger(testLog), WithSigner(NewWeb3Signer(signer.URL, MainnetChainConfig)))
+	require.Nil(t, err)
+
+	timestamp := uint64(time.Now().Unix())
+	registration := testRegistration(t, 1, timestamp)
+	pubkey := registration.Message.Pubkey.String()
+	feeRecipient := common.HexToAddress("0x02")
+	service.preferences.update(pubkey, func(p *ValidatorPreferences) { p.FeeRecipient = &feeRecipient })
+
+	// registrations of the consensus client are signed anew with the preferences
+	var result string
+	require.Nil(t, service.RegisterValidatorV1(nil, &[]SignedValidatorRegistrationV1{registration}, &result))
+	broadcast := <-received
+	require.Equal(t, 1, len(broadcast))
+	require.Equal(t, feeRecipient, broadcast[0].Message.FeeRecipient)
+	require.Equal(t, registration.Message.GasLimit, broadcast[0].Message.GasLimit)
+	require.Less(t, timestamp, broadcast[0].Message.Timestamp)
+	require.Nil(t, service.verifyRegistration(&broadcast[0]))
+	require.Equal(t, &broadcast[0], service.store.GetValidatorRegistration(context.Background(), pubkey))
+
+	// so are cached registrations once the preferences change
+	gasLimit := uint64(36_000_000)
+	service.preferences.update(pubkey, func(p *ValidatorPreferences) { p.GasLimit = &gasLimit })
+	service.registerPreferences(context.Background(), pubkey, testLog)
+	broadcast = <-received
+	require.Equal(t, feeRecipient, broadcast[0].Message.FeeRecipient)
+	require.Equal(t, gasLimit, broadcast[0].Message.GasLimit)
+	require.Nil(t, service.verifyRegistration(&broadcast[0]))
+
+	// and left alone if they match
+	service.registerPreferences(context.Background(), pubkey, testLog)
+	select {
+	case registrations := <-received:
+		t.Fatalf("unchanged registration was broadcast again: %v", registrations)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
@@ -0,0 +1,90 @@
+package lib
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+)
+
+var (
+	// DomainTypeAppBuilder is the domain type of builder API messages, see https://github.com/ethereum/builder-specs
+	DomainTypeAppBuilder = [4]byte{0x00, 0x00, 0x00, 0x01}
+	// DomainTypeBeaconProposer is the domain type of beacon blocks
+	DomainTypeBeaconProposer = [4]byte{0x00, 0x00, 0x00, 0x00}
+)
+
+// ComputeDomain implements compute_domain of the consensus specs
+func ComputeDomain(domainType [4]byte, forkVersion [4]byte, genesisValidatorsRoot [32]byte) [32]byte {
+	// hash_tree_root(ForkData) of a Bytes4 and a Root is the hash of both, each padded to 32 bytes
+	var forkData [64]byte
+	copy(forkData[:4], forkVersion[:])
+	copy(forkData[32:], genesisValidatorsRoot[:])
+	forkDataRoot := sha256.Sum256(forkData[:])
+
+	var domain [32]byte
+	copy(domain[:4], domainType[:])
+	copy(domain[4:], forkDataRoot[:28])
+	return domain
+}
+
+// ComputeSigningRoot implements compute_signing_root of the consensus specs for an object root
+func ComputeSigningRoot(objectRoot [32]byte, domain [32]byte) [32]byte {
+	var signingData [64]byte
+	copy(signingData[:32], objectRoot[:])
+	copy(signingData[32:], domain[:])
+	return sha256.Sum256(signingData[:])
+}
+
+// BuilderDomain is the signing domain of builder API messages, which uses the genesis fork version and an empty genesis validators root
+func (c *ChainConfig) BuilderDomain() [32]byte {
+	return ComputeDomain(DomainTypeAppBuilder, c.GenesisForkVersion, [32]byte{})
+}
+
+// HashTreeRoot returns the SSZ hash tree root of the registration
+func (r *ValidatorRegistrationV1) HashTreeRoot() ([32]byte, error) {
+	if len(r.Pubkey) != 48 {
+		return [32]byte{}, fmt.Errorf("invalid pubkey length %d", len(r.Pubkey))
+	}
+
+	var feeRecipient, gasLimit, timestamp [32]byte
+	copy(feeRecipient[:], r.FeeRecipient[:])
+	binary.LittleEndian.PutUint64(gasLimit[:], r.GasLimit)
+	binary.LittleEndian.PutUint64(timestamp[:], r.Timestamp)
+
+	// a Bytes48 spans two chunks
+	var pubkeyChunks [64]byte
+	copy(pubkeyChunks[:], r.Pubkey)
+	pubkey := sha256.Sum256(pubkeyChunks[:])
+
+	return merkleize([][32]byte{feeRecipient, gasLimit, timestamp, pubkey}), nil
+}
+
+// merkleize returns the merkle root of chunks, padded with zero chunks to a power of two
+func merkleize(chunks [][32]byte) [32]byte {
+	return merkleizeWithLimit(chunks, len(chunks))
+}
+
+// merkleizeWithLimit returns the merkle root of chunks, padded with zero chunks to the power of two of limit, as for SSZ lists
+func merkleizeWithLimit(chunks [][32]byte, limit int) [32]byte {
+	width := 1
+	for width < limit {
+		width *= 2
+	}
+	layer := make([][32]byte, width)
+	copy(layer, chunks)
+	for len(layer) > 1 {
+		next := make([][32]byte, len(layer)/2)
+		for i := range next {
+			next[i] = sha256.Sum256(append(layer[2*i][:], layer[2*i+1][:]...))
+		}
+		layer = next
+	}
+	return layer[0]
+}
+
+// mixInLength returns the root of an SSZ list from the root of its elements
+func mixInLength(root [32]byte, length int) [32]byte {
+	var lengthChunk [32]byte
+	binary.LittleEndian.PutUint64(lengthChunk[:], uint64(length))
+	return sha256.Sum256(append(root[:], lengthChunk[:]...))
+}
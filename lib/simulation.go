@@ -0,0 +1,42 @@
+package lib
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var payloadSimulationsTotal = metricsFactory.NewCounterVec(prometheus.CounterOpts{
+	Name: "mevboost_payload_simulations_total",
+	Help: "Revealed payloads submitted to the local execution client before they were returned, by relay and the status it reported, error if it didn't answer",
+}, []string{"relay", "status"})
+
+// payloadSimulator submits revealed payloads to an execution client of the operator, only payloads it reports as valid
+// are returned to the consensus client
+type payloadSimulator struct {
+	el      *ExecutionClient
+	timeout time.Duration
+}
+
+// simulate returns an error wrapping ErrValidationFailed unless the execution client reports the payload as VALID
+// within the timeout. Syncing or accepted payloads fail too, the execution client couldn't execute them.
+func (s *payloadSimulator) simulate(ctx context.Context, relayURL string, payload *ExecutionPayloadWithTxRootV1) error {
+	if s == nil {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	status, err := s.el.NewPayload(ctx, payload)
+	if err != nil {
+		payloadSimulationsTotal.WithLabelValues(relayURL, "error").Inc()
+		return fmt.Errorf("%w: simulation on %s failed: %v", ErrValidationFailed, s.el.URL(), err)
+	}
+	payloadSimulationsTotal.WithLabelValues(relayURL, string(status.Status)).Inc()
+	if status.Status != ForkchoiceStatusValid {
+		return fmt.Errorf("%w: %s reported %s for payload %s: %s", ErrValidationFailed, s.el.URL(), status.Status, payload.BlockHash, status.ValidationError)
+	}
+	return nil
+}
@@ -0,0 +1,98 @@
+package lib
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/stretchr/testify/require"
+)
+
+// newSimulationEngine returns an engine API answering engine_newPayloadV1 with status, after delay
+func newSimulationEngine(t *testing.T, status *atomic.Value, delay time.Duration) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		require.Nil(t, err)
+		var req struct {
+			Method string               `json:"method"`
+			Params []executionPayloadV1 `json:"params"`
+		}
+		require.Nil(t, json.Unmarshal(body, &req))
+		require.Equal(t, "engine_newPayloadV1", req.Method)
+		require.Len(t, req.Params, 1)
+		require.NotEmpty(t, req.Params[0].Transactions)
+		require.NotEmpty(t, r.Header.Get("Authorization"), "the engine API is authenticated")
+
+		time.Sleep(delay)
+		resp, err := formatResponse(PayloadStatus{Status: status.Load().(ForkchoiceStatus), ValidationError: "bad block"})
+		require.Nil(t, err)
+		w.Write(resp)
+	}))
+}
+
+func TestRelayService_PayloadSimulation(t *testing.T) {
+	payload := blockPayload(t, testBlock(t))
+	raw, _, err := decodeTransactions(*payload.Transactions)
+	require.Nil(t, err)
+	payload.TransactionsRoot, err = transactionsRoot(raw)
+	require.Nil(t, err)
+	relay := newHeaderRelay(t, payload)
+	defer relay.Close()
+	status := new(atomic.Value)
+	status.Store(ForkchoiceStatusValid)
+	engine := newSimulationEngine(t, status, 0)
+	defer engine.Close()
+
+	service, err := newRelayService(WithRelayURLs(relay.URL), WithStore(NewStore()), WithLogger(testLog),
+		WithPayloadSimulation(NewEngineClient(engine.URL, make([]byte, 32)), time.Second))
+	require.Nil(t, err)
+	block := &SignedBlindedBeaconBlock{Message: &BlindedBeaconBlock{Body: &BlindedBeaconBlockBody{ExecutionPayloadHeader: payload.Header()}}}
+	result := new(ExecutionPayloadWithTxRootV1)
+	require.Nil(t, service.ProposeBlindedBlockV1(nil, block, result))
+	require.Equal(t, payload.BlockHash, result.BlockHash)
+
+	status.Store(ForkchoiceStatusInvalid)
+	err = service.ProposeBlindedBlockV1(nil, block, new(ExecutionPayloadWithTxRootV1))
+	require.True(t, errors.Is(err, ErrValidationFailed), "invalid payloads aren't returned")
+	status.Store(ForkchoiceStatusSyncing)
+	err = service.ProposeBlindedBlockV1(nil, block, new(ExecutionPayloadWithTxRootV1))
+	require.True(t, errors.Is(err, ErrValidationFailed), "payloads the execution client couldn't execute aren't returned")
+
+	status.Store(ForkchoiceStatusValid)
+	slow := newSimulationEngine(t, status, 200*time.Millisecond)
+	defer slow.Close()
+	simulator := &payloadSimulator{el: NewEngineClient(slow.URL, make([]byte, 32)), timeout: 50 * time.Millisecond}
+	err = simulator.simulate(context.Background(), relay.URL, &payload)
+	require.True(t, errors.Is(err, ErrValidationFailed), "simulations time out")
+}
+
+func TestExecutionClient_NewPayload(t *testing.T) {
+	payload := capellaPayload(t)
+	engine := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		require.Nil(t, err)
+		var req struct {
+			Method string               `json:"method"`
+			Params []executionPayloadV1 `json:"params"`
+		}
+		require.Nil(t, json.Unmarshal(body, &req))
+		require.Equal(t, "engine_newPayloadV2", req.Method, "payloads with withdrawals are capella payloads")
+		require.Equal(t, payload.Withdrawals, req.Params[0].Withdrawals)
+		require.Equal(t, (*payload.Transactions)[0], hexutil.Encode(req.Params[0].Transactions[0]))
+		resp, err := formatResponse(PayloadStatus{Status: ForkchoiceStatusValid})
+		require.Nil(t, err)
+		w.Write(resp)
+	}))
+	defer engine.Close()
+
+	status, err := NewEngineClient(engine.URL, make([]byte, 32)).NewPayload(context.Background(), &payload)
+	require.Nil(t, err)
+	require.Equal(t, ForkchoiceStatusValid, status.Status)
+}
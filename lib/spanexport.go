@@ -0,0 +1,298 @@
+package lib
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Span kinds and status codes of OTLP, see https://github.com/open-telemetry/opentelemetry-proto
+const (
+	otlpSpanKindServer = 2
+	otlpSpanKindClient = 3
+	otlpStatusError    = 2
+
+	// spanBufferSize is how many finished spans wait for the next export before new ones are dropped
+	spanBufferSize = 4096
+	// spanExportBatchSize is the most spans sent to the collector at once
+	spanExportBatchSize = 512
+)
+
+// spanExportInterval is how often finished spans are sent to the collector
+var spanExportInterval = 5 * time.Second
+
+var spansDroppedTotal = metricsFactory.NewCounter(prometheus.CounterOpts{
+	Name: "mevboost_spans_dropped_total",
+	Help: "Finished spans not exported because the buffer was full or the collector failed",
+})
+
+// exportedSpan is a finished span of mev-boost, a served JSON-RPC call or a relay call
+type exportedSpan struct {
+	traceID    string
+	spanID     string
+	parentID   string // empty for the root span of a trace
+	name       string
+	kind       int
+	start, end time.Time
+	attributes map[string]string
+	err        error
+}
+
+// spanExporter sends finished spans to an OpenTelemetry collector with OTLP over HTTP in its JSON encoding, in batches
+// every spanExportInterval. Spans are dropped rather than slowing down requests when the collector can't keep up.
+type spanExporter struct {
+	url     string // the traces endpoint of the collector
+	service string
+	client  *http.Client
+	log     Logger
+	spans   chan *exportedSpan
+}
+
+func newSpanExporter(endpoint, service string, log Logger) *spanExporter {
+	return &spanExporter{
+		url:     strings.TrimSuffix(endpoint, "/") + "/v1/traces",
+		service: service,
+		client:  &http.Client{Timeout: spanExportInterval},
+		log:     log.WithField("prefix", "lib/spanexport"),
+		spans:   make(chan *exportedSpan, spanBufferSize),
+	}
+}
+
+// export queues a finished span for the next export
+func (e *spanExporter) export(span *exportedSpan) {
+	if e == nil {
+		return
+	}
+	select {
+	case e.spans <- span:
+	default:
+		spansDroppedTotal.Inc()
+	}
+}
+
+// start exports the queued spans every spanExportInterval until ctx is done
+func (e *spanExporter) start(ctx context.Context) {
+	runLoop(ctx, e.log, "span_export", spanExportInterval, false, e.flush)
+}
+
+// flush sends the queued spans to the collector
+func (e *spanExporter) flush(ctx context.Context) {
+	for {
+		var batch []*exportedSpan
+	drain:
+		for len(batch) < spanExportBatchSize {
+			select {
+			case span := <-e.spans:
+				batch = append(batch, span)
+			default:
+				break drain
+			}
+		}
+		if len(batch) == 0 {
+			return
+		}
+		if err := e.send(ctx, batch); err != nil {
+			spansDroppedTotal.Add(float64(len(batch)))
+			e.log.WithFields(Fields{"spans": len(batch), "error": err}).Warn("could not export spans")
+			return
+		}
+	}
+}
+
+func (e *spanExporter) send(ctx context.Context, batch []*exportedSpan) error {
+	body, err := json.Marshal(e.request(batch))
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("collector answered %d: %s", resp.StatusCode, bytes.TrimSpace(message))
+	}
+	return nil
+}
+
+// The OTLP JSON encoding of an export request, ids are hex and timestamps are decimal strings
+type (
+	otlpExportRequest struct {
+		ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+	}
+	otlpResourceSpans struct {
+		Resource   otlpResource     `json:"resource"`
+		ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+	}
+	otlpResource struct {
+		Attributes []otlpAttribute `json:"attributes"`
+	}
+	otlpScopeSpans struct {
+		Scope otlpScope  `json:"scope"`
+		Spans []otlpSpan `json:"spans"`
+	}
+	otlpScope struct {
+		Name string `json:"name"`
+	}
+	otlpSpan struct {
+		TraceID           string          `json:"traceId"`
+		SpanID            string          `json:"spanId"`
+		ParentSpanID      string          `json:"parentSpanId,omitempty"`
+		Name              string          `json:"name"`
+		Kind              int             `json:"kind"`
+		StartTimeUnixNano string          `json:"startTimeUnixNano"`
+		EndTimeUnixNano   string          `json:"endTimeUnixNano"`
+		Attributes        []otlpAttribute `json:"attributes,omitempty"`
+		Status            otlpStatus      `json:"status"`
+	}
+	otlpAttribute struct {
+		Key   string    `json:"key"`
+		Value otlpValue `json:"value"`
+	}
+	otlpValue struct {
+		StringValue string `json:"stringValue"`
+	}
+	otlpStatus struct {
+		Code    int    `json:"code,omitempty"`
+		Message string `json:"message,omitempty"`
+	}
+)
+
+func otlpAttributes(attributes map[string]string) []otlpAttribute {
+	keys := make([]string, 0, len(attributes))
+	for key := range attributes {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	encoded := make([]otlpAttribute, 0, len(keys))
+	for _, key := range keys {
+		encoded = append(encoded, otlpAttribute{Key: key, Value: otlpValue{StringValue: attributes[key]}})
+	}
+	return encoded
+}
+
+func (e *spanExporter) request(batch []*exportedSpan) *otlpExportRequest {
+	spans := make([]otlpSpan, 0, len(batch))
+	for _, span := range batch {
+		encoded := otlpSpan{
+			TraceID:           span.traceID,
+			SpanID:            span.spanID,
+			ParentSpanID:      span.parentID,
+			Name:              span.name,
+			Kind:              span.kind,
+			StartTimeUnixNano: strconv.FormatInt(span.start.UnixNano(), 10),
+			EndTimeUnixNano:   strconv.FormatInt(span.end.UnixNano(), 10),
+			Attributes:        otlpAttributes(span.attributes),
+		}
+		if span.err != nil {
+			encoded.Status = otlpStatus{Code: otlpStatusError, Message: span.err.Error()}
+		}
+		spans = append(spans, encoded)
+	}
+	return &otlpExportRequest{ResourceSpans: []otlpResourceSpans{{
+		Resource:   otlpResource{Attributes: otlpAttributes(map[string]string{"service.name": e.service})},
+		ScopeSpans: []otlpScopeSpans{{Scope: otlpScope{Name: "github.com/flashbots/mev-boost/lib"}, Spans: spans}},
+	}}}
+}
+
+// middleware records a server span for each JSON-RPC call, named after its method. The span continues the trace of
+// the consensus client with WithTraceContext, and starts a new trace otherwise. Relay calls become its children.
+func (e *spanExporter) middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			next.ServeHTTP(w, r)
+			return
+		}
+		body, err := io.ReadAll(io.LimitReader(r.Body, maxRelayResponseSize))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		var call struct {
+			Method string `json:"method"`
+		}
+		if json.Unmarshal(body, &call) != nil || call.Method == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		trace := &traceContext{traceID: randomHex(16), flags: "01"}
+		parentID := ""
+		if parent := traceContextFromContext(r.Context()); parent != nil {
+			copied := *parent
+			trace, parentID = &copied, parent.parentID
+		}
+		trace.parentID = randomHex(8)
+		if trace.traceID == "" || trace.parentID == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		span := &exportedSpan{
+			traceID:  trace.traceID,
+			spanID:   trace.parentID,
+			parentID: parentID,
+			name:     call.Method,
+			kind:     otlpSpanKindServer,
+			start:    now(),
+			attributes: map[string]string{
+				"rpc.system":          "jsonrpc",
+				"rpc.method":          call.Method,
+				"mevboost.request_id": requestIDFromContext(r.Context()),
+			},
+		}
+		recorder := &statusRecorder{ResponseWriter: w, code: http.StatusOK}
+		next.ServeHTTP(recorder, r.WithContext(context.WithValue(r.Context(), traceContextKey{}, trace)))
+		span.end = now()
+		span.attributes["http.status_code"] = strconv.Itoa(recorder.code)
+		if recorder.code >= http.StatusInternalServerError {
+			span.err = fmt.Errorf("status %d", recorder.code)
+		}
+		e.export(span)
+	})
+}
+
+// relayCall exports the span of a relay call: ctx is the one startRelaySpan returned for the call and parent the trace
+// context it was started in. Only the host of the relay is recorded, relay urls may hold credentials.
+func (e *spanExporter) relayCall(ctx context.Context, parent *traceContext, relayURL, method string, start time.Time, err error) {
+	span := traceContextFromContext(ctx)
+	if e == nil || parent == nil || span == nil || span == parent {
+		return
+	}
+	host := ""
+	if u, parseErr := url.Parse(relayURL); parseErr == nil {
+		host = u.Host
+	}
+	e.export(&exportedSpan{
+		traceID:  span.traceID,
+		spanID:   span.parentID,
+		parentID: parent.parentID,
+		name:     method,
+		kind:     otlpSpanKindClient,
+		start:    start,
+		end:      now(),
+		attributes: map[string]string{
+			"rpc.system":          "jsonrpc",
+			"rpc.method":          method,
+			"server.address":      host,
+			"mevboost.request_id": requestIDFromContext(ctx),
+		},
+		err: err,
+	})
+}
@@ -0,0 +1,100 @@
+package lib
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRouter_SpanExport(t *testing.T) {
+	defer func(interval time.Duration) { spanExportInterval = interval }(spanExportInterval)
+	spanExportInterval = 100 * time.Millisecond
+
+	var traceparent string
+	relay := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		traceparent = r.Header.Get("traceparent")
+		resp, err := formatResponse(ExecutionPayloadWithTxRootV1{BlockHash: common.HexToHash("0x01"), BaseFeePerGas: big.NewInt(1), FeeRecipientDiff: big.NewInt(1)})
+		require.Nil(t, err)
+		w.Write(resp)
+	}))
+	defer relay.Close()
+	exports := make(chan otlpExportRequest, 1)
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "/v1/traces", r.URL.Path)
+		var req otlpExportRequest
+		require.Nil(t, json.NewDecoder(r.Body).Decode(&req))
+		exports <- req
+	}))
+	defer collector.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	store := NewStore()
+	store.SetForkchoiceResponse(ctx, "0x01", relay.URL, "0x01")
+	router, err := NewRouter(ctx, WithRelayURLs(relay.URL), WithStore(store), WithLogger(testLog), WithCapabilityCheckInterval(0),
+		WithTraceContext(), WithSpanExport(collector.URL+"/", "mev-boost-test"))
+	require.Nil(t, err)
+
+	body, err := formatRequestBody("builder_getPayloadHeaderV1", []interface{}{"0x01"})
+	require.Nil(t, err)
+	req := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	req.Header.Set(requestIDHeader, "beacon-1")
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	require.Equal(t, http.StatusOK, rr.Code)
+
+	var export otlpExportRequest
+	select {
+	case export = <-exports:
+	case <-time.After(2 * spanExportInterval):
+		t.Fatal("spans weren't exported")
+	}
+	require.Len(t, export.ResourceSpans, 1)
+	require.Equal(t, []otlpAttribute{{Key: "service.name", Value: otlpValue{StringValue: "mev-boost-test"}}}, export.ResourceSpans[0].Resource.Attributes)
+	spans := export.ResourceSpans[0].ScopeSpans[0].Spans
+	require.Len(t, spans, 2)
+	call, server := spans[0], spans[1] // the relay call ends first
+	require.Equal(t, "builder_getPayloadHeaderV1", server.Name)
+	require.Equal(t, otlpSpanKindServer, server.Kind)
+	require.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", server.TraceID)
+	require.Equal(t, "00f067aa0ba902b7", server.ParentSpanID, "the span continues the trace of the consensus client")
+	require.Contains(t, server.Attributes, otlpAttribute{Key: "mevboost.request_id", Value: otlpValue{StringValue: "beacon-1"}})
+
+	require.Equal(t, methodRelayGetHeader, call.Name)
+	require.Equal(t, otlpSpanKindClient, call.Kind)
+	require.Equal(t, server.TraceID, call.TraceID)
+	require.Equal(t, server.SpanID, call.ParentSpanID)
+	require.Equal(t, "00-"+call.TraceID+"-"+call.SpanID+"-01", traceparent, "the relay is sent the span of its call")
+	require.Contains(t, call.Attributes, otlpAttribute{Key: "server.address", Value: otlpValue{StringValue: strings.TrimPrefix(relay.URL, "http://")}})
+}
+
+func TestSpanExporter_flush(t *testing.T) {
+	var batches []int
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req otlpExportRequest
+		require.Nil(t, json.NewDecoder(r.Body).Decode(&req))
+		batches = append(batches, len(req.ResourceSpans[0].ScopeSpans[0].Spans))
+	}))
+	defer collector.Close()
+
+	exporter := newSpanExporter(collector.URL, "mev-boost", testLog)
+	for i := 0; i < spanExportBatchSize+1; i++ {
+		exporter.export(&exportedSpan{traceID: "01", spanID: "02", name: "call", err: context.Canceled})
+	}
+	exporter.flush(context.Background())
+	require.Equal(t, []int{spanExportBatchSize, 1}, batches)
+
+	var nilExporter *spanExporter
+	nilExporter.export(&exportedSpan{})
+}
@@ -0,0 +1,489 @@
+package lib
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/big"
+	"mime"
+	"net/http"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// sszContentType is the media type of SSZ encoded relay responses, as in the builder API
+const sszContentType = "application/octet-stream"
+
+// sszAccept is the Accept header of relay calls that take SSZ responses, relays that don't speak SSZ answer with JSON
+const sszAccept = "application/octet-stream;q=1.0, application/json;q=0.9"
+
+// Limits of the SSZ types of the bellatrix specs
+const (
+	maxExtraDataBytes         = 32
+	maxTransactionsPerPayload = 1048576
+	maxBytesPerTransaction    = 1073741824
+)
+
+// Sizes of the fixed parts of the SSZ containers, variable-size fields take a 4 byte offset
+const (
+	sszOffsetSize                   = 4
+	executionPayloadPrefixSize      = 32 + 20 + 32 + 32 + 256 + 32 + 4*8
+	executionPayloadHeaderFixedSize = executionPayloadPrefixSize + sszOffsetSize + 32 + 32 + 32
+	executionPayloadFixedSize       = executionPayloadPrefixSize + sszOffsetSize + 32 + 32 + sszOffsetSize
+	builderBidFixedSize             = sszOffsetSize + 32 + 48
+	signedBuilderBidFixedSize       = sszOffsetSize + 96
+)
+
+var relayResponseFormatsTotal = metricsFactory.NewCounterVec(prometheus.CounterOpts{
+	Name: "mevboost_relay_response_formats_total",
+	Help: "Relay responses to calls that accept SSZ, by relay and format: ssz or json",
+}, []string{"relay", "format"})
+
+// sszUnmarshaler is implemented by the relay types with an SSZ encoding. Their methods are the ones of the ssz.Marshaler
+// and ssz.Unmarshaler interfaces of fastssz, so relays can use the types as they are.
+type sszUnmarshaler interface {
+	UnmarshalSSZ(buf []byte) error
+}
+
+// sszMethods are the relay methods whose results have an SSZ encoding
+var sszMethods = map[string]bool{
+	methodRelayGetHeader:    true,
+	methodRelayProposeBlock: true,
+}
+
+type sszContextKey struct{}
+
+// withSSZ marks the relay calls of ctx to accept SSZ responses
+func withSSZ(ctx context.Context) context.Context {
+	return context.WithValue(ctx, sszContextKey{}, true)
+}
+
+func acceptsSSZ(ctx context.Context) bool {
+	accepts, _ := ctx.Value(sszContextKey{}).(bool)
+	return accepts
+}
+
+// setSSZAccept asks the relay for an SSZ response, if the call of ctx accepts one
+func setSSZAccept(ctx context.Context, req *http.Request) {
+	if acceptsSSZ(ctx) {
+		req.Header.Set("Accept", sszAccept)
+	}
+}
+
+// isSSZResponse reports whether resp has an SSZ body
+func isSSZResponse(resp *http.Response) bool {
+	mediaType, _, err := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	return err == nil && mediaType == sszContentType
+}
+
+// readSSZResponse reads the decoded body of resp if it's SSZ, and counts the format of the responses of calls that
+// accept SSZ. Relays answer with SSZ only on success, error replies are JSON.
+func readSSZResponse(ctx context.Context, url string, resp *http.Response, body io.Reader, limit responseLimit) ([]byte, bool, error) {
+	if !acceptsSSZ(ctx) {
+		return nil, false, nil
+	}
+	if !isSSZResponse(resp) {
+		relayResponseFormatsTotal.WithLabelValues(url, "json").Inc()
+		return nil, false, nil
+	}
+	relayResponseFormatsTotal.WithLabelValues(url, "ssz").Inc()
+	if resp.StatusCode != http.StatusOK {
+		return nil, true, fmt.Errorf("%w: SSZ response with status %d", ErrValidationFailed, resp.StatusCode)
+	}
+	data, err := ioutil.ReadAll(limit.reader(body))
+	return data, true, err
+}
+
+// unmarshalSSZ decodes the SSZ result of a relay into result
+func unmarshalSSZ(data []byte, result interface{}) error {
+	target, ok := result.(sszUnmarshaler)
+	if !ok {
+		return fmt.Errorf("%w: unexpected SSZ response", ErrValidationFailed)
+	}
+	if err := target.UnmarshalSSZ(data); err != nil {
+		return fmt.Errorf("%w: could not decode SSZ response: %v", ErrValidationFailed, err)
+	}
+	return nil
+}
+
+// checkedResult buffers a JSON result of a relay, so its fields can be checked by the relay decoder before it's decoded.
+// SSZ results have no fields to check and are decoded into result right away.
+type checkedResult struct {
+	raw    json.RawMessage
+	result interface{}
+	ssz    bool
+}
+
+// UnmarshalJSON implements json.Unmarshaler
+func (c *checkedResult) UnmarshalJSON(data []byte) error {
+	c.raw = append(c.raw[:0], data...)
+	return nil
+}
+
+// UnmarshalSSZ implements sszUnmarshaler
+func (c *checkedResult) UnmarshalSSZ(data []byte) error {
+	c.ssz = true
+	target, ok := c.result.(sszUnmarshaler)
+	if !ok {
+		return errors.New("no SSZ encoding")
+	}
+	return target.UnmarshalSSZ(data)
+}
+
+func appendUint32(buf []byte, v uint32) []byte {
+	return append(buf, byte(v), byte(v>>8), byte(v>>16), byte(v>>24))
+}
+
+func appendUint64(buf []byte, v uint64) []byte {
+	var b [8]byte
+	binary.LittleEndian.PutUint64(b[:], v)
+	return append(buf, b[:]...)
+}
+
+// appendUint256 appends v as little endian uint256
+func appendUint256(buf []byte, v *big.Int) ([]byte, error) {
+	if v == nil || v.Sign() < 0 || v.BitLen() > 256 {
+		return nil, fmt.Errorf("invalid uint256 %v", v)
+	}
+	be := v.FillBytes(make([]byte, 32))
+	for i := len(be) - 1; i >= 0; i-- {
+		buf = append(buf, be[i])
+	}
+	return buf, nil
+}
+
+// sszReader reads the fixed-size fields of an SSZ container in order, the caller checks the length of the buffer
+type sszReader struct {
+	buf []byte
+	pos int
+}
+
+func (r *sszReader) bytes(n int) []byte {
+	b := r.buf[r.pos : r.pos+n]
+	r.pos += n
+	return b
+}
+
+func (r *sszReader) uint32() uint32 {
+	return binary.LittleEndian.Uint32(r.bytes(4))
+}
+
+func (r *sszReader) uint64() uint64 {
+	return binary.LittleEndian.Uint64(r.bytes(8))
+}
+
+func (r *sszReader) uint256() *big.Int {
+	le := r.bytes(32)
+	be := make([]byte, 32)
+	for i := range le {
+		be[31-i] = le[i]
+	}
+	return new(big.Int).SetBytes(be)
+}
+
+// appendExecutionPayloadPrefix appends the fields up to the timestamp, which headers and payloads have in common
+func appendExecutionPayloadPrefix(buf []byte, h *ExecutionPayloadHeaderV1) []byte {
+	buf = append(buf, h.ParentHash[:]...)
+	buf = append(buf, h.FeeRecipient[:]...)
+	buf = append(buf, h.StateRoot[:]...)
+	buf = append(buf, h.ReceiptsRoot[:]...)
+	buf = append(buf, h.LogsBloom[:]...)
+	buf = append(buf, h.PrevRandao[:]...)
+	buf = appendUint64(buf, h.BlockNumber)
+	buf = appendUint64(buf, h.GasLimit)
+	buf = appendUint64(buf, h.GasUsed)
+	return appendUint64(buf, h.Timestamp)
+}
+
+// readExecutionPayloadPrefix reads the fields written by appendExecutionPayloadPrefix
+func readExecutionPayloadPrefix(r *sszReader, h *ExecutionPayloadHeaderV1) {
+	copy(h.ParentHash[:], r.bytes(32))
+	copy(h.FeeRecipient[:], r.bytes(20))
+	copy(h.StateRoot[:], r.bytes(32))
+	copy(h.ReceiptsRoot[:], r.bytes(32))
+	copy(h.LogsBloom[:], r.bytes(256))
+	copy(h.PrevRandao[:], r.bytes(32))
+	h.BlockNumber = r.uint64()
+	h.GasLimit = r.uint64()
+	h.GasUsed = r.uint64()
+	h.Timestamp = r.uint64()
+}
+
+// SizeSSZ returns the size of the SSZ encoding of the header
+func (h *ExecutionPayloadHeaderV1) SizeSSZ() int {
+	return executionPayloadHeaderFixedSize + len(h.ExtraData)
+}
+
+// MarshalSSZ returns the SSZ encoding of the header
+func (h *ExecutionPayloadHeaderV1) MarshalSSZ() ([]byte, error) {
+	return h.MarshalSSZTo(make([]byte, 0, h.SizeSSZ()))
+}
+
+// MarshalSSZTo appends the SSZ encoding of the header to buf
+func (h *ExecutionPayloadHeaderV1) MarshalSSZTo(buf []byte) ([]byte, error) {
+	if len(h.ExtraData) > maxExtraDataBytes {
+		return nil, fmt.Errorf("invalid extra_data length %d", len(h.ExtraData))
+	}
+	buf = appendExecutionPayloadPrefix(buf, h)
+	buf = appendUint32(buf, executionPayloadHeaderFixedSize)
+	buf, err := appendUint256(buf, h.BaseFeePerGas)
+	if err != nil {
+		return nil, fmt.Errorf("invalid base_fee_per_gas: %w", err)
+	}
+	buf = append(buf, h.BlockHash[:]...)
+	buf = append(buf, h.TransactionsRoot[:]...)
+	return append(buf, h.ExtraData...), nil
+}
+
+// UnmarshalSSZ decodes the SSZ encoding of a header
+func (h *ExecutionPayloadHeaderV1) UnmarshalSSZ(buf []byte) error {
+	if len(buf) < executionPayloadHeaderFixedSize {
+		return fmt.Errorf("header of %d bytes is too short", len(buf))
+	}
+	var dec ExecutionPayloadHeaderV1
+	r := &sszReader{buf: buf}
+	readExecutionPayloadPrefix(r, &dec)
+	if offset := r.uint32(); offset != executionPayloadHeaderFixedSize {
+		return fmt.Errorf("invalid extra_data offset %d", offset)
+	}
+	dec.BaseFeePerGas = r.uint256()
+	copy(dec.BlockHash[:], r.bytes(32))
+	copy(dec.TransactionsRoot[:], r.bytes(32))
+	extraData := buf[executionPayloadHeaderFixedSize:]
+	if len(extraData) > maxExtraDataBytes {
+		return fmt.Errorf("invalid extra_data length %d", len(extraData))
+	}
+	dec.ExtraData = append([]byte{}, extraData...)
+	*h = dec
+	return nil
+}
+
+// SizeSSZ returns the size of the SSZ encoding of the payload, 0 if it can't be encoded
+func (p *ExecutionPayloadWithTxRootV1) SizeSSZ() int {
+	size := executionPayloadFixedSize + len(p.ExtraData)
+	if p.Transactions != nil {
+		for _, tx := range *p.Transactions {
+			decoded, err := hexutil.Decode(tx)
+			if err != nil {
+				return 0
+			}
+			size += sszOffsetSize + len(decoded)
+		}
+	}
+	return size
+}
+
+// MarshalSSZ returns the SSZ encoding of the payload as ExecutionPayload of the bellatrix specs, without transactions
+// root and FeeRecipientDiff
+func (p *ExecutionPayloadWithTxRootV1) MarshalSSZ() ([]byte, error) {
+	return p.MarshalSSZTo(make([]byte, 0, p.SizeSSZ()))
+}
+
+// MarshalSSZTo appends the SSZ encoding of the payload to buf
+func (p *ExecutionPayloadWithTxRootV1) MarshalSSZTo(buf []byte) ([]byte, error) {
+	if len(p.LogsBloom) != 256 {
+		return nil, fmt.Errorf("invalid logs_bloom length %d", len(p.LogsBloom))
+	}
+	if len(p.ExtraData) > maxExtraDataBytes {
+		return nil, fmt.Errorf("invalid extra_data length %d", len(p.ExtraData))
+	}
+	var transactions [][]byte
+	if p.Transactions != nil {
+		if len(*p.Transactions) > maxTransactionsPerPayload {
+			return nil, fmt.Errorf("%d transactions are too many", len(*p.Transactions))
+		}
+		transactions = make([][]byte, len(*p.Transactions))
+		for i, tx := range *p.Transactions {
+			decoded, err := hexutil.Decode(tx)
+			if err != nil {
+				return nil, fmt.Errorf("invalid transaction %d: %w", i, err)
+			}
+			if len(decoded) > maxBytesPerTransaction {
+				return nil, fmt.Errorf("transaction %d of %d bytes is too large", i, len(decoded))
+			}
+			transactions[i] = decoded
+		}
+	}
+
+	buf = appendExecutionPayloadPrefix(buf, p.Header())
+	buf = appendUint32(buf, executionPayloadFixedSize)
+	buf, err := appendUint256(buf, p.BaseFeePerGas)
+	if err != nil {
+		return nil, fmt.Errorf("invalid base_fee_per_gas: %w", err)
+	}
+	buf = append(buf, p.BlockHash[:]...)
+	buf = appendUint32(buf, uint32(executionPayloadFixedSize+len(p.ExtraData)))
+	buf = append(buf, p.ExtraData...)
+
+	offset := sszOffsetSize * len(transactions)
+	for _, tx := range transactions {
+		buf = appendUint32(buf, uint32(offset))
+		offset += len(tx)
+	}
+	for _, tx := range transactions {
+		buf = append(buf, tx...)
+	}
+	return buf, nil
+}
+
+// UnmarshalSSZ decodes the SSZ encoding of a payload. The transactions root is left to be computed from the
+// transactions, and FeeRecipientDiff is nil, the payload doesn't say what it pays the proposer.
+func (p *ExecutionPayloadWithTxRootV1) UnmarshalSSZ(buf []byte) error {
+	if len(buf) < executionPayloadFixedSize {
+		return fmt.Errorf("payload of %d bytes is too short", len(buf))
+	}
+	var header ExecutionPayloadHeaderV1
+	r := &sszReader{buf: buf}
+	readExecutionPayloadPrefix(r, &header)
+	extraDataOffset := r.uint32()
+	baseFee := r.uint256()
+	copy(header.BlockHash[:], r.bytes(32))
+	transactionsOffset := r.uint32()
+	if extraDataOffset != executionPayloadFixedSize {
+		return fmt.Errorf("invalid extra_data offset %d", extraDataOffset)
+	}
+	if transactionsOffset < extraDataOffset || int(transactionsOffset) > len(buf) || transactionsOffset-extraDataOffset > maxExtraDataBytes {
+		return fmt.Errorf("invalid transactions offset %d", transactionsOffset)
+	}
+	transactions, err := unmarshalTransactions(buf[transactionsOffset:])
+	if err != nil {
+		return err
+	}
+
+	*p = ExecutionPayloadWithTxRootV1{
+		ParentHash:    header.ParentHash,
+		FeeRecipient:  header.FeeRecipient,
+		StateRoot:     header.StateRoot,
+		ReceiptsRoot:  header.ReceiptsRoot,
+		LogsBloom:     append([]byte{}, header.LogsBloom[:]...),
+		PrevRandao:    header.PrevRandao,
+		Number:        header.BlockNumber,
+		GasLimit:      header.GasLimit,
+		GasUsed:       header.GasUsed,
+		Timestamp:     header.Timestamp,
+		ExtraData:     append([]byte{}, buf[extraDataOffset:transactionsOffset]...),
+		BaseFeePerGas: baseFee,
+		BlockHash:     header.BlockHash,
+		Transactions:  &transactions,
+	}
+	return nil
+}
+
+// unmarshalTransactions decodes an SSZ list of transactions into hex strings
+func unmarshalTransactions(buf []byte) ([]string, error) {
+	if len(buf) == 0 {
+		return []string{}, nil
+	}
+	if len(buf) < sszOffsetSize {
+		return nil, errors.New("invalid transactions")
+	}
+	first := binary.LittleEndian.Uint32(buf)
+	if first%sszOffsetSize != 0 || first == 0 || first > maxTransactionsPerPayload*sszOffsetSize || int(first) > len(buf) {
+		return nil, fmt.Errorf("invalid offset %d of the first transaction", first)
+	}
+	count := int(first / sszOffsetSize)
+	transactions := make([]string, count)
+	for i := 0; i < count; i++ {
+		start := binary.LittleEndian.Uint32(buf[i*sszOffsetSize:])
+		end := uint32(len(buf))
+		if i+1 < count {
+			end = binary.LittleEndian.Uint32(buf[(i+1)*sszOffsetSize:])
+		}
+		if start > end || int(end) > len(buf) || int(start) < count*sszOffsetSize {
+			return nil, fmt.Errorf("invalid offsets %d to %d of transaction %d", start, end, i)
+		}
+		if end-start > maxBytesPerTransaction {
+			return nil, fmt.Errorf("transaction %d of %d bytes is too large", i, end-start)
+		}
+		transactions[i] = hexutil.Encode(buf[start:end])
+	}
+	return transactions, nil
+}
+
+// SizeSSZ returns the size of the SSZ encoding of the bid
+func (b *BuilderBid) SizeSSZ() int {
+	return builderBidFixedSize + b.Header.SizeSSZ()
+}
+
+// MarshalSSZ returns the SSZ encoding of the bid
+func (b *BuilderBid) MarshalSSZ() ([]byte, error) {
+	return b.MarshalSSZTo(make([]byte, 0, b.SizeSSZ()))
+}
+
+// MarshalSSZTo appends the SSZ encoding of the bid to buf
+func (b *BuilderBid) MarshalSSZTo(buf []byte) ([]byte, error) {
+	if b.Header == nil {
+		return nil, errors.New("bid has no header")
+	}
+	buf = appendUint32(buf, builderBidFixedSize)
+	buf, err := appendUint256(buf, b.Value)
+	if err != nil {
+		return nil, fmt.Errorf("invalid value: %w", err)
+	}
+	buf = append(buf, b.Pubkey[:]...)
+	return b.Header.MarshalSSZTo(buf)
+}
+
+// UnmarshalSSZ decodes the SSZ encoding of a bid
+func (b *BuilderBid) UnmarshalSSZ(buf []byte) error {
+	if len(buf) < builderBidFixedSize {
+		return fmt.Errorf("bid of %d bytes is too short", len(buf))
+	}
+	var dec BuilderBid
+	r := &sszReader{buf: buf}
+	if offset := r.uint32(); offset != builderBidFixedSize {
+		return fmt.Errorf("invalid header offset %d", offset)
+	}
+	dec.Value = r.uint256()
+	copy(dec.Pubkey[:], r.bytes(48))
+	dec.Header = new(ExecutionPayloadHeaderV1)
+	if err := dec.Header.UnmarshalSSZ(buf[builderBidFixedSize:]); err != nil {
+		return err
+	}
+	*b = dec
+	return nil
+}
+
+// SizeSSZ returns the size of the SSZ encoding of the signed bid
+func (b *SignedBuilderBid) SizeSSZ() int {
+	return signedBuilderBidFixedSize + b.Message.SizeSSZ()
+}
+
+// MarshalSSZ returns the SSZ encoding of the signed bid
+func (b *SignedBuilderBid) MarshalSSZ() ([]byte, error) {
+	return b.MarshalSSZTo(make([]byte, 0, b.SizeSSZ()))
+}
+
+// MarshalSSZTo appends the SSZ encoding of the signed bid to buf
+func (b *SignedBuilderBid) MarshalSSZTo(buf []byte) ([]byte, error) {
+	if b.Message == nil {
+		return nil, errors.New("signed bid has no message")
+	}
+	buf = appendUint32(buf, signedBuilderBidFixedSize)
+	buf = append(buf, b.Signature[:]...)
+	return b.Message.MarshalSSZTo(buf)
+}
+
+// UnmarshalSSZ decodes the SSZ encoding of a signed bid
+func (b *SignedBuilderBid) UnmarshalSSZ(buf []byte) error {
+	if len(buf) < signedBuilderBidFixedSize {
+		return fmt.Errorf("signed bid of %d bytes is too short", len(buf))
+	}
+	var dec SignedBuilderBid
+	r := &sszReader{buf: buf}
+	if offset := r.uint32(); offset != signedBuilderBidFixedSize {
+		return fmt.Errorf("invalid message offset %d", offset)
+	}
+	copy(dec.Signature[:], r.bytes(96))
+	dec.Message = new(BuilderBid)
+	if err := dec.Message.UnmarshalSSZ(buf[signedBuilderBidFixedSize:]); err != nil {
+		return err
+	}
+	*b = dec
+	return nil
+}
@@ -0,0 +1,183 @@
+package lib
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/flashbots/mev-boost/lib/txroot"
+	"github.com/stretchr/testify/require"
+)
+
+func testPayload(txs ...string) *ExecutionPayloadWithTxRootV1 {
+	header := testHeader()
+	transactions := append([]string{}, txs...)
+	return &ExecutionPayloadWithTxRootV1{
+		ParentHash:    header.ParentHash,
+		FeeRecipient:  header.FeeRecipient,
+		StateRoot:     header.StateRoot,
+		ReceiptsRoot:  header.ReceiptsRoot,
+		LogsBloom:     header.LogsBloom[:],
+		PrevRandao:    header.PrevRandao,
+		Number:        header.BlockNumber,
+		GasLimit:      header.GasLimit,
+		GasUsed:       header.GasUsed,
+		Timestamp:     header.Timestamp,
+		ExtraData:     header.ExtraData,
+		BaseFeePerGas: header.BaseFeePerGas,
+		BlockHash:     header.BlockHash,
+		Transactions:  &transactions,
+	}
+}
+
+func TestExecutionPayloadHeaderV1_SSZ(t *testing.T) {
+	encoded, err := testHeader().MarshalSSZ()
+	require.Nil(t, err)
+	require.Len(t, encoded, testHeader().SizeSSZ())
+	require.Equal(t, executionPayloadHeaderFixedSize+len("mev-boost"), len(encoded))
+
+	decoded := new(ExecutionPayloadHeaderV1)
+	require.Nil(t, decoded.UnmarshalSSZ(encoded))
+	require.Equal(t, testHeader(), decoded)
+
+	require.Error(t, decoded.UnmarshalSSZ(encoded[:executionPayloadHeaderFixedSize-1]))
+	require.Error(t, decoded.UnmarshalSSZ(append(encoded, make([]byte, 32)...)), "extra data is at most 32 bytes")
+	badOffset := append([]byte{}, encoded...)
+	binary.LittleEndian.PutUint32(badOffset[executionPayloadPrefixSize:], executionPayloadHeaderFixedSize+1)
+	require.Error(t, decoded.UnmarshalSSZ(badOffset))
+
+	header := testHeader()
+	header.BaseFeePerGas = nil
+	_, err = header.MarshalSSZ()
+	require.Error(t, err)
+}
+
+func TestExecutionPayloadWithTxRootV1_SSZ(t *testing.T) {
+	for _, payload := range []*ExecutionPayloadWithTxRootV1{testPayload(), testPayload("0x01", "0x", "0x020304")} {
+		encoded, err := payload.MarshalSSZ()
+		require.Nil(t, err)
+		require.Len(t, encoded, payload.SizeSSZ())
+
+		decoded := new(ExecutionPayloadWithTxRootV1)
+		require.Nil(t, decoded.UnmarshalSSZ(encoded))
+		require.Equal(t, payload, decoded)
+	}
+
+	encoded, err := testPayload("0x01", "0x020304").MarshalSSZ()
+	require.Nil(t, err)
+	decoded := new(ExecutionPayloadWithTxRootV1)
+	require.Error(t, decoded.UnmarshalSSZ(encoded[:executionPayloadFixedSize-1]))
+	transactions := executionPayloadFixedSize + len("mev-boost")
+	for name, corrupt := range map[string]func(b []byte){
+		"extra data offset":       func(b []byte) { binary.LittleEndian.PutUint32(b[executionPayloadPrefixSize:], 0) },
+		"transactions offset":     func(b []byte) { binary.LittleEndian.PutUint32(b[executionPayloadFixedSize-4:], uint32(len(b)+1)) },
+		"first transaction":       func(b []byte) { binary.LittleEndian.PutUint32(b[transactions:], 3) },
+		"decreasing transactions": func(b []byte) { binary.LittleEndian.PutUint32(b[transactions+4:], 7) },
+	} {
+		b := append([]byte{}, encoded...)
+		corrupt(b)
+		require.Error(t, decoded.UnmarshalSSZ(b), name)
+	}
+
+	_, err = testPayload("0xzz").MarshalSSZ()
+	require.Error(t, err)
+	payload := testPayload()
+	payload.LogsBloom = nil
+	_, err = payload.MarshalSSZ()
+	require.Error(t, err)
+}
+
+func TestSignedBuilderBid_SSZ(t *testing.T) {
+	bid := &SignedBuilderBid{Message: &BuilderBid{Header: testHeader(), Value: big.NewInt(5), Pubkey: BLSPubkey{1}}, Signature: BLSSignature{2}}
+	encoded, err := bid.MarshalSSZ()
+	require.Nil(t, err)
+	require.Len(t, encoded, bid.SizeSSZ())
+
+	decoded := new(SignedBuilderBid)
+	require.Nil(t, decoded.UnmarshalSSZ(encoded))
+	require.Equal(t, bid, decoded)
+	require.Error(t, decoded.UnmarshalSSZ(encoded[:signedBuilderBidFixedSize+builderBidFixedSize-1]))
+}
+
+// newSSZRelay is a relay that answers with SSZ if the request accepts it, and with JSON otherwise
+func newSSZRelay(t *testing.T, bid *SignedBuilderBid, payload *ExecutionPayloadWithTxRootV1) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req rpcRequest
+		require.Nil(t, json.NewDecoder(r.Body).Decode(&req))
+		var result interface{ MarshalSSZ() ([]byte, error) }
+		switch req.Method {
+		case methodRelayGetHeader:
+			result = bid
+		case methodRelayProposeBlock:
+			result = payload
+		default:
+			resp, err := formatErrorResponse("unknown method")
+			require.Nil(t, err)
+			w.Write(resp)
+			return
+		}
+		if r.Header.Get("Accept") != sszAccept {
+			resp, err := formatResponse(result)
+			require.Nil(t, err)
+			w.Header().Set("Content-Type", "application/json")
+			w.Write(resp)
+			return
+		}
+		encoded, err := result.MarshalSSZ()
+		require.Nil(t, err)
+		w.Header().Set("Content-Type", sszContentType)
+		w.Write(encoded)
+	}))
+}
+
+func TestRelayService_SSZ(t *testing.T) {
+	payload := testPayload()
+	root, err := txroot.TransactionsRoot(nil)
+	require.Nil(t, err)
+	payload.TransactionsRoot = common.Hash(root)
+	*payload = withBlockHash(*payload)
+	bid := &SignedBuilderBid{Message: &BuilderBid{Header: payload.Header(), Value: big.NewInt(5)}}
+	relay := newSSZRelay(t, bid, payload)
+	defer relay.Close()
+	jsonHeader := *payload
+	jsonHeader.BlockHash, jsonHeader.Transactions, jsonHeader.FeeRecipientDiff = common.HexToHash("0x01"), nil, big.NewInt(1)
+	jsonRelay := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		resp, err := formatResponse(jsonHeader)
+		require.Nil(t, err)
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(resp)
+	}))
+	defer jsonRelay.Close()
+
+	store := NewStore()
+	for _, relayURL := range []string{relay.URL, jsonRelay.URL} {
+		store.SetForkchoiceResponse(context.Background(), "0x01", relayURL, "0x01")
+	}
+	service, err := newRelayService(WithRelayURLs(relay.URL, jsonRelay.URL), WithStore(store), WithLogger(testLog), WithRelaySSZ(), WithStrictContentTypes())
+	require.Nil(t, err)
+
+	payloadID := "0x01"
+	header := new(ExecutionPayloadWithTxRootV1)
+	require.Nil(t, service.GetPayloadHeaderV1(nil, &payloadID, header))
+	require.Equal(t, payload.BlockHash, header.BlockHash)
+	require.Equal(t, payload.TransactionsRoot, header.TransactionsRoot)
+	require.Equal(t, big.NewInt(5), header.FeeRecipientDiff)
+	results := make(map[string]string)
+	for _, bid := range service.bids.slot(service.chain.SlotAt(payload.Timestamp)) {
+		results[bid.RelayURL] = bid.Result
+	}
+	require.Equal(t, map[string]string{relay.URL: BidResultWon, jsonRelay.URL: BidResultValid}, results, "JSON relays still take part")
+
+	block := &SignedBlindedBeaconBlock{Message: &BlindedBeaconBlock{Slot: 1, Body: &BlindedBeaconBlockBody{ExecutionPayloadHeader: header.Header()}}}
+	revealed := new(ExecutionPayloadWithTxRootV1)
+	require.Nil(t, service.ProposeBlindedBlockV1(nil, block, revealed))
+	require.Equal(t, payload.BlockHash, revealed.BlockHash)
+	require.Equal(t, []string{}, *revealed.Transactions)
+	require.True(t, bytes.Equal(payload.LogsBloom, revealed.LogsBloom))
+}
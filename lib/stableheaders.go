@@ -0,0 +1,59 @@
+package lib
+
+import (
+	"context"
+	"sync"
+)
+
+// stableHeaders makes getPayloadHeader return the same header for all calls of a proposal, so redundant consensus
+// clients of a proposer, and the co-signing nodes of a distributed validator, never get different headers to sign. The
+// headers are kept in the store, shared with the routers of other frontends, and concurrent calls for the same proposal
+// wait for the first one instead of asking the relays again.
+type stableHeaders struct {
+	mu    sync.Mutex
+	calls map[ProposalKey]*stableHeaderCall
+}
+
+// stableHeaderCall serializes the getPayloadHeader calls of a proposal
+type stableHeaderCall struct {
+	mu      sync.Mutex
+	waiting int // calls holding or waiting for mu
+}
+
+func newStableHeaders() *stableHeaders {
+	return &stableHeaders{calls: make(map[ProposalKey]*stableHeaderCall)}
+}
+
+// lock waits until no other call of the proposal runs, and returns the function ending the call
+func (s *stableHeaders) lock(key ProposalKey) (unlock func()) {
+	s.mu.Lock()
+	call, ok := s.calls[key]
+	if !ok {
+		call = &stableHeaderCall{}
+		s.calls[key] = call
+	}
+	call.waiting++
+	s.mu.Unlock()
+
+	call.mu.Lock()
+	return func() {
+		call.mu.Unlock()
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if call.waiting--; call.waiting == 0 {
+			delete(s.calls, key)
+		}
+	}
+}
+
+// proposalKey returns the proposal of a payload id: the slot of its payload attributes, the head of its forkchoiceUpdated
+// call and the proposer of the slot, or the fee recipient if the proposer isn't known
+func (m *RelayService) proposalKey(ctx context.Context, payloadID string, attributes *PayloadAttributesV1, log Logger) ProposalKey {
+	key := ProposalKey{Slot: m.chain.SlotAt(uint64(attributes.Timestamp))}
+	key.ParentHash, _ = m.heads.get(payloadID)
+	key.Proposer = m.proposerOf(ctx, key.Slot, attributes.SuggestedFeeRecipient, log)
+	if key.Proposer == "" {
+		key.Proposer = attributes.SuggestedFeeRecipient.Hex()
+	}
+	return key
+}
@@ -0,0 +1,139 @@
+package lib
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRelayService_StableHeaders(t *testing.T) {
+	headerCount := 0
+	relay := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := ioutil.ReadAll(r.Body)
+		require.Nil(t, err)
+		req := new(rpcRequest)
+		require.Nil(t, json.Unmarshal(body, req))
+
+		var resp []byte
+		switch req.Method {
+		case methodForkchoiceUpdated:
+			resp, err = formatResponse(ForkChoiceResponse{PayloadStatus: PayloadStatus{Status: ForkchoiceStatusValid}, PayloadID: &hexutil.Bytes{1}})
+		case methodRelayGetHeader:
+			// every call returns a better bid for a different block
+			headerCount++
+			resp, err = formatResponse(ExecutionPayloadWithTxRootV1{
+				BlockHash:        common.BigToHash(big.NewInt(int64(headerCount))),
+				BaseFeePerGas:    big.NewInt(1),
+				FeeRecipientDiff: big.NewInt(int64(headerCount)),
+			})
+		}
+		require.Nil(t, err)
+		w.Write(resp)
+	}))
+	defer relay.Close()
+
+	attributes := map[string]interface{}{
+		"timestamp":             "0x62a5d140",
+		"prevRandao":            "0x0000000000000000000000000000000000000000000000000000000000000001",
+		"suggestedFeeRecipient": "0xdb65fed33dc262fe09d9a2ba8f80b329ba25f941",
+	}
+	getHeader := func(service *RelayService) common.Hash {
+		fcu := new(ForkChoiceResponse)
+		require.Nil(t, service.ForkchoiceUpdatedV1(nil, &[]interface{}{map[string]interface{}{}, attributes}, fcu))
+		payloadID := fcu.PayloadID.String()
+		header := new(ExecutionPayloadWithTxRootV1)
+		require.Nil(t, service.GetPayloadHeaderV1(nil, &payloadID, header))
+		return header.BlockHash
+	}
+
+	service, err := newRelayService(WithRelayURLs(relay.URL), WithStore(NewStore()), WithLogger(testLog), WithStableHeaders())
+	require.Nil(t, err)
+	first := getHeader(service)
+	require.Equal(t, first, getHeader(service), "same slot returns the same header")
+
+	attributes["timestamp"] = "0x62a5d14c" // next slot
+	require.NotEqual(t, first, getHeader(service))
+
+	service, err = newRelayService(WithRelayURLs(relay.URL), WithStore(NewStore()), WithLogger(testLog))
+	require.Nil(t, err)
+	require.NotEqual(t, getHeader(service), getHeader(service), "headers change without stable headers")
+}
+
+func TestRelayService_StableHeadersSharedStore(t *testing.T) {
+	var headerCalls int64
+	relay := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		req := new(rpcRequest)
+		require.Nil(t, json.NewDecoder(r.Body).Decode(req))
+		var resp []byte
+		var err error
+		switch req.Method {
+		case methodForkchoiceUpdated:
+			resp, err = formatResponse(ForkChoiceResponse{PayloadStatus: PayloadStatus{Status: ForkchoiceStatusValid}, PayloadID: &hexutil.Bytes{1}})
+		case methodRelayGetHeader:
+			calls := atomic.AddInt64(&headerCalls, 1)
+			time.Sleep(20 * time.Millisecond)
+			resp, err = formatResponse(ExecutionPayloadWithTxRootV1{
+				BlockHash:        common.BigToHash(big.NewInt(calls)),
+				BaseFeePerGas:    big.NewInt(1),
+				FeeRecipientDiff: big.NewInt(calls),
+			})
+		}
+		require.Nil(t, err)
+		w.Write(resp)
+	}))
+	defer relay.Close()
+
+	state := map[string]interface{}{"headBlockHash": "0x00000000000000000000000000000000000000000000000000000000000000aa"}
+	attributes := map[string]interface{}{
+		"timestamp":             "0x62a5d140",
+		"prevRandao":            "0x0000000000000000000000000000000000000000000000000000000000000001",
+		"suggestedFeeRecipient": "0xdb65fed33dc262fe09d9a2ba8f80b329ba25f941",
+	}
+	store := NewStore()
+	payloadIDOf := func(service *RelayService) string {
+		fcu := new(ForkChoiceResponse)
+		require.Nil(t, service.ForkchoiceUpdatedV1(nil, &[]interface{}{state, attributes}, fcu))
+		return fcu.PayloadID.String()
+	}
+	getHeader := func(service *RelayService, payloadID string) common.Hash {
+		header := new(ExecutionPayloadWithTxRootV1)
+		require.Nil(t, service.GetPayloadHeaderV1(nil, &payloadID, header))
+		return header.BlockHash
+	}
+
+	prysm, err := newRelayService(WithRelayURLs(relay.URL), WithStore(store), WithLogger(testLog), WithStableHeaders())
+	require.Nil(t, err)
+	lighthouse, err := newRelayService(WithRelayURLs(relay.URL), WithStore(store), WithLogger(testLog), WithStableHeaders())
+	require.Nil(t, err)
+	prysmPayloadID, lighthousePayloadID := payloadIDOf(prysm), payloadIDOf(lighthouse)
+
+	var wg sync.WaitGroup
+	headers := make([]common.Hash, 4)
+	for i := range headers {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			headers[i] = getHeader(prysm, prysmPayloadID)
+		}(i)
+	}
+	wg.Wait()
+	require.Equal(t, int64(1), atomic.LoadInt64(&headerCalls), "concurrent calls for a proposal ask the relays once")
+	for _, header := range headers {
+		require.Equal(t, headers[0], header)
+	}
+	require.Equal(t, headers[0], getHeader(lighthouse, lighthousePayloadID), "routers sharing the store return the same header")
+	require.Equal(t, int64(1), atomic.LoadInt64(&headerCalls))
+
+	state["headBlockHash"] = "0x00000000000000000000000000000000000000000000000000000000000000bb"
+	require.NotEqual(t, headers[0], getHeader(lighthouse, payloadIDOf(lighthouse)), "a proposal on another parent gets a header of its own")
+}
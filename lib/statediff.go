@@ -0,0 +1,117 @@
+package lib
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"sync"
+	"time"
+)
+
+var (
+	// stateDiffPollInterval is how often pending payloads are checked against the execution client
+	stateDiffPollInterval = 2 * time.Second
+	// stateDiffMaxWaitSlots bounds how long a payload waits for its block to be imported before the payment is checked from
+	// the payload alone
+	stateDiffMaxWaitSlots = 4
+)
+
+// pendingPayment is a revealed payload whose payment is checked once the execution client imported its block
+type pendingPayment struct {
+	bid        *Bid
+	payload    *ExecutionPayloadWithTxRootV1
+	revealedAt time.Time
+}
+
+// stateDiffVerifier checks proposer payments by the balance increase of the fee recipient in the revealed block, as
+// reported by an execution client. Unlike the last transaction of the payload, this accounts for any way of paying.
+type stateDiffVerifier struct {
+	el *ExecutionClient
+
+	mu      sync.Mutex
+	pending []*pendingPayment
+}
+
+func newStateDiffVerifier(el *ExecutionClient) *stateDiffVerifier {
+	return &stateDiffVerifier{el: el}
+}
+
+func (v *stateDiffVerifier) add(bid *Bid, payload *ExecutionPayloadWithTxRootV1) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.pending = append(v.pending, &pendingPayment{bid, payload, now()})
+}
+
+// take removes and returns all pending payloads
+func (v *stateDiffVerifier) take() []*pendingPayment {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	pending := v.pending
+	v.pending = nil
+	return pending
+}
+
+// requeue returns payloads to the pending ones, e.g. when their block isn't imported yet
+func (v *stateDiffVerifier) requeue(pending ...*pendingPayment) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.pending = append(pending, v.pending...)
+}
+
+// balanceIncrease returns by how much the block of payload increased the balance of the fee recipient of bid, or ok false
+// if the execution client didn't import the block yet
+func (v *stateDiffVerifier) balanceIncrease(ctx context.Context, p *pendingPayment) (increase *big.Int, ok bool, err error) {
+	imported, err := v.el.HasBlock(ctx, p.payload.BlockHash)
+	if err != nil || !imported {
+		return nil, false, err
+	}
+	before, err := v.el.BalanceAt(ctx, p.bid.FeeRecipient, p.payload.ParentHash)
+	if err != nil {
+		return nil, false, err
+	}
+	after, err := v.el.BalanceAt(ctx, p.bid.FeeRecipient, p.payload.BlockHash)
+	if err != nil {
+		return nil, false, err
+	}
+	return new(big.Int).Sub(after, before), true, nil
+}
+
+// verifyStateDiffs checks the payment of pending payloads whose block was imported. Payloads that waited longer than
+// stateDiffMaxWaitSlots are checked from the payload alone.
+func (m *RelayService) verifyStateDiffs(ctx context.Context) {
+	maxWait := m.chain.SlotDuration() * time.Duration(stateDiffMaxWaitSlots)
+	var waiting []*pendingPayment
+	for _, p := range m.stateDiffs.take() {
+		log := m.log.WithField("blockHash", p.payload.BlockHash)
+		increase, ok, err := m.stateDiffs.balanceIncrease(ctx, p)
+		if err != nil {
+			log.WithError(err).Debug("could not look up fee recipient balance on execution client")
+		}
+		if !ok {
+			if now().Sub(p.revealedAt) < maxWait {
+				waiting = append(waiting, p)
+				continue
+			}
+			log.Warn("execution client didn't import revealed block, verifying payment from the payload")
+			paid, err := verifyProposerPayment(p.payload, p.bid.FeeRecipient, p.bid.Value)
+			m.recordPayment(p.bid, p.payload, paid, err, log)
+			continue
+		}
+
+		paid := increase
+		if paid.Sign() < 0 {
+			paid = big.NewInt(0)
+		}
+		err = nil
+		if p.bid.Value != nil && increase.Cmp(p.bid.Value) < 0 {
+			err = fmt.Errorf("fee recipient balance increase of %s is below the promised %s", increase, p.bid.Value)
+		}
+		m.recordPayment(p.bid, p.payload, paid, err, log)
+	}
+	m.stateDiffs.requeue(waiting...)
+}
+
+// startStateDiffVerification checks pending payments against the execution client until ctx is done
+func (m *RelayService) startStateDiffVerification(ctx context.Context) {
+	runLoop(ctx, m.log, "state_diff_verification", stateDiffPollInterval, false, m.verifyStateDiffs)
+}
@@ -0,0 +1,84 @@
+package lib
+
+import (
+	"context"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/stretchr/testify/require"
+)
+
+// newMockExecutionNode serves eth_getBlockByHash for the blocks in balances, and eth_getBalance of any account by block hash
+func newMockExecutionNode(t *testing.T, balances map[common.Hash]*big.Int) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Method string            `json:"method"`
+			Params []json.RawMessage `json:"params"`
+		}
+		require.Nil(t, json.NewDecoder(r.Body).Decode(&req))
+
+		var result interface{}
+		switch req.Method {
+		case "eth_getBlockByHash":
+			var hash common.Hash
+			require.Nil(t, json.Unmarshal(req.Params[0], &hash))
+			if _, ok := balances[hash]; ok {
+				result = map[string]interface{}{"hash": hash}
+			}
+		case "eth_getBalance":
+			var block struct {
+				BlockHash common.Hash `json:"blockHash"`
+			}
+			require.Nil(t, json.Unmarshal(req.Params[1], &block))
+			balance, ok := balances[block.BlockHash]
+			require.True(t, ok, "balance of unknown block %s", block.BlockHash)
+			result = (*hexutil.Big)(balance)
+		}
+		resp, err := formatResponse(result)
+		require.Nil(t, err)
+		w.Write(resp)
+	}))
+}
+
+func TestRelayService_verifyStateDiffs(t *testing.T) {
+	parent, block, missing := common.HexToHash("0x01"), common.HexToHash("0x02"), common.HexToHash("0x03")
+	el := newMockExecutionNode(t, map[common.Hash]*big.Int{parent: big.NewInt(100), block: big.NewInt(107)})
+	defer el.Close()
+
+	store := NewStore()
+	relay, err := newRelayService(WithRelayURLs("http://relay"), WithStore(store), WithLogger(testLog), WithStateDiffPaymentVerification(NewExecutionClient(el.URL)))
+	require.Nil(t, err)
+
+	// the proposer is the block fee recipient, which can't be verified from the payload alone
+	proposer := common.HexToAddress("0x0000000000000000000000000000000000000002")
+	ctx := context.Background()
+	store.SetBid(ctx, block, &Bid{RelayURL: "http://relay", FeeRecipient: proposer, Value: big.NewInt(10)})
+	store.SetBid(ctx, missing, &Bid{RelayURL: "http://relay", FeeRecipient: proposer, Value: big.NewInt(10)})
+	relay.verifyPayment(ctx, &ExecutionPayloadWithTxRootV1{BlockHash: block, ParentHash: parent, FeeRecipient: proposer}, relay.log)
+	relay.verifyPayment(ctx, &ExecutionPayloadWithTxRootV1{BlockHash: missing, ParentHash: parent, FeeRecipient: proposer}, relay.log)
+	require.Empty(t, relay.accounting.snapshot(), "payments are verified in the background")
+
+	relay.verifyStateDiffs(ctx)
+	records := relay.payments.underpayments()
+	require.Equal(t, 1, len(records))
+	require.Equal(t, block, records[0].BlockHash)
+	require.Equal(t, big.NewInt(7), records[0].Paid)
+	require.Equal(t, 1, len(relay.stateDiffs.pending), "payload of a block that isn't imported yet waits")
+
+	// the payment of a block that is never imported is checked from the payload, the proposer is the fee recipient
+	defer func() { now = time.Now }()
+	now = func() time.Time { return time.Now().Add(time.Hour) }
+	relay.verifyStateDiffs(ctx)
+	require.Empty(t, relay.stateDiffs.pending)
+	accounts := relay.accounting.snapshot()
+	require.Equal(t, 1, len(accounts))
+	require.Equal(t, uint64(2), accounts[0].Payloads)
+	require.Equal(t, uint64(1), accounts[0].Unverified)
+	require.Equal(t, uint64(1), accounts[0].Underpaid)
+}
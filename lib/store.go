@@ -1,18 +1,27 @@
 package lib
 
 import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
 )
 
 var (
 	cleanupLoopInterval = 5 * time.Minute
 
-	secondsPerSlot = 12
-	slotsPerEpoch  = 32
-	stateExpiry    = time.Second * time.Duration(secondsPerSlot*slotsPerEpoch*2) // ~2 epochs
+	// secondsPerSlot is the slot time of the in-mem store unless WithSlotDuration sets the one of the network
+	secondsPerSlot        = 12
+	slotsPerEpoch         = 32
+	defaultRetentionSlots = uint64(slotsPerEpoch * 2)
 
 	// local now function, used instead of time.Now so it can be overwritten in tests
 	now = time.Now
@@ -21,13 +30,54 @@ var (
 type executionPayloadContainer struct {
 	Payload *ExecutionPayloadWithTxRootV1
 	AddedAt time.Time
+	Size    int64
 }
 
 type forkchoiceResponseContainer struct {
-	Payload map[string]string // map[relayURL]relayPayloadID
+	Payload    map[string]string // map[relayURL]relayPayloadID
+	Attributes *PayloadAttributesV1
+	AddedAt    time.Time
+}
+
+type bidContainer struct {
+	Bid     *Bid
+	AddedAt time.Time
+}
+
+type proposalHeaderContainer struct {
+	Header  *ExecutionPayloadWithTxRootV1
 	AddedAt time.Time
 }
 
+// ProposalKey identifies a proposal by its slot, the block it builds on and its proposer: the 0x prefixed pubkey of the
+// validator, or the fee recipient of its payload attributes if the validator isn't known
+type ProposalKey struct {
+	Slot       uint64
+	ParentHash common.Hash
+	Proposer   string
+}
+
+func (k ProposalKey) String() string {
+	return fmt.Sprintf("%d/%s/%s", k.Slot, k.ParentHash.Hex(), strings.ToLower(k.Proposer))
+}
+
+// parseProposalKey parses the String of a ProposalKey
+func parseProposalKey(s string) (ProposalKey, error) {
+	parts := strings.SplitN(s, "/", 3)
+	if len(parts) != 3 {
+		return ProposalKey{}, fmt.Errorf("invalid proposal key %q", s)
+	}
+	slot, err := strconv.ParseUint(parts[0], 10, 64)
+	if err != nil {
+		return ProposalKey{}, fmt.Errorf("invalid slot of proposal key %q: %w", s, err)
+	}
+	parentHash, err := hexutil.Decode(parts[1])
+	if err != nil || len(parentHash) != common.HashLength {
+		return ProposalKey{}, fmt.Errorf("invalid parent hash of proposal key %q", s)
+	}
+	return ProposalKey{Slot: slot, ParentHash: common.BytesToHash(parentHash), Proposer: parts[2]}, nil
+}
+
 func newForkchoiceResponseContainer() forkchoiceResponseContainer {
 	return forkchoiceResponseContainer{
 		Payload: make(map[string]string),
@@ -35,56 +85,243 @@ func newForkchoiceResponseContainer() forkchoiceResponseContainer {
 	}
 }
 
-// Store stores payloads and retrieves them based on blockHash hashes
+// Store stores payloads and retrieves them based on blockHash hashes. The context is the one of the consensus client request, so implementations backed by a database can respect its deadline.
 type Store interface {
-	GetExecutionPayload(blockHash common.Hash) *ExecutionPayloadWithTxRootV1
-	SetExecutionPayload(blockHash common.Hash, payload *ExecutionPayloadWithTxRootV1)
+	GetExecutionPayload(ctx context.Context, blockHash common.Hash) *ExecutionPayloadWithTxRootV1
+	SetExecutionPayload(ctx context.Context, blockHash common.Hash, payload *ExecutionPayloadWithTxRootV1)
+
+	SetForkchoiceResponse(ctx context.Context, boostPayloadID, relayURL, relayPayloadID string)
+	GetForkchoiceResponse(ctx context.Context, boostPayloadID string) (map[string]string, bool)
+
+	SetPayloadAttributes(ctx context.Context, boostPayloadID string, attributes *PayloadAttributesV1)
+	GetPayloadAttributes(ctx context.Context, boostPayloadID string) *PayloadAttributesV1
+
+	GetBid(ctx context.Context, blockHash common.Hash) *Bid
+	SetBid(ctx context.Context, blockHash common.Hash, bid *Bid)
+
+	// GetProposalHeader and SetProposalHeader keep the header returned for a proposal, so all consensus clients of a
+	// proposer get the same one. SetProposalHeader keeps the first header of a proposal and returns the header it keeps.
+	GetProposalHeader(ctx context.Context, key ProposalKey) *ExecutionPayloadWithTxRootV1
+	SetProposalHeader(ctx context.Context, key ProposalKey, header *ExecutionPayloadWithTxRootV1) *ExecutionPayloadWithTxRootV1
 
-	SetForkchoiceResponse(boostPayloadID, relayURL, relayPayloadID string)
-	GetForkchoiceResponse(boostPayloadID string) (map[string]string, bool)
+	// GetRelayReputation and SetRelayReputation keep the reputation of relays across restarts, so they should be durable.
+	// Reputations aren't removed by Cleanup or EvictBefore. Setting a nil reputation resets it.
+	GetRelayReputation(ctx context.Context, relayURL string) (*RelayReputation, error)
+	SetRelayReputation(ctx context.Context, relayURL string, reputation *RelayReputation) error
 
-	Cleanup()
+	// GetValidatorRegistration and SetValidatorRegistration keep the latest signed registration of each validator, by
+	// its 0x prefixed pubkey. Registrations aren't removed by Cleanup or EvictBefore.
+	GetValidatorRegistration(ctx context.Context, pubkey string) *SignedValidatorRegistrationV1
+	SetValidatorRegistration(ctx context.Context, registration *SignedValidatorRegistrationV1)
+
+	Cleanup(ctx context.Context)
+
+	// Sizes returns the number of entries in the store
+	Sizes(ctx context.Context) StoreSizes
+	// Dump returns a summary of the entries in the store, for the admin API
+	Dump(ctx context.Context) StoreDump
+}
+
+// StoreSizes is the number of entries of a Store by kind, forkchoice responses include payload attributes
+type StoreSizes struct {
+	Payloads            int
+	ForkchoiceResponses int
+	Bids                int
+	Registrations       int
+}
+
+// StoreDump is a summary of the entries of a Store, payloads are listed without their transactions
+type StoreDump struct {
+	Payloads []StoredPayload `json:"payloads"`
+	// ForkchoiceResponses are the payload ids of the relays by relay url, by payload id of mev-boost
+	ForkchoiceResponses map[string]map[string]string `json:"forkchoiceResponses"`
+	// Bids are by block hash
+	Bids          map[string]*Bid                  `json:"bids"`
+	Registrations []*SignedValidatorRegistrationV1 `json:"registrations"`
+	// ProposalHeaders are the headers returned for proposals by ProposalKey.String
+	ProposalHeaders map[string]*ExecutionPayloadWithTxRootV1 `json:"proposalHeaders"`
+}
+
+// StoredPayload is a payload of a StoreDump
+type StoredPayload struct {
+	BlockHash    common.Hash `json:"blockHash"`
+	Number       uint64      `json:"blockNumber"`
+	Timestamp    uint64      `json:"timestamp"`
+	Transactions int         `json:"transactions"`
+	AddedAt      time.Time   `json:"addedAt"`
+}
+
+func newStoreDump() StoreDump {
+	return StoreDump{
+		Payloads:            []StoredPayload{},
+		ForkchoiceResponses: make(map[string]map[string]string),
+		Bids:                make(map[string]*Bid),
+		Registrations:       []*SignedValidatorRegistrationV1{},
+		ProposalHeaders:     make(map[string]*ExecutionPayloadWithTxRootV1),
+	}
+}
+
+func (c executionPayloadContainer) stored() StoredPayload {
+	stored := StoredPayload{BlockHash: c.Payload.BlockHash, Number: c.Payload.Number, Timestamp: c.Payload.Timestamp, AddedAt: c.AddedAt}
+	if c.Payload.Transactions != nil {
+		stored.Transactions = len(*c.Payload.Transactions)
+	}
+	return stored
 }
 
 // map[common.Hash]*ExecutionPayloadWithTxRootV1
 // map blockHash to ExecutionPayloadWithTxRootV1. TODO: this has issues, in that blockHash could actually be the same between different payloads
-// TODO: clean this up periodically
+
+// defaultStoreShards is the number of shards the payloads, forkchoice responses and bids of the in-mem store are split
+// into, so concurrent requests for different payloads rarely wait for the same lock
+const defaultStoreShards = 16
+
+type payloadShard struct {
+	payloads map[common.Hash]executionPayloadContainer
+	mutex    sync.RWMutex
+}
+
+type forkchoiceShard struct {
+	forkchoices map[string]forkchoiceResponseContainer // key=boostPayloadID
+	mutex       sync.RWMutex
+}
+
+type bidShard struct {
+	bids  map[common.Hash]bidContainer
+	mutex sync.RWMutex
+}
 
 type store struct {
-	payloads     map[common.Hash]executionPayloadContainer
-	payloadMutex sync.RWMutex
+	payloadBytes   int64 // approximate memory used by payloads, accessed atomically so it's first for alignment
+	payloads       []payloadShard
+	payloadBudget  int64      // 0 means unlimited
+	evictMutex     sync.Mutex // serializes evictions for the payload budget
+	retentionSlots uint64
+	slotDuration   time.Duration
+	shards         int
+	log            Logger
+	name           string // the store label of the metrics
+
+	forkchoices []forkchoiceShard
+	bids        []bidShard
+
+	proposalHeaders     map[string]proposalHeaderContainer // key=ProposalKey.String()
+	proposalHeaderMutex sync.Mutex
+
+	reputations       map[string]*RelayReputation // key=relayURL
+	reputationsLoaded bool
+	reputationFile    string // empty if reputations are only kept in memory
+	reputationMutex   sync.Mutex
+
+	registrations     map[string]*SignedValidatorRegistrationV1 // key=lowercase pubkey
+	registrationMutex sync.RWMutex
+}
+
+// Evicter is implemented by stores that can remove the entries of finalized slots before they expire, like the in-mem
+// store and LevelDBStore. Stores without it keep such entries until Cleanup removes them.
+type Evicter interface {
+	// EvictBefore removes all entries of slots that started before the unix timestamp, e.g. because they are finalized
+	EvictBefore(ctx context.Context, timestamp uint64)
+}
+
+// StoreOption configures the in-mem store
+type StoreOption func(*store)
+
+// WithPayloadMemoryBudget caps the approximate memory used by cached payloads, the oldest payloads are evicted when it's exceeded.
+// The most recent payload is always kept, even if it's larger than the budget.
+func WithPayloadMemoryBudget(bytes int64) StoreOption {
+	return func(s *store) { s.payloadBudget = bytes }
+}
+
+// WithRetentionSlots sets how many slots the in-mem store keeps entries for, defaults to 64 (2 epochs). Cleanup removes
+// payloads and payload attributes of older slots, and all entries that were added longer ago.
+func WithRetentionSlots(slots uint64) StoreOption {
+	return func(s *store) { s.retentionSlots = slots }
+}
+
+// WithSlotDuration sets the slot time of the network, which the retention and the cleanup loop are counted in, defaults
+// to 12 seconds
+func WithSlotDuration(duration time.Duration) StoreOption {
+	return func(s *store) { s.slotDuration = duration }
+}
+
+// WithStoreName sets the store label of the metrics of the store, so the stores of several networks in one process are
+// told apart, defaults to "memory"
+func WithStoreName(name string) StoreOption {
+	return func(s *store) { s.name = name }
+}
+
+// WithStoreLogger sets the logger of the cleanup loop of NewStoreWithCleanup, defaults to the standard library logger
+func WithStoreLogger(log Logger) StoreOption {
+	return func(s *store) { s.log = log }
+}
 
-	forkchoices     map[string]forkchoiceResponseContainer // key=boostPayloadID
-	forkchoiceMutex sync.RWMutex
+// withStoreShards sets the number of shards of the in-mem store, 1 keeps every kind of entry behind a single lock
+func withStoreShards(shards int) StoreOption {
+	return func(s *store) { s.shards = shards }
 }
 
 // NewStore creates an in-mem store. Does not call Store.Cleanup() by default, so memory will build up. Use NewStoreWithCleanup if you want to start a cleanup loop as well.
-func NewStore() Store {
-	return &store{
-		payloads:    make(map[common.Hash]executionPayloadContainer),
-		forkchoices: make(map[string]forkchoiceResponseContainer),
+func NewStore(opts ...StoreOption) Store {
+	s := &store{
+		shards:          defaultStoreShards,
+		name:            "memory",
+		retentionSlots:  defaultRetentionSlots,
+		slotDuration:    time.Second * time.Duration(secondsPerSlot),
+		proposalHeaders: make(map[string]proposalHeaderContainer),
+		reputations:     make(map[string]*RelayReputation),
+		registrations:   make(map[string]*SignedValidatorRegistrationV1),
 	}
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	s.payloads = make([]payloadShard, s.shards)
+	s.forkchoices = make([]forkchoiceShard, s.shards)
+	s.bids = make([]bidShard, s.shards)
+	for i := 0; i < s.shards; i++ {
+		s.payloads[i].payloads = make(map[common.Hash]executionPayloadContainer)
+		s.forkchoices[i].forkchoices = make(map[string]forkchoiceResponseContainer)
+		s.bids[i].bids = make(map[common.Hash]bidContainer)
+	}
+	return s
 }
 
-// NewStoreWithCleanup creates an in-mem store, and starts goroutine that periodically removes old entries.
-func NewStoreWithCleanup() Store {
-	store := NewStore()
+// NewStoreWithCleanup creates an in-mem store, and starts goroutine that removes old entries every slot until ctx is done.
+func NewStoreWithCleanup(ctx context.Context, opts ...StoreOption) Store {
+	s := NewStore(opts...)
 
-	go func() {
-		for {
-			time.Sleep(cleanupLoopInterval)
-			store.Cleanup()
-		}
-	}()
+	logger := s.(*store).log
+	if logger == nil {
+		logger = NewStdLogger(log.Default())
+	}
+	runLoop(ctx, logger.WithField("prefix", "lib/store"), "store_cleanup", s.(*store).slotDuration, false, s.Cleanup)
+
+	return s
+}
 
-	return store
+// hashShard returns the shard of a block hash, whose bytes are already uniformly distributed
+func (s *store) hashShard(hash common.Hash) int {
+	return int(binary.BigEndian.Uint32(hash[common.HashLength-4:]) % uint32(s.shards))
 }
 
-func (s *store) GetExecutionPayload(blockHash common.Hash) *ExecutionPayloadWithTxRootV1 {
-	s.payloadMutex.RLock()
-	defer s.payloadMutex.RUnlock()
+// idShard returns the shard of a payload id, hashed with FNV-1a as payload ids may be any string
+func (s *store) idShard(id string) int {
+	h := uint32(2166136261)
+	for i := 0; i < len(id); i++ {
+		h ^= uint32(id[i])
+		h *= 16777619
+	}
+	return int(h % uint32(s.shards))
+}
 
-	payload, ok := s.payloads[blockHash]
+func (s *store) GetExecutionPayload(_ context.Context, blockHash common.Hash) *ExecutionPayloadWithTxRootV1 {
+	shard := &s.payloads[s.hashShard(blockHash)]
+	shard.mutex.RLock()
+	defer shard.mutex.RUnlock()
+
+	payload, ok := shard.payloads[blockHash]
+	recordStoreLookup("payload", ok)
 	if !ok {
 		return nil
 	}
@@ -92,50 +329,336 @@ func (s *store) GetExecutionPayload(blockHash common.Hash) *ExecutionPayloadWith
 	return payload.Payload
 }
 
-func (s *store) SetExecutionPayload(blockHash common.Hash, payload *ExecutionPayloadWithTxRootV1) {
+func (s *store) SetExecutionPayload(_ context.Context, blockHash common.Hash, payload *ExecutionPayloadWithTxRootV1) {
 	if payload == nil {
 		return
 	}
 
-	s.payloadMutex.Lock()
-	defer s.payloadMutex.Unlock()
+	size := payloadSize(payload)
+	shard := &s.payloads[s.hashShard(blockHash)]
+	shard.mutex.Lock()
+	s.deletePayload(shard, blockHash)
+	shard.payloads[blockHash] = executionPayloadContainer{payload, now(), size}
+	atomic.AddInt64(&s.payloadBytes, size)
+	shard.mutex.Unlock()
+
+	if s.payloadBudget > 0 {
+		s.evictPayloads(blockHash)
+	}
+	storePayloadBytes.WithLabelValues(s.name).Set(float64(atomic.LoadInt64(&s.payloadBytes)))
+}
+
+// evictPayloads removes the oldest payloads other than keep until the payloads fit in the budget. The oldest payload is
+// looked up across all shards, so evictions are serialized instead of holding the locks of all shards.
+func (s *store) evictPayloads(keep common.Hash) {
+	s.evictMutex.Lock()
+	defer s.evictMutex.Unlock()
+
+	for atomic.LoadInt64(&s.payloadBytes) > s.payloadBudget {
+		oldest, oldestAddedAt, found := common.Hash{}, time.Time{}, false
+		for i := range s.payloads {
+			shard := &s.payloads[i]
+			shard.mutex.RLock()
+			for entry, container := range shard.payloads {
+				if entry != keep && (!found || container.AddedAt.Before(oldestAddedAt)) {
+					oldest, oldestAddedAt, found = entry, container.AddedAt, true
+				}
+			}
+			shard.mutex.RUnlock()
+		}
+		if !found {
+			return
+		}
+
+		shard := &s.payloads[s.hashShard(oldest)]
+		shard.mutex.Lock()
+		s.deletePayload(shard, oldest)
+		shard.mutex.Unlock()
+		storePayloadEvictionsTotal.WithLabelValues(s.name).Inc()
+	}
+}
+
+// deletePayload removes a payload and its size from the budget, the mutex of shard must be held
+func (s *store) deletePayload(shard *payloadShard, blockHash common.Hash) {
+	if container, ok := shard.payloads[blockHash]; ok {
+		atomic.AddInt64(&s.payloadBytes, -container.Size)
+		delete(shard.payloads, blockHash)
+	}
+}
 
-	s.payloads[blockHash] = executionPayloadContainer{payload, now()}
+// payloadSize estimates the memory used by a payload, dominated by its transactions
+func payloadSize(payload *ExecutionPayloadWithTxRootV1) int64 {
+	size := int64(512 + len(payload.LogsBloom) + len(payload.ExtraData)) // fixed size fields and struct overhead
+	if payload.Transactions != nil {
+		for _, tx := range *payload.Transactions {
+			size += int64(len(tx)) + 16
+		}
+	}
+	return size
 }
 
-func (s *store) GetForkchoiceResponse(payloadID string) (map[string]string, bool) {
-	s.forkchoiceMutex.RLock()
-	defer s.forkchoiceMutex.RUnlock()
-	forkchoiceResponses, found := s.forkchoices[payloadID]
+func (s *store) GetForkchoiceResponse(_ context.Context, payloadID string) (map[string]string, bool) {
+	shard := &s.forkchoices[s.idShard(payloadID)]
+	shard.mutex.RLock()
+	defer shard.mutex.RUnlock()
+	forkchoiceResponses, found := shard.forkchoices[payloadID]
+	recordStoreLookup("forkchoice", found)
 	return forkchoiceResponses.Payload, found
 }
 
-func (s *store) SetForkchoiceResponse(boostPayloadID, relayURL, relayPayloadID string) {
-	s.forkchoiceMutex.Lock()
-	defer s.forkchoiceMutex.Unlock()
-	if _, ok := s.forkchoices[boostPayloadID]; !ok {
-		s.forkchoices[boostPayloadID] = newForkchoiceResponseContainer()
+func (s *store) SetForkchoiceResponse(_ context.Context, boostPayloadID, relayURL, relayPayloadID string) {
+	shard := &s.forkchoices[s.idShard(boostPayloadID)]
+	shard.mutex.Lock()
+	defer shard.mutex.Unlock()
+	if _, ok := shard.forkchoices[boostPayloadID]; !ok {
+		shard.forkchoices[boostPayloadID] = newForkchoiceResponseContainer()
+	}
+	shard.forkchoices[boostPayloadID].Payload[relayURL] = relayPayloadID
+}
+
+func (s *store) GetPayloadAttributes(_ context.Context, boostPayloadID string) *PayloadAttributesV1 {
+	shard := &s.forkchoices[s.idShard(boostPayloadID)]
+	shard.mutex.RLock()
+	defer shard.mutex.RUnlock()
+	return shard.forkchoices[boostPayloadID].Attributes
+}
+
+func (s *store) SetPayloadAttributes(_ context.Context, boostPayloadID string, attributes *PayloadAttributesV1) {
+	shard := &s.forkchoices[s.idShard(boostPayloadID)]
+	shard.mutex.Lock()
+	defer shard.mutex.Unlock()
+	container, ok := shard.forkchoices[boostPayloadID]
+	if !ok {
+		container = newForkchoiceResponseContainer()
+	}
+	container.Attributes = attributes
+	shard.forkchoices[boostPayloadID] = container
+}
+
+func (s *store) GetBid(_ context.Context, blockHash common.Hash) *Bid {
+	shard := &s.bids[s.hashShard(blockHash)]
+	shard.mutex.RLock()
+	defer shard.mutex.RUnlock()
+	bid, ok := shard.bids[blockHash]
+	recordStoreLookup("bid", ok)
+	return bid.Bid
+}
+
+func (s *store) SetBid(_ context.Context, blockHash common.Hash, bid *Bid) {
+	if bid == nil {
+		return
+	}
+
+	shard := &s.bids[s.hashShard(blockHash)]
+	shard.mutex.Lock()
+	defer shard.mutex.Unlock()
+	shard.bids[blockHash] = bidContainer{bid, now()}
+}
+
+func (s *store) GetProposalHeader(_ context.Context, key ProposalKey) *ExecutionPayloadWithTxRootV1 {
+	s.proposalHeaderMutex.Lock()
+	defer s.proposalHeaderMutex.Unlock()
+	container, ok := s.proposalHeaders[key.String()]
+	recordStoreLookup("proposal_header", ok)
+	return container.Header
+}
+
+func (s *store) SetProposalHeader(_ context.Context, key ProposalKey, header *ExecutionPayloadWithTxRootV1) *ExecutionPayloadWithTxRootV1 {
+	if header == nil {
+		return nil
+	}
+
+	s.proposalHeaderMutex.Lock()
+	defer s.proposalHeaderMutex.Unlock()
+	if container, ok := s.proposalHeaders[key.String()]; ok {
+		return container.Header
+	}
+	s.proposalHeaders[key.String()] = proposalHeaderContainer{header, now()}
+	return header
+}
+
+func (s *store) GetValidatorRegistration(_ context.Context, pubkey string) *SignedValidatorRegistrationV1 {
+	s.registrationMutex.RLock()
+	defer s.registrationMutex.RUnlock()
+	registration, ok := s.registrations[strings.ToLower(pubkey)]
+	recordStoreLookup("registration", ok)
+	return registration
+}
+
+func (s *store) SetValidatorRegistration(_ context.Context, registration *SignedValidatorRegistrationV1) {
+	if registration == nil || registration.Message == nil {
+		return
+	}
+
+	s.registrationMutex.Lock()
+	defer s.registrationMutex.Unlock()
+	s.registrations[registration.Message.Pubkey.String()] = registration
+}
+
+// EvictBefore implements Evicter, it removes payloads and payload attributes with an older timestamp, and entries without
+// one that were added earlier
+func (s *store) EvictBefore(_ context.Context, timestamp uint64) {
+	for i := range s.payloads {
+		shard := &s.payloads[i]
+		shard.mutex.Lock()
+		for entry, container := range shard.payloads {
+			if container.Payload.Timestamp < timestamp {
+				s.deletePayload(shard, entry)
+			}
+		}
+		shard.mutex.Unlock()
+	}
+	storePayloadBytes.WithLabelValues(s.name).Set(float64(atomic.LoadInt64(&s.payloadBytes)))
+
+	for i := range s.forkchoices {
+		shard := &s.forkchoices[i]
+		shard.mutex.Lock()
+		for entry, container := range shard.forkchoices {
+			added := uint64(container.AddedAt.Unix())
+			if container.Attributes != nil {
+				added = uint64(container.Attributes.Timestamp)
+			}
+			if added < timestamp {
+				delete(shard.forkchoices, entry)
+			}
+		}
+		shard.mutex.Unlock()
 	}
-	s.forkchoices[boostPayloadID].Payload[relayURL] = relayPayloadID
+
+	for i := range s.bids {
+		shard := &s.bids[i]
+		shard.mutex.Lock()
+		for entry, container := range shard.bids {
+			if uint64(container.AddedAt.Unix()) < timestamp {
+				delete(shard.bids, entry)
+			}
+		}
+		shard.mutex.Unlock()
+	}
+
+	s.proposalHeaderMutex.Lock()
+	for entry, container := range s.proposalHeaders {
+		if container.Header.Timestamp < timestamp {
+			delete(s.proposalHeaders, entry)
+		}
+	}
+	s.proposalHeaderMutex.Unlock()
 }
 
-// Cleanup removes all payloads older than 7 minutes (a bit more than an epoch, which is 6.4 minutes)
-func (s *store) Cleanup() {
-	// Cleanup ExecutionPayload
-	s.payloadMutex.Lock()
-	for entry := range s.payloads {
-		if time.Since(s.payloads[entry].AddedAt) > stateExpiry {
-			delete(s.payloads, entry)
+// Cleanup removes the payloads and payload attributes of slots before the retention, and entries added before it, see
+// WithRetentionSlots
+func (s *store) Cleanup(_ context.Context) {
+	cutoff := now().Add(-s.slotDuration * time.Duration(s.retentionSlots))
+	expired := func(addedAt time.Time, timestamp uint64) bool {
+		return addedAt.Before(cutoff) || (timestamp != 0 && timestamp < uint64(cutoff.Unix()))
+	}
+
+	for i := range s.payloads {
+		shard := &s.payloads[i]
+		shard.mutex.Lock()
+		for entry, container := range shard.payloads {
+			if expired(container.AddedAt, container.Payload.Timestamp) {
+				s.deletePayload(shard, entry)
+			}
+		}
+		shard.mutex.Unlock()
+	}
+	storePayloadBytes.WithLabelValues(s.name).Set(float64(atomic.LoadInt64(&s.payloadBytes)))
+
+	for i := range s.forkchoices {
+		shard := &s.forkchoices[i]
+		shard.mutex.Lock()
+		for entry, container := range shard.forkchoices {
+			var timestamp uint64
+			if container.Attributes != nil {
+				timestamp = uint64(container.Attributes.Timestamp)
+			}
+			if expired(container.AddedAt, timestamp) {
+				delete(shard.forkchoices, entry)
+			}
+		}
+		shard.mutex.Unlock()
+	}
+
+	for i := range s.bids {
+		shard := &s.bids[i]
+		shard.mutex.Lock()
+		for entry, container := range shard.bids {
+			if expired(container.AddedAt, 0) {
+				delete(shard.bids, entry)
+			}
 		}
+		shard.mutex.Unlock()
 	}
-	s.payloadMutex.Unlock()
 
-	// Cleanup ForkchoiceResponse
-	s.forkchoiceMutex.Lock()
-	for entry := range s.forkchoices {
-		if time.Since(s.forkchoices[entry].AddedAt) > stateExpiry {
-			delete(s.forkchoices, entry)
+	s.proposalHeaderMutex.Lock()
+	for entry, container := range s.proposalHeaders {
+		if expired(container.AddedAt, container.Header.Timestamp) {
+			delete(s.proposalHeaders, entry)
+		}
+	}
+	s.proposalHeaderMutex.Unlock()
+}
+
+// Dump implements Store, it locks one shard at a time so it's not a snapshot of the whole store
+func (s *store) Dump(_ context.Context) StoreDump {
+	dump := newStoreDump()
+	for i := range s.payloads {
+		shard := &s.payloads[i]
+		shard.mutex.RLock()
+		for _, container := range shard.payloads {
+			dump.Payloads = append(dump.Payloads, container.stored())
 		}
+		shard.mutex.RUnlock()
+	}
+	for i := range s.forkchoices {
+		shard := &s.forkchoices[i]
+		shard.mutex.RLock()
+		for boostPayloadID, container := range shard.forkchoices {
+			payloadIDs := make(map[string]string, len(container.Payload))
+			for relayURL, relayPayloadID := range container.Payload {
+				payloadIDs[relayURL] = relayPayloadID
+			}
+			dump.ForkchoiceResponses[boostPayloadID] = payloadIDs
+		}
+		shard.mutex.RUnlock()
+	}
+	for i := range s.bids {
+		shard := &s.bids[i]
+		shard.mutex.RLock()
+		for blockHash, container := range shard.bids {
+			dump.Bids[blockHash.Hex()] = container.Bid
+		}
+		shard.mutex.RUnlock()
+	}
+	s.registrationMutex.RLock()
+	for _, registration := range s.registrations {
+		dump.Registrations = append(dump.Registrations, registration)
+	}
+	s.registrationMutex.RUnlock()
+	s.proposalHeaderMutex.Lock()
+	for key, container := range s.proposalHeaders {
+		dump.ProposalHeaders[key] = container.Header
+	}
+	s.proposalHeaderMutex.Unlock()
+	return dump
+}
+
+func (s *store) Sizes(_ context.Context) StoreSizes {
+	var sizes StoreSizes
+	for i := 0; i < s.shards; i++ {
+		s.payloads[i].mutex.RLock()
+		sizes.Payloads += len(s.payloads[i].payloads)
+		s.payloads[i].mutex.RUnlock()
+		s.forkchoices[i].mutex.RLock()
+		sizes.ForkchoiceResponses += len(s.forkchoices[i].forkchoices)
+		s.forkchoices[i].mutex.RUnlock()
+		s.bids[i].mutex.RLock()
+		sizes.Bids += len(s.bids[i].bids)
+		s.bids[i].mutex.RUnlock()
 	}
-	s.forkchoiceMutex.Unlock()
+	s.registrationMutex.RLock()
+	sizes.Registrations = len(s.registrations)
+	s.registrationMutex.RUnlock()
+	return sizes
 }
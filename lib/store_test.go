@@ -1,46 +1,56 @@
 package lib
 
 import (
+	"context"
+	"fmt"
+	"math/big"
 	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/stretchr/testify/require"
 )
 
 func Test_store_SetGetExecutionPayload(t *testing.T) {
+	ctx := context.Background()
 	s := NewStore()
 	h := common.HexToHash("0x1")
-	payload := s.GetExecutionPayload(h)
+	payload := s.GetExecutionPayload(ctx, h)
 	if payload != nil {
 		t.Errorf("Expected nil, got %v", payload)
 	}
 
 	payload = &ExecutionPayloadWithTxRootV1{Number: 1}
-	s.SetExecutionPayload(h, payload)
-	if !reflect.DeepEqual(s.GetExecutionPayload(h), payload) {
-		t.Errorf("Expected %v, got %v", payload, s.GetExecutionPayload(h))
+	s.SetExecutionPayload(ctx, h, payload)
+	if !reflect.DeepEqual(s.GetExecutionPayload(ctx, h), payload) {
+		t.Errorf("Expected %v, got %v", payload, s.GetExecutionPayload(ctx, h))
 	}
 
 	payload = &ExecutionPayloadWithTxRootV1{Number: 2}
-	s.SetExecutionPayload(h, payload)
-	if !reflect.DeepEqual(s.GetExecutionPayload(h), payload) {
-		t.Errorf("Expected %v, got %v", payload, s.GetExecutionPayload(h))
+	s.SetExecutionPayload(ctx, h, payload)
+	if !reflect.DeepEqual(s.GetExecutionPayload(ctx, h), payload) {
+		t.Errorf("Expected %v, got %v", payload, s.GetExecutionPayload(ctx, h))
 	}
 }
 
 func Test_store_SetGetGetForkchoiceResponse(t *testing.T) {
+	ctx := context.Background()
 	s := NewStore()
 	id := "0x1"
-	_, ok := s.GetForkchoiceResponse(id)
+	_, ok := s.GetForkchoiceResponse(ctx, id)
 	require.Equal(t, false, ok)
 
 	relayURL := "abc"
 	relayPayloadID := "0x2"
-	s.SetForkchoiceResponse(id, relayURL, relayPayloadID)
+	s.SetForkchoiceResponse(ctx, id, relayURL, relayPayloadID)
 
-	res, ok := s.GetForkchoiceResponse(id)
+	res, ok := s.GetForkchoiceResponse(ctx, id)
 	require.Equal(t, true, ok)
 	require.Equal(t, res[relayURL], relayPayloadID)
 
@@ -48,40 +58,263 @@ func Test_store_SetGetGetForkchoiceResponse(t *testing.T) {
 	_, ok = res[relayURL]
 	require.Equal(t, false, ok)
 	relayPayloadID = "0x3"
-	s.SetForkchoiceResponse(id, relayURL, relayPayloadID)
+	s.SetForkchoiceResponse(ctx, id, relayURL, relayPayloadID)
 
-	res, ok = s.GetForkchoiceResponse(id)
+	res, ok = s.GetForkchoiceResponse(ctx, id)
 	require.Equal(t, true, ok)
 	require.Equal(t, res[relayURL], relayPayloadID)
 }
 
+func Test_store_LookupMetrics(t *testing.T) {
+	ctx := context.Background()
+	s := NewStore()
+	lookups := func(entry, result string) float64 {
+		return testutil.ToFloat64(storeLookupsTotal.WithLabelValues(entry, result))
+	}
+	hits, misses := lookups("payload", "hit"), lookups("payload", "miss")
+
+	h := common.HexToHash("0x1")
+	s.GetExecutionPayload(ctx, h)
+	s.SetExecutionPayload(ctx, h, &ExecutionPayloadWithTxRootV1{Number: 1})
+	s.GetExecutionPayload(ctx, h)
+	s.GetExecutionPayload(ctx, h)
+	require.Equal(t, hits+2, lookups("payload", "hit"))
+	require.Equal(t, misses+1, lookups("payload", "miss"))
+
+	misses = lookups("bid", "miss")
+	s.GetBid(ctx, h)
+	require.Equal(t, misses+1, lookups("bid", "miss"))
+}
+
 func Test_store_Cleanup(t *testing.T) {
 	// Reset 'now' after this test
 	defer func() { now = time.Now }()
 
-	s := NewStoreWithCleanup()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	s := NewStoreWithCleanup(ctx)
 	id1 := "123"
 	id2 := "234"
 
 	// Add a store item 20 minutes in the past
 	now = func() time.Time { return time.Now().Add(-20 * time.Minute) }
-	s.SetForkchoiceResponse(id1, "abc", "0x2")
+	s.SetForkchoiceResponse(ctx, id1, "abc", "0x2")
 
 	// Add a store item 5 minutes in the past
 	now = func() time.Time { return time.Now().Add(-5 * time.Minute) }
-	s.SetForkchoiceResponse(id2, "abc", "0x2")
+	s.SetForkchoiceResponse(ctx, id2, "abc", "0x2")
 
-	_, ok := s.GetForkchoiceResponse(id1)
+	_, ok := s.GetForkchoiceResponse(ctx, id1)
 	require.Equal(t, true, ok)
-	_, ok = s.GetForkchoiceResponse(id2)
+	_, ok = s.GetForkchoiceResponse(ctx, id2)
 	require.Equal(t, true, ok)
 
 	// Cleanup should remove 1 item, because it was added long enough in the past
-	s.Cleanup()
+	s.Cleanup(ctx)
 
 	// Test for items
-	_, ok = s.GetForkchoiceResponse(id1)
+	_, ok = s.GetForkchoiceResponse(ctx, id1)
 	require.Equal(t, false, ok)
-	_, ok = s.GetForkchoiceResponse(id2)
+	_, ok = s.GetForkchoiceResponse(ctx, id2)
 	require.Equal(t, true, ok)
 }
+
+func Test_store_RetentionSlots(t *testing.T) {
+	defer func() { now = time.Now }()
+
+	ctx := context.Background()
+	start := time.Unix(1_000_000, 0)
+	now = func() time.Time { return start }
+	s := NewStore(WithRetentionSlots(2))
+	s.SetExecutionPayload(ctx, common.HexToHash("0x01"), &ExecutionPayloadWithTxRootV1{Timestamp: uint64(start.Unix()) - 36})
+	s.SetExecutionPayload(ctx, common.HexToHash("0x02"), &ExecutionPayloadWithTxRootV1{Timestamp: uint64(start.Unix())})
+	s.SetPayloadAttributes(ctx, "0x01", &PayloadAttributesV1{Timestamp: hexutil.Uint64(start.Unix() - 36)})
+	s.SetForkchoiceResponse(ctx, "0x02", "http://relay", "0x02")
+	s.SetBid(ctx, common.HexToHash("0x02"), &Bid{Value: big.NewInt(1)})
+	s.SetValidatorRegistration(ctx, &SignedValidatorRegistrationV1{Message: &ValidatorRegistrationV1{Pubkey: make(hexutil.Bytes, 48)}})
+	require.Equal(t, StoreSizes{Payloads: 2, ForkchoiceResponses: 2, Bids: 1, Registrations: 1}, s.Sizes(ctx))
+
+	s.Cleanup(ctx)
+	require.Equal(t, StoreSizes{Payloads: 1, ForkchoiceResponses: 1, Bids: 1, Registrations: 1}, s.Sizes(ctx), "entries of slots before the retention are removed")
+	require.NotNil(t, s.GetExecutionPayload(ctx, common.HexToHash("0x02")))
+
+	now = func() time.Time { return start.Add(25 * time.Second) }
+	s.Cleanup(ctx)
+	require.Equal(t, StoreSizes{Registrations: 1}, s.Sizes(ctx), "entries added before the retention are removed, registrations are kept")
+
+	// with 6 second slots, 2 slots are 12 seconds
+	s = NewStore(WithRetentionSlots(2), WithSlotDuration(6*time.Second))
+	s.SetExecutionPayload(ctx, common.HexToHash("0x01"), &ExecutionPayloadWithTxRootV1{Timestamp: uint64(start.Unix())})
+	now = func() time.Time { return start.Add(13 * time.Second) }
+	s.Cleanup(ctx)
+	require.Zero(t, s.Sizes(ctx).Payloads, "the retention is counted in the slots of the network")
+}
+
+func Test_store_ConcurrentCleanup(t *testing.T) {
+	ctx := context.Background()
+	s := NewStore(WithRetentionSlots(1))
+	stale := uint64(time.Now().Add(-time.Minute).Unix())
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		i := i
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				h := common.BigToHash(big.NewInt(int64(i*100 + j)))
+				s.SetExecutionPayload(ctx, h, &ExecutionPayloadWithTxRootV1{Timestamp: stale})
+				s.SetBid(ctx, h, &Bid{Value: big.NewInt(1)})
+				s.SetPayloadAttributes(ctx, h.String(), &PayloadAttributesV1{Timestamp: hexutil.Uint64(stale)})
+				s.GetExecutionPayload(ctx, h)
+				s.Sizes(ctx)
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				s.Cleanup(ctx)
+			}
+		}()
+	}
+	wg.Wait()
+
+	s.Cleanup(ctx)
+	sizes := s.Sizes(ctx)
+	require.Equal(t, 0, sizes.Payloads)
+	require.Equal(t, 0, sizes.ForkchoiceResponses)
+	require.Equal(t, 800, sizes.Bids, "bids are pruned by the time they were added")
+	require.Zero(t, s.(*store).payloadBytes, "the payload budget is released")
+}
+
+func Test_store_PayloadMemoryBudget(t *testing.T) {
+	defer func() { now = time.Now }()
+
+	ctx := context.Background()
+	txs := []string{strings.Repeat("00", 500)}
+	payload := &ExecutionPayloadWithTxRootV1{Transactions: &txs}
+	size := payloadSize(payload)
+	s := NewStore(WithPayloadMemoryBudget(2 * size))
+
+	for i := 1; i <= 3; i++ {
+		now = func() time.Time { return time.Unix(int64(i), 0) }
+		s.SetExecutionPayload(ctx, common.BigToHash(big.NewInt(int64(i))), payload)
+	}
+	require.Nil(t, s.GetExecutionPayload(ctx, common.BigToHash(big.NewInt(1))), "oldest payload is evicted")
+	require.NotNil(t, s.GetExecutionPayload(ctx, common.BigToHash(big.NewInt(2))))
+	require.NotNil(t, s.GetExecutionPayload(ctx, common.BigToHash(big.NewInt(3))))
+	require.Equal(t, 2*size, s.(*store).payloadBytes)
+
+	// a payload larger than the budget is kept, but evicts all others
+	bigTxs := []string{strings.Repeat("00", 5000)}
+	s.SetExecutionPayload(ctx, common.HexToHash("0xff"), &ExecutionPayloadWithTxRootV1{Transactions: &bigTxs})
+	require.NotNil(t, s.GetExecutionPayload(ctx, common.HexToHash("0xff")))
+	require.Nil(t, s.GetExecutionPayload(ctx, common.BigToHash(big.NewInt(3))))
+}
+
+func Test_store_ProposalHeader(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	levelDB, err := NewLevelDBStore(ctx, t.TempDir(), time.Minute, testLog)
+	require.Nil(t, err)
+	defer levelDB.Close()
+
+	for name, s := range map[string]Store{"memory": NewStore(), "leveldb": levelDB} {
+		t.Run(name, func(t *testing.T) {
+			key := ProposalKey{Slot: 100, ParentHash: common.HexToHash("0xaa"), Proposer: "0xAB"}
+			require.Nil(t, s.GetProposalHeader(ctx, key))
+
+			first, second := storedPayload(common.HexToHash("0x01"), 1200), storedPayload(common.HexToHash("0x02"), 1200)
+			require.Equal(t, first.BlockHash, s.SetProposalHeader(ctx, key, first).BlockHash)
+			require.Equal(t, first.BlockHash, s.SetProposalHeader(ctx, key, second).BlockHash, "the first header of a proposal is kept")
+			require.Equal(t, first.BlockHash, s.GetProposalHeader(ctx, ProposalKey{100, key.ParentHash, "0xab"}).BlockHash)
+
+			otherParent := key
+			otherParent.ParentHash = common.HexToHash("0xbb")
+			require.Nil(t, s.GetProposalHeader(ctx, otherParent))
+
+			s.(Evicter).EvictBefore(ctx, 1300)
+			require.Nil(t, s.GetProposalHeader(ctx, key))
+		})
+	}
+}
+
+func Test_store_Dump(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	levelDB, err := NewLevelDBStore(ctx, t.TempDir(), time.Minute, testLog)
+	require.Nil(t, err)
+	defer levelDB.Close()
+
+	for name, s := range map[string]Store{"memory": NewStore(), "leveldb": levelDB} {
+		t.Run(name, func(t *testing.T) {
+			payload := storedPayload(common.HexToHash("0x01"), 1200)
+			payload.Transactions = &[]string{"0x02", "0x03"}
+			s.SetExecutionPayload(ctx, payload.BlockHash, payload)
+			s.SetForkchoiceResponse(ctx, "0xb00", "http://relay", "0x0a")
+			s.SetBid(ctx, payload.BlockHash, &Bid{RelayURL: "http://relay", Value: big.NewInt(5)})
+			s.SetValidatorRegistration(ctx, &SignedValidatorRegistrationV1{Message: &ValidatorRegistrationV1{Pubkey: make(hexutil.Bytes, 48)}})
+			proposal := ProposalKey{Slot: 100, ParentHash: common.HexToHash("0x04"), Proposer: "0xAB"}
+			s.SetProposalHeader(ctx, proposal, payload)
+
+			dump := s.Dump(ctx)
+			require.Len(t, dump.Payloads, 1)
+			require.Equal(t, payload.BlockHash, dump.Payloads[0].BlockHash)
+			require.Equal(t, 2, dump.Payloads[0].Transactions)
+			require.Equal(t, map[string]map[string]string{"0xb00": {"http://relay": "0x0a"}}, dump.ForkchoiceResponses)
+			require.Equal(t, "http://relay", dump.Bids[payload.BlockHash.Hex()].RelayURL)
+			require.Len(t, dump.Registrations, 1)
+			require.Len(t, dump.ProposalHeaders, 1)
+			require.Equal(t, payload.BlockHash, dump.ProposalHeaders[proposal.String()].BlockHash)
+			key, err := parseProposalKey(proposal.String())
+			require.Nil(t, err)
+			require.Equal(t, ProposalKey{Slot: 100, ParentHash: proposal.ParentHash, Proposer: "0xab"}, key)
+		})
+	}
+}
+
+// BenchmarkStore_Parallel looks up and sets payloads and forkchoice responses from concurrent requests, like with many
+// validators behind one mev-boost, with a single shard and the default shards of the in-mem store
+func BenchmarkStore_Parallel(b *testing.B) {
+	for _, shards := range []int{1, defaultStoreShards} {
+		b.Run(fmt.Sprintf("shards=%d", shards), func(b *testing.B) {
+			ctx := context.Background()
+			s := NewStore(withStoreShards(shards))
+			payload := storedPayload(common.Hash{}, 1200)
+			for i := 0; i < 1024; i++ {
+				h := common.BigToHash(big.NewInt(int64(i)))
+				s.SetExecutionPayload(ctx, h, payload)
+				s.SetForkchoiceResponse(ctx, h.Hex(), "http://relay", "0x01")
+			}
+
+			var next int64
+			b.ResetTimer()
+			b.RunParallel(func(pb *testing.PB) {
+				for pb.Next() {
+					i := atomic.AddInt64(&next, 1)
+					h := common.BigToHash(big.NewInt(i % 1024))
+					if i%4 == 0 {
+						s.SetExecutionPayload(ctx, h, payload)
+						s.SetForkchoiceResponse(ctx, h.Hex(), "http://relay", "0x02")
+						continue
+					}
+					s.GetExecutionPayload(ctx, h)
+					s.GetForkchoiceResponse(ctx, h.Hex())
+				}
+			})
+		})
+	}
+}
+
+func Test_store_PayloadBytesByStore(t *testing.T) {
+	ctx := context.Background()
+	first, second := NewStore(WithStoreName("test_first")), NewStore(WithStoreName("test_second"))
+
+	txs := []string{strings.Repeat("00", 1000)}
+	first.SetExecutionPayload(ctx, common.HexToHash("0x01"), &ExecutionPayloadWithTxRootV1{Transactions: &txs})
+	second.SetExecutionPayload(ctx, common.HexToHash("0x01"), &ExecutionPayloadWithTxRootV1{})
+
+	require.Equal(t, float64(first.(*store).payloadBytes), testutil.ToFloat64(storePayloadBytes.WithLabelValues("test_first")))
+	require.Equal(t, float64(second.(*store).payloadBytes), testutil.ToFloat64(storePayloadBytes.WithLabelValues("test_second")))
+	require.Less(t, second.(*store).payloadBytes, first.(*store).payloadBytes)
+}
@@ -0,0 +1,253 @@
+package lib
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/gorilla/mux"
+	"github.com/gorilla/websocket"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Methods of the WebSocket transport, modelled after eth_subscribe
+const (
+	methodSubscribe     = "builder_subscribe"
+	methodUnsubscribe   = "builder_unsubscribe"
+	methodSubscription  = "builder_subscription"
+	subscriptionBestBid = "bestBid"
+)
+
+const (
+	subscriptionWriteTimeout   = 2 * time.Second
+	maxSubscriptionMessageSize = 1 << 16
+)
+
+var bidSubscriptionsActive = metricsFactory.NewGauge(prometheus.GaugeOpts{
+	Name: "mevboost_bid_subscriptions",
+	Help: "Open best bid subscriptions of consensus clients",
+})
+
+var subscriptionUpgrader = websocket.Upgrader{}
+
+// isWebSocketUpgrade matches requests that open a WebSocket connection
+func isWebSocketUpgrade(r *http.Request, _ *mux.RouteMatch) bool {
+	return websocket.IsWebSocketUpgrade(r)
+}
+
+type subscriptionRequest struct {
+	ID     json.RawMessage   `json:"id"`
+	Method string            `json:"method"`
+	Params []json.RawMessage `json:"params"`
+}
+
+type subscriptionResponse struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Result  interface{}     `json:"result,omitempty"`
+	Error   *rpcError       `json:"error,omitempty"`
+}
+
+type subscriptionNotification struct {
+	JSONRPC string `json:"jsonrpc"`
+	Method  string `json:"method"`
+	Params  struct {
+		Subscription string      `json:"subscription"`
+		Result       interface{} `json:"result"`
+	} `json:"params"`
+}
+
+// subscriptionConn is the WebSocket connection of a consensus client with its open subscriptions
+type subscriptionConn struct {
+	ws            *websocket.Conn
+	writeMu       sync.Mutex
+	mu            sync.Mutex
+	subscriptions map[string]context.CancelFunc
+}
+
+func (c *subscriptionConn) write(v interface{}) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+
+	c.ws.SetWriteDeadline(time.Now().Add(subscriptionWriteTimeout))
+	return c.ws.WriteJSON(v)
+}
+
+func (c *subscriptionConn) reply(id json.RawMessage, result interface{}, err error) error {
+	resp := subscriptionResponse{JSONRPC: "2.0", ID: id, Result: result}
+	if err != nil {
+		resp.Result = nil
+		resp.Error = &rpcError{Code: -32000, Message: err.Error()}
+		var methodErr *MethodError
+		var rpcErr *rpcError
+		if errors.As(err, &methodErr) {
+			resp.Error.Code = methodErr.ErrorCode()
+		} else if errors.As(err, &rpcErr) {
+			resp.Error = rpcErr
+		}
+	}
+	return c.write(resp)
+}
+
+func (c *subscriptionConn) notify(id string, result interface{}) error {
+	notification := subscriptionNotification{JSONRPC: "2.0", Method: methodSubscription}
+	notification.Params.Subscription = id
+	notification.Params.Result = result
+	return c.write(notification)
+}
+
+func (c *subscriptionConn) add(id string, cancel context.CancelFunc) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.subscriptions[id] = cancel
+}
+
+// remove cancels a subscription and reports whether it was open
+func (c *subscriptionConn) remove(id string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	cancel, ok := c.subscriptions[id]
+	if ok {
+		cancel()
+		delete(c.subscriptions, id)
+	}
+	return ok
+}
+
+// handleWebSocket serves JSON-RPC over WebSocket. builder_subscribe("bestBid", payloadID) pushes the header
+// getPayloadHeader would return for payloadID whenever a more valuable bid arrives, until a third into its slot. With
+// WithWebSocketRPC, all other calls are served by the router like requests to /, concurrently.
+func (m *RelayService) handleWebSocket(w http.ResponseWriter, r *http.Request) {
+	ws, err := subscriptionUpgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return // the upgrader responded with the error
+	}
+	defer ws.Close()
+	if m.webSocketRPC != nil {
+		ws.SetReadLimit(maxRelayResponseSize)
+	} else {
+		ws.SetReadLimit(maxSubscriptionMessageSize)
+	}
+	var calls sync.WaitGroup
+	defer calls.Wait()
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	conn := &subscriptionConn{ws: ws, subscriptions: make(map[string]context.CancelFunc)}
+	if m.webSocketReadTimeout > 0 {
+		m.keepWebSocketAlive(ctx, ws)
+	}
+	rpcRequest := webSocketRPCRequest(ctx, r)
+	for {
+		if m.webSocketReadTimeout > 0 {
+			ws.SetReadDeadline(time.Now().Add(m.webSocketReadTimeout))
+		}
+		_, message, err := ws.ReadMessage()
+		if err != nil {
+			return
+		}
+		var req subscriptionRequest
+		parseErr := json.Unmarshal(message, &req)
+		if m.webSocketRPC != nil && (parseErr != nil || !m.isSubscriptionMethod(req.Method)) {
+			calls.Add(1)
+			go func() {
+				defer calls.Done()
+				if conn.write(serveRPC(m.webSocketRPC, rpcRequest, message)) != nil {
+					ws.Close() // ends the read loop
+				}
+			}()
+			continue
+		}
+		if parseErr != nil {
+			return
+		}
+
+		switch req.Method {
+		case methodSubscribe:
+			payloadID, deadline, err := m.bidSubscriptionParams(ctx, req.Params)
+			if err != nil {
+				err = conn.reply(req.ID, nil, err)
+				break
+			}
+			id := make(hexutil.Bytes, 16)
+			if _, err := rand.Read(id); err != nil {
+				return
+			}
+			subscriptionCtx, cancelSubscription := context.WithTimeout(ctx, deadline.Sub(now()))
+			conn.add(id.String(), cancelSubscription)
+			if err = conn.reply(req.ID, id.String(), nil); err == nil {
+				go m.pushBestBids(subscriptionCtx, conn, id.String(), payloadID)
+			}
+		case methodUnsubscribe:
+			var id string
+			if len(req.Params) != 1 || json.Unmarshal(req.Params[0], &id) != nil {
+				err = conn.reply(req.ID, nil, &rpcError{Code: rpcErrInvalidParams, Message: "expected a subscription id"})
+			} else if conn.remove(id) {
+				err = conn.reply(req.ID, true, nil)
+			} else {
+				err = conn.reply(req.ID, nil, &rpcError{Code: rpcErrInvalidParams, Message: "subscription not found"})
+			}
+		default:
+			err = conn.reply(req.ID, nil, &rpcError{Code: rpcErrMethodNotFound, Message: "method not found"})
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+// bidSubscriptionParams parses the params of a best bid subscription and returns when it ends, a third into the slot of
+// the payload id, when the header has to be signed to get the block attested
+func (m *RelayService) bidSubscriptionParams(ctx context.Context, params []json.RawMessage) (string, time.Time, error) {
+	var kind string
+	var payloadID hexutil.Bytes
+	if len(params) != 2 || json.Unmarshal(params[0], &kind) != nil || kind != subscriptionBestBid || json.Unmarshal(params[1], &payloadID) != nil {
+		return "", time.Time{}, &rpcError{Code: rpcErrInvalidParams, Message: `expected params ["bestBid", payloadId]`}
+	}
+	attributes := m.store.GetPayloadAttributes(ctx, payloadID.String())
+	if attributes == nil {
+		return "", time.Time{}, newMethodError(ErrUnknownPayload, "no proposal for payloadID %s", payloadID)
+	}
+	deadline := m.clock.local(m.chain.AttestationDeadline(uint64(attributes.Timestamp)))
+	if !now().Before(deadline) {
+		return "", time.Time{}, newMethodError(ErrStalePayloadID, "the proposal of payloadID %s is over", payloadID)
+	}
+	return payloadID.String(), deadline, nil
+}
+
+// pushBestBids requests the headers of payloadID every pushInterval and notifies the subscription of the
+// best one whenever it's more valuable than the last one pushed, until ctx is done
+func (m *RelayService) pushBestBids(ctx context.Context, conn *subscriptionConn, id, payloadID string) {
+	bidSubscriptionsActive.Inc()
+	defer bidSubscriptionsActive.Dec()
+	defer conn.remove(id)
+
+	ticker := time.NewTicker(m.pushInterval)
+	defer ticker.Stop()
+	var best *big.Int
+	for {
+		header := new(ExecutionPayloadWithTxRootV1)
+		err := m.GetPayloadHeaderV1(contextRequest(ctx), &payloadID, header)
+		if errors.Is(err, ErrUnknownPayload) || errors.Is(err, ErrStalePayloadID) {
+			return
+		}
+		if err == nil && (best == nil || bidValue(header).Cmp(best) > 0) {
+			best = bidValue(header)
+			if conn.notify(id, header) != nil {
+				return
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,81 @@
+package lib
+
+import (
+	"context"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRouter_BidSubscriptions(t *testing.T) {
+	var calls int64
+	relay := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		value := (atomic.AddInt64(&calls, 1) + 1) / 2 // every value is offered twice
+		if value > 3 {
+			value = 3
+		}
+		resp, err := formatResponse(ExecutionPayloadWithTxRootV1{
+			BlockHash:        common.BigToHash(big.NewInt(value)),
+			BaseFeePerGas:    big.NewInt(1),
+			FeeRecipientDiff: big.NewInt(value),
+		})
+		require.Nil(t, err)
+		w.Write(resp)
+	}))
+	defer relay.Close()
+
+	store := NewStore()
+	store.SetForkchoiceResponse(context.Background(), "0x01", relay.URL, "0x01")
+	store.SetPayloadAttributes(context.Background(), "0x01", &PayloadAttributesV1{Timestamp: hexutil.Uint64(time.Now().Unix())})
+	router, err := NewRouter(context.Background(), WithRelayURLs(relay.URL), WithStore(store), WithLogger(testLog),
+		WithCapabilityCheckInterval(0), WithBidSubscriptions(time.Millisecond))
+	require.Nil(t, err)
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	ws, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), nil)
+	require.Nil(t, err)
+	defer ws.Close()
+	call := func(method string, params ...interface{}) subscriptionResponse {
+		require.Nil(t, ws.WriteJSON(map[string]interface{}{"jsonrpc": "2.0", "id": 1, "method": method, "params": params}))
+		var resp subscriptionResponse
+		require.Nil(t, ws.ReadJSON(&resp))
+		return resp
+	}
+
+	resp := call(methodSubscribe, subscriptionBestBid, "0x01")
+	require.Nil(t, resp.Error)
+	id, ok := resp.Result.(string)
+	require.True(t, ok, resp.Result)
+
+	for _, expected := range []string{"0x1", "0x2", "0x3"} {
+		var notification struct {
+			Method string `json:"method"`
+			Params struct {
+				Subscription string                       `json:"subscription"`
+				Result       ExecutionPayloadWithTxRootV1 `json:"result"`
+			} `json:"params"`
+		}
+		require.Nil(t, ws.ReadJSON(&notification))
+		require.Equal(t, methodSubscription, notification.Method)
+		require.Equal(t, id, notification.Params.Subscription)
+		require.Equal(t, expected, (*hexutil.Big)(notification.Params.Result.FeeRecipientDiff).String(), "only improvements are pushed")
+	}
+	require.Equal(t, true, call(methodUnsubscribe, id).Result)
+
+	resp = call(methodUnsubscribe, id)
+	require.Equal(t, rpcErrInvalidParams, resp.Error.Code)
+	resp = call(methodSubscribe, subscriptionBestBid, "0x02")
+	require.Equal(t, ErrorCodeUnknownPayload, resp.Error.Code)
+	resp = call("builder_getPayloadHeaderV1", "0x01")
+	require.Equal(t, rpcErrMethodNotFound, resp.Error.Code)
+}
@@ -0,0 +1,158 @@
+package lib
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"os"
+)
+
+// Tenant is a consensus client served with its own relays and bid policy, so one mev-boost can serve several customers
+type Tenant struct {
+	Name string `json:"name"`
+	// Token identifies the requests of the tenant, as bearer token or basic auth password
+	Token string `json:"token"`
+	// RelayURLs are the relays used for the tenant, a subset of the configured relays. All relays are used if empty.
+	RelayURLs []string `json:"relays,omitempty"`
+	// MinBid is the lowest bid value in wei returned to the tenant, lower bids are ignored
+	MinBid *big.Int `json:"min_bid,omitempty"`
+	// Validation checks relay responses for the tenant, in addition to the policy of WithValidationPolicy
+	Validation ValidationPolicy `json:"-"`
+
+	relays map[string]bool
+}
+
+// LoadTenants reads a JSON list of tenants from path
+func LoadTenants(path string) ([]Tenant, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var tenants []Tenant
+	if err := json.Unmarshal(data, &tenants); err != nil {
+		return nil, fmt.Errorf("could not parse tenants: %w", err)
+	}
+	return tenants, nil
+}
+
+// usesRelay reports whether the relay at url is used for the tenant, a nil tenant uses all relays
+func (t *Tenant) usesRelay(url string) bool {
+	return t == nil || len(t.relays) == 0 || t.relays[url]
+}
+
+// relaysOf returns the forkchoice responses of the relays used for the tenant, in a new map
+func (t *Tenant) relaysOf(forkchoiceResponses map[string]string) map[string]string {
+	filtered := make(map[string]string, len(forkchoiceResponses))
+	for relayURL, payloadID := range forkchoiceResponses {
+		if t.usesRelay(relayURL) {
+			filtered[relayURL] = payloadID
+		}
+	}
+	return filtered
+}
+
+// acceptsBid reports whether a bid of value is high enough for the tenant
+func (t *Tenant) acceptsBid(value *big.Int) bool {
+	return t == nil || t.MinBid == nil || value.Cmp(t.MinBid) >= 0
+}
+
+// satisfiesFloor reports whether the min bid of the tenant meets the bid floor of a relay, a nil tenant accepts any bid
+func (t *Tenant) satisfiesFloor(floor *big.Int) bool {
+	if floor == nil {
+		return true
+	}
+	return t != nil && t.MinBid != nil && t.MinBid.Cmp(floor) >= 0
+}
+
+func (t *Tenant) validateHeader(ctx context.Context, relayURL string, header *ExecutionPayloadWithTxRootV1) error {
+	if t == nil {
+		return nil
+	}
+	return t.Validation.validateHeader(ctx, relayURL, header)
+}
+
+func (t *Tenant) validatePayload(ctx context.Context, relayURL string, payload *ExecutionPayloadWithTxRootV1) error {
+	if t == nil {
+		return nil
+	}
+	return t.Validation.validatePayload(ctx, relayURL, payload)
+}
+
+// tenantSet identifies the tenant of a request by its token
+type tenantSet struct {
+	tenants []*Tenant
+}
+
+func newTenantSet(tenants []Tenant, relayURLs []string) (*tenantSet, error) {
+	configured := make(map[string]bool, len(relayURLs))
+	for _, url := range relayURLs {
+		configured[url] = true
+	}
+
+	set := &tenantSet{}
+	names := make(map[string]bool)
+	tokens := make(map[string]bool)
+	for i := range tenants {
+		tenant := tenants[i]
+		if tenant.Name == "" || tenant.Token == "" {
+			return nil, fmt.Errorf("tenant %d: name and token are required", i)
+		}
+		if names[tenant.Name] || tokens[tenant.Token] {
+			return nil, fmt.Errorf("tenant %s: name and token must be unique", tenant.Name)
+		}
+		names[tenant.Name], tokens[tenant.Token] = true, true
+		if tenant.MinBid != nil && tenant.MinBid.Sign() < 0 {
+			return nil, fmt.Errorf("tenant %s: negative min bid", tenant.Name)
+		}
+
+		tenant.relays = make(map[string]bool, len(tenant.RelayURLs))
+		for _, url := range tenant.RelayURLs {
+			if !configured[url] {
+				return nil, fmt.Errorf("tenant %s: relay %s is not configured", tenant.Name, url)
+			}
+			tenant.relays[url] = true
+		}
+		set.tenants = append(set.tenants, &tenant)
+	}
+	return set, nil
+}
+
+// identify returns the tenant with token, or nil
+func (s *tenantSet) identify(token string) *Tenant {
+	var found *Tenant
+	for _, tenant := range s.tenants {
+		// compare against all tokens, so the timing doesn't tell which token matched
+		if matchesToken(token, []string{tenant.Token}) {
+			found = tenant
+		}
+	}
+	return found
+}
+
+type tenantContextKey struct{}
+
+// tenantFromContext returns the tenant of a request, nil without tenants
+func tenantFromContext(ctx context.Context) *Tenant {
+	tenant, _ := ctx.Value(tenantContextKey{}).(*Tenant)
+	return tenant
+}
+
+// handler rejects requests without a tenant token with 401 or 403, and passes the tenant of the others on in the
+// request context
+func (s *tenantSet) handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, ok := requestToken(r)
+		if !ok {
+			respondJSON(w, http.StatusUnauthorized, keymanagerError{"missing token"})
+			return
+		}
+		tenant := s.identify(token)
+		if tenant == nil {
+			respondJSON(w, http.StatusForbidden, keymanagerError{"invalid token"})
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), tenantContextKey{}, tenant)))
+	})
+}
@@ -0,0 +1,101 @@
+package lib
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRouter_Tenants(t *testing.T) {
+	newRelay := func(blockHash string, value int64) *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			resp, err := formatResponse(ExecutionPayloadWithTxRootV1{
+				BlockHash:        common.HexToHash(blockHash),
+				BaseFeePerGas:    big.NewInt(1),
+				FeeRecipientDiff: big.NewInt(value),
+			})
+			require.Nil(t, err)
+			w.Header().Set("Content-Type", "application/json")
+			w.Write(resp)
+		}))
+	}
+	low, high := newRelay("0x01", 1), newRelay("0x02", 2)
+	defer low.Close()
+	defer high.Close()
+
+	store := NewStore()
+	store.SetForkchoiceResponse(context.Background(), "0x01", low.URL, "0x01")
+	store.SetForkchoiceResponse(context.Background(), "0x01", high.URL, "0x01")
+	router, err := NewRouter(context.Background(), WithRelayURLs(low.URL, high.URL), WithStore(store), WithLogger(testLog), WithCapabilityCheckInterval(0),
+		WithTenants(
+			Tenant{Name: "alice", Token: "alice-token", RelayURLs: []string{low.URL}},
+			Tenant{Name: "bob", Token: "bob-token", MinBid: big.NewInt(3)},
+			Tenant{Name: "carol", Token: "carol-token", Validation: ValidationPolicy{
+				Header: func(_ context.Context, relayURL string, _ *ExecutionPayloadWithTxRootV1) error {
+					if relayURL == high.URL {
+						return ErrValidationFailed
+					}
+					return nil
+				},
+			}},
+			Tenant{Name: "dave", Token: "dave-token"},
+		))
+	require.Nil(t, err)
+
+	getHeader := func(token string) (int, *rpcResponse) {
+		body, err := formatRequestBody("builder_getPayloadHeaderV1", []interface{}{"0x01"})
+		require.Nil(t, err)
+		req := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		if rr.Code != http.StatusOK {
+			return rr.Code, nil
+		}
+		resp, err := parseRPCResponse(rr.Body.Bytes())
+		require.Nil(t, err)
+		return rr.Code, resp
+	}
+	blockHash := func(resp *rpcResponse) common.Hash {
+		require.Nil(t, resp.Error)
+		header := new(ExecutionPayloadWithTxRootV1)
+		require.Nil(t, json.Unmarshal(resp.Result, header))
+		return header.BlockHash
+	}
+
+	code, _ := getHeader("")
+	require.Equal(t, http.StatusUnauthorized, code)
+	code, _ = getHeader("mallory-token")
+	require.Equal(t, http.StatusForbidden, code)
+
+	_, resp := getHeader("alice-token")
+	require.Equal(t, common.HexToHash("0x01"), blockHash(resp), "alice only uses the low relay")
+	_, resp = getHeader("bob-token")
+	require.NotNil(t, resp.Error, "no bid reaches the min bid of bob")
+	_, resp = getHeader("carol-token")
+	require.Equal(t, common.HexToHash("0x01"), blockHash(resp), "the policy of carol rejects the high relay")
+	_, resp = getHeader("dave-token")
+	require.Equal(t, common.HexToHash("0x02"), blockHash(resp))
+}
+
+func Test_newTenantSet(t *testing.T) {
+	relays := []string{"http://relay"}
+	_, err := newTenantSet([]Tenant{{Name: "alice", Token: "a", RelayURLs: []string{"http://other"}}}, relays)
+	require.Error(t, err, "relays must be configured")
+	_, err = newTenantSet([]Tenant{{Name: "alice", Token: "a"}, {Name: "bob", Token: "a"}}, relays)
+	require.Error(t, err, "tokens must be unique")
+	_, err = newTenantSet([]Tenant{{Name: "alice"}}, relays)
+	require.Error(t, err, "tokens are required")
+	_, err = newRelayService(WithRelayURLs(relays...), WithTenants(Tenant{Name: "alice", Token: "a"}), WithStableHeaders())
+	require.Error(t, err)
+}
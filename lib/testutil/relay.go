@@ -0,0 +1,272 @@
+// Package testutil has a mock relay and a recording store to test code built on mev-boost
+package testutil
+
+import (
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/flashbots/mev-boost/lib"
+	"github.com/flashbots/mev-boost/lib/internal/blstest"
+)
+
+// Methods served by MockRelay
+const (
+	MethodForkchoiceUpdated = "engine_forkchoiceUpdatedV1"
+	MethodGetPayloadHeader  = "relay_getPayloadHeaderV1"
+	MethodProposeBlock      = "relay_proposeBlindedBlockV1"
+	MethodGetCapabilities   = "relay_getCapabilitiesV1"
+	MethodRegisterValidator = "relay_registerValidatorV1"
+)
+
+// Fault makes MockRelay misbehave for a method
+type Fault struct {
+	Delay        time.Duration // wait before answering, or until the request is cancelled
+	StatusCode   int           // HTTP status code of the reply, defaults to 200
+	ErrorCode    int           // JSON-RPC error code of the reply, 0 for a successful reply
+	ErrorMessage string
+	Malformed    bool // reply with invalid JSON
+
+	InvalidSignature bool // for getPayloadHeader, reply with a bid signature that doesn't verify, see SignBids
+	PayloadMismatch  bool // for proposeBlindedBlock, reveal a payload of another block than the one of the header
+}
+
+// MockRelay is a relay serving programmable bids and faults
+type MockRelay struct {
+	server *httptest.Server
+
+	mu           sync.Mutex
+	payloadID    hexutil.Bytes
+	payload      *lib.ExecutionPayloadWithTxRootV1
+	capabilities *lib.RelayCapabilities
+	faults       map[string]Fault
+	requests     map[string][]json.RawMessage
+
+	secretKey *big.Int // nil unless bids are signed
+	pubkey    []byte
+	domain    [32]byte
+}
+
+// NewMockRelay starts a relay that bids 1 wei for block 0x01. Call Close when done.
+func NewMockRelay() *MockRelay {
+	relay := &MockRelay{
+		payloadID: hexutil.Bytes{0, 0, 0, 0, 0, 0, 0, 1},
+		faults:    make(map[string]Fault),
+		requests:  make(map[string][]json.RawMessage),
+	}
+	relay.SetBid(common.HexToHash("0x01"), big.NewInt(1))
+	relay.server = httptest.NewServer(relay)
+	return relay
+}
+
+// URL of the relay, with the pubkey of the relay in the user part if bids are signed
+func (r *MockRelay) URL() string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.pubkey == nil {
+		return r.server.URL
+	}
+	return strings.Replace(r.server.URL, "://", "://"+hexutil.Encode(r.pubkey)+"@", 1)
+}
+
+// Close stops the relay
+func (r *MockRelay) Close() {
+	r.server.Close()
+}
+
+// SetBid makes the relay return a header and payload for blockHash, paying value to the proposer
+func (r *MockRelay) SetBid(blockHash common.Hash, value *big.Int) {
+	r.SetPayload(&lib.ExecutionPayloadWithTxRootV1{
+		BlockHash:        blockHash,
+		BaseFeePerGas:    big.NewInt(7),
+		FeeRecipientDiff: value,
+		LogsBloom:        make([]byte, 256),
+		ExtraData:        []byte{},
+	})
+}
+
+// SetPayload makes the relay return payload, with its transactions removed for getPayloadHeader
+func (r *MockRelay) SetPayload(payload *lib.ExecutionPayloadWithTxRootV1) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.payload = payload
+}
+
+// SignBids makes the relay sign its headers with secretKey in the builder domain of chain, like relays verified with
+// lib.WithBidSignatureVerification. Call it before passing URL to mev-boost, which then has the pubkey of the relay.
+func (r *MockRelay) SignBids(secretKey *big.Int, chain *lib.ChainConfig) error {
+	pubkey, _, err := blstest.Sign(secretKey, [32]byte{})
+	if err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.secretKey, r.pubkey, r.domain = secretKey, pubkey, chain.BuilderDomain()
+	return nil
+}
+
+// SetPayloadID sets the payload id returned by forkchoiceUpdated
+func (r *MockRelay) SetPayloadID(payloadID hexutil.Bytes) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.payloadID = payloadID
+}
+
+// SetCapabilities sets the reply of getCapabilities, nil replies with method not found
+func (r *MockRelay) SetCapabilities(capabilities *lib.RelayCapabilities) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.capabilities = capabilities
+}
+
+// SetFault makes the relay misbehave for method
+func (r *MockRelay) SetFault(method string, fault Fault) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.faults[method] = fault
+}
+
+// ClearFaults makes the relay behave again
+func (r *MockRelay) ClearFaults() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.faults = make(map[string]Fault)
+}
+
+// Requests returns the params of all requests the relay received for method
+func (r *MockRelay) Requests(method string) []json.RawMessage {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]json.RawMessage{}, r.requests[method]...)
+}
+
+// Registrations returns the validator registrations the relay received, in the order they were received
+func (r *MockRelay) Registrations() ([]lib.SignedValidatorRegistrationV1, error) {
+	var registrations []lib.SignedValidatorRegistrationV1
+	for _, params := range r.Requests(MethodRegisterValidator) {
+		var batch [][]lib.SignedValidatorRegistrationV1
+		if err := json.Unmarshal(params, &batch); err != nil {
+			return nil, err
+		}
+		if len(batch) == 1 {
+			registrations = append(registrations, batch[0]...)
+		}
+	}
+	return registrations, nil
+}
+
+// ServeHTTP implements http.Handler
+func (r *MockRelay) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	rpcReq := struct {
+		ID     json.RawMessage `json:"id"`
+		Method string          `json:"method"`
+		Params json.RawMessage `json:"params"`
+	}{}
+	if err := json.NewDecoder(req.Body).Decode(&rpcReq); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	r.mu.Lock()
+	r.requests[rpcReq.Method] = append(r.requests[rpcReq.Method], rpcReq.Params)
+	fault := r.faults[rpcReq.Method]
+	result, found, err := r.result(rpcReq.Method, fault)
+	r.mu.Unlock()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if fault.Delay > 0 {
+		select {
+		case <-time.After(fault.Delay):
+		case <-req.Context().Done():
+			return
+		}
+	}
+
+	res := map[string]interface{}{"jsonrpc": "2.0", "id": rpcReq.ID}
+	switch {
+	case fault.ErrorCode != 0:
+		res["error"] = map[string]interface{}{"code": fault.ErrorCode, "message": fault.ErrorMessage}
+	case !found:
+		res["error"] = map[string]interface{}{"code": -32601, "message": "method not found"}
+	default:
+		res["result"] = result
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if fault.StatusCode != 0 {
+		w.WriteHeader(fault.StatusCode)
+	}
+	if fault.Malformed {
+		w.Write([]byte(`{"jsonrpc":"2.0","result":`))
+		return
+	}
+	json.NewEncoder(w).Encode(res)
+}
+
+// result returns the successful reply of a method, misbehaving as fault says, r.mu must be held
+func (r *MockRelay) result(method string, fault Fault) (interface{}, bool, error) {
+	switch method {
+	case MethodForkchoiceUpdated:
+		return &lib.ForkChoiceResponse{
+			PayloadStatus: lib.PayloadStatus{Status: lib.ForkchoiceStatusValid},
+			PayloadID:     &r.payloadID,
+		}, true, nil
+	case MethodGetPayloadHeader:
+		header := *r.payload
+		header.Transactions = nil
+		if r.secretKey == nil {
+			return &header, true, nil
+		}
+		signed, err := r.signedHeader(&header, fault.InvalidSignature)
+		return signed, true, err
+	case MethodProposeBlock:
+		if fault.PayloadMismatch {
+			payload := *r.payload
+			payload.BlockHash[common.HashLength-1] ^= 0xff
+			return &payload, true, nil
+		}
+		return r.payload, true, nil
+	case MethodGetCapabilities:
+		return r.capabilities, r.capabilities != nil, nil
+	case MethodRegisterValidator:
+		return "OK", true, nil
+	}
+	return nil, false, nil
+}
+
+// signedHeader returns header with the signature of its bid, corrupted if invalid is set, r.mu must be held
+func (r *MockRelay) signedHeader(header *lib.ExecutionPayloadWithTxRootV1, invalid bool) (map[string]interface{}, error) {
+	bid := &lib.BuilderBid{Header: header.Header(), Value: header.FeeRecipientDiff}
+	copy(bid.Pubkey[:], r.pubkey)
+	root, err := bid.HashTreeRoot()
+	if err != nil {
+		return nil, err
+	}
+	_, signature, err := blstest.Sign(r.secretKey, lib.ComputeSigningRoot(root, r.domain))
+	if err != nil {
+		return nil, err
+	}
+	if invalid {
+		signature[len(signature)-1] ^= 1
+	}
+
+	data, err := json.Marshal(header)
+	if err != nil {
+		return nil, err
+	}
+	var signed map[string]interface{}
+	if err := json.Unmarshal(data, &signed); err != nil {
+		return nil, err
+	}
+	signed["signature"] = hexutil.Bytes(signature)
+	return signed, nil
+}
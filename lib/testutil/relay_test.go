@@ -0,0 +1,84 @@
+package testutil
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/flashbots/mev-boost/lib"
+	"github.com/flashbots/mev-boost/lib/client"
+	"github.com/flashbots/mev-boost/lib/logrusadapter"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/require"
+)
+
+func newBoost(t *testing.T, store lib.Store, relays ...*MockRelay) *client.Client {
+	urls := make([]string, len(relays))
+	for i, relay := range relays {
+		urls[i] = relay.URL()
+	}
+	router, err := lib.NewRouter(context.Background(), lib.WithRelayURLs(urls...), lib.WithStore(store), lib.WithLogger(logrusadapter.New(logrus.WithField("testing", true))), lib.WithCapabilityCheckInterval(0))
+	require.Nil(t, err)
+	server := httptest.NewServer(router)
+	t.Cleanup(server.Close)
+	return client.New(server.URL)
+}
+
+func blindedBlock(header *lib.ExecutionPayloadWithTxRootV1) *lib.SignedBlindedBeaconBlock {
+	return &lib.SignedBlindedBeaconBlock{
+		Message: &lib.BlindedBeaconBlock{Body: &lib.BlindedBeaconBlockBody{ExecutionPayloadHeader: header.Header()}},
+	}
+}
+
+func TestMockRelay_BestBid(t *testing.T) {
+	low, high := NewMockRelay(), NewMockRelay()
+	defer low.Close()
+	defer high.Close()
+	low.SetBid(common.HexToHash("0x01"), big.NewInt(1))
+	high.SetBid(common.HexToHash("0x02"), big.NewInt(2))
+
+	store := NewStore()
+	boost := newBoost(t, store, low, high)
+
+	ctx := context.Background()
+	fcu, err := boost.ForkchoiceUpdated(ctx, &lib.ForkchoiceStateV1{}, nil)
+	require.Nil(t, err)
+	header, err := boost.GetPayloadHeader(ctx, fcu.PayloadID.String())
+	require.Nil(t, err)
+	require.Equal(t, common.HexToHash("0x02"), header.BlockHash)
+	require.Len(t, low.Requests(MethodGetPayloadHeader), 1)
+	require.Len(t, high.Requests(MethodGetPayloadHeader), 1)
+	require.Equal(t, high.URL(), store.GetBid(ctx, header.BlockHash).RelayURL)
+
+	// the payload is asked from the relays when the store lost it
+	store.ForgetPayloads(true)
+	payload, err := boost.ProposeBlindedBlock(ctx, blindedBlock(header))
+	require.Nil(t, err)
+	require.Equal(t, header.BlockHash, payload.BlockHash)
+	require.Len(t, high.Requests(MethodProposeBlock), 1)
+}
+
+func TestMockRelay_Faults(t *testing.T) {
+	relay := NewMockRelay()
+	defer relay.Close()
+	boost := newBoost(t, NewStore(), relay)
+	ctx := context.Background()
+
+	fcu, err := boost.ForkchoiceUpdated(ctx, &lib.ForkchoiceStateV1{}, nil)
+	require.Nil(t, err)
+
+	relay.SetFault(MethodGetPayloadHeader, Fault{ErrorCode: -32000, ErrorMessage: "no bid"})
+	_, err = boost.GetPayloadHeader(ctx, fcu.PayloadID.String())
+	require.True(t, errors.Is(err, lib.ErrNoBids))
+
+	relay.SetFault(MethodGetPayloadHeader, Fault{Malformed: true})
+	_, err = boost.GetPayloadHeader(ctx, fcu.PayloadID.String())
+	require.Error(t, err)
+
+	relay.ClearFaults()
+	_, err = boost.GetPayloadHeader(ctx, fcu.PayloadID.String())
+	require.Nil(t, err)
+}
@@ -0,0 +1,118 @@
+package testutil
+
+import (
+	"context"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/flashbots/mev-boost/lib"
+)
+
+// Store is an in-memory lib.Store that counts calls per method, and can be told to forget payloads to test store misses
+type Store struct {
+	lib.Store
+
+	mu            sync.Mutex
+	calls         map[string]int
+	forgetPayload bool
+}
+
+// NewStore creates an empty store
+func NewStore() *Store {
+	return &Store{
+		Store: lib.NewStore(),
+		calls: make(map[string]int),
+	}
+}
+
+// Calls returns how often method was called
+func (s *Store) Calls(method string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.calls[method]
+}
+
+// ForgetPayloads makes GetExecutionPayload miss, like after a restart
+func (s *Store) ForgetPayloads(forget bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.forgetPayload = forget
+}
+
+func (s *Store) call(method string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.calls[method]++
+}
+
+// GetExecutionPayload implements lib.Store
+func (s *Store) GetExecutionPayload(ctx context.Context, blockHash common.Hash) *lib.ExecutionPayloadWithTxRootV1 {
+	s.call("GetExecutionPayload")
+	s.mu.Lock()
+	forget := s.forgetPayload
+	s.mu.Unlock()
+	if forget {
+		return nil
+	}
+	return s.Store.GetExecutionPayload(ctx, blockHash)
+}
+
+// SetExecutionPayload implements lib.Store
+func (s *Store) SetExecutionPayload(ctx context.Context, blockHash common.Hash, payload *lib.ExecutionPayloadWithTxRootV1) {
+	s.call("SetExecutionPayload")
+	s.Store.SetExecutionPayload(ctx, blockHash, payload)
+}
+
+// SetForkchoiceResponse implements lib.Store
+func (s *Store) SetForkchoiceResponse(ctx context.Context, boostPayloadID, relayURL, relayPayloadID string) {
+	s.call("SetForkchoiceResponse")
+	s.Store.SetForkchoiceResponse(ctx, boostPayloadID, relayURL, relayPayloadID)
+}
+
+// GetForkchoiceResponse implements lib.Store
+func (s *Store) GetForkchoiceResponse(ctx context.Context, boostPayloadID string) (map[string]string, bool) {
+	s.call("GetForkchoiceResponse")
+	return s.Store.GetForkchoiceResponse(ctx, boostPayloadID)
+}
+
+// SetPayloadAttributes implements lib.Store
+func (s *Store) SetPayloadAttributes(ctx context.Context, boostPayloadID string, attributes *lib.PayloadAttributesV1) {
+	s.call("SetPayloadAttributes")
+	s.Store.SetPayloadAttributes(ctx, boostPayloadID, attributes)
+}
+
+// GetPayloadAttributes implements lib.Store
+func (s *Store) GetPayloadAttributes(ctx context.Context, boostPayloadID string) *lib.PayloadAttributesV1 {
+	s.call("GetPayloadAttributes")
+	return s.Store.GetPayloadAttributes(ctx, boostPayloadID)
+}
+
+// GetBid implements lib.Store
+func (s *Store) GetBid(ctx context.Context, blockHash common.Hash) *lib.Bid {
+	s.call("GetBid")
+	return s.Store.GetBid(ctx, blockHash)
+}
+
+// EvictBefore implements lib.Evicter
+func (s *Store) EvictBefore(ctx context.Context, timestamp uint64) {
+	s.call("EvictBefore")
+	s.Store.(lib.Evicter).EvictBefore(ctx, timestamp)
+}
+
+// SetBid implements lib.Store
+func (s *Store) SetBid(ctx context.Context, blockHash common.Hash, bid *lib.Bid) {
+	s.call("SetBid")
+	s.Store.SetBid(ctx, blockHash, bid)
+}
+
+// GetValidatorRegistration implements lib.Store
+func (s *Store) GetValidatorRegistration(ctx context.Context, pubkey string) *lib.SignedValidatorRegistrationV1 {
+	s.call("GetValidatorRegistration")
+	return s.Store.GetValidatorRegistration(ctx, pubkey)
+}
+
+// SetValidatorRegistration implements lib.Store
+func (s *Store) SetValidatorRegistration(ctx context.Context, registration *lib.SignedValidatorRegistrationV1) {
+	s.call("SetValidatorRegistration")
+	s.Store.SetValidatorRegistration(ctx, registration)
+}
@@ -0,0 +1,151 @@
+package lib
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/httptrace"
+	"strconv"
+	"sync"
+	"time"
+)
+
+var maxRelayTimings = 50000
+
+// RelayCallTiming are the timings of a relay call, in microseconds since it was sent. Later stages are 0 if the call
+// failed before them.
+type RelayCallTiming struct {
+	Slot        uint64    `json:"slot,string"`
+	RelayURL    string    `json:"relayUrl"`
+	Method      string    `json:"method"`
+	SentAt      time.Time `json:"sentAt"`
+	FirstByteUs int64     `json:"firstByteUs,omitempty"` // first byte of the response arrived
+	ParsedUs    int64     `json:"parsedUs,omitempty"`    // response fully read and decoded
+	ValidatedUs int64     `json:"validatedUs,omitempty"` // response checked, or rejected
+	Value       *big.Int  `json:"value,omitempty"`       // of the bid or payload the relay sent, if any
+	Error       string    `json:"error,omitempty"`
+	Traceparent string    `json:"traceparent,omitempty"` // span of the call in the trace of the consensus client, if any
+}
+
+// relayTimings keeps the timings of the most recent relay calls, and writes them as JSON lines to out, if set
+type relayTimings struct {
+	mu       sync.RWMutex
+	timings  []*RelayCallTiming
+	out      io.Writer
+	log      Logger
+	logCalls bool // log a line for each call, see WithRelayCallLog
+}
+
+func newRelayTimings(out io.Writer, log Logger) *relayTimings {
+	return &relayTimings{out: out, log: log}
+}
+
+// start begins timing a relay call. The returned context records the arrival of the first response byte, which happens
+// before the response is returned to the caller.
+func (t *relayTimings) start(ctx context.Context, relayURL, method string) (context.Context, *RelayCallTiming) {
+	if t == nil {
+		return ctx, nil
+	}
+	timing := &RelayCallTiming{RelayURL: relayURL, Method: method, SentAt: now()}
+	ctx = httptrace.WithClientTrace(ctx, &httptrace.ClientTrace{
+		GotFirstResponseByte: func() {
+			timing.FirstByteUs = timing.since()
+		},
+	})
+	return ctx, timing
+}
+
+func (timing *RelayCallTiming) since() int64 {
+	return now().Sub(timing.SentAt).Microseconds()
+}
+
+// parsed records that the response of a call was decoded
+func (timing *RelayCallTiming) parsed() {
+	if timing != nil {
+		timing.ParsedUs = timing.since()
+	}
+}
+
+// bid records the value of the bid or payload a relay sent
+func (timing *RelayCallTiming) bid(value *big.Int) {
+	if timing != nil {
+		timing.Value = value
+	}
+}
+
+// finish records that the response of a call was validated, or rejected with err, and adds the call to the timings
+func (t *relayTimings) finish(timing *RelayCallTiming, slot uint64, err error) {
+	if t == nil || timing == nil {
+		return
+	}
+	timing.Slot = slot
+	timing.ValidatedUs = timing.since()
+	if err != nil {
+		timing.Error = err.Error()
+	}
+	if t.logCalls {
+		t.logCall(timing, err)
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.timings = append(t.timings, timing)
+	if len(t.timings) > maxRelayTimings {
+		t.timings = t.timings[len(t.timings)-maxRelayTimings:]
+	}
+	if t.out != nil {
+		if err := json.NewEncoder(t.out).Encode(timing); err != nil {
+			t.log.WithError(err).Warn("could not write relay timings")
+		}
+	}
+}
+
+// all returns copies of the timings of slot, or of all slots if slot is nil, oldest first
+func (t *relayTimings) all(slot *uint64) []RelayCallTiming {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	timings := []RelayCallTiming{}
+	for _, timing := range t.timings {
+		if slot == nil || timing.Slot == *slot {
+			timings = append(timings, *timing)
+		}
+	}
+	return timings
+}
+
+func (m *RelayService) handleRelayTimings(w http.ResponseWriter, r *http.Request) {
+	var slot *uint64
+	if value := r.URL.Query().Get("slot"); value != "" {
+		parsed, err := strconv.ParseUint(value, 10, 64)
+		if err != nil {
+			respondJSON(w, http.StatusBadRequest, keymanagerError{"invalid slot " + strconv.Quote(value)})
+			return
+		}
+		slot = &parsed
+	}
+	respondJSON(w, http.StatusOK, m.timings.all(slot))
+}
+
+// logCall logs a finished relay call with the fields every relay interaction is logged with, so log pipelines can
+// follow the auction without parsing messages
+func (t *relayTimings) logCall(timing *RelayCallTiming, err error) {
+	var value interface{} // logged as null, not <nil>, by the JSON formatters
+	if timing.Value != nil {
+		value = timing.Value.String()
+	}
+	log := t.log.WithFields(Fields{
+		"slot":        timing.Slot,
+		"relay":       timing.RelayURL,
+		"method":      timing.Method,
+		"duration_ms": float64(timing.ValidatedUs) / 1000,
+		"value":       value,
+	})
+	if err != nil {
+		log.WithError(err).Info("relay call failed")
+		return
+	}
+	log.Info("relay call")
+}
@@ -0,0 +1,105 @@
+package lib
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRouter_RelayTimings(t *testing.T) {
+	header := ExecutionPayloadWithTxRootV1{
+		BlockHash:        common.HexToHash("0x01"),
+		Timestamp:        MainnetChainConfig.GenesisTime + 7*MainnetChainConfig.SecondsPerSlot,
+		BaseFeePerGas:    big.NewInt(1),
+		FeeRecipientDiff: big.NewInt(1),
+	}
+	relay := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(10 * time.Millisecond)
+		resp, err := formatResponse(header)
+		require.Nil(t, err)
+		w.Write(resp)
+	}))
+	defer relay.Close()
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		resp, err := formatErrorResponse("no bid")
+		require.Nil(t, err)
+		w.Write(resp)
+	}))
+	defer failing.Close()
+
+	store := NewStore()
+	store.SetForkchoiceResponse(context.Background(), "0x01", relay.URL, "0x01")
+	store.SetForkchoiceResponse(context.Background(), "0x01", failing.URL, "0x01")
+	out := new(bytes.Buffer)
+	router, err := NewRouter(context.Background(), WithRelayURLs(relay.URL, failing.URL), WithStore(store), WithLogger(testLog), WithCapabilityCheckInterval(0),
+		WithRelayTimings(out))
+	require.Nil(t, err)
+
+	body, err := formatRequestBody("builder_getPayloadHeaderV1", []interface{}{"0x01"})
+	require.Nil(t, err)
+	req := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(httptest.NewRecorder(), req)
+
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/mev-boost/v1/relays/timings?slot=7", nil))
+	require.Equal(t, http.StatusOK, rr.Code)
+	var timings []RelayCallTiming
+	require.Nil(t, json.Unmarshal(rr.Body.Bytes(), &timings))
+	require.Len(t, timings, 1, "the failed call has no slot")
+
+	timing := timings[0]
+	require.Equal(t, relay.URL, timing.RelayURL)
+	require.Equal(t, methodRelayGetHeader, timing.Method)
+	require.GreaterOrEqual(t, timing.FirstByteUs, int64(10000))
+	require.GreaterOrEqual(t, timing.ParsedUs, timing.FirstByteUs)
+	require.GreaterOrEqual(t, timing.ValidatedUs, timing.ParsedUs)
+	require.Empty(t, timing.Error)
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	require.Len(t, lines, 2)
+	require.Contains(t, out.String(), "(no bid)")
+}
+
+func TestRelayService_RelayCallLog(t *testing.T) {
+	header := ExecutionPayloadWithTxRootV1{
+		BlockHash:        common.HexToHash("0x01"),
+		Timestamp:        MainnetChainConfig.GenesisTime + 7*MainnetChainConfig.SecondsPerSlot,
+		BaseFeePerGas:    big.NewInt(1),
+		FeeRecipientDiff: big.NewInt(12345),
+	}
+	relay := newHeaderRelay(t, header)
+	defer relay.Close()
+
+	store := NewStore()
+	store.SetForkchoiceResponse(context.Background(), "0x01", relay.URL, "0x01")
+	out := new(bytes.Buffer)
+	service, err := newRelayService(WithRelayURLs(relay.URL), WithStore(store), WithLogger(NewJSONLogger(out)), WithRelayCallLog())
+	require.Nil(t, err)
+
+	payloadID := "0x01"
+	require.Nil(t, service.GetPayloadHeaderV1(nil, &payloadID, new(ExecutionPayloadWithTxRootV1)))
+	var logged map[string]interface{}
+	for _, line := range strings.Split(strings.TrimSpace(out.String()), "\n") {
+		var entry map[string]interface{}
+		require.Nil(t, json.Unmarshal([]byte(line), &entry))
+		if entry["msg"] == "relay call" {
+			logged = entry
+		}
+	}
+	require.NotNil(t, logged, "the header call is logged")
+	require.Equal(t, float64(7), logged["slot"])
+	require.Equal(t, relay.URL, logged["relay"])
+	require.Equal(t, methodRelayGetHeader, logged["method"])
+	require.Equal(t, "12345", logged["value"])
+	require.Contains(t, logged, "duration_ms")
+}
@@ -0,0 +1,119 @@
+package lib
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"strings"
+)
+
+// traceContext is the W3C trace context, https://www.w3.org/TR/trace-context/, of a request of the consensus client
+type traceContext struct {
+	traceID  string // 32 lowercase hex digits
+	parentID string // the span of the caller, or of the relay call in outgoing requests
+	flags    string
+	state    string // tracestate, passed on unchanged
+}
+
+type traceContextKey struct{}
+
+// traceContextFromContext returns the trace context of an incoming request, nil if it had none
+func traceContextFromContext(ctx context.Context) *traceContext {
+	trace, _ := ctx.Value(traceContextKey{}).(*traceContext)
+	return trace
+}
+
+// parseTraceparent parses a traceparent header. Versions above 00 are parsed as 00, as the spec requires, and ignored
+// along with tracestate if they're invalid.
+func parseTraceparent(value string) (*traceContext, bool) {
+	if len(value) < 55 || (len(value) > 55 && value[55] != '-') {
+		return nil, false
+	}
+	version, traceID, parentID, flags := value[0:2], value[3:35], value[36:52], value[53:55]
+	if value[2] != '-' || value[35] != '-' || value[52] != '-' || version == "ff" || (version == "00" && len(value) != 55) {
+		return nil, false
+	}
+	for _, field := range []string{version, traceID, parentID, flags} {
+		if !isLowerHex(field) {
+			return nil, false
+		}
+	}
+	if strings.Trim(traceID, "0") == "" || strings.Trim(parentID, "0") == "" {
+		return nil, false
+	}
+	return &traceContext{traceID: traceID, parentID: parentID, flags: flags}, true
+}
+
+func isLowerHex(s string) bool {
+	for _, c := range s {
+		if (c < '0' || c > '9') && (c < 'a' || c > 'f') {
+			return false
+		}
+	}
+	return true
+}
+
+// traceparent formats the trace context as traceparent header of version 00
+func (t *traceContext) traceparent() string {
+	return "00-" + t.traceID + "-" + t.parentID + "-" + t.flags
+}
+
+// traceContextMiddleware adds the trace context of requests with a valid traceparent header to their context
+func traceContextMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		trace, ok := parseTraceparent(r.Header.Get("traceparent"))
+		if !ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+		trace.state = strings.Join(r.Header.Values("tracestate"), ",")
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), traceContextKey{}, trace)))
+	})
+}
+
+// startRelaySpan starts a span of the trace of ctx for a relay call, so the spans of the relay become its children.
+// The traceparent of the span is recorded in timing. Contexts without a trace are returned unchanged.
+func startRelaySpan(ctx context.Context, timing *RelayCallTiming) context.Context {
+	parent := traceContextFromContext(ctx)
+	if parent == nil {
+		return ctx
+	}
+	spanID := make([]byte, 8)
+	if _, err := rand.Read(spanID); err != nil {
+		return ctx // the call goes on as part of the caller's span
+	}
+	span := *parent
+	span.parentID = hex.EncodeToString(spanID)
+	if timing != nil {
+		timing.Traceparent = span.traceparent()
+	}
+	return context.WithValue(ctx, traceContextKey{}, &span)
+}
+
+// setTraceHeaders passes the correlation id and trace context of ctx on to a relay
+func setTraceHeaders(ctx context.Context, req *http.Request) {
+	if id := requestIDFromContext(ctx); id != "" {
+		req.Header.Set(requestIDHeader, id)
+	}
+	trace := traceContextFromContext(ctx)
+	if trace == nil {
+		return
+	}
+	req.Header.Set("traceparent", trace.traceparent())
+	if trace.state != "" {
+		req.Header.Set("tracestate", trace.state)
+	}
+}
+
+// withTraceFields adds the correlation id and trace id of ctx to the fields of log, so the log lines of a request can
+// be found together and from a trace
+func withTraceFields(ctx context.Context, log Logger) Logger {
+	if id := requestIDFromContext(ctx); id != "" {
+		log = log.WithField("requestId", id)
+	}
+	if trace := traceContextFromContext(ctx); trace != nil {
+		return log.WithField("traceId", trace.traceID)
+	}
+	return log
+}
@@ -0,0 +1,89 @@
+package lib
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseTraceparent(t *testing.T) {
+	trace, ok := parseTraceparent("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	require.True(t, ok)
+	require.Equal(t, &traceContext{traceID: "4bf92f3577b34da6a3ce929d0e0e4736", parentID: "00f067aa0ba902b7", flags: "01"}, trace)
+	_, ok = parseTraceparent("cc-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-future")
+	require.True(t, ok, "later versions are parsed as version 00")
+
+	for _, invalid := range []string{
+		"",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra",
+		"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+		"00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01",
+		"00-00000000000000000000000000000000-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01",
+		"00_4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+	} {
+		_, ok := parseTraceparent(invalid)
+		require.False(t, ok, invalid)
+	}
+}
+
+func TestRouter_TraceContext(t *testing.T) {
+	header := ExecutionPayloadWithTxRootV1{
+		BlockHash:        common.HexToHash("0x01"),
+		Timestamp:        MainnetChainConfig.GenesisTime + 7*MainnetChainConfig.SecondsPerSlot,
+		BaseFeePerGas:    big.NewInt(1),
+		FeeRecipientDiff: big.NewInt(1),
+	}
+	var traceparent, tracestate string
+	relay := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		traceparent, tracestate = r.Header.Get("traceparent"), r.Header.Get("tracestate")
+		resp, err := formatResponse(header)
+		require.Nil(t, err)
+		w.Write(resp)
+	}))
+	defer relay.Close()
+
+	getHeader := func(opts ...Option) []RelayCallTiming {
+		store := NewStore()
+		store.SetForkchoiceResponse(context.Background(), "0x01", relay.URL, "0x01")
+		opts = append(opts, WithRelayURLs(relay.URL), WithStore(store), WithLogger(testLog), WithCapabilityCheckInterval(0), WithRelayTimings(nil))
+		router, err := NewRouter(context.Background(), opts...)
+		require.Nil(t, err)
+
+		body, err := formatRequestBody("builder_getPayloadHeaderV1", []interface{}{"0x01"})
+		require.Nil(t, err)
+		req := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+		req.Header.Add("tracestate", "rojo=00f067aa0ba902b7")
+		req.Header.Add("tracestate", "congo=t61rcWkgMzE")
+		router.ServeHTTP(httptest.NewRecorder(), req)
+
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/mev-boost/v1/relays/timings?slot=7", nil))
+		var timings []RelayCallTiming
+		require.Nil(t, json.Unmarshal(rr.Body.Bytes(), &timings))
+		return timings
+	}
+
+	timings := getHeader()
+	require.Len(t, timings, 1)
+	require.Empty(t, traceparent, "trace context is only passed on with WithTraceContext")
+	require.Empty(t, timings[0].Traceparent)
+
+	timings = getHeader(WithTraceContext())
+	require.True(t, strings.HasPrefix(traceparent, "00-4bf92f3577b34da6a3ce929d0e0e4736-"))
+	require.True(t, strings.HasSuffix(traceparent, "-01"))
+	require.NotContains(t, traceparent, "00f067aa0ba902b7", "the relay call is a span of its own")
+	require.Equal(t, "rojo=00f067aa0ba902b7,congo=t61rcWkgMzE", tracestate)
+	require.Len(t, timings, 1)
+	require.Equal(t, traceparent, timings[0].Traceparent)
+}
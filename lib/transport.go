@@ -0,0 +1,92 @@
+package lib
+
+import (
+	"context"
+	"crypto/tls"
+	"io"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// RelayTransportConfig tunes the connections to relays. http.DefaultTransport keeps only 2 idle connections per host,
+// so the concurrent calls at the start of a slot open new connections, each with a TCP and TLS handshake.
+type RelayTransportConfig struct {
+	// MaxIdleConnsPerHost is the number of idle connections kept open to each relay
+	MaxIdleConnsPerHost int
+	// IdleConnTimeout closes connections idle for longer, it should be longer than a slot
+	IdleConnTimeout time.Duration
+	// KeepAlive is the interval of TCP keep-alive probes on relay connections
+	KeepAlive time.Duration
+	// Prewarm connects to every relay endpoint when the router is created, so the first calls don't pay for the handshakes
+	Prewarm bool
+}
+
+// tunedRelayClient returns a copy of base whose transport is a clone of the one of base tuned by cfg, so proxies, TLS
+// settings and dialers of a WithHTTPClient transport are kept. Transports other than *http.Transport can't be tuned and
+// are used as they are.
+func tunedRelayClient(base *http.Client, cfg RelayTransportConfig) *http.Client {
+	client := *base
+	switch transport := base.Transport.(type) {
+	case nil:
+		client.Transport = NewRelayTransport(cfg)
+	case *http.Transport:
+		client.Transport = tuneRelayTransport(transport.Clone(), cfg)
+	}
+	return &client
+}
+
+// DefaultRelayTransportConfig is the transport of relay requests unless WithRelayTransport or WithHTTPClient is used
+var DefaultRelayTransportConfig = RelayTransportConfig{
+	MaxIdleConnsPerHost: 16,
+	IdleConnTimeout:     90 * time.Second,
+	KeepAlive:           30 * time.Second,
+}
+
+// NewRelayTransport returns a transport to relays with the connection pool of cfg, which resumes TLS sessions
+func NewRelayTransport(cfg RelayTransportConfig) *http.Transport {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DialContext = (&net.Dialer{Timeout: 30 * time.Second, KeepAlive: cfg.KeepAlive}).DialContext
+	return tuneRelayTransport(transport, cfg)
+}
+
+// tuneRelayTransport sets the connection pool of cfg on transport and resumes TLS sessions, keeping its TLS settings
+func tuneRelayTransport(transport *http.Transport, cfg RelayTransportConfig) *http.Transport {
+	transport.MaxIdleConns = 0 // no limit across relays
+	transport.MaxIdleConnsPerHost = cfg.MaxIdleConnsPerHost
+	transport.IdleConnTimeout = cfg.IdleConnTimeout
+	if transport.TLSClientConfig == nil {
+		transport.TLSClientConfig = &tls.Config{}
+	}
+	if transport.TLSClientConfig.ClientSessionCache == nil {
+		transport.TLSClientConfig.ClientSessionCache = tls.NewLRUClientSessionCache(0)
+	}
+	return transport
+}
+
+// prewarmRelayConnections opens a connection to each endpoint of the relays with a HEAD request, whose response is
+// discarded. The connections go back to the idle pool of client.
+func prewarmRelayConnections(ctx context.Context, client *http.Client, endpoints []string, log Logger) {
+	var wg sync.WaitGroup
+	for _, endpoint := range endpoints {
+		wg.Add(1)
+		go func(endpoint string) {
+			defer wg.Done()
+			start := time.Now()
+			req, err := http.NewRequestWithContext(ctx, http.MethodHead, endpoint, nil)
+			if err != nil {
+				return
+			}
+			resp, err := client.Do(req)
+			if err != nil {
+				log.WithFields(Fields{"url": hostOf(req), "error": err}).Warn("could not prewarm relay connection")
+				return
+			}
+			io.Copy(io.Discard, io.LimitReader(resp.Body, 4096))
+			resp.Body.Close()
+			log.WithFields(Fields{"url": hostOf(req), "latency": time.Since(start)}).Debug("prewarmed relay connection")
+		}(endpoint)
+	}
+	wg.Wait()
+}
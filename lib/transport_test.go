@@ -0,0 +1,155 @@
+package lib
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// newCountingTLSRelay serves headers over TLS and counts the connections clients open
+func newCountingTLSRelay(t testing.TB, delay time.Duration) (*httptest.Server, *int64) {
+	resp, err := formatResponse(ExecutionPayloadWithTxRootV1{BlockHash: nilHash})
+	require.Nil(t, err)
+	relay := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(delay)
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(resp)
+	}))
+	var conns int64
+	relay.Config.ConnState = func(_ net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			atomic.AddInt64(&conns, 1)
+		}
+	}
+	relay.StartTLS()
+	return relay, &conns
+}
+
+// relayTransportFor returns a relay transport trusting the certificate of relay
+func relayTransportFor(relay *httptest.Server, cfg RelayTransportConfig) *http.Transport {
+	transport := NewRelayTransport(cfg)
+	transport.TLSClientConfig.RootCAs = relay.Client().Transport.(*http.Transport).TLSClientConfig.RootCAs
+	return transport
+}
+
+func TestPrewarmRelayConnections(t *testing.T) {
+	relay, conns := newCountingTLSRelay(t, 0)
+	defer relay.Close()
+	client := &http.Client{Transport: relayTransportFor(relay, DefaultRelayTransportConfig)}
+
+	prewarmRelayConnections(context.Background(), client, []string{relay.URL}, testLog)
+	require.Equal(t, int64(1), atomic.LoadInt64(conns))
+
+	_, err := makeRequest(context.Background(), client, relay.URL, "builder_getPayloadHeaderV1", []interface{}{"0x01"}, responseLimit{size: maxRelayHeaderResponseSize})
+	require.Nil(t, err)
+	require.Equal(t, int64(1), atomic.LoadInt64(conns), "the call reuses the prewarmed connection")
+}
+
+func TestRelayTransport_ConcurrentCalls(t *testing.T) {
+	relay, conns := newCountingTLSRelay(t, 10*time.Millisecond)
+	defer relay.Close()
+	client := &http.Client{Transport: relayTransportFor(relay, DefaultRelayTransportConfig)}
+
+	call := func() {
+		var wg sync.WaitGroup
+		for i := 0; i < 8; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				_, err := makeRequest(context.Background(), client, relay.URL, "builder_getPayloadHeaderV1", []interface{}{"0x01"}, responseLimit{size: maxRelayHeaderResponseSize})
+				require.Nil(t, err)
+			}()
+		}
+		wg.Wait()
+	}
+	call()
+	opened := atomic.LoadInt64(conns)
+	call()
+	require.Equal(t, opened, atomic.LoadInt64(conns), "the connections of the first round are kept idle for the second")
+}
+
+// BenchmarkRelayTransport_GetHeader sends rounds of concurrent getHeader calls, like at the start of a slot, and reports
+// the 99th percentile latency and the connections opened with http.DefaultTransport settings and the relay transport
+func BenchmarkRelayTransport_GetHeader(b *testing.B) {
+	for _, bench := range []struct {
+		name      string
+		transport func(relay *httptest.Server) http.RoundTripper
+	}{
+		{"default", func(relay *httptest.Server) http.RoundTripper { return relay.Client().Transport }},
+		{"relay", func(relay *httptest.Server) http.RoundTripper {
+			return relayTransportFor(relay, DefaultRelayTransportConfig)
+		}},
+	} {
+		b.Run(bench.name, func(b *testing.B) {
+			relay, conns := newCountingTLSRelay(b, time.Millisecond)
+			defer relay.Close()
+			client := &http.Client{Transport: bench.transport(relay)}
+
+			var mu sync.Mutex
+			var latencies []time.Duration
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				var wg sync.WaitGroup
+				for j := 0; j < 8; j++ {
+					wg.Add(1)
+					go func() {
+						defer wg.Done()
+						start := time.Now()
+						if _, err := makeRequest(context.Background(), client, relay.URL, "builder_getPayloadHeaderV1", []interface{}{"0x01"}, responseLimit{size: maxRelayHeaderResponseSize}); err != nil {
+							b.Error(err)
+						}
+						mu.Lock()
+						latencies = append(latencies, time.Since(start))
+						mu.Unlock()
+					}()
+				}
+				wg.Wait()
+			}
+			b.StopTimer()
+
+			sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+			b.ReportMetric(float64(latencies[len(latencies)*99/100].Microseconds())/1000, "p99-ms")
+			b.ReportMetric(float64(atomic.LoadInt64(conns)), "conns")
+		})
+	}
+}
+
+func TestWithRelayTransport_HTTPClient(t *testing.T) {
+	cfg := RelayTransportConfig{MaxIdleConnsPerHost: 4, IdleConnTimeout: time.Minute}
+	a := newRouterConfig(context.Background(), WithRelayTransport(cfg))
+	require.Equal(t, 4, a.httpClient.Transport.(*http.Transport).MaxIdleConnsPerHost)
+	require.Equal(t, 16, httpClient.Transport.(*http.Transport).MaxIdleConnsPerHost, "the default client isn't changed")
+
+	proxy := func(*http.Request) (*url.URL, error) { return url.Parse("http://proxy.example") }
+	base := &http.Transport{Proxy: proxy, TLSClientConfig: &tls.Config{ServerName: "relay.example"}}
+	client := &http.Client{Transport: base}
+	b := newRouterConfig(context.Background(), WithHTTPClient(client), WithRelayTransport(cfg))
+	transport := b.httpClient.Transport.(*http.Transport)
+	require.NotSame(t, base, transport)
+	require.NotNil(t, transport.Proxy, "the settings of the WithHTTPClient transport are kept")
+	require.Equal(t, "relay.example", transport.TLSClientConfig.ServerName)
+	require.NotNil(t, transport.TLSClientConfig.ClientSessionCache)
+	require.Equal(t, time.Minute, transport.IdleConnTimeout)
+	require.Same(t, base, client.Transport, "the client of WithHTTPClient isn't changed")
+	require.Zero(t, base.MaxIdleConnsPerHost)
+	require.Nil(t, base.TLSClientConfig.ClientSessionCache)
+
+	custom := roundTripperFunc(func(*http.Request) (*http.Response, error) { return nil, nil })
+	c := newRouterConfig(context.Background(), WithHTTPClient(&http.Client{Transport: custom}), WithRelayTransport(cfg))
+	_, ok := c.httpClient.Transport.(roundTripperFunc)
+	require.True(t, ok, "transports other than *http.Transport are kept")
+}
+
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) { return f(req) }
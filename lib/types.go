@@ -1,7 +1,6 @@
 package lib
 
 import (
-	"encoding/json"
 	"math/big"
 
 	"github.com/ethereum/go-ethereum/common"
@@ -10,53 +9,69 @@ import (
 
 var nilHash = common.Hash{}
 
-// SignedBlindedBeaconBlock forked from https://github.com/ethereum/consensus-specs/blob/v1.1.6/specs/phase0/beacon-chain.md#signedbeaconblockheader
-type SignedBlindedBeaconBlock struct {
-	Message   *BlindedBeaconBlock `json:"message"`
-	Signature string              `json:"signature"`
-}
-
-// BlindedBeaconBlock forked from https://github.com/ethereum/consensus-specs/blob/v1.1.6/specs/phase0/beacon-chain.md#beaconblock
-type BlindedBeaconBlock struct {
-	Slot          string          `json:"slot"`
-	ProposerIndex string          `json:"proposer_index"`
-	ParentRoot    string          `json:"parent_root"`
-	StateRoot     string          `json:"state_root"`
-	Body          json.RawMessage `json:"body"`
-}
-
-// BlindedBeaconBlockBodyPartial a partial block body only containing a payload, in both snake_case and camelCase
-type BlindedBeaconBlockBodyPartial struct {
-	ExecutionPayload      ExecutionPayloadHeaderOnlyBlockHash `json:"execution_payload_header"`
-	ExecutionPayloadCamel ExecutionPayloadHeaderOnlyBlockHash `json:"executionPayloadHeader"`
-}
-
 //go:generate go run github.com/fjl/gencodec -type ExecutionPayloadWithTxRootV1 -field-override executionPayloadHeaderMarshaling -out gen_ed.go
 
-// ExecutionPayloadWithTxRootV1 is the same as ExecutionPayloadV1 with a transactionsRoot in addition to transactions
+// ExecutionPayloadWithTxRootV1 is the same as ExecutionPayloadV1 with a transactionsRoot in addition to transactions.
+// From capella on it has the withdrawals of ExecutionPayloadV2 and their withdrawalsRoot.
 type ExecutionPayloadWithTxRootV1 struct {
 	ParentHash       common.Hash    `json:"parentHash" gencodec:"required"`
 	FeeRecipient     common.Address `json:"feeRecipient" gencodec:"required"`
 	StateRoot        common.Hash    `json:"stateRoot" gencodec:"required"`
 	ReceiptsRoot     common.Hash    `json:"receiptsRoot" gencodec:"required"`
-	LogsBloom        []byte         `json:"logsBloom" gencodec:"required"`
+	LogsBloom        []byte         `json:"logsBloom" gencodec:"required" ssz-size:"256"`
 	PrevRandao       common.Hash    `json:"prevRandao" gencodec:"required"`
 	Number           uint64         `json:"blockNumber" gencodec:"required"`
 	GasLimit         uint64         `json:"gasLimit" gencodec:"required"`
 	GasUsed          uint64         `json:"gasUsed" gencodec:"required"`
 	Timestamp        uint64         `json:"timestamp" gencodec:"required"`
-	ExtraData        []byte         `json:"extraData" gencodec:"required"`
-	BaseFeePerGas    *big.Int       `json:"baseFeePerGas" gencodec:"required"`
+	ExtraData        []byte         `json:"extraData" gencodec:"required" ssz-max:"32"`
+	BaseFeePerGas    *big.Int       `json:"baseFeePerGas" gencodec:"required" ssz-size:"32"`
 	BlockHash        common.Hash    `json:"blockHash" gencodec:"required"`
-	Transactions     *[]string      `json:"transactions,omitempty"`
+	Transactions     *[]string      `json:"transactions,omitempty" ssz-max:"1048576,1073741824"`
 	TransactionsRoot common.Hash    `json:"transactionsRoot"`
 	FeeRecipientDiff *big.Int       `json:"feeRecipientDiff" gencodec:"required"`
+	// Withdrawals are the withdrawals of payloads of capella or later, and WithdrawalsRoot their hash tree root
+	Withdrawals     *[]*Withdrawal `json:"withdrawals,omitempty"`
+	WithdrawalsRoot *common.Hash   `json:"withdrawalsRoot,omitempty"`
+}
+
+// ForkchoiceStateV1 as defined in the engine spec
+type ForkchoiceStateV1 struct {
+	HeadBlockHash      common.Hash `json:"headBlockHash"`
+	SafeBlockHash      common.Hash `json:"safeBlockHash"`
+	FinalizedBlockHash common.Hash `json:"finalizedBlockHash"`
+}
+
+// PayloadAttributesV1 as defined in the engine spec, only the fields mev-boost needs to track a proposal
+type PayloadAttributesV1 struct {
+	Timestamp             hexutil.Uint64 `json:"timestamp"`
+	PrevRandao            common.Hash    `json:"prevRandao"`
+	SuggestedFeeRecipient common.Address `json:"suggestedFeeRecipient"`
+}
+
+// Bid is a payload header received from a relay, along with what was promised to the proposer
+type Bid struct {
+	RelayURL     string
+	FeeRecipient common.Address // fee recipient the proposer asked for in forkchoiceUpdated
+	Value        *big.Int       // FeeRecipientDiff promised by the relay
+	Signature    []byte         // bid signature of the relay, nil unless bid signatures are verified
+	// Local is set for payloads of local execution clients served because no relay had a valid bid. They have no relay
+	// url or fee recipient, and Value is the estimate of their priority fees.
+	Local bool
+}
+
+// ValidatorRegistrationV1 as defined in the builder spec: https://github.com/ethereum/builder-specs
+type ValidatorRegistrationV1 struct {
+	FeeRecipient common.Address `json:"fee_recipient"`
+	GasLimit     uint64         `json:"gas_limit,string"`
+	Timestamp    uint64         `json:"timestamp,string"`
+	Pubkey       hexutil.Bytes  `json:"pubkey"`
 }
 
-// ExecutionPayloadHeaderOnlyBlockHash an execution payload with only a block hash, used for BlindedBeaconBlockBodyPartial
-type ExecutionPayloadHeaderOnlyBlockHash struct {
-	BlockHash      string `json:"block_hash"`
-	BlockHashCamel string `json:"blockHash"`
+// SignedValidatorRegistrationV1 is a ValidatorRegistrationV1 signed with the builder domain
+type SignedValidatorRegistrationV1 struct {
+	Message   *ValidatorRegistrationV1 `json:"message"`
+	Signature hexutil.Bytes            `json:"signature"`
 }
 
 // JSON type overrides for executableData.
@@ -0,0 +1,216 @@
+package lib
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	// registrationMaxFutureTime is how far the timestamp of a registration may be ahead of the clock, as in the builder specs
+	registrationMaxFutureTime = 10 * time.Second
+	// registrationAttempts is how often registrations are sent to a relay before giving up
+	registrationAttempts = 3
+)
+
+// registrationRetryDelay is the wait before the first retry of a failed broadcast to a relay, it doubles with each retry
+var registrationRetryDelay = time.Second
+
+var validatorRegistrationsTotal = metricsFactory.NewCounterVec(prometheus.CounterOpts{
+	Name: "mevboost_validator_registrations_total",
+	Help: "Batches of validator registrations sent to relays, by relay and result: registered, retried or failed",
+}, []string{"relay", "result"})
+
+// RegisterValidatorV1 accepts the signed fee recipient and gas limit preferences of validators from the consensus client,
+// caches them in the store and broadcasts them to the relays, retrying relays that fail. Registrations that aren't newer
+// than the cached one of their validator are neither cached nor broadcast. Invalid registrations reject the whole batch.
+// With a signer, registrations differing from the fee recipient or gas limit preferences are signed anew with them.
+func (m *RelayService) RegisterValidatorV1(req *http.Request, args *[]SignedValidatorRegistrationV1, result *string) error {
+	logMethod := withTraceFields(requestContext(req), m.log.WithField("method", "builder_registerValidatorV1"))
+	ctx := requestContext(req)
+
+	if args == nil {
+		return newMethodError(errInvalidParams, "expected a list of signed validator registrations")
+	}
+	for i := range *args {
+		if err := m.verifyRegistration(&(*args)[i]); err != nil {
+			logMethod.WithFields(Fields{"index": i, "error": err}).Warn("RegisterValidatorV1: rejected invalid registration")
+			return err
+		}
+	}
+
+	var registrations []SignedValidatorRegistrationV1
+	for _, registration := range *args {
+		registration := registration
+		cached := m.store.GetValidatorRegistration(ctx, registration.Message.Pubkey.String())
+		if cached != nil && cached.Message.Timestamp >= registration.Message.Timestamp {
+			continue
+		}
+		registration = *m.withPreferences(ctx, &registration, logMethod)
+		m.store.SetValidatorRegistration(ctx, &registration)
+		m.proposers.register(registration.Message)
+		m.checkGasLimitPreference(ctx, registration.Message.Pubkey.String(), logMethod)
+		registrations = append(registrations, registration)
+	}
+	logMethod.WithFields(Fields{"received": len(*args), "new": len(registrations)}).Info("RegisterValidatorV1: registrations received")
+	if len(registrations) > 0 {
+		m.broadcastRegistrations(registrations, tenantFromContext(ctx), logMethod)
+	}
+
+	*result = "OK"
+	return nil
+}
+
+// withPreferences returns registration signed anew with the fee recipient and gas limit preferences of its validator,
+// so relays build toward the preferences. Without a signer, preferences differing from registration, or if the signer
+// fails, registration is returned as is. The new registration is timestamped after registration, so it replaces it at
+// the relays and in the store.
+func (m *RelayService) withPreferences(ctx context.Context, registration *SignedValidatorRegistrationV1, log Logger) *SignedValidatorRegistrationV1 {
+	if m.signer == nil || registration.Message == nil {
+		return registration
+	}
+	preferences := m.preferences.get(registration.Message.Pubkey.String())
+	if preferences == nil {
+		return registration
+	}
+
+	message := *registration.Message
+	if preferences.FeeRecipient != nil {
+		message.FeeRecipient = *preferences.FeeRecipient
+	}
+	if preferences.GasLimit != nil {
+		message.GasLimit = *preferences.GasLimit
+	}
+	if message.FeeRecipient == registration.Message.FeeRecipient && message.GasLimit == registration.Message.GasLimit {
+		return registration
+	}
+	message.Timestamp = uint64(now().Unix())
+	if message.Timestamp <= registration.Message.Timestamp {
+		message.Timestamp = registration.Message.Timestamp + 1
+	}
+
+	fields := Fields{"pubkey": message.Pubkey.String(), "feeRecipient": message.FeeRecipient.Hex(), "gasLimit": message.GasLimit}
+	signed, err := m.signer.SignValidatorRegistration(ctx, &message)
+	if err != nil {
+		log.WithFields(fields).WithError(err).Error("could not sign registration with the validator preferences, relays keep the registered ones")
+		return registration
+	}
+	log.WithFields(fields).Info("signed registration with the validator preferences")
+	return signed
+}
+
+// registerPreferences signs the cached registration of a validator anew with its changed preferences and broadcasts
+// it, see withPreferences
+func (m *RelayService) registerPreferences(ctx context.Context, pubkey string, log Logger) {
+	cached := m.store.GetValidatorRegistration(ctx, pubkey)
+	if cached == nil {
+		return
+	}
+	registration := m.withPreferences(ctx, cached, log)
+	if registration == cached {
+		return
+	}
+	m.store.SetValidatorRegistration(ctx, registration)
+	m.proposers.register(registration.Message)
+	m.broadcastRegistrations([]SignedValidatorRegistrationV1{*registration}, nil, log)
+}
+
+// checkGasLimitPreference warns if the gas limit set with the validator preferences API differs from the signed
+// registration of the validator. Relays only learn the registered gas limit, which can't change without a new signature
+// of the validator, so they build toward it while headers are checked against the preference.
+func (m *RelayService) checkGasLimitPreference(ctx context.Context, pubkey string, log Logger) {
+	preferences := m.preferences.get(pubkey)
+	if preferences == nil || preferences.GasLimit == nil {
+		return
+	}
+	registration := m.store.GetValidatorRegistration(ctx, pubkey)
+	if registration == nil || registration.Message == nil || registration.Message.GasLimit == *preferences.GasLimit {
+		return
+	}
+	log.WithFields(Fields{"pubkey": pubkey, "registered": registration.Message.GasLimit, "preferred": *preferences.GasLimit}).Warn("gas limit preference differs from the registration sent to relays, update the gas limit of the validator client")
+}
+
+// verifyRegistration checks a registration is signed by its validator in the builder domain, and its timestamp isn't
+// in the future
+func (m *RelayService) verifyRegistration(registration *SignedValidatorRegistrationV1) error {
+	if registration.Message == nil {
+		return newMethodError(errInvalidParams, "registration has no message")
+	}
+	pubkey := registration.Message.Pubkey
+	if err := ValidatePubkey(pubkey); err != nil {
+		return fmt.Errorf("invalid pubkey %s: %w", pubkey, err)
+	}
+	if time.Unix(int64(registration.Message.Timestamp), 0).After(now().Add(registrationMaxFutureTime)) {
+		return fmt.Errorf("registration of %s has a timestamp in the future", pubkey)
+	}
+	root, err := registration.Message.HashTreeRoot()
+	if err != nil {
+		return err
+	}
+	ok, err := VerifySignature(pubkey, ComputeSigningRoot(root, m.chain.BuilderDomain()), registration.Signature)
+	if err != nil || !ok {
+		return newMethodError(ErrInvalidSignature, "invalid signature of the registration of %s", pubkey)
+	}
+	return nil
+}
+
+// broadcastRegistrations sends registrations to the relays that support them, each registration only to the relays its
+// validator may use. Relays aren't needed until the next proposal, so it doesn't block the consensus client, and failed
+// relays are retried with a growing delay.
+func (m *RelayService) broadcastRegistrations(registrations []SignedValidatorRegistrationV1, tenant *Tenant, logMethod Logger) {
+	byRelay := make(map[string][]SignedValidatorRegistrationV1)
+	for _, url := range m.relays.all() {
+		if !m.capabilities.supports(url, methodRelayRegisterValidator) || !tenant.usesRelay(url) || !m.relays.enabled(url) {
+			continue
+		}
+		for _, registration := range registrations {
+			if m.allowsRelay(registration.Message.Pubkey.String(), url) {
+				byRelay[url] = append(byRelay[url], registration)
+			}
+		}
+	}
+
+	go func() {
+		var wg sync.WaitGroup
+		for url, registrations := range byRelay {
+			wg.Add(1)
+			go func(url string, registrations []SignedValidatorRegistrationV1) {
+				defer wg.Done()
+				m.registerWithRelay(url, registrations, logMethod.WithField("url", url))
+			}(url, registrations)
+		}
+		wg.Wait()
+	}()
+}
+
+// registerWithRelay sends registrations to a relay, up to registrationAttempts times
+func (m *RelayService) registerWithRelay(url string, registrations []SignedValidatorRegistrationV1, log Logger) {
+	delay := registrationRetryDelay
+	for attempt := 1; ; attempt++ {
+		ctx, cancel := context.WithTimeout(context.Background(), m.chain.SlotDuration())
+		res, timing, err := m.requestRelay(ctx, url, methodRelayRegisterValidator, []interface{}{registrations})
+		cancel()
+		m.timings.finish(timing, m.chain.SlotAt(uint64(now().Unix())), err)
+		if err == nil && res.Error != nil {
+			err = res.Error
+		}
+		if err == nil {
+			validatorRegistrationsTotal.WithLabelValues(url, "registered").Inc()
+			log.WithField("count", len(registrations)).Debug("sent validator registrations to the relay")
+			return
+		}
+		if attempt == registrationAttempts {
+			validatorRegistrationsTotal.WithLabelValues(url, "failed").Inc()
+			log.WithFields(Fields{"error": err, "attempts": attempt}).Error("could not send validator registrations to the relay")
+			return
+		}
+		validatorRegistrationsTotal.WithLabelValues(url, "retried").Inc()
+		log.WithFields(Fields{"error": err, "retryIn": delay}).Warn("could not send validator registrations to the relay, retrying")
+		time.Sleep(delay)
+		delay *= 2
+	}
+}
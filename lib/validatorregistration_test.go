@@ -0,0 +1,138 @@
+package lib
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/require"
+)
+
+// testRegistration returns a registration of the validator of secretKey, signed in the mainnet builder domain
+func testRegistration(t *testing.T, secretKey int64, timestamp uint64) SignedValidatorRegistrationV1 {
+	pubkey, _ := blsSign(big.NewInt(secretKey), [32]byte{})
+	message := &ValidatorRegistrationV1{FeeRecipient: common.HexToAddress("0x01"), GasLimit: 30_000_000, Timestamp: timestamp, Pubkey: pubkey}
+	root, err := message.HashTreeRoot()
+	require.Nil(t, err)
+	_, signature := blsSign(big.NewInt(secretKey), ComputeSigningRoot(root, MainnetChainConfig.BuilderDomain()))
+	return SignedValidatorRegistrationV1{Message: message, Signature: signature}
+}
+
+// newRegistrationRelay fails the first failures registration calls, and sends the registrations of the others on received
+func newRegistrationRelay(t *testing.T, failures int32, received chan<- []SignedValidatorRegistrationV1) *httptest.Server {
+	var calls int32
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Method string                            `json:"method"`
+			Params [][]SignedValidatorRegistrationV1 `json:"params"`
+		}
+		require.Nil(t, json.NewDecoder(r.Body).Decode(&req))
+		require.Equal(t, methodRelayRegisterValidator, req.Method)
+		if atomic.AddInt32(&calls, 1) <= failures {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		resp, err := formatResponse("OK")
+		require.Nil(t, err)
+		w.Write(resp)
+		received <- req.Params[0]
+	}))
+}
+
+func TestRegisterValidatorV1(t *testing.T) {
+	defer func(delay time.Duration) { registrationRetryDelay = delay }(registrationRetryDelay)
+	registrationRetryDelay = time.Millisecond
+
+	received := make(chan []SignedValidatorRegistrationV1, 4)
+	relay := newRegistrationRelay(t, 0, received)
+	defer relay.Close()
+	flaky := newRegistrationRelay(t, registrationAttempts-1, received)
+	defer flaky.Close()
+	store := NewStore()
+	router, err := NewRouter(context.Background(), WithRelayURLs(relay.URL, flaky.URL), WithStore(store), WithLogger(testLog))
+	require.Nil(t, err)
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	timestamp := uint64(time.Now().Unix())
+	registration := testRegistration(t, 1, timestamp)
+	body, err := json.Marshal(map[string]interface{}{
+		"jsonrpc": "2.0", "id": "1", "method": "builder_registerValidatorV1",
+		"params": []interface{}{[]SignedValidatorRegistrationV1{registration}},
+	})
+	require.Nil(t, err)
+	resp, err := http.Post(server.URL, "application/json", bytes.NewReader(body))
+	require.Nil(t, err)
+	defer resp.Body.Close()
+	var res rpcResponse
+	require.Nil(t, json.NewDecoder(resp.Body).Decode(&res))
+	require.Nil(t, res.Error)
+	require.Equal(t, `"OK"`, string(res.Result))
+
+	for i := 0; i < 2; i++ {
+		select {
+		case registrations := <-received:
+			require.Equal(t, []SignedValidatorRegistrationV1{registration}, registrations, "the flaky relay gets them once it answers")
+		case <-time.After(5 * time.Second):
+			t.Fatal("registrations weren't broadcast to both relays")
+		}
+	}
+	require.Equal(t, &registration, store.GetValidatorRegistration(context.Background(), registration.Message.Pubkey.String()))
+}
+
+func TestRelayService_RegisterValidatorV1(t *testing.T) {
+	received := make(chan []SignedValidatorRegistrationV1, 4)
+	relay := newRegistrationRelay(t, 0, received)
+	defer relay.Close()
+	service, err := newRelayService(WithRelayURLs(relay.URL), WithLogger(testLog))
+	require.Nil(t, err)
+
+	timestamp := uint64(time.Now().Unix())
+	first, second := testRegistration(t, 1, timestamp), testRegistration(t, 2, timestamp)
+	var result string
+	require.Nil(t, service.RegisterValidatorV1(nil, &[]SignedValidatorRegistrationV1{first}, &result))
+	require.Equal(t, []SignedValidatorRegistrationV1{first}, <-received)
+
+	// registrations that aren't newer than the cached ones aren't broadcast again
+	stale := testRegistration(t, 1, timestamp-1)
+	require.Nil(t, service.RegisterValidatorV1(nil, &[]SignedValidatorRegistrationV1{stale, first, second}, &result))
+	require.Equal(t, []SignedValidatorRegistrationV1{second}, <-received)
+	require.Equal(t, &first, service.store.GetValidatorRegistration(context.Background(), first.Message.Pubkey.String()))
+
+	// invalid registrations reject the batch
+	forged := testRegistration(t, 1, timestamp+1)
+	forged.Message.GasLimit++
+	err = service.RegisterValidatorV1(nil, &[]SignedValidatorRegistrationV1{testRegistration(t, 3, timestamp), forged}, &result)
+	require.True(t, errors.Is(err, ErrInvalidSignature), err)
+	future := testRegistration(t, 1, timestamp+60)
+	require.Error(t, service.RegisterValidatorV1(nil, &[]SignedValidatorRegistrationV1{future}, &result))
+	require.Error(t, service.RegisterValidatorV1(nil, &[]SignedValidatorRegistrationV1{{}}, &result))
+	select {
+	case registrations := <-received:
+		t.Fatalf("rejected registrations were broadcast: %v", registrations)
+	case <-time.After(50 * time.Millisecond):
+	}
+	require.Nil(t, service.store.GetValidatorRegistration(context.Background(), testRegistration(t, 3, timestamp).Message.Pubkey.String()))
+}
+
+func TestLevelDBStore_ValidatorRegistration(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	s, err := NewLevelDBStore(ctx, t.TempDir(), time.Minute, testLog)
+	require.Nil(t, err)
+	defer s.Close()
+
+	registration := testRegistration(t, 1, 1)
+	require.Nil(t, s.GetValidatorRegistration(ctx, registration.Message.Pubkey.String()))
+	s.SetValidatorRegistration(ctx, &registration)
+	s.EvictBefore(ctx, uint64(time.Now().Unix()))
+	require.Equal(t, &registration, s.GetValidatorRegistration(ctx, registration.Message.Pubkey.String()))
+}
@@ -0,0 +1,168 @@
+package lib
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+// ValidatorRelays are the relays queried for the proposals of each validator
+type ValidatorRelays struct {
+	// Default are the relays of proposers without an entry or that aren't known, all relays if empty
+	Default []string `json:"default,omitempty"`
+	// Validators are the relays of the proposals of a validator, by pubkey. Relays are configured relays, by url or by
+	// host, as in relay groups.
+	Validators map[string][]string `json:"validators"`
+}
+
+// LoadValidatorRelays reads the relays per validator from a JSON file at path
+func LoadValidatorRelays(path string) (*ValidatorRelays, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	relays := new(ValidatorRelays)
+	if err := json.Unmarshal(data, relays); err != nil {
+		return nil, fmt.Errorf("could not parse validator relays: %w", err)
+	}
+	return relays, nil
+}
+
+// validatorRelays selects the relays of a proposal by the pubkey of its proposer
+type validatorRelays struct {
+	defaults   map[string]bool            // nil if proposers without an entry use all relays
+	validators map[string]map[string]bool // map[lowercase pubkey]map[relay url]
+}
+
+func newValidatorRelays(config *ValidatorRelays, relayURLs []string) (*validatorRelays, error) {
+	resolve := func(entries []string) (map[string]bool, error) {
+		relays := make(map[string]bool, len(entries))
+		for _, entry := range entries {
+			relayURL, err := resolveRelay(entry, relayURLs)
+			if err != nil {
+				return nil, err
+			}
+			relays[relayURL] = true
+		}
+		return relays, nil
+	}
+
+	r := &validatorRelays{validators: make(map[string]map[string]bool, len(config.Validators))}
+	if len(config.Default) > 0 {
+		var err error
+		if r.defaults, err = resolve(config.Default); err != nil {
+			return nil, fmt.Errorf("default relays: %w", err)
+		}
+	}
+	for pubkey, entries := range config.Validators {
+		if b, err := hexutil.Decode(pubkey); err != nil || len(b) != 48 {
+			return nil, fmt.Errorf("invalid validator pubkey %s", pubkey)
+		}
+		if len(entries) == 0 {
+			return nil, fmt.Errorf("validator %s: no relays", pubkey)
+		}
+		relays, err := resolve(entries)
+		if err != nil {
+			return nil, fmt.Errorf("validator %s: %w", pubkey, err)
+		}
+		r.validators[strings.ToLower(pubkey)] = relays
+	}
+	return r, nil
+}
+
+// usesRelay reports whether proposals of pubkey use the relay at url, all relays are used without validator relays
+func (r *validatorRelays) usesRelay(pubkey, url string) bool {
+	if r == nil {
+		return true
+	}
+	if relays, ok := r.validators[pubkey]; ok {
+		return relays[url]
+	}
+	return r.defaults == nil || r.defaults[url]
+}
+
+// registeredProposers maps fee recipients to the validators that registered them, to tell which validator a
+// forkchoiceUpdated call proposes for when the beacon node isn't asked
+type registeredProposers struct {
+	mu            sync.RWMutex
+	pubkeys       map[common.Address]map[string]bool // map[fee recipient]lowercase pubkeys
+	feeRecipients map[string]common.Address          // map[lowercase pubkey]fee recipient
+}
+
+func newRegisteredProposers() *registeredProposers {
+	return &registeredProposers{pubkeys: make(map[common.Address]map[string]bool), feeRecipients: make(map[string]common.Address)}
+}
+
+// register records the fee recipient of a validator, replacing its previous one
+func (p *registeredProposers) register(registration *ValidatorRegistrationV1) {
+	pubkey := registration.Pubkey.String()
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if previous, ok := p.feeRecipients[pubkey]; ok {
+		delete(p.pubkeys[previous], pubkey)
+		if len(p.pubkeys[previous]) == 0 {
+			delete(p.pubkeys, previous)
+		}
+	}
+	if p.pubkeys[registration.FeeRecipient] == nil {
+		p.pubkeys[registration.FeeRecipient] = make(map[string]bool)
+	}
+	p.pubkeys[registration.FeeRecipient][pubkey] = true
+	p.feeRecipients[pubkey] = registration.FeeRecipient
+}
+
+// only returns the validator registered with feeRecipient, or an empty string if none or several are
+func (p *registeredProposers) only(feeRecipient common.Address) string {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	if len(p.pubkeys[feeRecipient]) != 1 {
+		return ""
+	}
+	for pubkey := range p.pubkeys[feeRecipient] {
+		return pubkey
+	}
+	return ""
+}
+
+// restrictsRelays reports whether the relays of a proposal depend on its proposer
+func (m *RelayService) restrictsRelays() bool {
+	return m.validatorRelays != nil || m.preferences.hasRelayAllowlist()
+}
+
+// proposerOf returns the lowercase pubkey of the proposer of slot with feeRecipient: the proposer of the duties of the
+// beacon node if the chain state is checked, otherwise the only validator registered with the fee recipient. It's empty
+// if the proposer isn't known.
+func (m *RelayService) proposerOf(ctx context.Context, slot uint64, feeRecipient common.Address, logMethod Logger) string {
+	if m.chainChecks != nil {
+		pubkey, err := m.chainChecks.proposer(ctx, slot)
+		if err == nil {
+			return pubkey
+		}
+		logMethod.WithFields(Fields{"slot": slot, "error": err}).Warn("could not look up the proposer on the beacon node")
+	}
+	return m.proposers.only(feeRecipient)
+}
+
+// allowsRelay reports whether proposals of pubkey may use the relay at url: by the relay allowlist of the validator
+// preferences if it has one, and the validator relays otherwise
+func (m *RelayService) allowsRelay(pubkey, url string) bool {
+	if pubkey != "" {
+		if preferences := m.preferences.get(pubkey); preferences != nil && len(preferences.RelayAllowlist) > 0 {
+			for _, entry := range preferences.RelayAllowlist {
+				if relayURL, err := resolveRelay(entry, m.relays.all()); err == nil && relayURL == url {
+					return true
+				}
+			}
+			return false
+		}
+	}
+	return m.validatorRelays.usesRelay(pubkey, url)
+}
@@ -0,0 +1,122 @@
+package lib
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewValidatorRelays(t *testing.T) {
+	relayURLs := []string{"https://0xabc@relay-a.example.com", "https://relay-b.example.com"}
+	pubkey := hexutil.Encode(make([]byte, 48))
+	tests := []struct {
+		name   string
+		config ValidatorRelays
+		err    string
+	}{
+		{"by url and host", ValidatorRelays{Default: []string{"relay-a.example.com"}, Validators: map[string][]string{pubkey: {"https://relay-b.example.com"}}}, ""},
+		{"unknown default relay", ValidatorRelays{Default: []string{"relay-x.example.com"}}, "default relays: relay relay-x.example.com is not configured"},
+		{"unknown relay", ValidatorRelays{Validators: map[string][]string{pubkey: {"relay-x.example.com"}}}, "not configured"},
+		{"no relays", ValidatorRelays{Validators: map[string][]string{pubkey: {}}}, "no relays"},
+		{"invalid pubkey", ValidatorRelays{Validators: map[string][]string{"0x01": {"relay-a.example.com"}}}, "invalid validator pubkey"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := newValidatorRelays(&tt.config, relayURLs)
+			if tt.err == "" {
+				require.Nil(t, err)
+			} else {
+				require.Error(t, err)
+				require.Contains(t, err.Error(), tt.err)
+			}
+		})
+	}
+
+	mixedCase := hexutil.Encode(bytes.Repeat([]byte{0xab}, 48))
+	relays, err := newValidatorRelays(&ValidatorRelays{Validators: map[string][]string{"0x" + strings.ToUpper(mixedCase[2:]): {"relay-b.example.com"}}}, relayURLs)
+	require.Nil(t, err)
+	require.False(t, relays.usesRelay(mixedCase, relayURLs[0]))
+	require.True(t, relays.usesRelay(mixedCase, relayURLs[1]), "pubkeys are matched in lowercase")
+	require.True(t, relays.usesRelay("", relayURLs[0]), "unknown proposers use all relays without a default")
+}
+
+func TestRegisteredProposers(t *testing.T) {
+	proposers := newRegisteredProposers()
+	first, second := common.HexToAddress("0x01"), common.HexToAddress("0x02")
+	a, b := hexutil.Bytes(make([]byte, 48)), hexutil.Bytes(append(make([]byte, 47), 1))
+	require.Equal(t, "", proposers.only(first))
+
+	proposers.register(&ValidatorRegistrationV1{FeeRecipient: first, Pubkey: a})
+	require.Equal(t, a.String(), proposers.only(first))
+	proposers.register(&ValidatorRegistrationV1{FeeRecipient: first, Pubkey: b})
+	require.Equal(t, "", proposers.only(first), "the proposer of a shared fee recipient isn't known")
+	proposers.register(&ValidatorRegistrationV1{FeeRecipient: second, Pubkey: b})
+	require.Equal(t, a.String(), proposers.only(first))
+	require.Equal(t, b.String(), proposers.only(second))
+}
+
+func TestRelayService_ValidatorRelays(t *testing.T) {
+	var mu sync.Mutex
+	calls := make(map[string][]string) // methods by relay
+	newRelay := func(name string) *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			var req rpcRequest
+			require.Nil(t, json.NewDecoder(r.Body).Decode(&req))
+			mu.Lock()
+			calls[name] = append(calls[name], req.Method)
+			mu.Unlock()
+			resp, err := formatResponse(ForkChoiceResponse{PayloadStatus: PayloadStatus{Status: ForkchoiceStatusValid}, PayloadID: &hexutil.Bytes{1}})
+			require.Nil(t, err)
+			w.Write(resp)
+		}))
+	}
+	relayA, relayB := newRelay("a"), newRelay("b")
+	defer relayA.Close()
+	defer relayB.Close()
+	called := func() map[string][]string {
+		mu.Lock()
+		defer mu.Unlock()
+		c := calls
+		calls = make(map[string][]string)
+		return c
+	}
+
+	registration := testRegistration(t, 1, uint64(time.Now().Unix()))
+	pubkey := registration.Message.Pubkey.String()
+	service, err := newRelayService(WithRelayURLs(relayA.URL, relayB.URL), WithStore(NewStore()), WithLogger(testLog),
+		WithValidatorRelays(&ValidatorRelays{Default: []string{relayA.URL}, Validators: map[string][]string{pubkey: {relayB.URL}}}))
+	require.Nil(t, err)
+
+	var status string
+	require.Nil(t, service.RegisterValidatorV1(nil, &[]SignedValidatorRegistrationV1{registration}, &status))
+	require.Eventually(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(calls["b"]) == 1
+	}, time.Second, 10*time.Millisecond)
+	require.Equal(t, map[string][]string{"b": {methodRelayRegisterValidator}}, called(), "registrations only go to the relays of the validator")
+
+	forkchoiceUpdated := func(feeRecipient string) map[string][]string {
+		args := []interface{}{
+			map[string]interface{}{"headBlockHash": common.HexToHash("0x01").Hex()},
+			map[string]interface{}{"timestamp": "0x10", "suggestedFeeRecipient": feeRecipient},
+		}
+		require.Nil(t, service.ForkchoiceUpdatedV1(nil, &args, new(ForkChoiceResponse)))
+		return called()
+	}
+	require.Equal(t, map[string][]string{"b": {methodForkchoiceUpdated}}, forkchoiceUpdated(registration.Message.FeeRecipient.String()))
+	require.Equal(t, map[string][]string{"a": {methodForkchoiceUpdated}}, forkchoiceUpdated("0x0000000000000000000000000000000000000002"), "unknown proposers use the default")
+
+	// the relay allowlist of the validator preferences takes precedence
+	service.preferences.update(pubkey, func(p *ValidatorPreferences) { p.RelayAllowlist = []string{relayA.URL} })
+	require.Equal(t, map[string][]string{"a": {methodForkchoiceUpdated}}, forkchoiceUpdated(registration.Message.FeeRecipient.String()))
+}
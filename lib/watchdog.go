@@ -0,0 +1,84 @@
+package lib
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	backgroundLoopFailuresTotal = metricsFactory.NewCounterVec(prometheus.CounterOpts{
+		Name: "mevboost_background_loop_failures_total",
+		Help: "Runs of background loops that panicked, stalled or were skipped because a stalled run was still going, by loop and reason",
+	}, []string{"loop", "reason"})
+	backgroundLoopLastRun = metricsFactory.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "mevboost_background_loop_last_run_timestamp_seconds",
+		Help: "Unix time at which a background loop last completed a run",
+	}, []string{"loop"})
+)
+
+// runLoop calls run every interval until ctx is done, immediately first if immediate is set. The loop is watched:
+// a panicking run is logged and the loop carries on, and a run that doesn't return within the interval is abandoned
+// with its context cancelled, so a single stuck or crashed run doesn't silently stop the loop. An abandoned run that
+// ignores its context keeps going, and the loop skips its intervals until it returned, so runs never overlap.
+func runLoop(ctx context.Context, log Logger, name string, interval time.Duration, immediate bool, run func(ctx context.Context)) {
+	log = log.WithField("loop", name)
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		var running int32 // 1 while a run, possibly an abandoned one, hasn't returned
+		if immediate {
+			runWatched(ctx, log, name, interval, &running, run)
+		}
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if atomic.LoadInt32(&running) == 1 {
+					backgroundLoopFailuresTotal.WithLabelValues(name, "skipped").Inc()
+					log.Warn("stalled background loop run is still going, skipping this interval")
+					continue
+				}
+				runWatched(ctx, log, name, interval, &running, run)
+			}
+		}
+	}()
+}
+
+// runWatched calls run once, and returns when it's done, panicked or exceeded timeout. running is 1 until run returned.
+func runWatched(ctx context.Context, log Logger, name string, timeout time.Duration, running *int32, run func(ctx context.Context)) {
+	runCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	atomic.StoreInt32(running, 1)
+	done := make(chan bool, 1) // true if run panicked
+	go func() {
+		defer atomic.StoreInt32(running, 0)
+		defer func() {
+			if err := recover(); err != nil {
+				log.WithField("panic", err).Error("background loop panicked, it will run again at the next interval")
+				done <- true
+			}
+		}()
+		run(runCtx)
+		done <- false
+	}()
+
+	select {
+	case panicked := <-done:
+		if panicked {
+			backgroundLoopFailuresTotal.WithLabelValues(name, "panic").Inc()
+			return
+		}
+		backgroundLoopLastRun.WithLabelValues(name).Set(float64(now().Unix()))
+	case <-runCtx.Done():
+		if ctx.Err() != nil {
+			return
+		}
+		backgroundLoopFailuresTotal.WithLabelValues(name, "stall").Inc()
+		log.WithField("timeout", timeout).Error("background loop stalled, abandoning this run and restarting at the next interval")
+	}
+}
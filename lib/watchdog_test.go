@@ -0,0 +1,73 @@
+package lib
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunLoop_SurvivesPanicsAndStalls(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	panics := backgroundLoopFailuresTotal.WithLabelValues("test_watchdog", "panic")
+	stalls := backgroundLoopFailuresTotal.WithLabelValues("test_watchdog", "stall")
+	panicsBefore, stallsBefore := testutil.ToFloat64(panics), testutil.ToFloat64(stalls)
+
+	var runs int32
+	runLoop(ctx, testLog, "test_watchdog", 20*time.Millisecond, true, func(runCtx context.Context) {
+		switch atomic.AddInt32(&runs, 1) {
+		case 1:
+			panic("boom")
+		case 2:
+			time.Sleep(time.Second) // ignores its context and stalls
+		}
+	})
+
+	require.Eventually(t, func() bool { return atomic.LoadInt32(&runs) >= 4 }, 2*time.Second, 5*time.Millisecond)
+	require.Equal(t, panicsBefore+1, testutil.ToFloat64(panics))
+	require.Equal(t, stallsBefore+1, testutil.ToFloat64(stalls))
+}
+
+func TestRunLoop_StopsWithContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	var runs int32
+	runLoop(ctx, testLog, "test_stop", 10*time.Millisecond, false, func(context.Context) {
+		atomic.AddInt32(&runs, 1)
+	})
+	require.Eventually(t, func() bool { return atomic.LoadInt32(&runs) >= 1 }, time.Second, 5*time.Millisecond)
+
+	cancel()
+	time.Sleep(20 * time.Millisecond)
+	stopped := atomic.LoadInt32(&runs)
+	time.Sleep(50 * time.Millisecond)
+	require.Equal(t, stopped, atomic.LoadInt32(&runs))
+}
+
+func TestRunLoop_SkipsWhileStalledRunIsGoing(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	skipped := backgroundLoopFailuresTotal.WithLabelValues("test_overlap", "skipped")
+	skippedBefore := testutil.ToFloat64(skipped)
+
+	var runs, concurrent, overlaps int32
+	runLoop(ctx, testLog, "test_overlap", 10*time.Millisecond, true, func(context.Context) {
+		if atomic.AddInt32(&concurrent, 1) > 1 {
+			atomic.AddInt32(&overlaps, 1)
+		}
+		defer atomic.AddInt32(&concurrent, -1)
+		if atomic.AddInt32(&runs, 1) == 1 {
+			time.Sleep(100 * time.Millisecond) // ignores its context and stalls for several intervals
+		}
+	})
+
+	require.Eventually(t, func() bool { return atomic.LoadInt32(&runs) >= 3 }, 2*time.Second, 5*time.Millisecond)
+	require.Equal(t, int32(0), atomic.LoadInt32(&overlaps))
+	require.Less(t, skippedBefore, testutil.ToFloat64(skipped))
+}
@@ -0,0 +1,52 @@
+package lib
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// webSocketHeaders are the headers of a WebSocket upgrade that aren't passed on to the calls of the connection
+var webSocketHeaders = []string{"Connection", "Upgrade", "Sec-Websocket-Key", "Sec-Websocket-Version", "Sec-Websocket-Extensions", "Sec-Websocket-Protocol"}
+
+// isSubscriptionMethod reports whether method is served by the WebSocket connection itself rather than the router
+func (m *RelayService) isSubscriptionMethod(method string) bool {
+	return m.pushInterval > 0 && (method == methodSubscribe || method == methodUnsubscribe)
+}
+
+// webSocketRPCRequest returns the request the JSON-RPC calls of a WebSocket connection are served in: a POST to / with
+// the headers of the upgrade request, e.g. its User-Agent and Authorization, in ctx of the connection
+func webSocketRPCRequest(ctx context.Context, upgrade *http.Request) *http.Request {
+	r := upgrade.Clone(ctx)
+	r.Method = http.MethodPost
+	r.Body = http.NoBody
+	for _, header := range webSocketHeaders {
+		r.Header.Del(header)
+	}
+	r.Header.Set("Content-Type", "application/json")
+	return r
+}
+
+// keepWebSocketAlive pings ws at half the read timeout until ctx is done, and extends the read deadline with each pong,
+// so connections of consensus clients that are idle between proposals stay open as long as the client answers
+func (m *RelayService) keepWebSocketAlive(ctx context.Context, ws *websocket.Conn) {
+	ws.SetPongHandler(func(string) error {
+		return ws.SetReadDeadline(time.Now().Add(m.webSocketReadTimeout))
+	})
+	go func() {
+		ticker := time.NewTicker(m.webSocketReadTimeout / 2)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if err := ws.WriteControl(websocket.PingMessage, nil, time.Now().Add(subscriptionWriteTimeout)); err != nil {
+					return
+				}
+			}
+		}
+	}()
+}
@@ -0,0 +1,64 @@
+package lib
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRouter_WebSocketRPC(t *testing.T) {
+	relay := newHeaderRelay(t, ExecutionPayloadWithTxRootV1{BlockHash: common.HexToHash("0x01"), BaseFeePerGas: big.NewInt(7), FeeRecipientDiff: big.NewInt(1)})
+	defer relay.Close()
+
+	store := NewStore()
+	store.SetForkchoiceResponse(context.Background(), "0x01", relay.URL, "0x01")
+	router, err := NewRouter(context.Background(), WithRelayURLs(relay.URL), WithStore(store), WithLogger(testLog),
+		WithCapabilityCheckInterval(0), WithWebSocketRPC(100*time.Millisecond))
+	require.Nil(t, err)
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	ws, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), nil)
+	require.Nil(t, err)
+	defer ws.Close()
+	call := func(message string) json.RawMessage {
+		require.Nil(t, ws.WriteMessage(websocket.TextMessage, []byte(message)))
+		_, resp, err := ws.ReadMessage()
+		require.Nil(t, err)
+		return resp
+	}
+
+	var resp subscriptionResponse
+	require.Nil(t, json.Unmarshal(call(`{"jsonrpc": "2.0", "id": "1", "method": "builder_getPayloadHeaderV1", "params": ["0x01"]}`), &resp))
+	require.Equal(t, `"1"`, string(resp.ID))
+	require.Nil(t, resp.Error)
+	require.Equal(t, common.HexToHash("0x01").Hex(), resp.Result.(map[string]interface{})["blockHash"])
+
+	var batch []subscriptionResponse
+	require.Nil(t, json.Unmarshal(call(`[{"jsonrpc": "2.0", "id": "2", "method": "builder_getPayloadHeaderV1", "params": ["0x01"]}, {"jsonrpc": "2.0", "id": "3", "method": "builder_subscribe", "params": []}]`), &batch))
+	require.Len(t, batch, 2)
+	require.Nil(t, batch[0].Error)
+	require.Equal(t, rpcErrMethodNotFound, batch[1].Error.Code, "subscriptions need WithBidSubscriptions")
+
+	// the connection is closed once it doesn't answer pings, which the client only does while reading
+	time.Sleep(300 * time.Millisecond)
+	require.Nil(t, ws.SetReadDeadline(time.Now().Add(time.Second)))
+	for {
+		_, _, err := ws.ReadMessage()
+		if err != nil {
+			var netErr net.Error
+			require.False(t, errors.As(err, &netErr) && netErr.Timeout(), "mev-boost closes the connection rather than the read timing out: %v", err)
+			break
+		}
+	}
+}
@@ -0,0 +1,112 @@
+package lib
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+
+	"golang.org/x/time/rate"
+)
+
+// whitelabelMethods are the JSON-RPC methods served to users in whitelabel mode, those relays serve to mev-boost
+var whitelabelMethods = map[string]bool{
+	methodForkchoiceUpdated:      true,
+	methodRelayGetHeader:         true,
+	methodRelayProposeBlock:      true,
+	methodRelayGetCapabilities:   true,
+	methodRelayRegisterValidator: true,
+	methodForkchoiceUpdatedV2:    true,
+	methodRelayGetHeaderV2:       true,
+	methodRelayProposeBlockV2:    true,
+}
+
+// whitelabelUsers authenticates the users of whitelabel mode by their API token and rate limits each of them
+type whitelabelUsers struct {
+	tokens    []string
+	perSecond float64
+	burst     int
+
+	mu       sync.Mutex
+	limiters map[string]*rate.Limiter // map[token]
+}
+
+func newWhitelabelUsers(tokens []string, perSecond float64, burst int) *whitelabelUsers {
+	return &whitelabelUsers{tokens: tokens, perSecond: perSecond, burst: burst, limiters: make(map[string]*rate.Limiter)}
+}
+
+// requestToken returns the user token of a request, as bearer token or as basic auth password. mev-boost sends the user
+// info of relay urls as basic auth, so downstream instances pass their token with relay urls like https://:<token>@host.
+func requestToken(r *http.Request) (string, bool) {
+	if auth := r.Header.Get("Authorization"); strings.HasPrefix(auth, "Bearer ") {
+		return strings.TrimPrefix(auth, "Bearer "), true
+	}
+	_, password, ok := r.BasicAuth()
+	return password, ok && password != ""
+}
+
+// authenticate reports whether token is one of the user tokens
+func (u *whitelabelUsers) authenticate(token string) bool {
+	return matchesToken(token, u.tokens)
+}
+
+func (u *whitelabelUsers) allow(token string) bool {
+	if u.perSecond <= 0 {
+		return true
+	}
+	u.mu.Lock()
+	limiter, ok := u.limiters[token]
+	if !ok {
+		limiter = rate.NewLimiter(rate.Limit(u.perSecond), u.burst)
+		u.limiters[token] = limiter
+	}
+	u.mu.Unlock()
+	return limiter.Allow()
+}
+
+// middleware rejects requests without a user token with 401 or 403, and requests over the rate limit of the user with 429
+func (u *whitelabelUsers) middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, ok := requestToken(r)
+		if !ok {
+			respondJSON(w, http.StatusUnauthorized, keymanagerError{"missing token"})
+			return
+		}
+		if !u.authenticate(token) {
+			respondJSON(w, http.StatusForbidden, keymanagerError{"invalid token"})
+			return
+		}
+		if !u.allow(token) {
+			http.Error(w, http.StatusText(http.StatusTooManyRequests), http.StatusTooManyRequests)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// relayMethodsOnly answers JSON-RPC requests for methods other than whitelabelMethods with a method not found error,
+// so the engine and builder methods meant for the consensus client aren't served to users
+func relayMethodsOnly(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(io.LimitReader(r.Body, maxRelayResponseSize))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		var req struct {
+			ID     json.RawMessage `json:"id"`
+			Method string          `json:"method"`
+		}
+		if err := json.Unmarshal(body, &req); err == nil && !whitelabelMethods[req.Method] {
+			respondJSON(w, http.StatusOK, map[string]interface{}{
+				"id":    req.ID,
+				"error": rpcError{Code: rpcErrMethodNotFound, Message: "method not found"},
+			})
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,81 @@
+package lib
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRouter_Whitelabel(t *testing.T) {
+	relay := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		resp, err := formatResponse(ForkChoiceResponse{PayloadStatus: PayloadStatus{Status: ForkchoiceStatusValid}, PayloadID: &hexutil.Bytes{1}})
+		require.Nil(t, err)
+		w.Write(resp)
+	}))
+	defer relay.Close()
+
+	router, err := NewRouter(context.Background(), WithRelayURLs(relay.URL), WithLogger(testLog), WithCapabilityCheckInterval(0),
+		WithWhitelabel([]string{"alice", "bob", "carol"}, 1, 2))
+	require.Nil(t, err)
+
+	call := func(token, method string) *httptest.ResponseRecorder {
+		body, err := formatRequestBody(method, []interface{}{map[string]interface{}{}})
+		require.Nil(t, err)
+		req := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		return rr
+	}
+	errorCode := func(rr *httptest.ResponseRecorder) int {
+		resp, err := parseRPCResponse(rr.Body.Bytes())
+		require.Nil(t, err)
+		if resp.Error == nil {
+			return 0
+		}
+		return resp.Error.Code
+	}
+
+	require.Equal(t, http.StatusUnauthorized, call("", methodForkchoiceUpdated).Code)
+	require.Equal(t, http.StatusForbidden, call("mallory", methodForkchoiceUpdated).Code)
+
+	rr := call("alice", methodForkchoiceUpdated)
+	require.Equal(t, http.StatusOK, rr.Code)
+	require.Equal(t, 0, errorCode(rr))
+	rr = call("alice", "builder_getPayloadHeaderV1")
+	require.Equal(t, http.StatusOK, rr.Code)
+	require.Equal(t, rpcErrMethodNotFound, errorCode(rr), "only relay methods are served")
+	require.Equal(t, http.StatusTooManyRequests, call("alice", methodForkchoiceUpdated).Code)
+	require.Equal(t, http.StatusOK, call("bob", methodForkchoiceUpdated).Code, "users are limited separately")
+
+	// downstream mev-boost instances send the token as password of the relay url
+	server := httptest.NewServer(router)
+	defer server.Close()
+	resp, err := makeRequest(context.Background(), http.DefaultClient, strings.Replace(server.URL, "://", "://:carol@", 1), methodForkchoiceUpdated, []interface{}{map[string]interface{}{}}, defaultResponseLimit)
+	require.Nil(t, err)
+	require.Nil(t, resp.Error)
+
+	req := httptest.NewRequest(http.MethodGet, "/mev-boost/v1/deliveries", nil)
+	req.Header.Set("Authorization", "Bearer bob")
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	require.Equal(t, http.StatusNotFound, rr.Code)
+
+	req = httptest.NewRequest(http.MethodGet, pathProposerPayloadDelivered, nil)
+	req.Header.Set("Authorization", "Bearer bob")
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	require.Equal(t, http.StatusOK, rr.Code)
+	var traces []BidTrace
+	require.Nil(t, json.Unmarshal(rr.Body.Bytes(), &traces))
+}